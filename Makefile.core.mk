@@ -315,6 +315,15 @@ endef
 
 $(foreach bin,$(BINARIES),$(eval $(call build-linux,$(bin))))
 
+# pilot-discovery with the PKCS#11 support of the CA, see security/pkg/pki/pkcs11. Loading a PKCS#11
+# module requires cgo, so unlike the release binaries it is dynamically linked and only runs on the
+# default base image, not on distroless.
+.PHONY: pilot-discovery-pkcs11
+pilot-discovery-pkcs11: $(ISTIO_OUT_LINUX)
+	GOOS=linux GOARCH=amd64 CGO_ENABLED=1 go build ${GOBUILDFLAGS} -tags pkcs11 \
+		-ldflags "$$(common/scripts/report_build_info.sh | sed 's/^/-X /' | tr '\n' ' ')" \
+		-o $(ISTIO_OUT_LINUX)/pkcs11/pilot-discovery ./pilot/cmd/pilot-discovery
+
 # Create helper targets for each binary, like "pilot-discovery"
 # As an optimization, these still build everything
 $(foreach bin,$(BINARIES),$(shell basename $(bin))): build
@@ -460,7 +469,15 @@ mixer-test: mixer-racetest
 galley-test: galley-racetest
 
 .PHONY: security-test
-security-test: security-racetest
+security-test: security-racetest pkcs11-test
+
+# The PKCS#11 tests run against SoftHSM, they fail if PKCS11_TEST_MODULE is not installed.
+PKCS11_TEST_MODULE ?= /usr/lib/softhsm/libsofthsm2.so
+
+.PHONY: pkcs11-test
+pkcs11-test:
+	PKCS11_TEST_MODULE=$(PKCS11_TEST_MODULE) CGO_ENABLED=1 go test ${GOBUILDFLAGS} ${T} -tags pkcs11 \
+		./security/pkg/pki/pkcs11/... ./pilot/pkg/bootstrap/...
 
 .PHONY: cni-test cni.cmd-test cni.install-test
 cni-test: cni.cmd-test cni.install-test
@@ -0,0 +1,301 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"istio.io/istio/security/pkg/pki/escrow"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const (
+	// The files of the offline root CA in the output directory.
+	offlineRootCertFile = "root-cert.pem"
+	offlineRootKeyFile  = "root-key.pem"
+
+	// The files of a cluster in the layout of the cacerts secret of the plugged-in CA.
+	pluggedCACertFile    = "ca-cert.pem"
+	pluggedCAKeyFile     = "ca-key.pem"
+	pluggedCertChainFile = "cert-chain.pem"
+	pluggedRootCertFile  = "root-cert.pem"
+
+	// minCAKeySize is the smallest RSA key size allowed for the generated CAs.
+	minCAKeySize = 2048
+)
+
+// caGenerateOptions are the options of 'istioctl experimental ca generate'.
+type caGenerateOptions struct {
+	outDir          string
+	clusters        []string
+	org             string
+	rootTTL         time.Duration
+	intermediateTTL time.Duration
+	keySize         int
+	ecSigAlg        string
+	san             string
+	escrowLocations []string
+	escrowThreshold int
+}
+
+func caCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ca",
+		Short: "Manage the certificate authorities of the mesh",
+		Long: `'istioctl experimental ca' manages the certificate authorities of the mesh.
+
+THESE COMMANDS ARE UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.HelpFunc()(cmd, args)
+			if len(args) != 0 {
+				return fmt.Errorf("unknown subcommand %q", args[0])
+			}
+			return nil
+		},
+	}
+	cmd.AddCommand(caGenerateCmd())
+	cmd.AddCommand(caEscrowCmd())
+	return cmd
+}
+
+func caGenerateCmd() *cobra.Command {
+	opts := caGenerateOptions{}
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate an offline root CA and the intermediate CAs of the clusters",
+		Long: `'istioctl experimental ca generate' generates an offline root CA, and an intermediate CA
+signed by it for each cluster, in the layout of the cacerts secret of the plugged-in CA.
+
+The root cert and key are written to root-cert.pem and root-key.pem of the output directory. If
+they already exist, they are used to sign the intermediate CAs, so that clusters can be added to
+the mesh later. The root key should be kept offline.
+
+The intermediate CA of each cluster is written to a directory named after the cluster, holding
+ca-cert.pem, ca-key.pem, cert-chain.pem and root-cert.pem. The files are verified as istiod does
+before they are written, and existing intermediate CAs are never overwritten.
+
+With --escrow-location, the generated root key is split into shares stored in the locations instead
+of being written to root-key.pem, and the existing root key is recovered from them when needed.`,
+		Example: `
+# Generate a root CA and the intermediate CAs of two clusters
+istioctl experimental ca generate --clusters cluster1,cluster2 --out-dir certs
+
+# Create the cacerts secret of cluster1
+kubectl create secret generic cacerts -n istio-system \
+    --from-file=certs/cluster1/ca-cert.pem --from-file=certs/cluster1/ca-key.pem \
+    --from-file=certs/cluster1/root-cert.pem --from-file=certs/cluster1/cert-chain.pem
+
+# Generate a root CA whose key is escrowed as 3 shares, any 2 of which recover it
+istioctl experimental ca generate --clusters cluster1 --out-dir certs --escrow-threshold 2 \
+    --escrow-location secret:istio-system/root-key-share-1 \
+    --escrow-location secret:backup/root-key-share-2 \
+    --escrow-location file:/media/usb/root-key-share-3.pem`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			locations, err := escrowLocations(opts.escrowLocations)
+			if err != nil {
+				return err
+			}
+			return generateCACerts(cmd.OutOrStdout(), opts, locations)
+		},
+	}
+	cmd.PersistentFlags().StringVar(&opts.outDir, "out-dir", ".", "The directory to write the certs to")
+	cmd.PersistentFlags().StringSliceVar(&opts.clusters, "clusters", nil,
+		"Comma separated names of the clusters to generate intermediate CAs for")
+	cmd.PersistentFlags().StringVar(&opts.org, "org", "Istio", "The organization of the CA certs")
+	cmd.PersistentFlags().DurationVar(&opts.rootTTL, "root-ttl", 10*365*24*time.Hour, "The lifetime of the root cert")
+	cmd.PersistentFlags().DurationVar(&opts.intermediateTTL, "intermediate-ttl", 2*365*24*time.Hour,
+		"The lifetime of the intermediate certs")
+	cmd.PersistentFlags().IntVar(&opts.keySize, "key-size", 4096, "The size of the RSA keys")
+	cmd.PersistentFlags().StringVar(&opts.ecSigAlg, "ec-sig-alg", "",
+		"Generate ECDSA keys instead of RSA keys with the given algorithm: one of ECDSA|ECDSA-P384|ECDSA-P521")
+	cmd.PersistentFlags().StringVar(&opts.san, "san", "istiod.istio-system.svc",
+		"Comma separated DNS names of the intermediate certs")
+	cmd.PersistentFlags().StringArrayVar(&opts.escrowLocations, "escrow-location", nil,
+		"Escrow the root key in the locations instead of writing it to the output directory. "+escrowLocationHelp)
+	cmd.PersistentFlags().IntVar(&opts.escrowThreshold, "escrow-threshold", 2,
+		"The number of escrowed key shares recovering the root key")
+	return cmd
+}
+
+// generateCACerts generates the root CA, unless it exists in the output directory, and the
+// intermediate CAs of the clusters. If escrowLocations is not empty, the root key is escrowed in them.
+func generateCACerts(out io.Writer, opts caGenerateOptions, escrowLocations []escrow.Location) error {
+	if len(opts.clusters) == 0 {
+		return fmt.Errorf("expecting at least one cluster in --clusters")
+	}
+	for _, c := range opts.clusters {
+		if errs := validation.IsDNS1123Label(c); len(errs) > 0 {
+			return fmt.Errorf("invalid cluster name %q: %v", c, errs)
+		}
+	}
+	switch util.SupportedECSignatureAlgorithms(opts.ecSigAlg) {
+	case "", util.EcdsaSigAlg, util.EcdsaP384SigAlg, util.EcdsaP521SigAlg:
+	default:
+		return fmt.Errorf("unsupported EC signature algorithm %q", opts.ecSigAlg)
+	}
+	if opts.ecSigAlg == "" && opts.keySize < minCAKeySize {
+		return fmt.Errorf("key size %d is smaller than %d", opts.keySize, minCAKeySize)
+	}
+	if opts.intermediateTTL <= 0 || opts.rootTTL <= 0 {
+		return fmt.Errorf("the lifetimes of the certs should be positive")
+	}
+	if len(escrowLocations) > 0 && (opts.escrowThreshold < 2 || opts.escrowThreshold > len(escrowLocations)) {
+		return fmt.Errorf("the escrow threshold %d is not between 2 and the number of escrow locations %d",
+			opts.escrowThreshold, len(escrowLocations))
+	}
+	for _, c := range opts.clusters {
+		if _, err := os.Stat(filepath.Join(opts.outDir, c, pluggedCACertFile)); err == nil {
+			return fmt.Errorf("the intermediate CA of cluster %s already exists in %s", c, filepath.Join(opts.outDir, c))
+		}
+	}
+	if err := os.MkdirAll(opts.outDir, 0755); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	rootCertFile := filepath.Join(opts.outDir, offlineRootCertFile)
+	rootKeyFile := filepath.Join(opts.outDir, offlineRootKeyFile)
+	rootCertPEM, rootKeyPEM, err := loadOfflineRoot(rootCertFile, rootKeyFile, escrowLocations)
+	switch {
+	case err != nil:
+		return err
+	case rootCertPEM != nil:
+		fmt.Fprintf(out, "Using the existing root CA in %s\n", rootCertFile)
+	default:
+		rootCertPEM, rootKeyPEM, err = util.GenCertKeyFromOptions(util.CertOptions{
+			NotBefore:    now,
+			TTL:          opts.rootTTL,
+			Org:          opts.org,
+			IsCA:         true,
+			IsSelfSigned: true,
+			RSAKeySize:   opts.keySize,
+			ECSigAlg:     util.SupportedECSignatureAlgorithms(opts.ecSigAlg),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to generate the root CA: %v", err)
+		}
+		if len(escrowLocations) > 0 {
+			if err := splitCAKey(out, rootKeyPEM, opts.escrowThreshold, escrowLocations); err != nil {
+				return fmt.Errorf("failed to escrow the root key: %v", err)
+			}
+		} else if err := ioutil.WriteFile(rootKeyFile, rootKeyPEM, 0600); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(rootCertFile, rootCertPEM, 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Generated the root CA in %s\n", rootCertFile)
+	}
+	rootCert, err := util.ParsePemEncodedCertificate(rootCertPEM)
+	if err != nil {
+		return err
+	}
+	rootKey, err := util.ParsePemEncodedKey(rootKeyPEM)
+	if err != nil {
+		return err
+	}
+	if now.Add(opts.intermediateTTL).After(rootCert.NotAfter) {
+		return fmt.Errorf("the intermediate certs would expire after the root cert, which expires at %s",
+			rootCert.NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	for _, c := range opts.clusters {
+		certPEM, keyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+			Host:       opts.san,
+			NotBefore:  now,
+			TTL:        opts.intermediateTTL,
+			SignerCert: rootCert,
+			SignerPriv: rootKey,
+			Org:        opts.org,
+			IsCA:       true,
+			// The intermediate CAs sign workload certs only.
+			MaxPathLenZero: true,
+			RSAKeySize:     opts.keySize,
+			ECSigAlg:       util.SupportedECSignatureAlgorithms(opts.ecSigAlg),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to generate the intermediate CA of cluster %s: %v", c, err)
+		}
+		chainPEM := append(append([]byte{}, certPEM...), rootCertPEM...)
+		// Verify the certs as istiod does when loading the plugged-in CA.
+		if err := util.Verify(certPEM, keyPEM, chainPEM, rootCertPEM); err != nil {
+			return fmt.Errorf("the intermediate CA of cluster %s is invalid: %v", c, err)
+		}
+		dir := filepath.Join(opts.outDir, c)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		for _, f := range []struct {
+			name string
+			data []byte
+			perm os.FileMode
+		}{
+			{pluggedCAKeyFile, keyPEM, 0600},
+			{pluggedCACertFile, certPEM, 0644},
+			{pluggedCertChainFile, chainPEM, 0644},
+			{pluggedRootCertFile, rootCertPEM, 0644},
+		} {
+			if err := ioutil.WriteFile(filepath.Join(dir, f.name), f.data, f.perm); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(out, "Generated the intermediate CA of cluster %s in %s\n", c, dir)
+	}
+	return nil
+}
+
+// loadOfflineRoot returns the root cert and key in certFile and keyFile, or nil if neither exists.
+// If keyFile does not exist, the key is recovered from escrowLocations.
+func loadOfflineRoot(certFile, keyFile string, escrowLocations []escrow.Location) (certPEM, keyPEM []byte, err error) {
+	certPEM, certErr := ioutil.ReadFile(certFile)
+	keyPEM, keyErr := ioutil.ReadFile(keyFile)
+	if certErr == nil && os.IsNotExist(keyErr) && len(escrowLocations) > 0 {
+		if keyPEM, keyErr = escrow.Recover(context.Background(), escrowLocations); keyErr != nil {
+			return nil, nil, fmt.Errorf("failed to recover the root key from escrow: %v", keyErr)
+		}
+	}
+	if os.IsNotExist(certErr) && os.IsNotExist(keyErr) {
+		return nil, nil, nil
+	}
+	if certErr != nil {
+		return nil, nil, certErr
+	}
+	if keyErr != nil {
+		return nil, nil, keyErr
+	}
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return nil, nil, fmt.Errorf("the root key in %s does not match the root cert in %s: %v", keyFile, certFile, err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !cert.IsCA || !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return nil, nil, fmt.Errorf("the cert in %s is not a root CA cert", certFile)
+	}
+	return certPEM, keyPEM, nil
+}
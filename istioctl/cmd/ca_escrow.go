@@ -0,0 +1,193 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/security/pkg/pki/escrow"
+)
+
+const escrowLocationHelp = `A location of a key share, one of secret:<namespace>/<name> or file:<path>. ` +
+	`The share is encrypted with a KMS key when prefixed by local-kms:<key encryption key file>@ ` +
+	`or aws-kms:<region>/<key id>@`
+
+// caEscrowOptions are the options of 'istioctl experimental ca escrow'.
+type caEscrowOptions struct {
+	locations []string
+	threshold int
+	keyFile   string
+	certFile  string
+	outFile   string
+}
+
+func caEscrowCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "escrow",
+		Short: "Escrow a CA key as secret shares, and recover it from them",
+		Long: `'istioctl experimental ca escrow' splits a CA private key into shares stored in separate
+locations, any threshold of which recover the key, so that no single location holds the complete key.
+
+Each share is stored in a Kubernetes secret or a file, optionally encrypted with a KMS key. Existing
+shares are never overwritten.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.HelpFunc()(cmd, args)
+			if len(args) != 0 {
+				return fmt.Errorf("unknown subcommand %q", args[0])
+			}
+			return nil
+		},
+	}
+	cmd.AddCommand(caEscrowSplitCmd())
+	cmd.AddCommand(caEscrowRecoverCmd())
+	return cmd
+}
+
+func caEscrowSplitCmd() *cobra.Command {
+	opts := caEscrowOptions{}
+	cmd := &cobra.Command{
+		Use:   "split",
+		Short: "Split a CA key into shares stored in separate locations",
+		Example: `
+# Split the root key into 3 shares, any 2 of which recover it
+istioctl experimental ca escrow split --key certs/root-key.pem --threshold 2 \
+    --location secret:istio-system/root-key-share-1 \
+    --location aws-kms:us-east-1/alias/istio-escrow@secret:backup/root-key-share-2 \
+    --location file:/media/usb/root-key-share-3.pem`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keyPEM, err := ioutil.ReadFile(opts.keyFile)
+			if err != nil {
+				return err
+			}
+			locations, err := escrowLocations(opts.locations)
+			if err != nil {
+				return err
+			}
+			if err := splitCAKey(cmd.OutOrStdout(), keyPEM, opts.threshold, locations); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Remove %s once the key is verified to be recoverable\n", opts.keyFile)
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringVar(&opts.keyFile, "key", "", "The file of the CA key to split")
+	cmd.PersistentFlags().StringArrayVar(&opts.locations, "location", nil, escrowLocationHelp)
+	cmd.PersistentFlags().IntVar(&opts.threshold, "threshold", 2, "The number of shares recovering the key")
+	return cmd
+}
+
+func caEscrowRecoverCmd() *cobra.Command {
+	opts := caEscrowOptions{}
+	cmd := &cobra.Command{
+		Use:   "recover",
+		Short: "Recover a CA key from its shares",
+		Long: `'istioctl experimental ca escrow recover' loads the key shares in the locations and recovers
+the CA key from them. Locations that cannot be read are skipped, as long as enough shares are loaded.`,
+		Example: `
+# Recover the root key from 2 of its shares, and verify it matches the root cert
+istioctl experimental ca escrow recover --out-file root-key.pem --cert certs/root-cert.pem \
+    --location secret:istio-system/root-key-share-1 \
+    --location file:/media/usb/root-key-share-3.pem`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.outFile == "" {
+				return fmt.Errorf("expecting the file to write the key to in --out-file")
+			}
+			if _, err := os.Stat(opts.outFile); err == nil {
+				return fmt.Errorf("%s already exists", opts.outFile)
+			}
+			locations, err := escrowLocations(opts.locations)
+			if err != nil {
+				return err
+			}
+			keyPEM, err := escrow.Recover(context.Background(), locations)
+			if err != nil {
+				return err
+			}
+			if opts.certFile != "" {
+				certPEM, err := ioutil.ReadFile(opts.certFile)
+				if err != nil {
+					return err
+				}
+				if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+					return fmt.Errorf("the recovered key does not match the cert in %s: %v", opts.certFile, err)
+				}
+			}
+			f, err := os.OpenFile(opts.outFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+			if err != nil {
+				return err
+			}
+			if _, err := f.Write(keyPEM); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Recovered the key to %s\n", opts.outFile)
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringArrayVar(&opts.locations, "location", nil, escrowLocationHelp)
+	cmd.PersistentFlags().StringVarP(&opts.outFile, "out-file", "o", "", "The file to write the recovered key to")
+	cmd.PersistentFlags().StringVar(&opts.certFile, "cert", "", "The cert the recovered key should match")
+	return cmd
+}
+
+// escrowLocations parses the location specs. The Kubernetes client is only created if a share is
+// stored in a secret.
+func escrowLocations(specs []string) ([]escrow.Location, error) {
+	var client corev1.SecretsGetter
+	getClient := func() (corev1.SecretsGetter, error) {
+		if client == nil {
+			c, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return nil, err
+			}
+			client = c.CoreV1()
+		}
+		return client, nil
+	}
+	locations := make([]escrow.Location, 0, len(specs))
+	for _, spec := range specs {
+		loc, err := escrow.ParseLocation(spec, getClient)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, loc)
+	}
+	return locations, nil
+}
+
+// splitCAKey splits keyPEM into shares stored in locations, any threshold of which recover it.
+func splitCAKey(out io.Writer, keyPEM []byte, threshold int, locations []escrow.Location) error {
+	if err := escrow.Split(context.Background(), keyPEM, threshold, locations); err != nil {
+		return err
+	}
+	for _, loc := range locations {
+		fmt.Fprintf(out, "Stored a key share in %s\n", loc)
+	}
+	fmt.Fprintf(out, "Any %d of the %d key shares recover the key\n", threshold, len(locations))
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func runCAEscrow(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd := GetRootCmd(append([]string{"experimental", "ca", "escrow"}, args...))
+	rootCmd.SetOutput(&out)
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+func TestCAEscrow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ca-escrow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	client := fake.NewSimpleClientset()
+	interfaceFactory = func(_ string) (kubernetes.Interface, error) {
+		return client, nil
+	}
+
+	// The root key is escrowed instead of written to the output directory.
+	escrowArgs := []string{
+		"--escrow-threshold", "2",
+		"--escrow-location", "secret:istio-system/share-1",
+		"--escrow-location", "secret:backup/share-2",
+		"--escrow-location", "file:" + filepath.Join(dir, "share-3.pem"),
+	}
+	if out, err := runCAGenerate(t, dir, append([]string{"--clusters", "cluster1", "--escrow-threshold", "4"},
+		escrowArgs[2:]...)...); err == nil {
+		t.Errorf("expected an error for a threshold above the number of shares, got:\n%s", out)
+	}
+	out, err := runCAGenerate(t, dir, append([]string{"--clusters", "cluster1"}, escrowArgs...)...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Any 2 of the 3 key shares recover the key") {
+		t.Errorf("expected the root key to be escrowed, got:\n%s", out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, offlineRootKeyFile)); !os.IsNotExist(err) {
+		t.Errorf("the escrowed root key is written to the output directory")
+	}
+
+	// The escrowed root key signs the intermediate CAs of added clusters.
+	out, err = runCAGenerate(t, dir, append([]string{"--clusters", "cluster2"}, escrowArgs...)...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Using the existing root CA") {
+		t.Errorf("expected the existing root CA to be used, got:\n%s", out)
+	}
+
+	// The root key is recovered from any 2 of the shares.
+	keyFile := filepath.Join(dir, offlineRootKeyFile)
+	recoverArgs := []string{"recover", "--out-file", keyFile, "--cert", filepath.Join(dir, offlineRootCertFile),
+		"--location", "secret:backup/share-2", "--location", "file:" + filepath.Join(dir, "share-3.pem")}
+	if out, err := runCAEscrow(t, recoverArgs[:7]...); err == nil {
+		t.Errorf("expected an error recovering from a single share, got:\n%s", out)
+	}
+	if out, err := runCAEscrow(t, recoverArgs...); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("got mode %v for the recovered key, want 0600", info.Mode().Perm())
+	}
+	if out, err := runCAEscrow(t, recoverArgs...); err == nil {
+		t.Errorf("expected an error overwriting the recovered key, got:\n%s", out)
+	}
+
+	// The recovered key is split again into new shares.
+	out, err = runCAEscrow(t, "split", "--key", keyFile, "--threshold", "2",
+		"--location", "secret:istio-system/share-4", "--location", "file:"+filepath.Join(dir, "share-5.pem"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+	otherKeyFile := filepath.Join(dir, "other-key.pem")
+	if out, err := runCAEscrow(t, "recover", "--out-file", otherKeyFile,
+		"--location", "secret:istio-system/share-4", "--location", "file:"+filepath.Join(dir, "share-5.pem")); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+	key, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ioutil.ReadFile(otherKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, otherKey) {
+		t.Errorf("the key recovered from the new shares differs")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func runCAGenerate(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd := GetRootCmd(append([]string{"experimental", "ca", "generate", "--out-dir", dir, "--key-size", "2048"}, args...))
+	rootCmd.SetOutput(&out)
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+func TestCAGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ca-generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, args := range [][]string{
+		{},
+		{"--clusters", "Cluster_1"},
+		{"--clusters", "cluster1", "--key-size", "1024"},
+		{"--clusters", "cluster1", "--ec-sig-alg", "DSA"},
+		{"--clusters", "cluster1", "--root-ttl", "1h", "--intermediate-ttl", "2h"},
+	} {
+		if out, err := runCAGenerate(t, filepath.Join(dir, "invalid"), args...); err == nil {
+			t.Errorf("expected an error for %v, got:\n%s", args, out)
+		}
+	}
+
+	out, err := runCAGenerate(t, dir, "--clusters", "cluster1,cluster2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Generated the root CA") {
+		t.Errorf("expected the root CA to be generated, got:\n%s", out)
+	}
+	rootCertPEM, err := ioutil.ReadFile(filepath.Join(dir, offlineRootCertFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCluster := func(cluster string) {
+		clusterDir := filepath.Join(dir, cluster)
+		bundle, err := util.NewVerifiedKeyCertBundleFromFile(filepath.Join(clusterDir, pluggedCACertFile),
+			filepath.Join(clusterDir, pluggedCAKeyFile), filepath.Join(clusterDir, pluggedCertChainFile),
+			filepath.Join(clusterDir, pluggedRootCertFile))
+		if err != nil {
+			t.Fatalf("invalid intermediate CA of cluster %s: %v", cluster, err)
+		}
+		if !bytes.Equal(bundle.GetRootCertPem(), rootCertPEM) {
+			t.Errorf("the intermediate CA of cluster %s is not signed by the root CA", cluster)
+		}
+		cert, _, _, _ := bundle.GetAll()
+		if !cert.IsCA || !cert.MaxPathLenZero || cert.DNSNames[0] != "istiod.istio-system.svc" {
+			t.Errorf("unexpected intermediate cert of cluster %s: %+v", cluster, cert)
+		}
+		info, err := os.Stat(filepath.Join(clusterDir, pluggedCAKeyFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("got mode %v for the key of cluster %s, want 0600", info.Mode().Perm(), cluster)
+		}
+	}
+	verifyCluster("cluster1")
+	verifyCluster("cluster2")
+
+	// The existing root CA signs the intermediate CAs of added clusters.
+	out, err = runCAGenerate(t, dir, "--clusters", "cluster3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Using the existing root CA") {
+		t.Errorf("expected the existing root CA to be used, got:\n%s", out)
+	}
+	verifyCluster("cluster3")
+
+	// Existing intermediate CAs are not overwritten.
+	if out, err := runCAGenerate(t, dir, "--clusters", "cluster1"); err == nil {
+		t.Errorf("expected an error for an existing intermediate CA, got:\n%s", out)
+	}
+
+	// A root key not matching the root cert is rejected.
+	otherDir := filepath.Join(dir, "other")
+	if _, err := runCAGenerate(t, otherDir, "--clusters", "cluster1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	otherKey, err := ioutil.ReadFile(filepath.Join(otherDir, offlineRootKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, offlineRootKeyFile), otherKey, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := runCAGenerate(t, dir, "--clusters", "cluster4"); err == nil {
+		t.Errorf("expected an error for a mismatched root key, got:\n%s", out)
+	}
+}
@@ -149,6 +149,9 @@ debug and diagnose their Istio mesh.
 	experimentalCmd.AddCommand(softGraduatedCmd(Analyze()))
 	experimentalCmd.AddCommand(vmBootstrapCommand())
 	experimentalCmd.AddCommand(waitCmd())
+	experimentalCmd.AddCommand(secretCmd())
+	experimentalCmd.AddCommand(caCmd())
+	experimentalCmd.AddCommand(vmProvisionCmd())
 
 	postInstallCmd.AddCommand(Webhook())
 	experimentalCmd.AddCommand(postInstallCmd)
@@ -0,0 +1,812 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/security/pkg/k8s/chiron"
+	"istio.io/istio/security/pkg/k8s/controller"
+	"istio.io/istio/security/pkg/k8s/controllerstatus"
+	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// secretNow returns the current time, overridden in tests.
+var secretNow = time.Now
+
+// secretTypes are the types of the secrets holding certs issued by Istio.
+var secretTypes = []v1.SecretType{controller.IstioSecretType, chiron.IstioDNSSecretType}
+
+// secretInfo describes the cert held in a secret.
+type secretInfo struct {
+	Namespace       string    `json:"namespace"`
+	Name            string    `json:"name"`
+	Type            string    `json:"type"`
+	Identities      []string  `json:"identities,omitempty"`
+	SerialNumber    string    `json:"serialNumber,omitempty"`
+	NotBefore       time.Time `json:"notBefore,omitempty"`
+	NotAfter        time.Time `json:"notAfter,omitempty"`
+	RenewAt         time.Time `json:"renewAt,omitempty"`
+	RootFingerprint string    `json:"rootFingerprint,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+func secretCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secret",
+		Short: "Inspect the certificate secrets issued by Istio",
+		Long: `'istioctl experimental secret' inspects the secrets of type istio.io/key-and-cert and
+istio.io/dns-key-and-cert holding the certs issued by Istio.
+
+THESE COMMANDS ARE UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.HelpFunc()(cmd, args)
+			if len(args) != 0 {
+				return fmt.Errorf("unknown subcommand %q", args[0])
+			}
+			return nil
+		},
+	}
+	cmd.AddCommand(secretListCmd())
+	cmd.AddCommand(secretRotateCmd())
+	cmd.AddCommand(secretVerifyCmd())
+	cmd.AddCommand(secretStatusCmd())
+	cmd.AddCommand(secretDescribeCmd())
+	return cmd
+}
+
+func secretListCmd() *cobra.Command {
+	var allNamespaces bool
+	var outputFormat string
+	var gracePeriodRatio float64
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the certificate secrets issued by Istio",
+		Long: `'istioctl experimental secret list' lists the certificate secrets issued by Istio with the
+identities, serial number, expiry, renewal time and root cert fingerprint of their certs.
+
+The renewal time is estimated from the grace period ratio, the fraction of the cert lifetime
+before expiry in which the cert is renewed.`,
+		Example: `
+# List the certificate secrets in the default namespace
+istioctl experimental secret list
+
+# List the certificate secrets in all namespaces as JSON
+istioctl experimental secret list -A -o json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if gracePeriodRatio < 0 || gracePeriodRatio > 1 {
+				return fmt.Errorf("grace period ratio %v should be within [0, 1]", gracePeriodRatio)
+			}
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			ns := handlers.HandleNamespace(namespace, defaultNamespace)
+			if allNamespaces {
+				ns = metav1.NamespaceAll
+			}
+			infos, err := listIstioSecrets(client, ns, gracePeriodRatio)
+			if err != nil {
+				return err
+			}
+			switch outputFormat {
+			case summaryOutput:
+				return printSecretInfos(cmd.OutOrStdout(), infos)
+			case jsonOutput:
+				out, err := json.MarshalIndent(infos, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(cmd.OutOrStdout(), string(out))
+				return err
+			default:
+				return fmt.Errorf("output format %q not supported", outputFormat)
+			}
+		},
+	}
+	cmd.PersistentFlags().BoolVarP(&allNamespaces, "all-namespaces", "A", false,
+		"List the secrets in all namespaces")
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+	cmd.PersistentFlags().Float64Var(&gracePeriodRatio, "grace-period-ratio", 0.5,
+		"The fraction of the cert lifetime before expiry in which certs are renewed")
+	return cmd
+}
+
+func secretRotateCmd() *cobra.Command {
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "rotate [<secret>]",
+		Short: "Rotate the certs of DNS certificate secrets immediately",
+		Long: `'istioctl experimental secret rotate' requests the immediate rotation of the cert in a
+istio.io/dns-key-and-cert secret, or in all of them in the namespace with --all, by annotating
+the secrets with ` + chiron.ForceRotationAnnotation + `. The istiod certificate controller managing
+the secrets issues new certs and removes the annotation.
+
+Use it for incident response and rotation drills.`,
+		Example: `
+# Rotate the cert of the istio-galley secret
+istioctl experimental secret rotate istio-galley-dns-cert -n istio-system
+
+# Rotate the certs of all DNS certificate secrets in istio-system
+istioctl experimental secret rotate --all -n istio-system`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) == 1) {
+				return fmt.Errorf("expecting either a secret name or --all")
+			}
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			ns := handlers.HandleNamespace(namespace, defaultNamespace)
+			var names []string
+			if all {
+				secrets, err := client.CoreV1().Secrets(ns).List(context.TODO(), metav1.ListOptions{
+					FieldSelector: "type=" + string(chiron.IstioDNSSecretType),
+				})
+				if err != nil {
+					return err
+				}
+				for _, secret := range secrets.Items {
+					if secret.Type == chiron.IstioDNSSecretType {
+						names = append(names, secret.Name)
+					}
+				}
+				sort.Strings(names)
+			} else {
+				secret, err := client.CoreV1().Secrets(ns).Get(context.TODO(), args[0], metav1.GetOptions{})
+				if err != nil {
+					return fmt.Errorf("secret %q does not exist", args[0])
+				}
+				if secret.Type != chiron.IstioDNSSecretType {
+					return fmt.Errorf("secret %q is of type %s, not %s", args[0], secret.Type, chiron.IstioDNSSecretType)
+				}
+				names = []string{secret.Name}
+			}
+			patch, err := json.Marshal(map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{
+						chiron.ForceRotationAnnotation: secretNow().UTC().Format(time.RFC3339),
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				if _, err := client.CoreV1().Secrets(ns).Patch(context.TODO(), name, types.MergePatchType, patch,
+					metav1.PatchOptions{}); err != nil {
+					return fmt.Errorf("failed to request the rotation of secret %s.%s: %v", name, ns, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Requested the rotation of secret %s.%s\n", name, ns)
+			}
+			if len(names) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "No DNS certificate secrets found in namespace %s.\n", ns)
+			}
+			return nil
+		},
+	}
+	cmd.PersistentFlags().BoolVar(&all, "all", false, "Rotate all DNS certificate secrets in the namespace")
+	return cmd
+}
+
+func secretVerifyCmd() *cobra.Command {
+	var caSecretName, trustDomain string
+	var gracePeriodRatio float64
+	cmd := &cobra.Command{
+		Use:   "verify <secret>",
+		Short: "Verify a certificate secret against the Istio CA",
+		Long: `'istioctl experimental secret verify' checks the cert in a certificate secret issued by Istio:
+the private key matches the cert, the cert chains up to the root cert in the secret, the root cert
+is the one of the Istio CA, the cert holds the SPIFFE identity of the service account of the
+secret, and the cert is not due for renewal or expired.
+
+The root cert of the Istio CA is read from the --ca-secret secret in the Istio namespace.`,
+		Example: `
+# Verify the cert of the foo service account
+istioctl experimental secret verify istio.foo -n default
+
+# Verify against a plugged-in CA
+istioctl experimental secret verify istio.foo -n default --ca-secret cacerts`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			ns := handlers.HandleNamespace(namespace, defaultNamespace)
+			secret, err := client.CoreV1().Secrets(ns).Get(context.TODO(), args[0], metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("secret %q does not exist", args[0])
+			}
+			caSecret, err := client.CoreV1().Secrets(istioNamespace).Get(context.TODO(), caSecretName, metav1.GetOptions{})
+			if err != nil {
+				caSecret = nil
+			}
+			checks := verifySecret(secret, caSecret, trustDomain, gracePeriodRatio, secretNow())
+			failed := 0
+			for _, c := range checks {
+				if c.level == checkFail {
+					failed++
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%-5s %s\n", c.level, c.message)
+			}
+			if failed > 0 {
+				return fmt.Errorf("secret %s.%s failed %d check(s)", args[0], ns, failed)
+			}
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringVar(&caSecretName, "ca-secret", ca.CASecret,
+		"The secret in the Istio namespace holding the CA cert, e.g. cacerts for a plugged-in CA")
+	cmd.PersistentFlags().StringVar(&trustDomain, "trust-domain", constants.DefaultKubernetesDomain,
+		"The trust domain of the SPIFFE identities")
+	cmd.PersistentFlags().Float64Var(&gracePeriodRatio, "grace-period-ratio", 0.5,
+		"The fraction of the cert lifetime before expiry in which certs are renewed")
+	return cmd
+}
+
+func secretStatusCmd() *cobra.Command {
+	var outputFormat string
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of the certificate controllers of istiod",
+		Long: `'istioctl experimental secret status' shows the last status written by the certificate
+controllers of istiod: the version and pod of the istiod writing it, the number of managed, pending
+and failing secrets, the fingerprint of the root cert and the last reconcile times.
+
+istiod writes the status to the ` + controllerstatus.DefaultName + ` ConfigMap of the Istio namespace
+when CA_CONTROLLER_STATUS_ENABLED is set.`,
+		Example: `
+# Show the status of the certificate controllers
+istioctl experimental secret status -i istio-system`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			report, err := controllerstatus.Read(context.TODO(), client.CoreV1(), istioNamespace, controllerstatus.DefaultName)
+			if err != nil {
+				return err
+			}
+			switch outputFormat {
+			case summaryOutput:
+				return printControllerStatus(cmd.OutOrStdout(), report)
+			case jsonOutput:
+				out, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(cmd.OutOrStdout(), string(out))
+				return err
+			default:
+				return fmt.Errorf("output format %q not supported", outputFormat)
+			}
+		},
+	}
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+	return cmd
+}
+
+func secretDescribeCmd() *cobra.Command {
+	var file, outputFormat string
+	cmd := &cobra.Command{
+		Use:   "describe [<secret>]",
+		Short: "Describe the certs held in a secret or a file",
+		Long: `'istioctl experimental secret describe' decodes the PEM-encoded certs held in any secret, or in
+a file with --file, and prints the details relevant to Istio: the SPIFFE identities and their trust
+domains, the DNS names, whether the common name duplicates a SAN, the issuer in the chain, the
+validity and the key type and size.
+
+Every key of the secret holding certs is described, e.g. cert-chain.pem and root-cert.pem of an
+Istio secret, ca-cert.pem of cacerts or tls.crt and ca.crt of a TLS secret.`,
+		Example: `
+# Describe the certs of the foo service account
+istioctl experimental secret describe istio.foo -n default
+
+# Describe the certs of a plugged-in CA
+istioctl experimental secret describe cacerts -n istio-system
+
+# Describe the certs in a file
+istioctl experimental secret describe -f cert-chain.pem`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (file == "") == (len(args) == 0) {
+				return fmt.Errorf("expecting either a secret name or --file")
+			}
+			data := map[string][]byte{}
+			if file != "" {
+				content, err := ioutil.ReadFile(file)
+				if err != nil {
+					return err
+				}
+				data[file] = content
+			} else {
+				client, err := interfaceFactory(kubeconfig)
+				if err != nil {
+					return err
+				}
+				ns := handlers.HandleNamespace(namespace, defaultNamespace)
+				secret, err := client.CoreV1().Secrets(ns).Get(context.TODO(), args[0], metav1.GetOptions{})
+				if err != nil {
+					return fmt.Errorf("secret %q does not exist", args[0])
+				}
+				data = secret.Data
+			}
+			descriptions, err := describeCerts(data, secretNow())
+			if err != nil {
+				return err
+			}
+			switch outputFormat {
+			case summaryOutput:
+				return printCertDescriptions(cmd.OutOrStdout(), descriptions)
+			case jsonOutput:
+				out, err := json.MarshalIndent(descriptions, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(cmd.OutOrStdout(), string(out))
+				return err
+			default:
+				return fmt.Errorf("output format %q not supported", outputFormat)
+			}
+		},
+	}
+	cmd.PersistentFlags().StringVarP(&file, "file", "f", "", "The file holding the PEM-encoded certs to describe")
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+	return cmd
+}
+
+// certDescription describes a cert held in a secret or a file.
+type certDescription struct {
+	// Source is the secret key or file holding the cert, with the index of the cert in it.
+	Source       string    `json:"source"`
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serialNumber"`
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `json:"notAfter"`
+	Expired      bool      `json:"expired"`
+	KeyType      string    `json:"keyType"`
+	IsCA         bool      `json:"isCA"`
+	SPIFFEIDs    []string  `json:"spiffeIDs,omitempty"`
+	TrustDomains []string  `json:"trustDomains,omitempty"`
+	DNSNames     []string  `json:"dnsNames,omitempty"`
+	IPAddresses  []string  `json:"ipAddresses,omitempty"`
+	// DualUseCN is set when the common name duplicates a SAN, as in the certs issued with dual use.
+	DualUseCN bool `json:"dualUseCN"`
+	// IssuedBy is the source of the issuer of the cert, "self-signed", or empty if the issuer is not
+	// among the described certs.
+	IssuedBy    string `json:"issuedBy,omitempty"`
+	Fingerprint string `json:"sha256Fingerprint"`
+}
+
+// describeCerts describes the PEM-encoded certs in the values of data, ordered by key.
+func describeCerts(data map[string][]byte, now time.Time) ([]certDescription, error) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var certs []*x509.Certificate
+	var sources []string
+	for _, k := range keys {
+		// Keys holding private keys or other data are skipped.
+		i := 0
+		for block, rest := pem.Decode(data[k]); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("%s holds an invalid cert: %v", k, err)
+			}
+			certs = append(certs, cert)
+			sources = append(sources, fmt.Sprintf("%s[%d]", k, i))
+			i++
+		}
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM-encoded certs found")
+	}
+
+	descriptions := make([]certDescription, 0, len(certs))
+	for i, cert := range certs {
+		d := certDescription{
+			Source:       sources[i],
+			Subject:      cert.Subject.String(),
+			Issuer:       cert.Issuer.String(),
+			SerialNumber: cert.SerialNumber.Text(16),
+			NotBefore:    cert.NotBefore,
+			NotAfter:     cert.NotAfter,
+			Expired:      !now.Before(cert.NotAfter),
+			KeyType:      certKeyType(cert),
+			IsCA:         cert.IsCA,
+			DNSNames:     cert.DNSNames,
+			Fingerprint:  certFingerprint(cert.Raw),
+		}
+		sans := append([]string{}, cert.DNSNames...)
+		for _, u := range cert.URIs {
+			sans = append(sans, u.String())
+			if u.Scheme == "spiffe" {
+				d.SPIFFEIDs = append(d.SPIFFEIDs, u.String())
+				if !containsString(d.TrustDomains, u.Host) {
+					d.TrustDomains = append(d.TrustDomains, u.Host)
+				}
+			}
+		}
+		for _, ip := range cert.IPAddresses {
+			d.IPAddresses = append(d.IPAddresses, ip.String())
+			sans = append(sans, ip.String())
+		}
+		for _, san := range sans {
+			if cn := cert.Subject.CommonName; cn != "" && cn == san {
+				d.DualUseCN = true
+			}
+		}
+		for j, parent := range certs {
+			if cert.CheckSignatureFrom(parent) == nil && bytes.Equal(cert.RawIssuer, parent.RawSubject) {
+				if j == i {
+					d.IssuedBy = "self-signed"
+				} else {
+					d.IssuedBy = sources[j]
+				}
+				break
+			}
+		}
+		descriptions = append(descriptions, d)
+	}
+	return descriptions, nil
+}
+
+// containsString returns whether s holds v.
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// certKeyType returns the type and size of the public key of cert, e.g. RSA 2048 or ECDSA P-256.
+func certKeyType(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return cert.PublicKeyAlgorithm.String()
+	}
+}
+
+func printCertDescriptions(writer io.Writer, descriptions []certDescription) error {
+	now := secretNow()
+	w := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	for i, d := range descriptions {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s:\n", d.Source)
+		fmt.Fprintf(w, "  Subject:\t%s\n", d.Subject)
+		fmt.Fprintf(w, "  Issuer:\t%s\n", d.Issuer)
+		issuedBy := d.IssuedBy
+		if issuedBy == "" {
+			issuedBy = "not found"
+		}
+		fmt.Fprintf(w, "  Issued by:\t%s\n", issuedBy)
+		fmt.Fprintf(w, "  Serial:\t%s\n", d.SerialNumber)
+		expiry := "expired"
+		if !d.Expired {
+			expiry = "expires in " + d.NotAfter.Sub(now).Round(time.Minute).String()
+		}
+		fmt.Fprintf(w, "  Validity:\t%s to %s (%s)\n", d.NotBefore.UTC().Format(time.RFC3339),
+			d.NotAfter.UTC().Format(time.RFC3339), expiry)
+		fmt.Fprintf(w, "  Key:\t%s\n", d.KeyType)
+		fmt.Fprintf(w, "  CA:\t%t\n", d.IsCA)
+		if len(d.SPIFFEIDs) > 0 {
+			fmt.Fprintf(w, "  SPIFFE IDs:\t%s\n", strings.Join(d.SPIFFEIDs, ","))
+			fmt.Fprintf(w, "  Trust domains:\t%s\n", strings.Join(d.TrustDomains, ","))
+		}
+		if len(d.DNSNames) > 0 {
+			fmt.Fprintf(w, "  DNS names:\t%s\n", strings.Join(d.DNSNames, ","))
+		}
+		if len(d.IPAddresses) > 0 {
+			fmt.Fprintf(w, "  IP addresses:\t%s\n", strings.Join(d.IPAddresses, ","))
+		}
+		fmt.Fprintf(w, "  Dual-use CN:\t%t\n", d.DualUseCN)
+		fmt.Fprintf(w, "  SHA-256:\t%s\n", d.Fingerprint)
+	}
+	return w.Flush()
+}
+
+func printControllerStatus(writer io.Writer, report *controllerstatus.Report) error {
+	formatTime := func(t *metav1.Time) string {
+		if t == nil {
+			return "never"
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	w := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Version:\t%s\n", report.Version)
+	fmt.Fprintf(w, "Leader:\t%s\n", report.Leader)
+	fmt.Fprintf(w, "Root cert SHA-256:\t%s\n", report.RootCertSHA256)
+	fmt.Fprintf(w, "Managed secrets:\t%d\n", report.ManagedSecrets)
+	fmt.Fprintf(w, "Pending secrets:\t%d\n", report.PendingSecrets)
+	fmt.Fprintf(w, "Failing secrets:\t%d\n", report.FailingSecrets)
+	fmt.Fprintf(w, "Last CA cert sync:\t%s\n", formatTime(report.LastCACertSync))
+	fmt.Fprintf(w, "Last secret update:\t%s\n", formatTime(report.LastSecretUpdate))
+	fmt.Fprintf(w, "Reported:\t%s (%s ago)\n", formatTime(&report.ReportTime),
+		secretNow().Sub(report.ReportTime.Time).Round(time.Second))
+	return w.Flush()
+}
+
+const (
+	checkPass = "PASS"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+)
+
+// secretCheck is the outcome of a check of a certificate secret.
+type secretCheck struct {
+	level   string
+	message string
+}
+
+// verifySecret checks the cert held in secret. caSecret holds the cert of the Istio CA, and is nil
+// if it could not be read.
+func verifySecret(secret, caSecret *v1.Secret, trustDomain string, gracePeriodRatio float64,
+	now time.Time) []secretCheck {
+	var checks []secretCheck
+	pass := func(format string, a ...interface{}) {
+		checks = append(checks, secretCheck{checkPass, fmt.Sprintf(format, a...)})
+	}
+	warn := func(format string, a ...interface{}) {
+		checks = append(checks, secretCheck{checkWarn, fmt.Sprintf(format, a...)})
+	}
+	fail := func(format string, a ...interface{}) {
+		checks = append(checks, secretCheck{checkFail, fmt.Sprintf(format, a...)})
+	}
+
+	chainPEM := secret.Data[controller.CertChainID]
+	certs, err := parseCertsPEM(chainPEM)
+	if err != nil || len(certs) == 0 {
+		fail("%s does not hold a valid cert (%v); delete the secret so that it is recreated", controller.CertChainID, err)
+		return checks
+	}
+	cert := certs[0]
+	if _, err := tls.X509KeyPair(chainPEM, secret.Data[controller.PrivateKeyID]); err != nil {
+		fail("%s does not match the cert (%v); delete the secret so that it is recreated", controller.PrivateKeyID, err)
+	} else {
+		pass("the private key matches the cert")
+	}
+
+	roots, err := parseCertsPEM(secret.Data[controller.RootCertID])
+	if err != nil || len(roots) == 0 {
+		fail("%s does not hold a valid root cert (%v)", controller.RootCertID, err)
+	} else {
+		rootPool := x509.NewCertPool()
+		for _, r := range roots {
+			rootPool.AddCert(r)
+		}
+		intermediates := x509.NewCertPool()
+		for _, c := range certs[1:] {
+			intermediates.AddCert(c)
+		}
+		if _, err := cert.Verify(x509.VerifyOptions{
+			Roots:         rootPool,
+			Intermediates: intermediates,
+			CurrentTime:   cert.NotBefore.Add(time.Second),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err != nil {
+			fail("the cert does not chain up to %s (%v); the root cert may have been rotated, rotate the cert",
+				controller.RootCertID, err)
+		} else {
+			pass("the cert chains up to %s", controller.RootCertID)
+		}
+
+		if caSecret == nil {
+			warn("the CA secret could not be read, the root cert is not compared with the one of the CA; " +
+				"use --ca-secret for a plugged-in CA")
+		} else {
+			caRootPEM := caSecret.Data[ca.RootCertID]
+			if len(caRootPEM) == 0 {
+				caRootPEM = caSecret.Data["ca-cert.pem"]
+			}
+			caRoots, err := parseCertsPEM(caRootPEM)
+			if err != nil || len(caRoots) == 0 {
+				warn("the CA secret %s does not hold a valid root cert (%v)", caSecret.Name, err)
+			} else if !containsCert(roots, caRoots[0]) {
+				fail("%s is not the root cert of the CA in secret %s (fingerprint %s); rotate the cert",
+					controller.RootCertID, caSecret.Name, certFingerprint(caRoots[0].Raw)[:16])
+			} else {
+				pass("%s holds the root cert of the CA", controller.RootCertID)
+			}
+		}
+	}
+
+	if secret.Type == controller.IstioSecretType && strings.HasPrefix(secret.Name, "istio.") {
+		id := fmt.Sprintf("spiffe://%s/ns/%s/sa/%s", trustDomain, secret.Namespace, strings.TrimPrefix(secret.Name, "istio."))
+		found := false
+		for _, u := range cert.URIs {
+			if u.String() == id {
+				found = true
+			}
+		}
+		if found {
+			pass("the cert holds the identity %s", id)
+		} else {
+			fail("the cert does not hold the identity %s (SANs %v); check the trust domain", id, cert.URIs)
+		}
+	} else if len(cert.DNSNames) > 0 {
+		pass("the cert holds the DNS names %s", strings.Join(cert.DNSNames, ","))
+	}
+
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	renewAt := cert.NotAfter.Add(-time.Duration(float64(lifetime) * gracePeriodRatio))
+	switch {
+	case !now.Before(cert.NotAfter):
+		fail("the cert expired at %s; check the logs of the controller managing the secret",
+			cert.NotAfter.UTC().Format(time.RFC3339))
+	case !now.Before(renewAt):
+		warn("the cert expires at %s and should have been renewed at %s; check the logs of the controller "+
+			"managing the secret", cert.NotAfter.UTC().Format(time.RFC3339), renewAt.UTC().Format(time.RFC3339))
+	default:
+		pass("the cert expires at %s, renewal in %s", cert.NotAfter.UTC().Format(time.RFC3339),
+			renewAt.Sub(now).Round(time.Minute))
+	}
+	return checks
+}
+
+// parseCertsPEM parses the PEM-encoded certs in certsPEM.
+func parseCertsPEM(certsPEM []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(certsPEM); block != nil; block, rest = pem.Decode(rest) {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// containsCert returns whether certs holds cert.
+func containsCert(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
+
+// listIstioSecrets returns the certificate secrets issued by Istio in namespace, sorted by namespace and name.
+func listIstioSecrets(client kubernetes.Interface, namespace string, gracePeriodRatio float64) ([]secretInfo, error) {
+	var infos []secretInfo
+	for _, t := range secretTypes {
+		secrets, err := client.CoreV1().Secrets(namespace).List(context.TODO(), metav1.ListOptions{
+			FieldSelector: "type=" + string(t),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list secrets of type %s: %v", t, err)
+		}
+		for i := range secrets.Items {
+			// The field selector is not honored by every client, e.g. the fake clientset.
+			if secrets.Items[i].Type != t {
+				continue
+			}
+			infos = append(infos, inspectSecret(&secrets.Items[i], gracePeriodRatio))
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Namespace != infos[j].Namespace {
+			return infos[i].Namespace < infos[j].Namespace
+		}
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
+
+// inspectSecret describes the leaf cert and root cert held in secret.
+func inspectSecret(secret *v1.Secret, gracePeriodRatio float64) secretInfo {
+	info := secretInfo{Namespace: secret.Namespace, Name: secret.Name, Type: string(secret.Type)}
+	cert, err := util.ParsePemEncodedCertificate(secret.Data[controller.CertChainID])
+	if err != nil {
+		info.Error = fmt.Sprintf("invalid %s: %v", controller.CertChainID, err)
+		return info
+	}
+	for _, u := range cert.URIs {
+		info.Identities = append(info.Identities, u.String())
+	}
+	info.Identities = append(info.Identities, cert.DNSNames...)
+	info.SerialNumber = cert.SerialNumber.Text(16)
+	info.NotBefore = cert.NotBefore
+	info.NotAfter = cert.NotAfter
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	info.RenewAt = cert.NotAfter.Add(-time.Duration(float64(lifetime) * gracePeriodRatio))
+
+	root, err := util.ParsePemEncodedCertificate(secret.Data[controller.RootCertID])
+	if err != nil {
+		info.Error = fmt.Sprintf("invalid %s: %v", controller.RootCertID, err)
+		return info
+	}
+	info.RootFingerprint = certFingerprint(root.Raw)
+	return info
+}
+
+// certFingerprint returns the hex-encoded SHA-256 digest of a DER-encoded cert.
+func certFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+func printSecretInfos(writer io.Writer, infos []secretInfo) error {
+	if len(infos) == 0 {
+		_, err := fmt.Fprintln(writer, "No certificate secrets found.")
+		return err
+	}
+	now := secretNow()
+	w := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tIDENTITY\tSERIAL\tNOT AFTER\tRENEWAL\tROOT")
+	for _, info := range infos {
+		if info.Error != "" {
+			fmt.Fprintf(w, "%s\t%s\t%s\t\t\t\t\n", info.Namespace, info.Name, info.Error)
+			continue
+		}
+		renewal := "due"
+		if d := info.RenewAt.Sub(now); d > 0 {
+			renewal = "in " + d.Round(time.Minute).String()
+		}
+		if !info.NotAfter.After(now) {
+			renewal = "expired"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", info.Namespace, info.Name, strings.Join(info.Identities, ","),
+			info.SerialNumber, info.NotAfter.UTC().Format(time.RFC3339), renewal, info.RootFingerprint[:16])
+	}
+	return w.Flush()
+}
@@ -0,0 +1,380 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"istio.io/istio/security/pkg/k8s/chiron"
+	"istio.io/istio/security/pkg/k8s/controller"
+	"istio.io/istio/security/pkg/k8s/controllerstatus"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+var secretNotBefore = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// genTestSecretCerts returns a root cert, and a leaf cert for host signed by it with its key, valid for 24h.
+func genTestSecretCerts(t *testing.T, host string) (rootPEM, leafPEM, leafKeyPEM []byte) {
+	t.Helper()
+	rootPEM, rootKeyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+		NotBefore: secretNotBefore, TTL: 48 * time.Hour, Org: "test", IsCA: true, IsSelfSigned: true, RSAKeySize: 2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the root cert: %v", err)
+	}
+	root, err := util.ParsePemEncodedCertificate(rootPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootKey, err := util.ParsePemEncodedKey(rootKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafPEM, leafKeyPEM, err = util.GenCertKeyFromOptions(util.CertOptions{
+		Host: host, NotBefore: secretNotBefore, TTL: 24 * time.Hour, SignerCert: root, SignerPriv: rootKey,
+		RSAKeySize: 2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the leaf cert: %v", err)
+	}
+	return rootPEM, leafPEM, leafKeyPEM
+}
+
+func TestSecretList(t *testing.T) {
+	rootPEM, leafPEM, _ := genTestSecretCerts(t, "spiffe://cluster.local/ns/default/sa/foo")
+	_, dnsPEM, _ := genTestSecretCerts(t, "webhook.istio-system.svc")
+	objects := []runtime.Object{
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "istio.foo", Namespace: "default"},
+			Type:       controller.IstioSecretType,
+			Data:       map[string][]byte{controller.CertChainID: leafPEM, controller.RootCertID: rootPEM},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "istio.broken", Namespace: "default"},
+			Type:       controller.IstioSecretType,
+			Data:       map[string][]byte{controller.CertChainID: []byte("invalid")},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "webhook-certs", Namespace: "istio-system"},
+			Type:       chiron.IstioDNSSecretType,
+			Data:       map[string][]byte{controller.CertChainID: dnsPEM, controller.RootCertID: rootPEM},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "default"},
+			Type:       v1.SecretTypeOpaque,
+		},
+	}
+	secretNow = func() time.Time { return secretNotBefore.Add(6 * time.Hour) }
+	defer func() { secretNow = time.Now }()
+	interfaceFactory = mockInterfaceFactoryGenerator(objects)
+
+	var out bytes.Buffer
+	rootCmd := GetRootCmd(strings.Split("experimental secret list -A", " "))
+	rootCmd.SetOutput(&out)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected a header and 3 secrets, got:\n%s", out.String())
+	}
+	for i, expected := range [][]string{
+		{"default", "istio.broken", "invalid cert-chain.pem"},
+		{"default", "istio.foo", "spiffe://cluster.local/ns/default/sa/foo", "2020-01-02T00:00:00Z", "in 6h0m0s"},
+		{"istio-system", "webhook-certs", "webhook.istio-system.svc", "in 6h0m0s"},
+	} {
+		for _, s := range expected {
+			if !strings.Contains(lines[i+1], s) {
+				t.Errorf("expected line %q to contain %q", lines[i+1], s)
+			}
+		}
+	}
+
+	out.Reset()
+	rootCmd = GetRootCmd(strings.Split("experimental secret list -n default -o json --grace-period-ratio 0.2", " "))
+	rootCmd.SetOutput(&out)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var infos []secretInfo
+	if err := json.Unmarshal(out.Bytes(), &infos); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out.String(), err)
+	}
+	if len(infos) != 2 || infos[1].Name != "istio.foo" {
+		t.Fatalf("unexpected secrets %v", infos)
+	}
+	root, _ := util.ParsePemEncodedCertificate(rootPEM)
+	if infos[1].RootFingerprint != certFingerprint(root.Raw) {
+		t.Errorf("unexpected root fingerprint %s", infos[1].RootFingerprint)
+	}
+	if want := secretNotBefore.Add(24*time.Hour - 24*time.Hour/5); !infos[1].RenewAt.Equal(want) {
+		t.Errorf("got renewal time %v, want %v", infos[1].RenewAt, want)
+	}
+}
+
+func TestSecretStatus(t *testing.T) {
+	reportTime := secretNotBefore.Add(time.Hour)
+	report := controllerstatus.Report{
+		Version:        "1.7.0",
+		Leader:         "istiod-1",
+		RootCertSHA256: "abcd",
+		ManagedSecrets: 3,
+		FailingSecrets: 1,
+		ReportTime:     metav1.Time{Time: reportTime},
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interfaceFactory = mockInterfaceFactoryGenerator([]runtime.Object{&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: controllerstatus.DefaultName, Namespace: "istio-system"},
+		Data:       map[string]string{controllerstatus.DataKey: string(data)},
+	}})
+	secretNow = func() time.Time { return reportTime.Add(time.Minute) }
+	defer func() { secretNow = time.Now }()
+
+	var out bytes.Buffer
+	rootCmd := GetRootCmd(strings.Split("experimental secret status -i istio-system", " "))
+	rootCmd.SetOutput(&out)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	output := strings.Join(strings.Fields(out.String()), " ")
+	for _, s := range []string{"Leader: istiod-1", "abcd", "Managed secrets: 3", "Failing secrets: 1", "never", "(1m0s ago)"} {
+		if !strings.Contains(output, s) {
+			t.Errorf("expected output to contain %q, got:\n%s", s, out.String())
+		}
+	}
+
+	rootCmd = GetRootCmd(strings.Split("experimental secret status -i default", " "))
+	rootCmd.SetOutput(&out)
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("expected an error without a status ConfigMap")
+	}
+}
+
+func TestSecretRotate(t *testing.T) {
+	dnsSecret := func(name string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "istio-system"},
+			Type:       chiron.IstioDNSSecretType,
+		}
+	}
+	objects := []runtime.Object{
+		dnsSecret("galley-certs"),
+		dnsSecret("injector-certs"),
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "opaque", Namespace: "istio-system"},
+			Type:       v1.SecretTypeOpaque,
+		},
+	}
+	secretNow = func() time.Time { return secretNotBefore }
+	defer func() { secretNow = time.Now }()
+
+	cases := []testcase{
+		{
+			description:       "no secret",
+			args:              strings.Split("experimental secret rotate -n istio-system", " "),
+			expectedException: true,
+			expectedOutput:    "Error: expecting either a secret name or --all\n",
+		},
+		{
+			description:       "not a DNS certificate secret",
+			args:              strings.Split("experimental secret rotate opaque -n istio-system", " "),
+			k8sConfigs:        objects,
+			expectedException: true,
+			expectedOutput:    "Error: secret \"opaque\" is of type Opaque, not istio.io/dns-key-and-cert\n",
+		},
+		{
+			description:    "one secret",
+			args:           strings.Split("experimental secret rotate galley-certs -n istio-system", " "),
+			k8sConfigs:     objects,
+			expectedOutput: "Requested the rotation of secret galley-certs.istio-system\n",
+		},
+		{
+			description: "all secrets",
+			args:        strings.Split("experimental secret rotate --all -n istio-system", " "),
+			k8sConfigs:  objects,
+			expectedOutput: "Requested the rotation of secret galley-certs.istio-system\n" +
+				"Requested the rotation of secret injector-certs.istio-system\n",
+		},
+	}
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, c.description), func(t *testing.T) {
+			verifyAddToMeshOutput(t, c)
+		})
+	}
+}
+
+func TestVerifySecret(t *testing.T) {
+	rootPEM, leafPEM, keyPEM := genTestSecretCerts(t, "spiffe://cluster.local/ns/default/sa/foo")
+	otherRootPEM, otherLeafPEM, otherKeyPEM := genTestSecretCerts(t, "spiffe://cluster.local/ns/default/sa/bar")
+	workloadSecret := func(leaf, key, root []byte) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "istio.foo", Namespace: "default"},
+			Type:       controller.IstioSecretType,
+			Data: map[string][]byte{
+				controller.CertChainID: leaf, controller.PrivateKeyID: key, controller.RootCertID: root,
+			},
+		}
+	}
+	caSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio-ca-secret", Namespace: "istio-system"},
+		Data:       map[string][]byte{"ca-cert.pem": rootPEM},
+	}
+	testCases := map[string]struct {
+		secret   *v1.Secret
+		caSecret *v1.Secret
+		now      time.Time
+		expected []string
+	}{
+		"valid": {
+			secret:   workloadSecret(leafPEM, keyPEM, rootPEM),
+			caSecret: caSecret,
+			now:      secretNotBefore.Add(6 * time.Hour),
+			expected: []string{checkPass, checkPass, checkPass, checkPass, checkPass},
+		},
+		"no CA secret and due for renewal": {
+			secret:   workloadSecret(leafPEM, keyPEM, rootPEM),
+			now:      secretNotBefore.Add(18 * time.Hour),
+			expected: []string{checkPass, checkPass, checkWarn, checkPass, checkWarn},
+		},
+		"mismatched key and expired": {
+			secret:   workloadSecret(leafPEM, otherKeyPEM, rootPEM),
+			caSecret: caSecret,
+			now:      secretNotBefore.Add(25 * time.Hour),
+			expected: []string{checkFail, checkPass, checkPass, checkPass, checkFail},
+		},
+		"root of another CA": {
+			secret:   workloadSecret(otherLeafPEM, otherKeyPEM, otherRootPEM),
+			caSecret: caSecret,
+			now:      secretNotBefore,
+			expected: []string{checkPass, checkPass, checkFail, checkFail, checkPass},
+		},
+		"wrong root": {
+			secret:   workloadSecret(leafPEM, keyPEM, otherRootPEM),
+			caSecret: caSecret,
+			now:      secretNotBefore,
+			expected: []string{checkPass, checkFail, checkFail, checkPass, checkPass},
+		},
+		"invalid cert": {
+			secret:   workloadSecret([]byte("invalid"), keyPEM, rootPEM),
+			expected: []string{checkFail},
+		},
+	}
+	for name, tc := range testCases {
+		checks := verifySecret(tc.secret, tc.caSecret, "cluster.local", 0.5, tc.now)
+		var levels []string
+		for _, c := range checks {
+			levels = append(levels, c.level)
+		}
+		if !reflect.DeepEqual(levels, tc.expected) {
+			t.Errorf("%s: got checks %v, want levels %v", name, checks, tc.expected)
+		}
+	}
+}
+
+func TestSecretDescribe(t *testing.T) {
+	rootPEM, leafPEM, leafKeyPEM := genTestSecretCerts(t, "spiffe://cluster.local/ns/default/sa/foo")
+	objects := []runtime.Object{
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "istio.foo", Namespace: "default"},
+			Type:       controller.IstioSecretType,
+			Data: map[string][]byte{
+				controller.CertChainID:  leafPEM,
+				controller.PrivateKeyID: leafKeyPEM,
+				controller.RootCertID:   rootPEM,
+			},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "opaque", Namespace: "default"},
+			Type:       v1.SecretTypeOpaque,
+			Data:       map[string][]byte{"password": []byte("secret")},
+		},
+	}
+	secretNow = func() time.Time { return secretNotBefore.Add(6 * time.Hour) }
+	defer func() { secretNow = time.Now }()
+	interfaceFactory = mockInterfaceFactoryGenerator(objects)
+
+	var out bytes.Buffer
+	rootCmd := GetRootCmd(strings.Split("experimental secret describe istio.foo -n default", " "))
+	rootCmd.SetOutput(&out)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	output := strings.Join(strings.Fields(out.String()), " ")
+	for _, s := range []string{
+		"cert-chain.pem[0]: Subject:", "Issued by: root-cert.pem[0]", "expires in 18h0m0s", "Key: RSA 2048",
+		"SPIFFE IDs: spiffe://cluster.local/ns/default/sa/foo", "Trust domains: cluster.local",
+		"root-cert.pem[0]: Subject: O=test", "Issued by: self-signed", "CA: true",
+	} {
+		if !strings.Contains(output, s) {
+			t.Errorf("expected output to contain %q, got:\n%s", s, out.String())
+		}
+	}
+	if strings.Contains(output, controller.PrivateKeyID) {
+		t.Errorf("expected the private key to be skipped, got:\n%s", out.String())
+	}
+
+	rootCmd = GetRootCmd(strings.Split("experimental secret describe opaque -n default", " "))
+	rootCmd.SetOutput(&out)
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("expected an error for a secret without certs")
+	}
+
+	// Only the leaf cert is in the file, its issuer is not found.
+	dir, err := ioutil.TempDir("", "secret-describe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cert.pem")
+	if err := ioutil.WriteFile(file, leafPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	rootCmd = GetRootCmd([]string{"experimental", "secret", "describe", "-f", file, "-o", "json"})
+	rootCmd.SetOutput(&out)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var descriptions []certDescription
+	if err := json.Unmarshal(out.Bytes(), &descriptions); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out.String(), err)
+	}
+	if len(descriptions) != 1 || descriptions[0].Source != file+"[0]" || descriptions[0].IssuedBy != "" ||
+		descriptions[0].IsCA || descriptions[0].Expired {
+		t.Errorf("unexpected descriptions %+v", descriptions)
+	}
+
+	rootCmd = GetRootCmd([]string{"experimental", "secret", "describe", "istio.foo", "-f", file})
+	rootCmd.SetOutput(&out)
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("expected an error with both a secret and a file")
+	}
+}
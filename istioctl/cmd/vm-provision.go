@@ -0,0 +1,215 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	pilotcontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+)
+
+const (
+	// vmProvisionToken provisions a VM with a single-use bootstrap token.
+	vmProvisionToken = "token"
+	// vmProvisionCert provisions a VM with an initial cert signed by the Istio CA.
+	vmProvisionCert = "cert"
+
+	// The files of the VM bootstrap bundle.
+	vmTokenFile     = "istio-token"
+	vmRootCertFile  = "root-cert.pem"
+	vmCertChainFile = "cert-chain.pem"
+	vmKeyFile       = "key.pem"
+	vmClusterEnv    = "cluster.env"
+
+	// The directories of the VM the bundle files are installed to.
+	vmTokenDir      = "/var/run/secrets/tokens"
+	vmRootCertDir   = "/var/run/secrets/istio"
+	vmCertsDir      = "/etc/certs"
+	vmClusterEnvDir = "/var/lib/istio/envoy"
+)
+
+// vmProvisionOptions are the options of 'istioctl experimental vm-provision'.
+type vmProvisionOptions struct {
+	serviceAccount   string
+	namespace        string
+	outDir           string
+	mode             string
+	ttl              time.Duration
+	trustDomain      string
+	discoveryAddress string
+}
+
+func vmProvisionCmd() *cobra.Command {
+	opts := vmProvisionOptions{}
+	cmd := &cobra.Command{
+		Use:   "vm-provision <service-account>",
+		Short: "Generate the bootstrap bundle of a VM joining the mesh",
+		Long: `'istioctl experimental vm-provision' generates the bootstrap bundle of a VM joining the mesh
+with the identity of a service account, instead of copying the contents of its istio.* secret to
+the VM.
+
+In token mode, the default, the bundle holds a single-use bootstrap token bound to the identity,
+which the VM exchanges for its first cert. istiod must run with CA_VM_BOOTSTRAP_TOKENS=true. The
+VM then renews its cert by authenticating with the issued cert, written to ` + vmCertsDir + `.
+
+In cert mode, the bundle holds an initial cert signed with the key of the self-signed Istio CA,
+with which the VM authenticates to renew it.
+
+The bundle also holds the root cert of the mesh, and the cluster.env configuration of the agent.
+The directories of the VM the files are installed to are printed with the bundle.`,
+		Example: `
+# Generate the bundle of a VM running as the foo service account of the vm namespace
+istioctl experimental vm-provision foo -n vm --out-dir foo-bundle
+
+# Generate a bundle with an initial cert valid for a day
+istioctl experimental vm-provision foo -n vm --mode cert --ttl 24h --out-dir foo-bundle`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			opts.serviceAccount = args[0]
+			opts.namespace = handlers.HandleNamespace(namespace, defaultNamespace)
+			if opts.discoveryAddress == "" {
+				opts.discoveryAddress = fmt.Sprintf("istiod.%s.svc:15012", istioNamespace)
+			}
+			return provisionVM(cmd.OutOrStdout(), client, opts)
+		},
+	}
+	cmd.PersistentFlags().StringVar(&opts.outDir, "out-dir", ".", "The directory to write the bundle to")
+	cmd.PersistentFlags().StringVar(&opts.mode, "mode", vmProvisionToken,
+		"How the VM authenticates its first CSR: one of token|cert")
+	cmd.PersistentFlags().DurationVar(&opts.ttl, "ttl", time.Hour,
+		"The lifetime of the bootstrap token, or of the initial cert")
+	cmd.PersistentFlags().StringVar(&opts.trustDomain, "trust-domain", constants.DefaultKubernetesDomain,
+		"The trust domain of the identity of the VM")
+	cmd.PersistentFlags().StringVar(&opts.discoveryAddress, "discovery-address", "",
+		"The address of istiod reached by the VM, istiod.<istio namespace>.svc:15012 by default")
+	return cmd
+}
+
+// provisionVM writes the bootstrap bundle of a VM to the output directory.
+func provisionVM(out io.Writer, client kubernetes.Interface, opts vmProvisionOptions) error {
+	if opts.mode != vmProvisionToken && opts.mode != vmProvisionCert {
+		return fmt.Errorf("unsupported mode %q, expecting one of %s|%s", opts.mode, vmProvisionToken, vmProvisionCert)
+	}
+	if _, err := client.CoreV1().ServiceAccounts(opts.namespace).Get(context.TODO(), opts.serviceAccount,
+		metav1.GetOptions{}); err != nil {
+		return fmt.Errorf("service account %s.%s does not exist", opts.serviceAccount, opts.namespace)
+	}
+	cm, err := client.CoreV1().ConfigMaps(istioNamespace).Get(context.TODO(), pilotcontroller.CACertNamespaceConfigMap,
+		metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to read the root cert of the mesh: %v", err)
+	}
+	rootCertPEM := []byte(cm.Data[constants.CACertNamespaceConfigMapDataName])
+	if _, err := util.ParsePemEncodedCertificate(rootCertPEM); err != nil {
+		return fmt.Errorf("invalid root cert in ConfigMap %s.%s: %v", pilotcontroller.CACertNamespaceConfigMap,
+			istioNamespace, err)
+	}
+	identity := fmt.Sprintf("spiffe://%s/ns/%s/sa/%s", opts.trustDomain, opts.namespace, opts.serviceAccount)
+
+	files := map[string][]byte{vmRootCertFile: rootCertPEM}
+	env := []string{
+		"ISTIO_NAMESPACE=" + istioNamespace,
+		"POD_NAMESPACE=" + opts.namespace,
+		"TRUST_DOMAIN=" + opts.trustDomain,
+		"CA_ADDR=" + opts.discoveryAddress,
+		"PILOT_CERT_PROVIDER=istiod",
+		"OUTPUT_CERTS=" + vmCertsDir,
+		fmt.Sprintf("PROXY_CONFIG={\"discoveryAddress\": %q}", opts.discoveryAddress),
+	}
+	if opts.mode == vmProvisionToken {
+		token, err := authenticate.CreateBootstrapToken(client.CoreV1(), istioNamespace, identity, opts.ttl)
+		if err != nil {
+			return err
+		}
+		files[vmTokenFile] = []byte(token)
+		env = append(env, "JWT_POLICY=third-party-jwt")
+	} else {
+		ca, err := getCertificate(client)
+		if err != nil {
+			return fmt.Errorf("failed to read the Istio CA, cert mode requires the self-signed Istio CA: %v", err)
+		}
+		certPEM, keyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+			Host:       identity,
+			NotBefore:  time.Now(),
+			TTL:        opts.ttl,
+			SignerCert: ca.Ca,
+			SignerPriv: ca.Key,
+			Org:        extractOrgName(ca.Ca),
+			IsClient:   true,
+			IsServer:   true,
+			RSAKeySize: 2048,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to sign the initial cert: %v", err)
+		}
+		if err := util.Verify(certPEM, keyPEM, nil, rootCertPEM); err != nil {
+			return fmt.Errorf("the initial cert does not chain up to the root cert of the mesh: %v", err)
+		}
+		files[vmCertChainFile] = certPEM
+		files[vmKeyFile] = keyPEM
+		env = append(env, "JWT_POLICY=none", "PROV_CERT="+vmCertsDir)
+	}
+	files[vmClusterEnv] = []byte(strings.Join(env, "\n") + "\n")
+
+	if err := os.MkdirAll(opts.outDir, 0755); err != nil {
+		return err
+	}
+	for name, data := range files {
+		perm := os.FileMode(0644)
+		if name == vmTokenFile || name == vmKeyFile {
+			perm = 0600
+		}
+		if err := ioutil.WriteFile(filepath.Join(opts.outDir, name), data, perm); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(out, "Generated the bootstrap bundle of %s in %s\n", identity, opts.outDir)
+	fmt.Fprintln(out, "Install the files on the VM:")
+	install := func(name, dir string) {
+		fmt.Fprintf(out, "  %s -> %s\n", filepath.Join(opts.outDir, name), filepath.Join(dir, name))
+	}
+	install(vmClusterEnv, vmClusterEnvDir)
+	if opts.mode == vmProvisionToken {
+		install(vmTokenFile, vmTokenDir)
+		install(vmRootCertFile, vmRootCertDir)
+		fmt.Fprintf(out, "The bootstrap token expires in %s and can be used once. Once the VM has joined the mesh, "+
+			"remove the token and set PROV_CERT=%s in %s, so that the VM renews its cert with the issued one.\n",
+			opts.ttl, vmCertsDir, vmClusterEnv)
+	} else {
+		install(vmCertChainFile, vmCertsDir)
+		install(vmKeyFile, vmCertsDir)
+		install(vmRootCertFile, vmCertsDir)
+	}
+	return nil
+}
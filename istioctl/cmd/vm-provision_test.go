@@ -0,0 +1,153 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	pilotcontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/security/pkg/k8s/secret"
+	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+)
+
+func TestProvisionVM(t *testing.T) {
+	caCertPEM, caKeyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+		NotBefore: time.Now(), TTL: time.Hour, Org: "cluster.local", IsCA: true, IsSelfSigned: true, RSAKeySize: 2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newClient := func() *fake.Clientset {
+		return fake.NewSimpleClientset(
+			&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "vm"}},
+			&v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: pilotcontroller.CACertNamespaceConfigMap, Namespace: "istio-system"},
+				Data:       map[string]string{constants.CACertNamespaceConfigMapDataName: string(caCertPEM)},
+			},
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secret.CASecret, Namespace: "istio-system"},
+				Data:       map[string][]byte{"ca-cert.pem": caCertPEM, "ca-key.pem": caKeyPEM},
+			},
+		)
+	}
+	defer func(ns string) { istioNamespace = ns }(istioNamespace)
+	istioNamespace = "istio-system"
+
+	dir, err := ioutil.TempDir("", "vm-provision")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opts := vmProvisionOptions{
+		serviceAccount:   "foo",
+		namespace:        "vm",
+		mode:             vmProvisionToken,
+		ttl:              time.Hour,
+		trustDomain:      "cluster.local",
+		discoveryAddress: "istiod.istio-system.svc:15012",
+	}
+	identity := "spiffe://cluster.local/ns/vm/sa/foo"
+	readFile := func(dir, name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("missing bundle file %s: %v", name, err)
+		}
+		return string(data)
+	}
+
+	t.Run("token", func(t *testing.T) {
+		client := newClient()
+		opts := opts
+		opts.outDir = filepath.Join(dir, "token")
+		var out bytes.Buffer
+		if err := provisionVM(&out, client, opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		token := readFile(opts.outDir, vmTokenFile)
+		tokenID := strings.Split(token, ".")[0]
+		scrt, err := client.CoreV1().Secrets("istio-system").Get(context.TODO(),
+			authenticate.BootstrapTokenSecretPrefix+tokenID, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("the bootstrap token %q is not stored: %v", token, err)
+		}
+		if got := string(scrt.Data[authenticate.BootstrapTokenIdentityKey]); got != identity {
+			t.Errorf("got token identity %s, want %s", got, identity)
+		}
+		if got := readFile(opts.outDir, vmRootCertFile); got != string(caCertPEM) {
+			t.Errorf("unexpected root cert %s", got)
+		}
+		env := readFile(opts.outDir, vmClusterEnv)
+		for _, s := range []string{"POD_NAMESPACE=vm\n", "CA_ADDR=istiod.istio-system.svc:15012\n", "JWT_POLICY=third-party-jwt\n"} {
+			if !strings.Contains(env, s) {
+				t.Errorf("expected cluster.env to contain %q, got:\n%s", s, env)
+			}
+		}
+		if !strings.Contains(out.String(), filepath.Join(vmTokenDir, vmTokenFile)) {
+			t.Errorf("expected the install path of the token, got:\n%s", out.String())
+		}
+	})
+
+	t.Run("cert", func(t *testing.T) {
+		opts := opts
+		opts.mode = vmProvisionCert
+		opts.outDir = filepath.Join(dir, "cert")
+		if err := provisionVM(ioutil.Discard, newClient(), opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		certPEM := readFile(opts.outDir, vmCertChainFile)
+		if err := util.Verify([]byte(certPEM), []byte(readFile(opts.outDir, vmKeyFile)), nil, caCertPEM); err != nil {
+			t.Fatalf("invalid initial cert: %v", err)
+		}
+		cert, err := util.ParsePemEncodedCertificate([]byte(certPEM))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cert.URIs) != 1 || cert.URIs[0].String() != identity {
+			t.Errorf("got identities %v, want %s", cert.URIs, identity)
+		}
+		if env := readFile(opts.outDir, vmClusterEnv); !strings.Contains(env, "PROV_CERT="+vmCertsDir+"\n") {
+			t.Errorf("expected cluster.env to set PROV_CERT, got:\n%s", env)
+		}
+		if _, err := os.Stat(filepath.Join(opts.outDir, vmTokenFile)); err == nil {
+			t.Error("expected no bootstrap token in cert mode")
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, o := range []vmProvisionOptions{
+			{serviceAccount: "foo", namespace: "vm", mode: "password", ttl: time.Hour},
+			{serviceAccount: "bar", namespace: "vm", mode: vmProvisionToken, ttl: time.Hour},
+			{serviceAccount: "foo", namespace: "vm", mode: vmProvisionToken, ttl: -time.Hour},
+		} {
+			o.outDir = filepath.Join(dir, "errors")
+			if err := provisionVM(ioutil.Discard, newClient(), o); err == nil {
+				t.Errorf("expected an error for %+v", o)
+			}
+		}
+	})
+}
@@ -6988,10 +6988,14 @@ type BaseConfig struct {
 	// For Helm2 use, adds the CRDs to templates.
 	EnableCRDTemplates *protobuf.BoolValue `protobuf:"bytes,1,opt,name=enableCRDTemplates,proto3" json:"enableCRDTemplates,omitempty"`
 	// URL to use for validating webhook.
-	ValidationURL        string   `protobuf:"bytes,2,opt,name=validationURL,proto3" json:"validationURL,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	ValidationURL string `protobuf:"bytes,2,opt,name=validationURL,proto3" json:"validationURL,omitempty"`
+	// Rejects the modifications and deletions of the istio.io/key-and-cert secrets by anyone but istiod.
+	EnableSecretProtection *protobuf.BoolValue `protobuf:"bytes,3,opt,name=enableSecretProtection,proto3" json:"enableSecretProtection,omitempty"`
+	// Mounts the CA token and the root cert into the pods annotated with security.istio.io/mountWorkloadCert.
+	EnableCertMountWebhook *protobuf.BoolValue `protobuf:"bytes,4,opt,name=enableCertMountWebhook,proto3" json:"enableCertMountWebhook,omitempty"`
+	XXX_NoUnkeyedLiteral   struct{}            `json:"-"`
+	XXX_unrecognized       []byte              `json:"-"`
+	XXX_sizecache          int32               `json:"-"`
 }
 
 func (m *BaseConfig) Reset()         { *m = BaseConfig{} }
@@ -7033,6 +7037,20 @@ func (m *BaseConfig) GetValidationURL() string {
 	return ""
 }
 
+func (m *BaseConfig) GetEnableSecretProtection() *protobuf.BoolValue {
+	if m != nil {
+		return m.EnableSecretProtection
+	}
+	return nil
+}
+
+func (m *BaseConfig) GetEnableCertMountWebhook() *protobuf.BoolValue {
+	if m != nil {
+		return m.EnableCertMountWebhook
+	}
+	return nil
+}
+
 type IstiodRemoteConfig struct {
 	// URL to use for sidecar injector webhook.
 	InjectionURL         string   `protobuf:"bytes,1,opt,name=injectionURL,proto3" json:"injectionURL,omitempty"`
@@ -50,6 +50,8 @@ import (
 	"istio.io/istio/pkg/jwt"
 	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/pkg/util/gogoprotomarshal"
+	"istio.io/istio/security/pkg/debugserver"
+	"istio.io/istio/security/pkg/featuregate"
 	citadel "istio.io/istio/security/pkg/nodeagent/caclient/providers/citadel"
 	stsserver "istio.io/istio/security/pkg/stsservice/server"
 	"istio.io/istio/security/pkg/stsservice/tokenmanager"
@@ -277,6 +279,12 @@ var (
 				go statusServer.Run(ctx)
 			}
 
+			// Serve pprof and the runtime stats of the agent, if enabled.
+			if err := debugserver.Start(ctx.Done()); err != nil {
+				cancel()
+				return fmt.Errorf("failed to start the security debug server: %v", err)
+			}
+
 			// If security token service (STS) port is not zero, start STS server and
 			// listen on STS port for STS requests. For STS, see
 			// https://tools.ietf.org/html/draft-ietf-oauth-token-exchange-16.
@@ -442,6 +450,8 @@ func init() {
 		"Disable internal telemetry")
 	proxyCmd.PersistentFlags().StringVar(&outlierLogPath, "outlierLogPath", "",
 		"The log path for outlier detection")
+	featuregate.AddFlag(proxyCmd.PersistentFlags())
+	debugserver.AddFlag(proxyCmd.PersistentFlags())
 
 	// Attach the Istio logging options to the command.
 	loggingOptions.AttachCobraFlags(rootCmd)
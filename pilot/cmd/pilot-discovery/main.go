@@ -146,6 +146,10 @@ func init() {
 		"Specify the applications namespace list the controller manages, separated by comma; if not set, controller watches all namespaces")
 	discoveryCmd.PersistentFlags().DurationVar(&serverArgs.RegistryOptions.KubeOptions.ResyncPeriod, "resync", 60*time.Second,
 		"Controller resync interval")
+	discoveryCmd.PersistentFlags().IntVar(&serverArgs.RegistryOptions.KubeOptions.NamespaceControllerWorkers, "concurrent-workers", 1,
+		"Number of workers reconciling the CA root cert ConfigMaps of the namespaces concurrently")
+	discoveryCmd.PersistentFlags().StringVar(&serverArgs.RegistryOptions.KubeOptions.NamespaceSelector, "namespaceSelector", "",
+		"Label selector of the namespaces in which the CA root cert ConfigMap is managed, in addition to the namespaces of --appNamespace")
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.RegistryOptions.KubeOptions.DomainSuffix, "domain", constants.DefaultKubernetesDomain,
 		"DNS domain suffix")
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.RegistryOptions.KubeOptions.ClusterID, "clusterID", features.ClusterName,
@@ -16,20 +16,29 @@ package bootstrap
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
 	"istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
 
+	"istio.io/pkg/env"
 	"istio.io/pkg/log"
 
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/security/pkg/k8s/chiron"
+	"istio.io/istio/security/pkg/k8s/preflight"
+	certutil "istio.io/istio/security/pkg/util"
 )
 
 const (
@@ -54,6 +63,59 @@ var (
 
 	KubernetesCAProvider = "kubernetes"
 	IstiodCAProvider     = "istiod"
+
+	certControllerPKCS7Export = env.RegisterBoolVar("CERT_CONTROLLER_PKCS7_EXPORT", false,
+		"If enabled, the DNS certificate secrets from MeshConfig certificates also hold the cert chain and "+
+			"CA cert as a PKCS#7 bundle under cert-chain.p7b.")
+
+	certControllerACMEDirectory = env.RegisterStringVar("CERT_CONTROLLER_ACME_DIRECTORY", "",
+		"ACME directory URL, e.g. https://acme-v02.api.letsencrypt.org/directory. If set, the DNS certificates "+
+			"from MeshConfig certificates are publicly trusted certs issued by the ACME CA with DNS-01 challenges.")
+
+	certControllerACMEEmail = env.RegisterStringVar("CERT_CONTROLLER_ACME_EMAIL", "",
+		"Contact email of the ACME account.")
+
+	certControllerACMEAccountSecret = env.RegisterStringVar("CERT_CONTROLLER_ACME_ACCOUNT_SECRET", "istio-acme-account",
+		"Name of the secret in the istiod namespace holding the ACME account key, created if missing.")
+
+	certControllerACMEPropagationDelay = env.RegisterDurationVar("CERT_CONTROLLER_ACME_PROPAGATION_DELAY", 30*time.Second,
+		"Time to wait for the DNS-01 TXT records to propagate before the ACME CA validates them.")
+
+	certControllerACMERFC2136Nameserver = env.RegisterStringVar("CERT_CONTROLLER_ACME_RFC2136_NAMESERVER", "",
+		"Address of the DNS server receiving the RFC 2136 updates of the DNS-01 TXT records, e.g. 10.0.0.1:53.")
+
+	certControllerACMERFC2136Zone = env.RegisterStringVar("CERT_CONTROLLER_ACME_RFC2136_ZONE", "",
+		"DNS zone updated with the DNS-01 TXT records.")
+
+	certControllerACMERFC2136TSIGKey = env.RegisterStringVar("CERT_CONTROLLER_ACME_RFC2136_TSIG_KEY", "",
+		"Name of the TSIG key signing the RFC 2136 updates.")
+
+	certControllerACMERFC2136TSIGSecretFile = env.RegisterStringVar("CERT_CONTROLLER_ACME_RFC2136_TSIG_SECRET_FILE", "",
+		"File holding the base64 secret of the TSIG key.")
+
+	certControllerACMERFC2136TSIGAlgorithm = env.RegisterStringVar("CERT_CONTROLLER_ACME_RFC2136_TSIG_ALGORITHM", "hmac-sha256",
+		"Algorithm of the TSIG key.")
+
+	certControllerWebhookServices = env.RegisterStringVar("CERT_CONTROLLER_WEBHOOK_SERVICES", "",
+		"Comma separated webhook or aggregated API services, as namespace/service[:secret], whose serving certs "+
+			"are provisioned and rotated in the given secret, <service>-dns-cert by default. The certs are "+
+			"signed by the PILOT_CERT_PROVIDER.")
+
+	certControllerWebhookCertTTL = env.RegisterDurationVar("CERT_CONTROLLER_WEBHOOK_CERT_TTL", 30*24*time.Hour,
+		"The lifetime of the webhook serving certs signed by istiod.")
+
+	certControllerRenewalStrategy = env.RegisterStringVar("CERT_CONTROLLER_RENEWAL_STRATEGY", "",
+		"When the certificate controllers renew their certs: remaining:<percentage> of the lifetime, "+
+			"before:<duration> the expiration or after:<duration> the issuance. "+
+			"By default, certs are renewed when half of their lifetime remains.")
+
+	certControllerFailureAlertThreshold = env.RegisterIntVar("CERT_CONTROLLER_FAILURE_ALERT_THRESHOLD", 3,
+		"Number of consecutive failures to create or refresh a DNS certificate secret after which an alert "+
+			"is sent to CA_NOTIFICATION_WEBHOOK_URL. No alert is sent if not positive.")
+
+	certControllerRespectDeletions = env.RegisterBoolVar("CERT_CONTROLLER_RESPECT_DELETIONS", false,
+		"If enabled, the deleted DNS certificate secrets are not re-created until istiod restarts. Otherwise "+
+			"only the secrets annotated with "+chiron.KeepDeletedAnnotation+" are kept deleted.")
 )
 
 // CertController can create certificates signed by K8S server.
@@ -61,6 +123,12 @@ func (s *Server) initCertController(args *PilotArgs) error {
 	var err error
 	var secretNames, dnsNames, namespaces []string
 
+	if s.kubeClient != nil {
+		if err = s.initWebhookCertController(); err != nil {
+			return err
+		}
+	}
+
 	meshConfig := s.environment.Mesh()
 	if meshConfig.GetCertificates() == nil || len(meshConfig.GetCertificates()) == 0 {
 		// TODO: if the provider is set to Citadel, use that instead of k8s so the API is still preserved.
@@ -90,6 +158,17 @@ func (s *Server) initCertController(args *PilotArgs) error {
 	if err != nil {
 		return fmt.Errorf("failed to create certificate controller: %v", err)
 	}
+	if err = setRenewalStrategy(s.certController); err != nil {
+		return err
+	}
+	s.certController.FailureAlertThreshold = certControllerFailureAlertThreshold.Get()
+	s.certController.RespectDeletions = certControllerRespectDeletions.Get()
+	s.certController.IncludePKCS7 = certControllerPKCS7Export.Get()
+	if certControllerACMEDirectory.Get() != "" {
+		if s.certController.Issuer, err = newACMEIssuer(k8sClient.CoreV1(), args.Namespace); err != nil {
+			return fmt.Errorf("failed to create ACME issuer: %v", err)
+		}
+	}
 	s.addStartFunc(func(stop <-chan struct{}) error {
 		go func() {
 			// Run Chiron to manage the lifecycles of certificates
@@ -98,10 +177,216 @@ func (s *Server) initCertController(args *PilotArgs) error {
 
 		return nil
 	})
+	s.addDrainFunc(func(timeout time.Duration) {
+		if !s.certController.Drain(timeout) {
+			log.Warnf("the certificate controller did not complete its secret updates within %v", timeout)
+		}
+	})
+
+	return nil
+}
+
+// initWebhookCertController provisions and rotates the serving certs of the webhook services.
+func (s *Server) initWebhookCertController() error {
+	services, err := chiron.ParseWebhookServices(certControllerWebhookServices.Get())
+	if err != nil {
+		return err
+	}
+	s.webhookCerts = &webhookCertRunner{server: s}
+	if err = s.webhookCerts.setServices(services); err != nil {
+		return err
+	}
+	s.httpMux.HandleFunc("/debug/certcontrollerz", func(w http.ResponseWriter, _ *http.Request) {
+		wc := s.webhookCerts.controller()
+		if wc == nil {
+			http.Error(w, "no webhook services are configured", http.StatusNotFound)
+			return
+		}
+		out, err := json.MarshalIndent(wc.Status(), "", "    ")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprintf(w, "unable to marshal the cert controller status: %v", err)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		_, _ = w.Write(out)
+	})
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		s.webhookCerts.run(stop)
+		return nil
+	})
+	s.addDrainFunc(s.webhookCerts.drain)
+	return nil
+}
+
+// newWebhookCertController creates the controller of the serving certs of services.
+func (s *Server) newWebhookCertController(services []chiron.WebhookService) (*chiron.WebhookController, error) {
+	var secretNames, dnsNames, namespaces []string
+	for _, svc := range services {
+		secretNames = append(secretNames, svc.SecretName)
+		dnsNames = append(dnsNames, svc.DNSNames())
+		namespaces = append(namespaces, svc.Namespace)
+	}
+
+	k8sClient := s.kubeClient
+	if caPreflightChecks.Get() {
+		perms := preflight.Permissions("secrets", []string{"get", "list", "watch", "create", "update"}, namespaces...)
+		if err := preflight.CheckPermissions(k8sClient.AuthorizationV1().SelfSubjectAccessReviews(), perms); err != nil {
+			return nil, fmt.Errorf("webhook certificate controller preflight checks failed: %v", err)
+		}
+	}
+	wc, err := chiron.NewWebhookController(defaultCertGracePeriodRatio, defaultMinCertGracePeriod,
+		k8sClient.CoreV1(), k8sClient.AdmissionregistrationV1beta1(), k8sClient.CertificatesV1beta1(),
+		defaultCACertPath, secretNames, dnsNames, namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook certificate controller: %v", err)
+	}
+	if err = setRenewalStrategy(wc); err != nil {
+		return nil, err
+	}
+	wc.FailureAlertThreshold = certControllerFailureAlertThreshold.Get()
+	wc.RespectDeletions = certControllerRespectDeletions.Get()
+	if features.PilotCertProvider.Get() == IstiodCAProvider {
+		if s.ca == nil {
+			return nil, fmt.Errorf("webhook certs cannot be signed by istiod, the CA is disabled")
+		}
+		wc.Issuer = &chiron.CAIssuer{CA: s.ca, TTL: certControllerWebhookCertTTL.Get()}
+	}
+	return wc, nil
+}
+
+// webhookCertRunner runs the controller of the serving certs of the webhook services, and restarts it
+// when the webhook services are reconfigured.
+type webhookCertRunner struct {
+	server *Server
 
+	mutex    sync.Mutex
+	services []chiron.WebhookService
+	wc       *chiron.WebhookController
+	// strategy overrides the renewal strategy of the controller, if set.
+	strategy certutil.RenewalStrategy
+	// parent is closed when istiod stops. It is nil until istiod starts.
+	parent <-chan struct{}
+	// restart is closed to stop the current controller.
+	restart chan struct{}
+}
+
+// controller returns the current controller, or nil if no webhook services are configured.
+func (r *webhookCertRunner) controller() *chiron.WebhookController {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.wc
+}
+
+// setServices replaces the controller with one managing the certs of services, unless they are unchanged.
+func (r *webhookCertRunner) setServices(services []chiron.WebhookService) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if reflect.DeepEqual(services, r.services) {
+		return nil
+	}
+	var wc *chiron.WebhookController
+	if len(services) > 0 {
+		var err error
+		if wc, err = r.server.newWebhookCertController(services); err != nil {
+			return err
+		}
+		if r.strategy != nil {
+			wc.SetRenewalStrategy(r.strategy)
+		}
+		log.Infof("Provisioning serving certs of webhook services %v", services)
+	}
+	if r.restart != nil {
+		close(r.restart)
+		r.restart = nil
+	}
+	r.services, r.wc = services, wc
+	if r.parent != nil {
+		r.startLocked()
+	}
 	return nil
 }
 
+// setRenewalStrategy applies strategy to the current and future controllers.
+func (r *webhookCertRunner) setRenewalStrategy(strategy certutil.RenewalStrategy) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.strategy = strategy
+	if r.wc != nil {
+		r.wc.SetRenewalStrategy(strategy)
+	}
+}
+
+// run starts the controller, until stop is closed.
+func (r *webhookCertRunner) run(stop <-chan struct{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.parent = stop
+	r.startLocked()
+}
+
+// drain waits up to timeout for the secret updates in progress of the current controller.
+func (r *webhookCertRunner) drain(timeout time.Duration) {
+	wc := r.controller()
+	if wc != nil && !wc.Drain(timeout) {
+		log.Warnf("the webhook certificate controller did not complete its secret updates within %v", timeout)
+	}
+}
+
+func (r *webhookCertRunner) startLocked() {
+	if r.wc == nil {
+		return
+	}
+	select {
+	case <-r.parent:
+		// The controller is not started once istiod stops, e.g. when the webhook services are
+		// reconfigured during the shutdown.
+		return
+	default:
+	}
+	wc, parent, restart := r.wc, r.parent, make(chan struct{})
+	r.restart = restart
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-parent:
+		case <-restart:
+		}
+		close(stop)
+	}()
+	go wc.Run(stop)
+}
+
+// newACMEIssuer creates an issuer of publicly trusted DNS certs, validated with DNS-01 challenges
+// published with RFC 2136 updates.
+func newACMEIssuer(core corev1.CoreV1Interface, namespace string) (*chiron.ACMEIssuer, error) {
+	if certControllerACMERFC2136Nameserver.Get() == "" || certControllerACMERFC2136Zone.Get() == "" {
+		return nil, fmt.Errorf("the RFC 2136 nameserver and zone of the DNS-01 challenges must be set")
+	}
+	dnsProvider := &chiron.RFC2136Provider{
+		Nameserver:    certControllerACMERFC2136Nameserver.Get(),
+		Zone:          certControllerACMERFC2136Zone.Get(),
+		TSIGKey:       certControllerACMERFC2136TSIGKey.Get(),
+		TSIGAlgorithm: certControllerACMERFC2136TSIGAlgorithm.Get(),
+		Timeout:       10 * time.Second,
+	}
+	if f := certControllerACMERFC2136TSIGSecretFile.Get(); f != "" {
+		secret, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TSIG secret: %v", err)
+		}
+		dnsProvider.TSIGSecret = strings.TrimSpace(string(secret))
+	}
+	accountKey, err := chiron.LoadOrCreateACMEAccountKey(core, namespace, certControllerACMEAccountSecret.Get())
+	if err != nil {
+		return nil, err
+	}
+	issuer := chiron.NewACMEIssuer(certControllerACMEDirectory.Get(), certControllerACMEEmail.Get(), accountKey, dnsProvider)
+	issuer.PropagationDelay = certControllerACMEPropagationDelay.Get()
+	log.Infof("Use the ACME CA at %s to issue DNS certificates", certControllerACMEDirectory.Get())
+	return issuer, nil
+}
+
 // initDNSCerts will create the certificates to be used by Istiod GRPC server and webhooks.
 // If the certificate creation fails - for example no support in K8S - returns an error.
 // Will use the mesh.yaml DiscoveryAddress to find the default expected address of the control plane,
@@ -216,3 +501,29 @@ func (s *Server) initDNSCerts(hostname, customHost, namespace string) error {
 	log.Infoa("DNS certificates created in ", dnsCertDir)
 	return nil
 }
+
+// defaultRenewalStrategy returns CERT_CONTROLLER_RENEWAL_STRATEGY if set, or else the renewal when
+// defaultCertGracePeriodRatio of the lifetime remains.
+func defaultRenewalStrategy() (certutil.RenewalStrategy, error) {
+	if certControllerRenewalStrategy.Get() == "" {
+		return certutil.PercentageRemaining(defaultCertGracePeriodRatio * 100), nil
+	}
+	strategy, err := certutil.ParseRenewalStrategy(certControllerRenewalStrategy.Get())
+	if err != nil {
+		return nil, fmt.Errorf("invalid CERT_CONTROLLER_RENEWAL_STRATEGY: %v", err)
+	}
+	return strategy, nil
+}
+
+// setRenewalStrategy applies CERT_CONTROLLER_RENEWAL_STRATEGY to wc, if set.
+func setRenewalStrategy(wc *chiron.WebhookController) error {
+	if certControllerRenewalStrategy.Get() == "" {
+		return nil
+	}
+	strategy, err := certutil.ParseRenewalStrategy(certControllerRenewalStrategy.Get())
+	if err != nil {
+		return fmt.Errorf("invalid CERT_CONTROLLER_RENEWAL_STRATEGY: %v", err)
+	}
+	wc.SetRenewalStrategy(strategy)
+	return nil
+}
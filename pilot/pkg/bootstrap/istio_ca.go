@@ -17,6 +17,7 @@ package bootstrap
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -31,16 +32,46 @@ import (
 	"istio.io/istio/pkg/jwt"
 
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/leaderelection"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	"istio.io/pkg/env"
 	"istio.io/pkg/log"
+	"istio.io/pkg/version"
 
 	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/webhooks"
+	"istio.io/istio/security/pkg/adapter/awspca"
+	"istio.io/istio/security/pkg/adapter/caplugin"
+	"istio.io/istio/security/pkg/adapter/cas"
+	"istio.io/istio/security/pkg/adapter/vault"
+	"istio.io/istio/security/pkg/audit"
 	"istio.io/istio/security/pkg/cmd"
+	"istio.io/istio/security/pkg/k8s/cabackup"
+	"istio.io/istio/security/pkg/k8s/caconfig"
+	"istio.io/istio/security/pkg/k8s/castate"
+	"istio.io/istio/security/pkg/k8s/certmount"
+	"istio.io/istio/security/pkg/k8s/chiron"
+	secretcontroller "istio.io/istio/security/pkg/k8s/controller"
+	"istio.io/istio/security/pkg/k8s/controllerstatus"
+	"istio.io/istio/security/pkg/k8s/csrsigner"
+	"istio.io/istio/security/pkg/k8s/preflight"
+	"istio.io/istio/security/pkg/k8s/secretguard"
+	"istio.io/istio/security/pkg/k8s/trustanchor"
+	"istio.io/istio/security/pkg/k8s/trustbundle"
+	"istio.io/istio/security/pkg/notify"
 	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/ct"
+	"istio.io/istio/security/pkg/pki/kms"
+	"istio.io/istio/security/pkg/pki/kmssigner"
+	pkiutil "istio.io/istio/security/pkg/pki/util"
 	caserver "istio.io/istio/security/pkg/server/ca"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
 )
@@ -81,6 +112,11 @@ var (
 		cmd.DefaultMaxWorkloadCertTTL,
 		"The max TTL of issued workload certificates.")
 
+	minWorkloadCertTTL = env.RegisterDurationVar("MIN_WORKLOAD_CERT_TTL",
+		0,
+		"The min TTL of issued workload certificates. Requests for a shorter TTL are rejected. "+
+			"Zero disables the check.")
+
 	SelfSignedCACertTTL = env.RegisterDurationVar("CITADEL_SELF_SIGNED_CA_CERT_TTL",
 		cmd.DefaultSelfSignedCACertTTL,
 		"The TTL of self-signed CA root certificate.")
@@ -96,12 +132,415 @@ var (
 		cmd.DefaultRootCertGracePeriodPercentile,
 		"Grace period percentile for self-signed root cert.")
 
+	selfSignedRootCertRenewBefore = env.RegisterDurationVar("CITADEL_SELF_SIGNED_ROOT_CERT_RENEW_BEFORE",
+		0,
+		"The remaining lifetime of the self-signed root cert below which the root cert is rotated, "+
+			"in addition to the grace period percentile. Zero relies on the grace period percentile only.")
+
+	rootRotationConfirmFraction = env.RegisterFloatVar("CITADEL_ROOT_ROTATION_CONFIRM_FRACTION",
+		0,
+		"The fraction, in (0, 1], of the root cert ConfigMaps and workload secrets that must hold the new root "+
+			"of a two-phase root rotation, requested with the istio-ca-root-rotation secret, before the self-signed "+
+			"CA switches to signing with it. Zero disables two-phase root rotation.")
+
+	rootRotationCheckInterval = env.RegisterDurationVar("CITADEL_ROOT_ROTATION_CHECK_INTERVAL",
+		time.Minute,
+		"The interval of the checks of the requests and the propagation of two-phase root rotations.")
+
+	rootRotationMinDistributionTime = env.RegisterDurationVar("CITADEL_ROOT_ROTATION_MIN_DISTRIBUTION_TIME",
+		24*time.Hour,
+		"The minimum time the new root of a two-phase root rotation is distributed before the self-signed CA "+
+			"switches to signing with it, so that the workloads refresh their roots.")
+
 	enableJitterForRootCertRotator = env.RegisterBoolVar("CITADEL_ENABLE_JITTER_FOR_ROOT_CERT_ROTATOR",
 		true,
 		"If true, set up a jitter to start root cert rotator. "+
 			"Jitter selects a backoff time in seconds to start root cert rotator, "+
 			"and the back off time is below root cert check interval.")
 
+	pluggedCASecretName = env.RegisterStringVar("PLUGGED_CA_SECRET_NAME", "",
+		"Name of a secret holding a plugged-in CA key and cert. If set, the CA credentials are "+
+			"loaded from this secret instead of the files in ROOT_CA_DIR.")
+
+	pluggedCASecretNamespace = env.RegisterStringVar("PLUGGED_CA_SECRET_NAMESPACE", "",
+		"Namespace of the plugged-in CA secret. Defaults to the istiod namespace.")
+
+	pluggedCASecretCertKey = env.RegisterStringVar("PLUGGED_CA_SECRET_CERT_KEY", "ca-cert.pem",
+		"Data key of the CA signing cert in the plugged-in CA secret, e.g. tls.crt.")
+
+	pluggedCASecretPrivateKeyKey = env.RegisterStringVar("PLUGGED_CA_SECRET_PRIVATE_KEY_KEY", "ca-key.pem",
+		"Data key of the CA private key in the plugged-in CA secret, e.g. tls.key.")
+
+	pluggedCASecretCertChainKey = env.RegisterStringVar("PLUGGED_CA_SECRET_CERT_CHAIN_KEY", "cert-chain.pem",
+		"Data key of the CA cert chain in the plugged-in CA secret.")
+
+	pluggedCASecretRootCertKey = env.RegisterStringVar("PLUGGED_CA_SECRET_ROOT_CERT_KEY", "root-cert.pem",
+		"Data key of the root cert in the plugged-in CA secret, e.g. ca.crt.")
+
+	vaultCAStoreAddr = env.RegisterStringVar("VAULT_CA_STORE_ADDR", "",
+		"Address of a Vault server used to persist the self-signed CA key and cert instead of "+
+			"the istio-ca-secret Kubernetes secret. Root cert rotation is disabled in this mode.")
+
+	vaultCAStoreTokenFile = env.RegisterStringVar("VAULT_CA_STORE_TOKEN_FILE", "",
+		"Path of the file holding the Vault token used to access the CA store.")
+
+	vaultCAStoreMount = env.RegisterStringVar("VAULT_CA_STORE_MOUNT", "secret",
+		"Mount point of the Vault KV secrets engine used as the CA store.")
+
+	vaultCAStorePath = env.RegisterStringVar("VAULT_CA_STORE_PATH", "istio/ca",
+		"Path of the CA secret in the Vault KV secrets engine.")
+
+	vaultCAStoreKVVersion = env.RegisterIntVar("VAULT_CA_STORE_KV_VERSION", 2,
+		"Version of the Vault KV secrets engine used as the CA store, either 1 or 2.")
+
+	intermediateCAUpstreamDir = env.RegisterStringVar("INTERMEDIATE_CA_UPSTREAM_DIR", "",
+		"Directory holding the upstream CA ca-cert.pem, ca-key.pem, cert-chain.pem and root-cert.pem. "+
+			"If set, istiod runs as an intermediate CA with a cert signed by the upstream CA.")
+
+	intermediateCACertTTL = env.RegisterDurationVar("INTERMEDIATE_CA_CERT_TTL", 30*24*time.Hour,
+		"The TTL of the intermediate CA cert signed by the upstream CA.")
+
+	intermediateCACertCheckInterval = env.RegisterDurationVar("INTERMEDIATE_CA_CERT_CHECK_INTERVAL", time.Hour,
+		"The interval to check whether the intermediate CA cert needs to be renewed. "+
+			"Set to 0 to disable renewal.")
+
+	caKeyKMSProvider = env.RegisterStringVar("CA_KEY_KMS_PROVIDER", "",
+		"KMS provider used to envelope encrypt the self-signed CA private key in istio-ca-secret, "+
+			"one of local, vault-transit or aws-kms. The key is stored in plaintext if unset.")
+
+	caKeyKMSKeyID = env.RegisterStringVar("CA_KEY_KMS_KEY_ID", "",
+		"Key encryption key of the KMS provider: the key file for local, the transit key name for "+
+			"vault-transit, or the key ID or ARN for aws-kms.")
+
+	caKeyKMSVaultAddr = env.RegisterStringVar("CA_KEY_KMS_VAULT_ADDR", "",
+		"Address of the Vault server for the vault-transit KMS provider.")
+
+	caKeyKMSVaultTokenFile = env.RegisterStringVar("CA_KEY_KMS_VAULT_TOKEN_FILE", "",
+		"Path of the file holding the Vault token for the vault-transit KMS provider.")
+
+	caKeyKMSVaultMount = env.RegisterStringVar("CA_KEY_KMS_VAULT_MOUNT", "transit",
+		"Mount point of the Vault transit secrets engine for the vault-transit KMS provider.")
+
+	caKeyKMSAWSRegion = env.RegisterStringVar("CA_KEY_KMS_AWS_REGION", "",
+		"AWS region of the key for the aws-kms KMS provider.")
+
+	kmsSignerBackend = env.RegisterStringVar("CA_KMS_SIGNER", "",
+		"Cloud KMS holding the CA private key, one of aws, gcp or azure. If set, the CA signs with the "+
+			"KMS key, and ca-cert.pem, cert-chain.pem and root-cert.pem are read from ROOT_CA_DIR.")
+
+	kmsSignerKey = env.RegisterStringVar("CA_KMS_SIGNER_KEY", "",
+		"The CA signing key in the cloud KMS: the key ID or ARN for aws, the crypto key version name for "+
+			"gcp, or the key version URL for azure.")
+
+	kmsSignerAWSRegion = env.RegisterStringVar("CA_KMS_SIGNER_AWS_REGION", "",
+		"AWS region of the CA signing key for the aws KMS signer.")
+
+	caConfigResourceEnabled = env.RegisterBoolVar("CA_CONFIG_RESOURCE_ENABLED", false,
+		"If enabled, the CA and certificate controller settings are read at runtime from the cluster scoped "+
+			"IstioCAConfig resource named default. Its unset fields keep the values of the environment variables.")
+
+	trustBundleResourcesEnabled = env.RegisterBoolVar("TRUST_BUNDLE_RESOURCES_ENABLED", false,
+		"If enabled, the root certs of the cluster scoped TrustBundle resources are trusted in addition to "+
+			"the roots of the mesh, and distributed in the root cert ConfigMap of each namespace.")
+
+	trustBundleExpiryWarning = env.RegisterDurationVar("TRUST_BUNDLE_EXPIRY_WARNING", 30*24*time.Hour,
+		"A warning is reported when a root cert of a TrustBundle resource expires within this duration.")
+
+	caControllerStatusEnabled = env.RegisterBoolVar("CA_CONTROLLER_STATUS_ENABLED", false,
+		"If enabled, the version, leader, managed secret counts, root cert fingerprint and last reconcile "+
+			"times of the certificate controllers are written every minute to the "+controllerstatus.DefaultName+
+			" ConfigMap, as read by istioctl experimental secret status.")
+
+	caSecretProtectionEnabled = env.RegisterBoolVar("CA_SECRET_PROTECTION_ENABLED", false,
+		"If enabled, istiod serves the "+secretguard.WebhookPath+" admission webhook rejecting the modifications "+
+			"and deletions of the "+secretcontroller.IstioSecretType+" secrets by anyone but istiod, and patches "+
+			"the CA bundle of the istiod-secret-protection-<namespace> validatingwebhookconfiguration.")
+
+	caSecretProtectionAllowedUsers = env.RegisterStringVar("CA_SECRET_PROTECTION_ALLOWED_USERS", "",
+		"Comma separated list of the additional users allowed to modify and delete the "+
+			secretcontroller.IstioSecretType+" secrets when CA_SECRET_PROTECTION_ENABLED is set.")
+
+	caCertMountWebhookEnabled = env.RegisterBoolVar("CA_CERT_MOUNT_WEBHOOK_ENABLED", false,
+		"If enabled, istiod serves the "+certmount.WebhookPath+" admission webhook mounting the "+
+			"service account token for the CA at "+certmount.TokenPath+" and the root cert "+
+			"ConfigMap at "+certmount.RootCertPath+" into the pods annotated with "+certmount.Annotation+"=true, "+
+			"and keeps the CA bundle of the istiod-cert-mount-<namespace> mutatingwebhookconfiguration up to date.")
+
+	serviceAccount = env.RegisterStringVar("SERVICE_ACCOUNT", "istiod-service-account",
+		"The service account istiod runs as.")
+
+	caStateResourceEnabled = env.RegisterBoolVar("CA_STATE_RESOURCE_ENABLED", false,
+		"If enabled, the CA records its root cert fingerprint, rotation history, last issued serial and "+
+			"sync times in the istio-ca-state IstioCAState resource in the istiod namespace.")
+
+	caStateFlushInterval = env.RegisterDurationVar("CA_STATE_FLUSH_INTERVAL", 30*time.Second,
+		"The interval to write changes of the CA state to the IstioCAState resource.")
+
+	caBackupStoreURL = env.RegisterStringVar("CA_BACKUP_STORE_URL", "",
+		"The object storage of the encrypted backups of istio-ca-secret and of the IstioCAState resource of the "+
+			"self-signed CA: s3://<bucket>/<prefix>, gs://<bucket>/<prefix>, azblob://<account>/<container>/<prefix> "+
+			"or file:///<dir>. Empty disables backups.")
+
+	caBackupEncryptionKeyFile = env.RegisterStringVar("CA_BACKUP_ENCRYPTION_KEY_FILE", "",
+		"Path of the file holding the base64 encoded 32 byte key encrypting the CA backups.")
+
+	caBackupInterval = env.RegisterDurationVar("CA_BACKUP_INTERVAL", 24*time.Hour,
+		"The interval of the CA backups.")
+
+	caBackupRetain = env.RegisterIntVar("CA_BACKUP_RETAIN", 7,
+		"The number of most recent CA backups kept in the store. Zero keeps all the backups.")
+
+	caBackupRestore = env.RegisterBoolVar("CA_BACKUP_RESTORE", true,
+		"If enabled, istio-ca-secret and the IstioCAState resource are restored from the most recent CA backup "+
+			"when istio-ca-secret does not exist at startup, instead of generating a new self-signed root.")
+
+	caSerialNumberStrategy = env.RegisterStringVar("CA_SERIAL_NUMBER_STRATEGY", ca.RandomSerialNumbers,
+		"How the serial numbers of issued certs are generated: random, for random 128-bit serial numbers, "+
+			"or sequential, for sequential serial numbers reserved in blocks in the IstioCAState resource, "+
+			"which are unique across restarts and istiod replicas. sequential requires CA_STATE_RESOURCE_ENABLED.")
+
+	caSerialNumberBlockSize = env.RegisterIntVar("CA_SERIAL_NUMBER_BLOCK_SIZE", 1000,
+		"The number of sequential serial numbers an istiod instance reserves at once. Unused serial "+
+			"numbers of a block are skipped when istiod restarts.")
+
+	caVersionOverlapPeriod = env.RegisterDurationVar("CA_VERSION_OVERLAP_PERIOD", 0,
+		"How long the previous CA key/cert stays in the distributed root certs after a root or "+
+			"intermediate rotation. Zero drops the previous CA as soon as it is replaced.")
+
+	caCertPolicyIdentifiers = env.RegisterStringVar("CA_CERT_POLICY_IDENTIFIERS", "",
+		"Comma separated policy OIDs, e.g. 1.3.6.1.4.1.1234.1, included in the certificate policies "+
+			"extension of issued certs.")
+
+	caCertExtraExtensions = env.RegisterStringVar("CA_CERT_EXTRA_EXTENSIONS", "",
+		"Comma separated extensions included in issued certs, each in the form <oid>=<base64 DER value>. "+
+			"A leading ! marks the extension critical.")
+
+	caCertCRLDistributionPoints = env.RegisterStringVar("CA_CERT_CRL_DISTRIBUTION_POINTS", "",
+		"Comma separated URLs of CRLs, included in the CRL distribution points extension of issued certs.")
+
+	caCertOCSPServers = env.RegisterStringVar("CA_CERT_OCSP_SERVERS", "",
+		"Comma separated URLs of OCSP responders, included in the authority information access extension "+
+			"of issued certs.")
+
+	caCertIssuingCertificateURLs = env.RegisterStringVar("CA_CERT_ISSUING_CERTIFICATE_URLS", "",
+		"Comma separated URLs to fetch the issuing CA cert from, included in the authority information "+
+			"access extension of issued certs.")
+
+	csrMinRSAKeySize = env.RegisterIntVar("CA_CSR_MIN_RSA_KEY_SIZE", ca.DefaultMinCSRRSAKeySize,
+		"The minimum RSA key size in bits accepted in a CSR.")
+
+	csrMaxSize = env.RegisterIntVar("CA_CSR_MAX_SIZE", ca.DefaultMaxCSRSize,
+		"The maximum size in bytes of a PEM-encoded CSR.")
+
+	csrMaxSANs = env.RegisterIntVar("CA_CSR_MAX_SANS", ca.DefaultMaxCSRSANs,
+		"The maximum number of SANs requested in a CSR.")
+
+	ctLogs = env.RegisterStringVar("CA_CT_LOGS", "",
+		"Comma separated base URLs of Certificate Transparency logs to submit issued certs to. "+
+			"Submission failures are logged and do not block issuance.")
+
+	ctEmbedSCTs = env.RegisterBoolVar("CA_CT_EMBED_SCTS", false,
+		"If enabled, a precertificate is submitted to CA_CT_LOGS before issuance and the returned "+
+			"SCTs are embedded in the issued cert.")
+
+	ctSubmissionTimeout = env.RegisterDurationVar("CA_CT_SUBMISSION_TIMEOUT", 10*time.Second,
+		"The timeout of a submission to a CT log.")
+
+	pluggedCertWatchEnabled = env.RegisterBoolVar("CA_PLUGGED_CERT_WATCH", true,
+		"If enabled, the CA key/cert files in ROOT_CA_DIR are watched, and reloaded without a restart "+
+			"when they change.")
+
+	caKeyPassphrase = env.RegisterStringVar("CA_KEY_PASSPHRASE", "",
+		"Passphrase of an encrypted plugged-in CA private key. CA_KEY_PASSPHRASE_FILE is preferred.")
+
+	caKeyPassphraseFile = env.RegisterStringVar("CA_KEY_PASSPHRASE_FILE", "",
+		"File holding the passphrase of an encrypted plugged-in CA private key, e.g. mounted from "+
+			"a separate secret. The CA private key can then be an encrypted PEM or PKCS#8 key.")
+
+	caSANPolicyFile = env.RegisterStringVar("CA_SAN_POLICY_FILE", "",
+		"YAML file with the SAN authorization policy, restricting which identities may request which SANs.")
+
+	caSignatureAlgorithm = env.RegisterStringVar("CA_SIGNATURE_ALGORITHM", "",
+		"The algorithm used to sign workload certs, e.g. SHA384-RSA or ECDSA-SHA384. It must match the "+
+			"type of the CA key. If empty, the default for the CA key is used.")
+
+	caSubCAMaxPathLen = env.RegisterIntVar("CA_SUB_CA_MAX_PATH_LEN", -1,
+		"The path length constraint of CA certs signed by the CA, e.g. for istiod instances of other "+
+			"clusters. 0 prevents them from issuing further CA certs, a negative value leaves it unset.")
+
+	caSubCAPermittedDNSDomains = env.RegisterStringVar("CA_SUB_CA_PERMITTED_DNS_DOMAINS", "",
+		"Comma-separated DNS domains set as name constraints of CA certs signed by the CA. Certs "+
+			"issued by these CAs can only hold DNS names in these domains.")
+
+	caSubCAPermittedTrustDomains = env.RegisterStringVar("CA_SUB_CA_PERMITTED_TRUST_DOMAINS", "",
+		"Comma-separated trust domains set as URI name constraints of CA certs signed by the CA. "+
+			"Certs issued by these CAs can only hold SPIFFE identities of these trust domains.")
+
+	vmBootstrapTokens = env.RegisterBoolVar("CA_VM_BOOTSTRAP_TOKENS", false,
+		"If enabled, VMs can exchange a single-use bootstrap token, stored in a secret of type "+
+			"istio.io/bootstrap-token in the istiod namespace, for their initial certificate.")
+
+	caCloudIdentityProvider = env.RegisterStringVar("CA_CLOUD_IDENTITY_PROVIDER", "",
+		"If set, workloads can request certificates with the tokens of cloud-managed identities: gke for the "+
+			"Google ID tokens of GKE Workload Identity, or eks for the IRSA tokens of EKS clusters.")
+
+	caCloudIdentityIssuer = env.RegisterStringVar("CA_CLOUD_IDENTITY_ISSUER", "",
+		"The OIDC issuer of the cloud identity tokens. Defaults to https://accounts.google.com for gke, "+
+			"and is required for eks.")
+
+	caCloudIdentityAudience = env.RegisterStringVar("CA_CLOUD_IDENTITY_AUDIENCE", "istio-ca",
+		"The audience the cloud identity tokens must be issued for.")
+
+	caCloudIdentityMapping = env.RegisterStringVar("CA_CLOUD_IDENTITY_MAPPING", "",
+		"Comma-separated <cloud identity>=<namespace>/<service account> mappings of the cloud identities, i.e. "+
+			"the GCP service account emails or the EKS token subjects, to mesh identities. EKS service "+
+			"accounts that are not mapped keep their namespace and name.")
+
+	caTenantsFile = env.RegisterStringVar("CA_TENANTS_FILE", "",
+		"Path of the YAML file mapping groups of namespaces, selected by their labels, to the issuing CAs "+
+			"of their tenants. Each CA is read from a directory in the layout of the plugged-in CA.")
+
+	caSigningPolicyFile = env.RegisterStringVar("CA_SIGNING_POLICY_FILE", "",
+		"Path of the YAML file of the signing policy restricting the lifetime, the SAN types and the key types "+
+			"of the certs issued to the workloads of each group of namespaces.")
+
+	caSigner = env.RegisterStringVar("CA_SIGNER", "",
+		"External CA signing workload certs instead of the Istio CA: vault, google-cas, aws-pca or plugin. The Istio CA still signs "+
+			"the istiod DNS certs, and the roots of both CAs are distributed to the namespaces.")
+
+	vaultPKIAddrs = env.RegisterStringVar("VAULT_PKI_ADDRS", "",
+		"Comma separated addresses of the Vault servers signing workload certs when CA_SIGNER is vault. "+
+			"The next server is used when a server is unavailable.")
+
+	vaultPKIMount = env.RegisterStringVar("VAULT_PKI_MOUNT", "pki",
+		"Mount point of the Vault PKI secrets engine signing workload certs.")
+
+	vaultPKIRole = env.RegisterStringVar("VAULT_PKI_ROLE", "",
+		"Vault PKI role used to sign workload CSRs with sign-verbatim.")
+
+	vaultPKITokenFile = env.RegisterStringVar("VAULT_PKI_TOKEN_FILE", "",
+		"Path of the file holding the Vault token used to sign workload certs, if no AppRole is set.")
+
+	vaultPKIAppRoleID = env.RegisterStringVar("VAULT_PKI_APPROLE_ID", "",
+		"Role ID of the Vault AppRole used to sign workload certs.")
+
+	vaultPKIAppRoleSecretIDFile = env.RegisterStringVar("VAULT_PKI_APPROLE_SECRET_ID_FILE", "",
+		"Path of the file holding the secret ID of the Vault AppRole.")
+
+	vaultPKIAppRoleMount = env.RegisterStringVar("VAULT_PKI_APPROLE_MOUNT", "approle",
+		"Mount point of the Vault AppRole auth method.")
+
+	vaultPKIRootCertFile = env.RegisterStringVar("VAULT_PKI_ROOT_CERT_FILE", "",
+		"Path of the root cert of the Vault PKI, if the CA chain in Vault does not include it.")
+
+	googleCASProject = env.RegisterStringVar("GOOGLE_CAS_PROJECT", "",
+		"Project of the Google CAS CA pool signing workload certs when CA_SIGNER is google-cas.")
+
+	googleCASLocation = env.RegisterStringVar("GOOGLE_CAS_LOCATION", "",
+		"Region of the Google CAS CA pool, e.g. us-central1.")
+
+	googleCASPool = env.RegisterStringVar("GOOGLE_CAS_POOL", "",
+		"Name of the Google CAS CA pool.")
+
+	awsPCARegion = env.RegisterStringVar("AWS_PCA_REGION", "",
+		"AWS region of the ACM private CA signing workload certs when CA_SIGNER is aws-pca.")
+
+	awsPCAArn = env.RegisterStringVar("AWS_PCA_ARN", "",
+		"ARN of the ACM private CA.")
+
+	awsPCATemplateArn = env.RegisterStringVar("AWS_PCA_TEMPLATE_ARN", "",
+		"ARN of the ACM PCA template of workload certs. If empty, end-entity certs are issued.")
+
+	awsPCASigningAlgorithm = env.RegisterStringVar("AWS_PCA_SIGNING_ALGORITHM", "SHA256WITHRSA",
+		"Algorithm the ACM private CA signs workload certs with, e.g. SHA256WITHECDSA.")
+
+	caPluginAddr = env.RegisterStringVar("CA_PLUGIN_ADDR", "",
+		"Address of the CA plugin signing workload certs when CA_SIGNER is plugin, either host:port or "+
+			"unix:///path for a Unix domain socket.")
+
+	caPluginRootCertFile = env.RegisterStringVar("CA_PLUGIN_ROOT_CERT_FILE", "",
+		"Path of the root cert verifying the TLS cert of the CA plugin. If empty, the plugin is connected "+
+			"to without TLS, which is only suitable for Unix domain sockets.")
+
+	caPluginRefreshInterval = env.RegisterDurationVar("CA_PLUGIN_TRUST_BUNDLE_REFRESH_INTERVAL", 10*time.Minute,
+		"How often the trust bundle of the CA plugin is read again.")
+
+	caBundleMutatingWebhookConfigs = env.RegisterStringVar("CA_BUNDLE_MUTATING_WEBHOOK_CONFIGS", "",
+		"Comma separated names of MutatingWebhookConfigurations whose caBundle is kept in sync with the root "+
+			"cert of the Istio CA, e.g. across root rotations.")
+
+	caBundleValidatingWebhookConfigs = env.RegisterStringVar("CA_BUNDLE_VALIDATING_WEBHOOK_CONFIGS", "",
+		"Comma separated names of ValidatingWebhookConfigurations whose caBundle is kept in sync with the root "+
+			"cert of the Istio CA.")
+
+	caRootReplication = env.RegisterBoolVar("CA_ROOT_REPLICATION", false,
+		"If enabled, the root certs of the Istio CA, including the combined bundle during a root rotation, are "+
+			"replicated into the istio-trust-anchors ConfigMap of the remote clusters, and the root certs replicated "+
+			"by the remote clusters are distributed to the local workloads, keeping multicluster mTLS working "+
+			"across clusters with their own CAs.")
+
+	csrRateLimitQPS = env.RegisterFloatVar("CA_CSR_RATE_LIMIT_QPS", 0,
+		"The number of CSRs per second each caller identity may send to the CA. 0 disables rate limiting.")
+
+	csrRateLimitBurst = env.RegisterIntVar("CA_CSR_RATE_LIMIT_BURST", 10,
+		"The number of CSRs each caller identity may send at once when CA_CSR_RATE_LIMIT_QPS is set.")
+
+	caFederatedBundleEndpoints = env.RegisterStringVar("CA_FEDERATED_BUNDLE_ENDPOINTS", "",
+		"The SPIFFE bundle endpoints of the federated trust domains, in the format of "+
+			"<trust domain>|<URL>||<trust domain>|<URL>. The fetched root certs are added to the root certs "+
+			"distributed to the workloads, so that they accept peers of the federated trust domains.")
+
+	caFederatedBundleRefreshInterval = env.RegisterDurationVar("CA_FEDERATED_BUNDLE_REFRESH_INTERVAL", 5*time.Minute,
+		"The interval at which the trust bundles of CA_FEDERATED_BUNDLE_ENDPOINTS are fetched.")
+
+	caCSRSigner = env.RegisterBoolVar("CA_KUBERNETES_CSR_SIGNER", false,
+		"If enabled, the CA signs the approved Kubernetes CertificateSigningRequests whose signer name "+
+			"starts with "+csrsigner.SignerNamePrefix+".")
+
+	issuanceWebhookURL = env.RegisterStringVar("ISSUANCE_WEBHOOK_URL", "",
+		"If set, every certificate issued by the CA, including the refreshed workload and webhook certs and "+
+			"the rotated root certs, is posted as a signed JSON event to this URL.")
+
+	issuanceWebhookKeyFile = env.RegisterStringVar("ISSUANCE_WEBHOOK_KEY_FILE", "",
+		"File holding the HMAC key used to sign the events posted to ISSUANCE_WEBHOOK_URL.")
+
+	caNotificationWebhookURL = env.RegisterStringVar("CA_NOTIFICATION_WEBHOOK_URL", "",
+		"If set, alerts about root cert rotations, failing cert secret refreshes and the CA cert expiry are "+
+			"posted to this URL, e.g. a Slack incoming webhook.")
+
+	caCertExpiryAlertThreshold = env.RegisterDurationVar("CA_CERT_EXPIRY_ALERT_THRESHOLD", 30*24*time.Hour,
+		"An alert is sent to CA_NOTIFICATION_WEBHOOK_URL when the CA cert expires within this duration.")
+
+	caIssuancePolicyURL = env.RegisterStringVar("CA_ISSUANCE_POLICY_URL", "",
+		"If set, the CA consults this endpoint before signing each workload certificate, e.g. the data API "+
+			"of Open Policy Agent such as http://localhost:8181/v1/data/istio/issuance. The endpoint receives "+
+			"the identity, SANs, TTL and requester as input, and returns whether the issuance is allowed.")
+
+	caIssuancePolicyTimeout = env.RegisterDurationVar("CA_ISSUANCE_POLICY_TIMEOUT", 2*time.Second,
+		"Timeout of the evaluation of CA_ISSUANCE_POLICY_URL.")
+
+	caIssuancePolicyFailOpen = env.RegisterBoolVar("CA_ISSUANCE_POLICY_FAIL_OPEN", false,
+		"If enabled, certificates are signed when CA_ISSUANCE_POLICY_URL fails to be evaluated. Otherwise "+
+			"the requests fail.")
+
+	auditSyslogAddress = env.RegisterStringVar("AUDIT_SYSLOG_ADDRESS", "",
+		"If set, the security audit events are sent to this syslog server, as udp://host:port or tcp://host:port.")
+
+	auditSyslogFormat = env.RegisterStringVar("AUDIT_SYSLOG_FORMAT", string(audit.FormatCEF),
+		"Format of the audit events sent to AUDIT_SYSLOG_ADDRESS, cef or json.")
+
+	auditKafkaRESTURL = env.RegisterStringVar("AUDIT_KAFKA_REST_URL", "",
+		"If set, the security audit events are produced to a Kafka topic through a Kafka REST proxy, "+
+			"e.g. http://kafka-rest:8082/topics/istio-audit.")
+
+	caPreflightChecks = env.RegisterBoolVar("CA_PREFLIGHT_CHECKS", true,
+		"If enabled, istiod verifies at startup that it is allowed to manage the CA secrets and ConfigMaps, "+
+			"that istio-ca-secret is readable and that the mounted root certs are valid, and fails with a "+
+			"clear error otherwise.")
+
+	intermediateCACertGracePeriodPercentile = env.RegisterIntVar("INTERMEDIATE_CA_CERT_GRACE_PERIOD_PERCENTILE", 20,
+		"Grace period percentile for the intermediate CA cert.")
+
 	k8sInCluster = env.RegisterStringVar("KUBERNETES_SERVICE_HOST", "",
 		"Kuberenetes service host, set automatically when running in-cluster")
 
@@ -114,6 +553,16 @@ var (
 
 	audience = env.RegisterStringVar("AUDIENCE", "",
 		"Expected audience in the tokens. ")
+
+	tokenReviewAudiences = env.RegisterStringVar("TOKEN_REVIEW_AUDIENCES", "",
+		"Comma separated list of audiences one of which the tokens of the CA clients must be bound to. "+
+			"If unset, the audience of the JWT policy is used.")
+
+	tokenTrustedIssuers = env.RegisterStringVar("TOKEN_TRUSTED_ISSUERS", "",
+		"Comma separated list of the issuers of the tokens accepted from the CA clients. If unset, all issuers are accepted.")
+
+	requireBoundTokens = env.RegisterBoolVar("REQUIRE_BOUND_TOKENS", false,
+		"If enabled, the CA rejects the first-party service account tokens, and only accepts bound (projected) tokens.")
 )
 
 type CAOptions struct {
@@ -196,6 +645,18 @@ func (s *Server) RunCA(grpc *grpc.Server, ca caserver.CertificateAuthority, opts
 	if startErr != nil {
 		log.Fatalf("failed to create istio ca server: %v", startErr)
 	}
+	caServer.SetRateLimit(caserver.RateLimitConfig{QPS: csrRateLimitQPS.Get(), Burst: csrRateLimitBurst.Get()})
+	if url := caIssuancePolicyURL.Get(); url != "" {
+		caServer.SetIssuancePolicy(caserver.NewWebhookIssuancePolicy(url, caIssuancePolicyTimeout.Get()),
+			caIssuancePolicyFailOpen.Get())
+	}
+	if auds := tokenReviewAudiences.Get(); auds != "" {
+		caServer.SetTokenAudiences(strings.Split(auds, ","))
+	}
+	if s.caSigningPolicy != nil {
+		caServer.SetSigningPolicy(s.caSigningPolicy)
+	}
+	s.watchMeshTrustDomain(caServer)
 
 	// TODO: if not set, parse Istiod's own token (if present) and get the issuer. The same issuer is used
 	// for all tokens - no need to configure twice. The token may also include cluster info to auto-configure
@@ -213,6 +674,35 @@ func (s *Server) RunCA(grpc *grpc.Server, ca caserver.CertificateAuthority, opts
 		}
 	}
 
+	if vmBootstrapTokens.Get() && s.kubeClient != nil {
+		caServer.Authenticators = append(caServer.Authenticators,
+			authenticate.NewBootstrapTokenAuthenticator(s.kubeClient.CoreV1(), opts.Namespace))
+		log.Infoa("Using VM bootstrap token authentication")
+	}
+
+	if provider := caCloudIdentityProvider.Get(); provider != "" {
+		mapping, err := authenticate.ParseCloudIdentityMapping(caCloudIdentityMapping.Get())
+		if err != nil {
+			log.Fatalf("invalid CA_CLOUD_IDENTITY_MAPPING: %v", err)
+		}
+		cloudAuth, err := authenticate.NewCloudIdentityAuthenticator(provider, caCloudIdentityIssuer.Get(),
+			caCloudIdentityAudience.Get(), opts.TrustDomain, mapping)
+		if err != nil {
+			log.Fatalf("failed to create the cloud identity authenticator: %v", err)
+		}
+		caServer.Authenticators = append(caServer.Authenticators, cloudAuth)
+		log.Infof("Using %s cloud identity authentication", provider)
+	}
+
+	policy := authenticate.TokenPolicy{RequireBoundTokens: requireBoundTokens.Get()}
+	if auds := tokenReviewAudiences.Get(); auds != "" {
+		policy.Audiences = strings.Split(auds, ",")
+	}
+	if issuers := tokenTrustedIssuers.Get(); issuers != "" {
+		policy.Issuers = strings.Split(issuers, ",")
+	}
+	caServer.SetTokenPolicy(policy)
+
 	// Allow authorization with a previously issued certificate, for VMs
 	// Will return a caller with identities extracted from the SAN, should be a SPIFFE identity.
 	caServer.Authenticators = append(caServer.Authenticators, &authenticate.ClientCertAuthenticator{})
@@ -311,6 +801,8 @@ func (s *Server) initPublicKey() error {
 func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *CAOptions) (*ca.IstioCA, error) {
 	var caOpts *ca.IstioCAOptions
 	var err error
+	// backuper backs up the self-signed CA, if enabled.
+	var backuper *cabackup.Backuper
 
 	maxCertTTL := maxWorkloadCertTTL.Get()
 	if SelfSignedCACertTTL.Get().Seconds() > maxCertTTL.Seconds() {
@@ -327,7 +819,54 @@ func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *CAOptions) (
 		rootCertFile = ""
 	}
 
-	if _, err := os.Stat(signingKeyFile); err != nil {
+	if secretName := pluggedCASecretName.Get(); secretName != "" && client != nil {
+		log.Infof("Use plugged-in CA certificate from secret %s", secretName)
+		source := secretcontroller.CASecretSource{
+			Name:          secretName,
+			Namespace:     pluggedCASecretNamespace.Get(),
+			CertKey:       pluggedCASecretCertKey.Get(),
+			PrivateKeyKey: pluggedCASecretPrivateKeyKey.Get(),
+			CertChainKey:  pluggedCASecretCertChainKey.Get(),
+			RootCertKey:   pluggedCASecretRootCertKey.Get(),
+		}
+		if source.KeyPassphrase, err = readCAKeyPassphrase(); err != nil {
+			return nil, err
+		}
+		if source.Namespace == "" {
+			source.Namespace = opts.Namespace
+		}
+		caOpts, err = ca.NewPluggedCertIstioCAOptionsFromSecret(source, rootCertFile,
+			workloadCertTTL.Get(), maxCertTTL, opts.Namespace, client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create an istiod CA: %v", err)
+		}
+	} else if pkcs11Configured() {
+		caOpts, err = createPKCS11CAOptions(client, opts, maxCertTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create an istiod CA: %v", err)
+		}
+	} else if kmsSignerBackend.Get() != "" {
+		log.Infof("Use CA private key in %s KMS", kmsSignerBackend.Get())
+		caOpts, err = createKMSSignerCAOptions(client, opts, maxCertTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create an istiod CA: %v", err)
+		}
+	} else if upstreamDir := intermediateCAUpstreamDir.Get(); upstreamDir != "" && client != nil {
+		log.Infof("Use intermediate CA certificate signed by the upstream CA in %s", upstreamDir)
+		upstream, err := ca.NewFileUpstreamCA(path.Join(upstreamDir, "ca-cert.pem"),
+			path.Join(upstreamDir, "ca-key.pem"), path.Join(upstreamDir, "cert-chain.pem"),
+			path.Join(upstreamDir, "root-cert.pem"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create an istiod CA: %v", err)
+		}
+		caOpts, err = ca.NewIntermediateIstioCAOptions(upstream, intermediateCACertTTL.Get(),
+			workloadCertTTL.Get(), maxCertTTL, intermediateCACertCheckInterval.Get(),
+			intermediateCACertGracePeriodPercentile.Get(), opts.TrustDomain, opts.TrustDomain,
+			rootCertFile, opts.Namespace, client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create an istiod CA: %v", err)
+		}
+	} else if _, err := os.Stat(signingKeyFile); err != nil {
 		// The user-provided certs are missing - create a self-signed cert.
 		// If we are not in K8S - no CA
 		// TODO: generate self-signed files in the /etc/cacert for non-k8s
@@ -349,14 +888,42 @@ func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *CAOptions) (
 		// maxCertTTL in NewSelfSignedIstioCAOptions() is set to be the same as
 		// SelfSignedCACertTTL because the istiod certificate issued by Citadel
 		// will have a TTL equal to SelfSignedCACertTTL.
-		caOpts, err = ca.NewSelfSignedIstioCAOptions(ctx,
-			selfSignedRootCertGracePeriodPercentile.Get(), SelfSignedCACertTTL.Get(),
-			selfSignedRootCertCheckInterval.Get(), workloadCertTTL.Get(),
-			maxCertTTL, opts.TrustDomain, true,
-			opts.Namespace, -1, client, rootCertFile,
-			enableJitterForRootCertRotator.Get())
-		if err != nil {
-			return nil, fmt.Errorf("failed to create a self-signed istiod CA: %v", err)
+		if vaultCAStoreAddr.Get() != "" {
+			caOpts, err = createVaultStoreCAOptions(client, opts, maxCertTTL, rootCertFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create a self-signed istiod CA: %v", err)
+			}
+		} else {
+			keyEncryption, err := createKeyEncryptionProvider()
+			if err != nil {
+				return nil, fmt.Errorf("failed to create a self-signed istiod CA: %v", err)
+			}
+			if backuper, err = s.createCABackuper(client, opts.Namespace); err != nil {
+				return nil, fmt.Errorf("failed to create a self-signed istiod CA: %v", err)
+			}
+			if backuper != nil && caBackupRestore.Get() {
+				if err := restoreCABackup(ctx, backuper, client, opts.Namespace); err != nil {
+					return nil, fmt.Errorf("failed to create a self-signed istiod CA: %v", err)
+				}
+			}
+			caOpts, err = ca.NewSelfSignedIstioCAOptionsWithKeyEncryption(ctx,
+				selfSignedRootCertGracePeriodPercentile.Get(), SelfSignedCACertTTL.Get(),
+				selfSignedRootCertCheckInterval.Get(), workloadCertTTL.Get(),
+				maxCertTTL, opts.TrustDomain, true,
+				opts.Namespace, -1, client, rootCertFile,
+				enableJitterForRootCertRotator.Get(), keyEncryption)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create a self-signed istiod CA: %v", err)
+			}
+			caOpts.RotatorConfig.RenewBefore = selfSignedRootCertRenewBefore.Get()
+			if fraction := rootRotationConfirmFraction.Get(); fraction != 0 {
+				caOpts.RootRotationConfig = &ca.RootRotationConfig{
+					ConfirmFraction:     fraction,
+					CheckInterval:       rootRotationCheckInterval.Get(),
+					MinDistributionTime: rootRotationMinDistributionTime.Get(),
+					RootCertConfigMap:   controller.CACertNamespaceConfigMap,
+				}
+			}
 		}
 	} else {
 		log.Info("Use local CA certificate")
@@ -368,11 +935,88 @@ func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *CAOptions) (
 		certChainFile := path.Join(LocalCertDir.Get(), "cert-chain.pem")
 		s.caBundlePath = certChainFile
 
-		caOpts, err = ca.NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, signingKeyFile,
-			rootCertFile, workloadCertTTL.Get(), maxCertTTL, opts.Namespace, client)
+		passphrase, err := readCAKeyPassphrase()
+		if err != nil {
+			return nil, err
+		}
+		caOpts, err = ca.NewPluggedCertIstioCAOptionsWithKeyPassphrase(certChainFile, signingCertFile, signingKeyFile,
+			rootCertFile, passphrase, workloadCertTTL.Get(), maxCertTTL, opts.Namespace, client)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create an istiod CA: %v", err)
 		}
+		caOpts.PluggedCertWatcherConfig.Enabled = pluggedCertWatchEnabled.Get()
+	}
+
+	caOpts.MinCertTTL = minWorkloadCertTTL.Get()
+	caOpts.CAVersionOverlap = caVersionOverlapPeriod.Get()
+	caOpts.CSRValidation = &ca.CSRValidationOptions{
+		MinRSAKeySize: csrMinRSAKeySize.Get(),
+		MaxCSRSize:    csrMaxSize.Get(),
+		MaxSANs:       csrMaxSANs.Get(),
+	}
+	if err := setCertExtensions(caOpts); err != nil {
+		return nil, err
+	}
+	if f := caSANPolicyFile.Get(); f != "" {
+		policy, err := ca.LoadSANPolicyFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CA_SAN_POLICY_FILE: %v", err)
+		}
+		caOpts.SANPolicy = policy
+	}
+	sigAlg, err := pkiutil.ParseSignatureAlgorithm(caSignatureAlgorithm.Get())
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA_SIGNATURE_ALGORITHM: %v", err)
+	}
+	caOpts.SignatureAlgorithm = sigAlg
+	caOpts.SubCAMaxPathLen = caSubCAMaxPathLen.Get()
+	caOpts.SubCAMaxPathLenZero = caOpts.SubCAMaxPathLen == 0
+	if domains := caSubCAPermittedDNSDomains.Get(); domains != "" {
+		caOpts.SubCAPermittedDNSDomains = strings.Split(domains, ",")
+	}
+	if domains := caSubCAPermittedTrustDomains.Get(); domains != "" {
+		caOpts.SubCAPermittedURIDomains = strings.Split(domains, ",")
+	}
+	if logs := ctLogs.Get(); logs != "" {
+		submitter, err := ct.NewSubmitter(ct.Config{
+			Logs:      strings.Split(logs, ","),
+			EmbedSCTs: ctEmbedSCTs.Get(),
+			Timeout:   ctSubmissionTimeout.Get(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid CA_CT_LOGS: %v", err)
+		}
+		caOpts.CTSubmitter = submitter
+	}
+
+	// rootCertRotatorChan channel accepts signals to stop root cert rotator for
+	// self-signed CA.
+	rootCertRotatorChan := make(chan struct{})
+
+	if caStateResourceEnabled.Get() && s.kubeConfig != nil {
+		dynamicClient, err := dynamic.NewForConfig(s.kubeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create a dynamic client for the CA state: %v", err)
+		}
+		stateController := castate.NewController(dynamicClient, opts.Namespace, castate.DefaultName)
+		if err := stateController.Load(context.TODO()); err != nil {
+			log.Warnf("Failed to load the persisted CA state: %v", err)
+		}
+		caOpts.StateRecorder = stateController
+		if caSerialNumberStrategy.Get() == ca.SequentialSerialNumbers {
+			generator, err := ca.NewSequentialSerialNumberGenerator(stateController, int64(caSerialNumberBlockSize.Get()))
+			if err != nil {
+				return nil, fmt.Errorf("invalid CA_SERIAL_NUMBER_BLOCK_SIZE: %v", err)
+			}
+			caOpts.SerialNumberGenerator = generator
+		}
+		go stateController.Run(caStateFlushInterval.Get(), rootCertRotatorChan)
+	}
+	switch strategy := caSerialNumberStrategy.Get(); {
+	case strategy == ca.SequentialSerialNumbers && caOpts.SerialNumberGenerator == nil:
+		return nil, fmt.Errorf("CA_SERIAL_NUMBER_STRATEGY=%s requires CA_STATE_RESOURCE_ENABLED", strategy)
+	case strategy != ca.SequentialSerialNumbers && strategy != ca.RandomSerialNumbers:
+		return nil, fmt.Errorf("invalid CA_SERIAL_NUMBER_STRATEGY %q", strategy)
 	}
 
 	istioCA, err := ca.NewIstioCA(caOpts)
@@ -383,11 +1027,697 @@ func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *CAOptions) (
 	// TODO: provide an endpoint returning all the roots. SDS can only pull a single root in current impl.
 	// ca.go saves or uses the secret, but also writes to the configmap "istio-security", under caTLSRootCert
 
-	// rootCertRotatorChan channel accepts signals to stop root cert rotator for
-	// self-signed CA.
-	rootCertRotatorChan := make(chan struct{})
 	// Start root cert rotator in a separate goroutine.
 	istioCA.Run(rootCertRotatorChan)
+	if backuper != nil {
+		go backuper.Run(caBackupInterval.Get(), rootCertRotatorChan)
+	}
 
 	return istioCA, nil
 }
+
+// createVaultStoreCAOptions creates the options of a self-signed CA whose key and cert are
+// persisted in Vault.
+func createVaultStoreCAOptions(client corev1.CoreV1Interface, opts *CAOptions, maxCertTTL time.Duration,
+	rootCertFile string) (*ca.IstioCAOptions, error) {
+	var token []byte
+	if f := vaultCAStoreTokenFile.Get(); f != "" {
+		var err error
+		if token, err = ioutil.ReadFile(f); err != nil {
+			return nil, fmt.Errorf("failed to read Vault token: %v", err)
+		}
+	}
+	store, err := vault.NewKVStore(vaultCAStoreAddr.Get(), strings.TrimSpace(string(token)),
+		vaultCAStoreMount.Get(), vaultCAStorePath.Get(), vaultCAStoreKVVersion.Get())
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Use Vault at %s to persist the self-signed CA", vaultCAStoreAddr.Get())
+	return ca.NewSelfSignedIstioCAOptionsFromStore(store, SelfSignedCACertTTL.Get(), workloadCertTTL.Get(),
+		maxCertTTL, opts.TrustDomain, true, opts.Namespace, client, rootCertFile)
+}
+
+// createExternalCA creates the external CA selected by CA_SIGNER, or returns nil if none is selected.
+func (s *Server) createExternalCA(opts *CAOptions) (caserver.CertificateAuthority, error) {
+	var externalCA caserver.CertificateAuthority
+	var err error
+	switch caSigner.Get() {
+	case "":
+		return nil, nil
+	case "vault":
+		externalCA, err = s.createVaultPKICA(opts)
+	case "google-cas":
+		log.Infof("Use the Google CAS CA pool %s in %s to sign workload certs", googleCASPool.Get(), googleCASLocation.Get())
+		externalCA, err = cas.NewCA(context.Background(), cas.Config{
+			Project:    googleCASProject.Get(),
+			Location:   googleCASLocation.Get(),
+			Pool:       googleCASPool.Get(),
+			DefaultTTL: workloadCertTTL.Get(),
+			MaxTTL:     maxWorkloadCertTTL.Get(),
+		})
+	case "aws-pca":
+		log.Infof("Use the ACM private CA %s to sign workload certs", awsPCAArn.Get())
+		externalCA, err = awspca.NewCA(awspca.Config{
+			Region:           awsPCARegion.Get(),
+			CAArn:            awsPCAArn.Get(),
+			TemplateArn:      awsPCATemplateArn.Get(),
+			SigningAlgorithm: awsPCASigningAlgorithm.Get(),
+			DefaultTTL:       workloadCertTTL.Get(),
+			MaxTTL:           maxWorkloadCertTTL.Get(),
+		})
+	case "plugin":
+		externalCA, err = s.createPluginCA()
+	default:
+		return nil, fmt.Errorf("unsupported CA signer %q", caSigner.Get())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return externalCA, nil
+}
+
+// createTenantCA creates the CA routing the workload CSRs to the issuing CAs of the tenants configured
+// in CA_TENANTS_FILE, or returns nil if none is configured. defaultCA signs the other CSRs.
+func (s *Server) createTenantCA(defaultCA caserver.CertificateAuthority) (*caserver.TenantCA, error) {
+	f := caTenantsFile.Get()
+	if f == "" {
+		return nil, nil
+	}
+	if s.kubeClient == nil {
+		return nil, fmt.Errorf("CA_TENANTS_FILE requires a Kubernetes client")
+	}
+	config, err := caserver.LoadTenantsFile(f)
+	if err != nil {
+		return nil, err
+	}
+	maxCertTTL := maxWorkloadCertTTL.Get()
+	if SelfSignedCACertTTL.Get() < maxCertTTL {
+		maxCertTTL = SelfSignedCACertTTL.Get()
+	}
+	tenants := make([]caserver.Tenant, 0, len(config.Tenants))
+	for _, t := range config.Tenants {
+		// The istio-security ConfigMap holds the cert of the default CA, hence no client.
+		caOpts, err := ca.NewPluggedCertIstioCAOptions(path.Join(t.CertDir, "cert-chain.pem"),
+			path.Join(t.CertDir, "ca-cert.pem"), path.Join(t.CertDir, "ca-key.pem"),
+			path.Join(t.CertDir, "root-cert.pem"), workloadCertTTL.Get(), maxCertTTL, "", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the CA of tenant %s: %v", t.Name, err)
+		}
+		caOpts.MinCertTTL = minWorkloadCertTTL.Get()
+		tenantCA, err := ca.NewIstioCA(caOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the CA of tenant %s: %v", t.Name, err)
+		}
+		tenants = append(tenants, caserver.Tenant{
+			Name:     t.Name,
+			Selector: labels.SelectorFromSet(t.NamespaceSelector),
+			CA:       tenantCA,
+		})
+		log.Infof("Use the CA in %s to sign the workload certs of tenant %s", t.CertDir, t.Name)
+	}
+	tenantCA := caserver.NewTenantCA(defaultCA, tenants, s.kubeClient)
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go tenantCA.Run(stop)
+		return nil
+	})
+	return tenantCA, nil
+}
+
+// createSigningPolicy creates the signing policy configured in CA_SIGNING_POLICY_FILE, or returns nil
+// if none is configured.
+func (s *Server) createSigningPolicy() (*caserver.SigningPolicy, error) {
+	f := caSigningPolicyFile.Get()
+	if f == "" {
+		return nil, nil
+	}
+	policy, err := caserver.LoadSigningPolicyFile(f, s.kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go policy.Run(stop)
+		return nil
+	})
+	log.Infof("Use the signing policy in %s", f)
+	return policy, nil
+}
+
+// createVaultPKICA creates a CA signing workload certs with the Vault PKI secrets engine.
+func (s *Server) createVaultPKICA(opts *CAOptions) (*vault.PKICA, error) {
+	config := vault.PKIConfig{
+		Mount:        vaultPKIMount.Get(),
+		Role:         vaultPKIRole.Get(),
+		AppRoleID:    vaultPKIAppRoleID.Get(),
+		AppRoleMount: vaultPKIAppRoleMount.Get(),
+		DefaultTTL:   workloadCertTTL.Get(),
+		MaxTTL:       maxWorkloadCertTTL.Get(),
+		Addrs:        splitNames(vaultPKIAddrs.Get()),
+	}
+	if f := vaultPKITokenFile.Get(); f != "" {
+		token, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Vault token: %v", err)
+		}
+		config.Token = strings.TrimSpace(string(token))
+	}
+	if f := vaultPKIAppRoleSecretIDFile.Get(); f != "" {
+		secretID, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Vault AppRole secret ID: %v", err)
+		}
+		config.AppRoleSecretID = strings.TrimSpace(string(secretID))
+	}
+	if f := vaultPKIRootCertFile.Get(); f != "" {
+		rootCert, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Vault root cert: %v", err)
+		}
+		config.RootCert = rootCert
+	}
+	pkiCA, err := vault.NewPKICA(config)
+	if err != nil {
+		return nil, err
+	}
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go pkiCA.Run(stop)
+		return nil
+	})
+	log.Infof("Use the Vault PKI at %s to sign workload certs", vaultPKIAddrs.Get())
+	return pkiCA, nil
+}
+
+// initCABundleReconciler keeps the caBundle of the configured webhook configurations in sync with
+// the root cert of the Istio CA.
+func (s *Server) initCABundleReconciler() {
+	mutating := splitNames(caBundleMutatingWebhookConfigs.Get())
+	validating := splitNames(caBundleValidatingWebhookConfigs.Get())
+	if s.kubeClient == nil || (len(mutating) == 0 && len(validating) == 0) {
+		return
+	}
+	r := webhooks.NewCABundleReconciler(s.kubeClient, mutating, validating, func() []byte {
+		return s.ca.GetCAKeyCertBundle().GetRootCertPem()
+	})
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go r.Run(stop, controller.NamespaceResyncPeriod)
+		return nil
+	})
+}
+
+// initTrustAnchorReplication replicates the root certs of the Istio CA into the remote clusters,
+// and loads the root certs replicated by the remote clusters.
+func (s *Server) initTrustAnchorReplication(args *PilotArgs) {
+	if !caRootReplication.Get() || s.kubeClient == nil {
+		return
+	}
+	replicator := trustanchor.NewReplicator(s.clusterID, args.Namespace, func() []byte {
+		return s.ca.GetCAKeyCertBundle().GetRootCertPem()
+	})
+	args.RegistryOptions.KubeOptions.ClusterHandlers = append(args.RegistryOptions.KubeOptions.ClusterHandlers, replicator)
+	s.trustAnchorPeers = trustanchor.NewPeers(s.kubeClient.CoreV1(), args.Namespace, s.clusterID)
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go replicator.Run(stop, controller.NamespaceResyncPeriod)
+		go s.trustAnchorPeers.Run(stop, controller.NamespaceResyncPeriod)
+		return nil
+	})
+	log.Infof("Replicating the root certs of cluster %s to the remote clusters", s.clusterID)
+}
+
+// initRootRotation runs the two-phase root rotation of the CA, if enabled, on the elected istiod only.
+func (s *Server) initRootRotation(args *PilotArgs) {
+	rotation := s.ca.RootRotation()
+	if rotation == nil || s.kubeClient == nil {
+		return
+	}
+	s.addTerminatingStartFunc(func(stop <-chan struct{}) error {
+		leaderelection.
+			NewLeaderElection(args.Namespace, args.PodName, leaderelection.CARootRotation, s.kubeClient).
+			AddRunFunction(func(stop <-chan struct{}) {
+				log.Info("Running the two-phase root rotation of the CA")
+				rotation.Run(stop)
+			}).
+			Run(stop)
+		return nil
+	})
+}
+
+// initCSRSigner signs the Kubernetes CSRs of the istio.io signers, if enabled.
+func (s *Server) initCSRSigner() {
+	if !caCSRSigner.Get() || s.kubeClient == nil {
+		return
+	}
+	signer := csrsigner.NewController(s.kubeClient.CertificatesV1beta1(), s.ca, workloadCertTTL.Get())
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go signer.Run(stop)
+		return nil
+	})
+}
+
+// initIssuanceWebhook posts the issued certificates to an external inventory, if configured.
+func (s *Server) initIssuanceWebhook() error {
+	if issuanceWebhookURL.Get() == "" {
+		return nil
+	}
+	var key []byte
+	if issuanceWebhookKeyFile.Get() != "" {
+		var err error
+		if key, err = ioutil.ReadFile(issuanceWebhookKeyFile.Get()); err != nil {
+			return fmt.Errorf("failed to read the issuance webhook key: %v", err)
+		}
+		key = bytes.TrimSpace(key)
+	}
+	s.runAuditExporter(audit.NewWebhookExporter(issuanceWebhookURL.Get(), key))
+	log.Infof("Posting the issued certificates to %s", issuanceWebhookURL.Get())
+	return nil
+}
+
+// initCAConfig applies the IstioCAConfig resource to the CA and the certificate controllers, if enabled.
+func (s *Server) initCAConfig() error {
+	if !caConfigResourceEnabled.Get() || s.kubeConfig == nil {
+		return nil
+	}
+	dynamicClient, err := dynamic.NewForConfig(s.kubeConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create a dynamic client for the CA config: %v", err)
+	}
+	defaultStrategy, err := defaultRenewalStrategy()
+	if err != nil {
+		return err
+	}
+	defaultServices, err := chiron.ParseWebhookServices(certControllerWebhookServices.Get())
+	if err != nil {
+		return err
+	}
+	c := caconfig.NewController(dynamicClient, caconfig.DefaultName)
+	if s.ca != nil {
+		c.AddHandler(s.applyCAConfigSpec)
+	}
+	c.AddHandler(func(spec *caconfig.Spec) error {
+		strategy, _ := spec.RenewalStrategy()
+		if strategy == nil {
+			strategy = defaultStrategy
+		}
+		if s.certController != nil {
+			s.certController.SetRenewalStrategy(strategy)
+		}
+		if s.webhookCerts == nil {
+			return nil
+		}
+		s.webhookCerts.setRenewalStrategy(strategy)
+		services, _ := spec.WebhookServices()
+		if services == nil {
+			services = defaultServices
+		}
+		return s.webhookCerts.setServices(services)
+	})
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go c.Run(stop)
+		return nil
+	})
+	log.Infof("Watching the %s %s resource", caconfig.Kind, caconfig.DefaultName)
+	return nil
+}
+
+// initTrustBundles watches the TrustBundle resources, if enabled.
+func (s *Server) initTrustBundles() error {
+	if !trustBundleResourcesEnabled.Get() || s.kubeConfig == nil {
+		return nil
+	}
+	dynamicClient, err := dynamic.NewForConfig(s.kubeConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create a dynamic client for the trust bundles: %v", err)
+	}
+	s.trustBundles = trustbundle.NewController(dynamicClient, trustBundleExpiryWarning.Get(), time.Hour)
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go s.trustBundles.Run(stop)
+		return nil
+	})
+	log.Infof("Watching the %s resources", trustbundle.Kind)
+	return nil
+}
+
+// initControllerStatus periodically writes the status of the certificate controllers, if enabled.
+func (s *Server) initControllerStatus(args *PilotArgs) {
+	if !caControllerStatusEnabled.Get() || s.kubeClient == nil {
+		return
+	}
+	writer := controllerstatus.NewWriter(s.kubeClient.CoreV1(), args.Namespace, controllerstatus.DefaultName,
+		func() controllerstatus.Report {
+			report := controllerstatus.Report{Version: version.Info.Version, Leader: args.PodName}
+			if s.ca != nil {
+				report.RootCertSHA256 = castate.Fingerprint(s.ca.GetCAKeyCertBundle().GetRootCertPem())
+			}
+			if s.certController != nil {
+				report.AddControllerStatus(s.certController.Status())
+			}
+			if s.webhookCerts != nil {
+				if wc := s.webhookCerts.controller(); wc != nil {
+					report.AddControllerStatus(wc.Status())
+				}
+			}
+			return report
+		})
+	s.addTerminatingStartFunc(func(stop <-chan struct{}) error {
+		leaderelection.
+			NewLeaderElection(args.Namespace, args.PodName, leaderelection.CAControllerStatus, s.kubeClient).
+			AddRunFunction(func(stop <-chan struct{}) {
+				log.Infof("Writing the certificate controller status to %s", controllerstatus.DefaultName)
+				writer.Run(time.Minute, stop)
+			}).
+			Run(stop)
+		return nil
+	})
+}
+
+// initSecretProtection rejects the modifications of the Citadel secrets by anyone but istiod, if enabled.
+func (s *Server) initSecretProtection(args *PilotArgs) {
+	if !caSecretProtectionEnabled.Get() || s.kubeClient == nil || s.httpsMux == nil {
+		return
+	}
+	allowedUsers := append([]string{secretguard.ServiceAccountUser(args.Namespace, serviceAccount.Get())},
+		secretguard.DefaultAllowedUsers...)
+	for _, user := range strings.Split(caSecretProtectionAllowedUsers.Get(), ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			allowedUsers = append(allowedUsers, user)
+		}
+	}
+	s.httpsMux.Handle(secretguard.WebhookPath, secretguard.NewGuard(allowedUsers, args.Namespace))
+	s.reconcileWebhookCABundle(nil, []string{"istiod-secret-protection-" + args.Namespace})
+	log.Infof("Protecting the %v secrets and the CA secret, allowed users: %v", secretguard.ProtectedTypes, allowedUsers)
+}
+
+// initCertMount mounts the workload credentials into the annotated pods, if enabled.
+func (s *Server) initCertMount(args *PilotArgs) {
+	if !caCertMountWebhookEnabled.Get() || s.kubeClient == nil || s.httpsMux == nil {
+		return
+	}
+	s.httpsMux.HandleFunc(certmount.WebhookPath, certmount.ServeHTTP)
+	s.reconcileWebhookCABundle([]string{"istiod-cert-mount-" + args.Namespace}, nil)
+	log.Infof("Mounting the workload credentials into the pods annotated with %s", certmount.Annotation)
+}
+
+// reconcileWebhookCABundle keeps the CA bundle of istiod in the caBundle of the named webhook
+// configurations of the webhooks served by istiod.
+func (s *Server) reconcileWebhookCABundle(mutating, validating []string) {
+	r := webhooks.NewCABundleReconciler(s.kubeClient, mutating, validating, func() []byte {
+		caBundle, err := ioutil.ReadFile(s.caBundlePath)
+		if err != nil {
+			log.Errorf("failed to read the CA bundle of the webhooks %v: %v", append(mutating, validating...), err)
+		}
+		return caBundle
+	})
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go r.Run(stop, time.Minute)
+		return nil
+	})
+}
+
+// initAuditExport exports the audit events to the configured SIEM destinations.
+func (s *Server) initAuditExport() error {
+	var exporters []audit.Exporter
+	if address := auditSyslogAddress.Get(); address != "" {
+		parts := strings.SplitN(address, "://", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid syslog address %q, expected udp://host:port or tcp://host:port", address)
+		}
+		exporter, err := audit.NewSyslogExporter(parts[0], parts[1], audit.Format(auditSyslogFormat.Get()))
+		if err != nil {
+			return err
+		}
+		exporters = append(exporters, exporter)
+	}
+	if url := auditKafkaRESTURL.Get(); url != "" {
+		exporters = append(exporters, audit.NewKafkaRESTExporter(url))
+	}
+	for _, exporter := range exporters {
+		s.runAuditExporter(exporter)
+		log.Infof("Exporting the audit events to %v", exporter)
+	}
+	return nil
+}
+
+// runAuditExporter passes the audit entries to exporter through an ExportSink.
+func (s *Server) runAuditExporter(exporter audit.Exporter) {
+	sink := audit.NewExportSink(exporter)
+	audit.RegisterSink(sink)
+	// The entries are exported until the secret updates in progress are drained on shutdown.
+	s.addTerminatingStartFunc(func(stop <-chan struct{}) error {
+		sink.Run(s.drained)
+		return nil
+	})
+}
+
+// initNotifications posts the alerts about the CA to a webhook, and watches the expiry of the CA
+// cert, if configured.
+func (s *Server) initNotifications() {
+	if caNotificationWebhookURL.Get() == "" {
+		return
+	}
+	notifier := notify.NewWebhookNotifier(caNotificationWebhookURL.Get())
+	notify.Register(notifier)
+	checker := notify.NewExpiryChecker(caCertExpiryAlertThreshold.Get())
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go notifier.Run(stop)
+		go func() {
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+			for {
+				cert, _, _, _ := s.ca.GetCAKeyCertBundle().GetAll()
+				checker.Check(cert, time.Now())
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+		return nil
+	})
+	log.Infof("Posting the CA alerts to %s", caNotificationWebhookURL.Get())
+}
+
+// initFederatedBundles fetches the trust bundles of the federated trust domains, if configured.
+func (s *Server) initFederatedBundles() error {
+	if caFederatedBundleEndpoints.Get() == "" {
+		return nil
+	}
+	endpoints, err := spiffe.ParseBundleEndpoints(caFederatedBundleEndpoints.Get())
+	if err != nil {
+		return fmt.Errorf("invalid federated bundle endpoints: %v", err)
+	}
+	s.federatedBundles = spiffe.NewBundleFetcher(endpoints, nil)
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go s.federatedBundles.Run(stop, caFederatedBundleRefreshInterval.Get())
+		return nil
+	})
+	log.Infof("Fetching the trust bundles of the federated trust domains from %v", endpoints)
+	return nil
+}
+
+// runCAPreflightChecks verifies the Kubernetes access and the root certs the CA needs, so that a
+// misconfiguration fails istiod at startup instead of after the CA retries for minutes.
+func (s *Server) runCAPreflightChecks(namespace string) error {
+	if !caPreflightChecks.Get() || s.kubeClient == nil {
+		return nil
+	}
+	verbs := []string{"get", "create", "update"}
+	perms := append(preflight.Permissions("secrets", verbs, namespace),
+		preflight.Permissions("configmaps", verbs, namespace)...)
+	if err := preflight.CheckPermissions(s.kubeClient.AuthorizationV1().SelfSubjectAccessReviews(), perms); err != nil {
+		return err
+	}
+	if err := preflight.CheckSecretAccess(s.kubeClient.CoreV1(), namespace, ca.CASecret); err != nil {
+		return err
+	}
+	rootCertFile := path.Join(LocalCertDir.Get(), "root-cert.pem")
+	if bundle, err := ioutil.ReadFile(rootCertFile); err == nil {
+		if err = preflight.CheckCABundle(bundle, time.Now()); err != nil {
+			return fmt.Errorf("invalid root certs in %s: %v", rootCertFile, err)
+		}
+	}
+	return nil
+}
+
+// splitNames splits a comma separated list of names, ignoring empty names.
+func splitNames(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// createPluginCA creates a CA signing workload certs with an out-of-process CA plugin.
+func (s *Server) createPluginCA() (*caplugin.CA, error) {
+	dialOpt := grpc.WithInsecure()
+	if f := caPluginRootCertFile.Get(); f != "" {
+		creds, err := credentials.NewClientTLSFromFile(f, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA plugin root cert: %v", err)
+		}
+		dialOpt = grpc.WithTransportCredentials(creds)
+	}
+	pluginCA, err := caplugin.NewCA(caPluginAddr.Get(), 0, dialOpt)
+	if err != nil {
+		return nil, err
+	}
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go pluginCA.Run(stop, caPluginRefreshInterval.Get())
+		return nil
+	})
+	s.addReadinessProbe("CA Plugin", func() (bool, error) {
+		if err := pluginCA.Healthy(); err != nil {
+			return false, err
+		}
+		return true, nil
+	})
+	log.Infof("Use the CA plugin at %s to sign workload certs", caPluginAddr.Get())
+	return pluginCA, nil
+}
+
+// readCAKeyPassphrase returns the passphrase of an encrypted plugged-in CA private key, or nil
+// if none is configured.
+func readCAKeyPassphrase() ([]byte, error) {
+	if f := caKeyPassphraseFile.Get(); f != "" {
+		passphrase, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA key passphrase: %v", err)
+		}
+		return bytes.TrimRight(passphrase, "\r\n"), nil
+	}
+	if p := caKeyPassphrase.Get(); p != "" {
+		return []byte(p), nil
+	}
+	return nil, nil
+}
+
+// createCABackuper returns the backuper of the self-signed CA configured by CA_BACKUP_STORE_URL, or nil if
+// backups are disabled.
+func (s *Server) createCABackuper(client corev1.CoreV1Interface, namespace string) (*cabackup.Backuper, error) {
+	storeURL := caBackupStoreURL.Get()
+	if storeURL == "" {
+		return nil, nil
+	}
+	if caBackupEncryptionKeyFile.Get() == "" {
+		return nil, fmt.Errorf("CA_BACKUP_STORE_URL requires CA_BACKUP_ENCRYPTION_KEY_FILE")
+	}
+	encryption, err := kms.NewLocalProvider(caBackupEncryptionKeyFile.Get())
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA_BACKUP_ENCRYPTION_KEY_FILE: %v", err)
+	}
+	store, err := cabackup.NewStore(context.TODO(), storeURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA_BACKUP_STORE_URL: %v", err)
+	}
+	config := cabackup.Config{
+		Namespace:  namespace,
+		Client:     client,
+		Store:      store,
+		Encryption: encryption,
+		Retain:     caBackupRetain.Get(),
+	}
+	if caStateResourceEnabled.Get() && s.kubeConfig != nil {
+		dynamicClient, err := dynamic.NewForConfig(s.kubeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create a dynamic client for the CA state: %v", err)
+		}
+		config.CAState = dynamicClient.Resource(castate.GroupVersionResource).Namespace(namespace)
+		config.CAStateName = castate.DefaultName
+	}
+	log.Infof("Back up the CA to %s every %v", storeURL, caBackupInterval.Get())
+	return cabackup.NewBackuper(config)
+}
+
+// restoreCABackup restores istio-ca-secret from the most recent backup if it does not exist.
+func restoreCABackup(ctx context.Context, backuper *cabackup.Backuper, client corev1.CoreV1Interface,
+	namespace string) error {
+	_, err := client.Secrets(namespace).Get(ctx, ca.CASecret, metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		return err
+	}
+	err = backuper.Restore(ctx, "")
+	if err == cabackup.ErrNoBackup {
+		log.Infof("No CA backup to restore %s/%s from", namespace, ca.CASecret)
+		return nil
+	}
+	return err
+}
+
+// createKeyEncryptionProvider returns the KMS provider used to encrypt the CA private key, or nil
+// if the key is stored in plaintext.
+func createKeyEncryptionProvider() (kms.Provider, error) {
+	switch caKeyKMSProvider.Get() {
+	case "":
+		return nil, nil
+	case "local":
+		return kms.NewLocalProvider(caKeyKMSKeyID.Get())
+	case "vault-transit":
+		var token []byte
+		if f := caKeyKMSVaultTokenFile.Get(); f != "" {
+			var err error
+			if token, err = ioutil.ReadFile(f); err != nil {
+				return nil, fmt.Errorf("failed to read Vault token: %v", err)
+			}
+		}
+		return kms.NewVaultTransitProvider(caKeyKMSVaultAddr.Get(), strings.TrimSpace(string(token)),
+			caKeyKMSVaultMount.Get(), caKeyKMSKeyID.Get())
+	case "aws-kms":
+		return kms.NewAWSProvider(caKeyKMSAWSRegion.Get(), caKeyKMSKeyID.Get())
+	default:
+		return nil, fmt.Errorf("unsupported KMS provider %q", caKeyKMSProvider.Get())
+	}
+}
+
+// createKMSSignerCAOptions returns the options of a CA signing with a private key held in a cloud KMS.
+func createKMSSignerCAOptions(client corev1.CoreV1Interface, opts *CAOptions,
+	maxCertTTL time.Duration) (*ca.IstioCAOptions, error) {
+	var signer crypto.Signer
+	var err error
+	switch kmsSignerBackend.Get() {
+	case "aws":
+		signer, err = kmssigner.NewAWSSigner(kmsSignerAWSRegion.Get(), kmsSignerKey.Get())
+	case "gcp":
+		signer, err = kmssigner.NewGCPSigner(context.Background(), kmsSignerKey.Get())
+	case "azure":
+		signer, err = kmssigner.NewAzureSigner(kmsSignerKey.Get())
+	default:
+		return nil, fmt.Errorf("unsupported KMS signer %q", kmsSignerBackend.Get())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ca.NewExternalSignerIstioCAOptions(signer, path.Join(LocalCertDir.Get(), "cert-chain.pem"),
+		path.Join(LocalCertDir.Get(), "ca-cert.pem"), path.Join(LocalCertDir.Get(), "root-cert.pem"),
+		workloadCertTTL.Get(), maxCertTTL, opts.Namespace, client)
+}
+
+// setCertExtensions sets the policy identifiers, extra extensions and CDP and AIA URLs of issued
+// certs from CA_CERT_POLICY_IDENTIFIERS, CA_CERT_EXTRA_EXTENSIONS, CA_CERT_CRL_DISTRIBUTION_POINTS,
+// CA_CERT_OCSP_SERVERS and CA_CERT_ISSUING_CERTIFICATE_URLS.
+func setCertExtensions(caOpts *ca.IstioCAOptions) error {
+	caOpts.CertCRLDistributionPoints = splitNames(caCertCRLDistributionPoints.Get())
+	caOpts.CertOCSPServers = splitNames(caCertOCSPServers.Get())
+	caOpts.CertIssuingCertificateURLs = splitNames(caCertIssuingCertificateURLs.Get())
+	for _, s := range strings.Split(caCertPolicyIdentifiers.Get(), ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		oid, err := pkiutil.ParseObjectIdentifier(s)
+		if err != nil {
+			return fmt.Errorf("invalid CA_CERT_POLICY_IDENTIFIERS: %v", err)
+		}
+		caOpts.CertPolicyIdentifiers = append(caOpts.CertPolicyIdentifiers, oid)
+	}
+	for _, s := range strings.Split(caCertExtraExtensions.Get(), ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		ext, err := pkiutil.ParseExtraExtension(s)
+		if err != nil {
+			return fmt.Errorf("invalid CA_CERT_EXTRA_EXTENSIONS: %v", err)
+		}
+		caOpts.CertExtraExtensions = append(caOpts.CertExtraExtensions, ext)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+// +build !pkcs11
+
+package bootstrap
+
+import (
+	"errors"
+	"time"
+
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/security/pkg/pki/ca"
+)
+
+// pkcs11Configured returns false, since the binaries built without the pkcs11 build tag cannot load
+// a PKCS#11 module.
+func pkcs11Configured() bool {
+	return false
+}
+
+func createPKCS11CAOptions(corev1.CoreV1Interface, *CAOptions, time.Duration) (*ca.IstioCAOptions, error) {
+	return nil, errors.New("PKCS#11 support requires pilot-discovery to be built with the pkcs11 build tag")
+}
@@ -0,0 +1,90 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+// +build pkcs11
+
+package bootstrap
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/pkg/env"
+	"istio.io/pkg/log"
+
+	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/pkcs11"
+	pkiutil "istio.io/istio/security/pkg/pki/util"
+)
+
+// The PKCS#11 env vars are only registered in the binaries built with the pkcs11 build tag, see
+// the pilot-discovery-pkcs11 make target.
+var (
+	pkcs11ModulePath = env.RegisterStringVar("PKCS11_MODULE_PATH", "",
+		"Path of the PKCS#11 module of an HSM holding the CA private key. If set, the CA signs with "+
+			"the key in the HSM, and ca-cert.pem, cert-chain.pem and root-cert.pem are read from ROOT_CA_DIR.")
+
+	pkcs11TokenLabel = env.RegisterStringVar("PKCS11_TOKEN_LABEL", "",
+		"Label of the PKCS#11 token holding the CA private key.")
+
+	pkcs11KeyLabel = env.RegisterStringVar("PKCS11_KEY_LABEL", "",
+		"Label of the CA private key object in the PKCS#11 token.")
+
+	pkcs11PINFile = env.RegisterStringVar("PKCS11_PIN_FILE", "",
+		"Path of the file holding the user PIN of the PKCS#11 token.")
+)
+
+// pkcs11Configured returns whether the CA private key is held in an HSM.
+func pkcs11Configured() bool {
+	return pkcs11ModulePath.Get() != ""
+}
+
+// createPKCS11CAOptions returns the options of a CA signing with a private key held in an HSM.
+func createPKCS11CAOptions(client corev1.CoreV1Interface, opts *CAOptions,
+	maxCertTTL time.Duration) (*ca.IstioCAOptions, error) {
+	log.Infof("Use CA private key in the HSM via PKCS#11 module %s", pkcs11ModulePath.Get())
+	signingCertFile := path.Join(LocalCertDir.Get(), "ca-cert.pem")
+	certBytes, err := ioutil.ReadFile(signingCertFile)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := pkiutil.ParsePemEncodedCertificate(certBytes)
+	if err != nil {
+		return nil, err
+	}
+	var pin []byte
+	if f := pkcs11PINFile.Get(); f != "" {
+		if pin, err = ioutil.ReadFile(f); err != nil {
+			return nil, fmt.Errorf("failed to read PKCS#11 PIN: %v", err)
+		}
+	}
+	signer, err := pkcs11.NewSigner(pkcs11.Config{
+		ModulePath: pkcs11ModulePath.Get(),
+		TokenLabel: pkcs11TokenLabel.Get(),
+		PIN:        strings.TrimSpace(string(pin)),
+		KeyLabel:   pkcs11KeyLabel.Get(),
+	}, cert.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return ca.NewExternalSignerIstioCAOptions(signer, path.Join(LocalCertDir.Get(), "cert-chain.pem"),
+		signingCertFile, path.Join(LocalCertDir.Get(), "root-cert.pem"), workloadCertTTL.Get(), maxCertTTL,
+		opts.Namespace, client)
+}
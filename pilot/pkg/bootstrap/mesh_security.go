@@ -0,0 +1,130 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"strings"
+
+	"istio.io/pkg/log"
+
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/security/pkg/k8s/caconfig"
+	"istio.io/istio/security/pkg/pki/ca"
+	caserver "istio.io/istio/security/pkg/server/ca"
+)
+
+// initMeshSecurity applies the security section of the mesh config to the CA, and applies it again
+// whenever the mesh config changes, so that the CA settings are managed with the rest of the mesh
+// config instead of only with the istiod flags.
+func (s *Server) initMeshSecurity() {
+	if s.ca == nil {
+		return
+	}
+	s.caDefaultSettings = s.ca.Settings()
+	s.applyCASettings()
+	s.environment.AddMeshHandler(s.applyCASettings)
+}
+
+// applyCASettings applies the settings of the mesh config and of the IstioCAConfig resource to the CA.
+func (s *Server) applyCASettings() {
+	s.caSettingsMutex.Lock()
+	defer s.caSettingsMutex.Unlock()
+	if err := s.ca.UpdateSettings(s.caSettings(s.caConfigSpec)); err != nil {
+		log.Errorf("Failed to apply the security settings of the mesh config to the CA: %v", err)
+	}
+}
+
+// applyCAConfigSpec applies the spec of the IstioCAConfig resource to the CA.
+func (s *Server) applyCAConfigSpec(spec *caconfig.Spec) error {
+	s.caSettingsMutex.Lock()
+	defer s.caSettingsMutex.Unlock()
+	if err := s.ca.UpdateSettings(s.caSettings(spec)); err != nil {
+		return err
+	}
+	s.caConfigSpec = spec
+	return nil
+}
+
+// caSettings returns the settings of the CA: the values of the istiod flags, overridden by the
+// security section of the mesh config, overridden by spec of the IstioCAConfig resource if not nil.
+func (s *Server) caSettings(spec *caconfig.Spec) ca.Settings {
+	settings := s.caDefaultSettings
+	security := s.environment.SecurityConfig()
+	if security.WorkloadCertTTL != nil {
+		settings.DefaultCertTTL = security.WorkloadCertTTL.Duration
+	}
+	if security.MaxWorkloadCertTTL != nil {
+		settings.MaxCertTTL = security.MaxWorkloadCertTTL.Duration
+	}
+	if security.DualUse != nil {
+		settings.DualUse = *security.DualUse
+	}
+	if security.PKCS8Key != nil {
+		settings.PKCS8Key = *security.PKCS8Key
+	}
+	if spec == nil {
+		return settings
+	}
+	if spec.WorkloadCertTTL != nil {
+		settings.DefaultCertTTL = spec.WorkloadCertTTL.Duration
+	}
+	if spec.MaxWorkloadCertTTL != nil {
+		settings.MaxCertTTL = spec.MaxWorkloadCertTTL.Duration
+	}
+	if spec.MinWorkloadCertTTL != nil {
+		settings.MinCertTTL = spec.MinWorkloadCertTTL.Duration
+	}
+	if spec.MinRSAKeySize > 0 {
+		validation := *settings.CSRValidation
+		validation.MinRSAKeySize = spec.MinRSAKeySize
+		settings.CSRValidation = &validation
+	}
+	return settings
+}
+
+// watchMeshTrustDomain applies the changes of the trust domain and its aliases in the mesh config to
+// the identities of the callers of the CA.
+func (s *Server) watchMeshTrustDomain(caServer *caserver.Server) {
+	s.environment.AddMeshHandler(func() {
+		meshConfig := s.environment.Mesh()
+		spiffe.SetTrustDomainAliases(meshConfig.TrustDomainAliases)
+		trustDomain := strings.Replace(meshConfig.TrustDomain, "@", ".", -1)
+		previous := spiffe.GetTrustDomain()
+		if trustDomain == "" || trustDomain == previous {
+			return
+		}
+		if err := spiffe.ValidateTrustDomain(trustDomain); err != nil {
+			log.Errorf("Ignoring the invalid trust domain %q of the mesh config: %v", meshConfig.TrustDomain, err)
+			return
+		}
+		spiffe.SetTrustDomain(trustDomain)
+		caServer.SetTrustDomain(trustDomain)
+		log.Infof("Trust domain of the CA changed from %s to %s", previous, trustDomain)
+		for _, alias := range meshConfig.TrustDomainAliases {
+			if strings.Replace(alias, "@", ".", -1) == previous {
+				return
+			}
+		}
+		log.Warnf("The previous trust domain %s is not in the trust domain aliases of the mesh config, "+
+			"the identities of the existing workload certs are not accepted anymore", previous)
+	})
+}
+
+// explicitOptIn returns whether the security section of the mesh config requires the namespaces to
+// opt in to the root cert ConfigMap.
+func explicitOptIn(security *mesh.SecurityConfig) bool {
+	return security.ExplicitOptIn != nil && *security.ExplicitOptIn
+}
@@ -15,6 +15,7 @@
 package bootstrap
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
@@ -23,6 +24,7 @@ import (
 	"net"
 	"net/http"
 	"path"
+	"strings"
 	"sync"
 	"time"
 
@@ -70,8 +72,12 @@ import (
 	kubelib "istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/inject"
 	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/security/pkg/k8s/caconfig"
 	"istio.io/istio/security/pkg/k8s/chiron"
+	"istio.io/istio/security/pkg/k8s/trustanchor"
+	"istio.io/istio/security/pkg/k8s/trustbundle"
 	"istio.io/istio/security/pkg/pki/ca"
+	caserver "istio.io/istio/security/pkg/server/ca"
 )
 
 var (
@@ -106,6 +112,9 @@ func init() {
 // startFunc defines a function that will be used to start one or more components of the Pilot discovery service.
 type startFunc func(stop <-chan struct{}) error
 
+// drainFunc waits up to timeout for the work in progress of a component to complete.
+type drainFunc func(timeout time.Duration)
+
 // readinessProbe defines a function that will be used indicate whether a server is ready.
 type readinessProbe func() (bool, error)
 
@@ -151,6 +160,25 @@ type Server struct {
 
 	certController *chiron.WebhookController
 	ca             *ca.IstioCA
+	// webhookCerts runs the controller of the serving certs of the webhook services.
+	webhookCerts *webhookCertRunner
+	// externalCA signs workload certs instead of ca, if configured.
+	externalCA caserver.CertificateAuthority
+	// tenantCA signs the workload certs of the tenants with their own CAs, if configured.
+	tenantCA *caserver.TenantCA
+	// caSigningPolicy restricts the workload certs issued in each namespace, if configured.
+	caSigningPolicy *caserver.SigningPolicy
+	// caSettingsMutex guards the settings of ca, which are the values of the flags in caDefaultSettings
+	// overridden by the mesh config and by caConfigSpec, the spec of the IstioCAConfig resource.
+	caSettingsMutex   sync.Mutex
+	caDefaultSettings ca.Settings
+	caConfigSpec      *caconfig.Spec
+	// trustAnchorPeers holds the root certs replicated by the remote clusters, if enabled.
+	trustAnchorPeers *trustanchor.Peers
+	// federatedBundles holds the root certs of the federated trust domains, if configured.
+	federatedBundles *spiffe.BundleFetcher
+	// trustBundles holds the root certs of the TrustBundle resources, if enabled.
+	trustBundles *trustbundle.Controller
 	// path to the caBundle that signs the DNS certs. This should be agnostic to provider.
 	caBundlePath string
 	certMu       sync.Mutex
@@ -168,6 +196,11 @@ type Server struct {
 
 	// duration used for graceful shutdown.
 	shutdownDuration time.Duration
+
+	// drainFuncs wait for the work in progress of components when istiod stops, e.g. the updates of
+	// secrets, before drained is closed.
+	drainFuncs []drainFunc
+	drained    chan struct{}
 }
 
 // NewServer creates a new Server instance based on the provided arguments.
@@ -185,10 +218,13 @@ func NewServer(args *PilotArgs) (*Server, error) {
 		fileWatcher:     filewatcher.NewWatcher(),
 		httpMux:         http.NewServeMux(),
 		readinessProbes: make(map[string]readinessProbe),
+		drained:         make(chan struct{}),
 	}
 
 	if args.ShutdownDuration == 0 {
 		s.shutdownDuration = 10 * time.Second // If not specified set to 10 seconds.
+	} else {
+		s.shutdownDuration = args.ShutdownDuration
 	}
 
 	if args.RegistryOptions.KubeOptions.WatchedNamespaces != "" {
@@ -275,6 +311,32 @@ func NewServer(args *PilotArgs) (*Server, error) {
 		args.RegistryOptions.KubeOptions.FetchCaRoot = s.fetchCARoot
 	}
 
+	if s.ca != nil {
+		s.initTrustAnchorReplication(args)
+		s.initRootRotation(args)
+		s.initCSRSigner()
+		s.initNotifications()
+		if err := s.initIssuanceWebhook(); err != nil {
+			return nil, err
+		}
+		if err := s.initFederatedBundles(); err != nil {
+			return nil, err
+		}
+		if err := s.initTrustBundles(); err != nil {
+			return nil, err
+		}
+		s.initSecretProtection(args)
+		s.initCertMount(args)
+	}
+	s.initMeshSecurity()
+	if err := s.initCAConfig(); err != nil {
+		return nil, fmt.Errorf("error initializing CA config: %v", err)
+	}
+	if err := s.initAuditExport(); err != nil {
+		return nil, fmt.Errorf("error initializing audit export: %v", err)
+	}
+	s.initControllerStatus(args)
+
 	if err := s.initClusterRegistries(args); err != nil {
 		return nil, fmt.Errorf("error initializing cluster registries: %v", err)
 	}
@@ -384,15 +446,13 @@ func (s *Server) initKubeClient(args *PilotArgs) error {
 		if err != nil {
 			return fmt.Errorf("failed creating kube config: %v", err)
 		}
-		s.kubeClient, err = kubelib.CreateClientset(args.RegistryOptions.KubeConfig, "", func(config *rest.Config) {
-			config.QPS = 20
-			config.Burst = 40
-		})
+		configureKubeClient(s.kubeConfig)
+		s.kubeClient, err = kubelib.CreateClientset(args.RegistryOptions.KubeConfig, "", configureKubeClient)
 		if err != nil {
 			return fmt.Errorf("failed creating kube client: %v", err)
 		}
 
-		s.metadataClient, err = kubelib.CreateMetadataClient(args.RegistryOptions.KubeConfig, "")
+		s.metadataClient, err = kubelib.CreateMetadataClient(args.RegistryOptions.KubeConfig, "", configureKubeClient)
 		if err != nil {
 			return fmt.Errorf("failed creating kube metadata client: %v", err)
 		}
@@ -465,8 +525,23 @@ func (s *Server) initDiscoveryService(args *PilotArgs) error {
 	return nil
 }
 
+// configureKubeClient applies the rate limits and the request timeout of the Kubernetes clients of istiod.
+func configureKubeClient(config *rest.Config) {
+	config.QPS = float32(features.KubeClientQPS)
+	config.Burst = features.KubeClientBurst
+	if features.KubeClientTimeout > 0 {
+		kubelib.SetRequestTimeout(config, features.KubeClientTimeout)
+	}
+}
+
 // Wait for the stop, and do cleanups
 func (s *Server) waitForShutdown(stop <-chan struct{}) {
+	s.requiredTerminations.Add(1)
+	go func() {
+		defer s.requiredTerminations.Done()
+		<-stop
+		s.drain()
+	}()
 	go func() {
 		<-stop
 		s.fileWatcher.Close()
@@ -652,6 +727,27 @@ func (s *Server) addStartFunc(fn startFunc) {
 	s.startFuncs = append(s.startFuncs, fn)
 }
 
+// addDrainFunc registers a function waiting for the work in progress of a component when istiod stops,
+// so that it is not interrupted in the middle, e.g. of the update of a secret.
+func (s *Server) addDrainFunc(fn drainFunc) {
+	s.drainFuncs = append(s.drainFuncs, fn)
+}
+
+// drain runs the drain functions concurrently, each for up to the shutdown duration, and then closes
+// drained. Components recording the work, such as the audit sinks, are stopped by drained.
+func (s *Server) drain() {
+	var wg sync.WaitGroup
+	for _, fn := range s.drainFuncs {
+		wg.Add(1)
+		go func(fn drainFunc) {
+			defer wg.Done()
+			fn(s.shutdownDuration)
+		}(fn)
+	}
+	wg.Wait()
+	close(s.drained)
+}
+
 // adds a readiness probe for Istiod Server.
 func (s *Server) addReadinessProbe(name string, fn readinessProbe) {
 	s.readinessProbes[name] = fn
@@ -962,12 +1058,26 @@ func (s *Server) initControllers(args *PilotArgs) error {
 // initNamespaceController initializes namespace controller to sync config map.
 func (s *Server) initNamespaceController(args *PilotArgs) {
 	if s.ca != nil && s.kubeClient != nil {
+		// The namespace controller of the current leadership follows the explicit opt-in of the mesh config.
+		var ncMutex sync.Mutex
+		var current *kubecontroller.NamespaceController
+		s.environment.AddMeshHandler(func() {
+			ncMutex.Lock()
+			defer ncMutex.Unlock()
+			if current != nil {
+				current.SetExplicitOptIn(explicitOptIn(s.environment.SecurityConfig()))
+			}
+		})
 		s.addTerminatingStartFunc(func(stop <-chan struct{}) error {
 			leaderelection.
 				NewLeaderElection(args.Namespace, args.PodName, leaderelection.NamespaceController, s.kubeClient).
 				AddRunFunction(func(stop <-chan struct{}) {
 					log.Infof("Starting namespace controller")
 					nc := kubecontroller.NewNamespaceController(s.fetchCARoot, args.RegistryOptions.KubeOptions, s.kubeClient)
+					ncMutex.Lock()
+					current = nc
+					nc.SetExplicitOptIn(explicitOptIn(s.environment.SecurityConfig()))
+					ncMutex.Unlock()
 					nc.Run(stop)
 				}).
 				Run(stop)
@@ -1016,10 +1126,34 @@ func (s *Server) maybeCreateCA(caOpts *CAOptions) error {
 		if s.kubeClient != nil {
 			corev1 = s.kubeClient.CoreV1()
 		}
+		if caOpts.TrustDomain != "" {
+			if err = spiffe.ValidateTrustDomain(strings.Replace(caOpts.TrustDomain, "@", ".", -1)); err != nil {
+				return fmt.Errorf("invalid trust domain: %v", err)
+			}
+		}
+		spiffe.SetTrustDomainAliases(s.environment.Mesh().TrustDomainAliases)
+		if err = s.runCAPreflightChecks(caOpts.Namespace); err != nil {
+			return fmt.Errorf("CA preflight checks failed: %v", err)
+		}
 		// May return nil, if the CA is missing required configs - This is not an error.
 		if s.ca, err = s.createIstioCA(corev1, caOpts); err != nil {
 			return fmt.Errorf("failed to create CA: %v", err)
 		}
+		if s.externalCA, err = s.createExternalCA(caOpts); err != nil {
+			return fmt.Errorf("failed to create external CA: %v", err)
+		}
+		if s.ca != nil {
+			workloadCA := caserver.CertificateAuthority(s.ca)
+			if s.externalCA != nil {
+				workloadCA = s.externalCA
+			}
+			if s.tenantCA, err = s.createTenantCA(workloadCA); err != nil {
+				return fmt.Errorf("failed to create tenant CAs: %v", err)
+			}
+		}
+		if s.caSigningPolicy, err = s.createSigningPolicy(); err != nil {
+			return fmt.Errorf("invalid CA_SIGNING_POLICY_FILE: %v", err)
+		}
 		if err = s.initPublicKey(); err != nil {
 			return fmt.Errorf("error initializing public key: %v", err)
 		}
@@ -1030,17 +1164,57 @@ func (s *Server) maybeCreateCA(caOpts *CAOptions) error {
 // startCA starts the CA server if configured.
 func (s *Server) startCA(caOpts *CAOptions) {
 	if s.ca != nil {
+		s.initCABundleReconciler()
 		s.addStartFunc(func(stop <-chan struct{}) error {
 			log.Infof("staring CA")
-			s.RunCA(s.secureGrpcServer, s.ca, caOpts)
+			if s.tenantCA != nil {
+				s.RunCA(s.secureGrpcServer, s.tenantCA, caOpts)
+			} else if s.externalCA != nil {
+				s.RunCA(s.secureGrpcServer, s.externalCA, caOpts)
+			} else {
+				s.RunCA(s.secureGrpcServer, s.ca, caOpts)
+			}
 			return nil
 		})
 	}
 }
 
 func (s *Server) fetchCARoot() map[string]string {
+	rootCerts := s.ca.GetCAKeyCertBundle().GetRootCertPem()
+	if s.externalCA != nil {
+		// Workloads must trust the roots of both the Istio CA signing the istiod certs and the
+		// external CA signing the workload certs.
+		externalRootCerts := s.externalCA.GetCAKeyCertBundle().GetRootCertPem()
+		if !bytes.Equal(rootCerts, externalRootCerts) {
+			rootCerts = append(append(append([]byte{}, rootCerts...), '\n'), externalRootCerts...)
+		}
+	}
+	if s.tenantCA != nil {
+		// Workloads must trust the roots of the CAs of all the tenants.
+		if tenantRootCerts := s.tenantCA.RootCerts(); len(tenantRootCerts) > 0 {
+			rootCerts = append(append(append([]byte{}, rootCerts...), '\n'), tenantRootCerts...)
+		}
+	}
+	if s.trustAnchorPeers != nil {
+		// Workloads must also trust the roots of the remote clusters with their own CAs.
+		if peerRootCerts := s.trustAnchorPeers.RootCerts(); len(peerRootCerts) > 0 {
+			rootCerts = append(append(append([]byte{}, rootCerts...), '\n'), peerRootCerts...)
+		}
+	}
+	if s.federatedBundles != nil {
+		// Workloads accept the peers of the federated trust domains.
+		if federatedRootCerts := s.federatedBundles.RootCerts(); len(federatedRootCerts) > 0 {
+			rootCerts = append(append(append([]byte{}, rootCerts...), '\n'), federatedRootCerts...)
+		}
+	}
+	if s.trustBundles != nil {
+		// Workloads trust the additional anchors supplied by the operators.
+		if bundleRootCerts := s.trustBundles.RootCerts(); len(bundleRootCerts) > 0 {
+			rootCerts = append(append(append([]byte{}, rootCerts...), '\n'), bundleRootCerts...)
+		}
+	}
 	return map[string]string{
-		constants.CACertNamespaceConfigMapDataName: string(s.ca.GetCAKeyCertBundle().GetRootCertPem()),
+		constants.CACertNamespaceConfigMapDataName: string(rootCerts),
 	}
 }
 
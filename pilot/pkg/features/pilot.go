@@ -258,6 +258,27 @@ var (
 			"See https://godoc.org/k8s.io/client-go/rest#Config Burst",
 	).Get()
 
+	KubeClientQPS = env.RegisterFloatVar(
+		"PILOT_KUBE_CLIENT_QPS",
+		20,
+		"The QPS of the Kubernetes clients of istiod, used by the registry, the CA and the certificate "+
+			"controllers. See https://godoc.org/k8s.io/client-go/rest#Config QPS",
+	).Get()
+
+	KubeClientBurst = env.RegisterIntVar(
+		"PILOT_KUBE_CLIENT_BURST",
+		40,
+		"The Burst rate of the Kubernetes clients of istiod. "+
+			"See https://godoc.org/k8s.io/client-go/rest#Config Burst",
+	).Get()
+
+	KubeClientTimeout = env.RegisterDurationVar(
+		"PILOT_KUBE_CLIENT_TIMEOUT",
+		0,
+		"The timeout of the requests of the Kubernetes clients of istiod, e.g. to create or update a secret. "+
+			"Watches are not affected. 0 disables the timeout.",
+	).Get()
+
 	// IstiodServiceCustomHost allow user to bring a custom address for istiod server
 	// for examples: istiod.mycompany.com
 	IstiodServiceCustomHost = env.RegisterStringVar("ISTIOD_CUSTOM_HOST", "",
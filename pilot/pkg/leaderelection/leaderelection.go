@@ -36,6 +36,10 @@ const (
 	IngressController = "istio-leader"
 	StatusController  = "istio-status-leader"
 	AnalyzeController = "istio-analyze-leader"
+	// CAControllerStatus elects the istiod writing the status of the certificate controllers.
+	CAControllerStatus = "istio-ca-controller-status-leader"
+	// CARootRotation elects the istiod running the two-phase root rotation of the CA.
+	CARootRotation = "istio-ca-root-rotation-leader"
 )
 
 type LeaderElection struct {
@@ -40,6 +40,7 @@ import (
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/security/pkg/debugserver"
 )
 
 var indexTmpl = template.Must(template.New("index").Parse(`<html>
@@ -110,6 +111,8 @@ func (s *DiscoveryServer) InitDebug(mux *http.ServeMux, sctl *aggregate.Controll
 
 	mux.HandleFunc("/debug", s.Debug)
 
+	s.addDebugHandler(mux, debugserver.RuntimePath, "Go runtime stats of the current program", debugserver.RuntimeHandler)
+
 	s.addDebugHandler(mux, "/debug/edsz", "Status and debug interface for EDS", s.edsz)
 	s.addDebugHandler(mux, "/debug/adsz", "Status and debug interface for ADS", s.adsz)
 	s.addDebugHandler(mux, "/debug/adsz?push=true", "Initiates push of the current state to all connected endpoints", s.adsz)
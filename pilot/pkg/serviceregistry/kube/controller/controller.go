@@ -126,6 +126,18 @@ type Options struct {
 
 	// CABundlePath defines the caBundle path for istiod Server
 	CABundlePath string
+
+	// ClusterHandlers are notified when remote clusters are added or deleted.
+	ClusterHandlers []ClusterHandler
+
+	// NamespaceControllerWorkers is the number of workers reconciling the CA root cert ConfigMaps of
+	// the namespaces concurrently. Defaults to 1.
+	NamespaceControllerWorkers int
+
+	// NamespaceSelector is a label selector of the namespaces in which the CA root cert ConfigMap is
+	// managed, in addition to WatchedNamespaces. The namespaces are added and removed at runtime as
+	// they are created, labeled and deleted.
+	NamespaceSelector string
 }
 
 // EndpointMode decides what source to use to get endpoint information
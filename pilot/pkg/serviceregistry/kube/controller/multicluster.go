@@ -46,6 +46,12 @@ var (
 	validationWebhookConfigNameTemplate = "istiod-" + validationWebhookConfigNameTemplateVar
 )
 
+// ClusterHandler is notified when remote clusters are added or deleted.
+type ClusterHandler interface {
+	ClusterAdded(clusterID string, client kubernetes.Interface)
+	ClusterDeleted(clusterID string)
+}
+
 type kubeController struct {
 	*Controller
 	stopCh chan struct{}
@@ -68,6 +74,8 @@ type Multicluster struct {
 	fetchCaRoot     func() map[string]string
 	caBundlePath    string
 	secretNamespace string
+
+	clusterHandlers []ClusterHandler
 }
 
 // NewMulticluster initializes data structure to store multicluster information
@@ -93,6 +101,7 @@ func NewMulticluster(kc kubernetes.Interface, secretNamespace string, opts Optio
 		fetchCaRoot:           opts.FetchCaRoot,
 		caBundlePath:          opts.CABundlePath,
 		secretNamespace:       secretNamespace,
+		clusterHandlers:       opts.ClusterHandlers,
 	}
 
 	_ = secretcontroller.StartSecretController(
@@ -149,6 +158,9 @@ func (m *Multicluster) AddMemberCluster(clientset kubernetes.Interface, metadata
 			go valicationWebhookController.Start(stopCh)
 		}
 	}
+	for _, h := range m.clusterHandlers {
+		h.ClusterAdded(clusterID, clientset)
+	}
 	return nil
 }
 
@@ -174,6 +186,9 @@ func (m *Multicluster) DeleteMemberCluster(clusterID string) error {
 	}
 	close(m.remoteKubeControllers[clusterID].stopCh)
 	delete(m.remoteKubeControllers, clusterID)
+	for _, h := range m.clusterHandlers {
+		h.ClusterDeleted(clusterID)
+	}
 	if m.XDSUpdater != nil {
 		m.XDSUpdater.ConfigUpdate(&model.PushRequest{Full: true})
 	}
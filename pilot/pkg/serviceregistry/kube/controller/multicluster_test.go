@@ -18,6 +18,7 @@ package controller
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -106,6 +107,30 @@ func mockCreateDynamicInterfaceFromClusterConfig(_ *clientcmdapi.Config) (dynami
 	return dynamicfake.NewSimpleDynamicClient(scheme), nil
 }
 
+// clusterRecorder records the remote clusters it is notified of.
+type clusterRecorder struct {
+	mutex    sync.Mutex
+	clusters map[string]bool
+}
+
+func (r *clusterRecorder) ClusterAdded(clusterID string, _ kubernetes.Interface) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.clusters[clusterID] = true
+}
+
+func (r *clusterRecorder) ClusterDeleted(clusterID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.clusters, clusterID)
+}
+
+func (r *clusterRecorder) count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.clusters)
+}
+
 // This test is skipped by the build tag !race due to https://github.com/istio/istio/issues/15610
 func Test_KubeSecretController(t *testing.T) {
 	secretcontroller.LoadKubeConfig = mockLoadKubeConfig
@@ -115,12 +140,14 @@ func Test_KubeSecretController(t *testing.T) {
 	secretcontroller.CreateDynamicInterfaceFromClusterConfig = mockCreateDynamicInterfaceFromClusterConfig
 
 	clientset := fake.NewSimpleClientset()
+	recorder := &clusterRecorder{clusters: map[string]bool{}}
 	mc, err := NewMulticluster(clientset,
 		testSecretNameSpace,
 		Options{
 			WatchedNamespaces: WatchedNamespaces,
 			DomainSuffix:      DomainSuffix,
 			ResyncPeriod:      ResyncPeriod,
+			ClusterHandlers:   []ClusterHandler{recorder},
 		},
 		mockserviceController, nil, nil)
 
@@ -138,6 +165,9 @@ func Test_KubeSecretController(t *testing.T) {
 
 	// Test - Verify that the remote controller has been added.
 	verifyControllers(t, mc, 1, "create remote controller")
+	if recorder.count() != 1 {
+		t.Errorf("expected the cluster handler to be notified of the remote cluster")
+	}
 
 	// Delete the mulicluster secret.
 	err = deleteMultiClusterSecret(clientset)
@@ -147,5 +177,8 @@ func Test_KubeSecretController(t *testing.T) {
 
 	// Test - Verify that the remote controller has been removed.
 	verifyControllers(t, mc, 0, "delete remote controller")
+	if recorder.count() != 0 {
+		t.Errorf("expected the cluster handler to be notified of the deleted remote cluster")
+	}
 
 }
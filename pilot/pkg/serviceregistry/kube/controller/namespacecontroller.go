@@ -18,11 +18,13 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	informer "k8s.io/client-go/informers/core/v1"
@@ -44,6 +46,8 @@ const (
 	NamespaceResyncPeriod = time.Second * 60
 	// The name of the ConfigMap in each namespace storing the root cert of non-Kube CA.
 	CACertNamespaceConfigMap = "istio-ca-root-cert"
+	// CAOptInLabel opts a namespace in to the root cert ConfigMap when explicit opt-in is required.
+	CAOptInLabel = "ca.istio.io/override"
 )
 
 var (
@@ -57,11 +61,23 @@ type NamespaceController struct {
 	client  corev1.CoreV1Interface
 
 	queue queue.Instance
+	// The number of workers processing the queue
+	workers int
 
 	// Controller and store for namespace objects
 	namespaceController cache.Controller
 	// Controller and store for ConfigMap objects
 	configMapController cache.Controller
+
+	// The cached namespaces, so that the phase of a namespace is known without a request to the API server
+	namespaceStore cache.Store
+
+	// selected returns whether the ConfigMap is managed in the namespace
+	selected func(*v1.Namespace) bool
+
+	// explicitOptIn is non-zero if the ConfigMap is only created in the namespaces labeled with
+	// CAOptInLabel=true. It can be changed at runtime.
+	explicitOptIn int32
 }
 
 // NewNamespaceController returns a pointer to a newly constructed NamespaceController instance.
@@ -70,11 +86,15 @@ func NewNamespaceController(data func() map[string]string, options Options, kube
 		getData: data,
 		client:  kubeClient.CoreV1(),
 		queue:   queue.NewQueue(time.Second),
+		workers: options.NamespaceControllerWorkers,
+	}
+	if c.workers < 1 {
+		c.workers = 1
 	}
 
 	watchedNamespaceList := strings.Split(options.WatchedNamespaces, ",")
 
-	mlw := listwatch.MultiNamespaceListerWatcher(watchedNamespaceList, func(namespace string) cache.ListerWatcher {
+	configMapListerWatcher := func(namespace string) cache.ListerWatcher {
 		return &cache.ListWatch{
 			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
 				opts.LabelSelector = fields.SelectorFromSet(configMapLabel).String()
@@ -85,7 +105,19 @@ func NewNamespaceController(data func() map[string]string, options Options, kube
 				return kubeClient.CoreV1().ConfigMaps(namespace).Watch(context.TODO(), opts)
 			},
 		}
-	})
+	}
+	// Unless all namespaces are managed, the ConfigMaps are watched in the selected namespaces only,
+	// which are added and removed as the namespaces change.
+	var mlw cache.ListerWatcher
+	var dynamicListerWatcher *listwatch.DynamicListerWatcher
+	c.selected = namespaceSelection(watchedNamespaceList, options.NamespaceSelector)
+	if c.selected == nil {
+		c.selected = func(*v1.Namespace) bool { return true }
+		mlw = listwatch.MultiNamespaceListerWatcher(watchedNamespaceList, configMapListerWatcher)
+	} else {
+		dynamicListerWatcher = listwatch.NewDynamicListerWatcher(nil, configMapListerWatcher)
+		mlw = dynamicListerWatcher
+	}
 
 	configmapInformer := cache.NewSharedIndexInformer(mlw, &v1.ConfigMap{}, options.ResyncPeriod,
 		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
@@ -111,13 +143,9 @@ func NewNamespaceController(data func() map[string]string, options Options, kube
 				}
 			}
 			c.queue.Push(func() error {
-				ns, err := kubeClient.CoreV1().Namespaces().Get(context.TODO(), cm.Namespace, metav1.GetOptions{})
-				if err != nil {
-					return err
-				}
 				// If the namespace is terminating, we may get into a loop of trying to re-add the configmap back
 				// We should make sure the namespace still exists
-				if ns.Status.Phase != v1.NamespaceTerminating {
+				if c.namespaceActive(cm.Namespace) && c.namespaceOptedIn(cm.Namespace) {
 					return c.insertDataForNamespace(cm.Namespace)
 				}
 				return nil
@@ -139,7 +167,11 @@ func NewNamespaceController(data func() map[string]string, options Options, kube
 			})
 		},
 	})
+	if dynamicListerWatcher != nil {
+		namespaceInformer.AddEventHandler(dynamicListerWatcher.NamespaceHandler(c.selected))
+	}
 	c.namespaceController = namespaceInformer
+	c.namespaceStore = namespaceInformer.GetStore()
 
 	return c
 }
@@ -147,10 +179,15 @@ func NewNamespaceController(data func() map[string]string, options Options, kube
 // Run starts the NamespaceController until a value is sent to stopCh.
 func (nc *NamespaceController) Run(stopCh <-chan struct{}) {
 	go nc.namespaceController.Run(stopCh)
+	// The ConfigMaps of the selected namespaces are listed once the namespaces are synced, rather than
+	// as each namespace is added.
+	cache.WaitForCacheSync(stopCh, nc.namespaceController.HasSynced)
 	go nc.configMapController.Run(stopCh)
-	cache.WaitForCacheSync(stopCh, nc.namespaceController.HasSynced, nc.configMapController.HasSynced)
-	log.Infof("Namespace controller started")
-	go nc.queue.Run(stopCh)
+	cache.WaitForCacheSync(stopCh, nc.configMapController.HasSynced)
+	log.Infof("Namespace controller started with %d workers", nc.workers)
+	for i := 0; i < nc.workers; i++ {
+		go nc.queue.Run(stopCh)
+	}
 }
 
 // insertDataForNamespace will add data into the configmap for the specified namespace
@@ -170,12 +207,81 @@ func (nc *NamespaceController) insertDataForNamespace(ns string) error {
 func (nc *NamespaceController) namespaceChange(obj interface{}) error {
 	ns, ok := obj.(*v1.Namespace)
 
-	if ok && ns.Status.Phase != v1.NamespaceTerminating {
+	if ok && ns.Status.Phase != v1.NamespaceTerminating && nc.selected(ns) && nc.optedIn(ns) {
 		return nc.insertDataForNamespace(ns.Name)
 	}
 	return nil
 }
 
+// SetExplicitOptIn sets whether the ConfigMap is only created in the namespaces labeled with
+// CAOptInLabel=true. When it changes, the cached namespaces are reconciled again. The existing
+// ConfigMaps of the namespaces that are not opted in are kept.
+func (nc *NamespaceController) SetExplicitOptIn(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	if atomic.SwapInt32(&nc.explicitOptIn, value) == value {
+		return
+	}
+	log.Infof("Explicit opt-in of the namespaces to the %s ConfigMap set to %v", CACertNamespaceConfigMap, enabled)
+	for _, obj := range nc.namespaceStore.List() {
+		obj := obj
+		nc.queue.Push(func() error {
+			return nc.namespaceChange(obj)
+		})
+	}
+}
+
+// optedIn returns whether the ConfigMap is managed in the namespace as far as explicit opt-in is concerned.
+func (nc *NamespaceController) optedIn(ns *v1.Namespace) bool {
+	return atomic.LoadInt32(&nc.explicitOptIn) == 0 || ns.Labels[CAOptInLabel] == "true"
+}
+
+// namespaceOptedIn returns whether the cached namespace is opted in.
+func (nc *NamespaceController) namespaceOptedIn(name string) bool {
+	obj, exists, err := nc.namespaceStore.GetByKey(name)
+	if err != nil || !exists {
+		return false
+	}
+	ns, ok := obj.(*v1.Namespace)
+	return ok && nc.optedIn(ns)
+}
+
+// namespaceSelection returns whether the ConfigMap is managed in a namespace, given the watched namespaces
+// and the label selector of the additional namespaces. It returns nil if all namespaces are managed.
+func namespaceSelection(namespaces []string, selector string) func(*v1.Namespace) bool {
+	listed := map[string]bool{}
+	for _, ns := range namespaces {
+		if ns == metav1.NamespaceAll {
+			return nil
+		}
+		listed[ns] = true
+	}
+	var labelSelector labels.Selector
+	if selector != "" {
+		var err error
+		if labelSelector, err = labels.Parse(selector); err != nil {
+			log.Errorf("invalid namespace selector %q, only the listed namespaces are selected: %v", selector, err)
+			labelSelector = nil
+		}
+	}
+	return func(ns *v1.Namespace) bool {
+		return listed[ns.Name] || (labelSelector != nil && labelSelector.Matches(labels.Set(ns.Labels)))
+	}
+}
+
+// namespaceActive returns true if the namespace exists and is not terminating, as cached by the
+// namespace informer.
+func (nc *NamespaceController) namespaceActive(name string) bool {
+	obj, exists, err := nc.namespaceStore.GetByKey(name)
+	if err != nil || !exists {
+		return false
+	}
+	ns, ok := obj.(*v1.Namespace)
+	return ok && ns.Status.Phase != v1.NamespaceTerminating
+}
+
 // When a config map is changed, merge the data into the configmap
 func (nc *NamespaceController) configMapChange(obj interface{}) error {
 	cm, ok := obj.(*v1.ConfigMap)
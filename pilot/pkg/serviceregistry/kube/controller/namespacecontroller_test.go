@@ -24,6 +24,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
 
 	"istio.io/istio/pkg/test/util/retry"
 	"istio.io/istio/security/pkg/util"
@@ -52,6 +53,138 @@ func TestNamespaceController(t *testing.T) {
 	expectConfigMap(t, client, "foo", testdata)
 }
 
+func TestNamespaceControllerTerminatingNamespace(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	testdata := map[string]string{"key": "value"}
+	nc := NewNamespaceController(func() map[string]string {
+		return testdata
+	}, Options{}, client)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	nc.Run(stop)
+
+	createNamespace(t, client, "foo")
+	expectConfigMap(t, client, "foo", testdata)
+
+	ns, err := client.CoreV1().Namespaces().Get(context.TODO(), "foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns.Status.Phase = v1.NamespaceTerminating
+	if _, err := client.CoreV1().Namespaces().UpdateStatus(context.TODO(), ns, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		if nc.namespaceActive("foo") {
+			return fmt.Errorf("namespace foo is not terminating in the cache yet")
+		}
+		return nil
+	}, retry.Timeout(time.Second*2))
+
+	deleteConfigMap(t, client, "foo")
+	time.Sleep(time.Second)
+	if _, err := client.CoreV1().ConfigMaps("foo").Get(context.TODO(), CACertNamespaceConfigMap, metav1.GetOptions{}); err == nil {
+		t.Error("expected the configmap not to be recreated in a terminating namespace")
+	}
+}
+
+func TestNamespaceControllerSelectedNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	testdata := map[string]string{"key": "value"}
+	nc := NewNamespaceController(func() map[string]string {
+		return testdata
+	}, Options{WatchedNamespaces: "foo", NamespaceSelector: "mesh=true"}, client)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	nc.Run(stop)
+
+	// The namespaces are created after the controller started.
+	createNamespace(t, client, "foo")
+	expectConfigMap(t, client, "foo", testdata)
+
+	if _, err := client.CoreV1().Namespaces().Create(context.TODO(), &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "bar", Labels: map[string]string{"mesh": "true"}},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectConfigMap(t, client, "bar", testdata)
+	// The ConfigMaps of the selected namespaces are watched once they are added.
+	retry.UntilSuccessOrFail(t, func() error {
+		if _, exists, _ := nc.configMapController.(cache.SharedIndexInformer).GetStore().GetByKey(
+			"bar/" + CACertNamespaceConfigMap); !exists {
+			return fmt.Errorf("configmap of namespace bar is not watched yet")
+		}
+		return nil
+	}, retry.Timeout(time.Second*2))
+	deleteConfigMap(t, client, "bar")
+	expectConfigMap(t, client, "bar", testdata)
+
+	createNamespace(t, client, "baz")
+	time.Sleep(time.Second)
+	if _, err := client.CoreV1().ConfigMaps("baz").Get(context.TODO(), CACertNamespaceConfigMap, metav1.GetOptions{}); err == nil {
+		t.Error("expected no configmap in a namespace neither listed nor selected")
+	}
+}
+
+func TestNamespaceControllerExplicitOptIn(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	testdata := map[string]string{"key": "value"}
+	nc := NewNamespaceController(func() map[string]string {
+		return testdata
+	}, Options{}, client)
+	nc.SetExplicitOptIn(true)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	nc.Run(stop)
+
+	if _, err := client.CoreV1().Namespaces().Create(context.TODO(), &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "bar", Labels: map[string]string{CAOptInLabel: "true"}},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectConfigMap(t, client, "bar", testdata)
+
+	createNamespace(t, client, "foo")
+	time.Sleep(time.Second)
+	if _, err := client.CoreV1().ConfigMaps("foo").Get(context.TODO(), CACertNamespaceConfigMap, metav1.GetOptions{}); err == nil {
+		t.Error("expected no configmap in a namespace not opted in")
+	}
+
+	// The namespaces are reconciled when explicit opt-in is disabled.
+	nc.SetExplicitOptIn(false)
+	expectConfigMap(t, client, "foo", testdata)
+}
+
+func TestNamespaceSelection(t *testing.T) {
+	namespace := func(name string, labels map[string]string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	if namespaceSelection([]string{""}, "mesh=true") != nil {
+		t.Error("expected all namespaces to be selected")
+	}
+	selected := namespaceSelection([]string{"foo", "istio-system"}, "mesh in (true, yes)")
+	for _, tc := range []struct {
+		ns   *v1.Namespace
+		want bool
+	}{
+		{namespace("foo", nil), true},
+		{namespace("istio-system", nil), true},
+		{namespace("bar", map[string]string{"mesh": "yes"}), true},
+		{namespace("bar", map[string]string{"mesh": "no"}), false},
+		{namespace("bar", nil), false},
+	} {
+		if got := selected(tc.ns); got != tc.want {
+			t.Errorf("selected(%s, %v) = %v, want %v", tc.ns.Name, tc.ns.Labels, got, tc.want)
+		}
+	}
+	if namespaceSelection([]string{"foo"}, "mesh in (")(namespace("bar", map[string]string{"mesh": "true"})) {
+		t.Error("expected an invalid selector to select no namespace")
+	}
+}
+
 func deleteConfigMap(t *testing.T, client *fake.Clientset, ns string) {
 	t.Helper()
 	if err := client.CoreV1().ConfigMaps(ns).Delete(context.TODO(), CACertNamespaceConfigMap, metav1.DeleteOptions{}); err != nil {
@@ -0,0 +1,73 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mesh
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// SecurityConfig holds the settings of the Istio CA in the security section of the mesh config. The
+// section is read by istiod alongside the MeshConfig proto, which does not have these settings yet,
+// so that they are configured and reloaded like the rest of the mesh config. The unset settings keep
+// the values of the istiod flags and environment variables.
+type SecurityConfig struct {
+	// WorkloadCertTTL is the TTL of the workload certs that do not request a TTL.
+	WorkloadCertTTL *metav1.Duration `json:"workloadCertTTL,omitempty"`
+	// MaxWorkloadCertTTL is the max TTL of the workload certs.
+	MaxWorkloadCertTTL *metav1.Duration `json:"maxWorkloadCertTTL,omitempty"`
+	// DualUse sets the common name of the workload certs to their identity.
+	DualUse *bool `json:"dualUse,omitempty"`
+	// PKCS8Key encodes the private keys generated by the CA in PKCS#8.
+	PKCS8Key *bool `json:"pkcs8Key,omitempty"`
+	// ExplicitOptIn only distributes the root cert to the namespaces labeled with
+	// ca.istio.io/override=true.
+	ExplicitOptIn *bool `json:"explicitOptIn,omitempty"`
+}
+
+// ParseSecurityConfig returns the security section of the mesh config YAML. The other fields are
+// ignored, and an empty SecurityConfig is returned if the section is missing.
+func ParseSecurityConfig(yamlText string) (*SecurityConfig, error) {
+	var config struct {
+		Security *SecurityConfig `json:"security,omitempty"`
+	}
+	if err := yaml.Unmarshal([]byte(yamlText), &config); err != nil {
+		return nil, fmt.Errorf("failed to parse the security section of the mesh config: %v", err)
+	}
+	if config.Security == nil {
+		return &SecurityConfig{}, nil
+	}
+	if err := config.Security.Validate(); err != nil {
+		return nil, err
+	}
+	return config.Security, nil
+}
+
+// Validate validates the security settings.
+func (c *SecurityConfig) Validate() error {
+	if c.WorkloadCertTTL != nil && c.WorkloadCertTTL.Duration <= 0 {
+		return fmt.Errorf("security.workloadCertTTL must be positive, got %s", c.WorkloadCertTTL.Duration)
+	}
+	if c.MaxWorkloadCertTTL != nil && c.MaxWorkloadCertTTL.Duration <= 0 {
+		return fmt.Errorf("security.maxWorkloadCertTTL must be positive, got %s", c.MaxWorkloadCertTTL.Duration)
+	}
+	if c.WorkloadCertTTL != nil && c.MaxWorkloadCertTTL != nil && c.WorkloadCertTTL.Duration > c.MaxWorkloadCertTTL.Duration {
+		return fmt.Errorf("security.workloadCertTTL %s is greater than security.maxWorkloadCertTTL %s",
+			c.WorkloadCertTTL.Duration, c.MaxWorkloadCertTTL.Duration)
+	}
+	return nil
+}
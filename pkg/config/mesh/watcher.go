@@ -15,6 +15,8 @@
 package mesh
 
 import (
+	"fmt"
+	"io/ioutil"
 	"reflect"
 	"sync"
 	"sync/atomic"
@@ -32,9 +34,16 @@ type Holder interface {
 	Mesh() *meshconfig.MeshConfig
 }
 
+// SecurityHolder is a holder of the security section of a mesh configuration.
+type SecurityHolder interface {
+	// SecurityConfig returns the security section, which is never nil.
+	SecurityConfig() *SecurityConfig
+}
+
 // Watcher is a Holder whose mesh config can be updated asynchronously.
 type Watcher interface {
 	Holder
+	SecurityHolder
 
 	// AddMeshHandler registers a callback handler for changes to the mesh config.
 	AddMeshHandler(func())
@@ -46,32 +55,35 @@ type watcher struct {
 	mutex    sync.Mutex
 	handlers []func()
 	mesh     *meshconfig.MeshConfig
+	security *SecurityConfig
 }
 
 // NewFixedWatcher creates a new Watcher that always returns the given mesh config. It will never
 // fire any events, since the config never changes.
 func NewFixedWatcher(mesh *meshconfig.MeshConfig) Watcher {
 	return &watcher{
-		mesh: mesh,
+		mesh:     mesh,
+		security: &SecurityConfig{},
 	}
 }
 
 // NewWatcher creates a new Watcher for changes to the given mesh config file. Returns an error
 // if the given file does not exist or failed during parsing.
 func NewWatcher(fileWatcher filewatcher.FileWatcher, filename string) (Watcher, error) {
-	meshConfig, err := ReadMeshConfig(filename)
+	meshConfig, security, err := readMeshAndSecurityConfig(filename, &SecurityConfig{})
 	if err != nil {
 		return nil, err
 	}
 
 	w := &watcher{
-		mesh: meshConfig,
+		mesh:     meshConfig,
+		security: security,
 	}
 
 	// Watch the config file for changes and reload if it got modified
 	addFileWatcher(fileWatcher, filename, func() {
 		// Reload the config file
-		meshConfig, err = ReadMeshConfig(filename)
+		meshConfig, security, err := readMeshAndSecurityConfig(filename, w.SecurityConfig())
 		if err != nil {
 			log.Warnf("failed to read mesh configuration, using default: %v", err)
 			return
@@ -80,7 +92,7 @@ func NewWatcher(fileWatcher filewatcher.FileWatcher, filename string) (Watcher,
 		var handlers []func()
 
 		w.mutex.Lock()
-		if !reflect.DeepEqual(meshConfig, w.mesh) {
+		if !reflect.DeepEqual(meshConfig, w.mesh) || !reflect.DeepEqual(security, w.SecurityConfig()) {
 			log.Infof("mesh configuration updated to: %s", spew.Sdump(meshConfig))
 			if !reflect.DeepEqual(meshConfig.ConfigSources, w.mesh.ConfigSources) {
 				log.Infof("mesh configuration sources have changed")
@@ -89,6 +101,7 @@ func NewWatcher(fileWatcher filewatcher.FileWatcher, filename string) (Watcher,
 
 			// Store the new mesh.
 			atomic.StorePointer((*unsafe.Pointer)(unsafe.Pointer(&w.mesh)), unsafe.Pointer(meshConfig))
+			atomic.StorePointer((*unsafe.Pointer)(unsafe.Pointer(&w.security)), unsafe.Pointer(security))
 			handlers = append([]func(){}, w.handlers...)
 		}
 		w.mutex.Unlock()
@@ -106,9 +119,33 @@ func (w *watcher) Mesh() *meshconfig.MeshConfig {
 	return (*meshconfig.MeshConfig)(atomic.LoadPointer((*unsafe.Pointer)(unsafe.Pointer(&w.mesh))))
 }
 
+// SecurityConfig returns the latest security section of the mesh config.
+func (w *watcher) SecurityConfig() *SecurityConfig {
+	return (*SecurityConfig)(atomic.LoadPointer((*unsafe.Pointer)(unsafe.Pointer(&w.security))))
+}
+
 // AddMeshHandler registers a callback handler for changes to the mesh config.
 func (w *watcher) AddMeshHandler(h func()) {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 	w.handlers = append(w.handlers, h)
 }
+
+// readMeshAndSecurityConfig reads the mesh config and its security section from filename. An invalid
+// security section does not fail the mesh config: it is logged, and the previous section is kept.
+func readMeshAndSecurityConfig(filename string, previous *SecurityConfig) (*meshconfig.MeshConfig, *SecurityConfig, error) {
+	yaml, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read mesh config file: %v", err)
+	}
+	meshConfig, err := ApplyMeshConfigDefaults(string(yaml))
+	if err != nil {
+		return nil, nil, err
+	}
+	security, err := ParseSecurityConfig(string(yaml))
+	if err != nil {
+		log.Warnf("invalid security section in mesh config %s, keeping the previous settings: %v", filename, err)
+		security = previous
+	}
+	return meshConfig, security, nil
+}
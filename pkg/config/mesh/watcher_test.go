@@ -72,6 +72,70 @@ func TestWatcherShouldNotifyHandlers(t *testing.T) {
 	}
 }
 
+func TestWatcherShouldNotifyHandlersOfSecurityChanges(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	path := newTempFile(t)
+	defer removeSilent(path)
+
+	writeFile(t, path, "trustDomain: example.com\nsecurity:\n  workloadCertTTL: 12h\n  dualUse: true\n")
+	w := newWatcher(t, path)
+	g.Expect(w.Mesh().TrustDomain).To(Equal("example.com"))
+	g.Expect(w.SecurityConfig().WorkloadCertTTL.Duration).To(Equal(12 * time.Hour))
+	g.Expect(*w.SecurityConfig().DualUse).To(BeTrue())
+
+	doneCh := make(chan struct{}, 1)
+	w.AddMeshHandler(func() {
+		close(doneCh)
+	})
+
+	// Only the security section changes.
+	writeFile(t, path, "trustDomain: example.com\nsecurity:\n  workloadCertTTL: 6h\n  dualUse: true\n")
+	select {
+	case <-doneCh:
+		g.Expect(w.SecurityConfig().WorkloadCertTTL.Duration).To(Equal(6 * time.Hour))
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for update")
+	}
+}
+
+func TestParseSecurityConfig(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	security, err := mesh.ParseSecurityConfig("ingressClass: istio\n")
+	g.Expect(err).To(BeNil())
+	g.Expect(security).To(Equal(&mesh.SecurityConfig{}))
+
+	security, err = mesh.ParseSecurityConfig("security:\n  maxWorkloadCertTTL: 48h\n  pkcs8Key: true\n  explicitOptIn: false\n")
+	g.Expect(err).To(BeNil())
+	g.Expect(security.MaxWorkloadCertTTL.Duration).To(Equal(48 * time.Hour))
+	g.Expect(*security.PKCS8Key).To(BeTrue())
+	g.Expect(*security.ExplicitOptIn).To(BeFalse())
+	g.Expect(security.WorkloadCertTTL).To(BeNil())
+
+	for _, invalid := range []string{
+		"security:\n  workloadCertTTL: 1y\n",
+		"security:\n  workloadCertTTL: -1h\n",
+		"security:\n  workloadCertTTL: 2h\n  maxWorkloadCertTTL: 1h\n",
+		"security:\n  dualUse: maybe\n",
+	} {
+		_, err = mesh.ParseSecurityConfig(invalid)
+		g.Expect(err).ToNot(BeNil(), invalid)
+	}
+}
+
+func TestWatcherKeepsSecurityConfigOnInvalidSection(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	path := newTempFile(t)
+	defer removeSilent(path)
+
+	writeFile(t, path, "trustDomain: example.com\nsecurity:\n  workloadCertTTL: -1h\n")
+	w := newWatcher(t, path)
+	g.Expect(w.Mesh().TrustDomain).To(Equal("example.com"))
+	g.Expect(w.SecurityConfig()).To(Equal(&mesh.SecurityConfig{}))
+}
+
 func newWatcher(t testing.TB, filename string) mesh.Watcher {
 	t.Helper()
 	w, err := mesh.NewWatcher(filewatcher.NewWatcher(), filename)
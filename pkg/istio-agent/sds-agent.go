@@ -15,6 +15,7 @@
 package istioagent
 
 import (
+	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
@@ -26,6 +27,7 @@ import (
 
 	"istio.io/istio/pilot/pkg/security/model"
 	"istio.io/istio/pkg/kube"
+	"istio.io/istio/security/pkg/featuregate"
 	caClientInterface "istio.io/istio/security/pkg/nodeagent/caclient/interface"
 	citadel "istio.io/istio/security/pkg/nodeagent/caclient/providers/citadel"
 	gca "istio.io/istio/security/pkg/nodeagent/caclient/providers/google"
@@ -34,6 +36,7 @@ import (
 	"istio.io/istio/security/pkg/nodeagent/cache"
 	"istio.io/istio/security/pkg/nodeagent/sds"
 	"istio.io/istio/security/pkg/nodeagent/secretfetcher"
+	pkiutil "istio.io/istio/security/pkg/pki/util"
 	"istio.io/pkg/env"
 	"istio.io/pkg/log"
 )
@@ -62,7 +65,7 @@ var (
 	caEndpointEnv = env.RegisterStringVar(caEndpoint, "", "").Get()
 
 	pluginNamesEnv             = env.RegisterStringVar(pluginNames, "", "").Get()
-	enableIngressGatewaySDSEnv = env.RegisterBoolVar(enableIngressGatewaySDS, false, "").Get()
+	enableIngressGatewaySDSVar = env.RegisterBoolVar(enableIngressGatewaySDS, false, "")
 
 	trustDomainEnv = env.RegisterStringVar(trustDomain, "", "").Get()
 	secretTTLEnv   = env.RegisterDurationVar(secretTTL, 24*time.Hour,
@@ -75,7 +78,7 @@ var (
 		"The ticker to detect and close stale connections").Get()
 	initialBackoffInMilliSecEnv = env.RegisterIntVar(initialBackoffInMilliSec, 0, "").Get()
 	pkcs8KeysEnv                = env.RegisterBoolVar(pkcs8Key, false, "Whether to generate PKCS#8 private keys").Get()
-	eccSigAlgEnv                = env.RegisterStringVar(eccSigAlg, "", "The type of ECC signature algorithm to use when generating private keys").Get()
+	eccSigAlgEnv                = env.RegisterStringVar(eccSigAlg, "", "The type of ECC signature algorithm to use when generating private keys, ECDSA (P256), ECDSA-P384 or ECDSA-P521").Get()
 
 	// Location of K8S CA root.
 	k8sCAPath = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
@@ -87,6 +90,17 @@ var (
 	CitadelCACertPath = "./var/run/secrets/istio"
 
 	fileMountedCertsEnv = env.RegisterBoolVar(fileMountedCerts, false, "").Get()
+
+	rootCAFromFileEnv = env.RegisterBoolVar(rootCAFromFile, false,
+		"Serve the ROOTCA resource from the mounted istio-ca-root-cert ConfigMap instead of the CSR "+
+			"responses, so that root rotations reach the proxies without re-issuing their certificates").Get()
+
+	workloadAttestationEnv = env.RegisterBoolVar(workloadAttestation, false,
+		"Attest that the callers of the workload SDS socket run in a pod of the service account of their "+
+			"token, by resolving their pod from their process. Requires NODE_NAME, and the service account of the "+
+			"agent must be allowed to list and watch the pods.").Get()
+	nodeNameEnv = env.RegisterStringVar(nodeName, "",
+		"The name of the node of the agent, whose pods are watched to attest the SDS callers").Get()
 )
 
 const (
@@ -137,6 +151,15 @@ const (
 
 	// Indicates whether proxy uses file mounted certificates.
 	fileMountedCerts = "FILE_MOUNTED_CERTS"
+
+	// Indicates whether the trust bundle is served from the mounted istio-ca-root-cert ConfigMap.
+	rootCAFromFile = "ROOTCA_FROM_FILE"
+
+	// Indicates whether the callers of the workload SDS socket are attested.
+	workloadAttestation = "WORKLOAD_ATTESTATION"
+
+	// The name of the node the agent runs on.
+	nodeName = "NODE_NAME"
 )
 
 var (
@@ -147,6 +170,7 @@ var (
 	gatewaySdsCacheOptions  cache.Options
 	serverOptions           sds.Options
 	gatewaySecretChan       chan struct{}
+	attestorStopChan        chan struct{}
 )
 
 // SDSAgent contains the configuration of the agent, based on the injected
@@ -310,6 +334,13 @@ func (sa *SDSAgent) Start(isSidecar bool, podNamespace string) (*sds.Server, err
 	} else {
 		serverOptions.UseLocalJWT = sa.CertsPath == "" // true if we don't have a key.pem
 	}
+	if workloadAttestationEnv {
+		attestor, err := newWorkloadAttestor()
+		if err != nil {
+			return nil, err
+		}
+		serverOptions.WorkloadAttestor = attestor
+	}
 
 	// TODO: remove the caching, workload has a single cert
 	workloadSecretCache, _ := sa.newSecretCache(serverOptions)
@@ -335,6 +366,20 @@ func (sa *SDSAgent) Start(isSidecar bool, podNamespace string) (*sds.Server, err
 	return server, nil
 }
 
+// newWorkloadAttestor creates the attestor of the SDS callers, watching the pods on the node of the agent.
+func newWorkloadAttestor() (sds.WorkloadAttestor, error) {
+	if nodeNameEnv == "" {
+		return nil, fmt.Errorf("%s requires %s to be set", workloadAttestation, nodeName)
+	}
+	cs, err := kube.CreateClientset("", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the client of the workload attestor: %v", err)
+	}
+	attestorStopChan = make(chan struct{})
+	log.Infof("Attesting the SDS callers with the pods on node %s", nodeNameEnv)
+	return sds.NewPodAttestor(cs, nodeNameEnv, attestorStopChan), nil
+}
+
 func ingressSdsExists() bool {
 	p := strings.TrimPrefix(model.IngressGatewaySdsUdsPath, "unix:")
 	dir := path.Dir(p)
@@ -353,6 +398,13 @@ func (sa *SDSAgent) newSecretCache(serverOptions sds.Options) (workloadSecretCac
 	var err error
 
 	workloadSdsCacheOptions.Plugins = sds.NewPlugins(serverOptions.PluginNames)
+	if serverOptions.UseLocalJWT {
+		// Projected tokens are rotated by the kubelet, the secrets are rotated with the new token.
+		workloadSdsCacheOptions.JWTPath = serverOptions.JWTPath
+	}
+	if rootCAFromFileEnv {
+		workloadSdsCacheOptions.RootCertFile = path.Join(CitadelCACertPath, constants.CACertNamespaceConfigMapDataName)
+	}
 	workloadSecretCache = cache.NewSecretCache(fetcher, sds.NotifyProxy, workloadSdsCacheOptions)
 	sa.WorkloadSecrets = workloadSecretCache
 
@@ -515,11 +567,18 @@ func applyEnvVars() {
 
 	serverOptions.EnableWorkloadSDS = true
 
-	serverOptions.EnableIngressGatewaySDS = enableIngressGatewaySDSEnv
+	if enabled, set := enableIngressGatewaySDSVar.Lookup(); set {
+		serverOptions.EnableIngressGatewaySDS = enabled
+	} else {
+		serverOptions.EnableIngressGatewaySDS = featuregate.Default.Enabled(featuregate.GatewaySDS)
+	}
 	serverOptions.CAProviderName = caProviderEnv
 	serverOptions.TrustDomain = trustDomainEnv
 	serverOptions.Pkcs8Keys = pkcs8KeysEnv
 	serverOptions.ECCSigAlg = eccSigAlgEnv
+	if serverOptions.ECCSigAlg == "" && featuregate.Default.Enabled(featuregate.ECDSADefault) {
+		serverOptions.ECCSigAlg = string(pkiutil.EcdsaSigAlg)
+	}
 	serverOptions.RecycleInterval = staledConnectionRecycleIntervalEnv
 	workloadSdsCacheOptions.SecretTTL = secretTTLEnv
 	workloadSdsCacheOptions.SecretRotationGracePeriodRatio = secretRotationGracePeriodRatioEnv
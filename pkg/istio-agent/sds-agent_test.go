@@ -30,3 +30,14 @@ func TestSDSAgentWithFileMountedCerts(t *testing.T) {
 		t.Fatalf("Unexpected error starting SDSAgent %v", err)
 	}
 }
+
+// Validate that the workload attestation cannot be enabled without the node of the agent.
+func TestSDSAgentWorkloadAttestationRequiresNodeName(t *testing.T) {
+	fm, wa, nn := fileMountedCertsEnv, workloadAttestationEnv, nodeNameEnv
+	fileMountedCertsEnv, workloadAttestationEnv, nodeNameEnv = true, true, ""
+	defer func() { fileMountedCertsEnv, workloadAttestationEnv, nodeNameEnv = fm, wa, nn }()
+	sa := NewSDSAgent("istiod.istio-system:15012", false, "custom", "", "", "kubernetes")
+	if _, err := sa.Start(true, "test"); err == nil {
+		t.Fatal("expected an error starting SDSAgent with WORKLOAD_ATTESTATION and no NODE_NAME")
+	}
+}
@@ -15,8 +15,13 @@
 package kube
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	kubeApiCore "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -83,6 +88,46 @@ func CreateClientset(kubeconfig, context string, fns ...func(*rest.Config)) (*ku
 	return kubernetes.NewForConfig(c)
 }
 
+// SetRequestTimeout bounds the requests of the clients created from config to timeout, including the
+// reading of the response. Unlike config.Timeout, it does not apply to watches, which are kept open
+// until the server closes them.
+func SetRequestTimeout(config *rest.Config, timeout time.Duration) {
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &timeoutRoundTripper{rt: rt, timeout: timeout}
+	})
+}
+
+type timeoutRoundTripper struct {
+	rt      http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *timeoutRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if watch, _ := strconv.ParseBool(req.URL.Query().Get("watch")); watch {
+		return t.rt.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.rt.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The deadline applies until the response is read.
+	resp.Body = &cancelingBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelingBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
 // CreateMetadataClient is a helper function that builds a kubernetes metadata client from a kubeconfig
 // filepath. See `BuildClientConfig` for kubeconfig loading rules.
 func CreateMetadataClient(kubeconfig, context string, fns ...func(*rest.Config)) (metadata.Interface, error) {
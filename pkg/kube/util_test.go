@@ -15,11 +15,19 @@
 package kube
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 func TestBuildClientConfig(t *testing.T) {
@@ -99,6 +107,49 @@ func TestBuildClientConfig(t *testing.T) {
 	}
 }
 
+func TestSetRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "true" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	config := &rest.Config{Host: server.URL}
+	SetRequestTimeout(config, 100*time.Millisecond)
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := client.CoreV1().Secrets("ns").Get(context.TODO(), "secret", metav1.GetOptions{}); err == nil {
+		t.Errorf("expected the request to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the request timed out after %v", elapsed)
+	}
+
+	w, err := client.CoreV1().Secrets("ns").Watch(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	defer w.Stop()
+	select {
+	case e, ok := <-w.ResultChan():
+		t.Errorf("expected the watch to stay open past the timeout, got %v (open: %v)", e, ok)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
 func generateKubeConfig(cluster1Host string, cluster2Host string) (string, error) {
 	tempDir, err := ioutil.TempDir("/tmp/", ".kube")
 	if err != nil {
@@ -0,0 +1,454 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listwatch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DynamicListerWatcher is a ListerWatcher of multiple namespaces, which can be added and removed at
+// runtime. The namespaces are changed without restarting the controller, nor relisting the objects of
+// the other namespaces: the active watch lists the objects of an added namespace, sends them as added
+// events and watches the namespace, and sends deleted events for the objects of a removed namespace.
+// The changes made before the first watch are only recorded, so that the namespaces added while a
+// Namespace informer syncs are listed at once by the first List.
+type DynamicListerWatcher struct {
+	f func(string) cache.ListerWatcher
+
+	mutex      sync.Mutex
+	namespaces map[string]cache.ListerWatcher
+	// listed are the objects returned by the last List, by namespace.
+	listed map[string]namespaceList
+	active *dynamicWatch
+}
+
+// namespaceList is the list of the objects of a namespace, by name.
+type namespaceList struct {
+	resourceVersion string
+	objects         map[string]runtime.Object
+}
+
+var _ cache.ListerWatcher = &DynamicListerWatcher{}
+
+// NewDynamicListerWatcher returns a DynamicListerWatcher of the initial namespaces, using f to create
+// the ListerWatcher of each namespace. The selectors, if any, are added to the requests of each namespace.
+func NewDynamicListerWatcher(namespaces []string, f func(string) cache.ListerWatcher,
+	selectors ...Selector) *DynamicListerWatcher {
+	f = withSelectors(f, selectors)
+	d := &DynamicListerWatcher{f: f, namespaces: map[string]cache.ListerWatcher{}}
+	for _, ns := range namespaces {
+		d.namespaces[ns] = f(ns)
+	}
+	return d
+}
+
+// AddNamespace starts listing and watching namespace. It returns whether the namespace was added.
+func (d *DynamicListerWatcher) AddNamespace(namespace string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if _, ok := d.namespaces[namespace]; ok {
+		return false
+	}
+	lw := d.f(namespace)
+	d.namespaces[namespace] = lw
+	if d.active != nil {
+		d.active.add(namespace, lw, "", nil)
+	}
+	return true
+}
+
+// RemoveNamespace stops listing and watching namespace. It returns whether the namespace was removed.
+func (d *DynamicListerWatcher) RemoveNamespace(namespace string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if _, ok := d.namespaces[namespace]; !ok {
+		return false
+	}
+	delete(d.namespaces, namespace)
+	if d.active != nil {
+		d.active.remove(namespace)
+	}
+	return true
+}
+
+// Namespaces returns the sorted namespaces that are listed and watched.
+func (d *DynamicListerWatcher) Namespaces() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.namespacesLocked()
+}
+
+// NamespaceHandler returns an event handler of a Namespace informer, which adds the namespaces for which
+// selected returns true, and removes the other and deleted namespaces.
+func (d *DynamicListerWatcher) NamespaceHandler(selected func(*v1.Namespace) bool) cache.ResourceEventHandler {
+	update := func(obj interface{}) {
+		ns, ok := obj.(*v1.Namespace)
+		if !ok {
+			return
+		}
+		if selected(ns) && ns.Status.Phase != v1.NamespaceTerminating {
+			d.AddNamespace(ns.Name)
+		} else {
+			d.RemoveNamespace(ns.Name)
+		}
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    update,
+		UpdateFunc: func(_, obj interface{}) { update(obj) },
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if ns, ok := obj.(*v1.Namespace); ok {
+				d.RemoveNamespace(ns.Name)
+			}
+		},
+	}
+}
+
+// List lists the objects of all namespaces. The resource version of the list combines the namespaces
+// and their resource versions, as <namespace>=<resource version>/...
+func (d *DynamicListerWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	d.mutex.Lock()
+	namespaces := d.namespacesLocked()
+	lws := make([]cache.ListerWatcher, 0, len(namespaces))
+	for _, ns := range namespaces {
+		lws = append(lws, d.namespaces[ns])
+	}
+	d.mutex.Unlock()
+
+	if strings.ContainsAny(options.ResourceVersion, "=/") {
+		// A combined resource version cannot be passed to the namespaces.
+		options.ResourceVersion = ""
+	}
+	l := metav1.List{}
+	listed := make(map[string]namespaceList, len(lws))
+	resourceVersions := make([]string, 0, len(lws))
+	for i, lw := range lws {
+		list, err := listObjects(lw, options)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list.objects {
+			l.Items = append(l.Items, runtime.RawExtension{Object: item})
+		}
+		listed[namespaces[i]] = list
+		resourceVersions = append(resourceVersions, namespaces[i]+"="+list.resourceVersion)
+	}
+	l.ListMeta.ResourceVersion = strings.Join(resourceVersions, "/")
+
+	d.mutex.Lock()
+	d.listed = listed
+	d.mutex.Unlock()
+	return &l, nil
+}
+
+// Watch watches the objects of all namespaces, from the combined resource version returned by List.
+// If the namespaces changed since the last List, an expired error is returned, so that the objects
+// are relisted.
+func (d *DynamicListerWatcher) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	namespaces := d.namespacesLocked()
+	resourceVersions := make([]string, len(namespaces))
+	if options.ResourceVersion != "" {
+		rvs := map[string]string{}
+		for _, part := range strings.Split(options.ResourceVersion, "/") {
+			items := strings.SplitN(part, "=", 2)
+			if len(items) != 2 {
+				return nil, errors.NewResourceExpired(fmt.Sprintf("resource version %q is not a combined resource version",
+					options.ResourceVersion))
+			}
+			rvs[items[0]] = items[1]
+		}
+		if len(rvs) != len(namespaces) {
+			return nil, errors.NewResourceExpired("the watched namespaces changed")
+		}
+		for i, ns := range namespaces {
+			rv, ok := rvs[ns]
+			if !ok {
+				return nil, errors.NewResourceExpired("the watched namespaces changed")
+			}
+			// The listed objects are needed to send their deletion if the namespace is removed.
+			if list, ok := d.listed[ns]; !ok || list.resourceVersion != rv {
+				return nil, errors.NewResourceExpired(fmt.Sprintf("resource version %q of namespace %s was not listed", rv, ns))
+			}
+			resourceVersions[i] = rv
+		}
+	}
+
+	w := newDynamicWatch(options)
+	for i, ns := range namespaces {
+		var objects map[string]runtime.Object
+		if options.ResourceVersion != "" {
+			objects = make(map[string]runtime.Object, len(d.listed[ns].objects))
+			for name, obj := range d.listed[ns].objects {
+				objects[name] = obj
+			}
+		}
+		w.add(ns, d.namespaces[ns], resourceVersions[i], objects)
+	}
+	if d.active != nil {
+		d.active.Stop()
+	}
+	d.active = w
+	return w, nil
+}
+
+func (d *DynamicListerWatcher) namespacesLocked() []string {
+	namespaces := make([]string, 0, len(d.namespaces))
+	for ns := range d.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// listObjects lists the objects of a namespace.
+func listObjects(lw cache.ListerWatcher, options metav1.ListOptions) (namespaceList, error) {
+	list, err := lw.List(options)
+	if err != nil {
+		return namespaceList{}, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return namespaceList{}, err
+	}
+	metaObj, err := meta.ListAccessor(list)
+	if err != nil {
+		return namespaceList{}, err
+	}
+	objects := make(map[string]runtime.Object, len(items))
+	for _, item := range items {
+		obj, err := meta.Accessor(item)
+		if err != nil {
+			return namespaceList{}, err
+		}
+		objects[obj.GetName()] = item.DeepCopyObject()
+	}
+	return namespaceList{resourceVersion: metaObj.GetResourceVersion(), objects: objects}, nil
+}
+
+// dynamicWatch combines the watches of namespaces, which can be added and removed while it runs.
+type dynamicWatch struct {
+	options metav1.ListOptions
+	result  chan watch.Event
+	stopped chan struct{}
+	wg      sync.WaitGroup
+
+	mutex sync.Mutex
+	// namespaces are the latest watches of the namespaces, including the removed ones that still
+	// send the deletion of their objects.
+	namespaces map[string]*namespaceWatch
+}
+
+// namespaceWatch is the watch of a namespace.
+type namespaceWatch struct {
+	removed chan struct{}
+	done    chan struct{}
+}
+
+func newDynamicWatch(options metav1.ListOptions) *dynamicWatch {
+	options.ResourceVersion = ""
+	w := &dynamicWatch{
+		options:    options,
+		result:     make(chan watch.Event),
+		stopped:    make(chan struct{}),
+		namespaces: map[string]*namespaceWatch{},
+	}
+	// result chan must be closed,
+	// once all event sender goroutines exited.
+	go func() {
+		<-w.stopped
+		w.wg.Wait()
+		close(w.result)
+	}()
+	return w
+}
+
+// add starts watching namespace from the resource version of the listed objects. If the objects are
+// nil, the objects of namespace are listed and sent as added events first.
+func (w *dynamicWatch) add(namespace string, lw cache.ListerWatcher, rv string, objects map[string]runtime.Object) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	select {
+	case <-w.stopped:
+		return
+	default:
+	}
+	nw := &namespaceWatch{removed: make(chan struct{}), done: make(chan struct{})}
+	prev := w.namespaces[namespace]
+	w.namespaces[namespace] = nw
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.run(namespace, lw, nw, prev, rv, objects)
+		close(nw.done)
+		w.mutex.Lock()
+		if w.namespaces[namespace] == nw {
+			delete(w.namespaces, namespace)
+		}
+		w.mutex.Unlock()
+	}()
+}
+
+// remove stops watching namespace, and sends the deletion of its objects.
+func (w *dynamicWatch) remove(namespace string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if nw, ok := w.namespaces[namespace]; ok {
+		select {
+		case <-nw.removed:
+		default:
+			close(nw.removed)
+		}
+	}
+}
+
+func (w *dynamicWatch) run(namespace string, lw cache.ListerWatcher, nw, prev *namespaceWatch, rv string,
+	objects map[string]runtime.Object) {
+	if prev != nil {
+		// The deletion of the objects by the previous watch of the namespace is sent first.
+		select {
+		case <-prev.done:
+		case <-w.stopped:
+			return
+		}
+	}
+	if objects == nil {
+		list, err := listObjects(lw, w.options)
+		if err != nil {
+			w.Stop()
+			return
+		}
+		rv, objects = list.resourceVersion, list.objects
+		for _, obj := range objects {
+			if !w.send(watch.Event{Type: watch.Added, Object: obj}) {
+				return
+			}
+		}
+	}
+	for {
+		options := w.options.DeepCopy()
+		options.ResourceVersion = rv
+		start := time.Now()
+		nsWatch, err := lw.Watch(*options)
+		if err != nil {
+			w.Stop()
+			return
+		}
+		var closed bool
+		rv, closed = w.forward(nsWatch, nw, rv, objects)
+		nsWatch.Stop()
+		if !closed {
+			break
+		}
+		// The watch of the namespace timed out, and is watched again from the last resource version,
+		// unless it was closed right away.
+		if time.Since(start) < time.Second {
+			w.Stop()
+			return
+		}
+	}
+	select {
+	case <-nw.removed:
+		for _, obj := range objects {
+			if !w.send(watch.Event{Type: watch.Deleted, Object: obj}) {
+				return
+			}
+		}
+	default:
+	}
+}
+
+// forward sends the events of the watch of a namespace, and tracks its objects. It returns the last
+// resource version, and whether the watch was closed.
+func (w *dynamicWatch) forward(nsWatch watch.Interface, nw *namespaceWatch, rv string,
+	objects map[string]runtime.Object) (string, bool) {
+	for {
+		select {
+		case <-nw.removed:
+			return rv, false
+		case <-w.stopped:
+			return rv, false
+		case event, ok := <-nsWatch.ResultChan():
+			if !ok {
+				return rv, true
+			}
+			if event.Type == watch.Error {
+				w.send(event)
+				w.Stop()
+				return rv, false
+			}
+			obj, err := meta.Accessor(event.Object)
+			if err != nil {
+				w.Stop()
+				return rv, false
+			}
+			rv = obj.GetResourceVersion()
+			switch event.Type {
+			case watch.Bookmark:
+				// The bookmark of a namespace is not a bookmark of the other namespaces.
+				continue
+			case watch.Deleted:
+				delete(objects, obj.GetName())
+			default:
+				objects[obj.GetName()] = event.Object
+			}
+			if !w.send(event) {
+				return rv, false
+			}
+		}
+	}
+}
+
+func (w *dynamicWatch) send(event watch.Event) bool {
+	select {
+	case w.result <- event:
+		return true
+	case <-w.stopped:
+		return false
+	}
+}
+
+// ResultChan implements the watch.Interface interface.
+func (w *dynamicWatch) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+// Stop implements the watch.Interface interface.
+// It stops the watches of all namespaces and closes the backing chan.
+// Can safely be called more than once.
+func (w *dynamicWatch) Stop() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	select {
+	case <-w.stopped:
+	default:
+		close(w.stopped)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listwatch
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func secretListerWatcher(client kubernetes.Interface) func(string) cache.ListerWatcher {
+	return func(namespace string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().Secrets(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().Secrets(namespace).Watch(context.TODO(), options)
+			},
+		}
+	}
+}
+
+// countingListerWatcher counts the lists of each namespace.
+type countingListerWatcher struct {
+	mutex sync.Mutex
+	lists map[string]int
+}
+
+func (c *countingListerWatcher) wrap(f func(string) cache.ListerWatcher) func(string) cache.ListerWatcher {
+	return func(namespace string) cache.ListerWatcher {
+		lw := f(namespace)
+		return &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				c.mutex.Lock()
+				c.lists[namespace]++
+				c.mutex.Unlock()
+				return lw.List(options)
+			},
+			WatchFunc: lw.Watch,
+		}
+	}
+}
+
+func (c *countingListerWatcher) expectLists(t *testing.T, expected map[string]int) {
+	t.Helper()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !reflect.DeepEqual(c.lists, expected) {
+		t.Errorf("got lists %v, want %v", c.lists, expected)
+	}
+}
+
+func waitForKeys(t *testing.T, store cache.Store, expected ...string) {
+	t.Helper()
+	var keys []string
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+		keys = store.ListKeys()
+		sort.Strings(keys)
+		if len(keys) == len(expected) && (len(keys) == 0 || reflect.DeepEqual(keys, expected)) {
+			return
+		}
+	}
+	t.Fatalf("got keys %v, want %v", keys, expected)
+}
+
+func TestDynamicListerWatcher(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	for _, ns := range []string{"ns1", "ns2", "ns3"} {
+		_, err := client.CoreV1().Secrets(ns).Create(context.TODO(), &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: ns},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatalf("failed to create secret: %v", err)
+		}
+	}
+
+	counter := &countingListerWatcher{lists: map[string]int{}}
+	lw := NewDynamicListerWatcher([]string{"ns1"}, counter.wrap(secretListerWatcher(client)))
+	store, controller := cache.NewInformer(lw, &v1.Secret{}, 0, cache.ResourceEventHandlerFuncs{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go controller.Run(stop)
+	waitForKeys(t, store, "ns1/secret")
+
+	if !lw.AddNamespace("ns2") || lw.AddNamespace("ns2") {
+		t.Error("expected ns2 to be added once")
+	}
+	waitForKeys(t, store, "ns1/secret", "ns2/secret")
+	// Only the added namespace is listed.
+	counter.expectLists(t, map[string]int{"ns1": 1, "ns2": 1})
+
+	// Events of the added namespace are watched.
+	_, err := client.CoreV1().Secrets("ns2").Create(context.TODO(), &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns2"},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create secret: %v", err)
+	}
+	waitForKeys(t, store, "ns1/secret", "ns2/other", "ns2/secret")
+
+	if !lw.RemoveNamespace("ns1") || lw.RemoveNamespace("ns1") {
+		t.Error("expected ns1 to be removed once")
+	}
+	waitForKeys(t, store, "ns2/other", "ns2/secret")
+
+	lw.RemoveNamespace("ns2")
+	waitForKeys(t, store)
+
+	// A namespace added again after its removal is listed again.
+	lw.AddNamespace("ns1")
+	waitForKeys(t, store, "ns1/secret")
+	lw.RemoveNamespace("ns1")
+	waitForKeys(t, store)
+	counter.expectLists(t, map[string]int{"ns1": 2, "ns2": 1})
+	if namespaces := lw.Namespaces(); len(namespaces) != 0 {
+		t.Errorf("expected no namespaces, got %v", namespaces)
+	}
+}
+
+func TestDynamicListerWatcherNamespaceHandler(t *testing.T) {
+	lw := NewDynamicListerWatcher(nil, secretListerWatcher(fake.NewSimpleClientset()))
+	handler := lw.NamespaceHandler(func(ns *v1.Namespace) bool {
+		return ns.Labels["istio-managed"] == "enabled"
+	})
+	namespace := func(name, label string, phase v1.NamespacePhase) *v1.Namespace {
+		return &v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"istio-managed": label}},
+			Status:     v1.NamespaceStatus{Phase: phase},
+		}
+	}
+
+	handler.OnAdd(namespace("a", "enabled", v1.NamespaceActive))
+	handler.OnAdd(namespace("b", "enabled", v1.NamespaceActive))
+	handler.OnAdd(namespace("c", "", v1.NamespaceActive))
+	handler.OnUpdate(nil, namespace("c", "enabled", v1.NamespaceActive))
+	handler.OnUpdate(nil, namespace("b", "", v1.NamespaceActive))
+	handler.OnUpdate(nil, namespace("a", "enabled", v1.NamespaceTerminating))
+	if namespaces := lw.Namespaces(); !reflect.DeepEqual(namespaces, []string{"c"}) {
+		t.Errorf("got namespaces %v, want [c]", namespaces)
+	}
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "c", Obj: namespace("c", "enabled", v1.NamespaceActive)})
+	if namespaces := lw.Namespaces(); len(namespaces) != 0 {
+		t.Errorf("got namespaces %v, want none", namespaces)
+	}
+}
+
+func TestDynamicListerWatcherExpiredResourceVersion(t *testing.T) {
+	lw := NewDynamicListerWatcher([]string{"ns1", "ns2"}, secretListerWatcher(fake.NewSimpleClientset()))
+	list, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list: %v", err)
+	}
+	listed, err := meta.ListAccessor(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rv := listed.GetResourceVersion()
+	for _, rv := range []string{"12", "ns1=1", "ns1=1/ns3=2", "ns1=1/ns2=2"} {
+		if _, err := lw.Watch(metav1.ListOptions{ResourceVersion: rv}); err == nil {
+			t.Errorf("expected an error watching from resource version %q", rv)
+		}
+	}
+	w, err := lw.Watch(metav1.ListOptions{ResourceVersion: rv})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	defer w.Stop()
+	// The active watch watches the added namespace, but a new watch requires a relist.
+	lw.AddNamespace("ns3")
+	if _, err := lw.Watch(metav1.ListOptions{ResourceVersion: rv}); err == nil {
+		t.Error("expected an error watching before relisting")
+	}
+}
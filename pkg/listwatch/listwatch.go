@@ -31,8 +31,12 @@ import (
 
 // MultiNamespaceListerWatcher takes a list of namespaces and a
 // cache.ListerWatcher generator func and returns a single cache.ListerWatcher
-// capable of operating on multiple namespaces.
-func MultiNamespaceListerWatcher(namespaces []string, f func(string) cache.ListerWatcher) cache.ListerWatcher {
+// capable of operating on multiple namespaces. The selectors, if any, are
+// added to the list and watch requests of each namespace, so that the
+// objects are filtered by the API server.
+func MultiNamespaceListerWatcher(namespaces []string, f func(string) cache.ListerWatcher,
+	selectors ...Selector) cache.ListerWatcher {
+	f = withSelectors(f, selectors)
 	// If there is only one namespace then there is no need to create a proxy.
 	if len(namespaces) == 1 {
 		return f(namespaces[0])
@@ -44,6 +48,67 @@ func MultiNamespaceListerWatcher(namespaces []string, f func(string) cache.Liste
 	return multiListerWatcher(lws)
 }
 
+// Selector is a label or field selector added to the list and watch requests.
+type Selector struct {
+	Label string
+	Field string
+}
+
+// LabelSelector returns a Selector of the objects matching the label selector.
+func LabelSelector(selector string) Selector {
+	return Selector{Label: selector}
+}
+
+// FieldSelector returns a Selector of the objects matching the field selector.
+func FieldSelector(selector string) Selector {
+	return Selector{Field: selector}
+}
+
+// apply adds the selector to options. Selectors are combined with a comma, which is a logical AND.
+func (s Selector) apply(options *metav1.ListOptions) {
+	options.LabelSelector = joinSelectors(options.LabelSelector, s.Label)
+	options.FieldSelector = joinSelectors(options.FieldSelector, s.Field)
+}
+
+func joinSelectors(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + "," + b
+}
+
+// withSelectors wraps the ListerWatchers created by f to add the selectors to their requests.
+func withSelectors(f func(string) cache.ListerWatcher, selectors []Selector) func(string) cache.ListerWatcher {
+	if len(selectors) == 0 {
+		return f
+	}
+	return func(namespace string) cache.ListerWatcher {
+		return &selectorListerWatcher{lw: f(namespace), selectors: selectors}
+	}
+}
+
+type selectorListerWatcher struct {
+	lw        cache.ListerWatcher
+	selectors []Selector
+}
+
+func (s *selectorListerWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	for _, selector := range s.selectors {
+		selector.apply(&options)
+	}
+	return s.lw.List(options)
+}
+
+func (s *selectorListerWatcher) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	for _, selector := range s.selectors {
+		selector.apply(&options)
+	}
+	return s.lw.Watch(options)
+}
+
 // multiListerWatcher abstracts several cache.ListerWatchers, allowing them
 // to be treated as a single cache.ListerWatcher.
 type multiListerWatcher []cache.ListerWatcher
@@ -183,3 +183,50 @@ func TestRacyMultiWatch(t *testing.T) {
 	mw.Stop()
 	mw.Stop()
 }
+
+func TestMultiNamespaceListerWatcherSelectors(t *testing.T) {
+	for _, namespaces := range [][]string{{"ns1"}, {"ns1", "ns2"}} {
+		var mutex sync.Mutex
+		var requests []metav1.ListOptions
+		record := func(options metav1.ListOptions) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			requests = append(requests, options)
+		}
+		mlw := MultiNamespaceListerWatcher(namespaces, func(string) cache.ListerWatcher {
+			return &cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					record(options)
+					return &metav1.List{}, nil
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					record(options)
+					return watch.NewFake(), nil
+				},
+			}
+		}, LabelSelector("mesh=enabled"), FieldSelector("type=istio.io/key-and-cert"))
+
+		if _, err := mlw.List(metav1.ListOptions{LabelSelector: "app=foo"}); err != nil {
+			t.Fatalf("failed to list: %v", err)
+		}
+		w, err := mlw.Watch(metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("failed to watch: %v", err)
+		}
+		w.Stop()
+
+		if len(requests) != 2*len(namespaces) {
+			t.Fatalf("expected %d requests, got %d", 2*len(namespaces), len(requests))
+		}
+		for i, options := range requests {
+			expectedLabel := "mesh=enabled"
+			if i < len(namespaces) {
+				expectedLabel = "app=foo,mesh=enabled"
+			}
+			if options.LabelSelector != expectedLabel || options.FieldSelector != "type=istio.io/key-and-cert" {
+				t.Errorf("%d namespaces: request %d has selectors %q and %q, want %q and %q", len(namespaces), i,
+					options.LabelSelector, options.FieldSelector, expectedLabel, "type=istio.io/key-and-cert")
+			}
+		}
+	}
+}
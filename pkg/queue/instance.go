@@ -28,7 +28,8 @@ type Task func() error
 type Instance interface {
 	// Push a task.
 	Push(task Task)
-	// Run the loop until a signal on the channel
+	// Run the loop until a signal on the channel. Run may be called from several goroutines to
+	// process the tasks concurrently, in which case they are no longer processed in order.
 	Run(<-chan struct{})
 }
 
@@ -62,7 +63,7 @@ func (q *queueImpl) Run(stop <-chan struct{}) {
 	go func() {
 		<-stop
 		q.cond.L.Lock()
-		q.cond.Signal()
+		q.cond.Broadcast()
 		q.closing = true
 		q.cond.L.Unlock()
 	}()
@@ -113,3 +113,36 @@ func TestResourceFree(t *testing.T) {
 		t.Log("queue return.")
 	}
 }
+
+func TestConcurrentRun(t *testing.T) {
+	q := NewQueue(1 * time.Microsecond)
+	stop := make(chan struct{})
+
+	// Each task blocks until all of them run, which requires as many concurrent workers as tasks.
+	workers := 4
+	running := sync.WaitGroup{}
+	running.Add(workers)
+	done := sync.WaitGroup{}
+	done.Add(workers)
+	for i := 0; i < workers; i++ {
+		q.Push(func() error {
+			defer done.Done()
+			running.Done()
+			running.Wait()
+			return nil
+		})
+	}
+	stopped := sync.WaitGroup{}
+	stopped.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer stopped.Done()
+			q.Run(stop)
+		}()
+	}
+	done.Wait()
+
+	// All the workers return once stopped.
+	close(stop)
+	stopped.Wait()
+}
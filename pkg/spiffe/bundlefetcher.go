@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spiffe
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// BundleFetcher periodically fetches the trust bundles of federated trust domains from their SPIFFE
+// bundle endpoints. The last bundle successfully fetched from an endpoint is kept when a fetch fails.
+type BundleFetcher struct {
+	endpoints         map[string]string
+	extraTrustedCerts []*x509.Certificate
+
+	mutex   sync.RWMutex
+	bundles map[string][]*x509.Certificate
+}
+
+// NewBundleFetcher returns a BundleFetcher for the endpoints, a map from the trust domains to the URLs of
+// their bundle endpoints. The endpoints are validated with the system cert pool and extraTrustedCerts.
+func NewBundleFetcher(endpoints map[string]string, extraTrustedCerts []*x509.Certificate) *BundleFetcher {
+	return &BundleFetcher{
+		endpoints:         endpoints,
+		extraTrustedCerts: extraTrustedCerts,
+		bundles:           map[string][]*x509.Certificate{},
+	}
+}
+
+// Fetch fetches the trust bundles from all endpoints. The returned error lists the endpoints that failed.
+func (f *BundleFetcher) Fetch() error {
+	var errs *multierror.Error
+	for trustDomain, endpoint := range f.endpoints {
+		bundles, err := RetrieveSpiffeBundleRootCerts(map[string]string{trustDomain: endpoint}, f.extraTrustedCerts)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		f.mutex.Lock()
+		f.bundles[trustDomain] = bundles[trustDomain]
+		f.mutex.Unlock()
+	}
+	return errs.ErrorOrNil()
+}
+
+// Run fetches the trust bundles every interval until stop is closed.
+func (f *BundleFetcher) Run(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := f.Fetch(); err != nil {
+			spiffeLog.Warnf("failed to fetch SPIFFE trust bundles: %v", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RootCerts returns the PEM-encoded root certs of all federated trust domains, ordered by trust domain.
+func (f *BundleFetcher) RootCerts() []byte {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	trustDomains := make([]string, 0, len(f.bundles))
+	for trustDomain := range f.bundles {
+		trustDomains = append(trustDomains, trustDomain)
+	}
+	sort.Strings(trustDomains)
+	var buf bytes.Buffer
+	for _, trustDomain := range trustDomains {
+		for _, cert := range f.bundles[trustDomain] {
+			_ = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,65 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spiffe
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBundleFetcher(t *testing.T) {
+	totalRetryTimeout = time.Millisecond * 50
+	var failing int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(validResponse))
+	})
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	fetcher := NewBundleFetcher(map[string]string{"partner.mesh": server.URL}, []*x509.Certificate{server.Certificate()})
+	if certs := fetcher.RootCerts(); len(certs) != 0 {
+		t.Errorf("expected no root certs before the first fetch, got %s", certs)
+	}
+	if err := fetcher.Fetch(); err != nil {
+		t.Fatalf("failed to fetch the trust bundles: %v", err)
+	}
+	rootCerts := fetcher.RootCerts()
+	block, rest := pem.Decode(rootCerts)
+	if block == nil || len(rest) != 0 {
+		t.Fatalf("expected 1 PEM-encoded root cert, got %s", rootCerts)
+	}
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		t.Errorf("failed to parse the root cert: %v", err)
+	}
+
+	// The bundle fetched last is kept when the endpoint fails.
+	atomic.StoreInt32(&failing, 1)
+	if err := fetcher.Fetch(); err == nil || !strings.Contains(err.Error(), "partner.mesh") {
+		t.Errorf("expected an error for the failing endpoint, got %v", err)
+	}
+	if certs := fetcher.RootCerts(); string(certs) != string(rootCerts) {
+		t.Errorf("expected the root certs to be kept after a failed fetch, got %s", certs)
+	}
+}
@@ -41,8 +41,9 @@ const (
 )
 
 var (
-	trustDomain      = defaultTrustDomain
-	trustDomainMutex sync.RWMutex
+	trustDomain        = defaultTrustDomain
+	trustDomainAliases []string
+	trustDomainMutex   sync.RWMutex
 
 	firstRetryBackOffTime = time.Millisecond * 50
 	totalRetryTimeout     = time.Second * 10
@@ -70,6 +71,91 @@ func GetTrustDomain() string {
 	return trustDomain
 }
 
+// SetTrustDomainAliases sets the trust domains whose identities are accepted as identities of the
+// trust domain, such as the previous trust domain during a trust domain migration.
+func SetTrustDomainAliases(aliases []string) {
+	normalized := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		normalized = append(normalized, strings.Replace(alias, "@", ".", -1))
+	}
+	trustDomainMutex.Lock()
+	trustDomainAliases = normalized
+	trustDomainMutex.Unlock()
+}
+
+// GetTrustDomainAliases returns the aliases of the trust domain.
+func GetTrustDomainAliases() []string {
+	trustDomainMutex.RLock()
+	defer trustDomainMutex.RUnlock()
+	return append([]string(nil), trustDomainAliases...)
+}
+
+// IsTrustedTrustDomain returns whether td is the trust domain or one of its aliases.
+func IsTrustedTrustDomain(td string) bool {
+	trustDomainMutex.RLock()
+	defer trustDomainMutex.RUnlock()
+	if td == trustDomain {
+		return true
+	}
+	for _, alias := range trustDomainAliases {
+		if td == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTrustDomain returns an error if td is not a valid SPIFFE trust domain, made of lowercase
+// letters, digits, dots, dashes and underscores.
+func ValidateTrustDomain(td string) error {
+	if td == "" {
+		return fmt.Errorf("trust domain is empty")
+	}
+	for _, c := range td {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			return fmt.Errorf("trust domain %q contains the invalid character %q", td, c)
+		}
+	}
+	return nil
+}
+
+// Identity is the SPIFFE identity of a Kubernetes service account.
+type Identity struct {
+	TrustDomain    string
+	Namespace      string
+	ServiceAccount string
+}
+
+func (i Identity) String() string {
+	return URIPrefix + i.TrustDomain + "/ns/" + i.Namespace + "/sa/" + i.ServiceAccount
+}
+
+// ParseIdentity parses a SPIFFE identity of the form spiffe://<trust domain>/ns/<namespace>/sa/<service account>.
+func ParseIdentity(uri string) (Identity, error) {
+	if !strings.HasPrefix(uri, URIPrefix) {
+		return Identity{}, fmt.Errorf("identity %q is not a SPIFFE URI", uri)
+	}
+	parts := strings.Split(uri[len(URIPrefix):], "/")
+	if len(parts) != 5 || parts[1] != "ns" || parts[3] != "sa" || parts[0] == "" || parts[2] == "" || parts[4] == "" {
+		return Identity{}, fmt.Errorf("identity %q is not of the form %s<trust domain>/ns/<namespace>/sa/<service account>",
+			uri, URIPrefix)
+	}
+	return Identity{TrustDomain: parts[0], Namespace: parts[2], ServiceAccount: parts[4]}, nil
+}
+
+// ValidateIdentity returns an error if uri is not the identity of a service account in the trust domain
+// or one of its aliases.
+func ValidateIdentity(uri string) error {
+	id, err := ParseIdentity(uri)
+	if err != nil {
+		return err
+	}
+	if !IsTrustedTrustDomain(id.TrustDomain) {
+		return fmt.Errorf("identity %q is outside of the trust domain %q and its aliases", uri, GetTrustDomain())
+	}
+	return nil
+}
+
 func DetermineTrustDomain(commandLineTrustDomain string, isKubernetes bool) string {
 	if len(commandLineTrustDomain) != 0 {
 		return commandLineTrustDomain
@@ -80,12 +166,17 @@ func DetermineTrustDomain(commandLineTrustDomain string, isKubernetes bool) stri
 	return ""
 }
 
-// GenSpiffeURI returns the formatted uri(SPIFFE format for now) for the certificate.
+// GenSpiffeURI returns the formatted uri(SPIFFE format for now) for the certificate. An error is returned
+// for an empty namespace or service account, or one with a '/', which would produce an identity
+// outside of the namespace.
 func GenSpiffeURI(ns, serviceAccount string) (string, error) {
 	var err error
 	if ns == "" || serviceAccount == "" {
 		err = fmt.Errorf(
 			"namespace or service account empty for SPIFFE uri ns=%v serviceAccount=%v", ns, serviceAccount)
+	} else if strings.Contains(ns, "/") || strings.Contains(serviceAccount, "/") {
+		err = fmt.Errorf(
+			"namespace or service account contains '/' for SPIFFE uri ns=%v serviceAccount=%v", ns, serviceAccount)
 	}
 	return URIPrefix + GetTrustDomain() + "/ns/" + ns + "/sa/" + serviceAccount, err
 }
@@ -116,6 +207,16 @@ func GenCustomSpiffe(identity string) string {
 func RetrieveSpiffeBundleRootCertsFromStringInput(inputString string, extraTrustedCerts []*x509.Certificate) (
 	map[string][]*x509.Certificate, error) {
 	spiffeLog.Infof("Processing SPIFFE bundle configuration: %v", inputString)
+	config, err := ParseBundleEndpoints(inputString)
+	if err != nil {
+		return nil, err
+	}
+	return RetrieveSpiffeBundleRootCerts(config, extraTrustedCerts)
+}
+
+// ParseBundleEndpoints parses SPIFFE bundle endpoints in the format of foo|URL1||bar|URL2 into a map from
+// the trust domains to the URLs.
+func ParseBundleEndpoints(inputString string) (map[string]string, error) {
 	config := make(map[string]string)
 	tuples := strings.Split(inputString, "||")
 	for _, tuple := range tuples {
@@ -127,7 +228,7 @@ func RetrieveSpiffeBundleRootCertsFromStringInput(inputString string, extraTrust
 		endpoint := items[1]
 		config[trustDomain] = endpoint
 	}
-	return RetrieveSpiffeBundleRootCerts(config, extraTrustedCerts)
+	return config, nil
 }
 
 // RetrieveSpiffeBundleRootCerts retrieves the trusted CA certificates from a list of SPIFFE bundle endpoints.
@@ -18,6 +18,7 @@ import (
 	"crypto/x509"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -114,6 +115,12 @@ func TestGenSpiffeURI(t *testing.T) {
 			trustDomain:   defaultTrustDomain,
 			expectedError: "namespace or service account empty for SPIFFE uri",
 		},
+		{
+			namespace:      "foo/sa/admin/ns/bar",
+			serviceAccount: "sa",
+			trustDomain:    defaultTrustDomain,
+			expectedError:  "namespace or service account contains '/'",
+		},
 		{
 			namespace:      "namespace-foo",
 			serviceAccount: "service-bar",
@@ -180,6 +187,69 @@ func TestGetSetTrustDomain(t *testing.T) {
 	}
 }
 
+func TestValidateTrustDomain(t *testing.T) {
+	for td, valid := range map[string]bool{
+		"cluster.local":        true,
+		"my-mesh_1.example.io": true,
+		"":                     false,
+		"Cluster.local":        false,
+		"cluster.local/ns":     false,
+		"td:8080":              false,
+	} {
+		if err := ValidateTrustDomain(td); (err == nil) != valid {
+			t.Errorf("ValidateTrustDomain(%q): got error %v, want valid %v", td, err, valid)
+		}
+	}
+}
+
+func TestParseIdentity(t *testing.T) {
+	id, err := ParseIdentity("spiffe://cluster.local/ns/foo/sa/bar")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := Identity{TrustDomain: "cluster.local", Namespace: "foo", ServiceAccount: "bar"}
+	if id != expected {
+		t.Errorf("got identity %+v, want %+v", id, expected)
+	}
+	if id.String() != "spiffe://cluster.local/ns/foo/sa/bar" {
+		t.Errorf("unexpected identity string %s", id)
+	}
+	for _, uri := range []string{
+		"cluster.local/ns/foo/sa/bar",
+		"spiffe://cluster.local/foo",
+		"spiffe://cluster.local/ns//sa/bar",
+		"spiffe://cluster.local/ns/foo/sa/bar/extra",
+		"spiffe:///ns/foo/sa/bar",
+	} {
+		if _, err := ParseIdentity(uri); err == nil {
+			t.Errorf("ParseIdentity(%q): expected an error", uri)
+		}
+	}
+}
+
+func TestValidateIdentity(t *testing.T) {
+	oldTrustDomain := GetTrustDomain()
+	defer SetTrustDomain(oldTrustDomain)
+	defer SetTrustDomainAliases(nil)
+
+	SetTrustDomain("new.td")
+	SetTrustDomainAliases([]string{"old.td", "legacy@td"})
+	if aliases := GetTrustDomainAliases(); !reflect.DeepEqual(aliases, []string{"old.td", "legacy.td"}) {
+		t.Errorf("unexpected trust domain aliases %v", aliases)
+	}
+	for uri, valid := range map[string]bool{
+		"spiffe://new.td/ns/foo/sa/bar":    true,
+		"spiffe://old.td/ns/foo/sa/bar":    true,
+		"spiffe://legacy.td/ns/foo/sa/bar": true,
+		"spiffe://other.td/ns/foo/sa/bar":  false,
+		"spiffe://new.td/custom":           false,
+	} {
+		if err := ValidateIdentity(uri); (err == nil) != valid {
+			t.Errorf("ValidateIdentity(%q): got error %v, want valid %v", uri, err, valid)
+		}
+	}
+}
+
 func TestMustGenSpiffeURI(t *testing.T) {
 	if nonsense := MustGenSpiffeURI("", ""); nonsense != "spiffe://cluster.local/ns//sa/" {
 		t.Errorf("Unexpected spiffe URI for empty namespace and service account: %s", nonsense)
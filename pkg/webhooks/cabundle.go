@@ -0,0 +1,152 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"k8s.io/api/admissionregistration/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/pkg/log"
+)
+
+// CABundleReconciler sets the caBundle of every webhook in a set of mutating and validating
+// webhook configurations, keeping it in sync with a CA bundle that changes over time, such as
+// the root cert of a CA whose root is rotated.
+type CABundleReconciler struct {
+	client            kubernetes.Interface
+	mutatingConfigs   []string
+	validatingConfigs []string
+	caBundle          func() []byte
+}
+
+// NewCABundleReconciler creates a reconciler of the named webhook configurations. caBundle returns
+// the current CA bundle.
+func NewCABundleReconciler(client kubernetes.Interface, mutatingConfigs, validatingConfigs []string,
+	caBundle func() []byte) *CABundleReconciler {
+	return &CABundleReconciler{
+		client:            client,
+		mutatingConfigs:   mutatingConfigs,
+		validatingConfigs: validatingConfigs,
+		caBundle:          caBundle,
+	}
+}
+
+// Run reconciles the webhook configurations every interval until stopCh is closed. Checking
+// periodically also reverts edits of the caBundle made by others.
+func (r *CABundleReconciler) Run(stopCh <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.Reconcile()
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile updates the webhook configurations whose caBundle differs from the current CA bundle.
+func (r *CABundleReconciler) Reconcile() {
+	caBundle := r.caBundle()
+	if len(caBundle) == 0 {
+		return
+	}
+	for _, name := range r.mutatingConfigs {
+		if err := r.reconcileMutating(name, caBundle); err != nil {
+			log.Errorf("failed to patch the caBundle of MutatingWebhookConfiguration %s: %v", name, err)
+		}
+	}
+	for _, name := range r.validatingConfigs {
+		if err := r.reconcileValidating(name, caBundle); err != nil {
+			log.Errorf("failed to update the caBundle of ValidatingWebhookConfiguration %s: %v", name, err)
+		}
+	}
+}
+
+func (r *CABundleReconciler) reconcileMutating(name string, caBundle []byte) error {
+	client := r.client.AdmissionregistrationV1beta1().MutatingWebhookConfigurations()
+	config, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	prev, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for i := range config.Webhooks {
+		if !bytes.Equal(config.Webhooks[i].ClientConfig.CABundle, caBundle) {
+			config.Webhooks[i].ClientConfig.CABundle = caBundle
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	curr, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	patch, err := strategicpatch.CreateTwoWayMergePatch(prev, curr, v1beta1.MutatingWebhookConfiguration{})
+	if err != nil {
+		return err
+	}
+	if _, err = client.Patch(context.TODO(), name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return err
+	}
+	log.Infof("Patched the caBundle of MutatingWebhookConfiguration %s", name)
+	return nil
+}
+
+func (r *CABundleReconciler) reconcileValidating(name string, caBundle []byte) error {
+	client := r.client.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations()
+	config, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	changed := false
+	for i := range config.Webhooks {
+		if !bytes.Equal(config.Webhooks[i].ClientConfig.CABundle, caBundle) {
+			config.Webhooks[i].ClientConfig.CABundle = caBundle
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	// The validating webhook configurations are updated rather than patched, like the validation
+	// webhook controller does, istiod is not allowed to patch them.
+	if _, err = client.Update(context.TODO(), config, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	log.Infof("Updated the caBundle of ValidatingWebhookConfiguration %s", name)
+	return nil
+}
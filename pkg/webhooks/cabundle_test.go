@@ -0,0 +1,100 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCABundleReconciler(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&admissionregistrationv1beta1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "injector"},
+			Webhooks: []admissionregistrationv1beta1.MutatingWebhook{
+				{Name: "sidecar-injector.istio.io"},
+				{Name: "other.istio.io"},
+			},
+		},
+		&admissionregistrationv1beta1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "galley"},
+			Webhooks: []admissionregistrationv1beta1.ValidatingWebhook{
+				{Name: "validation.istio.io"},
+			},
+		},
+	)
+	caBundle := []byte("root-1")
+	r := NewCABundleReconciler(client, []string{"injector", "missing"}, []string{"galley"},
+		func() []byte { return caBundle })
+
+	check := func(expected []byte) {
+		t.Helper()
+		mutating, err := client.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Get(
+			context.TODO(), "injector", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get the mutating webhook configuration: %v", err)
+		}
+		for _, w := range mutating.Webhooks {
+			if !bytes.Equal(w.ClientConfig.CABundle, expected) {
+				t.Errorf("webhook %s: got caBundle %q, want %q", w.Name, w.ClientConfig.CABundle, expected)
+			}
+		}
+		validating, err := client.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations().Get(
+			context.TODO(), "galley", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get the validating webhook configuration: %v", err)
+		}
+		for _, w := range validating.Webhooks {
+			if !bytes.Equal(w.ClientConfig.CABundle, expected) {
+				t.Errorf("webhook %s: got caBundle %q, want %q", w.Name, w.ClientConfig.CABundle, expected)
+			}
+		}
+	}
+	// The mutating configurations are patched and the validating ones updated.
+	countPatches := func() int {
+		n := 0
+		for _, a := range client.Actions() {
+			switch a.(type) {
+			case k8stesting.PatchAction, k8stesting.UpdateAction:
+				n++
+			}
+		}
+		return n
+	}
+
+	r.Reconcile()
+	check(caBundle)
+	if n := countPatches(); n != 2 {
+		t.Errorf("expected 2 patches, got %d", n)
+	}
+
+	r.Reconcile()
+	if n := countPatches(); n != 2 {
+		t.Errorf("expected up-to-date configurations not to be patched, got %d patches", n)
+	}
+
+	caBundle = []byte("root-2")
+	r.Reconcile()
+	check(caBundle)
+	if n := countPatches(); n != 4 {
+		t.Errorf("expected 4 patches after the root rotation, got %d", n)
+	}
+}
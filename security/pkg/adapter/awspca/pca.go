@@ -0,0 +1,281 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package awspca signs workload certificates with AWS Certificate Manager Private CA.
+package awspca
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/acmpca"
+
+	pkica "istio.io/istio/security/pkg/pki/ca"
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const (
+	defaultPollInterval = 200 * time.Millisecond
+	defaultTimeout      = 10 * time.Second
+)
+
+// Config configures a CA.
+type Config struct {
+	// Region is the AWS region of the private CA.
+	Region string
+	// CAArn is the ARN of the private CA issuing the certs.
+	CAArn string
+	// TemplateArn is the ARN of the template of the issued certs. If empty, the private CA issues
+	// end-entity certs.
+	TemplateArn string
+	// SigningAlgorithm is the algorithm the private CA signs with, SHA256WITHRSA by default. It must
+	// match the key type of the private CA.
+	SigningAlgorithm string
+
+	// DefaultTTL is the TTL of certs requested without a TTL. MaxTTL is the max TTL of issued certs.
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+	// Timeout is the max time to wait for a cert to be issued, 10s by default.
+	Timeout time.Duration
+}
+
+// pcaClient is the subset of the ACM PCA client used by the CA.
+type pcaClient interface {
+	IssueCertificate(*acmpca.IssueCertificateInput) (*acmpca.IssueCertificateOutput, error)
+	GetCertificate(*acmpca.GetCertificateInput) (*acmpca.GetCertificateOutput, error)
+	GetCertificateAuthorityCertificate(*acmpca.GetCertificateAuthorityCertificateInput) (
+		*acmpca.GetCertificateAuthorityCertificateOutput, error)
+}
+
+// CA signs certificates with an ACM private CA. It implements the CertificateAuthority interface of
+// the CA server.
+type CA struct {
+	config       Config
+	client       pcaClient
+	pollInterval time.Duration
+	now          func() time.Time
+
+	keyCertBundle util.KeyCertBundle
+}
+
+// NewCA returns a CA using the default AWS credential chain, e.g. the IAM role of the service
+// account on EKS. It reads the cert chain of the private CA.
+func NewCA(config Config) (*CA, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(config.Region)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create an AWS session: %v", err)
+	}
+	return newCA(config, acmpca.New(sess))
+}
+
+func newCA(config Config, client pcaClient) (*CA, error) {
+	if config.CAArn == "" {
+		return nil, errors.New("the ARN of the private CA must be configured")
+	}
+	if config.SigningAlgorithm == "" {
+		config.SigningAlgorithm = acmpca.SigningAlgorithmSha256withrsa
+	}
+	if !isSupportedSigningAlgorithm(config.SigningAlgorithm) {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", config.SigningAlgorithm)
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	ca := &CA{
+		config:       config,
+		client:       client,
+		pollInterval: defaultPollInterval,
+		now:          time.Now,
+	}
+	if err := ca.loadCACert(); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// Sign takes a PEM-encoded CSR, subject IDs and lifetime, and returns a cert signed by the private CA.
+func (ca *CA) Sign(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error) {
+	result, err := ca.SignWithResult(csrPEM, subjectIDs, ttl, forCA)
+	if err != nil {
+		return nil, err
+	}
+	return result.Leaf, nil
+}
+
+// SignWithCertChain is similar to Sign but returns the leaf cert and the entire cert chain.
+func (ca *CA) SignWithCertChain(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error) {
+	result, err := ca.SignWithResult(csrPEM, subjectIDs, ttl, forCA)
+	if err != nil {
+		return nil, err
+	}
+	return append(result.Leaf, result.CertChain...), nil
+}
+
+// SignWithResult issues a certificate from the CSR, and polls the private CA until it is issued.
+// The CSR must only request subjectIDs, since the private CA issues the SANs of the CSR.
+func (ca *CA) SignWithResult(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) (
+	*pkica.SignResult, error) {
+	if forCA {
+		return nil, caerror.NewError(caerror.CertGenError, errors.New("the ACM PCA CA cannot sign CA certs"))
+	}
+	if ttl <= 0 {
+		ttl = ca.config.DefaultTTL
+	}
+	if ttl <= 0 {
+		return nil, caerror.NewError(caerror.TTLError, errors.New("no TTL is requested"))
+	}
+	if ca.config.MaxTTL > 0 && ttl > ca.config.MaxTTL {
+		return nil, caerror.NewError(caerror.TTLError, fmt.Errorf(
+			"requested TTL %s is greater than the max allowed TTL %s", ttl, ca.config.MaxTTL))
+	}
+	csr, err := util.ParsePemEncodedCSR(csrPEM)
+	if err != nil {
+		return nil, caerror.NewError(caerror.CSRError, err)
+	}
+	if err := util.CheckCSRSANs(csr, subjectIDs); err != nil {
+		return nil, caerror.NewError(caerror.SANError, err)
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, caerror.NewError(caerror.CertGenError, err)
+	}
+	input := &acmpca.IssueCertificateInput{
+		CertificateAuthorityArn: aws.String(ca.config.CAArn),
+		Csr:                     csrPEM,
+		IdempotencyToken:        aws.String(hex.EncodeToString(token)),
+		SigningAlgorithm:        aws.String(ca.config.SigningAlgorithm),
+		Validity: &acmpca.Validity{
+			Type:  aws.String(acmpca.ValidityPeriodTypeAbsolute),
+			Value: aws.Int64(ca.now().Add(ttl).Unix()),
+		},
+	}
+	if ca.config.TemplateArn != "" {
+		input.TemplateArn = aws.String(ca.config.TemplateArn)
+	}
+	issued, err := ca.client.IssueCertificate(input)
+	if err != nil {
+		return nil, toCAError("failed to issue the certificate", err)
+	}
+	certPEM, chainPEM, err := ca.waitForCertificate(aws.StringValue(issued.CertificateArn))
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := util.ParsePemEncodedCertificate([]byte(certPEM))
+	if err != nil {
+		return nil, caerror.NewError(caerror.CertGenError, fmt.Errorf("invalid cert issued by ACM PCA: %v", err))
+	}
+	intermediates, roots, err := util.SplitRootCerts([]byte(chainPEM))
+	if err != nil {
+		return nil, caerror.NewError(caerror.CertGenError, fmt.Errorf("invalid cert chain issued by ACM PCA: %v", err))
+	}
+	if len(roots) == 0 {
+		roots = ca.keyCertBundle.GetRootCertPem()
+	}
+	return &pkica.SignResult{
+		Leaf:         []byte(strings.TrimSpace(certPEM) + "\n"),
+		CertChain:    intermediates,
+		RootCerts:    roots,
+		NotAfter:     cert.NotAfter,
+		SerialNumber: cert.SerialNumber,
+	}, nil
+}
+
+// GetCAKeyCertBundle returns a KeyCertBundle with the cert chain of the private CA, without a
+// private key.
+func (ca *CA) GetCAKeyCertBundle() util.KeyCertBundle {
+	return ca.keyCertBundle
+}
+
+// waitForCertificate polls the private CA until the cert is issued, and returns the cert and its chain.
+func (ca *CA) waitForCertificate(certArn string) (certPEM, chainPEM string, err error) {
+	deadline := ca.now().Add(ca.config.Timeout)
+	for {
+		out, err := ca.client.GetCertificate(&acmpca.GetCertificateInput{
+			CertificateArn:          aws.String(certArn),
+			CertificateAuthorityArn: aws.String(ca.config.CAArn),
+		})
+		if err == nil {
+			return aws.StringValue(out.Certificate), aws.StringValue(out.CertificateChain), nil
+		}
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != acmpca.ErrCodeRequestInProgressException {
+			return "", "", toCAError("failed to get the issued certificate", err)
+		}
+		if ca.now().After(deadline) {
+			return "", "", caerror.NewError(caerror.CANotReady, fmt.Errorf(
+				"the certificate %s is not issued after %s", certArn, ca.config.Timeout))
+		}
+		time.Sleep(ca.pollInterval)
+	}
+}
+
+// loadCACert reads the cert chain of the private CA.
+func (ca *CA) loadCACert() error {
+	out, err := ca.client.GetCertificateAuthorityCertificate(&acmpca.GetCertificateAuthorityCertificateInput{
+		CertificateAuthorityArn: aws.String(ca.config.CAArn),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get the cert of the private CA %s: %v", ca.config.CAArn, err)
+	}
+	caCert := []byte(aws.StringValue(out.Certificate))
+	intermediates, roots, err := util.SplitRootCerts([]byte(aws.StringValue(out.CertificateChain)))
+	if err != nil {
+		return fmt.Errorf("invalid cert chain of the private CA %s: %v", ca.config.CAArn, err)
+	}
+	certChain := append(append([]byte{}, caCert...), intermediates...)
+	if len(roots) == 0 {
+		// A root private CA has no cert chain.
+		roots = caCert
+		certChain = nil
+	}
+	bundle, err := util.NewVerifiedCertBundleFromPem(caCert, certChain, roots)
+	if err != nil {
+		return fmt.Errorf("invalid cert of the private CA %s: %v", ca.config.CAArn, err)
+	}
+	ca.keyCertBundle = bundle
+	return nil
+}
+
+// toCAError converts an ACM PCA error to a CA error.
+func toCAError(msg string, err error) error {
+	t := caerror.CertGenError
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case acmpca.ErrCodeMalformedCSRException:
+			t = caerror.CSRError
+		case acmpca.ErrCodeLimitExceededException, "ThrottlingException":
+			t = caerror.QuotaExceededError
+		}
+	}
+	return caerror.NewError(t, fmt.Errorf("%s: %v", msg, err))
+}
+
+func isSupportedSigningAlgorithm(algorithm string) bool {
+	switch algorithm {
+	case acmpca.SigningAlgorithmSha256withrsa, acmpca.SigningAlgorithmSha384withrsa,
+		acmpca.SigningAlgorithmSha512withrsa, acmpca.SigningAlgorithmSha256withecdsa,
+		acmpca.SigningAlgorithmSha384withecdsa, acmpca.SigningAlgorithmSha512withecdsa:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awspca
+
+import (
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/acmpca"
+
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const caArn = "arn:aws:acm-pca:us-east-1:123456789012:certificate-authority/istio"
+
+// fakePCA is a root private CA issuing certs after a number of polls.
+type fakePCA struct {
+	t       *testing.T
+	rootPEM []byte
+	signer  util.KeyCertBundle
+
+	pendingPolls int
+	issueErr     error
+	inputs       []*acmpca.IssueCertificateInput
+	issued       map[string][]byte
+}
+
+func newFakePCA(t *testing.T) *fakePCA {
+	certPEM, keyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          "pca",
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the root CA: %v", err)
+	}
+	signer, err := util.NewVerifiedKeyCertBundleFromPem(certPEM, keyPEM, nil, certPEM)
+	if err != nil {
+		t.Fatalf("failed to create the root CA bundle: %v", err)
+	}
+	return &fakePCA{t: t, rootPEM: certPEM, signer: signer, issued: map[string][]byte{}}
+}
+
+func (f *fakePCA) IssueCertificate(in *acmpca.IssueCertificateInput) (*acmpca.IssueCertificateOutput, error) {
+	if f.issueErr != nil {
+		return nil, f.issueErr
+	}
+	f.inputs = append(f.inputs, in)
+	csr, err := util.ParsePemEncodedCSR(in.Csr)
+	if err != nil {
+		return nil, awserr.New(acmpca.ErrCodeMalformedCSRException, "malformed CSR", nil)
+	}
+	ttl := time.Until(time.Unix(aws.Int64Value(in.Validity.Value), 0))
+	signingCert, signingKey, _, _ := f.signer.GetAll()
+	var sans []string
+	for _, u := range csr.URIs {
+		sans = append(sans, u.String())
+	}
+	der, err := util.GenCertFromCSR(csr, signingCert, csr.PublicKey, *signingKey, sans, ttl, false)
+	if err != nil {
+		f.t.Fatalf("failed to sign: %v", err)
+	}
+	arn := caArn + "/certificate/" + aws.StringValue(in.IdempotencyToken)
+	f.issued[arn] = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return &acmpca.IssueCertificateOutput{CertificateArn: aws.String(arn)}, nil
+}
+
+func (f *fakePCA) GetCertificate(in *acmpca.GetCertificateInput) (*acmpca.GetCertificateOutput, error) {
+	if f.pendingPolls > 0 {
+		f.pendingPolls--
+		return nil, awserr.New(acmpca.ErrCodeRequestInProgressException, "in progress", nil)
+	}
+	cert, ok := f.issued[aws.StringValue(in.CertificateArn)]
+	if !ok {
+		return nil, awserr.New(acmpca.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	return &acmpca.GetCertificateOutput{
+		Certificate:      aws.String(string(cert)),
+		CertificateChain: aws.String(string(f.rootPEM)),
+	}, nil
+}
+
+func (f *fakePCA) GetCertificateAuthorityCertificate(in *acmpca.GetCertificateAuthorityCertificateInput) (
+	*acmpca.GetCertificateAuthorityCertificateOutput, error) {
+	if aws.StringValue(in.CertificateAuthorityArn) != caArn {
+		return nil, awserr.New(acmpca.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	return &acmpca.GetCertificateAuthorityCertificateOutput{Certificate: aws.String(string(f.rootPEM))}, nil
+}
+
+func genCSR(t *testing.T, host string) []byte {
+	csr, _, err := util.GenCSR(util.CertOptions{Host: host, RSAKeySize: 2048})
+	if err != nil {
+		t.Fatalf("failed to generate a CSR: %v", err)
+	}
+	return csr
+}
+
+func TestCASign(t *testing.T) {
+	pca := newFakePCA(t)
+	ca, err := newCA(Config{
+		CAArn:       caArn,
+		TemplateArn: "arn:aws:acm-pca:::template/EndEntityCertificate/V1",
+		DefaultTTL:  time.Hour,
+		MaxTTL:      2 * time.Hour,
+	}, pca)
+	if err != nil {
+		t.Fatalf("failed to create the ACM PCA CA: %v", err)
+	}
+	ca.pollInterval = time.Millisecond
+	if root := ca.GetCAKeyCertBundle().GetRootCertPem(); string(root) != string(pca.rootPEM) {
+		t.Errorf("unexpected root cert %q", root)
+	}
+
+	pca.pendingPolls = 2
+	id := "spiffe://cluster.local/ns/default/sa/foo"
+	result, err := ca.SignWithResult(genCSR(t, id), []string{id}, 0, false)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(result.Leaf)
+	if err != nil {
+		t.Fatalf("invalid leaf cert: %v", err)
+	}
+	if len(cert.URIs) != 1 || cert.URIs[0].String() != id {
+		t.Errorf("unexpected SANs %v", cert.URIs)
+	}
+	if string(result.RootCerts) != string(pca.rootPEM) || len(result.CertChain) != 0 {
+		t.Errorf("unexpected root certs %q and cert chain %q", result.RootCerts, result.CertChain)
+	}
+	if pca.pendingPolls != 0 {
+		t.Errorf("expected the certificate to be polled until issued")
+	}
+	in := pca.inputs[0]
+	if aws.StringValue(in.SigningAlgorithm) != acmpca.SigningAlgorithmSha256withrsa ||
+		aws.StringValue(in.TemplateArn) != "arn:aws:acm-pca:::template/EndEntityCertificate/V1" ||
+		aws.StringValue(in.Validity.Type) != acmpca.ValidityPeriodTypeAbsolute {
+		t.Errorf("unexpected issue request %v", in)
+	}
+	if d := time.Until(time.Unix(aws.Int64Value(in.Validity.Value), 0)); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expected the default TTL to be requested, got %s", d)
+	}
+}
+
+func TestCASignErrors(t *testing.T) {
+	pca := newFakePCA(t)
+	ca, err := newCA(Config{CAArn: caArn, MaxTTL: time.Hour, Timeout: 10 * time.Millisecond}, pca)
+	if err != nil {
+		t.Fatalf("failed to create the ACM PCA CA: %v", err)
+	}
+	ca.pollInterval = time.Millisecond
+	id := "spiffe://cluster.local/ns/default/sa/foo"
+	testCases := map[string]struct {
+		csr          []byte
+		subjectIDs   []string
+		ttl          time.Duration
+		forCA        bool
+		issueErr     error
+		pendingPolls int
+		errType      string
+	}{
+		"CA cert": {
+			csr: genCSR(t, id), subjectIDs: []string{id}, ttl: time.Minute, forCA: true, errType: "CERT_GEN_ERROR",
+		},
+		"no TTL": {
+			csr: genCSR(t, id), subjectIDs: []string{id}, errType: "TTL_ERROR",
+		},
+		"TTL too long": {
+			csr: genCSR(t, id), subjectIDs: []string{id}, ttl: 2 * time.Hour, errType: "TTL_ERROR",
+		},
+		"SAN of another identity": {
+			csr:        genCSR(t, "spiffe://cluster.local/ns/default/sa/bar"),
+			subjectIDs: []string{id},
+			ttl:        time.Minute,
+			errType:    "SAN_ERROR",
+		},
+		"throttled": {
+			csr:        genCSR(t, id),
+			subjectIDs: []string{id},
+			ttl:        time.Minute,
+			issueErr:   awserr.New(acmpca.ErrCodeLimitExceededException, "limit exceeded", nil),
+			errType:    "QUOTA_EXCEEDED",
+		},
+		"not issued in time": {
+			csr: genCSR(t, id), subjectIDs: []string{id}, ttl: time.Minute, pendingPolls: 1000, errType: "CA_NOT_READY",
+		},
+	}
+	for name, tc := range testCases {
+		pca.issueErr = tc.issueErr
+		pca.pendingPolls = tc.pendingPolls
+		_, err := ca.Sign(tc.csr, tc.subjectIDs, tc.ttl, tc.forCA)
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		if caErr, ok := err.(*caerror.Error); !ok || caErr.ErrorType() != tc.errType {
+			t.Errorf("%s: got error %v, want error type %s", name, err, tc.errType)
+		}
+	}
+}
+
+func TestNewCAErrors(t *testing.T) {
+	pca := newFakePCA(t)
+	for name, config := range map[string]Config{
+		"no CA ARN":         {},
+		"unknown CA":        {CAArn: "arn:aws:acm-pca:us-east-1:123456789012:certificate-authority/unknown"},
+		"invalid algorithm": {CAArn: caArn, SigningAlgorithm: "MD5WITHRSA"},
+	} {
+		if _, err := newCA(config, pca); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
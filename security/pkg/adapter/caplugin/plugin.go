@@ -0,0 +1,244 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package caplugin signs workload certificates with an out-of-process CA plugin implementing
+// the CAPlugin gRPC service.
+package caplugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	pkica "istio.io/istio/security/pkg/pki/ca"
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+	pb "istio.io/istio/security/proto/caplugin/v1"
+	"istio.io/pkg/log"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	unixPrefix     = "unix://"
+)
+
+var pluginLog = log.RegisterScope("caplugin", "CA plugin log", 0)
+
+// CA signs certificates with a CA plugin. It implements the CertificateAuthority interface of
+// the CA server.
+type CA struct {
+	address string
+	conn    *grpc.ClientConn
+	client  pb.CAPluginClient
+	health  healthpb.HealthClient
+	timeout time.Duration
+
+	// mutex guards keyCertBundle, which is refreshed by Run.
+	mutex         sync.RWMutex
+	keyCertBundle util.KeyCertBundle
+}
+
+// NewCA connects to the CA plugin at address, either host:port or unix:///path for a Unix
+// domain socket, and reads its trust bundle.
+func NewCA(address string, timeout time.Duration, opts ...grpc.DialOption) (*CA, error) {
+	if address == "" {
+		return nil, errors.New("no CA plugin address is configured")
+	}
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	target := address
+	if strings.HasPrefix(address, unixPrefix) {
+		path := strings.TrimPrefix(address, unixPrefix)
+		target = path
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}))
+	}
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the CA plugin at %s: %v", address, err)
+	}
+	ca := &CA{
+		address: address,
+		conn:    conn,
+		client:  pb.NewCAPluginClient(conn),
+		health:  healthpb.NewHealthClient(conn),
+		timeout: timeout,
+	}
+	if err := ca.loadTrustBundle(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return ca, nil
+}
+
+// Run refreshes the trust bundle of the plugin every interval until stopCh is closed, and then
+// closes the connection to the plugin.
+func (ca *CA) Run(stopCh <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			_ = ca.conn.Close()
+			return
+		case <-ticker.C:
+			if err := ca.loadTrustBundle(); err != nil {
+				pluginLog.Errorf("failed to refresh the trust bundle of the CA plugin: %v", err)
+			}
+		}
+	}
+}
+
+// Healthy returns an error unless the plugin reports that it is serving.
+func (ca *CA) Healthy() error {
+	ctx, cancel := context.WithTimeout(context.Background(), ca.timeout)
+	defer cancel()
+	resp, err := ca.health.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return fmt.Errorf("health check of the CA plugin at %s failed: %v", ca.address, err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("the CA plugin at %s is %s", ca.address, resp.Status)
+	}
+	return nil
+}
+
+// Sign takes a PEM-encoded CSR, subject IDs and lifetime, and returns a cert signed by the plugin.
+func (ca *CA) Sign(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error) {
+	result, err := ca.SignWithResult(csrPEM, subjectIDs, ttl, forCA)
+	if err != nil {
+		return nil, err
+	}
+	return result.Leaf, nil
+}
+
+// SignWithCertChain is similar to Sign but returns the leaf cert and the entire cert chain.
+func (ca *CA) SignWithCertChain(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error) {
+	result, err := ca.SignWithResult(csrPEM, subjectIDs, ttl, forCA)
+	if err != nil {
+		return nil, err
+	}
+	return append(result.Leaf, result.CertChain...), nil
+}
+
+// SignWithResult sends the CSR to the plugin. The SANs of the issued cert must be subjectIDs.
+func (ca *CA) SignWithResult(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) (
+	*pkica.SignResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ca.timeout)
+	defer cancel()
+	resp, err := ca.client.Sign(ctx, &pb.SignRequest{
+		Csr:        string(csrPEM),
+		SubjectIds: subjectIDs,
+		TtlSeconds: int64(ttl.Seconds()),
+		ForCa:      forCA,
+	})
+	if err != nil {
+		return nil, toCAError(err)
+	}
+	cert, err := util.ParsePemEncodedCertificate([]byte(resp.Leaf))
+	if err != nil {
+		return nil, caerror.NewError(caerror.CertGenError, fmt.Errorf("invalid cert issued by the CA plugin: %v", err))
+	}
+	ids, err := util.ExtractIDs(cert.Extensions)
+	if err != nil {
+		return nil, caerror.NewError(caerror.CertGenError, fmt.Errorf("invalid cert issued by the CA plugin: %v", err))
+	}
+	allowed := map[string]bool{}
+	for _, id := range subjectIDs {
+		allowed[id] = true
+	}
+	for _, id := range ids {
+		if !allowed[id] {
+			return nil, caerror.NewError(caerror.CertGenError, fmt.Errorf(
+				"the CA plugin issued SAN %q, which was not requested", id))
+		}
+	}
+
+	roots := joinPEM(resp.Roots)
+	if len(roots) == 0 {
+		roots = ca.GetCAKeyCertBundle().GetRootCertPem()
+	}
+	return &pkica.SignResult{
+		Leaf:         joinPEM([]string{resp.Leaf}),
+		CertChain:    joinPEM(resp.Intermediates),
+		RootCerts:    roots,
+		NotAfter:     cert.NotAfter,
+		SerialNumber: cert.SerialNumber,
+	}, nil
+}
+
+// GetCAKeyCertBundle returns a KeyCertBundle with the trust bundle of the plugin, without a
+// private key.
+func (ca *CA) GetCAKeyCertBundle() util.KeyCertBundle {
+	ca.mutex.RLock()
+	defer ca.mutex.RUnlock()
+	return ca.keyCertBundle
+}
+
+func (ca *CA) loadTrustBundle() error {
+	ctx, cancel := context.WithTimeout(context.Background(), ca.timeout)
+	defer cancel()
+	resp, err := ca.client.GetTrustBundle(ctx, &pb.GetTrustBundleRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to get the trust bundle of the CA plugin at %s: %v", ca.address, err)
+	}
+	bundle, err := util.NewVerifiedCertBundleFromPem([]byte(resp.CaCert), joinPEM(resp.Intermediates),
+		joinPEM(resp.Roots))
+	if err != nil {
+		return fmt.Errorf("invalid trust bundle of the CA plugin at %s: %v", ca.address, err)
+	}
+	ca.mutex.Lock()
+	ca.keyCertBundle = bundle
+	ca.mutex.Unlock()
+	return nil
+}
+
+// toCAError converts a gRPC error of the plugin to a CA error.
+func toCAError(err error) error {
+	t := caerror.CertGenError
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		t = caerror.CSRError
+	case codes.PermissionDenied:
+		t = caerror.AuthorizationError
+	case codes.ResourceExhausted:
+		t = caerror.QuotaExceededError
+	case codes.Unavailable, codes.DeadlineExceeded:
+		t = caerror.BackendError
+	}
+	return caerror.NewError(t, fmt.Errorf("the CA plugin failed to sign: %v", err))
+}
+
+// joinPEM concatenates PEM-encoded certs, each ending with a newline.
+func joinPEM(certs []string) []byte {
+	var out []byte
+	for _, c := range certs {
+		if c = strings.TrimSpace(c); c != "" {
+			out = append(out, c+"\n"...)
+		}
+	}
+	return out
+}
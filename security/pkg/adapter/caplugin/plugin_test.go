@@ -0,0 +1,226 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caplugin
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+	pb "istio.io/istio/security/proto/caplugin/v1"
+)
+
+// fakePlugin is a root CA served over the CAPlugin protocol.
+type fakePlugin struct {
+	pb.UnimplementedCAPluginServer
+
+	t       *testing.T
+	rootPEM []byte
+	signer  util.KeyCertBundle
+
+	// extraSAN is added to every issued cert, to simulate a misbehaving plugin.
+	extraSAN string
+	signErr  error
+	requests []*pb.SignRequest
+}
+
+func (f *fakePlugin) Sign(_ context.Context, req *pb.SignRequest) (*pb.SignResponse, error) {
+	if f.signErr != nil {
+		return nil, f.signErr
+	}
+	f.requests = append(f.requests, req)
+	csr, err := util.ParsePemEncodedCSR([]byte(req.Csr))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	sans := req.SubjectIds
+	if f.extraSAN != "" {
+		sans = append(sans, f.extraSAN)
+	}
+	signingCert, signingKey, _, _ := f.signer.GetAll()
+	der, err := util.GenCertFromCSR(csr, signingCert, csr.PublicKey, *signingKey, sans,
+		time.Duration(req.TtlSeconds)*time.Second, req.ForCa)
+	if err != nil {
+		f.t.Fatalf("failed to sign: %v", err)
+	}
+	return &pb.SignResponse{
+		Leaf:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		Roots: []string{string(f.rootPEM)},
+	}, nil
+}
+
+func (f *fakePlugin) GetTrustBundle(context.Context, *pb.GetTrustBundleRequest) (*pb.GetTrustBundleResponse, error) {
+	return &pb.GetTrustBundleResponse{CaCert: string(f.rootPEM), Roots: []string{string(f.rootPEM)}}, nil
+}
+
+// startPlugin serves a fakePlugin and the health service on a Unix domain socket.
+func startPlugin(t *testing.T) (*fakePlugin, *health.Server, string, func()) {
+	certPEM, keyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          "plugin",
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the root CA: %v", err)
+	}
+	signer, err := util.NewVerifiedKeyCertBundleFromPem(certPEM, keyPEM, nil, certPEM)
+	if err != nil {
+		t.Fatalf("failed to create the root CA bundle: %v", err)
+	}
+	plugin := &fakePlugin{t: t, rootPEM: certPEM, signer: signer}
+
+	dir, err := ioutil.TempDir("", "caplugin")
+	if err != nil {
+		t.Fatalf("failed to create a temp dir: %v", err)
+	}
+	path := filepath.Join(dir, "plugin.sock")
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	healthServer := health.NewServer()
+	pb.RegisterCAPluginServer(server, plugin)
+	healthpb.RegisterHealthServer(server, healthServer)
+	go func() { _ = server.Serve(lis) }()
+	return plugin, healthServer, unixPrefix + path, func() {
+		server.Stop()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func genCSR(t *testing.T, host string) []byte {
+	csr, _, err := util.GenCSR(util.CertOptions{Host: host, RSAKeySize: 2048})
+	if err != nil {
+		t.Fatalf("failed to generate a CSR: %v", err)
+	}
+	return csr
+}
+
+func TestCASign(t *testing.T) {
+	plugin, _, addr, stop := startPlugin(t)
+	defer stop()
+	ca, err := NewCA(addr, time.Second, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to create the plugin CA: %v", err)
+	}
+	if root := ca.GetCAKeyCertBundle().GetRootCertPem(); string(root) != string(plugin.rootPEM) {
+		t.Errorf("unexpected root cert %q", root)
+	}
+
+	id := "spiffe://cluster.local/ns/default/sa/foo"
+	result, err := ca.SignWithResult(genCSR(t, id), []string{id}, time.Hour, false)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(result.Leaf)
+	if err != nil {
+		t.Fatalf("invalid leaf cert: %v", err)
+	}
+	if len(cert.URIs) != 1 || cert.URIs[0].String() != id {
+		t.Errorf("unexpected SANs %v", cert.URIs)
+	}
+	if string(result.RootCerts) != string(plugin.rootPEM) || len(result.CertChain) != 0 {
+		t.Errorf("unexpected root certs %q and cert chain %q", result.RootCerts, result.CertChain)
+	}
+	if req := plugin.requests[0]; req.TtlSeconds != 3600 || req.ForCa || len(req.SubjectIds) != 1 {
+		t.Errorf("unexpected sign request %v", req)
+	}
+}
+
+func TestCASignErrors(t *testing.T) {
+	plugin, _, addr, stop := startPlugin(t)
+	defer stop()
+	ca, err := NewCA(addr, time.Second, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to create the plugin CA: %v", err)
+	}
+	id := "spiffe://cluster.local/ns/default/sa/foo"
+	testCases := map[string]struct {
+		csr      []byte
+		signErr  error
+		extraSAN string
+		errType  string
+	}{
+		"invalid CSR": {
+			csr: []byte("invalid"), errType: "CSR_ERROR",
+		},
+		"denied": {
+			csr: genCSR(t, id), signErr: status.Error(codes.PermissionDenied, "denied"), errType: "AUTHORIZATION_ERROR",
+		},
+		"throttled": {
+			csr: genCSR(t, id), signErr: status.Error(codes.ResourceExhausted, "quota"), errType: "QUOTA_EXCEEDED",
+		},
+		"internal error": {
+			csr: genCSR(t, id), signErr: status.Error(codes.Internal, "boom"), errType: "CERT_GEN_ERROR",
+		},
+		"SAN not requested": {
+			csr: genCSR(t, id), extraSAN: "spiffe://cluster.local/ns/default/sa/bar", errType: "CERT_GEN_ERROR",
+		},
+	}
+	for name, tc := range testCases {
+		plugin.signErr = tc.signErr
+		plugin.extraSAN = tc.extraSAN
+		_, err := ca.Sign(tc.csr, []string{id}, time.Hour, false)
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		if caErr, ok := err.(*caerror.Error); !ok || caErr.ErrorType() != tc.errType {
+			t.Errorf("%s: got error %v, want error type %s", name, err, tc.errType)
+		}
+	}
+}
+
+func TestCAHealthy(t *testing.T) {
+	_, healthServer, addr, stop := startPlugin(t)
+	defer stop()
+	ca, err := NewCA(addr, time.Second, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to create the plugin CA: %v", err)
+	}
+	if err := ca.Healthy(); err != nil {
+		t.Errorf("expected the plugin to be healthy: %v", err)
+	}
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	if err := ca.Healthy(); err == nil {
+		t.Error("expected the plugin to be unhealthy")
+	}
+}
+
+func TestNewCAErrors(t *testing.T) {
+	if _, err := NewCA("", time.Second, grpc.WithInsecure()); err == nil {
+		t.Error("expected an error without an address")
+	}
+	addr := unixPrefix + filepath.Join(os.TempDir(), "caplugin-missing.sock")
+	if _, err := NewCA(addr, 100*time.Millisecond, grpc.WithInsecure()); err == nil {
+		t.Error("expected an error when the plugin is not running")
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cas signs workload certificates with Google Certificate Authority Service.
+package cas
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2/google"
+
+	pkica "istio.io/istio/security/pkg/pki/ca"
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/pkg/log"
+)
+
+const (
+	defaultEndpoint = "https://privateca.googleapis.com"
+	defaultTimeout  = 10 * time.Second
+	cloudPlatform   = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+var casLog = log.RegisterScope("cas", "Google CAS CA log", 0)
+
+// Config configures a CA.
+type Config struct {
+	// Project, Location and Pool identify the CA pool issuing the certs, i.e.
+	// projects/<Project>/locations/<Location>/caPools/<Pool>.
+	Project  string
+	Location string
+	Pool     string
+	// Endpoint is the CAS API endpoint, https://privateca.googleapis.com by default.
+	Endpoint string
+	// Timeout is the timeout of each request, 10s by default.
+	Timeout time.Duration
+
+	// DefaultTTL is the TTL of certs requested without a TTL. MaxTTL is the max TTL of issued certs.
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+}
+
+// CA signs certificates with a Google CAS CA pool. It implements the CertificateAuthority interface
+// of the CA server.
+type CA struct {
+	config Config
+	client *http.Client
+	pool   string
+
+	keyCertBundle util.KeyCertBundle
+}
+
+// NewCA returns a CA authenticating to CAS with the application default credentials, i.e. with
+// the Google service account bound to the Kubernetes service account through workload identity
+// on GKE. It reads the CA certs of the pool.
+func NewCA(ctx context.Context, config Config) (*CA, error) {
+	client, err := google.DefaultClient(ctx, cloudPlatform)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Google credentials: %v", err)
+	}
+	return newCA(config, client)
+}
+
+func newCA(config Config, client *http.Client) (*CA, error) {
+	if config.Project == "" || config.Location == "" || config.Pool == "" {
+		return nil, errors.New("the project, location and pool of the CAS CA pool must be configured")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = defaultEndpoint
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	ca := &CA{
+		config: config,
+		client: client,
+		pool:   fmt.Sprintf("projects/%s/locations/%s/caPools/%s", config.Project, config.Location, config.Pool),
+	}
+	if err := ca.loadCACerts(); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// Sign takes a PEM-encoded CSR, subject IDs and lifetime, and returns a cert signed by CAS.
+func (ca *CA) Sign(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error) {
+	result, err := ca.SignWithResult(csrPEM, subjectIDs, ttl, forCA)
+	if err != nil {
+		return nil, err
+	}
+	return result.Leaf, nil
+}
+
+// SignWithCertChain is similar to Sign but returns the leaf cert and the entire cert chain.
+func (ca *CA) SignWithCertChain(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error) {
+	result, err := ca.SignWithResult(csrPEM, subjectIDs, ttl, forCA)
+	if err != nil {
+		return nil, err
+	}
+	return append(result.Leaf, result.CertChain...), nil
+}
+
+// SignWithResult creates a certificate from the CSR in the CA pool. The CSR must only request
+// subjectIDs, since CAS issues the SANs of the CSR.
+func (ca *CA) SignWithResult(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) (
+	*pkica.SignResult, error) {
+	if forCA {
+		return nil, caerror.NewError(caerror.CertGenError, errors.New("the CAS CA cannot sign CA certs"))
+	}
+	if ttl <= 0 {
+		ttl = ca.config.DefaultTTL
+	}
+	if ca.config.MaxTTL > 0 && ttl > ca.config.MaxTTL {
+		return nil, caerror.NewError(caerror.TTLError, fmt.Errorf(
+			"requested TTL %s is greater than the max allowed TTL %s", ttl, ca.config.MaxTTL))
+	}
+	csr, err := util.ParsePemEncodedCSR(csrPEM)
+	if err != nil {
+		return nil, caerror.NewError(caerror.CSRError, err)
+	}
+	if err := util.CheckCSRSANs(csr, subjectIDs); err != nil {
+		return nil, caerror.NewError(caerror.SANError, err)
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return nil, caerror.NewError(caerror.CertGenError, err)
+	}
+	req := map[string]interface{}{"pemCsr": string(csrPEM)}
+	if ttl > 0 {
+		req["lifetime"] = fmt.Sprintf("%ds", int64(ttl.Seconds()))
+	}
+	var resp struct {
+		PemCertificate      string   `json:"pemCertificate"`
+		PemCertificateChain []string `json:"pemCertificateChain"`
+	}
+	url := fmt.Sprintf("%s/v1/%s/certificates?certificateId=istio-%s&requestId=%s",
+		ca.config.Endpoint, ca.pool, id, uuidFromID(id))
+	if err := ca.post("create_certificate", url, req, &resp); err != nil {
+		t := caerror.CertGenError
+		if isQuotaExceeded(err) {
+			t = caerror.QuotaExceededError
+		}
+		return nil, caerror.NewError(t, fmt.Errorf("failed to create the certificate in CAS: %v", err))
+	}
+	cert, err := util.ParsePemEncodedCertificate([]byte(resp.PemCertificate))
+	if err != nil {
+		return nil, caerror.NewError(caerror.CertGenError, fmt.Errorf("invalid cert issued by CAS: %v", err))
+	}
+	intermediates, roots, err := util.SplitRootCerts([]byte(strings.Join(resp.PemCertificateChain, "\n")))
+	if err != nil {
+		return nil, caerror.NewError(caerror.CertGenError, fmt.Errorf("invalid cert chain issued by CAS: %v", err))
+	}
+	if len(roots) == 0 {
+		roots = ca.keyCertBundle.GetRootCertPem()
+	}
+	return &pkica.SignResult{
+		Leaf:         []byte(strings.TrimSpace(resp.PemCertificate) + "\n"),
+		CertChain:    intermediates,
+		RootCerts:    roots,
+		NotAfter:     cert.NotAfter,
+		SerialNumber: cert.SerialNumber,
+	}, nil
+}
+
+// GetCAKeyCertBundle returns a KeyCertBundle with the CA certs of the pool, without a private key.
+func (ca *CA) GetCAKeyCertBundle() util.KeyCertBundle {
+	return ca.keyCertBundle
+}
+
+// loadCACerts reads the CA certs of the pool. The first chain is used as the cert chain of the
+// bundle, and the roots of all the chains as its roots.
+func (ca *CA) loadCACerts() error {
+	var resp struct {
+		CACerts []struct {
+			Certificates []string `json:"certificates"`
+		} `json:"caCerts"`
+	}
+	url := fmt.Sprintf("%s/v1/%s:fetchCaCerts", ca.config.Endpoint, ca.pool)
+	if err := ca.post("fetch_ca_certs", url, map[string]interface{}{}, &resp); err != nil {
+		return fmt.Errorf("failed to fetch the CA certs of %s: %v", ca.pool, err)
+	}
+	if len(resp.CACerts) == 0 || len(resp.CACerts[0].Certificates) == 0 {
+		return fmt.Errorf("the CA pool %s has no CA cert", ca.pool)
+	}
+	var signingCert, certChain, rootCerts []byte
+	for i, chain := range resp.CACerts {
+		intermediates, roots, err := util.SplitRootCerts([]byte(strings.Join(chain.Certificates, "\n")))
+		if err != nil {
+			return fmt.Errorf("invalid CA certs of %s: %v", ca.pool, err)
+		}
+		if i == 0 {
+			signingCert = []byte(chain.Certificates[0])
+			certChain = intermediates
+		}
+		if !bytes.Contains(rootCerts, roots) {
+			rootCerts = append(rootCerts, roots...)
+		}
+	}
+	if len(rootCerts) == 0 {
+		return fmt.Errorf("the CA certs of %s have no root cert", ca.pool)
+	}
+	bundle, err := util.NewVerifiedCertBundleFromPem(signingCert, certChain, rootCerts)
+	if err != nil {
+		return fmt.Errorf("invalid CA certs of %s: %v", ca.pool, err)
+	}
+	ca.keyCertBundle = bundle
+	return nil
+}
+
+// apiError is the error returned by Google APIs.
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Code, e.Status, e.Message)
+}
+
+func isQuotaExceeded(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && (apiErr.Code == http.StatusTooManyRequests || apiErr.Status == "RESOURCE_EXHAUSTED")
+}
+
+// post sends a JSON request to the CAS API, and decodes the response into out.
+func (ca *CA) post(operation, url string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ca.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := ca.client.Do(req)
+	casRequestLatency.With(operationTag.Value(operation)).Record(time.Since(start).Seconds())
+	if err != nil {
+		casErrorCounts.With(operationTag.Value(operation), statusTag.Value("UNAVAILABLE")).Increment()
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error *apiError `json:"error"`
+		}
+		if json.Unmarshal(respBody, &errResp) != nil || errResp.Error == nil {
+			errResp.Error = &apiError{Code: resp.StatusCode, Message: string(respBody)}
+		}
+		if errResp.Error.Code == 0 {
+			errResp.Error.Code = resp.StatusCode
+		}
+		if isQuotaExceeded(errResp.Error) {
+			casQuotaExceededCounts.With(operationTag.Value(operation)).Increment()
+		}
+		casErrorCounts.With(operationTag.Value(operation), statusTag.Value(errResp.Error.Status)).Increment()
+		casLog.Debugf("%s failed: %v", operation, errResp.Error)
+		return errResp.Error
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// randomID returns a random 16 bytes ID, hex-encoded, with the bits of a version 4 UUID.
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate a certificate ID: %v", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return hex.EncodeToString(b), nil
+}
+
+// uuidFromID formats a 32 hex digits ID as a UUID, which CAS requires for request IDs.
+func uuidFromID(id string) string {
+	return id[0:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:32]
+}
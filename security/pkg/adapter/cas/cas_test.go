@@ -0,0 +1,229 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cas
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const poolPath = "/v1/projects/p/locations/us-central1/caPools/istio"
+
+// fakeCAS is a minimal CAS API serving a single CA pool with a root CA.
+type fakeCAS struct {
+	t       *testing.T
+	rootPEM []byte
+	signer  util.KeyCertBundle
+
+	mutex     sync.Mutex
+	lifetimes []string
+	certIDs   []string
+	quota     bool
+}
+
+func newFakeCAS(t *testing.T) *fakeCAS {
+	certPEM, keyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          "cas",
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the root CA: %v", err)
+	}
+	signer, err := util.NewVerifiedKeyCertBundleFromPem(certPEM, keyPEM, nil, certPEM)
+	if err != nil {
+		t.Fatalf("failed to create the root CA bundle: %v", err)
+	}
+	return &fakeCAS{t: t, rootPEM: certPEM, signer: signer}
+}
+
+func (f *fakeCAS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.quota {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}`))
+		return
+	}
+	switch r.URL.Path {
+	case poolPath + ":fetchCaCerts":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"caCerts": []interface{}{map[string]interface{}{"certificates": []string{string(f.rootPEM)}}},
+		})
+	case poolPath + "/certificates":
+		var req struct {
+			PemCsr   string `json:"pemCsr"`
+			Lifetime string `json:"lifetime"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		csr, err := util.ParsePemEncodedCSR([]byte(req.PemCsr))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.lifetimes = append(f.lifetimes, req.Lifetime)
+		f.certIDs = append(f.certIDs, r.URL.Query().Get("certificateId"))
+		ttl, _ := time.ParseDuration(req.Lifetime)
+		signingCert, signingKey, _, _ := f.signer.GetAll()
+		var sans []string
+		for _, u := range csr.URIs {
+			sans = append(sans, u.String())
+		}
+		der, err := util.GenCertFromCSR(csr, signingCert, csr.PublicKey, *signingKey, sans, ttl, false)
+		if err != nil {
+			f.t.Errorf("failed to sign: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"pemCertificate":      string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+			"pemCertificateChain": []string{string(f.rootPEM)},
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"code":404,"message":"not found","status":"NOT_FOUND"}}`))
+	}
+}
+
+func newTestCA(t *testing.T, server *httptest.Server, pool string) (*CA, error) {
+	return newCA(Config{
+		Project:    "p",
+		Location:   "us-central1",
+		Pool:       pool,
+		Endpoint:   server.URL,
+		DefaultTTL: time.Hour,
+		MaxTTL:     2 * time.Hour,
+	}, server.Client())
+}
+
+func genCSR(t *testing.T, host string) []byte {
+	csr, _, err := util.GenCSR(util.CertOptions{Host: host, RSAKeySize: 2048})
+	if err != nil {
+		t.Fatalf("failed to generate a CSR: %v", err)
+	}
+	return csr
+}
+
+func TestCASign(t *testing.T) {
+	cas := newFakeCAS(t)
+	server := httptest.NewServer(cas)
+	defer server.Close()
+	ca, err := newTestCA(t, server, "istio")
+	if err != nil {
+		t.Fatalf("failed to create the CAS CA: %v", err)
+	}
+	if root := ca.GetCAKeyCertBundle().GetRootCertPem(); string(root) != string(cas.rootPEM) {
+		t.Errorf("unexpected root cert %q", root)
+	}
+
+	id := "spiffe://cluster.local/ns/default/sa/foo"
+	result, err := ca.SignWithResult(genCSR(t, id), []string{id}, 0, false)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(result.Leaf)
+	if err != nil {
+		t.Fatalf("invalid leaf cert: %v", err)
+	}
+	if len(cert.URIs) != 1 || cert.URIs[0].String() != id {
+		t.Errorf("unexpected SANs %v", cert.URIs)
+	}
+	if string(result.RootCerts) != string(cas.rootPEM) || len(result.CertChain) != 0 {
+		t.Errorf("unexpected root certs %q and cert chain %q", result.RootCerts, result.CertChain)
+	}
+	if !result.NotAfter.Equal(cert.NotAfter) {
+		t.Errorf("got NotAfter %v, want %v", result.NotAfter, cert.NotAfter)
+	}
+	if len(cas.lifetimes) != 1 || cas.lifetimes[0] != "3600s" {
+		t.Errorf("expected the default TTL to be requested, got %v", cas.lifetimes)
+	}
+	if !regexp.MustCompile(`^istio-[0-9a-f]{32}$`).MatchString(cas.certIDs[0]) {
+		t.Errorf("unexpected certificate ID %q", cas.certIDs[0])
+	}
+}
+
+func TestCASignErrors(t *testing.T) {
+	cas := newFakeCAS(t)
+	server := httptest.NewServer(cas)
+	defer server.Close()
+	ca, err := newTestCA(t, server, "istio")
+	if err != nil {
+		t.Fatalf("failed to create the CAS CA: %v", err)
+	}
+	id := "spiffe://cluster.local/ns/default/sa/foo"
+	testCases := map[string]struct {
+		csr        []byte
+		subjectIDs []string
+		ttl        time.Duration
+		forCA      bool
+		quota      bool
+		errType    string
+	}{
+		"CA cert": {
+			csr: genCSR(t, id), subjectIDs: []string{id}, ttl: time.Minute, forCA: true, errType: "CERT_GEN_ERROR",
+		},
+		"TTL too long": {
+			csr: genCSR(t, id), subjectIDs: []string{id}, ttl: 3 * time.Hour, errType: "TTL_ERROR",
+		},
+		"SAN of another identity": {
+			csr:        genCSR(t, "spiffe://cluster.local/ns/default/sa/bar"),
+			subjectIDs: []string{id},
+			ttl:        time.Minute,
+			errType:    "SAN_ERROR",
+		},
+		"quota exceeded": {
+			csr: genCSR(t, id), subjectIDs: []string{id}, ttl: time.Minute, quota: true, errType: "QUOTA_EXCEEDED",
+		},
+	}
+	for name, tc := range testCases {
+		cas.mutex.Lock()
+		cas.quota = tc.quota
+		cas.mutex.Unlock()
+		_, err := ca.Sign(tc.csr, tc.subjectIDs, tc.ttl, tc.forCA)
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		if caErr, ok := err.(*caerror.Error); !ok || caErr.ErrorType() != tc.errType {
+			t.Errorf("%s: got error %v, want error type %s", name, err, tc.errType)
+		}
+	}
+}
+
+func TestNewCAErrors(t *testing.T) {
+	cas := newFakeCAS(t)
+	server := httptest.NewServer(cas)
+	defer server.Close()
+	if _, err := newTestCA(t, server, "unknown"); err == nil {
+		t.Error("expected an error for an unknown pool")
+	}
+	if _, err := newCA(Config{Project: "p", Location: "us-central1"}, server.Client()); err == nil {
+		t.Error("expected an error for a missing pool")
+	}
+}
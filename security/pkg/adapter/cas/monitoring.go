@@ -0,0 +1,51 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cas
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	operationTag = monitoring.MustCreateLabel("operation")
+	statusTag    = monitoring.MustCreateLabel("status")
+
+	casRequestLatency = monitoring.NewDistribution(
+		"citadel_cas_request_latency_seconds",
+		"Latency in seconds of requests to Google Certificate Authority Service.",
+		[]float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+		monitoring.WithLabels(operationTag),
+	)
+
+	casErrorCounts = monitoring.NewSum(
+		"citadel_cas_error_count",
+		"The number of failed requests to Google Certificate Authority Service, by operation and status.",
+		monitoring.WithLabels(operationTag, statusTag),
+	)
+
+	casQuotaExceededCounts = monitoring.NewSum(
+		"citadel_cas_quota_exceeded_count",
+		"The number of requests to Google Certificate Authority Service rejected for exceeding the quota.",
+		monitoring.WithLabels(operationTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		casRequestLatency,
+		casErrorCounts,
+		casQuotaExceededCounts,
+	)
+}
@@ -0,0 +1,138 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+
+	pkica "istio.io/istio/security/pkg/pki/ca"
+	"istio.io/pkg/log"
+)
+
+const (
+	// kvCertField is the field of the KV secret holding the CA certificate.
+	kvCertField = "ca-cert.pem"
+	// kvKeyField is the field of the KV secret holding the CA private key.
+	kvKeyField = "ca-key.pem"
+)
+
+var vaultLog = log.RegisterScope("vaultstore", "Vault CA store log", 0)
+
+// KVStore persists the CA key and cert in a Vault KV secrets engine, so the
+// signing key does not need to be stored in a Kubernetes secret.
+type KVStore struct {
+	client    *api.Client
+	mount     string
+	path      string
+	kvVersion int
+}
+
+// NewKVStore returns a KVStore for the secret at secretPath of the KV engine mounted at mount.
+// kvVersion is the version of the KV engine, either 1 or 2. Only KV v2 supports check-and-set
+// writes: with KV v1, replicas starting concurrently on an empty store may overwrite each other's root.
+func NewKVStore(vaultAddr, token, mount, secretPath string, kvVersion int) (*KVStore, error) {
+	if kvVersion != 1 && kvVersion != 2 {
+		return nil, fmt.Errorf("unsupported KV engine version %d", kvVersion)
+	}
+	config := api.DefaultConfig()
+	config.Address = vaultAddr
+	client, err := api.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a Vault client: %v", err)
+	}
+	client.SetToken(token)
+	return &KVStore{
+		client:    client,
+		mount:     mount,
+		path:      secretPath,
+		kvVersion: kvVersion,
+	}, nil
+}
+
+// Load returns the CA cert and key stored in Vault. Empty values are returned if the
+// secret does not exist.
+func (s *KVStore) Load() ([]byte, []byte, error) {
+	secret, err := s.client.Logical().Read(s.dataPath())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s from Vault: %v", s.dataPath(), err)
+	}
+	if secret == nil || secret.Data == nil {
+		vaultLog.Infof("CA secret %s does not exist in Vault", s.dataPath())
+		return nil, nil, nil
+	}
+	data := secret.Data
+	if s.kvVersion == 2 {
+		// KV v2 nests the secret data and returns a nil value for deleted versions.
+		inner, ok := data["data"].(map[string]interface{})
+		if !ok {
+			return nil, nil, nil
+		}
+		data = inner
+	}
+	cert, _ := data[kvCertField].(string)
+	key, _ := data[kvKeyField].(string)
+	return []byte(cert), []byte(key), nil
+}
+
+// Store writes the CA cert and key to Vault. With KV v2 the write is a check-and-set creating the
+// secret, and pkica.ErrCAKeyCertExists is returned if it already exists.
+func (s *KVStore) Store(certPem, keyPem []byte) error {
+	data := map[string]interface{}{
+		kvCertField: string(certPem),
+		kvKeyField:  string(keyPem),
+	}
+	if s.kvVersion == 2 {
+		// cas=0 only allows the write if the secret does not exist.
+		data = map[string]interface{}{
+			"options": map[string]interface{}{"cas": 0},
+			"data":    data,
+		}
+	}
+	if _, err := s.client.Logical().Write(s.dataPath(), data); err != nil {
+		if isCASConflict(err) {
+			vaultLog.Infof("CA secret %s was written concurrently", s.dataPath())
+			return pkica.ErrCAKeyCertExists
+		}
+		return fmt.Errorf("failed to write %s to Vault: %v", s.dataPath(), err)
+	}
+	vaultLog.Infof("CA key and cert are written into Vault at %s", s.dataPath())
+	return nil
+}
+
+// isCASConflict returns whether err is the rejection of a check-and-set write by KV v2.
+func isCASConflict(err error) bool {
+	respErr, ok := err.(*api.ResponseError)
+	if !ok || respErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	for _, e := range respErr.Errors {
+		if strings.Contains(e, "check-and-set") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *KVStore) dataPath() string {
+	if s.kvVersion == 2 {
+		return path.Join(s.mount, "data", s.path)
+	}
+	return path.Join(s.mount, s.path)
+}
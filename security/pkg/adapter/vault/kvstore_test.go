@@ -0,0 +1,138 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	pkica "istio.io/istio/security/pkg/pki/ca"
+)
+
+// fakeKV is a minimal in-memory Vault KV engine.
+type fakeKV struct {
+	mutex   sync.Mutex
+	secrets map[string]map[string]interface{}
+}
+
+func (f *fakeKV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if r.Header.Get("X-Vault-Token") != "token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		data, ok := f.secrets[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	case http.MethodPut, http.MethodPost:
+		data := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if options, ok := data["options"].(map[string]interface{}); ok {
+			// Only cas=0 is supported, it creates the secret if it does not exist.
+			if _, exists := f.secrets[r.URL.Path]; exists && options["cas"] == float64(0) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"errors":["check-and-set parameter did not match the current version"]}`))
+				return
+			}
+			delete(data, "options")
+		}
+		f.secrets[r.URL.Path] = data
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestKVStore(t *testing.T) {
+	for _, version := range []int{1, 2} {
+		kv := &fakeKV{secrets: map[string]map[string]interface{}{}}
+		server := httptest.NewServer(kv)
+
+		store, err := NewKVStore(server.URL, "token", "secret", "istio/ca", version)
+		if err != nil {
+			t.Fatalf("KV v%d: failed to create store: %v", version, err)
+		}
+		cert, key, err := store.Load()
+		if err != nil {
+			t.Errorf("KV v%d: unexpected error loading an absent secret: %v", version, err)
+		}
+		if len(cert) != 0 || len(key) != 0 {
+			t.Errorf("KV v%d: expected empty cert and key, got %q %q", version, cert, key)
+		}
+
+		if err = store.Store([]byte("cert"), []byte("key")); err != nil {
+			t.Fatalf("KV v%d: failed to store: %v", version, err)
+		}
+		cert, key, err = store.Load()
+		if err != nil {
+			t.Fatalf("KV v%d: failed to load: %v", version, err)
+		}
+		if string(cert) != "cert" || string(key) != "key" {
+			t.Errorf("KV v%d: got cert %q key %q, want cert %q key %q", version, cert, key, "cert", "key")
+		}
+
+		expectedPath := "/v1/secret/istio/ca"
+		if version == 2 {
+			expectedPath = "/v1/secret/data/istio/ca"
+		}
+		if _, ok := kv.secrets[expectedPath]; !ok {
+			t.Errorf("KV v%d: expected the secret to be written at %s, got %v", version, expectedPath, kv.secrets)
+		}
+		server.Close()
+	}
+}
+
+func TestKVStoreConflict(t *testing.T) {
+	kv := &fakeKV{secrets: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(kv)
+	defer server.Close()
+
+	store, err := NewKVStore(server.URL, "token", "secret", "istio/ca", 2)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	if err = store.Store([]byte("cert"), []byte("key")); err != nil {
+		t.Fatalf("failed to store: %v", err)
+	}
+	if err = store.Store([]byte("other-cert"), []byte("other-key")); err != pkica.ErrCAKeyCertExists {
+		t.Fatalf("expected %v storing over an existing secret, got %v", pkica.ErrCAKeyCertExists, err)
+	}
+	cert, key, err := store.Load()
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if string(cert) != "cert" || string(key) != "key" {
+		t.Errorf("the first root was overwritten, got cert %q key %q", cert, key)
+	}
+}
+
+func TestKVStoreInvalidVersion(t *testing.T) {
+	if _, err := NewKVStore("http://127.0.0.1:8200", "token", "secret", "istio/ca", 3); err == nil {
+		t.Error("expected an error for an unsupported KV version")
+	}
+}
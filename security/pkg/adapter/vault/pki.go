@@ -0,0 +1,389 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+
+	pkica "istio.io/istio/security/pkg/pki/ca"
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/pkg/log"
+)
+
+const (
+	defaultAppRoleMount = "approle"
+	// minTokenRenewInterval bounds how often the Vault token is renewed.
+	minTokenRenewInterval = 10 * time.Second
+	// tokenRetryInterval is the wait before retrying a failed token renewal.
+	tokenRetryInterval = 30 * time.Second
+)
+
+var pkiLog = log.RegisterScope("vaultpki", "Vault PKI CA log", 0)
+
+// PKIConfig configures a PKICA.
+type PKIConfig struct {
+	// Addrs are the addresses of the Vault servers, e.g. "https://vault.example.com:8200". Requests go to
+	// the first server that responds, and fall back to the next ones when a server is unavailable.
+	Addrs []string
+	// Mount is the mount path of the PKI secrets engine, e.g. "pki".
+	Mount string
+	// Role is the PKI role used to sign CSRs with sign-verbatim. If empty, the default role is used.
+	Role string
+
+	// Token authenticates to Vault, if AppRoleID is not set.
+	Token string
+	// AppRoleID and AppRoleSecretID authenticate to Vault with the AppRole auth method mounted at
+	// AppRoleMount, "approle" by default.
+	AppRoleID       string
+	AppRoleSecretID string
+	AppRoleMount    string
+
+	// DefaultTTL is the TTL of certs requested without a TTL. MaxTTL is the max TTL of issued certs.
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+
+	// RootCert is the PEM-encoded root cert of the Vault PKI. If empty, the self-signed certs in the CA
+	// chain read from Vault are used.
+	RootCert []byte
+}
+
+// PKICA signs certificates with a Vault PKI secrets engine, so that Vault stays the only CA holding
+// a signing key. It implements the CertificateAuthority interface of the CA server.
+type PKICA struct {
+	config  PKIConfig
+	clients []*api.Client
+
+	// mutex guards the fields below.
+	mutex sync.Mutex
+	// current is the index of the client of the last server that responded.
+	current int
+	// tokenTTL is the TTL of the Vault token, 0 if it does not expire.
+	tokenTTL  time.Duration
+	renewable bool
+
+	keyCertBundle util.KeyCertBundle
+}
+
+// NewPKICA returns a PKICA. It authenticates to Vault and reads the CA chain of the PKI engine.
+func NewPKICA(config PKIConfig) (*PKICA, error) {
+	if len(config.Addrs) == 0 {
+		return nil, errors.New("no Vault address is configured")
+	}
+	if config.Mount == "" {
+		return nil, errors.New("no Vault PKI mount is configured")
+	}
+	if config.Token == "" && config.AppRoleID == "" {
+		return nil, errors.New("either a Vault token or an AppRole must be configured")
+	}
+	if config.AppRoleMount == "" {
+		config.AppRoleMount = defaultAppRoleMount
+	}
+	ca := &PKICA{config: config}
+	for _, addr := range config.Addrs {
+		c := api.DefaultConfig()
+		c.Address = addr
+		// Unavailable servers are retried through the fallback servers instead.
+		c.MaxRetries = 0
+		client, err := api.NewClient(c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create a Vault client for %s: %v", addr, err)
+		}
+		client.SetToken(config.Token)
+		ca.clients = append(ca.clients, client)
+	}
+	if err := ca.authenticate(); err != nil {
+		return nil, err
+	}
+	if err := ca.loadCAChain(); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// Run renews the Vault token until stopCh is closed.
+func (ca *PKICA) Run(stopCh <-chan struct{}) {
+	for {
+		ca.mutex.Lock()
+		ttl := ca.tokenTTL
+		ca.mutex.Unlock()
+		if ttl == 0 {
+			pkiLog.Info("Vault token does not expire, stop renewing it")
+			return
+		}
+		interval := ttl / 2
+		if interval < minTokenRenewInterval {
+			interval = minTokenRenewInterval
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(interval):
+		}
+		if err := ca.renewToken(); err != nil {
+			pkiLog.Errorf("failed to renew the Vault token: %v", err)
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(tokenRetryInterval):
+			}
+		}
+	}
+}
+
+// Sign takes a PEM-encoded CSR, subject IDs and lifetime, and returns a cert signed by Vault.
+func (ca *PKICA) Sign(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error) {
+	result, err := ca.SignWithResult(csrPEM, subjectIDs, ttl, forCA)
+	if err != nil {
+		return nil, err
+	}
+	return result.Leaf, nil
+}
+
+// SignWithCertChain is similar to Sign but returns the leaf cert and the entire cert chain.
+func (ca *PKICA) SignWithCertChain(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error) {
+	result, err := ca.SignWithResult(csrPEM, subjectIDs, ttl, forCA)
+	if err != nil {
+		return nil, err
+	}
+	return append(result.Leaf, result.CertChain...), nil
+}
+
+// SignWithResult sends the CSR to the sign-verbatim endpoint of the PKI engine. Since sign-verbatim
+// issues the SANs of the CSR, the CSR must only request subjectIDs.
+func (ca *PKICA) SignWithResult(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) (
+	*pkica.SignResult, error) {
+	if forCA {
+		return nil, caerror.NewError(caerror.CertGenError, errors.New("the Vault PKI CA cannot sign CA certs"))
+	}
+	if ttl <= 0 {
+		ttl = ca.config.DefaultTTL
+	}
+	if ca.config.MaxTTL > 0 && ttl > ca.config.MaxTTL {
+		return nil, caerror.NewError(caerror.TTLError, fmt.Errorf(
+			"requested TTL %s is greater than the max allowed TTL %s", ttl, ca.config.MaxTTL))
+	}
+	csr, err := util.ParsePemEncodedCSR(csrPEM)
+	if err != nil {
+		return nil, caerror.NewError(caerror.CSRError, err)
+	}
+	if err := util.CheckCSRSANs(csr, subjectIDs); err != nil {
+		return nil, caerror.NewError(caerror.SANError, err)
+	}
+
+	data := map[string]interface{}{
+		"csr":    string(csrPEM),
+		"format": "pem",
+	}
+	if ttl > 0 {
+		data["ttl"] = fmt.Sprintf("%ds", int64(ttl.Seconds()))
+	}
+	signPath := path.Join(ca.config.Mount, "sign-verbatim")
+	if ca.config.Role != "" {
+		signPath = path.Join(signPath, ca.config.Role)
+	}
+	secret, err := ca.do(func(c *api.Client) (*api.Secret, error) {
+		return c.Logical().Write(signPath, data)
+	})
+	if err != nil {
+		t := caerror.CertGenError
+		if isUnavailable(err) {
+			t = caerror.BackendError
+		}
+		return nil, caerror.NewError(t, fmt.Errorf("failed to sign the CSR with Vault: %v", err))
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, caerror.NewError(caerror.CertGenError, errors.New("empty sign response from Vault"))
+	}
+	certPEM, _ := secret.Data["certificate"].(string)
+	cert, err := util.ParsePemEncodedCertificate([]byte(certPEM))
+	if err != nil {
+		return nil, caerror.NewError(caerror.CertGenError, fmt.Errorf("invalid cert issued by Vault: %v", err))
+	}
+	_, _, certChain, rootCerts := ca.keyCertBundle.GetAll()
+	return &pkica.SignResult{
+		Leaf:         []byte(strings.TrimSpace(certPEM) + "\n"),
+		CertChain:    certChain,
+		RootCerts:    rootCerts,
+		NotAfter:     cert.NotAfter,
+		SerialNumber: cert.SerialNumber,
+	}, nil
+}
+
+// GetCAKeyCertBundle returns a KeyCertBundle with the CA chain of the PKI engine, without a private key.
+func (ca *PKICA) GetCAKeyCertBundle() util.KeyCertBundle {
+	return ca.keyCertBundle
+}
+
+// authenticate logs in with the AppRole if configured, and otherwise looks up the configured token.
+func (ca *PKICA) authenticate() error {
+	if ca.config.AppRoleID == "" {
+		secret, err := ca.do(func(c *api.Client) (*api.Secret, error) {
+			return c.Auth().Token().LookupSelf()
+		})
+		if err != nil {
+			return fmt.Errorf("failed to look up the Vault token: %v", err)
+		}
+		ttl, _ := secret.TokenTTL()
+		renewable, _ := secret.TokenIsRenewable()
+		ca.setToken("", ttl, renewable)
+		return nil
+	}
+
+	loginPath := path.Join("auth", ca.config.AppRoleMount, "login")
+	secret, err := ca.do(func(c *api.Client) (*api.Secret, error) {
+		return c.Logical().Write(loginPath, map[string]interface{}{
+			"role_id":   ca.config.AppRoleID,
+			"secret_id": ca.config.AppRoleSecretID,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to log in to Vault with the AppRole: %v", err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return errors.New("no token in the AppRole login response")
+	}
+	ca.setToken(secret.Auth.ClientToken, time.Duration(secret.Auth.LeaseDuration)*time.Second, secret.Auth.Renewable)
+	return nil
+}
+
+// renewToken renews the Vault token, or logs in again with the AppRole if the token cannot be renewed.
+func (ca *PKICA) renewToken() error {
+	ca.mutex.Lock()
+	ttl, renewable := ca.tokenTTL, ca.renewable
+	ca.mutex.Unlock()
+	if renewable {
+		secret, err := ca.do(func(c *api.Client) (*api.Secret, error) {
+			return c.Auth().Token().RenewSelf(int(ttl.Seconds()))
+		})
+		if err == nil && secret != nil && secret.Auth != nil {
+			ca.setToken("", time.Duration(secret.Auth.LeaseDuration)*time.Second, secret.Auth.Renewable)
+			pkiLog.Debugf("renewed the Vault token for %ds", secret.Auth.LeaseDuration)
+			return nil
+		}
+		if ca.config.AppRoleID == "" {
+			return fmt.Errorf("token renewal failed: %v", err)
+		}
+		pkiLog.Warnf("failed to renew the Vault token, logging in again: %v", err)
+	} else if ca.config.AppRoleID == "" {
+		return errors.New("the Vault token is not renewable")
+	}
+	return ca.authenticate()
+}
+
+// setToken records the TTL of the Vault token, and sets it on the clients if token is not empty.
+func (ca *PKICA) setToken(token string, ttl time.Duration, renewable bool) {
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+	if token != "" {
+		for _, c := range ca.clients {
+			c.SetToken(token)
+		}
+	}
+	ca.tokenTTL = ttl
+	ca.renewable = renewable
+}
+
+// loadCAChain reads the CA chain of the PKI engine and splits it into intermediate and root certs.
+func (ca *PKICA) loadCAChain() error {
+	chainPEM, err := ca.readCert("ca_chain")
+	if err != nil {
+		return err
+	}
+	if len(chainPEM) == 0 {
+		if chainPEM, err = ca.readCert("ca"); err != nil {
+			return err
+		}
+	}
+	intermediates, roots, err := util.SplitRootCerts(chainPEM)
+	if err != nil {
+		return fmt.Errorf("invalid Vault CA chain: %v", err)
+	}
+	if len(ca.config.RootCert) > 0 {
+		roots = ca.config.RootCert
+	}
+	if len(roots) == 0 {
+		return errors.New("the Vault CA chain has no root cert, configure the root cert")
+	}
+	signingCert := roots
+	if block, _ := pem.Decode(intermediates); block != nil {
+		signingCert = pem.EncodeToMemory(block)
+	}
+	bundle, err := util.NewVerifiedCertBundleFromPem(signingCert, intermediates, roots)
+	if err != nil {
+		return fmt.Errorf("invalid Vault CA chain: %v", err)
+	}
+	ca.keyCertBundle = bundle
+	return nil
+}
+
+// readCert reads a cert of the PKI engine, e.g. "ca" or "ca_chain".
+func (ca *PKICA) readCert(name string) ([]byte, error) {
+	certPath := path.Join(ca.config.Mount, "cert", name)
+	secret, err := ca.do(func(c *api.Client) (*api.Secret, error) {
+		return c.Logical().Read(certPath)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from Vault: %v", certPath, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, nil
+	}
+	cert, _ := secret.Data["certificate"].(string)
+	return []byte(cert), nil
+}
+
+// do runs op against the Vault servers, starting with the last one that responded, and falls back to
+// the next server if a server is unavailable.
+func (ca *PKICA) do(op func(c *api.Client) (*api.Secret, error)) (*api.Secret, error) {
+	ca.mutex.Lock()
+	start := ca.current
+	ca.mutex.Unlock()
+	var errs []string
+	for i := 0; i < len(ca.clients); i++ {
+		idx := (start + i) % len(ca.clients)
+		secret, err := op(ca.clients[idx])
+		if err == nil {
+			ca.mutex.Lock()
+			ca.current = idx
+			ca.mutex.Unlock()
+			return secret, nil
+		}
+		if !isUnavailable(err) {
+			return nil, err
+		}
+		pkiLog.Warnf("Vault server %s is unavailable: %v", ca.config.Addrs[idx], err)
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("no Vault server is available: %s", strings.Join(errs, "; "))
+}
+
+// isUnavailable returns whether err means that the Vault server could not serve the request, as opposed
+// to rejecting it.
+func isUnavailable(err error) bool {
+	var respErr *api.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode >= 500 || respErr.StatusCode == 429
+	}
+	return true
+}
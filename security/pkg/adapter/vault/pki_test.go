@@ -0,0 +1,337 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// fakePKI is a minimal Vault PKI engine mounted at "pki", with token and AppRole auth.
+type fakePKI struct {
+	t       *testing.T
+	rootPEM []byte
+	signer  util.KeyCertBundle
+
+	mutex    sync.Mutex
+	token    string
+	signTTLs []string
+	renewals int
+}
+
+func newFakePKI(t *testing.T) *fakePKI {
+	certPEM, keyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          "vault",
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the root CA: %v", err)
+	}
+	signer, err := util.NewVerifiedKeyCertBundleFromPem(certPEM, keyPEM, nil, certPEM)
+	if err != nil {
+		t.Fatalf("failed to create the root CA bundle: %v", err)
+	}
+	return &fakePKI{t: t, rootPEM: certPEM, signer: signer, token: "token"}
+}
+
+func (f *fakePKI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	body := map[string]interface{}{}
+	if r.Method != http.MethodGet {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+	if r.URL.Path == "/v1/auth/approle/login" {
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":["invalid role or secret ID"]}`))
+			return
+		}
+		f.token = "approle-token"
+		writeJSON(w, map[string]interface{}{"auth": map[string]interface{}{
+			"client_token": f.token, "lease_duration": 60, "renewable": true}})
+		return
+	}
+	if r.Header.Get("X-Vault-Token") != f.token {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+	switch r.URL.Path {
+	case "/v1/auth/token/lookup-self":
+		writeJSON(w, map[string]interface{}{"data": map[string]interface{}{"ttl": 3600, "renewable": true}})
+	case "/v1/auth/token/renew-self":
+		f.renewals++
+		writeJSON(w, map[string]interface{}{"auth": map[string]interface{}{
+			"client_token": f.token, "lease_duration": 120, "renewable": true}})
+	case "/v1/pki/cert/ca_chain":
+		writeJSON(w, map[string]interface{}{"data": map[string]interface{}{"certificate": string(f.rootPEM)}})
+	case "/v1/pki/sign-verbatim/istio":
+		csr, err := util.ParsePemEncodedCSR([]byte(body["csr"].(string)))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.signTTLs = append(f.signTTLs, body["ttl"].(string))
+		ttl, _ := time.ParseDuration(body["ttl"].(string))
+		signingCert, signingKey, _, _ := f.signer.GetAll()
+		var sans []string
+		for _, u := range csr.URIs {
+			sans = append(sans, u.String())
+		}
+		der, err := util.GenCertFromCSR(csr, signingCert, csr.PublicKey, *signingKey, sans, ttl, false)
+		if err != nil {
+			f.t.Errorf("failed to sign: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		writeJSON(w, map[string]interface{}{"data": map[string]interface{}{
+			"certificate": string(certPEM), "issuing_ca": string(f.rootPEM)}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errors":[]}`))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func genCSR(t *testing.T, host string) []byte {
+	csr, _, err := util.GenCSR(util.CertOptions{Host: host, RSAKeySize: 2048})
+	if err != nil {
+		t.Fatalf("failed to generate a CSR: %v", err)
+	}
+	return csr
+}
+
+func TestPKICASign(t *testing.T) {
+	vault := newFakePKI(t)
+	server := httptest.NewServer(vault)
+	defer server.Close()
+	ca, err := NewPKICA(PKIConfig{
+		Addrs:      []string{server.URL},
+		Mount:      "pki",
+		Role:       "istio",
+		Token:      "token",
+		DefaultTTL: time.Hour,
+		MaxTTL:     2 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the Vault PKI CA: %v", err)
+	}
+	if root := ca.GetCAKeyCertBundle().GetRootCertPem(); string(root) != string(vault.rootPEM) {
+		t.Errorf("unexpected root cert %q", root)
+	}
+
+	id := "spiffe://cluster.local/ns/default/sa/foo"
+	result, err := ca.SignWithResult(genCSR(t, id), []string{id}, 0, false)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(result.Leaf)
+	if err != nil {
+		t.Fatalf("invalid leaf cert: %v", err)
+	}
+	if len(cert.URIs) != 1 || cert.URIs[0].String() != id {
+		t.Errorf("unexpected SANs %v", cert.URIs)
+	}
+	if !result.NotAfter.Equal(cert.NotAfter) || result.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		t.Errorf("result does not match the leaf cert: %v %v", result.NotAfter, result.SerialNumber)
+	}
+	if string(result.RootCerts) != string(vault.rootPEM) {
+		t.Errorf("unexpected root certs %q", result.RootCerts)
+	}
+	if len(vault.signTTLs) != 1 || vault.signTTLs[0] != "3600s" {
+		t.Errorf("expected the default TTL to be requested, got %v", vault.signTTLs)
+	}
+
+	chain, err := ca.SignWithCertChain(genCSR(t, id), []string{id}, 30*time.Minute, false)
+	if err != nil {
+		t.Fatalf("failed to sign with cert chain: %v", err)
+	}
+	if !strings.HasPrefix(string(chain), "-----BEGIN CERTIFICATE-----") {
+		t.Errorf("unexpected cert chain %q", chain)
+	}
+	if vault.signTTLs[1] != "1800s" {
+		t.Errorf("expected TTL 1800s, got %s", vault.signTTLs[1])
+	}
+}
+
+func TestPKICASignErrors(t *testing.T) {
+	vault := newFakePKI(t)
+	server := httptest.NewServer(vault)
+	defer server.Close()
+	ca, err := NewPKICA(PKIConfig{
+		Addrs:  []string{server.URL},
+		Mount:  "pki",
+		Role:   "istio",
+		Token:  "token",
+		MaxTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the Vault PKI CA: %v", err)
+	}
+	id := "spiffe://cluster.local/ns/default/sa/foo"
+	testCases := map[string]struct {
+		csr        []byte
+		subjectIDs []string
+		ttl        time.Duration
+		forCA      bool
+		errType    string
+	}{
+		"CA cert": {
+			csr: genCSR(t, id), subjectIDs: []string{id}, ttl: time.Minute, forCA: true, errType: "CERT_GEN_ERROR",
+		},
+		"TTL too long": {
+			csr: genCSR(t, id), subjectIDs: []string{id}, ttl: 2 * time.Hour, errType: "TTL_ERROR",
+		},
+		"invalid CSR": {
+			csr: []byte("invalid"), subjectIDs: []string{id}, ttl: time.Minute, errType: "CSR_ERROR",
+		},
+		"SAN of another identity": {
+			csr:        genCSR(t, "spiffe://cluster.local/ns/default/sa/bar"),
+			subjectIDs: []string{id},
+			ttl:        time.Minute,
+			errType:    "SAN_ERROR",
+		},
+	}
+	for name, tc := range testCases {
+		_, err := ca.Sign(tc.csr, tc.subjectIDs, tc.ttl, tc.forCA)
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		if caErr, ok := err.(*caerror.Error); !ok || caErr.ErrorType() != tc.errType {
+			t.Errorf("%s: got error %v, want error type %s", name, err, tc.errType)
+		}
+	}
+	if len(vault.signTTLs) != 0 {
+		t.Errorf("expected no request to be sent to Vault, got %d", len(vault.signTTLs))
+	}
+}
+
+func TestPKICAFallback(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"errors":["Vault is sealed"]}`))
+	}))
+	defer unavailable.Close()
+	vault := newFakePKI(t)
+	server := httptest.NewServer(vault)
+	defer server.Close()
+
+	ca, err := NewPKICA(PKIConfig{
+		Addrs: []string{unavailable.URL, server.URL},
+		Mount: "pki",
+		Role:  "istio",
+		Token: "token",
+	})
+	if err != nil {
+		t.Fatalf("failed to create the Vault PKI CA: %v", err)
+	}
+	id := "spiffe://cluster.local/ns/default/sa/foo"
+	if _, err := ca.Sign(genCSR(t, id), []string{id}, time.Minute, false); err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if ca.current != 1 {
+		t.Errorf("expected the second server to be used, got %d", ca.current)
+	}
+
+	if _, err := NewPKICA(PKIConfig{
+		Addrs: []string{unavailable.URL},
+		Mount: "pki",
+		Token: "token",
+	}); err == nil {
+		t.Error("expected an error when no Vault server is available")
+	}
+	if _, err := NewPKICA(PKIConfig{
+		Addrs: []string{server.URL},
+		Mount: "pki",
+		Token: "wrong",
+	}); err == nil {
+		t.Error("expected an error for an invalid token")
+	}
+
+	// Signing fails with a retryable error when no Vault server is available.
+	server.Close()
+	_, err = ca.Sign(genCSR(t, id), []string{id}, time.Minute, false)
+	if caErr, ok := err.(*caerror.Error); !ok || caErr.ErrorType() != "BACKEND_ERROR" || !caErr.IsRetryable() {
+		t.Errorf("got error %v, want a retryable BACKEND_ERROR", err)
+	}
+}
+
+func TestPKICAAppRole(t *testing.T) {
+	vault := newFakePKI(t)
+	server := httptest.NewServer(vault)
+	defer server.Close()
+	ca, err := NewPKICA(PKIConfig{
+		Addrs:           []string{server.URL},
+		Mount:           "pki",
+		Role:            "istio",
+		AppRoleID:       "role",
+		AppRoleSecretID: "secret",
+	})
+	if err != nil {
+		t.Fatalf("failed to create the Vault PKI CA: %v", err)
+	}
+	if ca.tokenTTL != time.Minute || !ca.renewable {
+		t.Errorf("unexpected token TTL %s and renewable %v", ca.tokenTTL, ca.renewable)
+	}
+	if err := ca.renewToken(); err != nil {
+		t.Fatalf("failed to renew the token: %v", err)
+	}
+	if vault.renewals != 1 || ca.tokenTTL != 2*time.Minute {
+		t.Errorf("expected the token to be renewed, got %d renewals and TTL %s", vault.renewals, ca.tokenTTL)
+	}
+
+	// A revoked token is replaced by logging in again.
+	vault.mutex.Lock()
+	vault.token = "revoked"
+	vault.mutex.Unlock()
+	if err := ca.renewToken(); err != nil {
+		t.Fatalf("failed to log in again: %v", err)
+	}
+	id := "spiffe://cluster.local/ns/default/sa/foo"
+	if _, err := ca.Sign(genCSR(t, id), []string{id}, time.Minute, false); err != nil {
+		t.Errorf("failed to sign after logging in again: %v", err)
+	}
+}
+
+func TestNewPKICAInvalidConfig(t *testing.T) {
+	for name, config := range map[string]PKIConfig{
+		"no address": {Mount: "pki", Token: "token"},
+		"no mount":   {Addrs: []string{"http://127.0.0.1:8200"}, Token: "token"},
+		"no auth":    {Addrs: []string{"http://127.0.0.1:8200"}, Mount: "pki"},
+	} {
+		if _, err := NewPKICA(config); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
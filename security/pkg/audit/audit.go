@@ -0,0 +1,127 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit records security relevant decisions, such as denied certificate requests, in an
+// audit log separate from the debug logs.
+package audit
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"istio.io/pkg/log"
+)
+
+var auditLog = log.RegisterScope("audit", "Security audit log", 0)
+
+// Decision is the outcome of an audited request.
+type Decision string
+
+const (
+	// Allow means the request is allowed.
+	Allow Decision = "allow"
+	// Deny means the request is denied.
+	Deny Decision = "deny"
+)
+
+// Origin tells whether an audited request comes from a remote API or from within the control plane.
+type Origin string
+
+const (
+	// Remote means the request is received through a remote API, e.g. the CSR gRPC API.
+	Remote Origin = "remote"
+	// Internal means the request is made by a controller within the control plane.
+	Internal Origin = "internal"
+)
+
+// Lifecycle is the step of the lifecycle of the certificate recorded by an entry.
+type Lifecycle string
+
+const (
+	// Issued means a certificate is issued, e.g. for a CSR.
+	Issued Lifecycle = "issued"
+	// Rotated means a CA certificate is replaced with a new one, e.g. by a root rotation.
+	Rotated Lifecycle = "rotated"
+)
+
+// Entry is an audit log entry.
+type Entry struct {
+	// Event is the kind of the audited event, e.g. "san_authorization".
+	Event string `json:"event"`
+	// Requester is the identity of the caller.
+	Requester string `json:"requester,omitempty"`
+	// SANs are the SANs requested by the caller.
+	SANs []string `json:"sans,omitempty"`
+	// Decision is the outcome of the request.
+	Decision Decision `json:"decision"`
+	// Reason explains the decision.
+	Reason string `json:"reason,omitempty"`
+
+	// Origin tells where the request comes from.
+	Origin Origin `json:"origin,omitempty"`
+	// Peer is the address of a remote caller.
+	Peer string `json:"peer,omitempty"`
+	// CredentialType is the type of the credential the caller authenticated with.
+	CredentialType string `json:"credentialType,omitempty"`
+	// SerialNumber is the serial number of the issued certificate.
+	SerialNumber string `json:"serialNumber,omitempty"`
+	// NotAfter is the expiration time of the issued certificate.
+	NotAfter *time.Time `json:"notAfter,omitempty"`
+	// Lifecycle is the step of the lifecycle of the issued certificate, Issued if empty.
+	Lifecycle Lifecycle `json:"lifecycle,omitempty"`
+}
+
+// IsIssuance returns true if the entry records the issuance of a certificate.
+func (e Entry) IsIssuance() bool {
+	return e.Decision == Allow && e.SerialNumber != ""
+}
+
+// Sink receives audit entries in addition to the audit log.
+type Sink interface {
+	Record(Entry)
+}
+
+var (
+	sinksMutex sync.RWMutex
+	sinks      = map[*Sink]Sink{}
+)
+
+// RegisterSink adds a sink receiving all subsequent audit entries. The returned function removes it.
+func RegisterSink(s Sink) (unregister func()) {
+	key := &s
+	sinksMutex.Lock()
+	sinks[key] = s
+	sinksMutex.Unlock()
+	return func() {
+		sinksMutex.Lock()
+		delete(sinks, key)
+		sinksMutex.Unlock()
+	}
+}
+
+// Record writes the entry to the audit log as JSON, and passes it to the registered sinks.
+func Record(e Entry) {
+	if data, err := json.Marshal(e); err != nil {
+		auditLog.Errorf("failed to marshal audit entry %+v (error %v)", e, err)
+	} else {
+		auditLog.Info(string(data))
+	}
+
+	sinksMutex.RLock()
+	defer sinksMutex.RUnlock()
+	for _, s := range sinks {
+		s.Record(e)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"reflect"
+	"testing"
+)
+
+type fakeSink struct {
+	entries []Entry
+}
+
+func (s *fakeSink) Record(e Entry) {
+	s.entries = append(s.entries, e)
+}
+
+func TestRecord(t *testing.T) {
+	sink := &fakeSink{}
+	unregister := RegisterSink(sink)
+
+	entry := Entry{
+		Event:     "san_authorization",
+		Requester: "spiffe://cluster.local/ns/foo/sa/foo",
+		SANs:      []string{"foo.example.com"},
+		Decision:  Deny,
+		Reason:    "not allowed",
+	}
+	Record(entry)
+	if !reflect.DeepEqual(sink.entries, []Entry{entry}) {
+		t.Errorf("sink got %v, want %v", sink.entries, []Entry{entry})
+	}
+
+	unregister()
+	Record(entry)
+	if len(sink.entries) != 1 {
+		t.Errorf("unregistered sink got %d entries, want 1", len(sink.entries))
+	}
+}
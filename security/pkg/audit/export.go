@@ -0,0 +1,124 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"time"
+)
+
+const (
+	exportQueueSize = 10000
+	exportBatchSize = 100
+	exportAttempts  = 3
+	exportBackoff   = time.Second
+	// exportFlushTimeout bounds the export of the pending entries when the sink stops.
+	exportFlushTimeout = 5 * time.Second
+)
+
+// TimedEntry is an audit entry with the time it was recorded.
+type TimedEntry struct {
+	Entry
+	Time time.Time `json:"time"`
+}
+
+// Exporter sends audit entries to an external system, such as a SIEM.
+type Exporter interface {
+	// Export sends entries, returning an error if they may not have been received.
+	Export(entries []TimedEntry) error
+	// String describes the destination of the exporter, for logging.
+	String() string
+}
+
+// ExportSink passes the audit entries to an Exporter in batches, asynchronously, so that the audited
+// requests are not delayed by the external system. It queues, batches and retries the entries for all
+// the exporters, such as the syslog, Kafka and webhook ones.
+type ExportSink struct {
+	exporter Exporter
+	queue    chan TimedEntry
+}
+
+// NewExportSink creates an ExportSink exporting the entries with exporter.
+func NewExportSink(exporter Exporter) *ExportSink {
+	return &ExportSink{
+		exporter: exporter,
+		queue:    make(chan TimedEntry, exportQueueSize),
+	}
+}
+
+// Record implements Sink. The entries are exported by Run, and dropped if too many are pending.
+func (s *ExportSink) Record(e Entry) {
+	select {
+	case s.queue <- TimedEntry{Entry: e, Time: time.Now()}:
+	default:
+		auditLog.Warnf("dropping audit entry %q, too many entries are pending for %v", e.Event, s.exporter)
+	}
+}
+
+// Run exports the recorded entries until stop is closed, and then the entries still pending, for up
+// to exportFlushTimeout.
+func (s *ExportSink) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			s.flush(time.Now().Add(exportFlushTimeout))
+			return
+		case e := <-s.queue:
+			s.export(s.batch(e))
+		}
+	}
+}
+
+// batch returns first followed by the pending entries, up to exportBatchSize entries.
+func (s *ExportSink) batch(first TimedEntry) []TimedEntry {
+	entries := []TimedEntry{first}
+	for len(entries) < exportBatchSize {
+		select {
+		case e := <-s.queue:
+			entries = append(entries, e)
+		default:
+			return entries
+		}
+	}
+	return entries
+}
+
+// flush exports the pending entries until none is left or deadline is reached.
+func (s *ExportSink) flush(deadline time.Time) {
+	for time.Now().Before(deadline) {
+		select {
+		case e := <-s.queue:
+			s.export(s.batch(e))
+		default:
+			return
+		}
+	}
+	if pending := len(s.queue); pending > 0 {
+		auditLog.Warnf("dropping %d audit entries pending for %v, the export did not complete before shutdown",
+			pending, s.exporter)
+	}
+}
+
+func (s *ExportSink) export(entries []TimedEntry) {
+	var err error
+	for attempt := 0; attempt < exportAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(exportBackoff * time.Duration(attempt))
+		}
+		if err = s.exporter.Export(entries); err == nil {
+			return
+		}
+	}
+	auditLog.Errorf("failed to export %d audit entries to %v: %v", len(entries), s.exporter, err)
+}
@@ -0,0 +1,87 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeExporter struct {
+	mutex    sync.Mutex
+	batches  [][]TimedEntry
+	exported chan struct{}
+}
+
+func (f *fakeExporter) Export(entries []TimedEntry) error {
+	f.mutex.Lock()
+	f.batches = append(f.batches, entries)
+	f.mutex.Unlock()
+	f.exported <- struct{}{}
+	return nil
+}
+
+func (f *fakeExporter) String() string {
+	return "fake"
+}
+
+func TestExportSink(t *testing.T) {
+	exporter := &fakeExporter{exported: make(chan struct{}, 10)}
+	sink := NewExportSink(exporter)
+	for i := 0; i < exportBatchSize+1; i++ {
+		sink.Record(Entry{Event: "csr_signing", Decision: Allow})
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go sink.Run(stop)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-exporter.exported:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the entries were not exported")
+		}
+	}
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	if len(exporter.batches[0]) != exportBatchSize || len(exporter.batches[1]) != 1 {
+		t.Errorf("expected batches of %d and 1 entries, got %d and %d",
+			exportBatchSize, len(exporter.batches[0]), len(exporter.batches[1]))
+	}
+	if exporter.batches[0][0].Time.IsZero() {
+		t.Error("expected the entries to be timestamped")
+	}
+}
+
+func TestExportSinkFlushesOnStop(t *testing.T) {
+	exporter := &fakeExporter{exported: make(chan struct{}, 10)}
+	sink := NewExportSink(exporter)
+	for i := 0; i < exportBatchSize+1; i++ {
+		sink.Record(Entry{Event: "csr_signing", Decision: Allow})
+	}
+	stop := make(chan struct{})
+	close(stop)
+	// Run returns once the pending entries are exported.
+	sink.Run(stop)
+
+	exported := 0
+	for _, batch := range exporter.batches {
+		exported += len(batch)
+	}
+	if exported != exportBatchSize+1 {
+		t.Errorf("expected the %d pending entries to be exported on stop, got %d", exportBatchSize+1, exported)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// kafkaRESTContentType is the content type of the JSON records of the Kafka REST proxy v2 API.
+const kafkaRESTContentType = "application/vnd.kafka.json.v2+json"
+
+// KafkaRESTExporter produces the audit entries as JSON records to a Kafka topic through a Kafka REST
+// proxy, e.g. http://kafka-rest:8082/topics/istio-audit.
+type KafkaRESTExporter struct {
+	url    string
+	client *http.Client
+}
+
+// NewKafkaRESTExporter creates a KafkaRESTExporter producing to the topic URL url.
+func NewKafkaRESTExporter(url string) *KafkaRESTExporter {
+	return &KafkaRESTExporter{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type kafkaRecord struct {
+	Key   string     `json:"key,omitempty"`
+	Value TimedEntry `json:"value"`
+}
+
+// Export implements Exporter. The records are keyed by requester, so that the entries of a requester
+// are kept in order.
+func (k *KafkaRESTExporter) Export(entries []TimedEntry) error {
+	records := make([]kafkaRecord, 0, len(entries))
+	for _, e := range entries {
+		records = append(records, kafkaRecord{Key: e.Requester, Value: e})
+	}
+	body, err := json.Marshal(struct {
+		Records []kafkaRecord `json:"records"`
+	}{records})
+	if err != nil {
+		return err
+	}
+	resp, err := k.client.Post(k.url, kafkaRESTContentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (k *KafkaRESTExporter) String() string {
+	return k.url
+}
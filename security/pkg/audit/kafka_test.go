@@ -0,0 +1,53 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKafkaRESTExporter(t *testing.T) {
+	var records []kafkaRecord
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/topics/istio-audit" || r.Header.Get("Content-Type") != kafkaRESTContentType {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var body struct {
+			Records []kafkaRecord `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode the records: %v", err)
+		}
+		records = body.Records
+	}))
+	defer server.Close()
+
+	exporter := NewKafkaRESTExporter(server.URL + "/topics/istio-audit")
+	entries := []TimedEntry{
+		{Entry: Entry{Event: "csr_signing", Requester: "foo", Decision: Allow}, Time: time.Unix(1600000000, 0).UTC()},
+		{Entry: Entry{Event: "csr_signing", Requester: "bar", Decision: Deny}, Time: time.Unix(1600000001, 0).UTC()},
+	}
+	if err := exporter.Export(entries); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if len(records) != 2 || records[0].Key != "foo" || !reflect.DeepEqual(records[1].Value, entries[1]) {
+		t.Errorf("unexpected records %+v", records)
+	}
+}
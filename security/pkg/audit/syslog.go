@@ -0,0 +1,196 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/pkg/version"
+)
+
+// Format is the format of the audit entries sent to syslog.
+type Format string
+
+const (
+	// FormatJSON formats the entries as JSON.
+	FormatJSON Format = "json"
+	// FormatCEF formats the entries in the ArcSight Common Event Format.
+	FormatCEF Format = "cef"
+)
+
+const (
+	// The syslog facility of the audit entries, security/authorization messages.
+	syslogFacilityAuthPriv = 10
+	syslogSeverityWarning  = 4
+	syslogSeverityNotice   = 5
+	syslogAppName          = "istiod"
+	syslogDialTimeout      = 10 * time.Second
+)
+
+// SyslogExporter sends the audit entries to a syslog server as RFC 5424 messages. With TCP, the
+// messages are framed with octet counting as in RFC 6587.
+type SyslogExporter struct {
+	network  string
+	address  string
+	format   Format
+	hostname string
+
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+// NewSyslogExporter creates a SyslogExporter sending to address over network, "udp" or "tcp", the
+// entries in format.
+func NewSyslogExporter(network, address string, format Format) (*SyslogExporter, error) {
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("unsupported syslog network %q", network)
+	}
+	if format != FormatJSON && format != FormatCEF {
+		return nil, fmt.Errorf("unsupported audit format %q", format)
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	return &SyslogExporter{network: network, address: address, format: format, hostname: hostname}, nil
+}
+
+// Export implements Exporter. The connection is reopened after a failure.
+func (s *SyslogExporter) Export(entries []TimedEntry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.address, syslogDialTimeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	for _, e := range entries {
+		msg, err := s.message(e)
+		if err != nil {
+			auditLog.Errorf("failed to format audit entry %+v (error %v)", e.Entry, err)
+			continue
+		}
+		if s.network == "tcp" {
+			msg = strconv.Itoa(len(msg)) + " " + msg
+		}
+		if _, err := s.conn.Write([]byte(msg)); err != nil {
+			_ = s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SyslogExporter) String() string {
+	return s.network + "://" + s.address
+}
+
+// message returns the RFC 5424 message of e.
+func (s *SyslogExporter) message(e TimedEntry) (string, error) {
+	var payload string
+	if s.format == FormatCEF {
+		payload = CEF(e)
+	} else {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return "", err
+		}
+		payload = string(data)
+	}
+	severity := syslogSeverityNotice
+	if e.Decision == Deny {
+		severity = syslogSeverityWarning
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s - %s\n", syslogFacilityAuthPriv*8+severity,
+		e.Time.UTC().Format(time.RFC3339Nano), s.hostname, syslogAppName, os.Getpid(), msgID(e.Event), payload), nil
+}
+
+// msgID returns event as an RFC 5424 MSGID, printable ASCII of at most 32 characters.
+func msgID(event string) string {
+	if event == "" {
+		return "-"
+	}
+	id := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, event)
+	if len(id) > 32 {
+		id = id[:32]
+	}
+	return id
+}
+
+// CEF returns e in the ArcSight Common Event Format.
+func CEF(e TimedEntry) string {
+	severity := 3
+	name := e.Event + " allowed"
+	if e.Decision == Deny {
+		severity = 7
+		name = e.Event + " denied"
+	}
+	ext := []string{
+		"rt=" + strconv.FormatInt(e.Time.UnixNano()/int64(time.Millisecond), 10),
+		"act=" + cefExtension(string(e.Decision)),
+	}
+	add := func(key, value string) {
+		if value != "" {
+			ext = append(ext, key+"="+cefExtension(value))
+		}
+	}
+	add("suser", e.Requester)
+	add("reason", e.Reason)
+	for i, field := range []struct{ label, value string }{
+		{"sans", strings.Join(e.SANs, ",")},
+		{"origin", string(e.Origin)},
+		{"peer", e.Peer},
+		{"credentialType", e.CredentialType},
+		{"serialNumber", e.SerialNumber},
+	} {
+		if field.value != "" {
+			add(fmt.Sprintf("cs%dLabel", i+1), field.label)
+			add(fmt.Sprintf("cs%d", i+1), field.value)
+		}
+	}
+	if e.NotAfter != nil {
+		add("end", strconv.FormatInt(e.NotAfter.UnixNano()/int64(time.Millisecond), 10))
+	}
+	return fmt.Sprintf("CEF:0|Istio|istiod|%s|%s|%s|%d|%s", cefHeader(version.Info.Version),
+		cefHeader(e.Event), cefHeader(name), severity, strings.Join(ext, " "))
+}
+
+var (
+	cefHeaderReplacer    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionReplacer = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+func cefHeader(s string) string {
+	return cefHeaderReplacer.Replace(s)
+}
+
+func cefExtension(s string) string {
+	return cefExtensionReplacer.Replace(s)
+}
@@ -0,0 +1,85 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/pkg/version"
+)
+
+func TestCEF(t *testing.T) {
+	notAfter := time.Unix(1700000000, 0)
+	e := TimedEntry{
+		Entry: Entry{
+			Event:        "csr_signing",
+			Requester:    "spiffe://cluster.local/ns/foo/sa/foo",
+			SANs:         []string{"spiffe://cluster.local/ns/foo/sa/foo"},
+			Decision:     Deny,
+			Reason:       "denied by the issuance policy: a=b|c",
+			Origin:       Remote,
+			SerialNumber: "2a",
+			NotAfter:     &notAfter,
+		},
+		Time: time.Unix(1600000000, 0),
+	}
+	expected := "CEF:0|Istio|istiod|" + version.Info.Version + "|csr_signing|csr_signing denied|7|" +
+		"rt=1600000000000 act=deny suser=spiffe://cluster.local/ns/foo/sa/foo " +
+		`reason=denied by the issuance policy: a\=b|c ` +
+		"cs1Label=sans cs1=spiffe://cluster.local/ns/foo/sa/foo cs2Label=origin cs2=remote " +
+		"cs5Label=serialNumber cs5=2a end=1700000000000"
+	if got := CEF(e); got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestSyslogExporter(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	exporter, err := NewSyslogExporter("udp", conn.LocalAddr().String(), FormatCEF)
+	if err != nil {
+		t.Fatalf("failed to create the exporter: %v", err)
+	}
+	e := TimedEntry{Entry: Entry{Event: "csr_signing", Decision: Allow}, Time: time.Unix(1600000000, 0)}
+	if err := exporter.Export([]TimedEntry{e}); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("failed to read the message: %v", err)
+	}
+	msg := string(buf[:n])
+	// authpriv.notice
+	if !strings.HasPrefix(msg, "<85>1 2020-09-13T12:26:40Z ") {
+		t.Errorf("unexpected header in %q", msg)
+	}
+	if !strings.Contains(msg, " istiod ") || !strings.Contains(msg, " csr_signing - CEF:0|Istio|") {
+		t.Errorf("unexpected message %q", msg)
+	}
+
+	if _, err := NewSyslogExporter("udp", "localhost:514", "xml"); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
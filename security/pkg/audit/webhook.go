@@ -0,0 +1,128 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader is the header of the requests of a WebhookExporter holding the hex encoded
+// HMAC-SHA256 of the body, keyed with the shared key of the exporter.
+const SignatureHeader = "X-Istio-Signature"
+
+// CertificateEvent is the JSON payload posted by a WebhookExporter for an issued or rotated certificate.
+type CertificateEvent struct {
+	// Event is the kind of the audited event, e.g. "csr_signing".
+	Event string `json:"event"`
+	// Lifecycle tells whether the certificate is issued, or replaces a CA certificate.
+	Lifecycle Lifecycle `json:"lifecycle"`
+	// Identity is the identity of the requester of the certificate.
+	Identity string `json:"identity,omitempty"`
+	// SANs are the SANs of the certificate.
+	SANs []string `json:"sans,omitempty"`
+	// SerialNumber is the hex encoded serial number of the certificate.
+	SerialNumber string `json:"serialNumber"`
+	// NotAfter is the expiration time of the certificate.
+	NotAfter *time.Time `json:"notAfter,omitempty"`
+	// Time is the time of the event.
+	Time time.Time `json:"time"`
+}
+
+// WebhookExporter posts the issued certificates, including the refreshed ones and the rotated CA
+// certificates, to an HTTP endpoint, e.g. of a certificate inventory, so that it is kept in sync
+// without reading the secrets. The requests are signed with a shared key. An event is posted at least
+// once: the events of a batch are posted again if a later one fails.
+//
+// There are no revocation events, since istiod does not revoke certificates, nor expiry events, since
+// the certificates expire at the NotAfter of their issuance event.
+type WebhookExporter struct {
+	url    string
+	key    []byte
+	client *http.Client
+}
+
+// NewWebhookExporter creates a WebhookExporter posting to url, signing the requests with key.
+func NewWebhookExporter(url string, key []byte) *WebhookExporter {
+	return &WebhookExporter{
+		url:    url,
+		key:    key,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Export implements Exporter. Only the issuances are posted, one request per issuance.
+func (w *WebhookExporter) Export(entries []TimedEntry) error {
+	for _, e := range entries {
+		if !e.IsIssuance() {
+			continue
+		}
+		event := CertificateEvent{
+			Event:        e.Event,
+			Lifecycle:    e.Lifecycle,
+			Identity:     e.Requester,
+			SANs:         e.SANs,
+			SerialNumber: e.SerialNumber,
+			NotAfter:     e.NotAfter,
+			Time:         e.Time,
+		}
+		if event.Lifecycle == "" {
+			event.Lifecycle = Issued
+		}
+		if err := w.post(event); err != nil {
+			return fmt.Errorf("failed to post the issuance of certificate %s: %v", e.SerialNumber, err)
+		}
+	}
+	return nil
+}
+
+func (w *WebhookExporter) String() string {
+	return w.url
+}
+
+func (w *WebhookExporter) post(event CertificateEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(w.key, body))
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body keyed with key, as set in SignatureHeader.
+func Sign(key, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
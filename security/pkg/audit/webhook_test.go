@@ -0,0 +1,101 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWebhookExporter(t *testing.T) {
+	key := []byte("shared-key")
+	received := make(chan CertificateEvent, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read the body: %v", err)
+		}
+		if got := r.Header.Get(SignatureHeader); got != Sign(key, body) {
+			t.Errorf("unexpected signature %q", got)
+		}
+		event := CertificateEvent{}
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("failed to unmarshal the event: %v", err)
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	notAfter := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Unix(1600000000, 0).UTC()
+	exporter := NewWebhookExporter(server.URL, key)
+	if err := exporter.Export([]TimedEntry{
+		{Entry: Entry{Event: "csr_signing", Decision: Deny, Reason: "rate limited"}, Time: now},
+		{Entry: Entry{
+			Event:        "csr_signing",
+			Requester:    "spiffe://cluster.local/ns/foo/sa/foo",
+			SANs:         []string{"spiffe://cluster.local/ns/foo/sa/foo"},
+			Decision:     Allow,
+			SerialNumber: "2a",
+			NotAfter:     &notAfter,
+		}, Time: now},
+		{Entry: Entry{
+			Event:        "root_cert_rotation",
+			Decision:     Allow,
+			SerialNumber: "2b",
+			Lifecycle:    Rotated,
+		}, Time: now},
+	}); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("expected the issuance and the rotation to be posted, got %d events", len(received))
+	}
+	expected := CertificateEvent{
+		Event:        "csr_signing",
+		Lifecycle:    Issued,
+		Identity:     "spiffe://cluster.local/ns/foo/sa/foo",
+		SANs:         []string{"spiffe://cluster.local/ns/foo/sa/foo"},
+		SerialNumber: "2a",
+		NotAfter:     &notAfter,
+		Time:         now,
+	}
+	if event := <-received; !reflect.DeepEqual(event, expected) {
+		t.Errorf("expected %+v, got %+v", expected, event)
+	}
+	if event := <-received; event.Lifecycle != Rotated || event.SerialNumber != "2b" {
+		t.Errorf("unexpected rotation event %+v", event)
+	}
+}
+
+func TestWebhookExporterError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	exporter := NewWebhookExporter(server.URL, nil)
+	if err := exporter.Export([]TimedEntry{
+		{Entry: Entry{Event: "csr_signing", Decision: Allow, SerialNumber: "2a"}, Time: time.Now()},
+	}); err == nil {
+		t.Error("expected an error for a failed post, so that the export sink retries it")
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package debugserver serves the pprof profiles and the runtime stats of the istio agent on a
+// separate address, set with the SECURITY_DEBUG_ADDRESS environment variable or the
+// --security-debug-address flag, so that CPU and memory issues, e.g. during mass rotations, can be
+// profiled in production. Nothing is served unless the address is set.
+//
+// Istiod serves its pprof profiles on its own debug endpoints, to which it adds RuntimeHandler.
+package debugserver
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"istio.io/pkg/env"
+	"istio.io/pkg/log"
+)
+
+const (
+	// RuntimePath is the path of the runtime stats.
+	RuntimePath = "/debug/runtimez"
+
+	shutdownTimeout = 5 * time.Second
+)
+
+var (
+	addressEnv = env.RegisterStringVar("SECURITY_DEBUG_ADDRESS", "",
+		"The address serving the pprof profiles and the runtime stats of the istio agent, "+
+			"e.g. localhost:9876. Nothing is served if it is empty.")
+
+	// Address is the address of the debug server, set from SECURITY_DEBUG_ADDRESS.
+	Address = addressEnv.Get()
+)
+
+// AddFlag adds the --security-debug-address flag setting Address to fs. The flag overrides
+// SECURITY_DEBUG_ADDRESS.
+func AddFlag(fs *pflag.FlagSet) {
+	fs.StringVar(&Address, "security-debug-address", Address, "The address serving the pprof profiles "+
+		"and the runtime stats of the istio agent, e.g. localhost:9876. Nothing is served if it is empty.")
+}
+
+// RuntimeStats are the stats of the Go runtime served at RuntimePath.
+type RuntimeStats struct {
+	GoVersion    string `json:"goVersion"`
+	NumCPU       int    `json:"numCPU"`
+	GOMAXPROCS   int    `json:"gomaxprocs"`
+	NumGoroutine int    `json:"numGoroutine"`
+	NumCgoCall   int64  `json:"numCgoCall"`
+
+	HeapAlloc    uint64 `json:"heapAlloc"`
+	HeapInuse    uint64 `json:"heapInuse"`
+	HeapObjects  uint64 `json:"heapObjects"`
+	HeapReleased uint64 `json:"heapReleased"`
+	StackInuse   uint64 `json:"stackInuse"`
+	Sys          uint64 `json:"sys"`
+	TotalAlloc   uint64 `json:"totalAlloc"`
+	Mallocs      uint64 `json:"mallocs"`
+	Frees        uint64 `json:"frees"`
+
+	NumGC        uint32     `json:"numGC"`
+	PauseTotalNs uint64     `json:"pauseTotalNs"`
+	LastGC       *time.Time `json:"lastGC,omitempty"`
+}
+
+// ReadRuntimeStats returns the current stats of the Go runtime.
+func ReadRuntimeStats() RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	stats := RuntimeStats{
+		GoVersion:    runtime.Version(),
+		NumCPU:       runtime.NumCPU(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		NumGoroutine: runtime.NumGoroutine(),
+		NumCgoCall:   runtime.NumCgoCall(),
+		HeapAlloc:    m.HeapAlloc,
+		HeapInuse:    m.HeapInuse,
+		HeapObjects:  m.HeapObjects,
+		HeapReleased: m.HeapReleased,
+		StackInuse:   m.StackInuse,
+		Sys:          m.Sys,
+		TotalAlloc:   m.TotalAlloc,
+		Mallocs:      m.Mallocs,
+		Frees:        m.Frees,
+		NumGC:        m.NumGC,
+		PauseTotalNs: m.PauseTotalNs,
+	}
+	if m.LastGC > 0 {
+		lastGC := time.Unix(0, int64(m.LastGC))
+		stats.LastGC = &lastGC
+	}
+	return stats
+}
+
+// Server serves the pprof profiles and the runtime stats.
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewServer creates a Server listening on addr.
+func NewServer(addr string) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	// pprof.Index also serves the named profiles, e.g. /debug/pprof/heap and /debug/pprof/goroutine.
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc(RuntimePath, RuntimeHandler)
+	return &Server{
+		listener: listener,
+		server:   &http.Server{Handler: mux},
+	}, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Run serves until stop is closed.
+func (s *Server) Run(stop <-chan struct{}) {
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := s.server.Shutdown(ctx); err != nil {
+			log.Warnf("failed to shut down the security debug server: %v", err)
+		}
+	}()
+	log.Infof("Serving the security debug endpoints at %s", s.listener.Addr())
+	if err := s.server.Serve(s.listener); err != nil && err != http.ErrServerClosed {
+		log.Errorf("security debug server failed: %v", err)
+	}
+}
+
+// Start serves at Address until stop is closed, unless Address is empty.
+func Start(stop <-chan struct{}) error {
+	if Address == "" {
+		return nil
+	}
+	s, err := NewServer(Address)
+	if err != nil {
+		return err
+	}
+	go s.Run(stop)
+	return nil
+}
+
+// RuntimeHandler serves the runtime stats as JSON.
+func RuntimeHandler(w http.ResponseWriter, _ *http.Request) {
+	out, err := json.MarshalIndent(ReadRuntimeStats(), "", "    ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(out)
+}
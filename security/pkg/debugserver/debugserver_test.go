@@ -0,0 +1,66 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debugserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	s, err := NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("failed to create the server: %v", err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.Run(stop)
+	base := fmt.Sprintf("http://%s", s.Addr())
+
+	resp, err := http.Get(base + RuntimePath)
+	if err != nil {
+		t.Fatalf("failed to get the runtime stats: %v", err)
+	}
+	var stats RuntimeStats
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("failed to decode the runtime stats: %v", err)
+	}
+	if stats.NumGoroutine == 0 || stats.HeapAlloc == 0 || stats.GoVersion == "" {
+		t.Errorf("unexpected runtime stats %+v", stats)
+	}
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/goroutine?debug=1"} {
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Fatalf("failed to get %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("unexpected status %s of %s", resp.Status, path)
+		}
+	}
+}
+
+func TestStartDisabled(t *testing.T) {
+	defer func(address string) { Address = address }(Address)
+	Address = ""
+	if err := Start(make(chan struct{})); err != nil {
+		t.Errorf("expected nothing to be served without an address, got %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package featuregate gates the new behaviors of the security components, so that they can ship
+// disabled and be enabled per cluster, with the SECURITY_FEATURE_GATES environment variable or the
+// --security-feature-gates flag, without a separate build.
+package featuregate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+
+	"istio.io/pkg/env"
+	"istio.io/pkg/log"
+)
+
+// Feature is the name of a gated feature.
+type Feature string
+
+// Stage is the maturity of a feature.
+type Stage string
+
+const (
+	// Alpha features are disabled by default and may change or be removed.
+	Alpha Stage = "Alpha"
+	// Beta features are well tested, and may be enabled by default.
+	Beta Stage = "Beta"
+)
+
+// Spec describes a gated feature.
+type Spec struct {
+	Default     bool
+	Stage       Stage
+	Description string
+}
+
+const (
+	// ECDSADefault generates ECDSA P-256 workload keys in the agent instead of RSA keys, unless
+	// ECC_SIGNATURE_ALGORITHM is set.
+	ECDSADefault Feature = "ECDSADefault"
+	// GatewaySDS serves the gateway credentials over SDS from the agent, unless
+	// ENABLE_INGRESS_GATEWAY_SDS is set.
+	GatewaySDS Feature = "GatewaySDS"
+)
+
+var defaultFeatures = map[Feature]Spec{
+	ECDSADefault: {
+		Default:     false,
+		Stage:       Alpha,
+		Description: "Generate ECDSA P-256 workload keys instead of RSA keys, unless ECC_SIGNATURE_ALGORITHM is set",
+	},
+	GatewaySDS: {
+		Default:     false,
+		Stage:       Alpha,
+		Description: "Serve the gateway credentials over SDS, unless ENABLE_INGRESS_GATEWAY_SDS is set",
+	},
+}
+
+var (
+	featureGatesEnv = env.RegisterStringVar("SECURITY_FEATURE_GATES", "",
+		"Comma separated feature=true|false pairs enabling or disabling the gated security features: "+
+			strings.Join(New(defaultFeatures).Known(), ", "))
+
+	// Default holds the gates of the security features, set from SECURITY_FEATURE_GATES.
+	Default = newDefault()
+)
+
+func newDefault() *Gates {
+	g := New(defaultFeatures)
+	if err := g.Set(featureGatesEnv.Get()); err != nil {
+		log.Errorf("Invalid SECURITY_FEATURE_GATES: %v", err)
+	}
+	return g
+}
+
+// Gates holds whether the known features are enabled. It implements pflag.Value.
+type Gates struct {
+	mutex   sync.RWMutex
+	known   map[Feature]Spec
+	enabled map[Feature]bool
+}
+
+var _ pflag.Value = &Gates{}
+
+// New returns the gates of the known features, set to their defaults.
+func New(known map[Feature]Spec) *Gates {
+	g := &Gates{
+		known:   map[Feature]Spec{},
+		enabled: map[Feature]bool{},
+	}
+	for f, spec := range known {
+		g.known[f] = spec
+	}
+	return g
+}
+
+// Enabled returns whether the feature is enabled. It panics if the feature is unknown, which is a
+// programming error.
+func (g *Gates) Enabled(f Feature) bool {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	if enabled, ok := g.enabled[f]; ok {
+		return enabled
+	}
+	spec, ok := g.known[f]
+	if !ok {
+		panic(fmt.Sprintf("unknown feature %q", f))
+	}
+	return spec.Default
+}
+
+// SetFromMap enables or disables the features in m. Nothing is changed if a feature is unknown.
+func (g *Gates) SetFromMap(m map[string]bool) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	for name := range m {
+		if _, ok := g.known[Feature(name)]; !ok {
+			names := make([]string, 0, len(g.known))
+			for f := range g.known {
+				names = append(names, string(f))
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown feature %q, known features: %s", name, strings.Join(names, ", "))
+		}
+	}
+	for name, enabled := range m {
+		g.enabled[Feature(name)] = enabled
+		if enabled != g.known[Feature(name)].Default {
+			log.Infof("Security feature %s set to %v", name, enabled)
+		}
+	}
+	return nil
+}
+
+// Set enables or disables the features in value, comma separated feature=true|false pairs.
+func (g *Gates) Set(value string) error {
+	m := map[string]bool{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid feature gate %q, expecting feature=true|false", pair)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return fmt.Errorf("invalid value of feature gate %q: %v", pair, err)
+		}
+		m[strings.TrimSpace(kv[0])] = enabled
+	}
+	return g.SetFromMap(m)
+}
+
+// String returns the features that are set, as comma separated feature=true|false pairs.
+func (g *Gates) String() string {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	pairs := make([]string, 0, len(g.enabled))
+	for f, enabled := range g.enabled {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", f, enabled))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Type implements pflag.Value.
+func (g *Gates) Type() string {
+	return "mapStringBool"
+}
+
+// Known returns the descriptions of the known features, sorted by name.
+func (g *Gates) Known() []string {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	known := make([]string, 0, len(g.known))
+	for f, spec := range g.known {
+		known = append(known, fmt.Sprintf("%s=true|false (%s, default=%v): %s", f, spec.Stage, spec.Default, spec.Description))
+	}
+	sort.Strings(known)
+	return known
+}
+
+// AddFlag adds the --security-feature-gates flag setting the Default gates to fs. The flag
+// overrides the features set by SECURITY_FEATURE_GATES.
+func AddFlag(fs *pflag.FlagSet) {
+	fs.Var(Default, "security-feature-gates", "Comma separated feature=true|false pairs enabling or "+
+		"disabling the gated security features:\n"+strings.Join(Default.Known(), "\n"))
+}
@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuregate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	alphaFeature Feature = "AlphaFeature"
+	betaFeature  Feature = "BetaFeature"
+)
+
+func newTestGates() *Gates {
+	return New(map[Feature]Spec{
+		alphaFeature: {Default: false, Stage: Alpha, Description: "An alpha feature"},
+		betaFeature:  {Default: true, Stage: Beta, Description: "A beta feature"},
+	})
+}
+
+func TestGates(t *testing.T) {
+	g := newTestGates()
+	if g.Enabled(alphaFeature) || !g.Enabled(betaFeature) {
+		t.Errorf("the features are not set to their defaults")
+	}
+	if err := g.Set(" AlphaFeature=true, BetaFeature=false "); err != nil {
+		t.Fatalf("Set() error: %v", err)
+	}
+	if !g.Enabled(alphaFeature) || g.Enabled(betaFeature) {
+		t.Errorf("the features are not set")
+	}
+	if got, want := g.String(), "AlphaFeature=true,BetaFeature=false"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	for _, invalid := range []string{
+		"AlphaFeature",
+		"AlphaFeature=maybe",
+		"AlphaFeature=false,UnknownFeature=true",
+	} {
+		if err := g.Set(invalid); err == nil {
+			t.Errorf("Set(%q) expected an error", invalid)
+		}
+	}
+	// Nothing is changed by an invalid value.
+	if !g.Enabled(alphaFeature) {
+		t.Errorf("the features are changed by an invalid value")
+	}
+}
+
+func TestUnknownFeaturePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Enabled() of an unknown feature did not panic")
+		}
+	}()
+	newTestGates().Enabled("UnknownFeature")
+}
+
+func TestFlag(t *testing.T) {
+	g := newTestGates()
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.Var(g, "security-feature-gates", strings.Join(g.Known(), "\n"))
+	if err := fs.Parse([]string{"--security-feature-gates=AlphaFeature=true"}); err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if !g.Enabled(alphaFeature) || !g.Enabled(betaFeature) {
+		t.Errorf("the flag is not applied")
+	}
+}
+
+func TestDefaultFeatures(t *testing.T) {
+	for f := range defaultFeatures {
+		if Default.Enabled(f) != defaultFeatures[f].Default {
+			t.Errorf("feature %s is not set to its default", f)
+		}
+	}
+	if len(Default.Known()) != len(defaultFeatures) {
+		t.Errorf("unexpected known features %v", Default.Known())
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cabackup
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// azureSASTokenEnv is the environment variable holding the SAS token of the Azure Blob container.
+	azureSASTokenEnv = "AZURE_STORAGE_SAS_TOKEN"
+	azureAPIVersion  = "2019-12-12"
+)
+
+// azureStore stores the objects as block blobs in an Azure Blob Storage container, authenticating
+// with a shared access signature.
+type azureStore struct {
+	client    *http.Client
+	endpoint  string
+	container string
+	prefix    string
+	sasToken  string
+}
+
+// NewAzureStore returns a Store of the blobs under prefix in container of the storage account,
+// authenticating with sasToken.
+func NewAzureStore(account, container, prefix, sasToken string) (Store, error) {
+	if account == "" || container == "" {
+		return nil, fmt.Errorf("an Azure storage account and container are required")
+	}
+	return newAzureStore(http.DefaultClient, fmt.Sprintf("https://%s.blob.core.windows.net", account),
+		container, prefix, sasToken)
+}
+
+func newAzureStore(client *http.Client, endpoint, container, prefix, sasToken string) (Store, error) {
+	if sasToken == "" {
+		return nil, fmt.Errorf("a SAS token of the Azure Blob container is required in %s", azureSASTokenEnv)
+	}
+	return &azureStore{
+		client:    client,
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		container: container,
+		prefix:    prefix,
+		sasToken:  strings.TrimPrefix(sasToken, "?"),
+	}, nil
+}
+
+func (s *azureStore) newRequest(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Request, error) {
+	rawQuery := s.sasToken
+	if len(query) > 0 {
+		rawQuery = query.Encode() + "&" + rawQuery
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.endpoint+path+"?"+rawQuery, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	return req, nil
+}
+
+func (s *azureStore) blobPath(name string) string {
+	segments := strings.Split(s.prefix+name, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return "/" + url.PathEscape(s.container) + "/" + strings.Join(segments, "/")
+}
+
+// Put implements Store.
+func (s *azureStore) Put(ctx context.Context, name string, data []byte) error {
+	req, err := s.newRequest(ctx, http.MethodPut, s.blobPath(name), nil, data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	_, err = doRequest(s.client, req)
+	return err
+}
+
+// Get implements Store.
+func (s *azureStore) Get(ctx context.Context, name string) ([]byte, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, s.blobPath(name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return doRequest(s.client, req)
+}
+
+// List implements Store.
+func (s *azureStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {s.prefix + prefix}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		req, err := s.newRequest(ctx, http.MethodGet, "/"+url.PathEscape(s.container), query, nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := doRequest(s.client, req)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Blobs []struct {
+				Name string `xml:"Name"`
+			} `xml:"Blobs>Blob"`
+			NextMarker string `xml:"NextMarker"`
+		}
+		if err := xml.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse the Azure blobs: %v", err)
+		}
+		for _, blob := range page.Blobs {
+			names = append(names, strings.TrimPrefix(blob.Name, s.prefix))
+		}
+		if marker = page.NextMarker; marker == "" {
+			return names, nil
+		}
+	}
+}
+
+// Delete implements Store.
+func (s *azureStore) Delete(ctx context.Context, name string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, s.blobPath(name), nil, nil)
+	if err != nil {
+		return err
+	}
+	_, err = doRequest(s.client, req)
+	return err
+}
@@ -0,0 +1,278 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cabackup backs up the CA secret and the issuance registry of a self-signed Istio CA, encrypted,
+// to object storage, and restores them, so that losing the self-signed root does not require re-rolling
+// trust for the whole mesh.
+package cabackup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/security/pkg/pki/kms"
+	"istio.io/pkg/log"
+)
+
+const (
+	// CASecret is the name of the backed up CA secret.
+	CASecret = "istio-ca-secret"
+	// namePrefix and nameSuffix surround the timestamp in the object names of the backups, so that
+	// sorting the names sorts the backups from the oldest to the newest.
+	namePrefix = "istio-ca-backup-"
+	nameSuffix = ".pem"
+	timeFormat = "20060102T150405Z"
+	// documentVersion is the version of the format of the backups.
+	documentVersion = 1
+)
+
+var backupLog = log.RegisterScope("cabackup", "CA backup log", 0)
+
+// ErrNoBackup is returned by Restore when there is no backup to restore.
+var ErrNoBackup = errors.New("no CA backup found")
+
+// Config configures a Backuper.
+type Config struct {
+	// Namespace of the CA secret and of the CA state resource.
+	Namespace string
+	Client    corev1.SecretsGetter
+	// CAState is the client of the IstioCAState resources in Namespace, holding the issuance registry.
+	// The issuance registry is not backed up if it is nil.
+	CAState     dynamic.ResourceInterface
+	CAStateName string
+	// Store stores the backups.
+	Store Store
+	// Encryption encrypts the backups.
+	Encryption kms.Provider
+	// Retain is the number of most recent backups kept in Store. Zero keeps all the backups.
+	Retain int
+}
+
+// document is the content of a backup, before encryption.
+type document struct {
+	Version   int                        `json:"version"`
+	Time      metav1.Time                `json:"time"`
+	Namespace string                     `json:"namespace"`
+	CASecret  *v1.Secret                 `json:"caSecret"`
+	CAState   *unstructured.Unstructured `json:"caState,omitempty"`
+}
+
+// Backuper backs up and restores the CA secret and the issuance registry.
+type Backuper struct {
+	config Config
+	now    func() time.Time
+}
+
+// NewBackuper returns a new Backuper.
+func NewBackuper(config Config) (*Backuper, error) {
+	if config.Store == nil {
+		return nil, errors.New("a store of the CA backups is required")
+	}
+	if config.Encryption == nil {
+		return nil, errors.New("an encryption key of the CA backups is required")
+	}
+	if config.Retain < 0 {
+		return nil, fmt.Errorf("invalid number of retained CA backups %d", config.Retain)
+	}
+	return &Backuper{config: config, now: time.Now}, nil
+}
+
+// Run backs up the CA every interval until stop is closed.
+func (b *Backuper) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if name, err := b.Backup(context.TODO()); err != nil {
+			backupLog.Errorf("Failed to back up the CA: %v", err)
+		} else {
+			backupLog.Infof("Backed up the CA to %s", name)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Backup writes an encrypted backup of the CA secret and the issuance registry to the store, deletes
+// the backups beyond the retained ones, and returns the name of the backup.
+func (b *Backuper) Backup(ctx context.Context) (string, error) {
+	name, err := b.backup(ctx)
+	if err != nil {
+		backupCounts.With(resultTag.Value(resultFailure)).Increment()
+		return "", err
+	}
+	backupCounts.With(resultTag.Value(resultSuccess)).Increment()
+	lastBackupTimestamp.Record(float64(b.now().Unix()))
+	if err := b.prune(ctx); err != nil {
+		backupLog.Warnf("Failed to delete the old CA backups: %v", err)
+	}
+	return name, nil
+}
+
+func (b *Backuper) backup(ctx context.Context) (string, error) {
+	secret, err := b.config.Client.Secrets(b.config.Namespace).Get(ctx, CASecret, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s/%s: %v", b.config.Namespace, CASecret, err)
+	}
+	now := b.now().UTC()
+	doc := &document{
+		Version:   documentVersion,
+		Time:      metav1.Time{Time: now},
+		Namespace: b.config.Namespace,
+		CASecret: &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        secret.Name,
+				Labels:      secret.Labels,
+				Annotations: secret.Annotations,
+			},
+			Type: secret.Type,
+			Data: secret.Data,
+		},
+	}
+	if b.config.CAState != nil {
+		state, err := b.config.CAState.Get(ctx, b.config.CAStateName, metav1.GetOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return "", fmt.Errorf("failed to get the CA state %s: %v", b.config.CAStateName, err)
+		}
+		if err == nil {
+			doc.CAState = stripObjectMeta(state)
+		}
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := kms.EncryptData(b.config.Encryption, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt the CA backup: %v", err)
+	}
+	name := namePrefix + now.Format(timeFormat) + nameSuffix
+	if err := b.config.Store.Put(ctx, name, encrypted); err != nil {
+		return "", fmt.Errorf("failed to write the CA backup %s: %v", name, err)
+	}
+	return name, nil
+}
+
+// Restore restores the CA secret and the issuance registry from the backup name, or from the most
+// recent backup if name is empty. It never overwrites an existing CA secret or CA state, and returns
+// ErrNoBackup if there is no backup.
+func (b *Backuper) Restore(ctx context.Context, name string) error {
+	if name == "" {
+		names, err := b.List(ctx)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return ErrNoBackup
+		}
+		name = names[len(names)-1]
+	}
+	encrypted, err := b.config.Store.Get(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to read the CA backup %s: %v", name, err)
+	}
+	data, err := kms.DecryptData(b.config.Encryption, encrypted)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt the CA backup %s: %v", name, err)
+	}
+	doc := &document{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return fmt.Errorf("failed to parse the CA backup %s: %v", name, err)
+	}
+	if doc.Version != documentVersion || doc.CASecret == nil {
+		return fmt.Errorf("unsupported CA backup %s", name)
+	}
+
+	secret := doc.CASecret.DeepCopy()
+	secret.Namespace = b.config.Namespace
+	if _, err := b.config.Client.Secrets(b.config.Namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to restore secret %s/%s: %v", b.config.Namespace, secret.Name, err)
+	}
+	backupLog.Infof("Restored secret %s/%s from the CA backup %s of %s", b.config.Namespace, secret.Name,
+		name, doc.Time.Format(time.RFC3339))
+	if b.config.CAState != nil && doc.CAState != nil {
+		state := doc.CAState.DeepCopy()
+		state.SetNamespace(b.config.Namespace)
+		_, err := b.config.CAState.Create(ctx, state, metav1.CreateOptions{})
+		if kerrors.IsAlreadyExists(err) {
+			backupLog.Infof("Keep the existing CA state %s", state.GetName())
+		} else if err != nil {
+			return fmt.Errorf("failed to restore the CA state %s: %v", state.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// List returns the names of the backups in the store, from the oldest to the newest.
+func (b *Backuper) List(ctx context.Context) ([]string, error) {
+	names, err := b.config.Store.List(ctx, namePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the CA backups: %v", err)
+	}
+	backups := names[:0]
+	for _, name := range names {
+		if strings.HasPrefix(name, namePrefix) && strings.HasSuffix(name, nameSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// prune deletes the backups older than the retained ones.
+func (b *Backuper) prune(ctx context.Context) error {
+	if b.config.Retain == 0 {
+		return nil
+	}
+	names, err := b.List(ctx)
+	if err != nil {
+		return err
+	}
+	for len(names) > b.config.Retain {
+		if err := b.config.Store.Delete(ctx, names[0]); err != nil {
+			return fmt.Errorf("failed to delete the CA backup %s: %v", names[0], err)
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// stripObjectMeta returns a copy of obj without the metadata set by the API server, so that it can be
+// created again.
+func stripObjectMeta(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	stripped := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	for k, v := range obj.DeepCopy().Object {
+		if k != "metadata" {
+			stripped.Object[k] = v
+		}
+	}
+	stripped.SetName(obj.GetName())
+	stripped.SetLabels(obj.GetLabels())
+	stripped.SetAnnotations(obj.GetAnnotations())
+	return stripped
+}
@@ -0,0 +1,214 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cabackup
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/pki/kms"
+)
+
+const testNamespace = "istio-system"
+
+var testCAStateResource = schema.GroupVersionResource{Group: "security.istio.io", Version: "v1alpha1", Resource: "istiocastates"}
+
+func newTestEncryption(t *testing.T, dir string, seed byte) kms.Provider {
+	t.Helper()
+	kekFile := filepath.Join(dir, "kek")
+	kek := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{seed}, 32))
+	if err := ioutil.WriteFile(kekFile, []byte(kek), 0600); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := kms.NewLocalProvider(kekFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func newTestCAState() *unstructured.Unstructured {
+	state := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{"nextSerialNumber": "2a"},
+	}}
+	state.SetAPIVersion("security.istio.io/v1alpha1")
+	state.SetKind("IstioCAState")
+	state.SetName("istio-ca-state")
+	state.SetNamespace(testNamespace)
+	state.SetResourceVersion("42")
+	return state
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cabackup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewFileStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryption := newTestEncryption(t, dir, 0x42)
+
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: CASecret, Namespace: testNamespace, ResourceVersion: "7"},
+		Type:       "istio.io/ca-root",
+		Data:       map[string][]byte{"ca-cert.pem": []byte("cert"), "ca-key.pem": []byte("private key")},
+	})
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newTestCAState())
+	config := Config{
+		Namespace:   testNamespace,
+		Client:      client.CoreV1(),
+		CAState:     dynamicClient.Resource(testCAStateResource).Namespace(testNamespace),
+		CAStateName: "istio-ca-state",
+		Store:       store,
+		Encryption:  encryption,
+		Retain:      2,
+	}
+	backuper, err := NewBackuper(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	backuper.now = func() time.Time { return now }
+
+	var names []string
+	for i := 0; i < 3; i++ {
+		name, err := backuper.Backup(context.TODO())
+		if err != nil {
+			t.Fatalf("Backup() error: %v", err)
+		}
+		names = append(names, name)
+		now = now.Add(time.Hour)
+	}
+	if names[0] != "istio-ca-backup-20200501T000000Z.pem" {
+		t.Errorf("unexpected backup name %s", names[0])
+	}
+	listed, err := backuper.List(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(listed, ",") != strings.Join(names[1:], ",") {
+		t.Errorf("the retained backups are %v, want %v", listed, names[1:])
+	}
+	data, err := store.Get(context.TODO(), names[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("private key")) || bytes.Contains(data, []byte(base64.StdEncoding.EncodeToString([]byte("private key")))) {
+		t.Errorf("the backup holds the plaintext private key")
+	}
+
+	// Restore refuses to overwrite the existing secret.
+	if err := backuper.Restore(context.TODO(), ""); err == nil {
+		t.Errorf("Restore() should not overwrite the existing CA secret")
+	}
+
+	// Restore the latest backup after the CA secret and the CA state are lost.
+	if err := client.CoreV1().Secrets(testNamespace).Delete(context.TODO(), CASecret, metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.CAState.Delete(context.TODO(), "istio-ca-state", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := backuper.Restore(context.TODO(), ""); err != nil {
+		t.Fatalf("Restore() error: %v", err)
+	}
+	secret, err := client.CoreV1().Secrets(testNamespace).Get(context.TODO(), CASecret, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("the CA secret is not restored: %v", err)
+	}
+	if string(secret.Data["ca-key.pem"]) != "private key" || secret.Type != "istio.io/ca-root" {
+		t.Errorf("unexpected restored secret %+v", secret)
+	}
+	state, err := config.CAState.Get(context.TODO(), "istio-ca-state", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("the CA state is not restored: %v", err)
+	}
+	if next, _, _ := unstructured.NestedString(state.Object, "status", "nextSerialNumber"); next != "2a" {
+		t.Errorf("the restored next serial number is %q, want 2a", next)
+	}
+
+	// A backup cannot be restored with another key.
+	other, err := NewBackuper(Config{Namespace: "other", Client: client.CoreV1(), Store: store,
+		Encryption: newTestEncryption(t, dir, 0x43)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Restore(context.TODO(), names[2]); err == nil {
+		t.Errorf("Restore() with another key should fail")
+	}
+}
+
+func TestRestoreWithoutBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cabackup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backuper, err := NewBackuper(Config{Namespace: testNamespace, Client: fake.NewSimpleClientset().CoreV1(),
+		Store: store, Encryption: newTestEncryption(t, dir, 0x42)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backuper.Restore(context.TODO(), ""); err != ErrNoBackup {
+		t.Errorf("Restore() without a backup returned %v, want ErrNoBackup", err)
+	}
+	if _, err := backuper.Backup(context.TODO()); err == nil {
+		t.Errorf("Backup() without a CA secret should fail")
+	}
+}
+
+func TestNewBackuperErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cabackup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryption := newTestEncryption(t, dir, 0x42)
+	for _, config := range []Config{
+		{Encryption: encryption},
+		{Store: store},
+		{Store: store, Encryption: encryption, Retain: -1},
+	} {
+		if _, err := NewBackuper(config); err == nil {
+			t.Errorf("NewBackuper(%+v) should fail", config)
+		}
+	}
+}
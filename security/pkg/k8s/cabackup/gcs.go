@@ -0,0 +1,129 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cabackup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	defaultGCSEndpoint = "https://storage.googleapis.com"
+	gcsReadWriteScope  = "https://www.googleapis.com/auth/devstorage.read_write"
+)
+
+// gcsStore stores the objects in a Google Cloud Storage bucket with the JSON API.
+type gcsStore struct {
+	client   *http.Client
+	endpoint string
+	bucket   string
+	prefix   string
+}
+
+// NewGCSStore returns a Store of the objects under prefix in bucket, authenticating with the
+// application default credentials, e.g. workload identity on GKE.
+func NewGCSStore(ctx context.Context, bucket, prefix string) (Store, error) {
+	client, err := google.DefaultClient(ctx, gcsReadWriteScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Google credentials: %v", err)
+	}
+	return newGCSStore(client, defaultGCSEndpoint, bucket, prefix)
+}
+
+func newGCSStore(client *http.Client, endpoint, bucket, prefix string) (Store, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("a GCS bucket is required")
+	}
+	return &gcsStore{client: client, endpoint: strings.TrimSuffix(endpoint, "/"), bucket: bucket, prefix: prefix}, nil
+}
+
+func (s *gcsStore) objectURL(name string) string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", s.endpoint, url.PathEscape(s.bucket), url.PathEscape(s.prefix+name))
+}
+
+// Put implements Store.
+func (s *gcsStore) Put(ctx context.Context, name string, data []byte) error {
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", s.endpoint,
+		url.PathEscape(s.bucket), url.QueryEscape(s.prefix+name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	_, err = doRequest(s.client, req)
+	return err
+}
+
+// Get implements Store.
+func (s *gcsStore) Get(ctx context.Context, name string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(name)+"?alt=media", nil)
+	if err != nil {
+		return nil, err
+	}
+	return doRequest(s.client, req)
+}
+
+// List implements Store.
+func (s *gcsStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	pageToken := ""
+	for {
+		query := url.Values{"prefix": {s.prefix + prefix}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		u := fmt.Sprintf("%s/storage/v1/b/%s/o?%s", s.endpoint, url.PathEscape(s.bucket), query.Encode())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := doRequest(s.client, req)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse the GCS objects: %v", err)
+		}
+		for _, item := range page.Items {
+			names = append(names, strings.TrimPrefix(item.Name, s.prefix))
+		}
+		if pageToken = page.NextPageToken; pageToken == "" {
+			return names, nil
+		}
+	}
+}
+
+// Delete implements Store.
+func (s *gcsStore) Delete(ctx context.Context, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(name), nil)
+	if err != nil {
+		return err
+	}
+	_, err = doRequest(s.client, req)
+	return err
+}
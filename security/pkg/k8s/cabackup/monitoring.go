@@ -0,0 +1,43 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cabackup
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+var (
+	resultTag = monitoring.MustCreateLabel("result")
+
+	backupCounts = monitoring.NewSum(
+		"citadel_ca_backup_count",
+		"The number of backups of the CA secret and the issuance registry, by result.",
+		monitoring.WithLabels(resultTag),
+	)
+
+	lastBackupTimestamp = monitoring.NewGauge(
+		"citadel_ca_backup_last_success_timestamp",
+		"The unix timestamp, in seconds, of the last successful backup of the CA.",
+	)
+)
+
+func init() {
+	monitoring.MustRegister(backupCounts, lastBackupTimestamp)
+}
@@ -0,0 +1,97 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cabackup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// s3Store stores the objects in an S3 bucket, encrypted at rest with S3 managed keys in addition to
+// the encryption of the backups.
+type s3Store struct {
+	client s3iface.S3API
+	bucket string
+	prefix string
+}
+
+// NewS3Store returns a Store of the objects under prefix in bucket. The region and the credentials
+// are taken from the default AWS configuration.
+func NewS3Store(bucket, prefix string) (Store, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("an S3 bucket is required")
+	}
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create an AWS session: %v", err)
+	}
+	return &s3Store{client: s3.New(sess), bucket: bucket, prefix: prefix}, nil
+}
+
+// Put implements Store.
+func (s *s3Store) Put(ctx context.Context, name string, data []byte) error {
+	_, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:               aws.String(s.bucket),
+		Key:                  aws.String(s.prefix + name),
+		Body:                 bytes.NewReader(data),
+		ServerSideEncryption: aws.String(s3.ServerSideEncryptionAes256),
+	})
+	return err
+}
+
+// Get implements Store.
+func (s *s3Store) Get(ctx context.Context, name string) ([]byte, error) {
+	out, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return ioutil.ReadAll(out.Body)
+}
+
+// List implements Store.
+func (s *s3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := s.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.prefix + prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			names = append(names, strings.TrimPrefix(aws.StringValue(obj.Key), s.prefix))
+		}
+		return true
+	})
+	return names, err
+}
+
+// Delete implements Store.
+func (s *s3Store) Delete(ctx context.Context, name string) error {
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + name),
+	})
+	return err
+}
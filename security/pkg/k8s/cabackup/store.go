@@ -0,0 +1,137 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cabackup
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store stores the CA backups as named objects.
+type Store interface {
+	// Put writes the object name.
+	Put(ctx context.Context, name string, data []byte) error
+	// Get reads the object name.
+	Get(ctx context.Context, name string) ([]byte, error)
+	// List returns the names of the objects starting with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete deletes the object name.
+	Delete(ctx context.Context, name string) error
+}
+
+// NewStore returns the Store of the objects under storeURL, one of:
+//
+//	s3://<bucket>/<prefix>, with the default AWS credential chain
+//	gs://<bucket>/<prefix>, with the Google application default credentials
+//	azblob://<account>/<container>/<prefix>, with the SAS token in AZURE_STORAGE_SAS_TOKEN
+//	file:///<dir>
+func NewStore(ctx context.Context, storeURL string) (Store, error) {
+	u, err := url.Parse(storeURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA backup store URL %q: %v", storeURL, err)
+	}
+	prefix := strings.TrimPrefix(u.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	switch u.Scheme {
+	case "s3":
+		return NewS3Store(u.Host, prefix)
+	case "gs":
+		return NewGCSStore(ctx, u.Host, prefix)
+	case "azblob":
+		parts := strings.SplitN(prefix, "/", 2)
+		if parts[0] == "" {
+			return nil, fmt.Errorf("CA backup store URL %q has no container", storeURL)
+		}
+		return NewAzureStore(u.Host, parts[0], parts[1], os.Getenv(azureSASTokenEnv))
+	case "file":
+		return NewFileStore(u.Path)
+	default:
+		return nil, fmt.Errorf("unsupported CA backup store URL %q", storeURL)
+	}
+}
+
+// fileStore stores the objects as files in a directory, e.g. on a persistent volume.
+type fileStore struct {
+	dir string
+}
+
+// NewFileStore returns a Store of the files in dir.
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+// Put implements Store.
+func (s *fileStore) Put(_ context.Context, name string, data []byte) error {
+	tmp := filepath.Join(s.dir, "."+name)
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, name))
+}
+
+// Get implements Store.
+func (s *fileStore) Get(_ context.Context, name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(s.dir, name))
+}
+
+// List implements Store.
+func (s *fileStore) List(_ context.Context, prefix string) ([]string, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if f.Mode().IsRegular() && strings.HasPrefix(f.Name(), prefix) {
+			names = append(names, f.Name())
+		}
+	}
+	return names, nil
+}
+
+// Delete implements Store.
+func (s *fileStore) Delete(_ context.Context, name string) error {
+	return os.Remove(filepath.Join(s.dir, name))
+}
+
+// doRequest sends req with client and returns the response body, or an error if the response
+// status is not 2xx.
+func doRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s returned %s: %s", req.Method, req.URL.Path, resp.Status,
+			strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,286 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cabackup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// objects is an in-memory object store shared by the fake servers.
+type objects struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+}
+
+func (o *objects) put(name string, data []byte) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.data[name] = data
+}
+
+func (o *objects) get(name string) ([]byte, bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	data, ok := o.data[name]
+	return data, ok
+}
+
+func (o *objects) delete(name string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	delete(o.data, name)
+}
+
+func (o *objects) list(prefix string) []string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	var names []string
+	for name := range o.data {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// testStore puts, lists, gets and deletes objects in store, and checks the objects stored under prefix.
+func testStore(t *testing.T, store Store, stored *objects, prefix string) {
+	t.Helper()
+	ctx := context.TODO()
+	for _, name := range []string{"istio-ca-backup-1.pem", "istio-ca-backup-2.pem", "other"} {
+		if err := store.Put(ctx, name, []byte("data of "+name)); err != nil {
+			t.Fatalf("Put(%s) error: %v", name, err)
+		}
+	}
+	if _, ok := stored.get(prefix + "istio-ca-backup-1.pem"); !ok {
+		t.Fatalf("the object is not stored under %q: %v", prefix, stored.list(""))
+	}
+	names, err := store.List(ctx, "istio-ca-backup-")
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "istio-ca-backup-1.pem,istio-ca-backup-2.pem" {
+		t.Errorf("List() returned %v", names)
+	}
+	data, err := store.Get(ctx, "istio-ca-backup-2.pem")
+	if err != nil || string(data) != "data of istio-ca-backup-2.pem" {
+		t.Errorf("Get() returned %q, %v", data, err)
+	}
+	if err := store.Delete(ctx, "istio-ca-backup-1.pem"); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+	if _, ok := stored.get(prefix + "istio-ca-backup-1.pem"); ok {
+		t.Errorf("the deleted object is still stored")
+	}
+	if _, err := store.Get(ctx, "istio-ca-backup-1.pem"); err == nil {
+		t.Errorf("Get() of a deleted object should fail")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cabackup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewStore(context.TODO(), "file://"+dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := store.List(context.TODO(), "")
+	if err != nil || len(names) != 0 {
+		t.Errorf("List() of an empty store returned %v, %v", names, err)
+	}
+	ctx := context.TODO()
+	if err := store.Put(ctx, "istio-ca-backup-1.pem", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := store.Get(ctx, "istio-ca-backup-1.pem"); err != nil || string(data) != "data" {
+		t.Errorf("Get() returned %q, %v", data, err)
+	}
+	if err := store.Delete(ctx, "istio-ca-backup-1.pem"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGCSStore(t *testing.T) {
+	stored := &objects{data: map[string][]byte{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/bucket/o":
+			data, _ := ioutil.ReadAll(r.Body)
+			stored.put(r.URL.Query().Get("name"), data)
+		case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/bucket/o":
+			var page struct {
+				Items []map[string]string `json:"items"`
+			}
+			for _, name := range stored.list(r.URL.Query().Get("prefix")) {
+				page.Items = append(page.Items, map[string]string{"name": name})
+			}
+			_ = json.NewEncoder(w).Encode(page)
+		case strings.HasPrefix(r.URL.Path, "/storage/v1/b/bucket/o/"):
+			name := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/bucket/o/")
+			data, ok := stored.get(name)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method == http.MethodDelete {
+				stored.delete(name)
+				return
+			}
+			_, _ = w.Write(data)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	store, err := newGCSStore(server.Client(), server.URL, "bucket", "mesh/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, store, stored, "mesh/")
+}
+
+func TestAzureStore(t *testing.T) {
+	stored := &objects{data: map[string][]byte{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != "secret" || r.Header.Get("x-ms-version") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/container" && r.URL.Query().Get("comp") == "list" {
+			type blob struct {
+				Name string `xml:"Name"`
+			}
+			var page struct {
+				XMLName xml.Name `xml:"EnumerationResults"`
+				Blobs   []blob   `xml:"Blobs>Blob"`
+			}
+			for _, name := range stored.list(r.URL.Query().Get("prefix")) {
+				page.Blobs = append(page.Blobs, blob{Name: name})
+			}
+			_ = xml.NewEncoder(w).Encode(page)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/container/")
+		switch r.Method {
+		case http.MethodPut:
+			if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data, _ := ioutil.ReadAll(r.Body)
+			stored.put(name, data)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet, http.MethodDelete:
+			data, ok := stored.get(name)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method == http.MethodDelete {
+				stored.delete(name)
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			_, _ = w.Write(data)
+		}
+	}))
+	defer server.Close()
+	if _, err := newAzureStore(server.Client(), server.URL, "container", "", ""); err == nil {
+		t.Errorf("expected an error without a SAS token")
+	}
+	store, err := newAzureStore(server.Client(), server.URL, "container", "mesh/", "?sv=2019-12-12&sig=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, store, stored, "mesh/")
+}
+
+// fakeS3 implements the S3 API calls of s3Store on objects.
+type fakeS3 struct {
+	s3iface.S3API
+	objects *objects
+}
+
+func (f *fakeS3) PutObjectWithContext(_ aws.Context, in *s3.PutObjectInput, _ ...request.Option) (*s3.PutObjectOutput, error) {
+	data, _ := ioutil.ReadAll(in.Body)
+	f.objects.put(aws.StringValue(in.Key), data)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) GetObjectWithContext(_ aws.Context, in *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	data, ok := f.objects.get(aws.StringValue(in.Key))
+	if !ok {
+		return nil, &s3NotFound{}
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (f *fakeS3) ListObjectsV2PagesWithContext(_ aws.Context, in *s3.ListObjectsV2Input,
+	fn func(*s3.ListObjectsV2Output, bool) bool, _ ...request.Option) error {
+	page := &s3.ListObjectsV2Output{}
+	for _, name := range f.objects.list(aws.StringValue(in.Prefix)) {
+		page.Contents = append(page.Contents, &s3.Object{Key: aws.String(name)})
+	}
+	fn(page, true)
+	return nil
+}
+
+func (f *fakeS3) DeleteObjectWithContext(_ aws.Context, in *s3.DeleteObjectInput, _ ...request.Option) (*s3.DeleteObjectOutput, error) {
+	f.objects.delete(aws.StringValue(in.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+type s3NotFound struct{}
+
+func (*s3NotFound) Error() string { return "NoSuchKey" }
+
+func TestS3Store(t *testing.T) {
+	stored := &objects{data: map[string][]byte{}}
+	store := &s3Store{client: &fakeS3{objects: stored}, bucket: "bucket", prefix: "mesh/"}
+	testStore(t, store, stored, "mesh/")
+}
+
+func TestNewStoreErrors(t *testing.T) {
+	for _, storeURL := range []string{
+		"ftp://host/dir",
+		"azblob://account",
+		"://invalid",
+	} {
+		if _, err := NewStore(context.TODO(), storeURL); err == nil {
+			t.Errorf("NewStore(%q) should fail", storeURL)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package caconfig watches the IstioCAConfig custom resource, which configures the Istio CA and the
+// certificate controllers at runtime.
+package caconfig
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+
+	"istio.io/istio/security/pkg/k8s/chiron"
+	certutil "istio.io/istio/security/pkg/util"
+	"istio.io/pkg/log"
+)
+
+const (
+	// Kind is the kind of the IstioCAConfig custom resource.
+	Kind = "IstioCAConfig"
+	// DefaultName is the default name of the IstioCAConfig custom resource.
+	DefaultName = "default"
+)
+
+// GroupVersionResource of the cluster scoped IstioCAConfig custom resource.
+var GroupVersionResource = schema.GroupVersionResource{
+	Group:    "security.istio.io",
+	Version:  "v1alpha1",
+	Resource: "istiocaconfigs",
+}
+
+var caConfigLog = log.RegisterScope("caconfig", "CA config controller log", 0)
+
+// Spec is the spec of the IstioCAConfig resource. The unset fields keep the values of the istiod
+// flags and environment variables.
+type Spec struct {
+	// WorkloadCertTTL is the TTL of the workload certs that do not request a TTL.
+	WorkloadCertTTL *metav1.Duration `json:"workloadCertTTL,omitempty"`
+	// MaxWorkloadCertTTL is the max TTL of the workload certs.
+	MaxWorkloadCertTTL *metav1.Duration `json:"maxWorkloadCertTTL,omitempty"`
+	// MinWorkloadCertTTL is the min TTL of the workload certs.
+	MinWorkloadCertTTL *metav1.Duration `json:"minWorkloadCertTTL,omitempty"`
+	// MinRSAKeySize is the minimum size in bits of the RSA keys of the CSRs.
+	MinRSAKeySize int `json:"minRSAKeySize,omitempty"`
+	// CertController configures the DNS certificate controllers.
+	CertController *CertControllerSpec `json:"certController,omitempty"`
+}
+
+// CertControllerSpec configures the DNS certificate controllers.
+type CertControllerSpec struct {
+	// RenewalStrategy is when the certs are renewed: remaining:<percentage> of the lifetime, i.e. the
+	// grace period ratio, before:<duration> the expiration or after:<duration> the issuance.
+	RenewalStrategy string `json:"renewalStrategy,omitempty"`
+	// WebhookServices are the webhook or aggregated API services, as namespace/service[:secret],
+	// whose serving certs are provisioned and rotated.
+	WebhookServices []string `json:"webhookServices,omitempty"`
+}
+
+// Status is the status of the IstioCAConfig resource.
+type Status struct {
+	// ObservedGeneration is the generation of the spec last processed.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Error is the reason the spec is not applied, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Validate returns an error if the spec is invalid.
+func (s *Spec) Validate() error {
+	for name, d := range map[string]*metav1.Duration{
+		"workloadCertTTL":    s.WorkloadCertTTL,
+		"maxWorkloadCertTTL": s.MaxWorkloadCertTTL,
+		"minWorkloadCertTTL": s.MinWorkloadCertTTL,
+	} {
+		if d != nil && d.Duration <= 0 {
+			return fmt.Errorf("%s must be positive", name)
+		}
+	}
+	if s.MinRSAKeySize < 0 {
+		return fmt.Errorf("minRSAKeySize must not be negative")
+	}
+	if _, err := s.RenewalStrategy(); err != nil {
+		return err
+	}
+	_, err := s.WebhookServices()
+	return err
+}
+
+// RenewalStrategy returns the renewal strategy of the certificate controllers, or nil if not set.
+func (s *Spec) RenewalStrategy() (certutil.RenewalStrategy, error) {
+	if s.CertController == nil || s.CertController.RenewalStrategy == "" {
+		return nil, nil
+	}
+	return certutil.ParseRenewalStrategy(s.CertController.RenewalStrategy)
+}
+
+// WebhookServices returns the webhook services whose serving certs are managed, or nil if not set.
+func (s *Spec) WebhookServices() ([]chiron.WebhookService, error) {
+	if s.CertController == nil || s.CertController.WebhookServices == nil {
+		return nil, nil
+	}
+	services, err := chiron.ParseWebhookServices(strings.Join(s.CertController.WebhookServices, ","))
+	if err != nil {
+		return nil, err
+	}
+	if services == nil {
+		services = []chiron.WebhookService{}
+	}
+	return services, nil
+}
+
+// Handler applies a spec. A deleted IstioCAConfig is handled as an empty spec.
+type Handler func(spec *Spec) error
+
+// Controller watches the IstioCAConfig resource and passes its spec to the handlers. The outcome is
+// recorded in the status of the resource.
+type Controller struct {
+	client dynamic.NamespaceableResourceInterface
+	name   string
+
+	informer cache.Controller
+
+	mutex    sync.Mutex
+	handlers []Handler
+}
+
+// NewController returns a new Controller of the IstioCAConfig resource name.
+func NewController(client dynamic.Interface, name string) *Controller {
+	c := &Controller{
+		client: client.Resource(GroupVersionResource),
+		name:   name,
+	}
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	_, c.informer = cache.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.FieldSelector = selector
+				return c.client.List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.FieldSelector = selector
+				return c.client.Watch(context.TODO(), options)
+			},
+		},
+		&unstructured.Unstructured{},
+		0,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: c.configChanged,
+			UpdateFunc: func(_, newObj interface{}) {
+				c.configChanged(newObj)
+			},
+			DeleteFunc: c.configDeleted,
+		})
+	return c
+}
+
+// AddHandler adds a handler of the spec. The handlers must be added before Run.
+func (c *Controller) AddHandler(h Handler) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.handlers = append(c.handlers, h)
+}
+
+// Run watches the IstioCAConfig resource until stop is closed.
+func (c *Controller) Run(stop <-chan struct{}) {
+	c.informer.Run(stop)
+}
+
+// HasSynced returns true once the IstioCAConfig resource was initially listed.
+func (c *Controller) HasSynced() bool {
+	return c.informer.HasSynced()
+}
+
+func (c *Controller) configChanged(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || u.GetName() != c.name {
+		return
+	}
+	status := Status{ObservedGeneration: u.GetGeneration()}
+	spec, err := specFromObject(u)
+	if err == nil {
+		err = spec.Validate()
+	}
+	if err == nil {
+		err = c.apply(spec)
+	} else {
+		err = fmt.Errorf("invalid %s %s, the previous configuration is kept: %v", Kind, c.name, err)
+	}
+	if err != nil {
+		caConfigLog.Errorf("%v", err)
+		status.Error = err.Error()
+	} else {
+		caConfigLog.Infof("Applied %s %s (generation %d)", Kind, c.name, u.GetGeneration())
+	}
+	if err := c.writeStatus(u, &status); err != nil {
+		caConfigLog.Warnf("failed to update the status of %s %s: %v", Kind, c.name, err)
+	}
+}
+
+func (c *Controller) configDeleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if u, ok := obj.(*unstructured.Unstructured); !ok || u.GetName() != c.name {
+		return
+	}
+	caConfigLog.Infof("%s %s is deleted, reverting to the default configuration", Kind, c.name)
+	if err := c.apply(&Spec{}); err != nil {
+		caConfigLog.Errorf("%v", err)
+	}
+}
+
+// apply passes spec to all the handlers, returning their errors.
+func (c *Controller) apply(spec *Spec) error {
+	c.mutex.Lock()
+	handlers := c.handlers
+	c.mutex.Unlock()
+	var errs []string
+	for _, h := range handlers {
+		if err := h(spec); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to apply %s %s: %s", Kind, c.name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// writeStatus updates the status of obj, unless it is unchanged. Skipping unchanged statuses avoids
+// processing the resource again for the update of its own status.
+func (c *Controller) writeStatus(obj *unstructured.Unstructured, status *Status) error {
+	current := &Status{}
+	if m, found, _ := unstructured.NestedMap(obj.Object, "status"); found {
+		_ = runtime.DefaultUnstructuredConverter.FromUnstructured(m, current)
+	}
+	if reflect.DeepEqual(current, status) {
+		return nil
+	}
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(status)
+	if err != nil {
+		return err
+	}
+	updated := obj.DeepCopy()
+	updated.Object["status"] = m
+	_, err = c.client.UpdateStatus(context.TODO(), updated, metav1.UpdateOptions{})
+	return err
+}
+
+func specFromObject(obj *unstructured.Unstructured) (*Spec, error) {
+	spec := &Spec{}
+	m, found, err := unstructured.NestedMap(obj.Object, "spec")
+	if err != nil || !found {
+		return spec, err
+	}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(m, spec); err != nil {
+		return nil, fmt.Errorf("failed to parse %s %s (%v)", Kind, obj.GetName(), err)
+	}
+	return spec, nil
+}
@@ -0,0 +1,130 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caconfig
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func newConfig(spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetAPIVersion(GroupVersionResource.GroupVersion().String())
+	obj.SetKind(Kind)
+	obj.SetName(DefaultName)
+	return obj
+}
+
+func TestSpecFromObject(t *testing.T) {
+	spec, err := specFromObject(newConfig(map[string]interface{}{
+		"workloadCertTTL": "12h",
+		"minRSAKeySize":   int64(3072),
+		"certController": map[string]interface{}{
+			"renewalStrategy": "before:24h",
+			"webhookServices": []interface{}{"foo/webhook:webhook-certs"},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("failed to parse the spec: %v", err)
+	}
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("unexpected invalid spec: %v", err)
+	}
+	if spec.WorkloadCertTTL.Duration != 12*time.Hour || spec.MaxWorkloadCertTTL != nil || spec.MinRSAKeySize != 3072 {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+	services, _ := spec.WebhookServices()
+	if len(services) != 1 || services[0].SecretName != "webhook-certs" {
+		t.Errorf("unexpected webhook services %v", services)
+	}
+
+	invalid := []*Spec{
+		{WorkloadCertTTL: &metav1.Duration{Duration: -time.Hour}},
+		{MinRSAKeySize: -1},
+		{CertController: &CertControllerSpec{RenewalStrategy: "sometimes"}},
+		{CertController: &CertControllerSpec{WebhookServices: []string{"no-namespace"}}},
+	}
+	for _, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Errorf("expected spec %+v to be invalid", s)
+		}
+	}
+}
+
+func TestController(t *testing.T) {
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme(),
+		newConfig(map[string]interface{}{"workloadCertTTL": "12h"}))
+	c := NewController(client, DefaultName)
+	specs := make(chan *Spec, 10)
+	c.AddHandler(func(spec *Spec) error {
+		specs <- spec
+		return nil
+	})
+	stop := make(chan struct{})
+	defer close(stop)
+	go c.Run(stop)
+
+	next := func() *Spec {
+		select {
+		case spec := <-specs:
+			return spec
+		case <-time.After(5 * time.Second):
+			t.Fatal("the spec was not handled")
+			return nil
+		}
+	}
+	if spec := next(); spec.WorkloadCertTTL == nil || spec.WorkloadCertTTL.Duration != 12*time.Hour {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+
+	// An invalid spec is not applied, and reported in the status.
+	resources := client.Resource(GroupVersionResource)
+	obj, err := resources.Get(context.TODO(), DefaultName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the config: %v", err)
+	}
+	obj.Object["spec"] = map[string]interface{}{"workloadCertTTL": "-1h"}
+	if _, err := resources.Update(context.TODO(), obj, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update the config: %v", err)
+	}
+	for i := 0; ; i++ {
+		obj, _ = resources.Get(context.TODO(), DefaultName, metav1.GetOptions{})
+		if msg, _, _ := unstructured.NestedString(obj.Object, "status", "error"); msg != "" {
+			break
+		}
+		if i == 50 {
+			t.Fatal("the invalid spec was not reported in the status")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	select {
+	case spec := <-specs:
+		t.Errorf("unexpected spec %+v", spec)
+	default:
+	}
+
+	if err := resources.Delete(context.TODO(), DefaultName, metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete the config: %v", err)
+	}
+	if spec := next(); spec.WorkloadCertTTL != nil {
+		t.Errorf("expected an empty spec after the deletion, got %+v", spec)
+	}
+}
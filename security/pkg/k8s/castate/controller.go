@@ -0,0 +1,315 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package castate persists the operational state of an Istio CA in an IstioCAState
+// custom resource.
+package castate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"istio.io/pkg/log"
+)
+
+const (
+	// Kind is the kind of the IstioCAState custom resource.
+	Kind = "IstioCAState"
+	// DefaultName is the default name of the IstioCAState custom resource.
+	DefaultName = "istio-ca-state"
+	// maxRotationHistory is the max number of root cert rotations kept in the state.
+	maxRotationHistory = 10
+	// maxReserveAttempts is the max number of attempts to reserve serial numbers when other CA
+	// replicas update the resource concurrently.
+	maxReserveAttempts = 10
+)
+
+// GroupVersionResource of the IstioCAState custom resource.
+var GroupVersionResource = schema.GroupVersionResource{
+	Group:    "security.istio.io",
+	Version:  "v1alpha1",
+	Resource: "istiocastates",
+}
+
+var caStateLog = log.RegisterScope("castate", "CA state controller log", 0)
+
+// RootCertRotation records a rotation of the CA root cert.
+type RootCertRotation struct {
+	Time                   metav1.Time `json:"time"`
+	PreviousRootCertSHA256 string      `json:"previousRootCertSHA256,omitempty"`
+	RootCertSHA256         string      `json:"rootCertSHA256"`
+}
+
+// State is the operational state of an Istio CA, stored as the status of the IstioCAState resource.
+type State struct {
+	// RootCertSHA256 is the hex encoded SHA-256 fingerprint of the current root cert.
+	RootCertSHA256 string `json:"rootCertSHA256,omitempty"`
+	// RotationHistory holds the most recent root cert rotations, oldest first.
+	RotationHistory []RootCertRotation `json:"rotationHistory,omitempty"`
+	// LastIssuedSerial is the hex encoded serial number of the last issued cert.
+	LastIssuedSerial string `json:"lastIssuedSerial,omitempty"`
+	// LastIssueTime is the time of the last issued cert.
+	LastIssueTime *metav1.Time `json:"lastIssueTime,omitempty"`
+	// LastSyncTime is the time the CA was last synced with the CA secret.
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// NextSerialNumber is the hex encoded first serial number that is not reserved yet for
+	// sequential serial numbers.
+	NextSerialNumber string `json:"nextSerialNumber,omitempty"`
+}
+
+// Controller keeps the state of an Istio CA in memory and periodically flushes it to the
+// IstioCAState resource. It implements ca.CAStateRecorder.
+type Controller struct {
+	client dynamic.ResourceInterface
+	name   string
+
+	mutex sync.Mutex
+	state State
+	dirty bool
+}
+
+// NewController returns a new Controller for the IstioCAState resource with the given
+// namespace and name.
+func NewController(client dynamic.Interface, namespace, name string) *Controller {
+	return &Controller{
+		client: client.Resource(GroupVersionResource).Namespace(namespace),
+		name:   name,
+	}
+}
+
+// Load loads the previously persisted state, if any, so that it survives restarts.
+func (c *Controller) Load(ctx context.Context) error {
+	obj, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get %s %s (%v)", Kind, c.name, err)
+	}
+	state, err := stateFromObject(obj)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.state = *state
+	return nil
+}
+
+// GetState returns a copy of the current state.
+func (c *Controller) GetState() State {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	state := c.state
+	state.RotationHistory = append([]RootCertRotation(nil), c.state.RotationHistory...)
+	return state
+}
+
+// RecordRootCert implements ca.CAStateRecorder.
+func (c *Controller) RecordRootCert(rootCertPem []byte) {
+	fingerprint := Fingerprint(rootCertPem)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if fingerprint == c.state.RootCertSHA256 {
+		return
+	}
+	if c.state.RootCertSHA256 != "" {
+		c.state.RotationHistory = append(c.state.RotationHistory, RootCertRotation{
+			Time:                   metav1.Now(),
+			PreviousRootCertSHA256: c.state.RootCertSHA256,
+			RootCertSHA256:         fingerprint,
+		})
+		if n := len(c.state.RotationHistory); n > maxRotationHistory {
+			c.state.RotationHistory = c.state.RotationHistory[n-maxRotationHistory:]
+		}
+	}
+	c.state.RootCertSHA256 = fingerprint
+	c.dirty = true
+}
+
+// RecordIssuedCert implements ca.CAStateRecorder.
+func (c *Controller) RecordIssuedCert(serial *big.Int) {
+	now := metav1.Now()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.state.LastIssuedSerial = serial.Text(16)
+	c.state.LastIssueTime = &now
+	c.dirty = true
+}
+
+// RecordSync implements ca.CAStateRecorder.
+func (c *Controller) RecordSync() {
+	now := metav1.Now()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.state.LastSyncTime = &now
+	c.dirty = true
+}
+
+// ReserveSerialNumbers implements ca.SerialNumberReserver. The reservation is written to the
+// IstioCAState resource right away, and is retried if another CA replica updated it concurrently,
+// so that the same serial numbers are never reserved twice.
+func (c *Controller) ReserveSerialNumbers(ctx context.Context, size int64) (*big.Int, error) {
+	for attempt := 0; attempt < maxReserveAttempts; attempt++ {
+		first, err := c.reserveSerialNumbers(ctx, size)
+		if errors.IsConflict(err) || errors.IsAlreadyExists(err) {
+			continue
+		}
+		return first, err
+	}
+	return nil, fmt.Errorf("failed to reserve serial numbers in %s %s after %d attempts", Kind, c.name, maxReserveAttempts)
+}
+
+func (c *Controller) reserveSerialNumbers(ctx context.Context, size int64) (*big.Int, error) {
+	obj, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	create := errors.IsNotFound(err)
+	if create {
+		obj = &unstructured.Unstructured{}
+		obj.SetAPIVersion(GroupVersionResource.GroupVersion().String())
+		obj.SetKind(Kind)
+		obj.SetName(c.name)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s (%v)", Kind, c.name, err)
+	}
+
+	// Serial numbers must be positive, so the first reserved serial number is 1.
+	first := big.NewInt(1)
+	if next, found, _ := unstructured.NestedString(obj.Object, "status", "nextSerialNumber"); found {
+		if _, ok := first.SetString(next, 16); !ok {
+			return nil, fmt.Errorf("invalid next serial number %q in %s %s", next, Kind, c.name)
+		}
+	}
+	next := new(big.Int).Add(first, big.NewInt(size)).Text(16)
+	if err = unstructured.SetNestedField(obj.Object, next, "status", "nextSerialNumber"); err != nil {
+		return nil, err
+	}
+	if create {
+		_, err = c.client.Create(ctx, obj, metav1.CreateOptions{})
+	} else {
+		_, err = c.client.Update(ctx, obj, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	c.state.NextSerialNumber = next
+	c.mutex.Unlock()
+	return first, nil
+}
+
+// Flush writes the current state to the IstioCAState resource if it changed since the last flush.
+func (c *Controller) Flush(ctx context.Context) error {
+	c.mutex.Lock()
+	if !c.dirty {
+		c.mutex.Unlock()
+		return nil
+	}
+	state := c.state
+	c.dirty = false
+	c.mutex.Unlock()
+
+	if err := c.write(ctx, &state); err != nil {
+		c.mutex.Lock()
+		c.dirty = true
+		c.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run flushes the state every interval until stopCh is closed.
+func (c *Controller) Run(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Flush(context.TODO()); err != nil {
+				caStateLog.Errorf("Failed to flush CA state: %v", err)
+			}
+		case <-stopCh:
+			if err := c.Flush(context.TODO()); err != nil {
+				caStateLog.Errorf("Failed to flush CA state: %v", err)
+			}
+			return
+		}
+	}
+}
+
+func (c *Controller) write(ctx context.Context, state *State) error {
+	status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(state)
+	if err != nil {
+		return fmt.Errorf("failed to convert %s (%v)", Kind, err)
+	}
+	obj, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		obj = &unstructured.Unstructured{}
+		obj.SetAPIVersion(GroupVersionResource.GroupVersion().String())
+		obj.SetKind(Kind)
+		obj.SetName(c.name)
+		obj.Object["status"] = status
+		if _, err = c.client.Create(ctx, obj, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create %s %s (%v)", Kind, c.name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get %s %s (%v)", Kind, c.name, err)
+	}
+	// The serial number reservations are written directly, keep the stored one.
+	if next, found, _ := unstructured.NestedString(obj.Object, "status", "nextSerialNumber"); found {
+		status["nextSerialNumber"] = next
+	}
+	obj.Object["status"] = status
+	if _, err = c.client.Update(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update %s %s (%v)", Kind, c.name, err)
+	}
+	return nil
+}
+
+func stateFromObject(obj *unstructured.Unstructured) (*State, error) {
+	state := &State{}
+	status, found, err := unstructured.NestedMap(obj.Object, "status")
+	if err != nil || !found {
+		return state, err
+	}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(status, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s %s (%v)", Kind, obj.GetName(), err)
+	}
+	return state, nil
+}
+
+// Fingerprint returns the hex encoded SHA-256 fingerprint of the first cert in certPem.
+func Fingerprint(certPem []byte) string {
+	der := certPem
+	if block, _ := pem.Decode(certPem); block != nil {
+		der = block.Bytes
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
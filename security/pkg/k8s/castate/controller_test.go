@@ -0,0 +1,127 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package castate
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func TestControllerFlushAndLoad(t *testing.T) {
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	ctx := context.Background()
+
+	c := NewController(client, "istio-system", DefaultName)
+	if err := c.Load(ctx); err != nil {
+		t.Fatalf("Load() on missing resource returned error: %v", err)
+	}
+	c.RecordRootCert([]byte("root-1"))
+	c.RecordIssuedCert(big.NewInt(0xabc))
+	c.RecordSync()
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	c.RecordRootCert([]byte("root-1"))
+	c.RecordRootCert([]byte("root-2"))
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+
+	restarted := NewController(client, "istio-system", DefaultName)
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	state := restarted.GetState()
+	if state.RootCertSHA256 != Fingerprint([]byte("root-2")) {
+		t.Errorf("unexpected root cert fingerprint %q", state.RootCertSHA256)
+	}
+	if len(state.RotationHistory) != 1 {
+		t.Fatalf("expected 1 rotation, got %d", len(state.RotationHistory))
+	}
+	if state.RotationHistory[0].PreviousRootCertSHA256 != Fingerprint([]byte("root-1")) {
+		t.Errorf("unexpected previous root cert fingerprint %q", state.RotationHistory[0].PreviousRootCertSHA256)
+	}
+	if state.LastIssuedSerial != "abc" {
+		t.Errorf("unexpected last issued serial %q", state.LastIssuedSerial)
+	}
+	if state.LastIssueTime == nil || state.LastSyncTime == nil {
+		t.Errorf("issue and sync times should be recorded: %+v", state)
+	}
+}
+
+func TestControllerRotationHistoryLimit(t *testing.T) {
+	c := NewController(fake.NewSimpleDynamicClient(runtime.NewScheme()), "istio-system", DefaultName)
+	for i := 0; i <= maxRotationHistory+2; i++ {
+		c.RecordRootCert(big.NewInt(int64(i)).Bytes())
+	}
+	state := c.GetState()
+	if len(state.RotationHistory) != maxRotationHistory {
+		t.Errorf("expected %d rotations, got %d", maxRotationHistory, len(state.RotationHistory))
+	}
+	last := state.RotationHistory[len(state.RotationHistory)-1]
+	if last.RootCertSHA256 != state.RootCertSHA256 {
+		t.Errorf("last rotation should be to the current root cert")
+	}
+}
+
+func TestControllerReserveSerialNumbers(t *testing.T) {
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	ctx := context.Background()
+
+	// Two CA replicas share the resource.
+	a := NewController(client, "istio-system", DefaultName)
+	b := NewController(client, "istio-system", DefaultName)
+	expected := []struct {
+		c     *Controller
+		first int64
+	}{
+		{c: a, first: 1},
+		{c: b, first: 101},
+		{c: a, first: 201},
+	}
+	for i, e := range expected {
+		first, err := e.c.ReserveSerialNumbers(ctx, 100)
+		if err != nil {
+			t.Fatalf("reservation %d: ReserveSerialNumbers() error: %v", i, err)
+		}
+		if first.Int64() != e.first {
+			t.Errorf("reservation %d: got first serial number %v, expected %d", i, first, e.first)
+		}
+	}
+
+	// Flushing the state of a replica keeps the reservations of the others.
+	b.RecordSync()
+	if err := b.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	restarted := NewController(client, "istio-system", DefaultName)
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if next := restarted.GetState().NextSerialNumber; next != big.NewInt(301).Text(16) {
+		t.Errorf("unexpected next serial number %q", next)
+	}
+	first, err := restarted.ReserveSerialNumbers(ctx, 100)
+	if err != nil {
+		t.Fatalf("ReserveSerialNumbers() error: %v", err)
+	}
+	if first.Int64() != 301 {
+		t.Errorf("got first serial number %v after restart, expected 301", first)
+	}
+}
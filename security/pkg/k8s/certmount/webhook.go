@@ -0,0 +1,188 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package certmount implements a mutating admission webhook mounting the credentials of the mesh
+// identity into the annotated pods, so that the workloads without a sidecar can use it without
+// hand-written volumes. No secret holds the workload certs: istiod signs them over CSRs, so the pods
+// get the projected service account token authenticating these CSRs and the root cert ConfigMap.
+package certmount
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	admission "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
+	"istio.io/istio/security/pkg/k8s/tokenreview"
+	"istio.io/pkg/log"
+)
+
+const (
+	// WebhookPath is the path of the webhook on the HTTPS server of istiod.
+	WebhookPath = "/mount-certs"
+
+	// Annotation requests the credentials to be mounted into the pod when set to "true".
+	Annotation = "security.istio.io/mountWorkloadCert"
+
+	// TokenPath is where the istio-token file, the service account token for the CA, is mounted. It
+	// is the path read by the istio agent.
+	TokenPath = "/var/run/secrets/tokens"
+	// RootCertPath is where the root cert ConfigMap is mounted.
+	RootCertPath = "/var/run/secrets/istio"
+
+	tokenVolumeName    = "istio-token"
+	rootCertVolumeName = "istiod-ca-cert"
+
+	// tokenExpirationSeconds matches the expiration of the token of the sidecars, the kubelet
+	// refreshes it before it expires.
+	tokenExpirationSeconds = 43200
+)
+
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Admit mounts the credentials into the pod if it is annotated with Annotation.
+func Admit(req *admission.AdmissionRequest) *admission.AdmissionResponse {
+	if req.Kind.Kind != "Pod" || req.Operation != admission.Create {
+		return &admission.AdmissionResponse{Allowed: true}
+	}
+	pod := &corev1.Pod{}
+	if err := json.Unmarshal(req.Object.Raw, pod); err != nil {
+		return &admission.AdmissionResponse{Result: &metav1.Status{Message: fmt.Sprintf("could not decode the pod: %v", err)}}
+	}
+	patch := createPatch(pod)
+	if len(patch) == 0 {
+		return &admission.AdmissionResponse{Allowed: true}
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return &admission.AdmissionResponse{Result: &metav1.Status{Message: fmt.Sprintf("could not encode the patch: %v", err)}}
+	}
+	log.Debugf("Mounting the workload credentials into pod %s/%s%s", req.Namespace, pod.Name, pod.GenerateName)
+	patchType := admission.PatchTypeJSONPatch
+	return &admission.AdmissionResponse{Allowed: true, Patch: patchBytes, PatchType: &patchType}
+}
+
+// createPatch returns the JSON patch adding the credential volumes to the pod and mounting them into its
+// containers. The volumes already present in the pod are left as is.
+func createPatch(pod *corev1.Pod) []patchOperation {
+	if pod.Annotations[Annotation] != "true" {
+		return nil
+	}
+	expiration := int64(tokenExpirationSeconds)
+	volumes := []corev1.Volume{
+		{
+			Name: tokenVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{{
+						ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+							Path:              "istio-token",
+							Audience:          tokenreview.DefaultAudience,
+							ExpirationSeconds: &expiration,
+						},
+					}},
+				},
+			},
+		},
+		{
+			Name: rootCertVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: controller.CACertNamespaceConfigMap},
+				},
+			},
+		},
+	}
+	mounts := []corev1.VolumeMount{
+		{Name: tokenVolumeName, MountPath: TokenPath, ReadOnly: true},
+		{Name: rootCertVolumeName, MountPath: RootCertPath, ReadOnly: true},
+	}
+
+	existing := map[string]bool{}
+	for _, v := range pod.Spec.Volumes {
+		existing[v.Name] = true
+	}
+	var patch []patchOperation
+	added := map[string]bool{}
+	for _, v := range volumes {
+		if existing[v.Name] {
+			continue
+		}
+		patch = appendOperation(patch, "/spec/volumes", len(pod.Spec.Volumes) == 0 && len(added) == 0, v)
+		added[v.Name] = true
+	}
+	for i, c := range pod.Spec.Containers {
+		path := fmt.Sprintf("/spec/containers/%d/volumeMounts", i)
+		first := len(c.VolumeMounts) == 0
+		for _, m := range mounts {
+			if !added[m.Name] {
+				continue
+			}
+			patch = appendOperation(patch, path, first, m)
+			first = false
+		}
+	}
+	return patch
+}
+
+// appendOperation appends the operation adding the value to the array at path, creating the array if
+// first is true.
+func appendOperation(patch []patchOperation, path string, first bool, value interface{}) []patchOperation {
+	if first {
+		return append(patch, patchOperation{Op: "add", Path: path, Value: []interface{}{value}})
+	}
+	return append(patch, patchOperation{Op: "add", Path: path + "/-", Value: value})
+}
+
+// ServeHTTP serves the AdmissionReviews of the webhook.
+func ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		http.Error(w, "no body found", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "invalid Content-Type, want `application/json`", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	review := admission.AdmissionReview{}
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, fmt.Sprintf("could not decode body: %v", err), http.StatusBadRequest)
+		return
+	}
+	response := admission.AdmissionReview{
+		TypeMeta: review.TypeMeta,
+		Response: Admit(review.Request),
+	}
+	response.Response.UID = review.Request.UID
+
+	resp, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could encode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if _, err := w.Write(resp); err != nil {
+		log.Errorf("failed to write the admission response: %v", err)
+	}
+}
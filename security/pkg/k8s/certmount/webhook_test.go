@@ -0,0 +1,143 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certmount
+
+import (
+	"encoding/json"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	admission "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"istio.io/istio/security/pkg/k8s/tokenreview"
+)
+
+func admit(t *testing.T, pod *corev1.Pod) *corev1.Pod {
+	t.Helper()
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := Admit(&admission.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Namespace: "foo",
+		Operation: admission.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	})
+	if !resp.Allowed {
+		t.Fatalf("pod not allowed: %v", resp.Result)
+	}
+	if resp.Patch == nil {
+		return pod
+	}
+	patch, err := jsonpatch.DecodePatch(resp.Patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched, err := patch.Apply(raw)
+	if err != nil {
+		t.Fatalf("failed to apply patch %s: %v", resp.Patch, err)
+	}
+	out := &corev1.Pod{}
+	if err := json.Unmarshal(patched, out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestAdmit(t *testing.T) {
+	annotations := map[string]string{Annotation: "true"}
+	testCases := map[string]struct {
+		pod         *corev1.Pod
+		wantToken   bool
+		wantVolumes int
+		wantMounts  []int
+	}{
+		"not annotated": {
+			pod: &corev1.Pod{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			},
+			wantMounts: []int{0},
+		},
+		"default service account": {
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: annotations},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "helper"}}},
+			},
+			wantToken:   true,
+			wantVolumes: 2,
+			wantMounts:  []int{2, 2},
+		},
+		"existing volumes": {
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: annotations},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: "bar",
+					Volumes:            []corev1.Volume{{Name: "data"}},
+					Containers: []corev1.Container{{
+						Name:         "app",
+						VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/data"}},
+					}},
+				},
+			},
+			wantToken:   true,
+			wantVolumes: 3,
+			wantMounts:  []int{3},
+		},
+		"volumes already mounted": {
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: annotations},
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{{Name: tokenVolumeName}, {Name: rootCertVolumeName}},
+					Containers: []corev1.Container{{
+						Name:         "app",
+						VolumeMounts: []corev1.VolumeMount{{Name: tokenVolumeName, MountPath: "/custom"}},
+					}},
+				},
+			},
+			wantVolumes: 2,
+			wantMounts:  []int{1},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			pod := admit(t, tc.pod)
+			if len(pod.Spec.Volumes) != tc.wantVolumes {
+				t.Errorf("got %d volumes, want %d: %+v", len(pod.Spec.Volumes), tc.wantVolumes, pod.Spec.Volumes)
+			}
+			for i, c := range pod.Spec.Containers {
+				if len(c.VolumeMounts) != tc.wantMounts[i] {
+					t.Errorf("container %s: got %d mounts, want %d", c.Name, len(c.VolumeMounts), tc.wantMounts[i])
+				}
+			}
+			if !tc.wantToken {
+				return
+			}
+			for _, v := range pod.Spec.Volumes {
+				if v.Name != tokenVolumeName {
+					continue
+				}
+				if v.Projected == nil || len(v.Projected.Sources) != 1 || v.Projected.Sources[0].ServiceAccountToken == nil ||
+					v.Projected.Sources[0].ServiceAccountToken.Audience != tokenreview.DefaultAudience {
+					t.Errorf("unexpected token volume %+v", v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chiron
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/pkg/log"
+)
+
+const (
+	// acmeAccountKeyID is the data key of the ACME account key in the account secret.
+	acmeAccountKeyID = "account-key.pem"
+	// defaultACMETimeout bounds the time to issue a cert, including the DNS-01 validation.
+	defaultACMETimeout = 5 * time.Minute
+)
+
+// DNSProvider publishes the TXT records of ACME DNS-01 challenges.
+type DNSProvider interface {
+	// Present creates the TXT record fqdn with value.
+	Present(fqdn, value string) error
+	// CleanUp removes the TXT record fqdn with value.
+	CleanUp(fqdn, value string) error
+}
+
+// ACMEIssuer issues publicly trusted certs from an ACME CA, e.g. Let's Encrypt, validating the
+// DNS names with DNS-01 challenges.
+type ACMEIssuer struct {
+	client *acme.Client
+	email  string
+	dns    DNSProvider
+
+	// PropagationDelay is the time to wait for the TXT records to be visible to the ACME CA.
+	PropagationDelay time.Duration
+	// Timeout bounds the time to issue a cert.
+	Timeout time.Duration
+
+	registerMutex sync.Mutex
+	registered    bool
+}
+
+// NewACMEIssuer returns an ACMEIssuer for the ACME directory directoryURL, using the account
+// with accountKey and contact email, and publishing the challenges with dns.
+func NewACMEIssuer(directoryURL, email string, accountKey crypto.Signer, dns DNSProvider) *ACMEIssuer {
+	return &ACMEIssuer{
+		client:  &acme.Client{Key: accountKey, DirectoryURL: directoryURL},
+		email:   email,
+		dns:     dns,
+		Timeout: defaultACMETimeout,
+	}
+}
+
+// Issue orders a cert for the comma separated dnsNames, completing the DNS-01 challenges of the
+// names not yet authorized.
+func (i *ACMEIssuer) Issue(dnsNames, secretName, namespace string) (chain, key, caCert []byte, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), i.Timeout)
+	defer cancel()
+	if err := i.register(ctx); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var names []string
+	for _, name := range strings.Split(dnsNames, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil, nil, fmt.Errorf("no DNS name for secret %s/%s", namespace, secretName)
+	}
+	order, err := i.client.AuthorizeOrder(ctx, acme.DomainIDs(names...))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create the ACME order for %v: %v", names, err)
+	}
+	for _, url := range order.AuthzURLs {
+		if err := i.authorize(ctx, url); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if order, err = i.client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, nil, nil, fmt.Errorf("the ACME order for %v failed: %v", names, err)
+	}
+
+	priv, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to generate a key: %v", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: names[0]},
+		DNSNames: names,
+	}, priv)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create a CSR: %v", err)
+	}
+	der, _, err := i.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to finalize the ACME order for %v: %v", names, err)
+	}
+	for _, b := range der {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: b})...)
+	}
+	if len(der) > 1 {
+		// The ACME CA does not return its root, the issuer is the closest CA cert.
+		caCert = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der[len(der)-1]})
+	}
+	key = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+	log.Infof("issued an ACME cert for %v in secret %s/%s", names, namespace, secretName)
+	return chain, key, caCert, nil
+}
+
+// register creates the ACME account, unless it exists.
+func (i *ACMEIssuer) register(ctx context.Context) error {
+	i.registerMutex.Lock()
+	defer i.registerMutex.Unlock()
+	if i.registered {
+		return nil
+	}
+	account := &acme.Account{}
+	if i.email != "" {
+		account.Contact = []string{"mailto:" + i.email}
+	}
+	if _, err := i.client.Register(ctx, account, acme.AcceptTOS); err != nil && err != acme.ErrAccountAlreadyExists {
+		return fmt.Errorf("failed to register the ACME account: %v", err)
+	}
+	i.registered = true
+	return nil
+}
+
+// authorize completes the DNS-01 challenge of the authorization at url, unless it is valid.
+func (i *ACMEIssuer) authorize(ctx context.Context, url string) error {
+	authz, err := i.client.GetAuthorization(ctx, url)
+	if err != nil {
+		return fmt.Errorf("failed to get the ACME authorization %s: %v", url, err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "dns-01" {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("no dns-01 challenge for %s", authz.Identifier.Value)
+	}
+	value, err := i.client.DNS01ChallengeRecord(challenge.Token)
+	if err != nil {
+		return err
+	}
+	// The challenge of a wildcard name is published under the base domain.
+	fqdn := "_acme-challenge." + strings.TrimPrefix(authz.Identifier.Value, "*.") + "."
+	if err := i.dns.Present(fqdn, value); err != nil {
+		return fmt.Errorf("failed to publish the TXT record %s: %v", fqdn, err)
+	}
+	defer func() {
+		if err := i.dns.CleanUp(fqdn, value); err != nil {
+			log.Warnf("failed to remove the TXT record %s: %v", fqdn, err)
+		}
+	}()
+	if i.PropagationDelay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(i.PropagationDelay):
+		}
+	}
+	if _, err := i.client.Accept(ctx, challenge); err != nil {
+		return fmt.Errorf("failed to accept the dns-01 challenge for %s: %v", authz.Identifier.Value, err)
+	}
+	if _, err := i.client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("the dns-01 challenge for %s failed: %v", authz.Identifier.Value, err)
+	}
+	return nil
+}
+
+// LoadOrCreateACMEAccountKey returns the ACME account key stored in the secret secretName in
+// namespace, creating the secret with a new key if it does not exist.
+func LoadOrCreateACMEAccountKey(core corev1.CoreV1Interface, namespace, secretName string) (crypto.Signer, error) {
+	secret, err := core.Secrets(namespace).Get(context.TODO(), secretName, metav1.GetOptions{})
+	if err == nil {
+		key, err := util.ParsePemEncodedKey(secret.Data[acmeAccountKeyID])
+		if err != nil {
+			return nil, fmt.Errorf("invalid ACME account key in secret %s/%s: %v", namespace, secretName, err)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("the ACME account key is not a signing key")
+		}
+		return signer, nil
+	}
+	if !kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get secret %s/%s: %v", namespace, secretName, err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	secret = &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: namespace},
+		Data: map[string][]byte{
+			acmeAccountKeyID: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}),
+		},
+	}
+	if _, err := core.Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+		if kerrors.IsAlreadyExists(err) {
+			// Another replica created the key first.
+			return LoadOrCreateACMEAccountKey(core, namespace, secretName)
+		}
+		return nil, fmt.Errorf("failed to create secret %s/%s: %v", namespace, secretName, err)
+	}
+	log.Infof("created the ACME account key in secret %s/%s", namespace, secretName)
+	return key, nil
+}
@@ -0,0 +1,303 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chiron
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/crypto/acme"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// fakeACME is a minimal RFC 8555 ACME server with a single order, validating dns-01 challenges
+// against the records of a fakeDNS.
+type fakeACME struct {
+	t      *testing.T
+	url    string
+	dns    *fakeDNS
+	signer util.KeyCertBundle
+
+	mutex      sync.Mutex
+	account    *acme.Client
+	identifier string
+	authzValid bool
+	finalized  bool
+	certPEM    []byte
+}
+
+func (f *fakeACME) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", time.Now().UnixNano()))
+	if r.URL.Path == "/directory" {
+		writeACME(w, http.StatusOK, map[string]interface{}{
+			"newNonce":   f.url + "/new-nonce",
+			"newAccount": f.url + "/new-account",
+			"newOrder":   f.url + "/new-order",
+		})
+		return
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(jws.Payload)
+
+	order := func() map[string]interface{} {
+		status := "pending"
+		if f.authzValid {
+			status = "ready"
+		}
+		o := map[string]interface{}{
+			"status":         status,
+			"identifiers":    []interface{}{map[string]string{"type": "dns", "value": f.identifier}},
+			"authorizations": []string{f.url + "/authz/1"},
+			"finalize":       f.url + "/finalize/1",
+		}
+		if f.finalized {
+			o["status"] = "valid"
+			o["certificate"] = f.url + "/cert/1"
+		}
+		return o
+	}
+	authz := func() map[string]interface{} {
+		status := "pending"
+		if f.authzValid {
+			status = "valid"
+		}
+		return map[string]interface{}{
+			"status":     status,
+			"identifier": map[string]string{"type": "dns", "value": f.identifier},
+			"challenges": []interface{}{
+				map[string]string{"type": "http-01", "url": f.url + "/chal/2", "token": "http-token", "status": "pending"},
+				map[string]string{"type": "dns-01", "url": f.url + "/chal/1", "token": "dns-token", "status": status},
+			},
+		}
+	}
+
+	switch r.URL.Path {
+	case "/new-account":
+		w.Header().Set("Location", f.url+"/account/1")
+		writeACME(w, http.StatusCreated, map[string]string{"status": "valid"})
+	case "/new-order":
+		var req struct {
+			Identifiers []struct{ Value string } `json:"identifiers"`
+		}
+		_ = json.Unmarshal(payload, &req)
+		f.identifier = req.Identifiers[0].Value
+		w.Header().Set("Location", f.url+"/order/1")
+		writeACME(w, http.StatusCreated, order())
+	case "/order/1":
+		w.Header().Set("Location", f.url+"/order/1")
+		writeACME(w, http.StatusOK, order())
+	case "/authz/1":
+		writeACME(w, http.StatusOK, authz())
+	case "/chal/1":
+		expected, _ := f.account.DNS01ChallengeRecord("dns-token")
+		if f.dns.records["_acme-challenge."+f.identifier+"."] != expected {
+			f.t.Errorf("the TXT record is not published: %v", f.dns.records)
+		} else {
+			f.authzValid = true
+		}
+		writeACME(w, http.StatusOK, map[string]string{"type": "dns-01", "url": f.url + "/chal/1", "status": "valid"})
+	case "/finalize/1":
+		var req struct {
+			CSR string `json:"csr"`
+		}
+		_ = json.Unmarshal(payload, &req)
+		der, _ := base64.RawURLEncoding.DecodeString(req.CSR)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		signingCert, signingKey, _, _ := f.signer.GetAll()
+		cert, err := util.GenCertFromCSR(csr, signingCert, csr.PublicKey, *signingKey, csr.DNSNames, time.Hour, false)
+		if err != nil {
+			f.t.Fatalf("failed to sign: %v", err)
+		}
+		f.certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+		f.finalized = true
+		w.Header().Set("Location", f.url+"/order/1")
+		writeACME(w, http.StatusOK, order())
+	case "/cert/1":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		_, _ = w.Write(append(append([]byte{}, f.certPEM...), f.signer.GetRootCertPem()...))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func writeACME(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// fakeDNS records the published TXT records.
+type fakeDNS struct {
+	records map[string]string
+	removed []string
+}
+
+func (d *fakeDNS) Present(fqdn, value string) error {
+	d.records[fqdn] = value
+	return nil
+}
+
+func (d *fakeDNS) CleanUp(fqdn, value string) error {
+	delete(d.records, fqdn)
+	d.removed = append(d.removed, fqdn)
+	return nil
+}
+
+func TestACMEIssuer(t *testing.T) {
+	rootPEM, rootKey, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          "acme",
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the ACME CA: %v", err)
+	}
+	signer, err := util.NewVerifiedKeyCertBundleFromPem(rootPEM, rootKey, nil, rootPEM)
+	if err != nil {
+		t.Fatalf("failed to create the ACME CA bundle: %v", err)
+	}
+
+	client := fake.NewSimpleClientset()
+	accountKey, err := LoadOrCreateACMEAccountKey(client.CoreV1(), "istio-system", "istio-acme-account")
+	if err != nil {
+		t.Fatalf("failed to create the account key: %v", err)
+	}
+	dnsProvider := &fakeDNS{records: map[string]string{}}
+	server := &fakeACME{t: t, dns: dnsProvider, signer: signer, account: &acme.Client{Key: accountKey}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	server.url = ts.URL
+
+	issuer := NewACMEIssuer(ts.URL+"/directory", "admin@example.com", accountKey, dnsProvider)
+	chain, key, caCert, err := issuer.Issue("gateway.example.com", "gateway-cert", "istio-system")
+	if err != nil {
+		t.Fatalf("failed to issue the cert: %v", err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(chain)
+	if err != nil {
+		t.Fatalf("invalid cert chain: %v", err)
+	}
+	if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "gateway.example.com" {
+		t.Errorf("unexpected DNS names %v", cert.DNSNames)
+	}
+	if _, err := util.ParsePemEncodedKey(key); err != nil {
+		t.Errorf("invalid key: %v", err)
+	}
+	if string(caCert) != string(rootPEM) {
+		t.Errorf("got CA cert %q, want %q", caCert, rootPEM)
+	}
+	if len(dnsProvider.records) != 0 || len(dnsProvider.removed) != 1 {
+		t.Errorf("expected the TXT record to be removed, got %v", dnsProvider.records)
+	}
+
+	// The account key is reused.
+	reloaded, err := LoadOrCreateACMEAccountKey(client.CoreV1(), "istio-system", "istio-acme-account")
+	if err != nil {
+		t.Fatalf("failed to load the account key: %v", err)
+	}
+	if !reflect.DeepEqual(reloaded.Public(), accountKey.Public()) {
+		t.Error("expected the stored account key to be loaded")
+	}
+}
+
+func TestRFC2136Provider(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	secret := base64.StdEncoding.EncodeToString([]byte("secret"))
+	var mutex sync.Mutex
+	var updates []string
+	server := &dns.Server{
+		PacketConn: conn,
+		TsigSecret: map[string]string{"acme.": secret},
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			if r.IsTsig() == nil || w.TsigStatus() != nil {
+				m.Rcode = dns.RcodeRefused
+			} else {
+				mutex.Lock()
+				for _, rr := range r.Ns {
+					updates = append(updates, strings.Join(strings.Fields(rr.String()), " "))
+				}
+				mutex.Unlock()
+				m.SetTsig("acme.", dns.HmacSHA256, 300, time.Now().Unix())
+			}
+			_ = w.WriteMsg(m)
+		}),
+	}
+	go func() { _ = server.ActivateAndServe() }()
+	defer func() { _ = server.Shutdown() }()
+
+	p := &RFC2136Provider{
+		Nameserver: conn.LocalAddr().String(),
+		Zone:       "example.com",
+		TSIGKey:    "acme",
+		TSIGSecret: secret,
+		Timeout:    5 * time.Second,
+	}
+	if err := p.Present("_acme-challenge.gateway.example.com.", "value"); err != nil {
+		t.Fatalf("failed to present the record: %v", err)
+	}
+	if err := p.CleanUp("_acme-challenge.gateway.example.com.", "value"); err != nil {
+		t.Fatalf("failed to clean up the record: %v", err)
+	}
+	expected := []string{
+		`_acme-challenge.gateway.example.com. 60 IN TXT "value"`,
+		`_acme-challenge.gateway.example.com. 0 NONE TXT "value"`,
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !reflect.DeepEqual(updates, expected) {
+		t.Errorf("got updates %v, want %v", updates, expected)
+	}
+
+	p.TSIGSecret = base64.StdEncoding.EncodeToString([]byte("wrong"))
+	if err := p.Present("_acme-challenge.gateway.example.com.", "value"); err == nil {
+		t.Error("expected an error for an invalid TSIG secret")
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chiron
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// KeyCertGenerator generates keys and certs for hostnames with a CA running in the same process,
+// such as the Istio CA.
+type KeyCertGenerator interface {
+	GenKeyCert(hostnames []string, certTTL time.Duration) ([]byte, []byte, error)
+	GetCAKeyCertBundle() util.KeyCertBundle
+}
+
+// CertControllerRequester is the requester of the certs issued by a CAIssuer in the SAN policy of
+// the CA, e.g. to allow the certificate controller to request protected DNS names.
+const CertControllerRequester = "istio.io/cert-controller"
+
+// sanAuthorizer is implemented by CAs enforcing a SAN policy, such as the Istio CA.
+type sanAuthorizer interface {
+	AuthorizeSANs(requester string, sans []string) error
+}
+
+// CAIssuer issues the certs of the secrets with a KeyCertGenerator. If the CA enforces a SAN policy,
+// the DNS names are requested as CertControllerRequester.
+type CAIssuer struct {
+	CA  KeyCertGenerator
+	TTL time.Duration
+}
+
+// Issue implements CertIssuer.
+func (i *CAIssuer) Issue(dnsNames, secretName, namespace string) (chain, key, caCert []byte, err error) {
+	hostnames := strings.Split(dnsNames, ",")
+	if authorizer, ok := i.CA.(sanAuthorizer); ok {
+		if err = authorizer.AuthorizeSANs(CertControllerRequester, hostnames); err != nil {
+			return nil, nil, nil, fmt.Errorf("the cert of secret %s in namespace %s is not authorized: %v",
+				secretName, namespace, err)
+		}
+	}
+	chain, key, err = i.CA.GenKeyCert(hostnames, i.TTL)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to issue a cert for secret %s in namespace %s: %v",
+			secretName, namespace, err)
+	}
+	return chain, key, i.CACert(), nil
+}
+
+// CACert returns the root cert of the CA, so that secrets are refreshed when it is rotated.
+func (i *CAIssuer) CACert() []byte {
+	return i.CA.GetCAKeyCertBundle().GetRootCertPem()
+}
+
+// WebhookService is a webhook or aggregated API service whose serving cert is managed.
+type WebhookService struct {
+	Namespace  string
+	Name       string
+	SecretName string
+}
+
+// DNSNames returns the comma separated DNS names the Kubernetes API server may use to reach the service.
+func (s WebhookService) DNSNames() string {
+	return strings.Join([]string{
+		s.Name,
+		s.Name + "." + s.Namespace,
+		s.Name + "." + s.Namespace + ".svc",
+	}, ",")
+}
+
+// ParseWebhookServices parses a comma separated list of namespace/service:secret entries. If the
+// secret is omitted, it is named <service>-dns-cert.
+func ParseWebhookServices(value string) ([]WebhookService, error) {
+	var services []WebhookService
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		svc := WebhookService{}
+		nsName := entry
+		if i := strings.Index(entry, ":"); i >= 0 {
+			nsName, svc.SecretName = entry[:i], entry[i+1:]
+		}
+		parts := strings.Split(nsName, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid webhook service %q, expected namespace/service[:secret]", entry)
+		}
+		svc.Namespace, svc.Name = parts[0], parts[1]
+		if svc.SecretName == "" {
+			svc.SecretName = svc.Name + "-dns-cert"
+		}
+		services = append(services, svc)
+	}
+	return services, nil
+}
@@ -0,0 +1,179 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chiron
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/util"
+	certutil "istio.io/istio/security/pkg/util"
+)
+
+// fakeCA generates self-signed certs and reports a replaceable root cert.
+type fakeCA struct {
+	t      *testing.T
+	bundle util.KeyCertBundle
+	hosts  [][]string
+}
+
+func newFakeCA(t *testing.T) *fakeCA {
+	c := &fakeCA{t: t}
+	c.rotate()
+	return c
+}
+
+func (c *fakeCA) rotate() {
+	certPEM, keyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL: time.Hour, Org: "fake", IsCA: true, IsSelfSigned: true, RSAKeySize: 2048,
+	})
+	if err != nil {
+		c.t.Fatalf("failed to create the root cert: %v", err)
+	}
+	if c.bundle, err = util.NewVerifiedKeyCertBundleFromPem(certPEM, keyPEM, nil, certPEM); err != nil {
+		c.t.Fatalf("failed to create the root bundle: %v", err)
+	}
+}
+
+func (c *fakeCA) GenKeyCert(hostnames []string, certTTL time.Duration) ([]byte, []byte, error) {
+	c.hosts = append(c.hosts, hostnames)
+	return util.GenCertKeyFromOptions(util.CertOptions{
+		Host: hostnames[0], TTL: certTTL, Org: "fake", IsSelfSigned: true, RSAKeySize: 2048,
+	})
+}
+
+func (c *fakeCA) GetCAKeyCertBundle() util.KeyCertBundle {
+	return c.bundle
+}
+
+func TestCAIssuerRefreshesOnRootRotation(t *testing.T) {
+	fca := newFakeCA(t)
+	client := fake.NewSimpleClientset()
+	wc := &WebhookController{
+		core:              client.CoreV1(),
+		secretNames:       []string{"webhook-certs"},
+		dnsNames:          []string{"webhook,webhook.ns,webhook.ns.svc"},
+		serviceNamespaces: []string{"ns"},
+		certUtil:          certutil.NewCertUtil(50),
+		Issuer:            &CAIssuer{CA: fca, TTL: time.Hour},
+	}
+	if err := wc.upsertSecret("webhook-certs", "webhook,webhook.ns,webhook.ns.svc", "ns"); err != nil {
+		t.Fatalf("failed to upsert the secret: %v", err)
+	}
+	if !reflect.DeepEqual(fca.hosts, [][]string{{"webhook", "webhook.ns", "webhook.ns.svc"}}) {
+		t.Errorf("unexpected hosts %v", fca.hosts)
+	}
+	scrt, err := client.CoreV1().Secrets("ns").Get(context.TODO(), "webhook-certs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the secret: %v", err)
+	}
+	if string(scrt.Data[ca.RootCertID]) != string(fca.bundle.GetRootCertPem()) {
+		t.Errorf("the secret does not hold the root cert of the CA")
+	}
+
+	wc.scrtUpdated(nil, scrt)
+	if len(fca.hosts) != 1 {
+		t.Errorf("expected an up-to-date secret not to be refreshed")
+	}
+
+	fca.rotate()
+	wc.scrtUpdated(nil, scrt)
+	if len(fca.hosts) != 2 {
+		t.Fatalf("expected the secret to be refreshed after the root rotation")
+	}
+	scrt, err = client.CoreV1().Secrets("ns").Get(context.TODO(), "webhook-certs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the secret: %v", err)
+	}
+	if string(scrt.Data[ca.RootCertID]) != string(fca.bundle.GetRootCertPem()) {
+		t.Errorf("the secret does not hold the new root cert")
+	}
+}
+
+// authorizingCA is a fakeCA enforcing a SAN policy.
+type authorizingCA struct {
+	*fakeCA
+	requester string
+	sans      []string
+	err       error
+}
+
+func (c *authorizingCA) AuthorizeSANs(requester string, sans []string) error {
+	c.requester, c.sans = requester, sans
+	return c.err
+}
+
+func TestCAIssuerAuthorizesSANs(t *testing.T) {
+	fca := &authorizingCA{fakeCA: newFakeCA(t), err: fmt.Errorf("not allowed")}
+	issuer := &CAIssuer{CA: fca, TTL: time.Hour}
+	if _, _, _, err := issuer.Issue("webhook,webhook.ns.svc", "webhook-certs", "ns"); err == nil {
+		t.Errorf("expected the cert to be denied by the SAN policy")
+	}
+	if len(fca.hosts) != 0 {
+		t.Errorf("expected no cert to be issued")
+	}
+	if fca.requester != CertControllerRequester || !reflect.DeepEqual(fca.sans, []string{"webhook", "webhook.ns.svc"}) {
+		t.Errorf("unexpected authorization of %v for %q", fca.sans, fca.requester)
+	}
+
+	fca.err = nil
+	if _, _, _, err := issuer.Issue("webhook,webhook.ns.svc", "webhook-certs", "ns"); err != nil {
+		t.Errorf("failed to issue the cert: %v", err)
+	}
+}
+
+func TestParseWebhookServices(t *testing.T) {
+	testCases := map[string]struct {
+		value    string
+		expected []WebhookService
+		err      bool
+	}{
+		"empty": {value: ""},
+		"default secret name": {
+			value:    "istio-system/istio-galley",
+			expected: []WebhookService{{Namespace: "istio-system", Name: "istio-galley", SecretName: "istio-galley-dns-cert"}},
+		},
+		"multiple services": {
+			value: "istio-system/istio-sidecar-injector:injector-certs, foo/webhook",
+			expected: []WebhookService{
+				{Namespace: "istio-system", Name: "istio-sidecar-injector", SecretName: "injector-certs"},
+				{Namespace: "foo", Name: "webhook", SecretName: "webhook-dns-cert"},
+			},
+		},
+		"no namespace":  {value: "webhook", err: true},
+		"empty service": {value: "foo/:certs", err: true},
+	}
+	for name, tc := range testCases {
+		services, err := ParseWebhookServices(tc.value)
+		if tc.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(services, tc.expected) {
+			t.Errorf("%s: got %v, want %v", name, services, tc.expected)
+		}
+	}
+	svc := WebhookService{Namespace: "foo", Name: "webhook"}
+	if dnsNames := svc.DNSNames(); dnsNames != "webhook,webhook.foo,webhook.foo.svc" {
+		t.Errorf("unexpected DNS names %q", dnsNames)
+	}
+}
@@ -18,8 +18,10 @@ import (
 	"bytes"
 	"context"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
@@ -28,6 +30,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 
 	admissionv1 "k8s.io/client-go/kubernetes/typed/admissionregistration/v1beta1"
@@ -36,6 +39,8 @@ import (
 	"k8s.io/client-go/tools/cache"
 
 	"istio.io/istio/pkg/listwatch"
+	"istio.io/istio/security/pkg/audit"
+	"istio.io/istio/security/pkg/notify"
 	"istio.io/istio/security/pkg/pki/ca"
 	"istio.io/istio/security/pkg/pki/util"
 	certutil "istio.io/istio/security/pkg/util"
@@ -49,6 +54,22 @@ const (
 	// The Istio DNS secret annotation type
 	IstioDNSSecretType = "istio.io/dns-key-and-cert"
 
+	// ForceRotationAnnotation requests the immediate rotation of the cert in a secret. It is
+	// removed once the secret is refreshed.
+	ForceRotationAnnotation = "istio.io/force-rotation"
+
+	// GracePeriodRatioAnnotation overrides the grace period ratio of the controller for the cert in
+	// a secret, e.g. to rotate the certs of servers with long-lived connections earlier.
+	GracePeriodRatioAnnotation = "istio.io/grace-period-ratio"
+
+	// KeepDeletedAnnotation requests the controller not to re-create the secret when it is deleted,
+	// e.g. to decommission a service. The secret is created again when the controller reconciles all
+	// its secrets, at the next start.
+	KeepDeletedAnnotation = "istio.io/keep-deleted"
+
+	// The audit event of the issuance of the cert of a secret.
+	webhookCertIssuanceEvent = "webhook_cert_issuance"
+
 	// For debugging, set the resync period to be a shorter period.
 	secretResyncPeriod = 10 * time.Second
 	// secretResyncPeriod = time.Minute
@@ -87,6 +108,10 @@ type WebhookController struct {
 	// Controller and store for secret objects.
 	scrtController cache.Controller
 	scrtStore      cache.Store
+	// Controller and store for namespace objects, so that the phase of the namespace of a deleted
+	// secret is known without a request to the API server.
+	namespaceController cache.Controller
+	namespaceStore      cache.Store
 	// The file path to the k8s CA certificate
 	k8sCaCertFile  string
 	minGracePeriod time.Duration
@@ -94,6 +119,44 @@ type WebhookController struct {
 	// Length of the grace period for the certificate rotation.
 	gracePeriodRatio float32
 	certUtil         certutil.CertUtil
+	// certUtilMutex guards certUtil, which can be changed while the controller runs.
+	certUtilMutex sync.RWMutex
+
+	// IncludePKCS7 adds the cert chain and CA cert as a PKCS#7 bundle to the secrets, for TLS
+	// stacks that only import P7B.
+	IncludePKCS7 bool
+
+	// Issuer issues the certs of the secrets instead of the Kubernetes CA, if set.
+	Issuer CertIssuer
+
+	// The state of the managed secrets, for debugging.
+	status controllerStatus
+
+	// FailureAlertThreshold is the number of consecutive failures to create or refresh a secret
+	// after which an alert is sent. No alert is sent if it is not positive.
+	FailureAlertThreshold int
+
+	// RespectDeletions keeps the deleted secrets deleted until the controller reconciles all its
+	// secrets again, at the next start, as if they had KeepDeletedAnnotation.
+	RespectDeletions bool
+
+	// inflight tracks the creations and refreshes of secrets in progress, waited for by Drain.
+	inflight sync.WaitGroup
+	// drainMutex guards draining, so that no work is added to inflight once the controller drains.
+	drainMutex sync.Mutex
+	draining   bool
+}
+
+// CertIssuer issues the DNS certs of the secrets managed by a WebhookController.
+type CertIssuer interface {
+	// Issue returns the PEM-encoded cert chain, private key and CA cert of a new cert for the
+	// comma separated dnsNames, stored in the secret secretName in namespace.
+	Issue(dnsNames, secretName, namespace string) (chain, key, caCert []byte, err error)
+}
+
+// caCertProvider is implemented by issuers whose CA cert is known, such as CAIssuer.
+type caCertProvider interface {
+	CACert() []byte
 }
 
 // NewWebhookController returns a pointer to a newly constructed WebhookController instance.
@@ -115,10 +178,10 @@ func NewWebhookController(gracePeriodRatio float32, minGracePeriod time.Duration
 	if len(dnsNames) != len(serviceNamespaces) {
 		return nil, fmt.Errorf("the size of service names must be the same as the size of service namespaces")
 	}
-	// Check secret names are unique
+	// Check secret names are unique within each namespace
 	set := make(map[string]bool) // New empty set
-	for _, n := range secretNames {
-		set[n] = true // Add
+	for i, n := range secretNames {
+		set[serviceNamespaces[i]+"/"+n] = true // Add
 	}
 	if len(set) != len(secretNames) {
 		return nil, fmt.Errorf("the secret names must be unique")
@@ -136,6 +199,7 @@ func NewWebhookController(gracePeriodRatio float32, minGracePeriod time.Duration
 		serviceNamespaces: serviceNamespaces,
 		certUtil:          certutil.NewCertUtil(int(gracePeriodRatio * 100)),
 	}
+	c.status.track(secretNames, dnsNames, serviceNamespaces)
 
 	// read CA cert at the beginning of launching the controller.
 	_, err := reloadCACert(c)
@@ -149,31 +213,52 @@ func NewWebhookController(gracePeriodRatio float32, minGracePeriod time.Duration
 		scrtLW := listwatch.MultiNamespaceListerWatcher(serviceNamespaces, func(namespace string) cache.ListerWatcher {
 			return &cache.ListWatch{
 				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-					options.FieldSelector = istioSecretSelector
 					return core.Secrets(namespace).List(context.TODO(), options)
 				},
 				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-					options.FieldSelector = istioSecretSelector
 					return core.Secrets(namespace).Watch(context.TODO(), options)
 				},
 			}
-		})
+		}, listwatch.FieldSelector(istioSecretSelector))
 		// The certificate rotation is handled by scrtUpdated().
 		c.scrtStore, c.scrtController =
 			cache.NewInformer(scrtLW, &v1.Secret{}, secretResyncPeriod, cache.ResourceEventHandlerFuncs{
 				DeleteFunc: c.scrtDeleted,
 				UpdateFunc: c.scrtUpdated,
 			})
+		nsLW := &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return core.Namespaces().List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return core.Namespaces().Watch(context.TODO(), options)
+			},
+		}
+		c.namespaceStore, c.namespaceController =
+			cache.NewInformer(nsLW, &v1.Namespace{}, 0, cache.ResourceEventHandlerFuncs{})
 	}
 
 	return c, nil
 }
 
+// SetRenewalStrategy renews the certs of the secrets as decided by strategy, instead of when
+// gracePeriodRatio of their lifetime remains.
+func (wc *WebhookController) SetRenewalStrategy(strategy certutil.RenewalStrategy) {
+	wc.certUtilMutex.Lock()
+	defer wc.certUtilMutex.Unlock()
+	wc.certUtil = certutil.NewCertUtilWithStrategy(strategy)
+}
+
 // Run starts the WebhookController until stopCh is notified.
 func (wc *WebhookController) Run(stopCh <-chan struct{}) {
+	registerInventory(&wc.status, stopCh)
 	// Create secrets containing certificates
 	for i, secretName := range wc.secretNames {
+		if !wc.startWork() {
+			return
+		}
 		err := wc.upsertSecret(secretName, wc.dnsNames[i], wc.serviceNamespaces[i])
+		wc.inflight.Done()
 		if err != nil {
 			log.Errorf("error when upserting secret (%v) in ns (%v): %v", secretName, wc.serviceNamespaces[i], err)
 		}
@@ -181,15 +266,58 @@ func (wc *WebhookController) Run(stopCh <-chan struct{}) {
 
 	if len(wc.secretNames) > 0 {
 		// Manage the secrets
+		go wc.namespaceController.Run(stopCh)
 		go wc.scrtController.Run(stopCh)
 		// upsertSecret to update and insert secret
 		// it throws error if the secret cache is not synchronized, but the secret exists in the system.
 		// Hence waiting for the cache is synced.
-		cache.WaitForCacheSync(stopCh, wc.scrtController.HasSynced)
+		cache.WaitForCacheSync(stopCh, wc.namespaceController.HasSynced, wc.scrtController.HasSynced)
+	}
+}
+
+// Drain stops the controller from creating or refreshing secrets for new events, and waits up to
+// timeout for the ones in progress to complete, so that the process does not exit in the middle of
+// an update. It returns false if some are still in progress after timeout.
+func (wc *WebhookController) Drain(timeout time.Duration) bool {
+	wc.drainMutex.Lock()
+	wc.draining = true
+	wc.drainMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		wc.inflight.Wait()
+		close(done)
+	}()
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-done:
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+// startWork adds a creation or refresh of a secret to inflight, and returns true, unless the
+// controller drains. The caller must call inflight.Done() once the work completes.
+func (wc *WebhookController) startWork() bool {
+	wc.drainMutex.Lock()
+	defer wc.drainMutex.Unlock()
+	if wc.draining {
+		return false
 	}
+	wc.inflight.Add(1)
+	return true
 }
 
-func (wc *WebhookController) upsertSecret(secretName, dnsName, secretNamespace string) error {
+func (wc *WebhookController) upsertSecret(secretName, dnsName, secretNamespace string) (err error) {
+	ctx, span := startSpan(context.Background(), "chiron.upsertSecret", secretNamespace, secretName)
+	var chain []byte
+	defer func() {
+		endSpan(span, err)
+		wc.recordUpdate(secretNamespace, secretName, chain, err)
+	}()
+
 	secret := &v1.Secret{
 		Data: map[string][]byte{},
 		ObjectMeta: metav1.ObjectMeta{
@@ -200,16 +328,18 @@ func (wc *WebhookController) upsertSecret(secretName, dnsName, secretNamespace s
 		Type: IstioDNSSecretType,
 	}
 
-	existingSecret, err := wc.core.Secrets(secretNamespace).Get(context.TODO(), secretName, metav1.GetOptions{})
-	if err == nil && existingSecret != nil {
+	existingSecret, getErr := wc.core.Secrets(secretNamespace).Get(ctx, secretName, metav1.GetOptions{})
+	if getErr == nil && existingSecret != nil {
 		log.Debugf("upsertSecret(): the secret (%v) in namespace (%v) exists, return",
 			secretName, secretNamespace)
+		wc.status.observe(secretNamespace, secretName, existingSecret.Data[ca.CertChainID])
+		reportInventory()
 		// Do nothing for existing secrets. Rotating expiring certs are handled by the `scrtUpdated` method.
 		return nil
 	}
 
 	// Now we know the secret does not exist yet. So we create a new one.
-	chain, key, caCert, err := GenKeyCertK8sCA(wc.certClient.CertificateSigningRequests(), dnsName, secretName, secretNamespace, wc.k8sCaCertFile)
+	chain, key, caCert, err := wc.genKeyCert(ctx, dnsName, secretName, secretNamespace)
 	if err != nil {
 		log.Errorf("failed to generate key and certificate for secret %v in namespace %v (error %v)",
 			secretName, secretNamespace, err)
@@ -220,10 +350,15 @@ func (wc *WebhookController) upsertSecret(secretName, dnsName, secretNamespace s
 		ca.PrivateKeyID: key,
 		ca.RootCertID:   caCert,
 	}
+	if err = wc.addPKCS7(secret, chain, caCert); err != nil {
+		return err
+	}
 
 	// We retry several times when create secret to mitigate transient network failures.
 	for i := 0; i < secretCreationRetry; i++ {
-		_, err = wc.core.Secrets(secretNamespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+		_, createSpan := startSpan(ctx, "kube.CreateSecret", secretNamespace, secretName)
+		_, err = wc.core.Secrets(secretNamespace).Create(ctx, secret, metav1.CreateOptions{})
+		endSpan(createSpan, err)
 		if err == nil || errors.IsAlreadyExists(err) {
 			if errors.IsAlreadyExists(err) {
 				log.Infof("Istio secret \"%s\" in namespace \"%s\" already exists", secretName, secretNamespace)
@@ -242,6 +377,7 @@ func (wc *WebhookController) upsertSecret(secretName, dnsName, secretNamespace s
 	}
 
 	log.Infof("Istio secret \"%s\" in namespace \"%s\" has been created", secretName, secretNamespace)
+	auditIssuance(secretNamespace, secretName, chain)
 	return nil
 }
 
@@ -255,8 +391,23 @@ func (wc *WebhookController) scrtDeleted(obj interface{}) {
 
 	scrtName := scrt.Name
 	if wc.isWebhookSecret(scrtName, scrt.GetNamespace()) {
+		if !wc.startWork() {
+			log.Infof("not re-creating deleted Istio secret %s in namespace %s, the controller is stopping",
+				scrtName, scrt.GetNamespace())
+			return
+		}
+		defer wc.inflight.Done()
+		if wc.namespaceTerminating(scrt.GetNamespace()) {
+			log.Infof("not re-creating deleted Istio secret %s, namespace %s is terminating", scrtName, scrt.GetNamespace())
+			return
+		}
+		if _, ok := scrt.Annotations[KeepDeletedAnnotation]; ok || wc.RespectDeletions {
+			log.Infof("not re-creating deleted Istio secret %s in namespace %s until the controller restarts",
+				scrtName, scrt.GetNamespace())
+			return
+		}
 		log.Infof("re-create deleted Istio secret %s in namespace %s", scrtName, scrt.GetNamespace())
-		dnsName, found := wc.getDNSName(scrtName)
+		dnsName, found := wc.getDNSName(scrtName, scrt.GetNamespace())
 		if !found {
 			log.Errorf("failed to find the DNS name of the secret: %v", scrtName)
 			return
@@ -269,6 +420,21 @@ func (wc *WebhookController) scrtDeleted(obj interface{}) {
 	}
 }
 
+// namespaceTerminating returns true if namespace is terminating or deleted, as cached by the namespace
+// informer, in which case the secrets in it are deleted along with it and must not be re-created.
+func (wc *WebhookController) namespaceTerminating(namespace string) bool {
+	obj, exists, err := wc.namespaceStore.GetByKey(namespace)
+	if err != nil {
+		log.Debugf("failed to get namespace %s: %v", namespace, err)
+		return false
+	}
+	if !exists {
+		return true
+	}
+	ns, ok := obj.(*v1.Namespace)
+	return ok && ns.Status.Phase == v1.NamespaceTerminating
+}
+
 // scrtUpdated() is the callback function for update event. It handles
 // the certificate rotations.
 func (wc *WebhookController) scrtUpdated(oldObj, newObj interface{}) {
@@ -284,12 +450,29 @@ func (wc *WebhookController) scrtUpdated(oldObj, newObj interface{}) {
 	if !wc.isWebhookSecret(name, namespace) {
 		return
 	}
+	if !wc.startWork() {
+		log.Debugf("ignoring the update of secret %s/%s, the controller is stopping", namespace, name)
+		return
+	}
+	defer wc.inflight.Done()
+
+	if _, ok := scrt.Annotations[ForceRotationAnnotation]; ok {
+		log.Infof("refreshing secret %s/%s, the rotation is requested", namespace, name)
+		secretRefreshCounts.With(reasonTag.Value(refreshForced)).Increment()
+		if err := wc.refreshSecret(scrt); err != nil {
+			log.Errorf("failed to update secret %s/%s (error: %s)", namespace, name, err)
+		}
+		return
+	}
 
 	certBytes := scrt.Data[ca.CertChainID]
+	wc.status.observe(namespace, name, certBytes)
+	reportInventory()
 	_, err := util.ParsePemEncodedCertificate(certBytes)
 	if err != nil {
 		log.Warnf("failed to parse certificates in secret %s/%s (error: %v), refreshing the secret.",
 			namespace, name, err)
+		secretRefreshCounts.With(reasonTag.Value(refreshInvalidCert)).Increment()
 		if err = wc.refreshSecret(scrt); err != nil {
 			log.Errora(err)
 		}
@@ -297,7 +480,7 @@ func (wc *WebhookController) scrtUpdated(oldObj, newObj interface{}) {
 		return
 	}
 
-	_, waitErr := wc.certUtil.GetWaitTime(certBytes, time.Now(), wc.minGracePeriod)
+	_, waitErr := wc.certUtilFor(scrt).GetWaitTime(certBytes, time.Now(), wc.minGracePeriod)
 
 	// Refresh the secret if 1) the certificate contained in the secret is about
 	// to expire, or 2) the root certificate in the secret is different than the
@@ -305,14 +488,32 @@ func (wc *WebhookController) scrtUpdated(oldObj, newObj interface{}) {
 	// a new self-signed CA cert is generated).
 	// The secret will be periodically inspected, so an update to the CA certificate
 	// will eventually lead to the update of workload certificates.
+	// The CA cert of an external issuer is not known in advance, so only the expiry is checked,
+	// unless the issuer exposes its CA cert.
+	if wc.Issuer != nil {
+		outdated := false
+		if p, ok := wc.Issuer.(caCertProvider); ok {
+			outdated = !util.SameCertificates(p.CACert(), scrt.Data[ca.RootCertID])
+		}
+		if waitErr != nil || outdated {
+			log.Infof("refreshing secret %s/%s, either the leaf certificate is about to expire "+
+				"or the root certificate is outdated", namespace, name)
+			recordRefreshReason(waitErr != nil)
+			if err = wc.refreshSecret(scrt); err != nil {
+				log.Errorf("failed to update secret %s/%s (error: %s)", namespace, name, err)
+			}
+		}
+		return
+	}
 	caCert, err := wc.getCACert()
 	if err != nil {
 		log.Errorf("failed to get CA certificate: %v", err)
 		return
 	}
-	if waitErr != nil || !bytes.Equal(caCert, scrt.Data[ca.RootCertID]) {
+	if waitErr != nil || !util.SameCertificates(caCert, scrt.Data[ca.RootCertID]) {
 		log.Infof("refreshing secret %s/%s, either the leaf certificate is about to expire "+
 			"or the root certificate is outdated", namespace, name)
+		recordRefreshReason(waitErr != nil)
 
 		if err = wc.refreshSecret(scrt); err != nil {
 			log.Errorf("failed to update secret %s/%s (error: %s)", namespace, name, err)
@@ -320,29 +521,163 @@ func (wc *WebhookController) scrtUpdated(oldObj, newObj interface{}) {
 	}
 }
 
+// certUtilFor returns the CertUtil for the cert in scrt, which honors GracePeriodRatioAnnotation.
+func (wc *WebhookController) certUtilFor(scrt *v1.Secret) certutil.CertUtil {
+	wc.certUtilMutex.RLock()
+	defaultCertUtil := wc.certUtil
+	wc.certUtilMutex.RUnlock()
+	value, ok := scrt.Annotations[GracePeriodRatioAnnotation]
+	if !ok {
+		return defaultCertUtil
+	}
+	ratio, err := strconv.ParseFloat(value, 32)
+	if err != nil || ratio < 0 || ratio > 1 {
+		log.Warnf("ignoring invalid %s annotation %q of secret %s/%s, the ratio must be between 0 and 1",
+			GracePeriodRatioAnnotation, value, scrt.Namespace, scrt.Name)
+		return defaultCertUtil
+	}
+	return certutil.NewCertUtil(int(ratio * 100))
+}
+
+// recordRefreshReason records a refresh due to the expiry of the cert, or otherwise an outdated root cert.
+func recordRefreshReason(expiring bool) {
+	if expiring {
+		secretRefreshCounts.With(reasonTag.Value(refreshExpiry)).Increment()
+	} else {
+		secretRefreshCounts.With(reasonTag.Value(refreshRootMismatch)).Increment()
+	}
+}
+
 // refreshSecret is an inner func to refresh cert secrets when necessary
-func (wc *WebhookController) refreshSecret(scrt *v1.Secret) error {
+func (wc *WebhookController) refreshSecret(scrt *v1.Secret) (err error) {
 	namespace := scrt.GetNamespace()
 	scrtName := scrt.Name
-
-	dnsName, found := wc.getDNSName(scrtName)
+	ctx, span := startSpan(context.Background(), "chiron.refreshSecret", namespace, scrtName)
+	// The secret is refreshed in a copy, since scrt may be owned by the informer cache.
+	updated := scrt.DeepCopy()
+	defer func() {
+		endSpan(span, err)
+		wc.recordUpdate(namespace, scrtName, updated.Data[ca.CertChainID], err)
+	}()
+
+	dnsName, found := wc.getDNSName(scrtName, namespace)
 	if !found {
 		return fmt.Errorf("failed to find the service name for the secret (%v) to refresh", scrtName)
 	}
 
-	chain, key, caCert, err := GenKeyCertK8sCA(wc.certClient.CertificateSigningRequests(), dnsName, scrtName, namespace, wc.k8sCaCertFile)
+	chain, key, caCert, err := wc.genKeyCert(ctx, dnsName, scrtName, namespace)
 	if err != nil {
 		return err
 	}
 
-	scrt.Data[ca.CertChainID] = chain
-	scrt.Data[ca.PrivateKeyID] = key
-	scrt.Data[ca.RootCertID] = caCert
+	if updated.Data == nil {
+		updated.Data = map[string][]byte{}
+	}
+	updated.Data[ca.CertChainID] = chain
+	updated.Data[ca.PrivateKeyID] = key
+	updated.Data[ca.RootCertID] = caCert
+	if err = wc.addPKCS7(updated, chain, caCert); err != nil {
+		return err
+	}
+	patch, err := secretPatch(scrt, updated)
+	if err != nil {
+		return err
+	}
 
-	_, err = wc.core.Secrets(namespace).Update(context.TODO(), scrt, metav1.UpdateOptions{})
+	// The secret is patched rather than updated, to keep the changes made to the rest of it
+	// concurrently, e.g. labels added by other controllers.
+	_, patchSpan := startSpan(ctx, "kube.PatchSecret", namespace, scrtName)
+	_, err = wc.core.Secrets(namespace).Patch(ctx, scrtName, types.MergePatchType, patch, metav1.PatchOptions{})
+	endSpan(patchSpan, err)
+	if err == nil {
+		auditIssuance(namespace, scrtName, chain)
+	}
 	return err
 }
 
+// recordUpdate records the result of the creation or refresh of a secret, and sends an alert when
+// it fails FailureAlertThreshold times in a row.
+func (wc *WebhookController) recordUpdate(namespace, name string, certChain []byte, err error) {
+	failures := wc.status.recordUpdate(namespace, name, certChain, err, time.Now())
+	reportInventory()
+	if wc.FailureAlertThreshold > 0 && failures == wc.FailureAlertThreshold {
+		notify.Send(notify.SecretRefreshFailing, "failed to update the webhook cert secret %s/%s %d times in a row: %v",
+			namespace, name, failures, err)
+	}
+}
+
+// auditIssuance records the issuance of the cert chain of a secret in the audit log.
+func auditIssuance(namespace, name string, chain []byte) {
+	cert, err := util.ParsePemEncodedCertificate(chain)
+	if err != nil {
+		log.Warnf("failed to parse the cert issued for secret %s/%s: %v", namespace, name, err)
+		return
+	}
+	audit.Record(audit.Entry{
+		Event:        webhookCertIssuanceEvent,
+		SANs:         cert.DNSNames,
+		Decision:     audit.Allow,
+		Reason:       fmt.Sprintf("stored in secret %s/%s", namespace, name),
+		Origin:       audit.Internal,
+		SerialNumber: cert.SerialNumber.Text(16),
+		NotAfter:     &cert.NotAfter,
+	})
+}
+
+// secretPatch returns a JSON merge patch setting the key and certs of the secret to the ones in updated,
+// along with the other data changed from scrt, and removing ForceRotationAnnotation.
+func secretPatch(scrt, updated *v1.Secret) ([]byte, error) {
+	data := map[string]interface{}{
+		ca.CertChainID:  updated.Data[ca.CertChainID],
+		ca.PrivateKeyID: updated.Data[ca.PrivateKeyID],
+		ca.RootCertID:   updated.Data[ca.RootCertID],
+	}
+	for k, v := range updated.Data {
+		if !bytes.Equal(v, scrt.Data[k]) {
+			data[k] = v
+		}
+	}
+	for k := range scrt.Data {
+		if _, ok := updated.Data[k]; !ok {
+			data[k] = nil
+		}
+	}
+	patch := map[string]interface{}{"data": data}
+	if _, ok := scrt.Annotations[ForceRotationAnnotation]; ok {
+		patch["metadata"] = map[string]interface{}{
+			"annotations": map[string]interface{}{ForceRotationAnnotation: nil},
+		}
+	}
+	return json.Marshal(patch)
+}
+
+// genKeyCert generates a key and cert for dnsName with the issuer, or the Kubernetes CA by default.
+func (wc *WebhookController) genKeyCert(ctx context.Context, dnsName, secretName, namespace string) (
+	chain, key, caCert []byte, err error) {
+	_, span := startSpan(ctx, "chiron.genKeyCert", namespace, secretName)
+	defer func() { endSpan(span, err) }()
+	if wc.Issuer != nil {
+		return wc.Issuer.Issue(dnsName, secretName, namespace)
+	}
+	return GenKeyCertK8sCA(wc.certClient.CertificateSigningRequests(), dnsName, secretName, namespace, wc.k8sCaCertFile)
+}
+
+// addPKCS7 adds the cert chain and CA cert to the secret as a PKCS#7 bundle if enabled, and
+// otherwise removes a stale bundle.
+func (wc *WebhookController) addPKCS7(scrt *v1.Secret, chain, caCert []byte) error {
+	if !wc.IncludePKCS7 {
+		delete(scrt.Data, ca.PKCS7CertChainID)
+		return nil
+	}
+	p7b, err := util.EncodePKCS7(chain, caCert)
+	if err != nil {
+		return fmt.Errorf("failed to encode the PKCS#7 bundle for secret %v in namespace %v (error %v)",
+			scrt.Name, scrt.Namespace, err)
+	}
+	scrt.Data[ca.PKCS7CertChainID] = p7b
+	return nil
+}
+
 // Return whether the input secret name is a Webhook secret
 func (wc *WebhookController) isWebhookSecret(name, namespace string) bool {
 	for i, n := range wc.secretNames {
@@ -370,9 +705,9 @@ func (wc *WebhookController) getCACert() ([]byte, error) {
 }
 
 // Get the DNS name for the secret. Return the DNS name and whether it is found.
-func (wc *WebhookController) getDNSName(secretName string) (string, bool) {
+func (wc *WebhookController) getDNSName(secretName, namespace string) (string, bool) {
 	for i, name := range wc.secretNames {
-		if name == secretName {
+		if name == secretName && wc.serviceNamespaces[i] == namespace {
 			return wc.dnsNames[i], true
 		}
 	}
@@ -21,17 +21,20 @@ import (
 	"testing"
 	"time"
 
+	"istio.io/istio/security/pkg/audit"
 	"istio.io/istio/security/pkg/pki/ca"
 
 	v1 "k8s.io/api/core/v1"
 
 	"istio.io/istio/security/pkg/pki/util"
+	certutil "istio.io/istio/security/pkg/util"
 
 	cert "k8s.io/api/certificates/v1beta1"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
 )
 
 const (
@@ -276,6 +279,9 @@ func TestScrtDeleted(t *testing.T) {
 		}
 
 		// The secret deleted should be recovered.
+		if err := wc.namespaceStore.Add(activeNamespace(tc.serviceNamespaces[0])); err != nil {
+			t.Fatal(err)
+		}
 		wc.scrtDeleted(scrt)
 		scrt, err = client.CoreV1().Secrets(tc.serviceNamespaces[0]).Get(context.TODO(), tc.secretNames[0], metav1.GetOptions{})
 		if err != nil || scrt == nil {
@@ -738,7 +744,7 @@ func TestGetDNSName(t *testing.T) {
 			t.Errorf("failed to create a webhook controller: %v", err)
 		}
 
-		ret, found := wc.getDNSName(tc.scrtName)
+		ret, found := wc.getDNSName(tc.scrtName, serviceNamespaces[0])
 		if tc.expectFound != found {
 			t.Errorf("expected found (%v) differs from the actual found (%v)", tc.expectFound, found)
 			continue
@@ -748,3 +754,352 @@ func TestGetDNSName(t *testing.T) {
 		}
 	}
 }
+
+func TestAddPKCS7(t *testing.T) {
+	wc := &WebhookController{IncludePKCS7: true}
+	scrt := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio.webhook.foo", Namespace: "foo.ns"},
+		Data:       map[string][]byte{},
+	}
+	if err := wc.addPKCS7(scrt, []byte(exampleCACert1), []byte(exampleCACert2)); err != nil {
+		t.Fatalf("failed to add the PKCS#7 bundle: %v", err)
+	}
+	if len(scrt.Data[ca.PKCS7CertChainID]) == 0 {
+		t.Errorf("expected %v in the secret data", ca.PKCS7CertChainID)
+	}
+
+	if err := wc.addPKCS7(scrt, []byte("invalid"), nil); err == nil {
+		t.Error("expected an error when there is no certificate to encode")
+	}
+
+	wc.IncludePKCS7 = false
+	if err := wc.addPKCS7(scrt, []byte(exampleCACert1), []byte(exampleCACert2)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := scrt.Data[ca.PKCS7CertChainID]; ok {
+		t.Errorf("expected %v to be removed from the secret data", ca.PKCS7CertChainID)
+	}
+}
+
+// staticIssuer issues the same cert for all secrets.
+type staticIssuer struct {
+	requests []string
+}
+
+func (i *staticIssuer) Issue(dnsNames, secretName, namespace string) (chain, key, caCert []byte, err error) {
+	i.requests = append(i.requests, dnsNames)
+	return []byte(exampleCACert1), []byte("key"), []byte(exampleCACert2), nil
+}
+
+func TestUpsertSecretWithIssuer(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	issuer := &staticIssuer{}
+	wc := &WebhookController{core: client.CoreV1(), Issuer: issuer}
+	if err := wc.upsertSecret("istio.webhook.foo", "foo.com,www.foo.com", "foo.ns"); err != nil {
+		t.Fatalf("failed to upsert the secret: %v", err)
+	}
+	scrt, err := client.CoreV1().Secrets("foo.ns").Get(context.TODO(), "istio.webhook.foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the secret: %v", err)
+	}
+	if string(scrt.Data[ca.CertChainID]) != exampleCACert1 || string(scrt.Data[ca.RootCertID]) != exampleCACert2 {
+		t.Errorf("the secret does not hold the issued cert: %v", scrt.Data)
+	}
+	if !reflect.DeepEqual(issuer.requests, []string{"foo.com,www.foo.com"}) {
+		t.Errorf("unexpected issuer requests %v", issuer.requests)
+	}
+}
+
+func TestForceRotation(t *testing.T) {
+	fca := newFakeCA(t)
+	client := fake.NewSimpleClientset()
+	wc := &WebhookController{
+		core:              client.CoreV1(),
+		secretNames:       []string{"webhook-certs"},
+		dnsNames:          []string{"webhook.ns.svc"},
+		serviceNamespaces: []string{"ns"},
+		certUtil:          certutil.NewCertUtil(50),
+		Issuer:            &CAIssuer{CA: fca, TTL: time.Hour},
+	}
+	if err := wc.upsertSecret("webhook-certs", "webhook.ns.svc", "ns"); err != nil {
+		t.Fatalf("failed to upsert the secret: %v", err)
+	}
+	scrt, err := client.CoreV1().Secrets("ns").Get(context.TODO(), "webhook-certs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the secret: %v", err)
+	}
+	scrt.Annotations = map[string]string{ForceRotationAnnotation: "2020-01-01T00:00:00Z", "other": "kept"}
+	if scrt, err = client.CoreV1().Secrets("ns").Update(context.TODO(), scrt, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update the secret: %v", err)
+	}
+	wc.scrtUpdated(nil, scrt)
+	if len(fca.hosts) != 2 {
+		t.Fatalf("expected the secret to be refreshed when the rotation is requested")
+	}
+	scrt, err = client.CoreV1().Secrets("ns").Get(context.TODO(), "webhook-certs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the secret: %v", err)
+	}
+	if !reflect.DeepEqual(scrt.Annotations, map[string]string{"other": "kept"}) {
+		t.Errorf("expected the rotation annotation to be removed, got %v", scrt.Annotations)
+	}
+	wc.scrtUpdated(nil, scrt)
+	if len(fca.hosts) != 2 {
+		t.Errorf("expected the secret not to be refreshed again")
+	}
+}
+
+func TestGracePeriodRatioAnnotation(t *testing.T) {
+	fca := newFakeCA(t)
+	client := fake.NewSimpleClientset()
+	wc := &WebhookController{
+		core:              client.CoreV1(),
+		secretNames:       []string{"webhook-certs"},
+		dnsNames:          []string{"webhook.ns.svc"},
+		serviceNamespaces: []string{"ns"},
+		certUtil:          certutil.NewCertUtil(50),
+		Issuer:            &CAIssuer{CA: fca, TTL: time.Hour},
+	}
+	if err := wc.upsertSecret("webhook-certs", "webhook.ns.svc", "ns"); err != nil {
+		t.Fatalf("failed to upsert the secret: %v", err)
+	}
+	scrt, err := client.CoreV1().Secrets("ns").Get(context.TODO(), "webhook-certs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the secret: %v", err)
+	}
+
+	for _, invalid := range []string{"soon", "1.5"} {
+		scrt.Annotations = map[string]string{GracePeriodRatioAnnotation: invalid}
+		wc.scrtUpdated(nil, scrt)
+		if len(fca.hosts) != 1 {
+			t.Fatalf("expected the secret not to be refreshed with the invalid ratio %q", invalid)
+		}
+	}
+
+	// The whole lifetime of the fresh cert is in the grace period.
+	scrt.Annotations = map[string]string{GracePeriodRatioAnnotation: "1"}
+	wc.scrtUpdated(nil, scrt)
+	if len(fca.hosts) != 2 {
+		t.Errorf("expected the secret to be refreshed with the overridden grace period ratio")
+	}
+}
+
+// activeNamespace returns an active namespace named name.
+func activeNamespace(name string) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     v1.NamespaceStatus{Phase: v1.NamespaceActive},
+	}
+}
+
+// newNamespaceStore returns a namespace store holding namespaces, as cached by the namespace informer.
+func newNamespaceStore(t *testing.T, namespaces ...*v1.Namespace) cache.Store {
+	t.Helper()
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, ns := range namespaces {
+		if err := store.Add(ns); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestScrtDeletedInTerminatingNamespace(t *testing.T) {
+	fca := newFakeCA(t)
+	ns := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ns"},
+		Status:     v1.NamespaceStatus{Phase: v1.NamespaceTerminating},
+	}
+	wc := &WebhookController{
+		core:              fake.NewSimpleClientset().CoreV1(),
+		namespaceStore:    newNamespaceStore(t, ns),
+		secretNames:       []string{"webhook-certs"},
+		dnsNames:          []string{"webhook.ns.svc"},
+		serviceNamespaces: []string{"ns"},
+		certUtil:          certutil.NewCertUtil(50),
+		Issuer:            &CAIssuer{CA: fca, TTL: time.Hour},
+	}
+	scrt := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "webhook-certs", Namespace: "ns"}}
+	wc.scrtDeleted(scrt)
+	if len(fca.hosts) != 0 {
+		t.Errorf("expected the secret not to be re-created in a terminating namespace")
+	}
+
+	if err := wc.namespaceStore.Delete(ns); err != nil {
+		t.Fatal(err)
+	}
+	wc.scrtDeleted(scrt)
+	if len(fca.hosts) != 0 {
+		t.Errorf("expected the secret not to be re-created in a deleted namespace")
+	}
+
+	if err := wc.namespaceStore.Add(activeNamespace("ns")); err != nil {
+		t.Fatal(err)
+	}
+	wc.scrtDeleted(scrt)
+	if len(fca.hosts) != 1 {
+		t.Errorf("expected the secret to be re-created in an active namespace")
+	}
+}
+
+func TestScrtDeletedRespectDeletions(t *testing.T) {
+	fca := newFakeCA(t)
+	wc := &WebhookController{
+		core:              fake.NewSimpleClientset().CoreV1(),
+		namespaceStore:    newNamespaceStore(t, activeNamespace("ns")),
+		secretNames:       []string{"webhook-certs"},
+		dnsNames:          []string{"webhook.ns.svc"},
+		serviceNamespaces: []string{"ns"},
+		certUtil:          certutil.NewCertUtil(50),
+		Issuer:            &CAIssuer{CA: fca, TTL: time.Hour},
+	}
+	annotated := &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:        "webhook-certs",
+		Namespace:   "ns",
+		Annotations: map[string]string{KeepDeletedAnnotation: ""},
+	}}
+	wc.scrtDeleted(annotated)
+	if len(fca.hosts) != 0 {
+		t.Errorf("expected the secret with the %s annotation not to be re-created", KeepDeletedAnnotation)
+	}
+
+	scrt := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "webhook-certs", Namespace: "ns"}}
+	wc.RespectDeletions = true
+	wc.scrtDeleted(scrt)
+	if len(fca.hosts) != 0 {
+		t.Errorf("expected the secret not to be re-created when the deletions are respected")
+	}
+
+	wc.RespectDeletions = false
+	wc.scrtDeleted(scrt)
+	if len(fca.hosts) != 1 {
+		t.Errorf("expected the secret to be re-created")
+	}
+}
+
+func TestRefreshSecretKeepsConcurrentChanges(t *testing.T) {
+	fca := newFakeCA(t)
+	client := fake.NewSimpleClientset()
+	wc := &WebhookController{
+		core:              client.CoreV1(),
+		secretNames:       []string{"webhook-certs"},
+		dnsNames:          []string{"webhook.ns.svc"},
+		serviceNamespaces: []string{"ns"},
+		certUtil:          certutil.NewCertUtil(50),
+		Issuer:            &CAIssuer{CA: fca, TTL: time.Hour},
+	}
+	if err := wc.upsertSecret("webhook-certs", "webhook.ns.svc", "ns"); err != nil {
+		t.Fatalf("failed to upsert the secret: %v", err)
+	}
+	cached, err := client.CoreV1().Secrets("ns").Get(context.TODO(), "webhook-certs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the secret: %v", err)
+	}
+	oldChain := cached.Data[ca.CertChainID]
+
+	// Another controller labels the secret after it was cached.
+	labeled := cached.DeepCopy()
+	labeled.Labels = map[string]string{"owner": "other-controller"}
+	labeled.Data["extra"] = []byte("kept")
+	if _, err := client.CoreV1().Secrets("ns").Update(context.TODO(), labeled, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update the secret: %v", err)
+	}
+
+	if err := wc.refreshSecret(cached); err != nil {
+		t.Fatalf("failed to refresh the secret: %v", err)
+	}
+	if !bytes.Equal(cached.Data[ca.CertChainID], oldChain) {
+		t.Errorf("expected the cached secret not to be modified")
+	}
+	scrt, err := client.CoreV1().Secrets("ns").Get(context.TODO(), "webhook-certs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the secret: %v", err)
+	}
+	if scrt.Labels["owner"] != "other-controller" || string(scrt.Data["extra"]) != "kept" {
+		t.Errorf("expected the concurrent changes to be kept, got labels %v and data keys %v", scrt.Labels, scrt.Data)
+	}
+	if bytes.Equal(scrt.Data[ca.CertChainID], oldChain) {
+		t.Errorf("expected the cert chain to be refreshed")
+	}
+}
+
+type auditSink struct {
+	entries []audit.Entry
+}
+
+func (s *auditSink) Record(e audit.Entry) {
+	s.entries = append(s.entries, e)
+}
+
+func TestAuditIssuance(t *testing.T) {
+	sink := &auditSink{}
+	defer audit.RegisterSink(sink)()
+	fca := newFakeCA(t)
+	client := fake.NewSimpleClientset()
+	wc := &WebhookController{
+		core:              client.CoreV1(),
+		secretNames:       []string{"webhook-certs"},
+		dnsNames:          []string{"webhook.ns.svc"},
+		serviceNamespaces: []string{"ns"},
+		certUtil:          certutil.NewCertUtil(50),
+		Issuer:            &CAIssuer{CA: fca, TTL: time.Hour},
+	}
+	if err := wc.upsertSecret("webhook-certs", "webhook.ns.svc", "ns"); err != nil {
+		t.Fatalf("failed to upsert the secret: %v", err)
+	}
+	scrt, err := client.CoreV1().Secrets("ns").Get(context.TODO(), "webhook-certs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the secret: %v", err)
+	}
+	if err := wc.refreshSecret(scrt); err != nil {
+		t.Fatalf("failed to refresh the secret: %v", err)
+	}
+
+	if len(sink.entries) != 2 {
+		t.Fatalf("expected an audit entry per issuance, got %v", sink.entries)
+	}
+	for _, e := range sink.entries {
+		if !e.IsIssuance() || e.Event != webhookCertIssuanceEvent || e.NotAfter == nil ||
+			!reflect.DeepEqual(e.SANs, []string{"webhook.ns.svc"}) {
+			t.Errorf("unexpected audit entry %+v", e)
+		}
+	}
+	if sink.entries[0].SerialNumber == sink.entries[1].SerialNumber {
+		t.Errorf("expected the refreshed cert to have a new serial number")
+	}
+}
+
+func TestDrain(t *testing.T) {
+	fca := newFakeCA(t)
+	client := fake.NewSimpleClientset()
+	wc := &WebhookController{
+		core:              client.CoreV1(),
+		secretNames:       []string{"webhook-certs"},
+		dnsNames:          []string{"webhook.ns.svc"},
+		serviceNamespaces: []string{"ns"},
+		certUtil:          certutil.NewCertUtil(50),
+		Issuer:            &CAIssuer{CA: fca, TTL: time.Hour},
+	}
+	if !wc.startWork() {
+		t.Fatalf("expected the work to be accepted before the controller drains")
+	}
+	if wc.Drain(10 * time.Millisecond) {
+		t.Errorf("expected the drain to time out while a secret is being updated")
+	}
+
+	scrt := &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:        "webhook-certs",
+		Namespace:   "ns",
+		Annotations: map[string]string{ForceRotationAnnotation: ""},
+	}}
+	wc.scrtUpdated(nil, scrt)
+	wc.scrtDeleted(scrt)
+	if len(fca.hosts) != 0 {
+		t.Errorf("expected no secret to be updated once the controller drains")
+	}
+
+	wc.inflight.Done()
+	if !wc.Drain(time.Second) {
+		t.Errorf("expected the drain to complete once the update completes")
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chiron
+
+import (
+	"sync"
+
+	"istio.io/pkg/monitoring"
+)
+
+const (
+	reasonLabel    = "reason"
+	namespaceLabel = "namespace"
+
+	// refreshExpiry means the cert in the secret is about to expire.
+	refreshExpiry = "expiry"
+	// refreshRootMismatch means the CA cert in the secret differs from the one of the CA.
+	refreshRootMismatch = "root_mismatch"
+	// refreshInvalidCert means the cert in the secret cannot be parsed.
+	refreshInvalidCert = "invalid_cert"
+	// refreshForced means the rotation is requested with the ForceRotationAnnotation.
+	refreshForced = "forced"
+)
+
+var (
+	reasonTag    = monitoring.MustCreateLabel(reasonLabel)
+	namespaceTag = monitoring.MustCreateLabel(namespaceLabel)
+
+	secretRefreshCounts = monitoring.NewSum(
+		"chiron_secret_refresh_count",
+		"The number of refreshes of the DNS cert secrets, by reason.",
+		monitoring.WithLabels(reasonTag),
+	)
+
+	managedSecrets = monitoring.NewGauge(
+		"chiron_managed_secrets",
+		"The number of DNS cert secrets managed by the running controllers, by namespace.",
+		monitoring.WithLabels(namespaceTag),
+	)
+
+	pendingSecrets = monitoring.NewGauge(
+		"chiron_pending_secrets",
+		"The number of managed DNS cert secrets not created yet, by namespace.",
+		monitoring.WithLabels(namespaceTag),
+	)
+
+	failingSecrets = monitoring.NewGauge(
+		"chiron_failing_secrets",
+		"The number of managed DNS cert secrets whose last creation or refresh failed, by namespace.",
+		monitoring.WithLabels(namespaceTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(secretRefreshCounts, managedSecrets, pendingSecrets, failingSecrets)
+}
+
+// secretCounts are the numbers of managed, pending and failing secrets of a namespace.
+type secretCounts struct {
+	managed, pending, failing int
+}
+
+var (
+	inventoryMutex sync.Mutex
+	// The statuses of the running controllers.
+	inventory = map[*controllerStatus]bool{}
+	// The namespaces of the last report, so that the gauges of the namespaces without secrets are reset.
+	reportedNamespaces = map[string]bool{}
+)
+
+// registerInventory adds the secrets of a running controller to the inventory metrics, until stop is closed.
+func registerInventory(s *controllerStatus, stop <-chan struct{}) {
+	inventoryMutex.Lock()
+	inventory[s] = true
+	inventoryMutex.Unlock()
+	reportInventory()
+	go func() {
+		<-stop
+		inventoryMutex.Lock()
+		delete(inventory, s)
+		inventoryMutex.Unlock()
+		reportInventory()
+	}()
+}
+
+// reportInventory records the inventory metrics of the secrets of the running controllers.
+func reportInventory() {
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+	counts := map[string]*secretCounts{}
+	for s := range inventory {
+		for ns, c := range s.countByNamespace() {
+			total, ok := counts[ns]
+			if !ok {
+				total = &secretCounts{}
+				counts[ns] = total
+			}
+			total.managed += c.managed
+			total.pending += c.pending
+			total.failing += c.failing
+		}
+	}
+	for ns := range reportedNamespaces {
+		if _, ok := counts[ns]; !ok {
+			counts[ns] = &secretCounts{}
+		}
+	}
+	reportedNamespaces = map[string]bool{}
+	for ns, c := range counts {
+		managedSecrets.With(namespaceTag.Value(ns)).Record(float64(c.managed))
+		pendingSecrets.With(namespaceTag.Value(ns)).Record(float64(c.pending))
+		failingSecrets.With(namespaceTag.Value(ns)).Record(float64(c.failing))
+		if c.managed > 0 {
+			reportedNamespaces[ns] = true
+		}
+	}
+}
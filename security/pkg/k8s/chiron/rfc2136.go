@@ -0,0 +1,88 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chiron
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const defaultRFC2136TTL = 60
+
+// RFC2136Provider publishes the TXT records of ACME DNS-01 challenges with RFC 2136 dynamic
+// updates, signed with TSIG if configured.
+type RFC2136Provider struct {
+	// Nameserver is the address of the primary DNS server of the zone, e.g. "10.0.0.1:53".
+	Nameserver string
+	// Zone is the zone of the DNS names, e.g. "example.com.".
+	Zone string
+	// TSIGKey and TSIGSecret are the name and base64 secret of the TSIG key, if set.
+	TSIGKey    string
+	TSIGSecret string
+	// TSIGAlgorithm is the TSIG algorithm, hmac-sha256 by default.
+	TSIGAlgorithm string
+	// TTL is the TTL of the TXT records, 60s by default.
+	TTL uint32
+	// Timeout is the timeout of the updates.
+	Timeout time.Duration
+}
+
+// Present creates the TXT record fqdn with value.
+func (p *RFC2136Provider) Present(fqdn, value string) error {
+	return p.update(fqdn, value, true)
+}
+
+// CleanUp removes the TXT record fqdn with value.
+func (p *RFC2136Provider) CleanUp(fqdn, value string) error {
+	return p.update(fqdn, value, false)
+}
+
+func (p *RFC2136Provider) update(fqdn, value string, insert bool) error {
+	ttl := p.TTL
+	if ttl == 0 {
+		ttl = defaultRFC2136TTL
+	}
+	rr := &dns.TXT{
+		Hdr: dns.RR_Header{Name: dns.Fqdn(fqdn), Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: ttl},
+		Txt: []string{value},
+	}
+	m := new(dns.Msg)
+	m.SetUpdate(dns.Fqdn(p.Zone))
+	if insert {
+		m.Insert([]dns.RR{rr})
+	} else {
+		m.Remove([]dns.RR{rr})
+	}
+	c := &dns.Client{Timeout: p.Timeout}
+	if p.TSIGKey != "" {
+		algorithm := p.TSIGAlgorithm
+		if algorithm == "" {
+			algorithm = dns.HmacSHA256
+		}
+		key := dns.Fqdn(p.TSIGKey)
+		m.SetTsig(key, dns.Fqdn(algorithm), 300, time.Now().Unix())
+		c.TsigSecret = map[string]string{key: p.TSIGSecret}
+	}
+	reply, _, err := c.Exchange(m, p.Nameserver)
+	if err != nil {
+		return fmt.Errorf("DNS update of %s failed: %v", fqdn, err)
+	}
+	if reply.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("DNS update of %s failed: %s", fqdn, dns.RcodeToString[reply.Rcode])
+	}
+	return nil
+}
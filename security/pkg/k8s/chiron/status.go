@@ -0,0 +1,185 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chiron
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// The number of errors kept in the status of a WebhookController.
+const maxRecentErrors = 20
+
+// ControllerStatus is a snapshot of the state of a WebhookController, for debugging.
+type ControllerStatus struct {
+	// The namespaces of the managed secrets.
+	Namespaces []string `json:"namespaces"`
+	// The managed secrets.
+	Secrets []SecretStatus `json:"secrets"`
+	// The secrets whose last creation or refresh failed. They are retried at the next resync.
+	PendingRetries []string `json:"pendingRetries"`
+	// The last time the CA cert was loaded.
+	LastCACertSync time.Time `json:"lastCACertSync,omitempty"`
+	// The most recent errors, the latest last.
+	RecentErrors []ErrorRecord `json:"recentErrors"`
+}
+
+// SecretStatus is the state of a secret managed by a WebhookController.
+type SecretStatus struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	DNSNames  string `json:"dnsNames"`
+	// The expiry of the cert in the secret, if known.
+	NotAfter time.Time `json:"notAfter,omitempty"`
+	// The last time the secret was created or refreshed by the controller.
+	LastUpdate time.Time `json:"lastUpdate,omitempty"`
+	// The number of consecutive failures to create or refresh the secret.
+	Failures  int    `json:"failures,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// ErrorRecord is an error of a WebhookController.
+type ErrorRecord struct {
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Error     string    `json:"error"`
+}
+
+// controllerStatus tracks the state of the secrets of a WebhookController.
+type controllerStatus struct {
+	mutex          sync.Mutex
+	secrets        map[string]*SecretStatus
+	lastCACertSync time.Time
+	recentErrors   []ErrorRecord
+}
+
+// track starts tracking the secrets.
+func (s *controllerStatus) track(secretNames, dnsNames, namespaces []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.secrets = map[string]*SecretStatus{}
+	for i, name := range secretNames {
+		s.secrets[namespaces[i]+"/"+name] = &SecretStatus{Namespace: namespaces[i], Name: name, DNSNames: dnsNames[i]}
+	}
+}
+
+// observe records the expiry of the cert chain in the secret name in namespace.
+func (s *controllerStatus) observe(namespace, name string, certChain []byte) {
+	cert, err := util.ParsePemEncodedCertificate(certChain)
+	if err != nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if st, ok := s.secrets[namespace+"/"+name]; ok {
+		st.NotAfter = cert.NotAfter
+	}
+}
+
+// recordUpdate records the result of the creation or refresh of the secret name in namespace. A nil
+// certChain and err means the secret was left unchanged. It returns the number of consecutive failures.
+func (s *controllerStatus) recordUpdate(namespace, name string, certChain []byte, err error, now time.Time) int {
+	if err == nil && certChain != nil {
+		s.observe(namespace, name, certChain)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	st, ok := s.secrets[namespace+"/"+name]
+	if !ok {
+		return 0
+	}
+	if err == nil {
+		if certChain != nil {
+			st.LastUpdate = now
+		}
+		st.Failures = 0
+		st.LastError = ""
+		return 0
+	}
+	st.Failures++
+	st.LastError = err.Error()
+	s.recentErrors = append(s.recentErrors, ErrorRecord{Time: now, Namespace: namespace, Name: name, Error: err.Error()})
+	if len(s.recentErrors) > maxRecentErrors {
+		s.recentErrors = s.recentErrors[len(s.recentErrors)-maxRecentErrors:]
+	}
+	return st.Failures
+}
+
+// countByNamespace returns the numbers of secrets by namespace. A secret is pending until the cert in
+// it is known, and failing if its last creation or refresh failed.
+func (s *controllerStatus) countByNamespace() map[string]secretCounts {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	counts := map[string]secretCounts{}
+	for _, st := range s.secrets {
+		c := counts[st.Namespace]
+		c.managed++
+		if st.NotAfter.IsZero() {
+			c.pending++
+		}
+		if st.Failures > 0 {
+			c.failing++
+		}
+		counts[st.Namespace] = c
+	}
+	return counts
+}
+
+func (s *controllerStatus) recordCACertSync(now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastCACertSync = now
+}
+
+func (s *controllerStatus) snapshot() ControllerStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	out := ControllerStatus{
+		Namespaces:     []string{},
+		Secrets:        []SecretStatus{},
+		PendingRetries: []string{},
+		LastCACertSync: s.lastCACertSync,
+		RecentErrors:   append([]ErrorRecord{}, s.recentErrors...),
+	}
+	namespaces := map[string]bool{}
+	for key, st := range s.secrets {
+		out.Secrets = append(out.Secrets, *st)
+		namespaces[st.Namespace] = true
+		if st.Failures > 0 {
+			out.PendingRetries = append(out.PendingRetries, key)
+		}
+	}
+	for ns := range namespaces {
+		out.Namespaces = append(out.Namespaces, ns)
+	}
+	sort.Strings(out.Namespaces)
+	sort.Strings(out.PendingRetries)
+	sort.Slice(out.Secrets, func(i, j int) bool {
+		if out.Secrets[i].Namespace != out.Secrets[j].Namespace {
+			return out.Secrets[i].Namespace < out.Secrets[j].Namespace
+		}
+		return out.Secrets[i].Name < out.Secrets[j].Name
+	})
+	return out
+}
+
+// Status returns a snapshot of the state of the controller, for debugging.
+func (wc *WebhookController) Status() ControllerStatus {
+	return wc.status.snapshot()
+}
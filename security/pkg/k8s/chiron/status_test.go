@@ -0,0 +1,182 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chiron
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/security/pkg/notify"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func TestControllerStatus(t *testing.T) {
+	now := time.Now()
+	certPEM, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         "svc.ns.svc",
+		NotBefore:    now,
+		TTL:          time.Hour,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to generate a cert: %v", err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		t.Fatalf("failed to parse the cert: %v", err)
+	}
+
+	var s controllerStatus
+	s.track([]string{"b-cert", "a-cert"}, []string{"b.ns2", "a.ns1"}, []string{"ns2", "ns1"})
+	s.recordCACertSync(now)
+	s.recordUpdate("ns1", "a-cert", certPEM, nil, now)
+	s.recordUpdate("ns2", "b-cert", nil, fmt.Errorf("forbidden"), now)
+	// Secrets not managed by the controller are ignored.
+	s.recordUpdate("ns3", "c-cert", nil, fmt.Errorf("ignored"), now)
+
+	expected := ControllerStatus{
+		Namespaces: []string{"ns1", "ns2"},
+		Secrets: []SecretStatus{
+			{Namespace: "ns1", Name: "a-cert", DNSNames: "a.ns1", NotAfter: cert.NotAfter, LastUpdate: now},
+			{Namespace: "ns2", Name: "b-cert", DNSNames: "b.ns2", Failures: 1, LastError: "forbidden"},
+		},
+		PendingRetries: []string{"ns2/b-cert"},
+		LastCACertSync: now,
+		RecentErrors:   []ErrorRecord{{Time: now, Namespace: "ns2", Name: "b-cert", Error: "forbidden"}},
+	}
+	if status := s.snapshot(); !reflect.DeepEqual(status, expected) {
+		t.Errorf("got status %+v, want %+v", status, expected)
+	}
+
+	// A successful retry clears the failure.
+	s.recordUpdate("ns2", "b-cert", certPEM, nil, now)
+	if status := s.snapshot(); len(status.PendingRetries) != 0 || status.Secrets[1].LastError != "" {
+		t.Errorf("expected no pending retries after a successful retry, got %+v", status)
+	}
+
+	for i := 0; i < 2*maxRecentErrors; i++ {
+		s.recordUpdate("ns1", "a-cert", nil, fmt.Errorf("error %d", i), now)
+	}
+	status := s.snapshot()
+	if len(status.RecentErrors) != maxRecentErrors {
+		t.Fatalf("got %d recent errors, want %d", len(status.RecentErrors), maxRecentErrors)
+	}
+	if last := status.RecentErrors[maxRecentErrors-1].Error; last != fmt.Sprintf("error %d", 2*maxRecentErrors-1) {
+		t.Errorf("got last error %q, want the latest error", last)
+	}
+	if status.Secrets[0].Failures != 2*maxRecentErrors {
+		t.Errorf("got %d failures, want %d", status.Secrets[0].Failures, 2*maxRecentErrors)
+	}
+}
+
+type fakeNotifier struct {
+	alerts []notify.Alert
+}
+
+func (n *fakeNotifier) Notify(alert notify.Alert) {
+	n.alerts = append(n.alerts, alert)
+}
+
+func TestFailureAlert(t *testing.T) {
+	n := &fakeNotifier{}
+	defer notify.Register(n)()
+	wc := &WebhookController{FailureAlertThreshold: 2}
+	wc.status.track([]string{"a-cert"}, []string{"a.ns1"}, []string{"ns1"})
+
+	for i := 0; i < 3; i++ {
+		wc.recordUpdate("ns1", "a-cert", nil, fmt.Errorf("forbidden"))
+	}
+	if len(n.alerts) != 1 || n.alerts[0].Kind != notify.SecretRefreshFailing {
+		t.Fatalf("expected a single alert, got %v", n.alerts)
+	}
+
+	// The alert is sent again if the secret fails again after a success.
+	wc.recordUpdate("ns1", "a-cert", nil, nil)
+	wc.recordUpdate("ns1", "a-cert", nil, fmt.Errorf("forbidden"))
+	wc.recordUpdate("ns1", "a-cert", nil, fmt.Errorf("forbidden"))
+	if len(n.alerts) != 2 {
+		t.Errorf("expected a second alert, got %v", n.alerts)
+	}
+}
+
+func gaugeValue(t *testing.T, name, namespace string) float64 {
+	t.Helper()
+	rows, err := view.RetrieveData(name)
+	if err != nil {
+		t.Fatalf("failed to get the value of %s: %v", name, err)
+	}
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key.Name() == namespaceLabel && tag.Value == namespace {
+				return row.Data.(*view.LastValueData).Value
+			}
+		}
+	}
+	return 0
+}
+
+func TestInventoryMetrics(t *testing.T) {
+	certPEM, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         "a.inventory1.svc",
+		NotBefore:    time.Now(),
+		TTL:          time.Hour,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wc := &WebhookController{}
+	wc.status.track([]string{"a-cert", "b-cert", "c-cert"}, []string{"a", "b", "c"},
+		[]string{"inventory1", "inventory1", "inventory2"})
+	stop := make(chan struct{})
+	registerInventory(&wc.status, stop)
+
+	expect := func(namespace string, managed, pending, failing float64) {
+		t.Helper()
+		if got := gaugeValue(t, "chiron_managed_secrets", namespace); got != managed {
+			t.Errorf("managed secrets of %s: got %v, want %v", namespace, got, managed)
+		}
+		if got := gaugeValue(t, "chiron_pending_secrets", namespace); got != pending {
+			t.Errorf("pending secrets of %s: got %v, want %v", namespace, got, pending)
+		}
+		if got := gaugeValue(t, "chiron_failing_secrets", namespace); got != failing {
+			t.Errorf("failing secrets of %s: got %v, want %v", namespace, got, failing)
+		}
+	}
+	expect("inventory1", 2, 2, 0)
+	expect("inventory2", 1, 1, 0)
+
+	wc.recordUpdate("inventory1", "a-cert", certPEM, nil)
+	wc.recordUpdate("inventory2", "c-cert", nil, fmt.Errorf("forbidden"))
+	expect("inventory1", 2, 1, 0)
+	expect("inventory2", 1, 1, 1)
+
+	// The secrets of a stopped controller are not counted anymore.
+	close(stop)
+	retry.UntilSuccessOrFail(t, func() error {
+		if got := gaugeValue(t, "chiron_managed_secrets", "inventory1"); got != 0 {
+			return fmt.Errorf("got %v managed secrets after the controller stopped", got)
+		}
+		return nil
+	}, retry.Timeout(time.Second*2))
+	expect("inventory2", 0, 0, 0)
+}
@@ -0,0 +1,43 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chiron
+
+import (
+	"context"
+
+	"go.opencensus.io/trace"
+)
+
+// Span attribute keys of the secret the traced operation acts on.
+const (
+	namespaceAttr = "namespace"
+	secretAttr    = "secret"
+)
+
+// startSpan starts a span for an operation on the secret name in namespace. The spans are exported
+// with the OpenCensus trace exporters registered by the process, if any.
+func startSpan(ctx context.Context, spanName, namespace, name string) (context.Context, *trace.Span) {
+	ctx, span := trace.StartSpan(ctx, spanName)
+	span.AddAttributes(trace.StringAttribute(namespaceAttr, namespace), trace.StringAttribute(secretAttr, name))
+	return ctx, span
+}
+
+// endSpan records err, if any, as the status of span and ends it.
+func endSpan(span *trace.Span, err error) {
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+	}
+	span.End()
+}
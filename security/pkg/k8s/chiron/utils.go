@@ -180,6 +180,7 @@ func reloadCACert(wc *WebhookController) (bool, error) {
 	if err != nil {
 		return certChanged, err
 	}
+	wc.status.recordCACertSync(time.Now())
 	if !bytes.Equal(caCert, wc.CACert) {
 		wc.CACert = append([]byte(nil), caCert...)
 		certChanged = true
@@ -311,7 +312,23 @@ func readSignedCertificate(certClient certclient.CertificateSigningRequestInterf
 		}
 		return nil, nil, fmt.Errorf("failed to create cert pool")
 	}
-	if ok := roots.AppendCertsFromPEM(caCert); !ok {
+	// The CA cert may be a bundle of the root cert and the intermediate CAs of the signer, and the
+	// signed certificate may be followed by intermediate CAs, e.g. policy CA -> issuing CA.
+	intermediateCerts, rootCerts, err := util.SplitRootCerts(append(append([]byte{}, certPEM...), caCert...))
+	if err != nil {
+		errCsr := cleanUpCertGen(certClient, csrName)
+		if errCsr != nil {
+			log.Errorf("failed to clean up CSR (%v): %v", csrName, err)
+		}
+		return nil, nil, fmt.Errorf("failed to parse the certificate chain: %v", err)
+	}
+	if len(rootCerts) == 0 {
+		// The signer's CA cert is not self-signed, so it is the trust anchor.
+		rootCerts = caCert
+	}
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM(intermediateCerts)
+	if ok := roots.AppendCertsFromPEM(rootCerts); !ok {
 		errCsr := cleanUpCertGen(certClient, csrName)
 		if errCsr != nil {
 			log.Errorf("failed to clean up CSR (%v): %v", csrName, err)
@@ -328,7 +345,8 @@ func readSignedCertificate(certClient certclient.CertificateSigningRequestInterf
 		return nil, nil, fmt.Errorf("failed to parse the certificate: %v", err)
 	}
 	_, err = certParsed.Verify(x509.VerifyOptions{
-		Roots: roots,
+		Roots:         roots,
+		Intermediates: intermediates,
 	})
 	if err != nil {
 		log.Errorf("failed to verify the certificate chain: %v", err)
@@ -341,6 +359,10 @@ func readSignedCertificate(certClient certclient.CertificateSigningRequestInterf
 	certChain := []byte{}
 	certChain = append(certChain, certPEM...)
 	certChain = append(certChain, caCert...)
+	// Order the chain from the signed certificate towards the root.
+	if ordered, err := util.OrderCertChain(certChain); err == nil {
+		certChain = ordered
+	}
 
 	return certChain, caCert, nil
 }
@@ -16,8 +16,10 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"istio.io/istio/security/pkg/pki/kms"
 	"istio.io/pkg/log"
 
 	v1 "k8s.io/api/core/v1"
@@ -35,13 +37,41 @@ const (
 	PrivateKeyID = "key.pem"
 	// The ID/name for the CA root certificate file.
 	RootCertID = "root-cert.pem"
+
+	// caPrivateKeyID is the ID/name of the CA private key in the CA secret.
+	caPrivateKeyID = "ca-key.pem"
 )
 
 var k8sControllerLog = log.RegisterScope("secretcontroller", "Citadel kubernetes controller log", 0)
 
+// CASecretSource describes the secret holding plugged-in CA credentials and the data keys
+// under which each PEM is stored. It allows loading secrets that do not follow the
+// istio-ca-secret layout, e.g. a cert-manager issued CA secret with tls.crt/tls.key/ca.crt.
+type CASecretSource struct {
+	// Name of the secret.
+	Name string
+	// Namespace of the secret.
+	Namespace string
+	// CertKey is the data key of the CA signing certificate.
+	CertKey string
+	// PrivateKeyKey is the data key of the CA private key.
+	PrivateKeyKey string
+	// CertChainKey is the data key of the CA certificate chain. Optional.
+	CertChainKey string
+	// RootCertKey is the data key of the root certificate. Optional; when absent
+	// the signing certificate is used as the root.
+	RootCertKey string
+	// KeyPassphrase decrypts the CA private key if it is encrypted. Optional.
+	KeyPassphrase []byte
+}
+
 // CaSecretController manages the self-signed signing CA secret.
 type CaSecretController struct {
 	client corev1.CoreV1Interface
+
+	// keyEncryption encrypts the CA private key stored in the CA secret. The key is
+	// stored in plaintext if it is nil.
+	keyEncryption kms.Provider
 }
 
 // NewCaSecretController returns a pointer to a newly constructed SecretController instance.
@@ -52,6 +82,53 @@ func NewCaSecretController(core corev1.CoreV1Interface) *CaSecretController {
 	return cs
 }
 
+// NewCaSecretControllerWithKeyEncryption returns a CaSecretController that stores the CA private
+// key envelope encrypted by provider, and decrypts it on load. The plaintext key is only kept in memory.
+func NewCaSecretControllerWithKeyEncryption(core corev1.CoreV1Interface, provider kms.Provider) *CaSecretController {
+	return &CaSecretController{
+		client:        core,
+		keyEncryption: provider,
+	}
+}
+
+// DecryptCASecret returns a copy of caSecret with the CA private key decrypted. caSecret is
+// returned as is if key encryption is not configured.
+func (csc *CaSecretController) DecryptCASecret(caSecret *v1.Secret) (*v1.Secret, error) {
+	if csc.keyEncryption == nil || caSecret == nil || len(caSecret.Data[caPrivateKeyID]) == 0 {
+		return caSecret, nil
+	}
+	key, err := kms.DecryptPrivateKey(csc.keyEncryption, caSecret.Data[caPrivateKeyID])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the private key in CA secret %s:%s (%v)",
+			caSecret.Namespace, caSecret.Name, err)
+	}
+	decrypted := caSecret.DeepCopy()
+	decrypted.Data[caPrivateKeyID] = key
+	return decrypted, nil
+}
+
+// EncryptCASecret returns a copy of caSecret with the CA private key encrypted. caSecret is
+// returned as is if key encryption is not configured or the key is already encrypted.
+func (csc *CaSecretController) EncryptCASecret(caSecret *v1.Secret) (*v1.Secret, error) {
+	if csc.keyEncryption == nil || caSecret == nil || len(caSecret.Data[caPrivateKeyID]) == 0 ||
+		kms.IsEncrypted(caSecret.Data[caPrivateKeyID]) {
+		return caSecret, nil
+	}
+	key, err := kms.EncryptPrivateKey(csc.keyEncryption, caSecret.Data[caPrivateKeyID])
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt the private key in CA secret %s:%s (%v)",
+			caSecret.Namespace, caSecret.Name, err)
+	}
+	encrypted := caSecret.DeepCopy()
+	encrypted.Data[caPrivateKeyID] = key
+	return encrypted, nil
+}
+
+// IsCASecretKeyEncrypted returns true if the CA private key in caSecret is stored encrypted.
+func IsCASecretKeyEncrypted(caSecret *v1.Secret) bool {
+	return kms.IsEncrypted(caSecret.Data[caPrivateKeyID])
+}
+
 // LoadCASecretWithRetry reads CA secret with retries until timeout.
 func (csc *CaSecretController) LoadCASecretWithRetry(secretName, namespace string,
 	retryInterval, timeout time.Duration) (*v1.Secret, error) {
@@ -61,7 +138,7 @@ func (csc *CaSecretController) LoadCASecretWithRetry(secretName, namespace strin
 	for {
 		caSecret, scrtErr = csc.client.Secrets(namespace).Get(context.TODO(), secretName, metav1.GetOptions{})
 		if scrtErr == nil {
-			return caSecret, scrtErr
+			return csc.DecryptCASecret(caSecret)
 		}
 		k8sControllerLog.Errorf("Failed on loading CA secret %s:%s.",
 			namespace, secretName)
@@ -78,6 +155,10 @@ func (csc *CaSecretController) LoadCASecretWithRetry(secretName, namespace strin
 // UpdateCASecretWithRetry updates CA secret with retries until timeout.
 func (csc *CaSecretController) UpdateCASecretWithRetry(caSecret *v1.Secret,
 	retryInterval, timeout time.Duration) error {
+	caSecret, err := csc.EncryptCASecret(caSecret)
+	if err != nil {
+		return err
+	}
 	start := time.Now()
 	for {
 		_, scrtErr := csc.client.Secrets(caSecret.Namespace).Update(context.TODO(), caSecret, metav1.UpdateOptions{})
@@ -95,3 +176,31 @@ func (csc *CaSecretController) UpdateCASecretWithRetry(caSecret *v1.Secret,
 		time.Sleep(retryInterval)
 	}
 }
+
+// LoadCAKeyCertWithRetry reads the CA secret described by source with retries until timeout,
+// and returns the signing cert, private key, cert chain and root cert stored in it.
+func (csc *CaSecretController) LoadCAKeyCertWithRetry(source CASecretSource,
+	retryInterval, timeout time.Duration) (certBytes, privKeyBytes, certChainBytes, rootCertBytes []byte, err error) {
+	caSecret, err := csc.LoadCASecretWithRetry(source.Name, source.Namespace, retryInterval, timeout)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if certBytes = caSecret.Data[source.CertKey]; len(certBytes) == 0 {
+		return nil, nil, nil, nil, fmt.Errorf("CA secret %s:%s has no data for key %q",
+			source.Namespace, source.Name, source.CertKey)
+	}
+	if privKeyBytes = caSecret.Data[source.PrivateKeyKey]; len(privKeyBytes) == 0 {
+		return nil, nil, nil, nil, fmt.Errorf("CA secret %s:%s has no data for key %q",
+			source.Namespace, source.Name, source.PrivateKeyKey)
+	}
+	if len(source.CertChainKey) > 0 {
+		certChainBytes = caSecret.Data[source.CertChainKey]
+	}
+	if len(source.RootCertKey) > 0 {
+		rootCertBytes = caSecret.Data[source.RootCertKey]
+	}
+	if len(rootCertBytes) == 0 {
+		rootCertBytes = certBytes
+	}
+	return certBytes, privKeyBytes, certChainBytes, rootCertBytes, nil
+}
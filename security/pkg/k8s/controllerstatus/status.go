@@ -0,0 +1,163 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package controllerstatus periodically writes the status of the certificate controllers of istiod to
+// a ConfigMap, for dashboards and istioctl.
+package controllerstatus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/security/pkg/k8s/chiron"
+	"istio.io/pkg/log"
+)
+
+const (
+	// DefaultName is the default name of the status ConfigMap.
+	DefaultName = "istio-ca-controller-status"
+	// DataKey is the key of the JSON encoded Report in the status ConfigMap.
+	DataKey = "status.json"
+)
+
+// Report is the status of the certificate controllers.
+type Report struct {
+	// Version is the version of the istiod writing the report.
+	Version string `json:"version"`
+	// Leader is the name of the istiod pod writing the report.
+	Leader string `json:"leader"`
+	// RootCertSHA256 is the hex encoded SHA-256 fingerprint of the root cert of the CA.
+	RootCertSHA256 string `json:"rootCertSHA256,omitempty"`
+	// ManagedSecrets is the number of secrets managed by the controllers.
+	ManagedSecrets int `json:"managedSecrets"`
+	// PendingSecrets is the number of managed secrets not created yet.
+	PendingSecrets int `json:"pendingSecrets"`
+	// FailingSecrets is the number of managed secrets whose last creation or refresh failed.
+	FailingSecrets int `json:"failingSecrets"`
+	// LastCACertSync is the last time the controllers loaded the CA cert.
+	LastCACertSync *metav1.Time `json:"lastCACertSync,omitempty"`
+	// LastSecretUpdate is the last time a managed secret was created or refreshed.
+	LastSecretUpdate *metav1.Time `json:"lastSecretUpdate,omitempty"`
+	// ReportTime is the time of the report.
+	ReportTime metav1.Time `json:"reportTime"`
+}
+
+// AddControllerStatus adds the secrets and timestamps of the status of a controller to the report.
+func (r *Report) AddControllerStatus(status chiron.ControllerStatus) {
+	for _, st := range status.Secrets {
+		r.ManagedSecrets++
+		if st.NotAfter.IsZero() {
+			r.PendingSecrets++
+		}
+		if st.Failures > 0 {
+			r.FailingSecrets++
+		}
+		r.LastSecretUpdate = latest(r.LastSecretUpdate, st.LastUpdate)
+	}
+	r.LastCACertSync = latest(r.LastCACertSync, status.LastCACertSync)
+}
+
+func latest(current *metav1.Time, t time.Time) *metav1.Time {
+	if t.IsZero() || (current != nil && !current.Time.Before(t)) {
+		return current
+	}
+	return &metav1.Time{Time: t}
+}
+
+// Writer periodically writes the reports returned by collect to the status ConfigMap.
+type Writer struct {
+	client    corev1.ConfigMapsGetter
+	namespace string
+	name      string
+	collect   func() Report
+}
+
+// NewWriter returns a Writer of the status ConfigMap name in namespace.
+func NewWriter(client corev1.ConfigMapsGetter, namespace, name string, collect func() Report) *Writer {
+	return &Writer{client: client, namespace: namespace, name: name, collect: collect}
+}
+
+// Run writes a report every interval until stop is closed.
+func (w *Writer) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Write(context.TODO(), time.Now()); err != nil {
+			log.Errorf("failed to write the controller status: %v", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Write writes the report collected at now to the status ConfigMap, creating it if needed.
+func (w *Writer) Write(ctx context.Context, now time.Time) error {
+	report := w.collect()
+	report.ReportTime = metav1.Time{Time: now}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal the controller status: %v", err)
+	}
+
+	configMaps := w.client.ConfigMaps(w.namespace)
+	cm, err := configMaps.Get(ctx, w.name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: w.name, Namespace: w.namespace},
+			Data:       map[string]string{DataKey: string(data)},
+		}
+		if _, err := configMaps.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create ConfigMap %s/%s: %v", w.namespace, w.name, err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get ConfigMap %s/%s: %v", w.namespace, w.name, err)
+	}
+	cm = cm.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[DataKey] = string(data)
+	if _, err := configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update ConfigMap %s/%s: %v", w.namespace, w.name, err)
+	}
+	return nil
+}
+
+// Read reads the last report from the status ConfigMap name in namespace.
+func Read(ctx context.Context, client corev1.ConfigMapsGetter, namespace, name string) (*Report, error) {
+	cm, err := client.ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ConfigMap %s/%s: %v", namespace, name, err)
+	}
+	data, ok := cm.Data[DataKey]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %s/%s has no %s", namespace, name, DataKey)
+	}
+	report := &Report{}
+	if err := json.Unmarshal([]byte(data), report); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the controller status: %v", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,91 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllerstatus
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/k8s/chiron"
+)
+
+func TestAddControllerStatus(t *testing.T) {
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	report := Report{}
+	report.AddControllerStatus(chiron.ControllerStatus{
+		Secrets: []chiron.SecretStatus{
+			{Name: "a", NotAfter: t0.Add(time.Hour), LastUpdate: t0},
+			{Name: "b"},
+			{Name: "c", NotAfter: t0.Add(time.Hour), LastUpdate: t0.Add(time.Minute), Failures: 2},
+		},
+		LastCACertSync: t0,
+	})
+	report.AddControllerStatus(chiron.ControllerStatus{
+		Secrets:        []chiron.SecretStatus{{Name: "d", NotAfter: t0.Add(time.Hour)}},
+		LastCACertSync: t0.Add(time.Second),
+	})
+
+	want := Report{
+		ManagedSecrets:   4,
+		PendingSecrets:   1,
+		FailingSecrets:   1,
+		LastCACertSync:   &metav1.Time{Time: t0.Add(time.Second)},
+		LastSecretUpdate: &metav1.Time{Time: t0.Add(time.Minute)},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got report %+v, want %+v", report, want)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	managed := 1
+	w := NewWriter(client.CoreV1(), "istio-system", DefaultName, func() Report {
+		return Report{Version: "1.7.0", Leader: "istiod-1", RootCertSHA256: "abcd", ManagedSecrets: managed}
+	})
+
+	now := time.Now().Truncate(time.Second)
+	if err := w.Write(context.TODO(), now); err != nil {
+		t.Fatal(err)
+	}
+	report, err := Read(context.TODO(), client.CoreV1(), "istio-system", DefaultName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Leader != "istiod-1" || report.ManagedSecrets != 1 || !report.ReportTime.Time.Equal(now) {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	// The existing ConfigMap is updated.
+	managed = 2
+	if err := w.Write(context.TODO(), now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if report, err = Read(context.TODO(), client.CoreV1(), "istio-system", DefaultName); err != nil {
+		t.Fatal(err)
+	}
+	if report.ManagedSecrets != 2 || !report.ReportTime.Time.Equal(now.Add(time.Minute)) {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	if _, err := Read(context.TODO(), client.CoreV1(), "istio-system", "missing"); err == nil {
+		t.Error("expected an error reading a missing ConfigMap")
+	}
+}
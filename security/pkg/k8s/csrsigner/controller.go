@@ -0,0 +1,192 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package csrsigner signs the Kubernetes CertificateSigningRequests of the istio.io signers with
+// the Istio CA, so that platform components can request Istio-rooted certs via the Kubernetes API.
+//
+// Only the CSRs approved by an approver, e.g. an administrator or an approval controller, are
+// signed. The SANs requested in the CSRs are signed if the SAN policy of the CA allows the user
+// who created the CSR to request them. A SPIFFE identity can only be requested by the service
+// account it identifies, in the trust domain or one of its aliases, so that nobody can get a cert for the identity of another workload or of
+// istiod.
+package csrsigner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	certv1beta1 "k8s.io/api/certificates/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	certclient "k8s.io/client-go/kubernetes/typed/certificates/v1beta1"
+	"k8s.io/client-go/tools/cache"
+
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/pkg/log"
+)
+
+const (
+	// SignerNamePrefix is the prefix of the signer names of the CSRs signed by the controller.
+	SignerNamePrefix = "istio.io/"
+
+	// serviceAccountPrefix is the prefix of the user names Kubernetes authenticates the service
+	// accounts as, followed by <namespace>:<name>.
+	serviceAccountPrefix = "system:serviceaccount:"
+
+	resyncPeriod = time.Minute
+)
+
+var signerLog = log.RegisterScope("csrsigner", "Kubernetes CSR signer log", 0)
+
+// CertificateAuthority signs the CSRs.
+type CertificateAuthority interface {
+	// SignWithCertChain signs the PEM-encoded CSR for subjectIDs, and returns the cert with its chain.
+	SignWithCertChain(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error)
+}
+
+// sanAuthorizer is implemented by CAs enforcing a SAN policy, such as the Istio CA.
+type sanAuthorizer interface {
+	AuthorizeSANs(requester string, sans []string) error
+}
+
+// Controller signs the approved CSRs of the istio.io signers.
+type Controller struct {
+	client certclient.CertificatesV1beta1Interface
+	ca     CertificateAuthority
+	ttl    time.Duration
+
+	controller cache.Controller
+}
+
+// NewController creates a Controller signing the CSRs with ca, for ttl.
+func NewController(client certclient.CertificatesV1beta1Interface, ca CertificateAuthority, ttl time.Duration) *Controller {
+	c := &Controller{
+		client: client,
+		ca:     ca,
+		ttl:    ttl,
+	}
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return client.CertificateSigningRequests().List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CertificateSigningRequests().Watch(context.TODO(), options)
+		},
+	}
+	_, c.controller = cache.NewInformer(lw, &certv1beta1.CertificateSigningRequest{}, resyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: c.csrUpdated,
+			UpdateFunc: func(_, obj interface{}) {
+				c.csrUpdated(obj)
+			},
+		})
+	return c
+}
+
+// Run runs the controller until stopCh is closed.
+func (c *Controller) Run(stopCh <-chan struct{}) {
+	signerLog.Infof("signing the approved CSRs of the %s* signers", SignerNamePrefix)
+	c.controller.Run(stopCh)
+}
+
+func (c *Controller) csrUpdated(obj interface{}) {
+	csr, ok := obj.(*certv1beta1.CertificateSigningRequest)
+	if !ok || !shouldSign(csr) {
+		return
+	}
+	if err := c.sign(csr); err != nil {
+		signerLog.Errorf("failed to sign CSR %s: %v", csr.Name, err)
+	}
+}
+
+// shouldSign returns true if csr is for an istio.io signer, approved and not signed yet.
+func shouldSign(csr *certv1beta1.CertificateSigningRequest) bool {
+	if csr.Spec.SignerName == nil || !strings.HasPrefix(*csr.Spec.SignerName, SignerNamePrefix) {
+		return false
+	}
+	if len(csr.Status.Certificate) > 0 {
+		return false
+	}
+	approved := false
+	for _, condition := range csr.Status.Conditions {
+		switch condition.Type {
+		case certv1beta1.CertificateDenied:
+			return false
+		case certv1beta1.CertificateApproved:
+			approved = true
+		}
+	}
+	return approved
+}
+
+// sign signs csr for the SANs it requests, and stores the cert chain in its status.
+func (c *Controller) sign(csr *certv1beta1.CertificateSigningRequest) error {
+	request, err := util.ParsePemEncodedCSR(csr.Spec.Request)
+	if err != nil {
+		return err
+	}
+	subjectIDs := append([]string{}, request.DNSNames...)
+	for _, uri := range request.URIs {
+		if uri.Scheme == "spiffe" {
+			if err = authorizeSPIFFEID(csr.Spec.Username, uri.String()); err != nil {
+				return err
+			}
+		}
+		subjectIDs = append(subjectIDs, uri.String())
+	}
+	for _, ip := range request.IPAddresses {
+		subjectIDs = append(subjectIDs, ip.String())
+	}
+	if len(subjectIDs) == 0 {
+		return fmt.Errorf("the CSR requests no SAN")
+	}
+	// The SAN policy of the CA applies to the user who created the CSR, not to its approver.
+	if authorizer, ok := c.ca.(sanAuthorizer); ok {
+		if err = authorizer.AuthorizeSANs(csr.Spec.Username, subjectIDs); err != nil {
+			return err
+		}
+	}
+	chain, err := c.ca.SignWithCertChain(csr.Spec.Request, subjectIDs, c.ttl, false)
+	if err != nil {
+		return err
+	}
+
+	csr = csr.DeepCopy()
+	csr.Status.Certificate = chain
+	if _, err = c.client.CertificateSigningRequests().UpdateStatus(context.TODO(), csr, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the status: %v", err)
+	}
+	signerLog.Infof("signed CSR %s of %s for %v", csr.Name, csr.Spec.Username, subjectIDs)
+	return nil
+}
+
+// authorizeSPIFFEID returns an error unless id is the SPIFFE identity, in the trust domain or one of its
+// aliases, of the service account username is authenticated as.
+func authorizeSPIFFEID(username, id string) error {
+	identity, err := spiffe.ParseIdentity(id)
+	if err != nil {
+		return err
+	}
+	if !spiffe.IsTrustedTrustDomain(identity.TrustDomain) {
+		return fmt.Errorf("identity %s is outside of the trust domain %q and its aliases", id, spiffe.GetTrustDomain())
+	}
+	if username != serviceAccountPrefix+identity.Namespace+":"+identity.ServiceAccount {
+		return fmt.Errorf("%q cannot request the identity %s of another service account", username, id)
+	}
+	return nil
+}
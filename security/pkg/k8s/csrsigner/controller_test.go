@@ -0,0 +1,184 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csrsigner
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	certv1beta1 "k8s.io/api/certificates/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+type fakeCA struct {
+	subjectIDs [][]string
+}
+
+func (ca *fakeCA) SignWithCertChain(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error) {
+	ca.subjectIDs = append(ca.subjectIDs, subjectIDs)
+	return []byte("chain"), nil
+}
+
+func newCSR(t *testing.T, name, signerName string, conditions ...certv1beta1.RequestConditionType) *certv1beta1.CertificateSigningRequest {
+	t.Helper()
+	csrPEM, _, err := util.GenCSR(util.CertOptions{
+		Host:       "spiffe://cluster.local/ns/foo/sa/bar,bar.foo.svc",
+		RSAKeySize: 2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to generate the CSR: %v", err)
+	}
+	csr := &certv1beta1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: certv1beta1.CertificateSigningRequestSpec{
+			Request:    csrPEM,
+			SignerName: &signerName,
+			Username:   "system:serviceaccount:foo:bar",
+		},
+	}
+	for _, condition := range conditions {
+		csr.Status.Conditions = append(csr.Status.Conditions, certv1beta1.CertificateSigningRequestCondition{Type: condition})
+	}
+	return csr
+}
+
+func TestShouldSign(t *testing.T) {
+	signed := newCSR(t, "signed", "istio.io/workloads", certv1beta1.CertificateApproved)
+	signed.Status.Certificate = []byte("chain")
+	testCases := []struct {
+		csr      *certv1beta1.CertificateSigningRequest
+		expected bool
+	}{
+		{csr: newCSR(t, "approved", "istio.io/workloads", certv1beta1.CertificateApproved), expected: true},
+		{csr: newCSR(t, "pending", "istio.io/workloads")},
+		{csr: newCSR(t, "denied", "istio.io/workloads", certv1beta1.CertificateApproved, certv1beta1.CertificateDenied)},
+		{csr: newCSR(t, "other-signer", certv1beta1.KubeletServingSignerName, certv1beta1.CertificateApproved)},
+		{csr: signed},
+	}
+	for _, tc := range testCases {
+		if got := shouldSign(tc.csr); got != tc.expected {
+			t.Errorf("%s: expected %v, got %v", tc.csr.Name, tc.expected, got)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	csr := newCSR(t, "approved", "istio.io/workloads", certv1beta1.CertificateApproved)
+	client := fake.NewSimpleClientset(csr)
+	ca := &fakeCA{}
+	c := NewController(client.CertificatesV1beta1(), ca, time.Hour)
+
+	c.csrUpdated(csr)
+	expectedIDs := [][]string{{"bar.foo.svc", "spiffe://cluster.local/ns/foo/sa/bar"}}
+	if !reflect.DeepEqual(ca.subjectIDs, expectedIDs) {
+		t.Errorf("expected the CSR to be signed for %v, got %v", expectedIDs, ca.subjectIDs)
+	}
+	got, err := client.CertificatesV1beta1().CertificateSigningRequests().Get(context.TODO(), "approved", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the CSR: %v", err)
+	}
+	if string(got.Status.Certificate) != "chain" {
+		t.Errorf("expected the cert chain in the status, got %q", got.Status.Certificate)
+	}
+
+	c.csrUpdated(got)
+	if len(ca.subjectIDs) != 1 {
+		t.Errorf("expected a signed CSR not to be signed again")
+	}
+}
+
+// authorizingCA is a fakeCA enforcing a SAN policy.
+type authorizingCA struct {
+	fakeCA
+	requester string
+	sans      []string
+	err       error
+}
+
+func (ca *authorizingCA) AuthorizeSANs(requester string, sans []string) error {
+	ca.requester, ca.sans = requester, sans
+	return ca.err
+}
+
+func TestSignAuthorizesSANs(t *testing.T) {
+	csr := newCSR(t, "approved", "istio.io/workloads", certv1beta1.CertificateApproved)
+	client := fake.NewSimpleClientset(csr)
+	ca := &authorizingCA{err: fmt.Errorf("not allowed")}
+	c := NewController(client.CertificatesV1beta1(), ca, time.Hour)
+
+	c.csrUpdated(csr)
+	if len(ca.subjectIDs) != 0 {
+		t.Errorf("expected the CSR denied by the SAN policy not to be signed")
+	}
+	expectedSANs := []string{"bar.foo.svc", "spiffe://cluster.local/ns/foo/sa/bar"}
+	if ca.requester != "system:serviceaccount:foo:bar" || !reflect.DeepEqual(ca.sans, expectedSANs) {
+		t.Errorf("unexpected authorization of %v for %q", ca.sans, ca.requester)
+	}
+
+	ca.err = nil
+	c.csrUpdated(csr)
+	if len(ca.subjectIDs) != 1 {
+		t.Errorf("expected the CSR allowed by the SAN policy to be signed")
+	}
+}
+
+func TestSignRefusesOtherSPIFFEIDs(t *testing.T) {
+	for _, username := range []string{"system:serviceaccount:foo:other", "system:serviceaccount:istio-system:istiod", "alice"} {
+		csr := newCSR(t, "approved", "istio.io/workloads", certv1beta1.CertificateApproved)
+		csr.Spec.Username = username
+		client := fake.NewSimpleClientset(csr)
+		ca := &fakeCA{}
+		c := NewController(client.CertificatesV1beta1(), ca, time.Hour)
+
+		c.csrUpdated(csr)
+		if len(ca.subjectIDs) != 0 {
+			t.Errorf("expected the identity of foo/bar not to be signed for %q", username)
+		}
+	}
+}
+
+func TestSignRefusesOtherTrustDomains(t *testing.T) {
+	defer spiffe.SetTrustDomainAliases(spiffe.GetTrustDomainAliases())
+	defer spiffe.SetTrustDomain(spiffe.GetTrustDomain())
+	spiffe.SetTrustDomain("cluster.local")
+	spiffe.SetTrustDomainAliases([]string{"old.local"})
+
+	for trustDomain, expected := range map[string]bool{"cluster.local": true, "old.local": true, "example.com": false} {
+		csrPEM, _, err := util.GenCSR(util.CertOptions{
+			Host:       "spiffe://" + trustDomain + "/ns/foo/sa/bar",
+			RSAKeySize: 2048,
+		})
+		if err != nil {
+			t.Fatalf("failed to generate the CSR: %v", err)
+		}
+		csr := newCSR(t, "approved", "istio.io/workloads", certv1beta1.CertificateApproved)
+		csr.Spec.Request = csrPEM
+		client := fake.NewSimpleClientset(csr)
+		ca := &fakeCA{}
+		c := NewController(client.CertificatesV1beta1(), ca, time.Hour)
+
+		c.csrUpdated(csr)
+		if signed := len(ca.subjectIDs) == 1; signed != expected {
+			t.Errorf("trust domain %s: expected signed to be %v, got %v", trustDomain, expected, signed)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package preflight verifies at startup that the CA has the Kubernetes access and the CA bundle it
+// needs, so that misconfigurations fail fast with a clear error instead of a stream of runtime failures.
+package preflight
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// Permission is an action on a resource of the core API group in a namespace.
+type Permission struct {
+	Namespace string
+	Resource  string
+	Verb      string
+}
+
+func (p Permission) String() string {
+	return fmt.Sprintf("%s %s in namespace %s", p.Verb, p.Resource, p.Namespace)
+}
+
+// Permissions returns the permissions to perform each of the verbs on resource in each of the namespaces.
+func Permissions(resource string, verbs []string, namespaces ...string) []Permission {
+	var perms []Permission
+	for _, ns := range namespaces {
+		for _, verb := range verbs {
+			perms = append(perms, Permission{Namespace: ns, Resource: resource, Verb: verb})
+		}
+	}
+	return perms
+}
+
+// CheckPermissions verifies with self subject access reviews that the caller has all of the permissions.
+// The returned error lists all denied permissions.
+func CheckPermissions(client authorizationclient.SelfSubjectAccessReviewInterface, perms []Permission) error {
+	var errs *multierror.Error
+	for _, p := range perms {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: p.Namespace,
+					Verb:      p.Verb,
+					Resource:  p.Resource,
+				},
+			},
+		}
+		resp, err := client.Create(context.TODO(), review, metav1.CreateOptions{})
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to check the permission to %v: %v", p, err))
+			continue
+		}
+		if !resp.Status.Allowed {
+			reason := resp.Status.Reason
+			if reason == "" {
+				reason = "denied"
+			}
+			errs = multierror.Append(errs, fmt.Errorf("not allowed to %v (%s)", p, reason))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// CheckSecretAccess verifies that the secret name in namespace can be read. A missing secret is not an
+// error, since the CA creates it.
+func CheckSecretAccess(core corev1.CoreV1Interface, namespace, name string) error {
+	_, err := core.Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err == nil || errors.IsNotFound(err) {
+		return nil
+	}
+	return fmt.Errorf("cannot read secret %s in namespace %s: %v", name, namespace, err)
+}
+
+// CheckCABundle verifies that the PEM-encoded bundle holds only CA certs, at least one, that are valid at now.
+func CheckCABundle(bundle []byte, now time.Time) error {
+	var errs *multierror.Error
+	n := 0
+	for rest := bundle; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		n++
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("cert %d of the CA bundle is invalid: %v", n, err))
+			continue
+		}
+		if !cert.IsCA {
+			errs = multierror.Append(errs, fmt.Errorf("cert %d (%s) of the CA bundle is not a CA cert", n, cert.Subject))
+		}
+		if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			errs = multierror.Append(errs, fmt.Errorf("cert %d (%s) of the CA bundle is not valid at %v (valid from %v to %v)",
+				n, cert.Subject, now, cert.NotBefore, cert.NotAfter))
+		}
+	}
+	if n == 0 {
+		return fmt.Errorf("the CA bundle holds no certificate")
+	}
+	return errs.ErrorOrNil()
+}
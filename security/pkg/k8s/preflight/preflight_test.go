@@ -0,0 +1,125 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preflight
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action ktesting.Action) (bool, runtime.Object, error) {
+		review := action.(ktesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		// Only allowed to read secrets in the istio-system namespace.
+		review.Status.Allowed = attrs.Namespace == "istio-system" && (attrs.Verb == "get" || attrs.Verb == "list")
+		return true, review, nil
+	})
+	reviews := client.AuthorizationV1().SelfSubjectAccessReviews()
+
+	if err := CheckPermissions(reviews, Permissions("secrets", []string{"get", "list"}, "istio-system")); err != nil {
+		t.Errorf("unexpected error for allowed permissions: %v", err)
+	}
+	err := CheckPermissions(reviews, Permissions("secrets", []string{"get", "create"}, "istio-system", "default"))
+	if err == nil {
+		t.Fatal("expected an error for denied permissions")
+	}
+	for _, denied := range []string{
+		"create secrets in namespace istio-system", "get secrets in namespace default", "create secrets in namespace default"} {
+		if !strings.Contains(err.Error(), denied) {
+			t.Errorf("expected the error to list %q, got %v", denied, err)
+		}
+	}
+	if strings.Contains(err.Error(), "get secrets in namespace istio-system") {
+		t.Errorf("unexpected allowed permission in the error: %v", err)
+	}
+}
+
+func TestCheckSecretAccess(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "istio-ca-secret", Namespace: "istio-system"}})
+	if err := CheckSecretAccess(client.CoreV1(), "istio-system", "istio-ca-secret"); err != nil {
+		t.Errorf("unexpected error for an existing secret: %v", err)
+	}
+	if err := CheckSecretAccess(client.CoreV1(), "default", "istio-ca-secret"); err != nil {
+		t.Errorf("unexpected error for a missing secret: %v", err)
+	}
+	client.PrependReactor("get", "secrets", func(action ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "istio-ca-secret", nil)
+	})
+	if err := CheckSecretAccess(client.CoreV1(), "istio-system", "istio-ca-secret"); err == nil {
+		t.Error("expected an error for a forbidden secret")
+	}
+}
+
+func TestCheckCABundle(t *testing.T) {
+	now := time.Now()
+	genCert := func(isCA bool, notBefore time.Time) []byte {
+		cert, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+			Host:         "test.com",
+			NotBefore:    notBefore,
+			TTL:          time.Hour,
+			Org:          "Istio",
+			IsCA:         isCA,
+			IsSelfSigned: true,
+			RSAKeySize:   2048,
+		})
+		if err != nil {
+			t.Fatalf("failed to generate a cert: %v", err)
+		}
+		return cert
+	}
+	root := genCert(true, now.Add(-time.Minute))
+
+	testCases := map[string]struct {
+		bundle      []byte
+		expectedErr string
+	}{
+		"valid": {bundle: append(append([]byte{}, root...), root...)},
+		"empty": {expectedErr: "holds no certificate"},
+		"not a CA": {
+			bundle:      append(append([]byte{}, root...), genCert(false, now.Add(-time.Minute))...),
+			expectedErr: "is not a CA cert",
+		},
+		"expired": {
+			bundle:      genCert(true, now.Add(-2*time.Hour)),
+			expectedErr: "is not valid at",
+		},
+	}
+	for id, tc := range testCases {
+		err := CheckCABundle(tc.bundle, now)
+		if tc.expectedErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", id, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+			t.Errorf("%s: got error %v, want %q", id, err, tc.expectedErr)
+		}
+	}
+}
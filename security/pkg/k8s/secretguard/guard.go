@@ -0,0 +1,175 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secretguard implements a validating admission webhook protecting the secrets managed by
+// istiod: the key/cert secrets of the workloads and of the webhooks, and the CA secret. A modification
+// or deletion of these secrets by anyone else silently breaks the mTLS of the workloads mounting them,
+// or the trust in the CA, until the next resync of the controller managing them.
+package secretguard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+
+	admission "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/security/pkg/audit"
+	"istio.io/istio/security/pkg/k8s/chiron"
+	"istio.io/istio/security/pkg/k8s/controller"
+	"istio.io/istio/security/pkg/k8s/secret"
+	"istio.io/pkg/log"
+)
+
+const (
+	// WebhookPath is the path of the webhook on the HTTPS server of istiod.
+	WebhookPath = "/protect-secrets"
+
+	// auditEvent is the event of the audit entries recording the rejected requests.
+	auditEvent = "secret_protection"
+)
+
+// DefaultAllowedUsers are the Kubernetes controllers deleting the secrets along with their namespace.
+var DefaultAllowedUsers = []string{
+	"system:serviceaccount:kube-system:namespace-controller",
+	"system:serviceaccount:kube-system:generic-garbage-collector",
+}
+
+// ServiceAccountUser returns the user name Kubernetes authenticates a service account as.
+func ServiceAccountUser(namespace, name string) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name)
+}
+
+// ProtectedTypes are the types of the secrets managed by istiod.
+var ProtectedTypes = []v1.SecretType{controller.IstioSecretType, chiron.IstioDNSSecretType}
+
+// Guard rejects the modifications and deletions of the secrets managed by istiod by the users not
+// allowed to manage them.
+type Guard struct {
+	allowedUsers map[string]bool
+	// caNamespace is the namespace of the CA secret.
+	caNamespace string
+}
+
+// NewGuard creates a Guard allowing the given users, typically the service account of istiod and
+// DefaultAllowedUsers, to manage the secrets of ProtectedTypes and the CA secret in caNamespace.
+func NewGuard(allowedUsers []string, caNamespace string) *Guard {
+	g := &Guard{allowedUsers: map[string]bool{}, caNamespace: caNamespace}
+	for _, user := range allowedUsers {
+		g.allowedUsers[user] = true
+	}
+	return g
+}
+
+// protected returns true if the secret of the request is managed by istiod.
+func (g *Guard) protected(req *admission.AdmissionRequest, scrt *v1.Secret) bool {
+	if req.Namespace == g.caNamespace && req.Name == secret.CASecret {
+		return true
+	}
+	for _, t := range ProtectedTypes {
+		if scrt.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Admit decides whether the request is allowed. Only the requests modifying the type or the data of a
+// secret managed by istiod, or deleting it, are checked; updates of the metadata alone, e.g. by other
+// controllers adding annotations, are allowed.
+func (g *Guard) Admit(req *admission.AdmissionRequest) *admission.AdmissionResponse {
+	allowed := &admission.AdmissionResponse{Allowed: true}
+	if req.Kind.Kind != "Secret" || len(req.OldObject.Raw) == 0 {
+		return allowed
+	}
+	if req.Operation != admission.Update && req.Operation != admission.Delete {
+		return allowed
+	}
+	old := &v1.Secret{}
+	if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+		return &admission.AdmissionResponse{Result: &metav1.Status{Message: fmt.Sprintf("could not decode the secret: %v", err)}}
+	}
+	if !g.protected(req, old) {
+		return allowed
+	}
+	if req.Operation == admission.Update {
+		updated := &v1.Secret{}
+		if err := json.Unmarshal(req.Object.Raw, updated); err != nil {
+			return &admission.AdmissionResponse{Result: &metav1.Status{Message: fmt.Sprintf("could not decode the secret: %v", err)}}
+		}
+		if updated.Type == old.Type && reflect.DeepEqual(updated.Data, old.Data) {
+			return allowed
+		}
+	}
+	if g.allowedUsers[req.UserInfo.Username] {
+		return allowed
+	}
+
+	reason := fmt.Sprintf("secret %s/%s of type %s is managed by istiod and cannot be %s by %s",
+		req.Namespace, req.Name, old.Type, operationVerb(req.Operation), req.UserInfo.Username)
+	audit.Record(audit.Entry{
+		Event:     auditEvent,
+		Requester: req.UserInfo.Username,
+		Decision:  audit.Deny,
+		Reason:    reason,
+		Origin:    audit.Remote,
+	})
+	return &admission.AdmissionResponse{
+		Result: &metav1.Status{Message: reason, Reason: metav1.StatusReasonForbidden, Code: http.StatusForbidden},
+	}
+}
+
+func operationVerb(op admission.Operation) string {
+	if op == admission.Delete {
+		return "deleted"
+	}
+	return "modified"
+}
+
+// ServeHTTP serves the AdmissionReviews of the webhook.
+func (g *Guard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		http.Error(w, "no body found", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "invalid Content-Type, want `application/json`", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	review := admission.AdmissionReview{}
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, fmt.Sprintf("could not decode body: %v", err), http.StatusBadRequest)
+		return
+	}
+	response := admission.AdmissionReview{
+		TypeMeta: review.TypeMeta,
+		Response: g.Admit(review.Request),
+	}
+	response.Response.UID = review.Request.UID
+
+	resp, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could encode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if _, err := w.Write(resp); err != nil {
+		log.Errorf("failed to write the admission response: %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secretguard
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admission "k8s.io/api/admission/v1beta1"
+	authentication "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"istio.io/istio/security/pkg/audit"
+	"istio.io/istio/security/pkg/k8s/chiron"
+	"istio.io/istio/security/pkg/k8s/controller"
+)
+
+const istiod = "system:serviceaccount:istio-system:istiod-service-account"
+
+type recordingSink struct {
+	entries []audit.Entry
+}
+
+func (s *recordingSink) Record(e audit.Entry) {
+	s.entries = append(s.entries, e)
+}
+
+func rawSecret(t *testing.T, secretType v1.SecretType, data map[string][]byte, annotations map[string]string) runtime.RawExtension {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio.default", Namespace: "foo", Annotations: annotations},
+		Type:       secretType,
+		Data:       data,
+	}
+	raw, err := json.Marshal(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestAdmit(t *testing.T) {
+	certs := map[string][]byte{controller.CertChainID: []byte("cert")}
+	otherCerts := map[string][]byte{controller.CertChainID: []byte("other")}
+
+	testCases := map[string]struct {
+		operation admission.Operation
+		user      string
+		old       runtime.RawExtension
+		new       runtime.RawExtension
+		// name is the namespace/name of the secret, foo/istio.default if empty.
+		name    string
+		allowed bool
+	}{
+		"update of the data by another user": {
+			operation: admission.Update,
+			user:      "alice",
+			old:       rawSecret(t, controller.IstioSecretType, certs, nil),
+			new:       rawSecret(t, controller.IstioSecretType, otherCerts, nil),
+			allowed:   false,
+		},
+		"update of the type by another user": {
+			operation: admission.Update,
+			user:      "alice",
+			old:       rawSecret(t, controller.IstioSecretType, certs, nil),
+			new:       rawSecret(t, v1.SecretTypeOpaque, certs, nil),
+			allowed:   false,
+		},
+		"deletion by another user": {
+			operation: admission.Delete,
+			user:      "alice",
+			old:       rawSecret(t, controller.IstioSecretType, certs, nil),
+			allowed:   false,
+		},
+		"update of the data by the controller": {
+			operation: admission.Update,
+			user:      istiod,
+			old:       rawSecret(t, controller.IstioSecretType, certs, nil),
+			new:       rawSecret(t, controller.IstioSecretType, otherCerts, nil),
+			allowed:   true,
+		},
+		"deletion by the namespace controller": {
+			operation: admission.Delete,
+			user:      DefaultAllowedUsers[0],
+			old:       rawSecret(t, controller.IstioSecretType, certs, nil),
+			allowed:   true,
+		},
+		"update of the metadata by another user": {
+			operation: admission.Update,
+			user:      "alice",
+			old:       rawSecret(t, controller.IstioSecretType, certs, nil),
+			new:       rawSecret(t, controller.IstioSecretType, certs, map[string]string{"foo": "bar"}),
+			allowed:   true,
+		},
+		"update of a webhook secret by another user": {
+			operation: admission.Update,
+			user:      "alice",
+			old:       rawSecret(t, chiron.IstioDNSSecretType, certs, nil),
+			new:       rawSecret(t, chiron.IstioDNSSecretType, otherCerts, nil),
+			allowed:   false,
+		},
+		"deletion of the CA secret by another user": {
+			operation: admission.Delete,
+			user:      "alice",
+			old:       rawSecret(t, "istio.io/ca-root", certs, nil),
+			name:      "istio-system/istio-ca-secret",
+			allowed:   false,
+		},
+		"deletion of a secret named as the CA secret in another namespace": {
+			operation: admission.Delete,
+			user:      "alice",
+			old:       rawSecret(t, v1.SecretTypeOpaque, certs, nil),
+			name:      "foo/istio-ca-secret",
+			allowed:   true,
+		},
+		"deletion of another secret": {
+			operation: admission.Delete,
+			user:      "alice",
+			old:       rawSecret(t, v1.SecretTypeOpaque, certs, nil),
+			allowed:   true,
+		},
+		"creation": {
+			operation: admission.Create,
+			user:      "alice",
+			new:       rawSecret(t, controller.IstioSecretType, certs, nil),
+			allowed:   true,
+		},
+	}
+
+	guard := NewGuard(append([]string{istiod}, DefaultAllowedUsers...), "istio-system")
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			sink := &recordingSink{}
+			unregister := audit.RegisterSink(sink)
+			defer unregister()
+
+			namespace, secretName := "foo", "istio.default"
+			if tc.name != "" {
+				parts := strings.SplitN(tc.name, "/", 2)
+				namespace, secretName = parts[0], parts[1]
+			}
+			resp := guard.Admit(&admission.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Secret"},
+				Namespace: namespace,
+				Name:      secretName,
+				Operation: tc.operation,
+				UserInfo:  authentication.UserInfo{Username: tc.user},
+				Object:    tc.new,
+				OldObject: tc.old,
+			})
+			if resp.Allowed != tc.allowed {
+				t.Fatalf("Admit() allowed = %v, want %v (result %v)", resp.Allowed, tc.allowed, resp.Result)
+			}
+			if tc.allowed {
+				if len(sink.entries) != 0 {
+					t.Errorf("unexpected audit entries %v", sink.entries)
+				}
+				return
+			}
+			if len(sink.entries) != 1 || sink.entries[0].Decision != audit.Deny || sink.entries[0].Requester != tc.user {
+				t.Errorf("unexpected audit entries %v", sink.entries)
+			}
+			if resp.Result == nil || resp.Result.Code != http.StatusForbidden {
+				t.Errorf("unexpected result %v", resp.Result)
+			}
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	review := admission.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1beta1", Kind: "AdmissionReview"},
+		Request: &admission.AdmissionRequest{
+			UID:       "1234",
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Secret"},
+			Operation: admission.Delete,
+			UserInfo:  authentication.UserInfo{Username: "alice"},
+			OldObject: rawSecret(t, controller.IstioSecretType, nil, nil),
+		},
+	}
+	body, err := json.Marshal(review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, WebhookPath, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	NewGuard(nil, "istio-system").ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	got := admission.AdmissionReview{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Response == nil || got.Response.UID != "1234" || got.Response.Allowed {
+		t.Errorf("unexpected response %+v", got.Response)
+	}
+	if got.Kind != "AdmissionReview" {
+		t.Errorf("unexpected kind %q", got.Kind)
+	}
+}
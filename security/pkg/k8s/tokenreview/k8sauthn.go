@@ -39,25 +39,57 @@ const (
 // targetToken: the JWT of the K8s service account to be reviewed
 // jwtPolicy: the policy for validating JWT.
 func ValidateK8sJwt(kubeClient kubernetes.Interface, targetToken, jwtPolicy string) ([]string, error) {
-	tokenReview := &k8sauth.TokenReview{
-		Spec: k8sauth.TokenReviewSpec{
-			Token: targetToken,
-		},
-	}
+	var audiences []string
 	if jwtPolicy == jwt.PolicyThirdParty {
-		tokenReview.Spec.Audiences = []string{DefaultAudience}
+		audiences = []string{DefaultAudience}
 	} else if jwtPolicy != jwt.PolicyFirstParty {
 		return nil, fmt.Errorf("invalid JWT policy: %v", jwtPolicy)
 	}
+	return ValidateK8sJwtForAudiences(kubeClient, targetToken, audiences)
+}
+
+// ValidateK8sJwtForAudiences validates a k8s JWT bound to one of audiences at API server, or a token
+// for the API server if audiences is empty.
+// Return {<namespace>, <serviceaccountname>} in the targetToken when the validation passes.
+// Otherwise, return the error.
+func ValidateK8sJwtForAudiences(kubeClient kubernetes.Interface, targetToken string, audiences []string) ([]string, error) {
+	tokenReview := &k8sauth.TokenReview{
+		Spec: k8sauth.TokenReviewSpec{
+			Token:     targetToken,
+			Audiences: audiences,
+		},
+	}
 	reviewRes, err := kubeClient.AuthenticationV1().TokenReviews().Create(context.TODO(), tokenReview, metav1.CreateOptions{})
 	if err != nil {
 		return nil, err
 	}
 
-	return getTokenReviewResult(reviewRes)
+	id, err := getTokenReviewResult(reviewRes)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAudiences(reviewRes, audiences); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// checkAudiences verifies that the audiences the token was authenticated for are among the requested
+// audiences, as clients setting TokenReviewSpec.Audiences must.
+func checkAudiences(tokenReview *k8sauth.TokenReview, audiences []string) error {
+	if len(audiences) == 0 {
+		return nil
+	}
+	for _, got := range tokenReview.Status.Audiences {
+		for _, want := range audiences {
+			if got == want {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("the token is not bound to any of the audiences %v, but %v", audiences, tokenReview.Status.Audiences)
 }
 
-// TODO: add test case
 func getTokenReviewResult(tokenReview *k8sauth.TokenReview) ([]string, error) {
 	if tokenReview.Status.Error != "" {
 		return nil, fmt.Errorf("the service account authentication returns an error: %v",
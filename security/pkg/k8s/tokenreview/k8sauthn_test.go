@@ -0,0 +1,120 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tokenreview
+
+import (
+	"reflect"
+	"testing"
+
+	k8sauth "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+
+	"istio.io/istio/pkg/jwt"
+)
+
+func TestValidateK8sJwtForAudiences(t *testing.T) {
+	testCases := map[string]struct {
+		audiences       []string
+		reviewAudiences []string
+		groups          []string
+		expectedID      []string
+		expectedErr     bool
+	}{
+		"api server token": {
+			groups:     []string{"system:serviceaccounts"},
+			expectedID: []string{"default", "example-pod-sa"},
+		},
+		"bound token": {
+			audiences:       []string{"istio-ca", "other"},
+			reviewAudiences: []string{"other"},
+			groups:          []string{"system:serviceaccounts"},
+			expectedID:      []string{"default", "example-pod-sa"},
+		},
+		"token not bound to the audiences": {
+			audiences:       []string{"istio-ca"},
+			reviewAudiences: []string{"api"},
+			groups:          []string{"system:serviceaccounts"},
+			expectedErr:     true,
+		},
+		"no audience in the review": {
+			audiences:   []string{"istio-ca"},
+			groups:      []string{"system:serviceaccounts"},
+			expectedErr: true,
+		},
+		"not a service account": {
+			groups:      []string{"system:authenticated"},
+			expectedErr: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			client.PrependReactor("create", "tokenreviews", func(action ktesting.Action) (bool, runtime.Object, error) {
+				review := action.(ktesting.CreateAction).GetObject().(*k8sauth.TokenReview)
+				if !reflect.DeepEqual(review.Spec.Audiences, tc.audiences) {
+					t.Errorf("expected the review of the audiences %v, got %v", tc.audiences, review.Spec.Audiences)
+				}
+				review.Status = k8sauth.TokenReviewStatus{
+					Authenticated: true,
+					Audiences:     tc.reviewAudiences,
+					User: k8sauth.UserInfo{
+						Username: "system:serviceaccount:default:example-pod-sa",
+						Groups:   tc.groups,
+					},
+				}
+				return true, review, nil
+			})
+			id, err := ValidateK8sJwtForAudiences(client, "token", tc.audiences)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(id, tc.expectedID) {
+				t.Errorf("expected %v, got %v", tc.expectedID, id)
+			}
+		})
+	}
+}
+
+func TestValidateK8sJwtPolicy(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action ktesting.Action) (bool, runtime.Object, error) {
+		review := action.(ktesting.CreateAction).GetObject().(*k8sauth.TokenReview)
+		review.Status = k8sauth.TokenReviewStatus{
+			Authenticated: true,
+			Audiences:     review.Spec.Audiences,
+			User: k8sauth.UserInfo{
+				Username: "system:serviceaccount:default:example-pod-sa",
+				Groups:   []string{"system:serviceaccounts"},
+			},
+		}
+		return true, review, nil
+	})
+	for _, policy := range []string{jwt.PolicyFirstParty, jwt.PolicyThirdParty} {
+		if _, err := ValidateK8sJwt(client, "token", policy); err != nil {
+			t.Errorf("unexpected error with the JWT policy %q: %v", policy, err)
+		}
+	}
+	if _, err := ValidateK8sJwt(client, "token", "unknown"); err == nil {
+		t.Error("expected an error with an invalid JWT policy")
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trustanchor replicates the root certs of the CA of a cluster into other clusters, so
+// that workloads across clusters with their own CAs keep trusting each other when a root rotates.
+//
+// Each cluster writes its root certs, including the combined bundle during a root rotation, to
+// the istio-trust-anchors ConfigMap of every remote cluster under the key <clusterID>.pem. Each
+// cluster distributes the root certs of its peers found in its own ConfigMap to its workloads.
+package trustanchor
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/pkg/log"
+)
+
+const (
+	// ConfigMapName is the name of the ConfigMap in the Istio namespace holding the root certs
+	// replicated from other clusters.
+	ConfigMapName = "istio-trust-anchors"
+
+	keySuffix = ".pem"
+)
+
+var anchorLog = log.RegisterScope("trustanchor", "Trust anchor replication log", 0)
+
+// Replicator writes the root certs of the local CA to the remote clusters.
+type Replicator struct {
+	clusterID string
+	namespace string
+	rootCerts func() []byte
+
+	mutex   sync.Mutex
+	clients map[string]corev1.CoreV1Interface
+	// pushed holds the root certs last written to each remote cluster.
+	pushed map[string][]byte
+}
+
+// NewReplicator creates a Replicator of the root certs returned by rootCerts, written to the
+// ConfigMap in namespace of the remote clusters under the key of clusterID.
+func NewReplicator(clusterID, namespace string, rootCerts func() []byte) *Replicator {
+	return &Replicator{
+		clusterID: clusterID,
+		namespace: namespace,
+		rootCerts: rootCerts,
+		clients:   map[string]corev1.CoreV1Interface{},
+		pushed:    map[string][]byte{},
+	}
+}
+
+// ClusterAdded starts replicating the root certs to a remote cluster.
+func (r *Replicator) ClusterAdded(clusterID string, client kubernetes.Interface) {
+	r.mutex.Lock()
+	r.clients[clusterID] = client.CoreV1()
+	delete(r.pushed, clusterID)
+	r.mutex.Unlock()
+	go r.Sync()
+}
+
+// ClusterDeleted stops replicating the root certs to a remote cluster. The root certs already
+// written are kept, since workloads of the remote cluster may still connect to this cluster.
+func (r *Replicator) ClusterDeleted(clusterID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.clients, clusterID)
+	delete(r.pushed, clusterID)
+}
+
+// Run syncs the remote clusters every interval until stopCh is closed.
+func (r *Replicator) Run(stopCh <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			r.Sync()
+		}
+	}
+}
+
+// Sync writes the current root certs to the remote clusters not holding them yet.
+func (r *Replicator) Sync() {
+	rootCerts := r.rootCerts()
+	if len(rootCerts) == 0 {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for clusterID, client := range r.clients {
+		if bytes.Equal(r.pushed[clusterID], rootCerts) {
+			continue
+		}
+		if err := write(client, r.namespace, r.clusterID+keySuffix, string(rootCerts)); err != nil {
+			anchorLog.Errorf("failed to replicate the root certs to cluster %s: %v", clusterID, err)
+			continue
+		}
+		r.pushed[clusterID] = rootCerts
+		anchorLog.Infof("replicated the root certs to cluster %s", clusterID)
+	}
+}
+
+// write sets key to value in the ConfigMap, creating it if needed.
+func write(client corev1.CoreV1Interface, namespace, key, value string) error {
+	cm, err := client.ConfigMaps(namespace).Get(context.TODO(), ConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: namespace},
+			Data:       map[string]string{key: value},
+		}
+		_, err = client.ConfigMaps(namespace).Create(context.TODO(), cm, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[key] = value
+	_, err = client.ConfigMaps(namespace).Update(context.TODO(), cm, metav1.UpdateOptions{})
+	return err
+}
+
+// Peers caches the root certs replicated into the local cluster by other clusters.
+type Peers struct {
+	client    corev1.CoreV1Interface
+	namespace string
+	clusterID string
+
+	mutex     sync.RWMutex
+	rootCerts []byte
+}
+
+// NewPeers creates a cache of the root certs in the ConfigMap in namespace, ignoring the ones
+// written under the key of the local clusterID.
+func NewPeers(client corev1.CoreV1Interface, namespace, clusterID string) *Peers {
+	return &Peers{client: client, namespace: namespace, clusterID: clusterID}
+}
+
+// Run reloads the root certs every interval until stopCh is closed.
+func (p *Peers) Run(stopCh <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := p.Load(); err != nil {
+			anchorLog.Errorf("failed to load the root certs of the peer clusters: %v", err)
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Load reads the root certs of the peer clusters. Invalid root certs are skipped.
+func (p *Peers) Load() error {
+	cm, err := p.client.ConfigMaps(p.namespace).Get(context.TODO(), ConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		p.set(nil)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for key := range cm.Data {
+		if strings.HasSuffix(key, keySuffix) && key != p.clusterID+keySuffix {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var rootCerts []byte
+	for _, key := range keys {
+		value := []byte(cm.Data[key])
+		if err := verifyRootCerts(value); err != nil {
+			anchorLog.Warnf("skipping the invalid root certs of cluster %s: %v", strings.TrimSuffix(key, keySuffix), err)
+			continue
+		}
+		rootCerts = append(rootCerts, bytes.TrimSpace(value)...)
+		rootCerts = append(rootCerts, '\n')
+	}
+	p.set(rootCerts)
+	return nil
+}
+
+// RootCerts returns the root certs of the peer clusters.
+func (p *Peers) RootCerts() []byte {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.rootCerts
+}
+
+func (p *Peers) set(rootCerts []byte) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !bytes.Equal(p.rootCerts, rootCerts) {
+		anchorLog.Infof("loaded %d bytes of root certs of the peer clusters", len(rootCerts))
+	}
+	p.rootCerts = rootCerts
+}
+
+// verifyRootCerts checks that certsPEM holds one or more PEM-encoded CA certs.
+func verifyRootCerts(certsPEM []byte) error {
+	n := 0
+	for block, rest := pem.Decode(certsPEM); block != nil; block, rest = pem.Decode(rest) {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return err
+		}
+		if !cert.IsCA {
+			return fmt.Errorf("cert %s is not a CA cert", cert.Subject)
+		}
+		n++
+	}
+	if n == 0 {
+		return fmt.Errorf("no PEM-encoded cert found")
+	}
+	return nil
+}
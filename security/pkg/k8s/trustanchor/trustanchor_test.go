@@ -0,0 +1,117 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trustanchor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func genRootCert(t *testing.T, org string) string {
+	t.Helper()
+	certPEM, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL: time.Hour, Org: org, IsCA: true, IsSelfSigned: true, RSAKeySize: 2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to create a root cert: %v", err)
+	}
+	return string(certPEM)
+}
+
+func getAnchors(t *testing.T, client *fake.Clientset) map[string]string {
+	t.Helper()
+	cm, err := client.CoreV1().ConfigMaps("istio-system").Get(context.TODO(), ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the trust anchors: %v", err)
+	}
+	return cm.Data
+}
+
+func TestReplicator(t *testing.T) {
+	rootCerts := genRootCert(t, "primary")
+	r := NewReplicator("primary", "istio-system", func() []byte { return []byte(rootCerts) })
+
+	remote1 := fake.NewSimpleClientset()
+	remote2 := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: "istio-system"},
+		Data:       map[string]string{"other.pem": "other"},
+	})
+	r.mutex.Lock()
+	r.clients["remote1"] = remote1.CoreV1()
+	r.clients["remote2"] = remote2.CoreV1()
+	r.mutex.Unlock()
+
+	r.Sync()
+	if anchors := getAnchors(t, remote1); anchors["primary.pem"] != rootCerts {
+		t.Errorf("unexpected trust anchors of remote1 %v", anchors)
+	}
+	if anchors := getAnchors(t, remote2); anchors["primary.pem"] != rootCerts || anchors["other.pem"] != "other" {
+		t.Errorf("unexpected trust anchors of remote2 %v", anchors)
+	}
+
+	// Unchanged root certs are not written again.
+	actions := len(remote1.Actions())
+	r.Sync()
+	if len(remote1.Actions()) != actions {
+		t.Errorf("expected unchanged root certs not to be written again")
+	}
+
+	// The combined bundle is replicated during a rotation.
+	rootCerts += genRootCert(t, "primary-new")
+	r.ClusterDeleted("remote2")
+	r.Sync()
+	if anchors := getAnchors(t, remote1); anchors["primary.pem"] != rootCerts {
+		t.Errorf("expected the combined bundle to be replicated, got %v", anchors)
+	}
+	if anchors := getAnchors(t, remote2); anchors["primary.pem"] == rootCerts {
+		t.Errorf("expected a deleted cluster not to be synced")
+	}
+}
+
+func TestPeers(t *testing.T) {
+	remoteRoot := genRootCert(t, "remote")
+	client := fake.NewSimpleClientset()
+	p := NewPeers(client.CoreV1(), "istio-system", "primary")
+	if err := p.Load(); err != nil || len(p.RootCerts()) != 0 {
+		t.Fatalf("expected no root certs without the ConfigMap, got %q, %v", p.RootCerts(), err)
+	}
+
+	_, err := client.CoreV1().ConfigMaps("istio-system").Create(context.TODO(), &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: "istio-system"},
+		Data: map[string]string{
+			"primary.pem": genRootCert(t, "primary"),
+			"remote.pem":  remoteRoot,
+			"broken.pem":  "invalid",
+			"notes":       "ignored",
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Load(); err != nil {
+		t.Fatalf("failed to load the root certs: %v", err)
+	}
+	if got := string(p.RootCerts()); got != strings.TrimSpace(remoteRoot)+"\n" {
+		t.Errorf("expected only the root cert of the remote cluster, got %q", got)
+	}
+}
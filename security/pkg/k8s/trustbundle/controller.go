@@ -0,0 +1,284 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trustbundle watches the TrustBundle custom resources, through which operators add trust
+// anchors, such as the roots of partner meshes or of a legacy PKI, to the root certs distributed to
+// the workloads.
+package trustbundle
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+
+	"istio.io/istio/security/pkg/notify"
+	"istio.io/pkg/log"
+)
+
+// Kind is the kind of the TrustBundle custom resource.
+const Kind = "TrustBundle"
+
+// GroupVersionResource of the cluster scoped TrustBundle custom resource.
+var GroupVersionResource = schema.GroupVersionResource{
+	Group:    "security.istio.io",
+	Version:  "v1alpha1",
+	Resource: "trustbundles",
+}
+
+var trustBundleLog = log.RegisterScope("trustbundle", "Trust bundle controller log", 0)
+
+// Spec is the spec of a TrustBundle resource.
+type Spec struct {
+	// RootCerts are the PEM encoded CA certs trusted in addition to the roots of the mesh.
+	RootCerts string `json:"rootCerts"`
+}
+
+// Status is the status of a TrustBundle resource.
+type Status struct {
+	// ObservedGeneration is the generation of the spec last processed.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Certificates is the number of certs distributed to the workloads.
+	Certificates int `json:"certificates,omitempty"`
+	// Error is the reason the bundle is rejected, if any.
+	Error string `json:"error,omitempty"`
+	// Warnings are about expired certs, which are not distributed, and certs about to expire.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Validate parses the PEM encoded rootCerts. It returns the certs valid at now, and warnings about
+// the certs expired or expiring within expiryWarning of now. All the PEM blocks must be CA certs.
+func Validate(rootCerts []byte, now time.Time, expiryWarning time.Duration) ([]*x509.Certificate, []string, error) {
+	var certs []*x509.Certificate
+	var warnings []string
+	found := false
+	for rest := rootCerts; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			if len(bytes.TrimSpace(rest)) > 0 {
+				return nil, nil, fmt.Errorf("invalid PEM data")
+			}
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, nil, fmt.Errorf("unexpected PEM block %q, only certificates are allowed", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse a certificate: %v", err)
+		}
+		if !cert.IsCA {
+			return nil, nil, fmt.Errorf("certificate %q is not a CA certificate", cert.Subject.String())
+		}
+		found = true
+		expiry := cert.NotAfter.UTC().Format(time.RFC3339)
+		switch {
+		case now.After(cert.NotAfter):
+			warnings = append(warnings, fmt.Sprintf("certificate %q expired at %s and is ignored", cert.Subject.String(), expiry))
+			continue
+		case cert.NotAfter.Sub(now) < expiryWarning:
+			warnings = append(warnings, fmt.Sprintf("certificate %q expires at %s", cert.Subject.String(), expiry))
+		}
+		certs = append(certs, cert)
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("no certificates")
+	}
+	return certs, warnings, nil
+}
+
+// Controller watches the TrustBundle resources, and keeps the certs of the valid ones. The outcome
+// of the validation is recorded in the status of each resource.
+type Controller struct {
+	client        dynamic.NamespaceableResourceInterface
+	expiryWarning time.Duration
+	informer      cache.Controller
+	now           func() time.Time
+
+	mutex   sync.RWMutex
+	bundles map[string][]byte
+	// warned holds the fingerprints of the certs whose expiry was notified.
+	warned map[string]bool
+}
+
+// NewController returns a new Controller of the TrustBundle resources, warning about the certs
+// expiring within expiryWarning. The resources are revalidated every resync.
+func NewController(client dynamic.Interface, expiryWarning, resync time.Duration) *Controller {
+	c := &Controller{
+		client:        client.Resource(GroupVersionResource),
+		expiryWarning: expiryWarning,
+		now:           time.Now,
+		bundles:       map[string][]byte{},
+		warned:        map[string]bool{},
+	}
+	_, c.informer = cache.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return c.client.List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return c.client.Watch(context.TODO(), options)
+			},
+		},
+		&unstructured.Unstructured{},
+		resync,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: c.bundleChanged,
+			UpdateFunc: func(_, newObj interface{}) {
+				c.bundleChanged(newObj)
+			},
+			DeleteFunc: c.bundleDeleted,
+		})
+	return c
+}
+
+// Run watches the TrustBundle resources until stop is closed.
+func (c *Controller) Run(stop <-chan struct{}) {
+	c.informer.Run(stop)
+}
+
+// HasSynced returns true once the TrustBundle resources were initially listed.
+func (c *Controller) HasSynced() bool {
+	return c.informer.HasSynced()
+}
+
+// RootCerts returns the PEM encoded certs of the valid TrustBundle resources, ordered by name.
+func (c *Controller) RootCerts() []byte {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	names := make([]string, 0, len(c.bundles))
+	for name := range c.bundles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var rootCerts []byte
+	for _, name := range names {
+		rootCerts = append(rootCerts, c.bundles[name]...)
+	}
+	return rootCerts
+}
+
+func (c *Controller) bundleChanged(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	name := u.GetName()
+	status := Status{ObservedGeneration: u.GetGeneration()}
+	certs, warnings, err := c.validate(u)
+	c.mutex.Lock()
+	if err != nil {
+		// A previously accepted version of the bundle is kept, so that a bad edit does not break
+		// the connectivity with the peers it anchors.
+		trustBundleLog.Errorf("rejecting %s %s: %v", Kind, name, err)
+		status.Error = err.Error()
+	} else {
+		var pemCerts []byte
+		for _, cert := range certs {
+			pemCerts = append(pemCerts, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+		}
+		c.bundles[name] = pemCerts
+		status.Certificates = len(certs)
+		status.Warnings = warnings
+	}
+	c.mutex.Unlock()
+	if err == nil {
+		c.warnExpiring(name, certs)
+	}
+	if err := c.writeStatus(u, &status); err != nil {
+		trustBundleLog.Warnf("failed to update the status of %s %s: %v", Kind, name, err)
+	}
+}
+
+func (c *Controller) bundleDeleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.bundles, u.GetName())
+	trustBundleLog.Infof("%s %s is deleted", Kind, u.GetName())
+}
+
+func (c *Controller) validate(obj *unstructured.Unstructured) ([]*x509.Certificate, []string, error) {
+	spec := &Spec{}
+	m, found, err := unstructured.NestedMap(obj.Object, "spec")
+	if err != nil || !found {
+		return nil, nil, fmt.Errorf("missing spec")
+	}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(m, spec); err != nil {
+		return nil, nil, fmt.Errorf("invalid spec: %v", err)
+	}
+	return Validate([]byte(spec.RootCerts), c.now(), c.expiryWarning)
+}
+
+// warnExpiring sends an alert for each cert of the bundle name expiring within expiryWarning, once.
+func (c *Controller) warnExpiring(name string, certs []*x509.Certificate) {
+	now := c.now()
+	for _, cert := range certs {
+		if cert.NotAfter.Sub(now) >= c.expiryWarning {
+			continue
+		}
+		sum := sha256.Sum256(cert.Raw)
+		fingerprint := hex.EncodeToString(sum[:])
+		c.mutex.Lock()
+		warned := c.warned[fingerprint]
+		c.warned[fingerprint] = true
+		c.mutex.Unlock()
+		if !warned {
+			trustBundleLog.Warnf("certificate %q of %s %s expires at %s", cert.Subject.String(), Kind, name, cert.NotAfter)
+			notify.Send(notify.TrustAnchorExpiring, "the trust anchor %q of %s %s expires at %s",
+				cert.Subject.String(), Kind, name, cert.NotAfter.UTC().Format(time.RFC3339))
+		}
+	}
+}
+
+// writeStatus updates the status of obj, unless it is unchanged.
+func (c *Controller) writeStatus(obj *unstructured.Unstructured, status *Status) error {
+	current := &Status{}
+	if m, found, _ := unstructured.NestedMap(obj.Object, "status"); found {
+		_ = runtime.DefaultUnstructuredConverter.FromUnstructured(m, current)
+	}
+	if reflect.DeepEqual(current, status) {
+		return nil
+	}
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(status)
+	if err != nil {
+		return err
+	}
+	updated := obj.DeepCopy()
+	updated.Object["status"] = m
+	_, err = c.client.UpdateStatus(context.TODO(), updated, metav1.UpdateOptions{})
+	return err
+}
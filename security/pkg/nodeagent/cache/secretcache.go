@@ -18,6 +18,8 @@ package cache
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -128,8 +130,18 @@ type Options struct {
 	OutputKeyCertToDir string
 
 	// The type of Elliptical Signature algorithm to use
-	// when generating private keys. Currently only ECDSA is supported.
+	// when generating private keys: ECDSA (P256), ECDSA-P384 or ECDSA-P521.
 	ECCSigAlg string
+
+	// JWTPath is the file of a projected token. When the token of a secret expires, the file is
+	// re-read to rotate the secret with the rotated token, instead of closing the stream to the proxy.
+	JWTPath string
+
+	// RootCertFile is the file of the trust bundle, e.g. the mounted istio-ca-root-cert ConfigMap.
+	// When set, the ROOTCA resource is served from this file and versioned independently of the
+	// workload certificate: the root in CSR responses is ignored and a change of the file is pushed
+	// to proxies without re-issuing their key/cert.
+	RootCertFile string
 }
 
 // SecretManager defines secrets management interface which is used by SDS.
@@ -291,6 +303,10 @@ func (sc *SecretCache) GenerateSecret(ctx context.Context, connectionID, resourc
 		return ns, nil
 	}
 
+	if sc.configOptions.RootCertFile != "" {
+		sc.reloadRootCertFile()
+	}
+
 	// If request is for root certificate,
 	// retry since rootCert may be empty until there is CSR response returned from CA.
 	rootCert, rootCertExpr := sc.getRootCert()
@@ -320,7 +336,7 @@ func (sc *SecretCache) GenerateSecret(ctx context.Context, connectionID, resourc
 		ExpireTime:   rootCertExpr,
 		Token:        token,
 		CreatedTime:  t,
-		Version:      t.String(),
+		Version:      rootCertVersion(rootCert),
 	}
 	cacheLog.Infoa("Loaded root cert from certificate ", resourceName)
 	sc.secrets.Store(connKey, *ns)
@@ -485,6 +501,9 @@ func (sc *SecretCache) keyCertRotationJob() {
 	for {
 		select {
 		case <-sc.rotationTicker.C:
+			if sc.configOptions.RootCertFile != "" {
+				sc.reloadRootCertFile()
+			}
 			sc.rotate(false /*updateRootFlag*/)
 		case <-sc.closing:
 			if sc.rotationTicker != nil {
@@ -601,7 +620,7 @@ func (sc *SecretCache) rotate(updateRootFlag bool) {
 				ExpireTime:   rootCertExpr,
 				Token:        secret.Token,
 				CreatedTime:  now,
-				Version:      now.String(),
+				Version:      rootCertVersion(rootCert),
 			}
 			secretMap.Store(connKey, ns)
 			cacheLog.Debugf("%s secret cache is updated", logPrefix)
@@ -627,10 +646,8 @@ func (sc *SecretCache) rotate(updateRootFlag bool) {
 		if sc.shouldRotate(&secret) {
 			atomic.AddUint64(&sc.secretChangedCount, 1)
 			// Send the notification to close the stream if token is expired, so that client could re-connect with a new token.
-			if sc.isTokenExpired(&secret) {
+			if sc.isTokenExpired(&secret) && !sc.reloadToken(&secret) {
 				cacheLog.Debugf("%s token expired", logPrefix)
-				// TODO(myidpt): Optimization needed. When using local JWT, server should directly push the new secret instead of
-				// requiring the client to send another SDS request.
 				sc.callbackWithTimeout(connKey, nil /*nil indicates close the streaming connection to proxy*/)
 				return true
 			}
@@ -927,23 +944,26 @@ func (sc *SecretCache) generateSecret(ctx context.Context, token string, connKey
 		return nil, fmt.Errorf("failed to extract expire time from server certificate in CSR response: %v", err)
 	}
 
-	length := len(certChainPEM)
-	rootCert, _ := sc.getRootCert()
-	// Leaf cert is element '0'. Root cert is element 'n'.
-	rootCertChanged := !bytes.Equal(rootCert, []byte(certChainPEM[length-1]))
-	if rootCert == nil || rootCertChanged {
-		rootCertExpireTime, err := nodeagentutil.ParseCertAndGetExpiryTimestamp([]byte(certChainPEM[length-1]))
-		if err == nil {
-			sc.setRootCert([]byte(certChainPEM[length-1]), rootCertExpireTime)
-		} else {
-			cacheLog.Errorf("%s failed to parse root certificate in CSR response: %v", logPrefix, err)
-			rootCertChanged = false
+	// The root in the CSR response is ignored when the trust bundle is served from a file.
+	if sc.configOptions.RootCertFile == "" {
+		length := len(certChainPEM)
+		rootCert, _ := sc.getRootCert()
+		// Leaf cert is element '0'. Root cert is element 'n'.
+		rootCertChanged := !bytes.Equal(rootCert, []byte(certChainPEM[length-1]))
+		if rootCert == nil || rootCertChanged {
+			rootCertExpireTime, err := nodeagentutil.ParseCertAndGetExpiryTimestamp([]byte(certChainPEM[length-1]))
+			if err == nil {
+				sc.setRootCert([]byte(certChainPEM[length-1]), rootCertExpireTime)
+			} else {
+				cacheLog.Errorf("%s failed to parse root certificate in CSR response: %v", logPrefix, err)
+				rootCertChanged = false
+			}
 		}
-	}
 
-	if rootCertChanged {
-		cacheLog.Info("Root cert has changed, start rotating root cert for SDS clients")
-		sc.rotate(true /*updateRootFlag*/)
+		if rootCertChanged {
+			cacheLog.Info("Root cert has changed, start rotating root cert for SDS clients")
+			sc.rotate(true /*updateRootFlag*/)
+		}
 	}
 
 	return &model.SecretItem{
@@ -981,6 +1001,55 @@ func (sc *SecretCache) isTokenExpired(secret *model.SecretItem) bool {
 	return expired
 }
 
+// reloadToken replaces the expired token of secret with the token in the projected token file, which
+// the kubelet rotates. It returns false if the file has no valid token.
+func (sc *SecretCache) reloadToken(secret *model.SecretItem) bool {
+	if sc.configOptions.JWTPath == "" {
+		return false
+	}
+	tok, err := ioutil.ReadFile(sc.configOptions.JWTPath)
+	if err != nil {
+		cacheLog.Errorf("failed to reload the token from %s: %v", sc.configOptions.JWTPath, err)
+		return false
+	}
+	token := strings.TrimSpace(string(tok))
+	if expired, err := util.IsJwtExpired(token, time.Now()); err != nil || expired {
+		cacheLog.Warnf("the token in %s is not rotated yet", sc.configOptions.JWTPath)
+		return false
+	}
+	cacheLog.Debugf("reloaded the rotated token from %s", sc.configOptions.JWTPath)
+	secret.Token = token
+	return true
+}
+
+// reloadRootCertFile re-reads the trust bundle from configOptions.RootCertFile and, when it has
+// changed, caches it and pushes the new ROOTCA resource to the SDS clients.
+func (sc *SecretCache) reloadRootCertFile() {
+	rootCert, err := ioutil.ReadFile(sc.configOptions.RootCertFile)
+	if err != nil {
+		cacheLog.Errorf("failed to read the root cert from %s: %v", sc.configOptions.RootCertFile, err)
+		return
+	}
+	if cached, _ := sc.getRootCert(); bytes.Equal(cached, rootCert) {
+		return
+	}
+	rootCertExpireTime, err := nodeagentutil.ParseCertAndGetExpiryTimestamp(rootCert)
+	if err != nil {
+		cacheLog.Errorf("failed to parse the root cert in %s: %v", sc.configOptions.RootCertFile, err)
+		return
+	}
+	sc.setRootCert(rootCert, rootCertExpireTime)
+	cacheLog.Infof("Root cert in %s has changed, start rotating root cert for SDS clients", sc.configOptions.RootCertFile)
+	sc.rotate(true /*updateRootFlag*/)
+}
+
+// rootCertVersion returns the version of the ROOTCA resource, which is derived from the content
+// of the root cert so that it only changes when the trust bundle does.
+func rootCertVersion(rootCert []byte) string {
+	sum := sha256.Sum256(rootCert)
+	return hex.EncodeToString(sum[:8])
+}
+
 // sendRetriableRequest sends retriable requests for either CSR or ExchangeToken.
 // Prior to sending the request, it also sleep random millisecond to avoid thundering herd problem.
 func (sc *SecretCache) sendRetriableRequest(ctx context.Context, csrPEM []byte,
@@ -17,6 +17,7 @@ package cache
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -322,6 +323,96 @@ func TestWorkloadAgentRefreshSecret(t *testing.T) {
 	}
 }
 
+// TestWorkloadAgentRootCertFromFile verifies that the ROOTCA resource is served from the root cert
+// file with a content based version, and that a change of the file is pushed without a CSR.
+func TestWorkloadAgentRootCertFromFile(t *testing.T) {
+	fakeCACli, err := mock.NewMockCAClient(0, time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating Mock CA client: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "root-cert-file")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	rootCertFile := filepath.Join(dir, "root-cert.pem")
+	rootCert, err := ioutil.ReadFile("./testdata/root-cert.pem")
+	if err != nil {
+		t.Fatalf("failed to read root cert: %v", err)
+	}
+	if err := ioutil.WriteFile(rootCertFile, rootCert, 0644); err != nil {
+		t.Fatalf("failed to write root cert: %v", err)
+	}
+
+	var mu sync.Mutex
+	var pushed []*model.SecretItem
+	cb := func(_ ConnKey, secret *model.SecretItem) error {
+		mu.Lock()
+		defer mu.Unlock()
+		pushed = append(pushed, secret)
+		return nil
+	}
+	opt := Options{
+		RotationInterval: time.Hour,
+		EvictionDuration: 0,
+		RootCertFile:     rootCertFile,
+	}
+	fetcher := &secretfetcher.SecretFetcher{
+		UseCaClient: true,
+		CaClient:    fakeCACli,
+	}
+	sc := NewSecretCache(fetcher, cb, opt)
+	defer sc.Close()
+
+	conID := "proxy1-id"
+	ctx := context.Background()
+	if _, err := sc.GenerateSecret(ctx, conID, WorkloadKeyCertResourceName, "jwtToken1"); err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+	gotSecretRoot, err := sc.GenerateSecret(ctx, conID, RootCertReqResourceName, "jwtToken1")
+	if err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+	// The root in the CSR response does not replace the one from the file.
+	if !bytes.Equal(gotSecretRoot.RootCert, rootCert) {
+		t.Errorf("Got unexpected root certificate. Got: %v\n want: %v", string(gotSecretRoot.RootCert), string(rootCert))
+	}
+	if got, want := gotSecretRoot.Version, rootCertVersion(rootCert); got != want {
+		t.Errorf("Got unexpected root cert version. Got: %v, want: %v", got, want)
+	}
+
+	// Reloading an unchanged file does not push anything.
+	sc.reloadRootCertFile()
+	if len(pushed) != 0 {
+		t.Errorf("Got %d unexpected pushes for an unchanged root cert", len(pushed))
+	}
+
+	newRootCert, err := ioutil.ReadFile("../../pki/testdata/ec-root-cert.pem")
+	if err != nil {
+		t.Fatalf("failed to read root cert: %v", err)
+	}
+	if err := ioutil.WriteFile(rootCertFile, newRootCert, 0644); err != nil {
+		t.Fatalf("failed to write root cert: %v", err)
+	}
+	signCount := atomic.LoadUint64(&fakeCACli.SignInvokeCount)
+	sc.reloadRootCertFile()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(pushed) != 1 {
+		t.Fatalf("Got %d pushes, want 1", len(pushed))
+	}
+	if got := pushed[0]; got.ResourceName != RootCertReqResourceName || !bytes.Equal(got.RootCert, newRootCert) {
+		t.Errorf("Got unexpected push for %q: %v", got.ResourceName, string(got.RootCert))
+	}
+	if got, want := pushed[0].Version, rootCertVersion(newRootCert); got != want || got == gotSecretRoot.Version {
+		t.Errorf("Got unexpected root cert version after rotation. Got: %v, want: %v", got, want)
+	}
+	if got := atomic.LoadUint64(&fakeCACli.SignInvokeCount); got != signCount {
+		t.Errorf("Root cert rotation sent %d unexpected CSRs", got-signCount)
+	}
+}
+
 // TestGatewayAgentGenerateSecret verifies that ingress gateway agent manages secret cache correctly.
 func TestGatewayAgentGenerateSecret(t *testing.T) {
 	sc := createSecretCache()
@@ -812,6 +903,38 @@ func TestSetAlwaysValidTokenFlag(t *testing.T) {
 	checkBool(t, "isTokenExpired", sc.isTokenExpired(&secret), false)
 }
 
+func TestReloadToken(t *testing.T) {
+	sc := createSecretCache()
+	defer sc.Close()
+	jwt := func(exp time.Time) string {
+		claims := fmt.Sprintf(`{"iss":"https://kubernetes.default.svc","aud":["istio-ca"],"exp":%d}`, exp.Unix())
+		return "e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".c2ln"
+	}
+	expired := jwt(time.Now().Add(-time.Minute))
+	secret := model.SecretItem{Token: expired}
+	checkBool(t, "reloadToken without a token file", sc.reloadToken(&secret), false)
+
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sc.configOptions.JWTPath = filepath.Join(dir, "istio-token")
+	if err := ioutil.WriteFile(sc.configOptions.JWTPath, []byte(expired), 0600); err != nil {
+		t.Fatal(err)
+	}
+	checkBool(t, "reloadToken with an expired token", sc.reloadToken(&secret), false)
+
+	rotated := jwt(time.Now().Add(time.Hour))
+	if err := ioutil.WriteFile(sc.configOptions.JWTPath, []byte(rotated+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	checkBool(t, "reloadToken with a rotated token", sc.reloadToken(&secret), true)
+	if secret.Token != rotated {
+		t.Errorf("expected the secret to hold the rotated token, got %q", secret.Token)
+	}
+}
+
 func TestRootCertificateExists(t *testing.T) {
 	testCases := map[string]struct {
 		certPath     string
@@ -0,0 +1,192 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	k8scache "k8s.io/client-go/tools/cache"
+
+	secutil "istio.io/istio/security/pkg/util"
+)
+
+const podUIDIndex = "uid"
+
+// podUIDRegexp matches the pod UID in the cgroup paths of a container, e.g.
+// /kubepods/burstable/pod<uid>/<container> or kubepods-burstable-pod<uid>.slice with the systemd driver.
+var podUIDRegexp = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+
+// WorkloadAttestor verifies that the process calling the SDS server over UDS runs as the
+// Kubernetes service account it presents a token for.
+type WorkloadAttestor interface {
+	// Attest returns an error if the process with the given pid does not run in a pod of the given
+	// namespace and service account.
+	Attest(pid int32, namespace, serviceAccount string) error
+}
+
+// PodAttestor attests UDS callers by resolving the pod of the calling process from its cgroup,
+// and comparing the service account of the pod with the claimed one.
+type PodAttestor struct {
+	informer k8scache.SharedIndexInformer
+
+	// procRoot is the mount point of the proc file system.
+	procRoot string
+}
+
+// NewPodAttestor creates a PodAttestor watching the pods scheduled on the given node.
+func NewPodAttestor(client kubernetes.Interface, nodeName string, stop <-chan struct{}) *PodAttestor {
+	fieldSelector := "spec.nodeName=" + nodeName
+	informer := k8scache.NewSharedIndexInformer(
+		&k8scache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				opts.FieldSelector = fieldSelector
+				return client.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), opts)
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				opts.FieldSelector = fieldSelector
+				return client.CoreV1().Pods(metav1.NamespaceAll).Watch(context.TODO(), opts)
+			},
+		},
+		&v1.Pod{}, 0, k8scache.Indexers{podUIDIndex: func(obj interface{}) ([]string, error) {
+			return []string{string(obj.(*v1.Pod).UID)}, nil
+		}},
+	)
+	go informer.Run(stop)
+
+	return &PodAttestor{
+		informer: informer,
+		procRoot: "/proc",
+	}
+}
+
+// Attest implements WorkloadAttestor.
+func (a *PodAttestor) Attest(pid int32, namespace, serviceAccount string) error {
+	if !a.informer.HasSynced() {
+		return errors.New("the pods on this node are not synced yet")
+	}
+	cgroup, err := ioutil.ReadFile(filepath.Join(a.procRoot, strconv.Itoa(int(pid)), "cgroup"))
+	if err != nil {
+		return fmt.Errorf("failed to read the cgroup of process %d: %v", pid, err)
+	}
+	uid, err := podUIDFromCgroup(cgroup)
+	if err != nil {
+		return fmt.Errorf("process %d: %v", pid, err)
+	}
+	objs, err := a.informer.GetIndexer().ByIndex(podUIDIndex, uid)
+	if err != nil {
+		return err
+	}
+	if len(objs) == 0 {
+		return fmt.Errorf("process %d runs in pod %s, which is not scheduled on this node", pid, uid)
+	}
+	pod := objs[0].(*v1.Pod)
+	podServiceAccount := pod.Spec.ServiceAccountName
+	if podServiceAccount == "" {
+		podServiceAccount = "default"
+	}
+	if pod.Namespace != namespace || podServiceAccount != serviceAccount {
+		return fmt.Errorf("process %d runs in pod %s/%s with service account %s, not %s/%s",
+			pid, pod.Namespace, pod.Name, podServiceAccount, namespace, serviceAccount)
+	}
+	return nil
+}
+
+func podUIDFromCgroup(cgroup []byte) (string, error) {
+	m := podUIDRegexp.FindSubmatch(cgroup)
+	if m == nil {
+		return "", errors.New("no pod UID in the cgroup")
+	}
+	// The systemd cgroup driver replaces the dashes of the UID with underscores.
+	return strings.ReplaceAll(string(m[1]), "_", "-"), nil
+}
+
+// attestCaller verifies that the UDS caller runs as the service account of token, which is the token it
+// presented, or the local JWT of the agent when the SDS server serves the proxy of its own pod.
+func (s *sdsservice) attestCaller(ctx context.Context, token string) error {
+	if s.attestor == nil {
+		return nil
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return errors.New("no peer in the request context")
+	}
+	info, ok := p.AuthInfo.(peerCredAuthInfo)
+	if !ok {
+		return errors.New("no peer credentials for the caller")
+	}
+	namespace, serviceAccount, err := secutil.GetServiceAccountFromJwt(token)
+	if err != nil {
+		return err
+	}
+	return s.attestor.Attest(info.pid, namespace, serviceAccount)
+}
+
+// peerCredAuthInfo holds the credentials of the process on the other end of a UDS connection.
+type peerCredAuthInfo struct {
+	pid int32
+	uid uint32
+}
+
+// AuthType implements credentials.AuthInfo.
+func (peerCredAuthInfo) AuthType() string {
+	return "peercred"
+}
+
+// peerCredentials are the gRPC transport credentials of a UDS server, which record the
+// credentials of the peer process of each connection.
+type peerCredentials struct{}
+
+func (*peerCredentials) ClientHandshake(_ context.Context, _ string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("peer credentials are only supported on the server side")
+}
+
+func (*peerCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, nil, fmt.Errorf("peer credentials require a unix domain socket, got %T", conn)
+	}
+	pid, uid, err := getPeerCred(uc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, peerCredAuthInfo{pid: pid, uid: uid}, nil
+}
+
+func (*peerCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "peercred"}
+}
+
+func (*peerCredentials) Clone() credentials.TransportCredentials {
+	return &peerCredentials{}
+}
+
+func (*peerCredentials) OverrideServerName(string) error {
+	return nil
+}
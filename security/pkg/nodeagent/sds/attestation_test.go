@@ -0,0 +1,316 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sds
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	sds "github.com/envoyproxy/go-control-plane/envoy/service/secret/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes/fake"
+	k8scache "k8s.io/client-go/tools/cache"
+)
+
+func TestPodUIDFromCgroup(t *testing.T) {
+	testCases := map[string]struct {
+		cgroup    string
+		uid       string
+		expectErr bool
+	}{
+		"cgroupfs driver": {
+			cgroup: "12:pids:/kubepods/burstable/pod2c48913c-b29f-11e7-9350-020968147796/9bca8d63d5fa610783847915bcff0ecac1273e5b4bed3f6fa1b07350e0135961\n" +
+				"11:memory:/kubepods/burstable/pod2c48913c-b29f-11e7-9350-020968147796/9bca8d63d5fa610783847915bcff0ecac1273e5b4bed3f6fa1b07350e0135961\n",
+			uid: "2c48913c-b29f-11e7-9350-020968147796",
+		},
+		"systemd driver": {
+			cgroup: "0::/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod2c48913c_b29f_11e7_9350_020968147796.slice/" +
+				"cri-containerd-9bca8d63d5fa610783847915bcff0ecac1273e5b4bed3f6fa1b07350e0135961.scope\n",
+			uid: "2c48913c-b29f-11e7-9350-020968147796",
+		},
+		"not in a pod": {
+			cgroup:    "0::/user.slice/user-1000.slice/session-1.scope\n",
+			expectErr: true,
+		},
+	}
+
+	for id, tc := range testCases {
+		uid, err := podUIDFromCgroup([]byte(tc.cgroup))
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got none", id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", id, err)
+		} else if uid != tc.uid {
+			t.Errorf("%s: got pod UID %q, want %q", id, uid, tc.uid)
+		}
+	}
+}
+
+func TestPodAttestor(t *testing.T) {
+	procRoot, err := ioutil.TempDir("", "proc")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(procRoot)
+	writeCgroup := func(pid int, uid string) {
+		dir := filepath.Join(procRoot, fmt.Sprint(pid))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+		cgroup := fmt.Sprintf("1:name=systemd:/kubepods/besteffort/pod%s/0123456789abcdef\n", uid)
+		if err := ioutil.WriteFile(filepath.Join(dir, "cgroup"), []byte(cgroup), 0644); err != nil {
+			t.Fatalf("failed to write cgroup: %v", err)
+		}
+	}
+	writeCgroup(100, "11111111-2222-3333-4444-555555555555")
+	writeCgroup(200, "66666666-7777-8888-9999-000000000000")
+	writeCgroup(300, "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee")
+
+	client := fake.NewSimpleClientset(
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "httpbin", Namespace: "foo", UID: types.UID("11111111-2222-3333-4444-555555555555")},
+			Spec:       v1.PodSpec{ServiceAccountName: "httpbin", NodeName: "node1"},
+		},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "sleep", Namespace: "foo", UID: types.UID("66666666-7777-8888-9999-000000000000")},
+			Spec:       v1.PodSpec{NodeName: "node1"},
+		},
+	)
+	stop := make(chan struct{})
+	defer close(stop)
+	attestor := NewPodAttestor(client, "node1", stop)
+	attestor.procRoot = procRoot
+	if !k8scache.WaitForCacheSync(stop, attestor.informer.HasSynced) {
+		t.Fatal("failed to sync the pod informer")
+	}
+
+	testCases := map[string]struct {
+		pid            int32
+		namespace      string
+		serviceAccount string
+		expectErr      bool
+	}{
+		"matching service account": {
+			pid:            100,
+			namespace:      "foo",
+			serviceAccount: "httpbin",
+		},
+		"default service account": {
+			pid:            200,
+			namespace:      "foo",
+			serviceAccount: "default",
+		},
+		"other service account": {
+			pid:            200,
+			namespace:      "foo",
+			serviceAccount: "httpbin",
+			expectErr:      true,
+		},
+		"other namespace": {
+			pid:            100,
+			namespace:      "bar",
+			serviceAccount: "httpbin",
+			expectErr:      true,
+		},
+		"pod not on the node": {
+			pid:            300,
+			namespace:      "foo",
+			serviceAccount: "httpbin",
+			expectErr:      true,
+		},
+		"unknown process": {
+			pid:            400,
+			namespace:      "foo",
+			serviceAccount: "httpbin",
+			expectErr:      true,
+		},
+	}
+
+	for id, tc := range testCases {
+		err := attestor.Attest(tc.pid, tc.namespace, tc.serviceAccount)
+		if tc.expectErr && err == nil {
+			t.Errorf("%s: expected error, got none", id)
+		} else if !tc.expectErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", id, err)
+		}
+	}
+}
+
+type fakeAttestor struct {
+	pids chan int32
+	err  error
+}
+
+func (a *fakeAttestor) Attest(pid int32, namespace, serviceAccount string) error {
+	a.pids <- pid
+	if namespace != "foo" || serviceAccount != "httpbin" {
+		return fmt.Errorf("unexpected service account %s/%s", namespace, serviceAccount)
+	}
+	return a.err
+}
+
+func TestFetchSecretsWithWorkloadAttestation(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only supported on Linux")
+	}
+	// An unsigned JWT of the foo/httpbin service account.
+	token := "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"system:serviceaccount:foo:httpbin"}`)) + ".sig"
+	req := &discovery.DiscoveryRequest{
+		TypeUrl:       SecretTypeV3,
+		ResourceNames: []string{testResourceName},
+		Node: &core.Node{
+			Id: "sidecar~127.0.0.1~WorkloadAttestation~local",
+		},
+	}
+
+	for _, attestErr := range []error{nil, errors.New("denied")} {
+		attestor := &fakeAttestor{pids: make(chan int32, 1), err: attestErr}
+		socket := fmt.Sprintf("/tmp/gotest%s.sock", string(uuid.NewUUID()))
+		server, err := NewServer(Options{
+			EnableWorkloadSDS: true,
+			RecycleInterval:   100 * time.Second,
+			WorkloadUDSPath:   socket,
+			WorkloadAttestor:  attestor,
+		}, &mockSecretStore{}, nil)
+		if err != nil {
+			t.Fatalf("failed to start grpc server for sds: %v", err)
+		}
+
+		conn, err := setupConnection(socket)
+		if err != nil {
+			t.Fatalf("failed to setup connection to socket %q: %v", socket, err)
+		}
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(credentialTokenHeaderKey, token))
+		resp, err := sds.NewSecretDiscoveryServiceClient(conn).FetchSecrets(ctx, req, grpc.WaitForReady(true))
+		if attestErr == nil {
+			if err != nil {
+				t.Errorf("FetchSecrets failed: %v", err)
+			} else if err := verifySDSSResponse(resp, fakePrivateKey, fakeCertificateChain); err != nil {
+				t.Errorf("SDS response verification failed: %v", err)
+			}
+		} else if status.Code(err) != codes.PermissionDenied {
+			t.Errorf("FetchSecrets got %v, want PermissionDenied", err)
+		}
+
+		select {
+		case pid := <-attestor.pids:
+			if pid != int32(os.Getpid()) {
+				t.Errorf("attested pid %d, want %d", pid, os.Getpid())
+			}
+		default:
+			t.Error("the caller was not attested")
+		}
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func TestFetchSecretsWithPodAttestor(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only supported on Linux")
+	}
+	dir, err := ioutil.TempDir("", "attestation")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	// The agent runs with the local JWT of the foo/httpbin service account.
+	jwtPath := filepath.Join(dir, "istio-token")
+	token := "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"system:serviceaccount:foo:httpbin"}`)) + ".sig"
+	if err := ioutil.WriteFile(jwtPath, []byte(token), 0644); err != nil {
+		t.Fatalf("failed to write the JWT: %v", err)
+	}
+	// The test process runs in the pod with the UID below.
+	uid := "11111111-2222-3333-4444-555555555555"
+	procDir := filepath.Join(dir, "proc", fmt.Sprint(os.Getpid()))
+	if err := os.MkdirAll(procDir, 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", procDir, err)
+	}
+	cgroup := fmt.Sprintf("1:name=systemd:/kubepods/besteffort/pod%s/0123456789abcdef\n", uid)
+	if err := ioutil.WriteFile(filepath.Join(procDir, "cgroup"), []byte(cgroup), 0644); err != nil {
+		t.Fatalf("failed to write cgroup: %v", err)
+	}
+	req := &discovery.DiscoveryRequest{
+		TypeUrl:       SecretTypeV3,
+		ResourceNames: []string{testResourceName},
+		Node: &core.Node{
+			Id: "sidecar~127.0.0.1~PodAttestation~local",
+		},
+	}
+
+	for _, serviceAccount := range []string{"httpbin", "sleep"} {
+		client := fake.NewSimpleClientset(&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "caller", Namespace: "foo", UID: types.UID(uid)},
+			Spec:       v1.PodSpec{ServiceAccountName: serviceAccount, NodeName: "node1"},
+		})
+		stop := make(chan struct{})
+		attestor := NewPodAttestor(client, "node1", stop)
+		attestor.procRoot = filepath.Join(dir, "proc")
+		if !k8scache.WaitForCacheSync(stop, attestor.informer.HasSynced) {
+			t.Fatal("failed to sync the pod informer")
+		}
+		socket := fmt.Sprintf("/tmp/gotest%s.sock", string(uuid.NewUUID()))
+		server, err := NewServer(Options{
+			EnableWorkloadSDS: true,
+			RecycleInterval:   100 * time.Second,
+			WorkloadUDSPath:   socket,
+			UseLocalJWT:       true,
+			JWTPath:           jwtPath,
+			WorkloadAttestor:  attestor,
+		}, &mockSecretStore{}, nil)
+		if err != nil {
+			t.Fatalf("failed to start grpc server for sds: %v", err)
+		}
+
+		conn, err := setupConnection(socket)
+		if err != nil {
+			t.Fatalf("failed to setup connection to socket %q: %v", socket, err)
+		}
+		resp, err := sds.NewSecretDiscoveryServiceClient(conn).FetchSecrets(context.Background(), req, grpc.WaitForReady(true))
+		if serviceAccount == "httpbin" {
+			if err != nil {
+				t.Errorf("FetchSecrets failed for a caller in a pod of the service account of the JWT: %v", err)
+			} else if err := verifySDSSResponse(resp, fakePrivateKey, fakeCertificateChain); err != nil {
+				t.Errorf("SDS response verification failed: %v", err)
+			}
+		} else if status.Code(err) != codes.PermissionDenied {
+			t.Errorf("FetchSecrets got %v for a caller in a pod of another service account, want PermissionDenied", err)
+		}
+		conn.Close()
+		server.Stop()
+		close(stop)
+	}
+}
@@ -22,6 +22,9 @@ import (
 // Previously exposed metrics: pending_push_per_connection, stale_conn_count_per_connection,
 // pushes_per_connection, push_errors_per_connection, pushed_root_cert_expiry_timestamp, pushed_server_cert_expiry_timestamp
 var (
+	connectionIDTag = monitoring.MustCreateLabel("connection_id")
+	resourceNameTag = monitoring.MustCreateLabel("resource_name")
+
 	// totalPushCounts records total number of SDS pushes since server starts serving.
 	totalPushCounts = monitoring.NewSum(
 		"total_pushes",
@@ -52,6 +55,22 @@ var (
 		"total_secret_update_failures",
 		"The total number of dynamic secret update failures reported by proxy.",
 	)
+
+	// rotationPushesPerConnection records the number of secret updates pushed to each SDS
+	// connection without waiting for a request from the proxy.
+	rotationPushesPerConnection = monitoring.NewSum(
+		"rotation_pushes_per_connection",
+		"The number of rotated secrets pushed per SDS connection.",
+		monitoring.WithLabels(connectionIDTag, resourceNameTag),
+	)
+
+	// deferredPushesPerConnection records the number of secret updates per SDS connection that
+	// had to wait for the proxy to acknowledge the previous push.
+	deferredPushesPerConnection = monitoring.NewSum(
+		"deferred_pushes_per_connection",
+		"The number of SDS pushes per connection deferred until the previous push is acknowledged.",
+		monitoring.WithLabels(connectionIDTag, resourceNameTag),
+	)
 )
 
 func init() {
@@ -61,5 +80,7 @@ func init() {
 		totalActiveConnCounts,
 		totalStaleConnCounts,
 		totalSecretUpdateFailureCounts,
+		rotationPushesPerConnection,
+		deferredPushesPerConnection,
 	)
 }
@@ -0,0 +1,27 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !linux
+
+package sds
+
+import (
+	"errors"
+	"net"
+)
+
+// getPeerCred returns the pid and uid of the process on the other end of the connection.
+func getPeerCred(*net.UnixConn) (int32, uint32, error) {
+	return 0, 0, errors.New("peer credentials are only supported on Linux")
+}
@@ -105,6 +105,11 @@ type sdsConnection struct {
 	// non-zero time indicates that the connection is waiting for SDS request.
 	sdsPushTime time.Time
 
+	// pendingPush is set when a secret update arrives while the previous push is not acknowledged
+	// yet. The update is pushed as soon as the next SDS request is received, so that a rotated
+	// secret is not dropped until the next rotation.
+	pendingPush bool
+
 	// Envoy may request different versions of configuration (XDS v2 vs v3). While internally we will
 	// only generate one version or the other, because the protos are wire compatible we can cast to the
 	// requested version. This struct keeps track of the Secret type requested.
@@ -132,6 +137,9 @@ type sdsservice struct {
 	jwtPath string
 
 	outputKeyCertToDir string
+
+	// attestor verifies the identity claimed by UDS callers, if set.
+	attestor WorkloadAttestor
 }
 
 // ClientDebug represents a single SDS connection to the ndoe agent
@@ -278,6 +286,8 @@ func (s *sdsservice) StreamSecrets(stream sds.SecretDiscoveryService_StreamSecre
 
 			// Reset SDS push time for new SDS push.
 			con.sdsPushTime = time.Time{}
+			pendingPush := con.pendingPush
+			con.pendingPush = false
 			con.mutex.Unlock()
 
 			defer releaseResourcePerConn(s, conID, resourceName)
@@ -291,6 +301,11 @@ func (s *sdsservice) StreamSecrets(stream sds.SecretDiscoveryService_StreamSecre
 					return err
 				}
 				token = string(tok)
+				if err := s.attestCaller(stream.Context(), token); err != nil {
+					sdsServiceLog.Errorf("%s Close connection. Failed to attest proxy %q: %v",
+						conIDresourceNamePrefix, discReq.Node.Id, err)
+					return status.Errorf(codes.PermissionDenied, "failed to attest the caller: %v", err)
+				}
 			} else if s.outputKeyCertToDir != "" {
 				// Using existing certs and the new SDS - skipToken case is for the old node agent.
 			} else if !s.skipToken {
@@ -301,6 +316,11 @@ func (s *sdsservice) StreamSecrets(stream sds.SecretDiscoveryService_StreamSecre
 						"incoming request: %v", conIDresourceNamePrefix, err)
 					return err
 				}
+				if err := s.attestCaller(ctx, t); err != nil {
+					sdsServiceLog.Errorf("%s Close connection. Failed to attest proxy %q: %v",
+						conIDresourceNamePrefix, discReq.Node.Id, err)
+					return status.Errorf(codes.PermissionDenied, "failed to attest the caller: %v", err)
+				}
 				token = t
 			}
 
@@ -319,6 +339,15 @@ func (s *sdsservice) StreamSecrets(stream sds.SecretDiscoveryService_StreamSecre
 				sdsServiceLog.Debugf("%s received SDS ACK from proxy %q, version info %q, "+
 					"error details %s\n", conIDresourceNamePrefix, discReq.Node.Id, discReq.VersionInfo,
 					discReq.ErrorDetail)
+				if pendingPush {
+					sdsServiceLog.Debugf("%s send deferred push to proxy %q", conIDresourceNamePrefix, discReq.Node.Id)
+					if err := pushSDS(con); err != nil {
+						sdsServiceLog.Errorf("%s Close connection. Failed to push key/cert to proxy %q: %v",
+							conIDresourceNamePrefix, discReq.Node.Id, err)
+						return err
+					}
+					rotationPushesPerConnection.With(connectionIDTag.Value(conID), resourceNameTag.Value(resourceName)).Increment()
+				}
 				continue
 			}
 
@@ -390,7 +419,13 @@ func (s *sdsservice) StreamSecrets(stream sds.SecretDiscoveryService_StreamSecre
 					conIDresourceNamePrefix, proxyID, err)
 				return err
 			}
-			sdsServiceLog.Infoa("Dynamic push for secret ", resourceName)
+			con.mutex.RLock()
+			deferred := con.pendingPush
+			con.mutex.RUnlock()
+			if !deferred {
+				rotationPushesPerConnection.With(connectionIDTag.Value(conID), resourceNameTag.Value(resourceName)).Increment()
+				sdsServiceLog.Infoa("Dynamic push for secret ", resourceName)
+			}
 		}
 	}
 }
@@ -406,12 +441,20 @@ func (s *sdsservice) FetchSecrets(ctx context.Context, discReq *discovery.Discov
 			return nil, err
 		}
 		token = string(tok)
+		if err := s.attestCaller(ctx, token); err != nil {
+			sdsServiceLog.Errorf("Failed to attest the caller: %v", err)
+			return nil, status.Errorf(codes.PermissionDenied, "failed to attest the caller: %v", err)
+		}
 	} else if !s.skipToken {
 		t, err := getCredentialToken(ctx)
 		if err != nil {
 			sdsServiceLog.Errorf("Failed to get credential token: %v", err)
 			return nil, err
 		}
+		if err := s.attestCaller(ctx, t); err != nil {
+			sdsServiceLog.Errorf("Failed to attest the caller: %v", err)
+			return nil, status.Errorf(codes.PermissionDenied, "failed to attest the caller: %v", err)
+		}
 		token = t
 	}
 
@@ -584,8 +627,10 @@ func pushSDS(con *sdsConnection) error {
 
 	conIDresourceNamePrefix := sdsLogPrefix(resourceName)
 	if !sdsPushTime.IsZero() {
-		sdsServiceLog.Errorf("%s skip multiple push, last push finishes at %s and is "+
+		sdsServiceLog.Warnf("%s defer push, last push finishes at %s and is "+
 			"waiting for next SDS request", conIDresourceNamePrefix, sdsPushTime.String())
+		con.pendingPush = true
+		deferredPushesPerConnection.With(connectionIDTag.Value(con.conID), resourceNameTag.Value(resourceName)).Increment()
 		return nil
 	}
 
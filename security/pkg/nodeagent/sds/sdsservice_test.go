@@ -631,6 +631,83 @@ func TestStreamSecretsMultiplePush(t *testing.T) {
 	waitForNotificationToProceed(t, notifyChan, "close stream")
 }
 
+func testSDSStreamDeferredPush(stream sds.SecretDiscoveryService_StreamSecretsClient, proxyID string,
+	notifyChan chan notifyMsg) {
+	req := &discovery.DiscoveryRequest{
+		TypeUrl:       SecretTypeV3,
+		ResourceNames: []string{testResourceName},
+		Node: &core.Node{
+			Id: proxyID,
+		},
+	}
+
+	// Send first request and verify the response.
+	if err := stream.Send(req); err != nil {
+		notifyChan <- notifyMsg{Err: err, Message: fmt.Sprintf("stream.Send failed: %v", err)}
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		notifyChan <- notifyMsg{Err: err, Message: fmt.Sprintf("stream.Recv failed: %v", err)}
+	}
+	if err := verifySDSSResponse(resp, fakePrivateKey, fakeCertificateChain); err != nil {
+		notifyChan <- notifyMsg{Err: err, Message: fmt.Sprintf("SDS response verification failed: %v", err)}
+	}
+
+	// Don't acknowledge the first response before the SDS server pushes the rotated secret.
+	notifyChan <- notifyMsg{Err: nil, Message: "notify push secret"}
+	if notify := <-notifyChan; notify.Message != "receive secret" {
+		errMisMatch := fmt.Errorf("received error does not match, got %v", notify.Err)
+		notifyChan <- notifyMsg{Err: errMisMatch, Message: errMisMatch.Error()}
+	}
+
+	// Acknowledge the first response, the deferred push is sent right away.
+	req.VersionInfo = resp.VersionInfo
+	req.ResponseNonce = resp.Nonce
+	if err := stream.Send(req); err != nil {
+		notifyChan <- notifyMsg{Err: err, Message: fmt.Sprintf("stream.Send failed: %v", err)}
+	}
+	resp, err = stream.Recv()
+	if err != nil {
+		notifyChan <- notifyMsg{Err: err, Message: fmt.Sprintf("stream.Recv failed: %v", err)}
+	}
+	if err := verifySDSSResponse(resp, fakePushPrivateKey, fakePushCertificateChain); err != nil {
+		notifyChan <- notifyMsg{Err: err, Message: fmt.Sprintf(
+			"deferred SDS response verification failed: %v", err)}
+	}
+
+	notifyChan <- notifyMsg{Err: nil, Message: "close stream"}
+}
+
+// TestStreamSecretsDeferredPush verifies that a secret update arriving before the previous push is
+// acknowledged is not dropped, but pushed once the proxy sends its next request.
+func TestStreamSecretsDeferredPush(t *testing.T) {
+	setup := StartTest(t)
+	defer setup.server.Stop()
+
+	conn, stream := createSDSStream(t, setup.socket, fakeToken1)
+	defer conn.Close()
+	proxyID := "sidecar~127.0.0.1~StreamDeferredPush~local"
+	notifyChan := make(chan notifyMsg)
+	go testSDSStreamDeferredPush(stream, proxyID, notifyChan)
+
+	waitForNotificationToProceed(t, notifyChan, "notify push secret")
+	conID := getClientConID(proxyID)
+	// Push the rotated secret without updating the secret store, so that the ACK of the first
+	// response still matches the cached version.
+	pushSecret := &model.SecretItem{
+		CertificateChain: fakePushCertificateChain,
+		PrivateKey:       fakePushPrivateKey,
+		ResourceName:     testResourceName,
+		Version:          "rotated_version",
+		Token:            fakeToken1,
+	}
+	if err := NotifyProxy(cache.ConnKey{ConnectionID: conID, ResourceName: testResourceName}, pushSecret); err != nil {
+		t.Fatalf("failed to send push notification to proxy %q", conID)
+	}
+	notifyChan <- notifyMsg{Err: nil, Message: "receive secret"}
+	waitForNotificationToProceed(t, notifyChan, "close stream")
+}
+
 func testSDSStreamUpdateFailures(stream sds.SecretDiscoveryService_StreamSecretsClient, proxyID string,
 	notifyChan chan notifyMsg) {
 	req := &discovery.DiscoveryRequest{
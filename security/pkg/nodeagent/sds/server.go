@@ -25,6 +25,9 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 
 	"istio.io/istio/security/pkg/nodeagent/cache"
 	"istio.io/istio/security/pkg/nodeagent/plugin"
@@ -37,6 +40,10 @@ const (
 	debugBase     = "/debug"
 	maxStreams    = 100000
 	maxRetryTimes = 5
+
+	// Names of the SDS services reported by the health service.
+	sdsServiceNameV3 = "envoy.service.secret.v3.SecretDiscoveryService"
+	sdsServiceNameV2 = "envoy.service.discovery.v2.SecretDiscoveryService"
 )
 
 // Options provides all of the configuration parameters for secret discovery service.
@@ -131,6 +138,11 @@ type Options struct {
 
 	// FileMountedCerts indicates file mounted certs.
 	FileMountedCerts bool
+
+	// WorkloadAttestor, if set, attests that the callers of the workload SDS UDS run as the service
+	// account of the token they present, or of the local JWT, based on their peer credentials. It is
+	// not supported with TLS.
+	WorkloadAttestor WorkloadAttestor
 }
 
 // Server is the gPRC server that exposes SDS through UDS.
@@ -155,6 +167,9 @@ func NewServer(options Options, workloadSecretCache, gatewaySecretCache cache.Se
 		gatewaySds: newSDSService(gatewaySecretCache, true, options.UseLocalJWT, options.FileMountedCerts,
 			options.RecycleInterval, options.JWTPath, options.OutputKeyCertToDir),
 	}
+	if s.workloadSds != nil {
+		s.workloadSds.attestor = options.WorkloadAttestor
+	}
 	if options.EnableWorkloadSDS {
 		if err := s.initWorkloadSdsService(&options); err != nil {
 			sdsServiceLog.Errorf("Failed to initialize secret discovery service for workload proxies: %v", err)
@@ -260,8 +275,14 @@ func (s *Server) initWorkloadSdsService(options *Options) error { //nolint: unpa
 		s.workloadSds.register(s.grpcWorkloadServer)
 		return nil
 	}
-	s.grpcWorkloadServer = grpc.NewServer(s.grpcServerOptions(options)...)
+	grpcOptions := s.grpcServerOptions(options)
+	if options.WorkloadAttestor != nil {
+		// The peer credentials of UDS callers are needed to attest them.
+		grpcOptions = append(grpcOptions, grpc.Creds(&peerCredentials{}))
+	}
+	s.grpcWorkloadServer = grpc.NewServer(grpcOptions...)
 	s.workloadSds.register(s.grpcWorkloadServer)
+	registerHealthAndReflection(s.grpcWorkloadServer)
 
 	var err error
 	s.grpcWorkloadListener, err = setUpUds(options.WorkloadUDSPath)
@@ -302,6 +323,7 @@ func (s *Server) initWorkloadSdsService(options *Options) error { //nolint: unpa
 func (s *Server) initGatewaySdsService(options *Options) error {
 	s.grpcGatewayServer = grpc.NewServer(s.grpcServerOptions(options)...)
 	s.gatewaySds.register(s.grpcGatewayServer)
+	registerHealthAndReflection(s.grpcGatewayServer)
 
 	var err error
 	s.grpcGatewayListener, err = setUpUds(options.IngressGatewayUDSPath)
@@ -340,6 +362,17 @@ func (s *Server) initGatewaySdsService(options *Options) error {
 	return nil
 }
 
+// registerHealthAndReflection registers the standard gRPC health and reflection services on an SDS
+// server created by the agent. The SDS services serve as soon as the server is up, since the
+// secrets are generated on request.
+func registerHealthAndReflection(server *grpc.Server) {
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(sdsServiceNameV3, healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(sdsServiceNameV2, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+}
+
 func setUpUds(udsPath string) (net.Listener, error) {
 	// Remove unix socket before use.
 	if err := os.Remove(udsPath); err != nil && !os.IsNotExist(err) {
@@ -32,6 +32,7 @@ import (
 	sds "github.com/envoyproxy/go-control-plane/envoy/service/secret/v3"
 	"github.com/golang/protobuf/ptypes"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 	"k8s.io/apimachinery/pkg/util/uuid"
 
@@ -383,3 +384,23 @@ func validateSDSSResponse(resp *discovery.DiscoveryResponse) error {
 
 	return nil
 }
+
+func TestHealthCheck(t *testing.T) {
+	setup := StartTest(t)
+	defer setup.server.Stop()
+
+	conn, err := setupConnection(setup.socket)
+	if err != nil {
+		t.Fatalf("failed to setup connection to socket %q: %v", setup.socket, err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	for _, service := range []string{"", sdsServiceNameV3, sdsServiceNameV2} {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Errorf("health check of %q failed: %v", service, err)
+		} else if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("got status %v for %q, want SERVING", resp.Status, service)
+		}
+	}
+}
@@ -169,6 +169,25 @@ func (s *CAServer) CreateCertificate(ctx context.Context, request *pb.IstioCerti
 	return response, nil
 }
 
+// CreateCertificateBatch handles a batch of CSRs.
+func (s *CAServer) CreateCertificateBatch(ctx context.Context, request *pb.IstioCertificateBatchRequest) (
+	*pb.IstioCertificateBatchResponse, error) {
+	response := &pb.IstioCertificateBatchResponse{}
+	for _, item := range request.Requests {
+		result := &pb.IstioCertificateBatchResult{}
+		resp, err := s.CreateCertificate(ctx, item.Request)
+		if err != nil {
+			st, _ := status.FromError(err)
+			result.Code = int32(st.Code())
+			result.Message = st.Message()
+		} else {
+			result.Response = resp
+		}
+		response.Results = append(response.Results, result)
+	}
+	return response, nil
+}
+
 func (s *CAServer) sign(csrPEM []byte, subjectIDs []string, _ time.Duration, forCA bool) ([]byte, error) {
 	csr, err := util.ParsePemEncodedCSR(csrPEM)
 	if err != nil {
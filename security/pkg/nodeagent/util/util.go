@@ -19,6 +19,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path"
 	"time"
 
@@ -56,8 +57,20 @@ func GetMetricsCounterValue(metricName string) (float64, error) {
 	return rows[0].Data.(*view.SumData).Value, nil
 }
 
+const (
+	// keyFilePerm is the permission of the output private key, readable only by the owner.
+	keyFilePerm os.FileMode = 0600
+	// certFilePerm is the permission of the output cert chain and root cert.
+	certFilePerm os.FileMode = 0644
+	// outputDirPerm is the permission of the output directory when it is created.
+	outputDirPerm os.FileMode = 0755
+)
+
 // Output the key and certificate to the given directory.
 // If directory is empty, return nil.
+// The directory is created if it does not exist. Each file is written to a temporary file in the
+// directory and renamed into place, so that readers never see a partially written file. The private
+// key is only readable by the owner.
 func OutputKeyCertToDir(dir string, privateKey, certChain, rootCert []byte) error {
 	if len(dir) == 0 {
 		return nil
@@ -66,22 +79,48 @@ func OutputKeyCertToDir(dir string, privateKey, certChain, rootCert []byte) erro
 	if privateKey == nil && certChain == nil && rootCert == nil {
 		return fmt.Errorf("the input private key, cert chain, and root cert are nil")
 	}
+	if err := os.MkdirAll(dir, outputDirPerm); err != nil {
+		return fmt.Errorf("failed to create output directory %s: %v", dir, err)
+	}
 
 	if privateKey != nil {
-		if err := ioutil.WriteFile(path.Join(dir, "key.pem"), privateKey, 0777); err != nil {
+		if err := writeFileAtomic(path.Join(dir, "key.pem"), privateKey, keyFilePerm); err != nil {
 			return fmt.Errorf("failed to write private key to file: %v", err)
 		}
 	}
 	if certChain != nil {
-		if err := ioutil.WriteFile(path.Join(dir, "cert-chain.pem"), certChain, 0777); err != nil {
+		if err := writeFileAtomic(path.Join(dir, "cert-chain.pem"), certChain, certFilePerm); err != nil {
 			return fmt.Errorf("failed to write cert chain to file: %v", err)
 		}
 	}
 	if rootCert != nil {
-		if err := ioutil.WriteFile(path.Join(dir, "root-cert.pem"), rootCert, 0777); err != nil {
+		if err := writeFileAtomic(path.Join(dir, "root-cert.pem"), rootCert, certFilePerm); err != nil {
 			return fmt.Errorf("failed to write root cert to file: %v", err)
 		}
 	}
 
 	return nil
 }
+
+// writeFileAtomic writes data to a temporary file next to filename and renames it to filename.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(path.Dir(filename), "."+path.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if err = f.Chmod(perm); err == nil {
+		if _, err = f.Write(data); err == nil {
+			err = f.Sync()
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
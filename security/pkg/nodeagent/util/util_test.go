@@ -0,0 +1,85 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputKeyCertToDir(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "output-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	dir := filepath.Join(tmp, "certs")
+
+	if err := OutputKeyCertToDir(dir, []byte("key"), []byte("chain"), []byte("root")); err != nil {
+		t.Fatalf("failed to output key and certs: %v", err)
+	}
+	expected := map[string]struct {
+		content string
+		perm    os.FileMode
+	}{
+		"key.pem":        {"key", keyFilePerm},
+		"cert-chain.pem": {"chain", certFilePerm},
+		"root-cert.pem":  {"root", certFilePerm},
+	}
+	for name, e := range expected {
+		path := filepath.Join(dir, name)
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		if string(content) != e.content {
+			t.Errorf("%s: got %q, want %q", name, content, e.content)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != e.perm {
+			t.Errorf("%s: got permission %v, want %v", name, info.Mode().Perm(), e.perm)
+		}
+	}
+
+	// Only the root cert is rotated.
+	if err := OutputKeyCertToDir(dir, nil, nil, []byte("new root")); err != nil {
+		t.Fatalf("failed to output the root cert: %v", err)
+	}
+	if content, _ := ioutil.ReadFile(filepath.Join(dir, "root-cert.pem")); string(content) != "new root" {
+		t.Errorf("root-cert.pem: got %q, want %q", content, "new root")
+	}
+	if content, _ := ioutil.ReadFile(filepath.Join(dir, "key.pem")); string(content) != "key" {
+		t.Errorf("key.pem: got %q, want %q", content, "key")
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("expected no temporary file left in the output directory, got %d files", len(files))
+	}
+
+	if err := OutputKeyCertToDir(dir, nil, nil, nil); err == nil {
+		t.Error("expected an error when there is nothing to output")
+	}
+	if err := OutputKeyCertToDir("", []byte("key"), nil, nil); err != nil {
+		t.Errorf("unexpected error for an empty directory: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"crypto/x509"
+	"time"
+)
+
+// ExpiryChecker alerts once per cert when a CA cert is within a threshold of its expiry.
+type ExpiryChecker struct {
+	threshold time.Duration
+	notified  string
+}
+
+// NewExpiryChecker creates an ExpiryChecker alerting threshold before the expiry of the certs.
+func NewExpiryChecker(threshold time.Duration) *ExpiryChecker {
+	return &ExpiryChecker{threshold: threshold}
+}
+
+// Check sends a CACertExpiring alert if cert expires within the threshold of now, unless it was
+// already sent for the cert. It returns true if the alert is sent.
+func (c *ExpiryChecker) Check(cert *x509.Certificate, now time.Time) bool {
+	if cert == nil || cert.NotAfter.Sub(now) > c.threshold {
+		return false
+	}
+	serial := cert.SerialNumber.String()
+	if serial == c.notified {
+		return false
+	}
+	c.notified = serial
+	Send(CACertExpiring, "the CA cert %q (serial %s) expires at %s, in %s",
+		cert.Subject.String(), serial, cert.NotAfter.Format(time.RFC3339), cert.NotAfter.Sub(now).Round(time.Minute))
+	return true
+}
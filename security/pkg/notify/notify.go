@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify sends human oriented alerts about the lifecycle of the CA and of the certs it
+// manages, e.g. root cert rotations or certs about to expire, to the registered notifiers.
+package notify
+
+import (
+	"fmt"
+	"sync"
+
+	"istio.io/pkg/log"
+)
+
+var notifyLog = log.RegisterScope("notify", "Security notifications", 0)
+
+// Kind is the kind of an alert.
+type Kind string
+
+const (
+	// RootRotationStarted is sent when the rotation of the self-signed root cert starts.
+	RootRotationStarted Kind = "root_rotation_started"
+	// RootRotationCompleted is sent when the rotation of the self-signed root cert completes.
+	RootRotationCompleted Kind = "root_rotation_completed"
+	// RootRotationFailed is sent when the rotation of the self-signed root cert fails.
+	RootRotationFailed Kind = "root_rotation_failed"
+	// SecretRefreshFailing is sent when a managed secret failed to be refreshed repeatedly.
+	SecretRefreshFailing Kind = "secret_refresh_failing"
+	// CACertExpiring is sent when the CA cert is about to expire.
+	CACertExpiring Kind = "ca_cert_expiring"
+	// TrustAnchorExpiring is sent when an additional trust anchor is about to expire.
+	TrustAnchorExpiring Kind = "trust_anchor_expiring"
+)
+
+// Alert is a notification for the operators of the mesh.
+type Alert struct {
+	Kind Kind `json:"kind"`
+	// Text is the human readable description of the alert.
+	Text string `json:"text"`
+}
+
+// Notifier delivers alerts, e.g. to a chat channel.
+type Notifier interface {
+	Notify(Alert)
+}
+
+var (
+	notifiersMutex sync.RWMutex
+	notifiers      = map[*Notifier]Notifier{}
+)
+
+// Register adds a notifier receiving all subsequent alerts. The returned function removes it.
+func Register(n Notifier) (unregister func()) {
+	key := &n
+	notifiersMutex.Lock()
+	notifiers[key] = n
+	notifiersMutex.Unlock()
+	return func() {
+		notifiersMutex.Lock()
+		delete(notifiers, key)
+		notifiersMutex.Unlock()
+	}
+}
+
+// Send logs the alert, and passes it to the registered notifiers.
+func Send(kind Kind, format string, args ...interface{}) {
+	alert := Alert{Kind: kind, Text: fmt.Sprintf(format, args...)}
+	notifyLog.Infof("%s: %s", alert.Kind, alert.Text)
+
+	notifiersMutex.RLock()
+	defer notifiersMutex.RUnlock()
+	for _, n := range notifiers {
+		n.Notify(alert)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeNotifier struct {
+	alerts []Alert
+}
+
+func (n *fakeNotifier) Notify(alert Alert) {
+	n.alerts = append(n.alerts, alert)
+}
+
+func TestSend(t *testing.T) {
+	n := &fakeNotifier{}
+	unregister := Register(n)
+	Send(RootRotationStarted, "rotating the root cert of %s", "istio-system")
+	unregister()
+	Send(RootRotationCompleted, "done")
+
+	expected := []Alert{{Kind: RootRotationStarted, Text: "rotating the root cert of istio-system"}}
+	if len(n.alerts) != 1 || n.alerts[0] != expected[0] {
+		t.Errorf("expected %v, got %v", expected, n.alerts)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	received := make(chan Alert, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		alert := Alert{}
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("failed to decode the alert: %v", err)
+		}
+		received <- alert
+	}))
+	defer server.Close()
+
+	w := NewWebhookNotifier(server.URL)
+	stop := make(chan struct{})
+	defer close(stop)
+	go w.Run(stop)
+
+	sent := Alert{Kind: SecretRefreshFailing, Text: "secret foo/bar failed to refresh 3 times"}
+	w.Notify(sent)
+	select {
+	case alert := <-received:
+		if alert != sent {
+			t.Errorf("expected %v, got %v", sent, alert)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the alert was not posted")
+	}
+}
+
+func TestExpiryChecker(t *testing.T) {
+	n := &fakeNotifier{}
+	defer Register(n)()
+	now := time.Now()
+	c := NewExpiryChecker(24 * time.Hour)
+
+	cert := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"cluster.local"}},
+		NotAfter:     now.Add(48 * time.Hour),
+	}
+	if c.Check(cert, now) {
+		t.Error("unexpected alert for a cert expiring after the threshold")
+	}
+	if !c.Check(cert, now.Add(25*time.Hour)) {
+		t.Error("expected an alert for a cert expiring within the threshold")
+	}
+	if c.Check(cert, now.Add(26*time.Hour)) {
+		t.Error("unexpected second alert for the same cert")
+	}
+	renewed := &x509.Certificate{SerialNumber: big.NewInt(2), NotAfter: now.Add(30 * time.Hour)}
+	if !c.Check(renewed, now.Add(26*time.Hour)) {
+		t.Error("expected an alert for a new cert expiring within the threshold")
+	}
+	if len(n.alerts) != 2 || n.alerts[0].Kind != CACertExpiring {
+		t.Errorf("unexpected alerts %v", n.alerts)
+	}
+}
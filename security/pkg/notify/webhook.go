@@ -0,0 +1,82 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const webhookQueueSize = 100
+
+// WebhookNotifier posts the alerts as JSON to an HTTP endpoint. The "text" field of the payload
+// makes it compatible with the incoming webhooks of Slack and similar chat services.
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+	queue  chan Alert
+}
+
+// NewWebhookNotifier creates a WebhookNotifier posting to url.
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan Alert, webhookQueueSize),
+	}
+}
+
+// Notify implements Notifier. The alerts are posted asynchronously by Run, and dropped if too many
+// are pending.
+func (w *WebhookNotifier) Notify(alert Alert) {
+	select {
+	case w.queue <- alert:
+	default:
+		notifyLog.Warnf("dropping alert %q, too many alerts are pending for %s", alert.Text, w.url)
+	}
+}
+
+// Run posts the alerts until stop is closed.
+func (w *WebhookNotifier) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case alert := <-w.queue:
+			if err := w.post(alert); err != nil {
+				notifyLog.Errorf("failed to post alert %q to %s: %v", alert.Text, w.url, err)
+			}
+		}
+	}
+}
+
+func (w *WebhookNotifier) post(alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
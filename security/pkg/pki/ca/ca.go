@@ -16,11 +16,17 @@ package ca
 
 import (
 	"context"
+	"crypto"
+	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/base64"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"net/url"
+	"sync"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -29,8 +35,11 @@ import (
 	"istio.io/istio/security/pkg/cmd"
 
 	"istio.io/istio/security/pkg/k8s/configmap"
+	"istio.io/istio/security/pkg/k8s/controller"
 	k8ssecret "istio.io/istio/security/pkg/k8s/secret"
+	"istio.io/istio/security/pkg/pki/ct"
 	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/kms"
 	"istio.io/istio/security/pkg/pki/util"
 	certutil "istio.io/istio/security/pkg/util"
 	"istio.io/pkg/log"
@@ -53,6 +62,8 @@ const (
 	PrivateKeyID = "key.pem"
 	// RootCertID is the ID/name for the CA root certificate file.
 	RootCertID = "root-cert.pem"
+	// PKCS7CertChainID is the ID/name for the cert chain and root cert as a PKCS#7 bundle.
+	PKCS7CertChainID = "cert-chain.p7b"
 	// ServiceAccountNameAnnotationKey is the key to specify corresponding service account in the annotation of K8s secrets.
 	ServiceAccountNameAnnotationKey = "istio.io/service-account.name"
 
@@ -73,6 +84,8 @@ const (
 	selfSignedCA caTypes = iota
 	// pluggedCertCA means the Istio CA uses a operator-specified key/cert.
 	pluggedCertCA
+	// intermediateCA means the Istio CA uses a locally generated key with a cert signed by an upstream CA.
+	intermediateCA
 )
 
 // IstioCAOptions holds the configurations for creating an Istio CA.
@@ -82,6 +95,8 @@ type IstioCAOptions struct {
 
 	DefaultCertTTL time.Duration
 	MaxCertTTL     time.Duration
+	// MinCertTTL is the minimum TTL of issued certificates. Zero disables the floor.
+	MinCertTTL time.Duration
 
 	KeyCertBundle util.KeyCertBundle
 
@@ -90,6 +105,71 @@ type IstioCAOptions struct {
 
 	// Config for creating self-signed root cert rotator.
 	RotatorConfig *SelfSignedCARootCertRotatorConfig
+
+	// Config for creating intermediate cert renewer.
+	IntermediateRenewerConfig *IntermediateCertRenewerConfig
+
+	// Config for the two-phase rotation of the root of a self-signed CA. It is disabled if nil.
+	RootRotationConfig *RootRotationConfig
+
+	// StateRecorder records the operational state of the CA. It is optional.
+	StateRecorder CAStateRecorder
+
+	// Signer signs certificates with a private key held outside of KeyCertBundle, e.g. in an HSM.
+	// KeyCertBundle holds no private key when it is set.
+	Signer crypto.Signer
+
+	// CAVersionOverlap is how long the previous CA key/cert stays trusted after a rotation.
+	// Zero drops the previous CA as soon as it is replaced.
+	CAVersionOverlap time.Duration
+
+	// CertPolicyIdentifiers are included in the certificate policies extension of issued certs.
+	CertPolicyIdentifiers []asn1.ObjectIdentifier
+	// CertExtraExtensions are included in issued certs as is.
+	CertExtraExtensions []pkix.Extension
+	// CertCRLDistributionPoints are the URLs of the CRLs included in the CRL distribution points
+	// extension of issued certs.
+	CertCRLDistributionPoints []string
+	// CertOCSPServers and CertIssuingCertificateURLs are the OCSP responder and CA issuers URLs
+	// included in the authority information access extension of issued certs.
+	CertOCSPServers            []string
+	CertIssuingCertificateURLs []string
+
+	// CSRValidation configures the checks applied to CSRs before signing. The defaults
+	// from DefaultCSRValidationOptions are used if it is nil.
+	CSRValidation *CSRValidationOptions
+
+	// CTSubmitter submits issued certs to Certificate Transparency logs. It is optional.
+	CTSubmitter *ct.Submitter
+
+	// Config for watching plugged-in CA key/cert files.
+	PluggedCertWatcherConfig *PluggedCertWatcherConfig
+
+	// SANPolicy restricts which identities may request which SANs. Nil allows any SAN.
+	SANPolicy *SANPolicy
+
+	// SignatureAlgorithm is the algorithm used to sign issued certs. It must match the type
+	// of the CA key. If unknown, the default algorithm for the CA key is used.
+	SignatureAlgorithm x509.SignatureAlgorithm
+
+	// SubCAMaxPathLen is the path length constraint of CA certs signed for CSRs with forCA set.
+	// As in x509.Certificate, zero means unset unless SubCAMaxPathLenZero is true, and a negative
+	// value means unset.
+	SubCAMaxPathLen int
+
+	// SubCAMaxPathLenZero sets a zero SubCAMaxPathLen explicitly, so that the CAs signed by this CA
+	// cannot issue further CA certs.
+	SubCAMaxPathLenZero bool
+
+	// SubCAPermittedDNSDomains and SubCAPermittedURIDomains are the name constraints of CA certs
+	// signed for CSRs with forCA set. They restrict the DNS domains and the URI domains, e.g. the
+	// SPIFFE trust domains, of the certs the signed CAs can issue.
+	SubCAPermittedDNSDomains []string
+	SubCAPermittedURIDomains []string
+
+	// SerialNumberGenerator generates the serial numbers of issued certs. Random 128-bit serial
+	// numbers are used if it is nil.
+	SerialNumberGenerator SerialNumberGenerator
 }
 
 // NewSelfSignedIstioCAOptions returns a new IstioCAOptions instance using self-signed certificate.
@@ -98,6 +178,20 @@ func NewSelfSignedIstioCAOptions(ctx context.Context,
 	maxCertTTL time.Duration, org string, dualUse bool, namespace string,
 	readCertRetryInterval time.Duration, client corev1.CoreV1Interface,
 	rootCertFile string, enableJitter bool) (caOpts *IstioCAOptions, err error) {
+	return NewSelfSignedIstioCAOptionsWithKeyEncryption(ctx, rootCertGracePeriodPercentile, caCertTTL,
+		rootCertCheckInverval, defaultCertTTL, maxCertTTL, org, dualUse, namespace, readCertRetryInterval,
+		client, rootCertFile, enableJitter, nil)
+}
+
+// NewSelfSignedIstioCAOptionsWithKeyEncryption is similar to NewSelfSignedIstioCAOptions, but the
+// CA private key in CASecret is envelope encrypted by keyEncryption. A plaintext key found in an
+// existing CASecret is encrypted in place.
+func NewSelfSignedIstioCAOptionsWithKeyEncryption(ctx context.Context,
+	rootCertGracePeriodPercentile int, caCertTTL, rootCertCheckInverval, defaultCertTTL,
+	maxCertTTL time.Duration, org string, dualUse bool, namespace string,
+	readCertRetryInterval time.Duration, client corev1.CoreV1Interface,
+	rootCertFile string, enableJitter bool, keyEncryption kms.Provider) (caOpts *IstioCAOptions, err error) {
+	caSecretController := controller.NewCaSecretControllerWithKeyEncryption(client, keyEncryption)
 	// For the first time the CA is up, if readSigningCertOnly is unset,
 	// it generates a self-signed key/cert pair and write it to CASecret.
 	// For subsequent restart, CA will reads key/cert from CASecret.
@@ -133,6 +227,7 @@ func NewSelfSignedIstioCAOptions(ctx context.Context,
 			rootCertFile:       rootCertFile,
 			enableJitter:       enableJitter,
 			client:             client,
+			keyEncryption:      keyEncryption,
 		},
 	}
 	if scrtErr != nil {
@@ -162,6 +257,9 @@ func NewSelfSignedIstioCAOptions(ctx context.Context,
 
 		// Write the key/cert back to secret so they will be persistent when CA restarts.
 		secret := k8ssecret.BuildSecret("", CASecret, namespace, nil, nil, nil, pemCert, pemKey, istioCASecretType)
+		if secret, err = caSecretController.EncryptCASecret(secret); err != nil {
+			return nil, fmt.Errorf("failed to create CA due to key encryption error (%v)", err)
+		}
 		if _, err = client.Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
 			pkiCaLog.Errorf("Failed to write secret to CA (error: %s). Abort.", err)
 			return nil, fmt.Errorf("failed to create CA due to secret write error")
@@ -169,7 +267,17 @@ func NewSelfSignedIstioCAOptions(ctx context.Context,
 		pkiCaLog.Infof("Using self-generated public key: %v", string(rootCerts))
 	} else {
 		pkiCaLog.Infof("Load signing key and cert from existing secret %s:%s", caSecret.Namespace, caSecret.Name)
-		rootCerts, err := util.AppendRootCerts(caSecret.Data[caCertID], rootCertFile)
+		if keyEncryption != nil && !controller.IsCASecretKeyEncrypted(caSecret) {
+			pkiCaLog.Infof("Encrypt the plaintext private key in secret %s:%s", caSecret.Namespace, caSecret.Name)
+			if err = caSecretController.UpdateCASecretWithRetry(caSecret.DeepCopy(),
+				cmd.ReadSigningCertRetryInterval, 30*time.Second); err != nil {
+				return nil, fmt.Errorf("failed to encrypt the CA private key (%v)", err)
+			}
+		}
+		if caSecret, err = caSecretController.DecryptCASecret(caSecret); err != nil {
+			return nil, err
+		}
+		rootCerts, err := caSecretRootCerts(caSecret, rootCertFile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to append root certificates (%v)", err)
 		}
@@ -191,13 +299,30 @@ func NewSelfSignedIstioCAOptions(ctx context.Context,
 // NewPluggedCertIstioCAOptions returns a new IstioCAOptions instance using given certificate.
 func NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, signingKeyFile, rootCertFile string,
 	defaultCertTTL, maxCertTTL time.Duration, namespace string, client corev1.CoreV1Interface) (caOpts *IstioCAOptions, err error) {
+	return NewPluggedCertIstioCAOptionsWithKeyPassphrase(certChainFile, signingCertFile, signingKeyFile, rootCertFile,
+		nil, defaultCertTTL, maxCertTTL, namespace, client)
+}
+
+// NewPluggedCertIstioCAOptionsWithKeyPassphrase is similar to NewPluggedCertIstioCAOptions, but the
+// private key in signingKeyFile can be an encrypted PEM or PKCS#8 key, decrypted with keyPassphrase.
+func NewPluggedCertIstioCAOptionsWithKeyPassphrase(certChainFile, signingCertFile, signingKeyFile, rootCertFile string,
+	keyPassphrase []byte, defaultCertTTL, maxCertTTL time.Duration, namespace string,
+	client corev1.CoreV1Interface) (caOpts *IstioCAOptions, err error) {
 	caOpts = &IstioCAOptions{
 		CAType:         pluggedCertCA,
 		DefaultCertTTL: defaultCertTTL,
 		MaxCertTTL:     maxCertTTL,
+		PluggedCertWatcherConfig: &PluggedCertWatcherConfig{
+			certChainFile:   certChainFile,
+			signingCertFile: signingCertFile,
+			signingKeyFile:  signingKeyFile,
+			rootCertFile:    rootCertFile,
+			namespace:       namespace,
+			client:          client,
+		},
 	}
-	if caOpts.KeyCertBundle, err = util.NewVerifiedKeyCertBundleFromFile(
-		signingCertFile, signingKeyFile, certChainFile, rootCertFile); err != nil {
+	if caOpts.KeyCertBundle, err = util.NewVerifiedKeyCertBundleFromFileWithPassphrase(
+		signingCertFile, signingKeyFile, certChainFile, rootCertFile, keyPassphrase); err != nil {
 		return nil, fmt.Errorf("failed to create CA KeyCertBundle (%v)", err)
 	}
 
@@ -208,32 +333,81 @@ func NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, signingKeyFile
 	if err != nil {
 		return nil, err
 	}
-	block, _ := pem.Decode(b)
+	if err = verifySigningCertIsCA(b); err != nil {
+		return nil, err
+	}
+
+	updatePluggedCertInConfigmap(namespace, client, caOpts.KeyCertBundle)
+	return caOpts, nil
+}
+
+// NewPluggedCertIstioCAOptionsFromSecret returns a new IstioCAOptions instance using the CA key/cert
+// stored in the secret described by source. rootCertFile, if not empty, holds additional root certs.
+func NewPluggedCertIstioCAOptionsFromSecret(source controller.CASecretSource, rootCertFile string,
+	defaultCertTTL, maxCertTTL time.Duration, namespace string, client corev1.CoreV1Interface) (caOpts *IstioCAOptions, err error) {
+	caOpts = &IstioCAOptions{
+		CAType:         pluggedCertCA,
+		DefaultCertTTL: defaultCertTTL,
+		MaxCertTTL:     maxCertTTL,
+	}
+	csc := controller.NewCaSecretController(client)
+	certBytes, privKeyBytes, certChainBytes, rootCertBytes, err := csc.LoadCAKeyCertWithRetry(
+		source, cmd.ReadSigningCertRetryInterval, 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CA secret %s:%s (%v)", source.Namespace, source.Name, err)
+	}
+	if err = verifySigningCertIsCA(certBytes); err != nil {
+		return nil, err
+	}
+	rootCerts, err := util.AppendRootCerts(rootCertBytes, rootCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to append root certificates (%v)", err)
+	}
+	if caOpts.KeyCertBundle, err = util.NewVerifiedKeyCertBundleFromPemWithPassphrase(
+		certBytes, privKeyBytes, certChainBytes, rootCerts, source.KeyPassphrase); err != nil {
+		return nil, fmt.Errorf("failed to create CA KeyCertBundle (%v)", err)
+	}
+	pkiCaLog.Infof("Load signing key and cert from secret %s:%s", source.Namespace, source.Name)
+
+	updatePluggedCertInConfigmap(namespace, client, caOpts.KeyCertBundle)
+	return caOpts, nil
+}
+
+// verifySigningCertIsCA returns an error if the first PEM encoded certificate cannot be used to sign
+// other certificates.
+func verifySigningCertIsCA(certBytes []byte) error {
+	block, _ := pem.Decode(certBytes)
 	if block == nil {
-		return nil, fmt.Errorf("invalid PEM encoded certificate")
+		return fmt.Errorf("invalid PEM encoded certificate")
 	}
 	cert, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse X.509 certificate")
+		return fmt.Errorf("failed to parse X.509 certificate")
 	}
 	if !cert.IsCA {
-		return nil, fmt.Errorf("certificate is not authorized to sign other certificates")
+		return fmt.Errorf("certificate is not authorized to sign other certificates")
 	}
+	return nil
+}
 
-	crt := caOpts.KeyCertBundle.GetCertChainPem()
+func updatePluggedCertInConfigmap(namespace string, client corev1.CoreV1Interface, bundle util.KeyCertBundle) {
+	if client == nil {
+		return
+	}
+	crt := bundle.GetCertChainPem()
 	if len(crt) == 0 {
-		crt = caOpts.KeyCertBundle.GetRootCertPem()
+		crt = bundle.GetRootCertPem()
 	}
-	if err = updateCertInConfigmap(namespace, client, crt); err != nil {
+	if err := updateCertInConfigmap(namespace, client, crt); err != nil {
 		pkiCaLog.Errorf("Failed to write Citadel cert to configmap (%v). Node agents will not be able to connect.", err)
 	}
-	return caOpts, nil
 }
 
 // IstioCA generates keys and certificates for Istio identities.
 type IstioCA struct {
 	defaultCertTTL time.Duration
 	maxCertTTL     time.Duration
+	minCertTTL     time.Duration
 
 	keyCertBundle util.KeyCertBundle
 
@@ -242,59 +416,304 @@ type IstioCA struct {
 	// rootCertRotator periodically rotates self-signed root cert for CA. It is nil
 	// if CA is not self-signed CA.
 	rootCertRotator *SelfSignedCARootCertRotator
+
+	// intermediateRenewer periodically renews the intermediate cert for CA. It is nil
+	// if CA is not an intermediate CA.
+	intermediateRenewer *IntermediateCertRenewer
+
+	// rootRotation rotates the root of a self-signed CA in two phases. It is nil if
+	// two-phase root rotation is disabled. It is not started by Run, see RootRotation.
+	rootRotation *RootRotationOrchestrator
+
+	// stateRecorder records the operational state of the CA. It is nil if
+	// the state is not recorded.
+	stateRecorder CAStateRecorder
+
+	// signer signs certificates with an external private key. It is nil if the
+	// private key is in keyCertBundle.
+	signer crypto.Signer
+
+	// policyIdentifiers and extraExtensions are included in issued certs.
+	policyIdentifiers []asn1.ObjectIdentifier
+	extraExtensions   []pkix.Extension
+
+	// crlDistributionPoints, ocspServers and issuingCertificateURLs are the CDP and AIA URLs
+	// included in issued certs.
+	crlDistributionPoints  []string
+	ocspServers            []string
+	issuingCertificateURLs []string
+
+	// csrValidation configures the checks applied to CSRs before signing.
+	csrValidation *CSRValidationOptions
+
+	// dualUse sets the common name of the issued workload certs to their first SAN.
+	dualUse bool
+	// pkcs8Key encodes the keys generated by GenKeyCert in PKCS#8.
+	pkcs8Key bool
+
+	// ctSubmitter submits issued certs to CT logs. It is nil if CT submission is disabled.
+	ctSubmitter *ct.Submitter
+
+	// pluggedCertWatcher reloads the plugged-in CA key/cert files when they change. It is nil
+	// if CA is not a plugged-cert CA or watching is disabled.
+	pluggedCertWatcher *PluggedCertWatcher
+
+	// sanPolicy restricts which identities may request which SANs. It is nil if any SAN is allowed.
+	sanPolicy *SANPolicy
+
+	// signatureAlgorithm is the algorithm used to sign issued certs. It is
+	// x509.UnknownSignatureAlgorithm to use the default for the CA key.
+	signatureAlgorithm x509.SignatureAlgorithm
+
+	// subCAMaxPathLen and subCAMaxPathLenZero are the path length constraint of signed CA certs.
+	subCAMaxPathLen     int
+	subCAMaxPathLenZero bool
+
+	// subCAPermittedDNSDomains and subCAPermittedURIDomains are the name constraints of signed CA certs.
+	subCAPermittedDNSDomains []string
+	subCAPermittedURIDomains []string
+
+	// serialNumberGenerator generates the serial numbers of issued certs. It is nil for random serial numbers.
+	serialNumberGenerator SerialNumberGenerator
+
+	// settingsMutex guards the cert TTLs, csrValidation, dualUse and pkcs8Key, which can be changed at runtime.
+	settingsMutex sync.RWMutex
+}
+
+// Settings are the settings of an IstioCA that can be changed while it runs.
+type Settings struct {
+	DefaultCertTTL time.Duration
+	MaxCertTTL     time.Duration
+	// MinCertTTL is the minimum TTL of issued certificates. Zero disables the floor.
+	MinCertTTL time.Duration
+	// CSRValidation configures the checks applied to CSRs before signing. The defaults
+	// from DefaultCSRValidationOptions are used if it is nil.
+	CSRValidation *CSRValidationOptions
+	// DualUse sets the common name of the issued workload certs to their first SAN, as the
+	// common name of the CSR does.
+	DualUse bool
+	// PKCS8Key encodes the keys generated by GenKeyCert in PKCS#8 instead of PKCS#1 or SEC 1.
+	PKCS8Key bool
 }
 
 // NewIstioCA returns a new IstioCA instance.
 func NewIstioCA(opts *IstioCAOptions) (*IstioCA, error) {
+	if opts.MinCertTTL > 0 && opts.MaxCertTTL > 0 && opts.MinCertTTL > opts.MaxCertTTL {
+		return nil, fmt.Errorf("min cert TTL %s is greater than max cert TTL %s", opts.MinCertTTL, opts.MaxCertTTL)
+	}
+	if err := util.ValidateExtraExtensions(opts.CertExtraExtensions); err != nil {
+		return nil, fmt.Errorf("invalid extra cert extensions: %v", err)
+	}
+	for _, urls := range [][]string{opts.CertCRLDistributionPoints, opts.CertOCSPServers, opts.CertIssuingCertificateURLs} {
+		if err := validateCertURLs(urls); err != nil {
+			return nil, err
+		}
+	}
 	ca := &IstioCA{
 		defaultCertTTL: opts.DefaultCertTTL,
 		maxCertTTL:     opts.MaxCertTTL,
+		minCertTTL:     opts.MinCertTTL,
 		keyCertBundle:  opts.KeyCertBundle,
 		livenessProbe:  probe.NewProbe(),
+		stateRecorder:  opts.StateRecorder,
+		signer:         opts.Signer,
+
+		policyIdentifiers:        opts.CertPolicyIdentifiers,
+		extraExtensions:          opts.CertExtraExtensions,
+		csrValidation:            opts.CSRValidation,
+		ctSubmitter:              opts.CTSubmitter,
+		sanPolicy:                opts.SANPolicy,
+		signatureAlgorithm:       opts.SignatureAlgorithm,
+		subCAMaxPathLen:          opts.SubCAMaxPathLen,
+		subCAMaxPathLenZero:      opts.SubCAMaxPathLenZero,
+		subCAPermittedDNSDomains: opts.SubCAPermittedDNSDomains,
+		subCAPermittedURIDomains: opts.SubCAPermittedURIDomains,
+		serialNumberGenerator:    opts.SerialNumberGenerator,
+		crlDistributionPoints:    opts.CertCRLDistributionPoints,
+		ocspServers:              opts.CertOCSPServers,
+		issuingCertificateURLs:   opts.CertIssuingCertificateURLs,
+	}
+	if err := ca.checkSignatureAlgorithm(); err != nil {
+		return nil, err
+	}
+	if ca.csrValidation == nil {
+		ca.csrValidation = DefaultCSRValidationOptions()
+	}
+	if opts.CAVersionOverlap > 0 && ca.keyCertBundle != nil {
+		ca.keyCertBundle.SetOverlapPeriod(opts.CAVersionOverlap)
+	}
+	if ca.stateRecorder != nil && ca.keyCertBundle != nil {
+		ca.stateRecorder.RecordRootCert(ca.keyCertBundle.GetRootCertPem())
 	}
 
 	if opts.CAType == selfSignedCA && opts.RotatorConfig.CheckInterval > time.Duration(0) {
 		ca.rootCertRotator = NewSelfSignedCARootCertRotator(opts.RotatorConfig, ca)
 	}
+	if opts.CAType == selfSignedCA && opts.RootRotationConfig != nil && opts.RotatorConfig.client != nil {
+		rootRotation, err := NewRootRotationOrchestrator(opts.RootRotationConfig, opts.RotatorConfig, ca)
+		if err != nil {
+			return nil, err
+		}
+		ca.rootRotation = rootRotation
+	}
+	if opts.CAType == intermediateCA && opts.IntermediateRenewerConfig.CheckInterval > time.Duration(0) {
+		ca.intermediateRenewer = NewIntermediateCertRenewer(opts.IntermediateRenewerConfig, ca)
+	}
+	if opts.CAType == pluggedCertCA && opts.PluggedCertWatcherConfig != nil && opts.PluggedCertWatcherConfig.Enabled {
+		watcher, err := NewPluggedCertWatcher(opts.PluggedCertWatcherConfig, ca)
+		if err != nil {
+			return nil, err
+		}
+		ca.pluggedCertWatcher = watcher
+	}
 	return ca, nil
 }
 
+// Settings returns the current settings of the CA.
+func (ca *IstioCA) Settings() Settings {
+	ca.settingsMutex.RLock()
+	defer ca.settingsMutex.RUnlock()
+	return Settings{
+		DefaultCertTTL: ca.defaultCertTTL,
+		MaxCertTTL:     ca.maxCertTTL,
+		MinCertTTL:     ca.minCertTTL,
+		CSRValidation:  ca.csrValidation,
+		DualUse:        ca.dualUse,
+		PKCS8Key:       ca.pkcs8Key,
+	}
+}
+
+// UpdateSettings applies settings to the certs signed from now on. The settings are left unchanged if
+// they are invalid.
+func (ca *IstioCA) UpdateSettings(settings Settings) error {
+	if settings.DefaultCertTTL <= 0 || settings.MaxCertTTL <= 0 {
+		return fmt.Errorf("the default and max cert TTLs must be positive")
+	}
+	if settings.DefaultCertTTL > settings.MaxCertTTL {
+		return fmt.Errorf("default cert TTL %s is greater than max cert TTL %s", settings.DefaultCertTTL, settings.MaxCertTTL)
+	}
+	if settings.MinCertTTL > settings.MaxCertTTL {
+		return fmt.Errorf("min cert TTL %s is greater than max cert TTL %s", settings.MinCertTTL, settings.MaxCertTTL)
+	}
+	if settings.CSRValidation == nil {
+		settings.CSRValidation = DefaultCSRValidationOptions()
+	}
+	ca.settingsMutex.Lock()
+	defer ca.settingsMutex.Unlock()
+	ca.defaultCertTTL = settings.DefaultCertTTL
+	ca.maxCertTTL = settings.MaxCertTTL
+	ca.minCertTTL = settings.MinCertTTL
+	ca.csrValidation = settings.CSRValidation
+	ca.dualUse = settings.DualUse
+	ca.pkcs8Key = settings.PKCS8Key
+	return nil
+}
+
 func (ca *IstioCA) Run(stopChan chan struct{}) {
 	if ca.rootCertRotator != nil {
 		// Start root cert rotator in a separate goroutine.
 		go ca.rootCertRotator.Run(stopChan)
 	}
+	if ca.intermediateRenewer != nil {
+		go ca.intermediateRenewer.Run(stopChan)
+	}
+	if ca.pluggedCertWatcher != nil {
+		go ca.pluggedCertWatcher.Run(stopChan)
+	}
 }
 
 // Sign takes a PEM-encoded CSR, subject IDs and lifetime, and returns a signed certificate. If forCA is true,
 // the signed certificate is a CA certificate, otherwise, it is a workload certificate.
 // TODO(myidpt): Add error code to identify the Sign error types.
 func (ca *IstioCA) Sign(csrPEM []byte, subjectIDs []string, requestedLifetime time.Duration, forCA bool) ([]byte, error) {
-	signingCert, signingKey, _, _ := ca.keyCertBundle.GetAll()
-	if signingCert == nil {
+	signingCert, signingKey, certChainBytes, _ := ca.keyCertBundle.GetAll()
+	if signingCert == nil || (signingKey == nil && ca.signer == nil) {
 		return nil, caerror.NewError(caerror.CANotReady, fmt.Errorf("Istio CA is not ready")) // nolint
 	}
+	var key crypto.PrivateKey = ca.signer
+	if ca.signer == nil {
+		key = *signingKey
+	}
+	return ca.sign(csrPEM, subjectIDs, requestedLifetime, forCA, signingCert, key, certChainBytes)
+}
 
-	csr, err := util.ParsePemEncodedCSR(csrPEM)
+func (ca *IstioCA) sign(csrPEM []byte, subjectIDs []string, requestedLifetime time.Duration, forCA bool,
+	signingCert *x509.Certificate, key crypto.PrivateKey, certChainBytes []byte) ([]byte, error) {
+	settings := ca.Settings()
+	csr, err := validateCSR(csrPEM, settings.CSRValidation)
 	if err != nil {
-		return nil, caerror.NewError(caerror.CSRError, err)
+		return nil, err
 	}
 
 	lifetime := requestedLifetime
 	// If the requested requestedLifetime is non-positive, apply the default TTL.
 	if requestedLifetime.Seconds() <= 0 {
-		lifetime = ca.defaultCertTTL
+		lifetime = settings.DefaultCertTTL
 	}
 	// If the requested TTL is greater than maxCertTTL, return an error
-	if requestedLifetime.Seconds() > ca.maxCertTTL.Seconds() {
+	if requestedLifetime.Seconds() > settings.MaxCertTTL.Seconds() {
+		ttlRejectionCounts.With(reasonTag.Value(ttlAboveMax)).Increment()
+		return nil, caerror.NewError(caerror.TTLError, fmt.Errorf(
+			"requested TTL %s is greater than the max allowed TTL %s", requestedLifetime, settings.MaxCertTTL))
+	}
+	// If the resulting lifetime is shorter than minCertTTL, return an error
+	if settings.MinCertTTL > 0 && lifetime < settings.MinCertTTL {
+		ttlRejectionCounts.With(reasonTag.Value(ttlBelowMin)).Increment()
 		return nil, caerror.NewError(caerror.TTLError, fmt.Errorf(
-			"requested TTL %s is greater than the max allowed TTL %s", requestedLifetime, ca.maxCertTTL))
+			"requested TTL %s is less than the min allowed TTL %s", lifetime, settings.MinCertTTL))
 	}
 
-	certBytes, err := util.GenCertFromCSR(csr, signingCert, csr.PublicKey, *signingKey, subjectIDs, lifetime, forCA)
+	if forCA && signingCert.MaxPathLenZero {
+		return nil, caerror.NewError(caerror.CertGenError, fmt.Errorf(
+			"the CA cert has a path length constraint of 0 and cannot sign CA certs"))
+	}
+
+	tmpl, err := util.GenCertTemplateFromCSR(csr, subjectIDs, lifetime, forCA, ca.policyIdentifiers, ca.extraExtensions)
 	if err != nil {
 		return nil, caerror.NewError(caerror.CertGenError, err)
 	}
+	if settings.DualUse && !forCA && tmpl.Subject.CommonName == "" && len(subjectIDs) > 0 {
+		if cn, err := util.DualUseCommonName(subjectIDs[0]); err != nil {
+			pkiCaLog.Warnf("dual-use failed for cert of %v, omitting the CN: %v", subjectIDs, err)
+		} else {
+			tmpl.Subject.CommonName = cn
+		}
+	}
+	if forCA {
+		tmpl.MaxPathLen = ca.subCAMaxPathLen
+		tmpl.MaxPathLenZero = ca.subCAMaxPathLenZero
+		if len(ca.subCAPermittedDNSDomains) > 0 || len(ca.subCAPermittedURIDomains) > 0 {
+			tmpl.PermittedDNSDomains = ca.subCAPermittedDNSDomains
+			tmpl.PermittedURIDomains = ca.subCAPermittedURIDomains
+			tmpl.PermittedDNSDomainsCritical = true
+		}
+	}
+	if ca.signatureAlgorithm != x509.UnknownSignatureAlgorithm {
+		tmpl.SignatureAlgorithm = ca.signatureAlgorithm
+	}
+	tmpl.CRLDistributionPoints = ca.crlDistributionPoints
+	tmpl.OCSPServer = ca.ocspServers
+	tmpl.IssuingCertificateURL = ca.issuingCertificateURLs
+	if ca.serialNumberGenerator != nil {
+		if tmpl.SerialNumber, err = ca.serialNumberGenerator.NextSerialNumber(); err != nil {
+			return nil, caerror.NewError(caerror.CertGenError, err)
+		}
+	}
+	if ca.ctSubmitter != nil && ca.ctSubmitter.EmbedSCTs() {
+		ca.embedSCTs(tmpl, csr.PublicKey, signingCert, key, certChainBytes)
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, tmpl, signingCert, csr.PublicKey, key)
+	if err != nil {
+		return nil, caerror.NewError(caerror.CertGenError, err)
+	}
+	if ca.ctSubmitter != nil && !ca.ctSubmitter.EmbedSCTs() {
+		go ca.submitToCT(certBytes, signingCert, certChainBytes)
+	}
+	if ca.stateRecorder != nil {
+		if issued, err := x509.ParseCertificate(certBytes); err == nil {
+			ca.stateRecorder.RecordIssuedCert(issued.SerialNumber)
+		}
+	}
 
 	block := &pem.Block{
 		Type:  "CERTIFICATE",
@@ -313,11 +732,28 @@ func (ca *IstioCA) SignWithCertChain(csrPEM []byte, subjectIDs []string, ttl tim
 	}
 	chainPem := ca.GetCAKeyCertBundle().GetCertChainPem()
 	if len(chainPem) > 0 {
-		cert = append(cert, chainPem...)
+		cert = orderCertChain(append(cert, chainPem...))
 	}
 	return cert, nil
 }
 
+// orderCertChain orders the PEM-encoded certs from the leaf towards the root. The cert chain of a
+// CA with several intermediate CAs, e.g. root -> policy CA -> issuing CA, may list them in any
+// order. The certs are returned unchanged if they cannot be parsed.
+func orderCertChain(certsPEM []byte) []byte {
+	ordered, err := util.OrderCertChain(certsPEM)
+	if err != nil {
+		return certsPEM
+	}
+	return ordered
+}
+
+// RootRotation returns the two-phase root rotation of the CA, or nil if it is disabled. Unlike the
+// other rotators, it must only run on one istiod replica.
+func (ca *IstioCA) RootRotation() *RootRotationOrchestrator {
+	return ca.rootRotation
+}
+
 // GetCAKeyCertBundle returns the KeyCertBundle for the CA.
 func (ca *IstioCA) GetCAKeyCertBundle() util.KeyCertBundle {
 	return ca.keyCertBundle
@@ -329,18 +765,58 @@ func updateCertInConfigmap(namespace string, client corev1.CoreV1Interface, cert
 	return cmc.InsertCATLSRootCert(certEncoded)
 }
 
+// validateCertURLs returns an error if any of the URLs to include in issued certs is not an
+// absolute http, https or ldap URL.
+func validateCertURLs(urls []string) error {
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return fmt.Errorf("invalid cert URL %q: %v", u, err)
+		}
+		if parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https" && parsed.Scheme != "ldap") {
+			return fmt.Errorf("invalid cert URL %q: must be an absolute http, https or ldap URL", u)
+		}
+	}
+	return nil
+}
+
+// checkSignatureAlgorithm returns an error if the configured signature algorithm cannot be
+// used with the CA key.
+func (ca *IstioCA) checkSignatureAlgorithm() error {
+	if ca.signatureAlgorithm == x509.UnknownSignatureAlgorithm {
+		return nil
+	}
+	var pub crypto.PublicKey
+	if ca.signer != nil {
+		pub = ca.signer.Public()
+	} else if ca.keyCertBundle != nil {
+		if signingCert, _, _, _ := ca.keyCertBundle.GetAll(); signingCert != nil {
+			pub = signingCert.PublicKey
+		}
+	}
+	if pub == nil {
+		return nil
+	}
+	if err := util.CheckSignatureAlgorithm(ca.signatureAlgorithm, pub); err != nil {
+		return fmt.Errorf("invalid CA signature algorithm: %v", err)
+	}
+	return nil
+}
+
 // GenKeyCert() generates a certificate signed by the CA and
 // returns the certificate chain and the private key.
 func (ca *IstioCA) GenKeyCert(hostnames []string, certTTL time.Duration) ([]byte, []byte, error) {
 	opts := util.CertOptions{
 		RSAKeySize: rsaKeySize,
+		PKCS8Key:   ca.Settings().PKCS8Key,
 	}
 
 	// use the type of private key the CA uses to generate an intermediate CA of that type (e.g. CA cert using RSA will
-	// cause intermediate CAs using RSA to be generated)
-	_, signingKey, _, _ := ca.keyCertBundle.GetAll()
-	if util.IsSupportedECPrivateKey(signingKey) {
-		opts.ECSigAlg = util.EcdsaSigAlg
+	// cause intermediate CAs using RSA to be generated). EC keys are generated on the same curve as the CA key.
+	if ca.signer != nil {
+		opts.ECSigAlg = util.ECSigAlgForKey(ca.signer.Public())
+	} else if signingCert, signingKey, _, _ := ca.keyCertBundle.GetAll(); util.IsSupportedECPrivateKey(signingKey) {
+		opts.ECSigAlg = util.ECSigAlgForKey(signingCert.PublicKey)
 	}
 
 	csrPEM, privPEM, err := util.GenCSR(opts)
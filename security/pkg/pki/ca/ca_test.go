@@ -17,18 +17,25 @@ package ca
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/base64"
 	"io/ioutil"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 
 	"istio.io/istio/security/pkg/k8s/configmap"
+	"istio.io/istio/security/pkg/k8s/controller"
 	k8ssecret "istio.io/istio/security/pkg/k8s/secret"
 	caerror "istio.io/istio/security/pkg/pki/error"
 	"istio.io/istio/security/pkg/pki/util"
@@ -371,6 +378,75 @@ func TestCreatePluggedCertCA(t *testing.T) {
 }
 
 // TODO: merge tests for SignCSR.
+func TestCreatePluggedCertCAFromSecret(t *testing.T) {
+	rootCertFile := "../testdata/multilevelpki/root-cert.pem"
+	certChainFile := "../testdata/multilevelpki/int2-cert-chain.pem"
+	signingCertFile := "../testdata/multilevelpki/int2-cert.pem"
+	signingKeyFile := "../testdata/multilevelpki/int2-key.pem"
+	caNamespace := "default"
+
+	readFile := func(name string) []byte {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		return b
+	}
+
+	client := fake.NewSimpleClientset()
+	// Mimic the layout of a cert-manager issued CA secret.
+	caSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cert-manager-ca", Namespace: "cert-manager"},
+		Data: map[string][]byte{
+			"tls.crt":   readFile(signingCertFile),
+			"tls.key":   readFile(signingKeyFile),
+			"chain.crt": readFile(certChainFile),
+			"ca.crt":    readFile(rootCertFile),
+		},
+	}
+	if _, err := client.CoreV1().Secrets("cert-manager").Create(context.TODO(), caSecret, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create secret (error: %s)", err)
+	}
+	source := controller.CASecretSource{
+		Name:          "cert-manager-ca",
+		Namespace:     "cert-manager",
+		CertKey:       "tls.crt",
+		PrivateKeyKey: "tls.key",
+		CertChainKey:  "chain.crt",
+		RootCertKey:   "ca.crt",
+	}
+
+	caopts, err := NewPluggedCertIstioCAOptionsFromSecret(source, "", 30*time.Minute, time.Hour,
+		caNamespace, client.CoreV1())
+	if err != nil {
+		t.Fatalf("Failed to create a plugged-cert CA Options: %v", err)
+	}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Got error while creating plugged-cert CA: %v", err)
+	}
+
+	signingCertBytes, signingKeyBytes, certChainBytes, rootCertBytes := ca.GetCAKeyCertBundle().GetAllPem()
+	if !comparePem(signingCertBytes, signingCertFile) {
+		t.Errorf("Failed to verify loading of signing cert pem.")
+	}
+	if !comparePem(signingKeyBytes, signingKeyFile) {
+		t.Errorf("Failed to verify loading of signing key pem.")
+	}
+	if !comparePem(certChainBytes, certChainFile) {
+		t.Errorf("Failed to verify loading of cert chain pem.")
+	}
+	if !comparePem(rootCertBytes, rootCertFile) {
+		t.Errorf("Failed to verify loading of root cert pem.")
+	}
+
+	source.PrivateKeyKey = "missing.key"
+	if _, err = NewPluggedCertIstioCAOptionsFromSecret(source, "", 30*time.Minute, time.Hour,
+		caNamespace, client.CoreV1()); err == nil {
+		t.Errorf("Expected an error when the private key data key is missing.")
+	}
+}
+
 func TestSignCSRForWorkload(t *testing.T) {
 	subjectID := "spiffe://example.com/ns/foo/sa/bar"
 	cases := map[string]struct {
@@ -517,6 +593,139 @@ func TestSignCSRForCA(t *testing.T) {
 	}
 }
 
+func TestSignCSRForCAWithMaxPathLen(t *testing.T) {
+	baseCA, err := createCA(time.Hour, "")
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	ca, err := NewIstioCA(&IstioCAOptions{
+		DefaultCertTTL:      time.Hour,
+		MaxCertTTL:          time.Hour,
+		KeyCertBundle:       baseCA.GetCAKeyCertBundle(),
+		RotatorConfig:       &SelfSignedCARootCertRotatorConfig{},
+		SubCAMaxPathLenZero: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	csrPEM, keyPEM, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/foo/sa/bar", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := ca.Sign(csrPEM, []string{"spiffe://cluster.local/ns/foo/sa/bar"}, time.Hour, true)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cert.IsCA || cert.MaxPathLen != 0 || !cert.MaxPathLenZero {
+		t.Errorf("Expected a CA cert with a path length constraint of 0, got IsCA %v, MaxPathLen %d",
+			cert.IsCA, cert.MaxPathLen)
+	}
+
+	// The delegated CA can sign workload certs, but no further CA certs.
+	_, _, _, rootCertBytes := ca.GetCAKeyCertBundle().GetAllPem()
+	chain := append(append([]byte{}, certPEM...), ca.GetCAKeyCertBundle().GetCertChainPem()...)
+	bundle, err := util.NewVerifiedKeyCertBundleFromPem(certPEM, keyPEM, chain, rootCertBytes)
+	if err != nil {
+		t.Fatalf("Failed to create the key/cert bundle of the delegated CA: %v", err)
+	}
+	subCA, err := NewIstioCA(&IstioCAOptions{
+		DefaultCertTTL: time.Hour,
+		MaxCertTTL:     time.Hour,
+		KeyCertBundle:  bundle,
+		RotatorConfig:  &SelfSignedCARootCertRotatorConfig{},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create the delegated CA: %v", err)
+	}
+	if _, err := subCA.Sign(csrPEM, []string{"spiffe://cluster.local/ns/foo/sa/bar"}, time.Hour, false); err != nil {
+		t.Errorf("Failed to sign a workload cert with the delegated CA: %v", err)
+	}
+	if _, err := subCA.Sign(csrPEM, []string{"spiffe://cluster.local/ns/foo/sa/bar"}, time.Hour, true); err == nil {
+		t.Error("Expected an error signing a CA cert with the delegated CA")
+	}
+}
+
+func TestSignCSRForCAWithNameConstraints(t *testing.T) {
+	baseCA, err := createCA(time.Hour, "")
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	ca, err := NewIstioCA(&IstioCAOptions{
+		DefaultCertTTL:           time.Hour,
+		MaxCertTTL:               time.Hour,
+		KeyCertBundle:            baseCA.GetCAKeyCertBundle(),
+		RotatorConfig:            &SelfSignedCARootCertRotatorConfig{},
+		SubCAPermittedURIDomains: []string{"cluster.local"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	csrPEM, keyPEM, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/foo/sa/bar", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := ca.Sign(csrPEM, []string{"spiffe://cluster.local/ns/foo/sa/bar"}, time.Hour, true)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cert.PermittedDNSDomainsCritical || len(cert.PermittedURIDomains) != 1 ||
+		cert.PermittedURIDomains[0] != "cluster.local" {
+		t.Fatalf("Expected critical name constraints for cluster.local, got %v", cert.PermittedURIDomains)
+	}
+
+	// Certs of the delegated CA outside of the permitted trust domain fail verification.
+	_, _, _, rootCertBytes := ca.GetCAKeyCertBundle().GetAllPem()
+	chain := append(append([]byte{}, certPEM...), ca.GetCAKeyCertBundle().GetCertChainPem()...)
+	bundle, err := util.NewVerifiedKeyCertBundleFromPem(certPEM, keyPEM, chain, rootCertBytes)
+	if err != nil {
+		t.Fatalf("Failed to create the key/cert bundle of the delegated CA: %v", err)
+	}
+	subCA, err := NewIstioCA(&IstioCAOptions{
+		DefaultCertTTL: time.Hour,
+		MaxCertTTL:     time.Hour,
+		KeyCertBundle:  bundle,
+		RotatorConfig:  &SelfSignedCARootCertRotatorConfig{},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create the delegated CA: %v", err)
+	}
+	workloadCSR, _, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/foo/sa/bar", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, tc := range map[string]struct {
+		san   string
+		valid bool
+	}{
+		"permitted trust domain": {san: "spiffe://cluster.local/ns/foo/sa/bar", valid: true},
+		"other trust domain":     {san: "spiffe://other.domain/ns/foo/sa/bar", valid: false},
+	} {
+		workloadPEM, err := subCA.SignWithCertChain(workloadCSR, []string{tc.san}, time.Hour, false)
+		if err != nil {
+			t.Fatalf("%s: failed to sign with the delegated CA: %v", id, err)
+		}
+		workloadCert, err := util.ParsePemEncodedCertificate(workloadPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+		roots.AppendCertsFromPEM(rootCertBytes)
+		intermediates.AppendCertsFromPEM(workloadPEM)
+		_, err = workloadCert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		if tc.valid != (err == nil) {
+			t.Errorf("%s: unexpected verification result: %v", id, err)
+		}
+	}
+}
+
 func TestSignCSRTTLError(t *testing.T) {
 	subjectID := "spiffe://example.com/ns/foo/sa/bar"
 	cases := map[string]struct {
@@ -563,6 +772,133 @@ func TestSignCSRTTLError(t *testing.T) {
 	}
 }
 
+func TestSignCSRMinTTLError(t *testing.T) {
+	subjectID := "spiffe://example.com/ns/foo/sa/bar"
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Org: "istio.io", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatalf("GenCSR error: %v", err)
+	}
+	ca, err := createCA(2*time.Hour, "")
+	if err != nil {
+		t.Fatalf("createCA error: %v", err)
+	}
+	ca.minCertTTL = 30 * time.Minute
+
+	cert, signErr := ca.Sign(csrPEM, []string{subjectID}, 10*time.Minute, false)
+	if cert != nil {
+		t.Errorf("Expected null cert be obtained a non-null cert.")
+	}
+	expectedErr := "requested TTL 10m0s is less than the min allowed TTL 30m0s"
+	if signErr == nil || signErr.Error() != expectedErr {
+		t.Errorf("Expected error: %s but got error: %v.", expectedErr, signErr)
+	}
+	if signErr.(*caerror.Error).ErrorType() != "TTL_ERROR" {
+		t.Errorf("Expected error type TTL_ERROR but got %s.", signErr.(*caerror.Error).ErrorType())
+	}
+
+	// A non-positive TTL falls back to the default TTL, which satisfies the floor.
+	if _, signErr = ca.Sign(csrPEM, []string{subjectID}, 0, false); signErr != nil {
+		t.Errorf("Unexpected error when signing with the default TTL: %v", signErr)
+	}
+}
+
+func TestNewIstioCAInvalidTTLRange(t *testing.T) {
+	caOpts := &IstioCAOptions{
+		MaxCertTTL: time.Hour,
+		MinCertTTL: 2 * time.Hour,
+	}
+	if _, err := NewIstioCA(caOpts); err == nil {
+		t.Errorf("Expected an error when min cert TTL is greater than max cert TTL.")
+	}
+}
+
+func TestUpdateSettings(t *testing.T) {
+	subjectID := "spiffe://example.com/ns/foo/sa/bar"
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Org: "istio.io", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatalf("GenCSR error: %v", err)
+	}
+	ca, err := createCA(2*time.Hour, "")
+	if err != nil {
+		t.Fatalf("createCA error: %v", err)
+	}
+	if _, err = ca.Sign(csrPEM, []string{subjectID}, 90*time.Minute, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	settings := ca.Settings()
+	settings.MaxCertTTL = time.Hour
+	settings.DefaultCertTTL = 2 * time.Hour
+	if err = ca.UpdateSettings(settings); err == nil {
+		t.Error("Expected an error when default cert TTL is greater than max cert TTL.")
+	}
+	settings.DefaultCertTTL = 30 * time.Minute
+	if err = ca.UpdateSettings(settings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = ca.Sign(csrPEM, []string{subjectID}, 90*time.Minute, false); err == nil {
+		t.Error("Expected the lowered max cert TTL to be applied.")
+	}
+
+	settings.CSRValidation = &CSRValidationOptions{MinRSAKeySize: 4096, MaxCSRSize: DefaultMaxCSRSize, MaxSANs: DefaultMaxCSRSANs}
+	if err = ca.UpdateSettings(settings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = ca.Sign(csrPEM, []string{subjectID}, 0, false); err == nil {
+		t.Error("Expected the CSR with a 2048 bits key to be rejected.")
+	}
+}
+
+func TestUpdateSettingsDualUseAndPKCS8Key(t *testing.T) {
+	subjectID := "spiffe://example.com/ns/foo/sa/bar"
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Org: "istio.io", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatalf("GenCSR error: %v", err)
+	}
+	ca, err := createCA(2*time.Hour, "")
+	if err != nil {
+		t.Fatalf("createCA error: %v", err)
+	}
+	commonName := func() string {
+		certPEM, err := ca.Sign(csrPEM, []string{subjectID}, 0, false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		cert, err := util.ParsePemEncodedCertificate(certPEM)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return cert.Subject.CommonName
+	}
+	if cn := commonName(); cn != "" {
+		t.Errorf("Unexpected common name %q without dual use.", cn)
+	}
+	_, keyPEM, err := ca.GenKeyCert([]string{"istiod.istio-system.svc"}, time.Hour)
+	if err != nil {
+		t.Fatalf("GenKeyCert error: %v", err)
+	}
+	if !strings.Contains(string(keyPEM), "BEGIN RSA PRIVATE KEY") {
+		t.Errorf("Expected a PKCS#1 key, got %s", keyPEM)
+	}
+
+	settings := ca.Settings()
+	settings.DualUse = true
+	settings.PKCS8Key = true
+	if err = ca.UpdateSettings(settings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cn := commonName(); cn != subjectID {
+		t.Errorf("Got common name %q with dual use, expected %q.", cn, subjectID)
+	}
+	_, keyPEM, err = ca.GenKeyCert([]string{"istiod.istio-system.svc"}, time.Hour)
+	if err != nil {
+		t.Fatalf("GenKeyCert error: %v", err)
+	}
+	if !strings.Contains(string(keyPEM), "BEGIN PRIVATE KEY") {
+		t.Errorf("Expected a PKCS#8 key, got %s", keyPEM)
+	}
+}
+
 func TestAppendRootCerts(t *testing.T) {
 	root1 := "root-cert-1"
 	expRootCerts := `root-cert-1
@@ -649,18 +985,35 @@ func TestGenKeyCert(t *testing.T) {
 		certChainFile   string
 		signingCertFile string
 		signingKeyFile  string
+		certs           int
 	}{
 		"RSA cryptography": {
 			rootCertFile:    "../testdata/multilevelpki/root-cert.pem",
 			certChainFile:   "../testdata/multilevelpki/int-cert-chain.pem",
 			signingCertFile: "../testdata/multilevelpki/int-cert.pem",
 			signingKeyFile:  "../testdata/multilevelpki/int-key.pem",
+			certs:           3,
 		},
 		"EC cryptography": {
 			rootCertFile:    "../testdata/multilevelpki/ecc-root-cert.pem",
 			certChainFile:   "../testdata/multilevelpki/ecc-int-cert-chain.pem",
 			signingCertFile: "../testdata/multilevelpki/ecc-int-cert.pem",
 			signingKeyFile:  "../testdata/multilevelpki/ecc-int-key.pem",
+			certs:           3,
+		},
+		"RSA cryptography with two intermediate CAs": {
+			rootCertFile:    "../testdata/multilevelpki/root-cert.pem",
+			certChainFile:   "../testdata/multilevelpki/int2-cert-chain.pem",
+			signingCertFile: "../testdata/multilevelpki/int2-cert.pem",
+			signingKeyFile:  "../testdata/multilevelpki/int2-key.pem",
+			certs:           4,
+		},
+		"EC cryptography with two intermediate CAs": {
+			rootCertFile:    "../testdata/multilevelpki/ecc-root-cert.pem",
+			certChainFile:   "../testdata/multilevelpki/ecc-int2-cert-chain.pem",
+			signingCertFile: "../testdata/multilevelpki/ecc-int2-cert.pem",
+			signingKeyFile:  "../testdata/multilevelpki/ecc-int2-key.pem",
+			certs:           4,
 		},
 	}
 	caNamespace := "default"
@@ -695,8 +1048,22 @@ func TestGenKeyCert(t *testing.T) {
 			t.Errorf("%s: X509KeyPair error: %v", id, err)
 		}
 
-		if len(cert.Certificate) != 3 {
-			t.Errorf("%s: unexpected number of certificates returned: %d (expected 3)", id, len(cert.Certificate))
+		if len(cert.Certificate) != tc.certs {
+			t.Errorf("%s: unexpected number of certificates returned: %d (expected %d)", id, len(cert.Certificate), tc.certs)
+		}
+		// The chain is ordered from the leaf towards the root.
+		for i := 0; i+1 < len(cert.Certificate); i++ {
+			child, err := x509.ParseCertificate(cert.Certificate[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			parent, err := x509.ParseCertificate(cert.Certificate[i+1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := child.CheckSignatureFrom(parent); err != nil {
+				t.Errorf("%s: cert %d is not issued by the next cert: %v", id, i, err)
+			}
 		}
 	}
 }
@@ -772,3 +1139,292 @@ func comparePem(expectedBytes []byte, file string) bool {
 	}
 	return true
 }
+
+// fakeKeyEncryption is a kms.Provider that wraps data keys by reversing them.
+type fakeKeyEncryption struct{}
+
+func (fakeKeyEncryption) Name() string  { return "fake" }
+func (fakeKeyEncryption) KeyID() string { return "fake-key" }
+func (fakeKeyEncryption) WrapKey(dataKey []byte) ([]byte, error) {
+	return reverse(dataKey), nil
+}
+func (fakeKeyEncryption) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	return reverse(wrappedKey), nil
+}
+
+func reverse(in []byte) []byte {
+	out := make([]byte, len(in))
+	for i := range in {
+		out[len(in)-1-i] = in[i]
+	}
+	return out
+}
+
+func TestCreateSelfSignedIstioCAWithKeyEncryption(t *testing.T) {
+	caNamespace := "default"
+	cases := map[string]struct {
+		initSecret *v1.Secret
+	}{
+		"Encrypt key of existing plaintext secret": {
+			initSecret: k8ssecret.BuildSecret("", CASecret, caNamespace,
+				nil, nil, nil, []byte(cert1Pem), []byte(key1Pem), istioCASecretType),
+		},
+		"Encrypt key of new secret": {},
+	}
+
+	for id, tc := range cases {
+		client := fake.NewSimpleClientset()
+		if tc.initSecret != nil {
+			if _, err := client.CoreV1().Secrets(caNamespace).Create(context.TODO(), tc.initSecret,
+				metav1.CreateOptions{}); err != nil {
+				t.Fatalf("%s: failed to create secret (%v)", id, err)
+			}
+		}
+		caopts, err := NewSelfSignedIstioCAOptionsWithKeyEncryption(context.Background(),
+			0, time.Hour, time.Hour, 30*time.Minute, time.Hour, "test.ca.Org", false, caNamespace, -1,
+			client.CoreV1(), "", false, fakeKeyEncryption{})
+		if err != nil {
+			t.Fatalf("%s: failed to create a self-signed CA Options: %v", id, err)
+		}
+		_, keyInMem, _, _ := caopts.KeyCertBundle.GetAllPem()
+		if tc.initSecret != nil && !bytes.Equal(keyInMem, []byte(key1Pem)) {
+			t.Errorf("%s: CA private key in memory does not match the key in the secret", id)
+		}
+
+		caSecret, err := client.CoreV1().Secrets(caNamespace).Get(context.TODO(), CASecret, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: failed to get CA secret (%v)", id, err)
+		}
+		if !controller.IsCASecretKeyEncrypted(caSecret) {
+			t.Errorf("%s: CA private key should be stored encrypted", id)
+		}
+		if bytes.Contains(caSecret.Data[caPrivateKeyID], keyInMem) {
+			t.Errorf("%s: CA secret should not contain the plaintext private key", id)
+		}
+
+		// Restarting the CA loads the encrypted key.
+		caopts, err = NewSelfSignedIstioCAOptionsWithKeyEncryption(context.Background(),
+			0, time.Hour, time.Hour, 30*time.Minute, time.Hour, "test.ca.Org", false, caNamespace, -1,
+			client.CoreV1(), "", false, fakeKeyEncryption{})
+		if err != nil {
+			t.Fatalf("%s: failed to reload a self-signed CA Options: %v", id, err)
+		}
+		if _, reloadedKey, _, _ := caopts.KeyCertBundle.GetAllPem(); !bytes.Equal(reloadedKey, keyInMem) {
+			t.Errorf("%s: reloaded CA private key does not match", id)
+		}
+	}
+}
+
+func TestCAVersionOverlap(t *testing.T) {
+	oldCA, err := createCA(time.Hour, "")
+	if err != nil {
+		t.Fatalf("Failed to create the old CA: %v", err)
+	}
+	newCA, err := createCA(time.Hour, "")
+	if err != nil {
+		t.Fatalf("Failed to create the new CA: %v", err)
+	}
+	oldRootCert := oldCA.GetCAKeyCertBundle().GetRootCertPem()
+
+	ca, err := NewIstioCA(&IstioCAOptions{
+		DefaultCertTTL:   time.Hour,
+		MaxCertTTL:       time.Hour,
+		KeyCertBundle:    oldCA.GetCAKeyCertBundle(),
+		RotatorConfig:    &SelfSignedCARootCertRotatorConfig{},
+		CAVersionOverlap: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	if err = ca.GetCAKeyCertBundle().VerifyAndSetAll(newCA.GetCAKeyCertBundle().GetAllPem()); err != nil {
+		t.Fatalf("Failed to rotate the CA key/cert: %v", err)
+	}
+	if versions := ca.GetCAKeyCertBundle().GetVersions(); len(versions) != 2 {
+		t.Fatalf("Expected 2 CA versions, got %d", len(versions))
+	}
+	if !bytes.Contains(ca.GetCAKeyCertBundle().GetRootCertPem(), oldRootCert) {
+		t.Error("Expected the old root cert to be trusted during the overlap period")
+	}
+}
+
+func TestSignWithCertExtensions(t *testing.T) {
+	policy := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 1234, 1}
+	custom := pkix.Extension{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 1234, 2}, Value: []byte{5, 0}}
+	baseCA, err := createCA(time.Hour, "")
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	ca, err := NewIstioCA(&IstioCAOptions{
+		DefaultCertTTL:        time.Hour,
+		MaxCertTTL:            time.Hour,
+		KeyCertBundle:         baseCA.GetCAKeyCertBundle(),
+		RotatorConfig:         &SelfSignedCARootCertRotatorConfig{},
+		CertPolicyIdentifiers: []asn1.ObjectIdentifier{policy},
+		CertExtraExtensions:   []pkix.Extension{custom},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/foo/sa/bar", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := ca.Sign(csrPEM, []string{"spiffe://cluster.local/ns/foo/sa/bar"}, time.Hour, false)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.PolicyIdentifiers) != 1 || !cert.PolicyIdentifiers[0].Equal(policy) {
+		t.Errorf("Expected policy identifiers [%v], got %v", policy, cert.PolicyIdentifiers)
+	}
+	found := false
+	for _, ext := range cert.Extensions {
+		found = found || ext.Id.Equal(custom.Id)
+	}
+	if !found {
+		t.Errorf("Expected extension %v in the issued cert", custom.Id)
+	}
+
+	if _, err = NewIstioCA(&IstioCAOptions{
+		KeyCertBundle:       baseCA.GetCAKeyCertBundle(),
+		RotatorConfig:       &SelfSignedCARootCertRotatorConfig{},
+		CertExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{2, 5, 29, 17}}},
+	}); err == nil {
+		t.Error("Expected an error for an extra extension overriding the SAN extension")
+	}
+}
+
+func TestSignWithSignatureAlgorithm(t *testing.T) {
+	baseCA, err := createCA(time.Hour, util.EcdsaP384SigAlg)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	ca, err := NewIstioCA(&IstioCAOptions{
+		DefaultCertTTL:     time.Hour,
+		MaxCertTTL:         time.Hour,
+		KeyCertBundle:      baseCA.GetCAKeyCertBundle(),
+		RotatorConfig:      &SelfSignedCARootCertRotatorConfig{},
+		SignatureAlgorithm: x509.ECDSAWithSHA512,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/foo/sa/bar", ECSigAlg: util.EcdsaP384SigAlg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := ca.Sign(csrPEM, []string{"spiffe://cluster.local/ns/foo/sa/bar"}, time.Hour, false)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.SignatureAlgorithm != x509.ECDSAWithSHA512 {
+		t.Errorf("Expected signature algorithm %v, got %v", x509.ECDSAWithSHA512, cert.SignatureAlgorithm)
+	}
+
+	// Generated keys use the curve of the CA key.
+	_, privPEM, err := ca.GenKeyCert([]string{"host1"}, time.Hour)
+	if err != nil {
+		t.Fatalf("GenKeyCert error: %v", err)
+	}
+	key, err := util.ParsePemEncodedKey(privPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ecKey, ok := key.(*ecdsa.PrivateKey); !ok || ecKey.Curve != elliptic.P384() {
+		t.Errorf("Expected a P384 key, got %T", key)
+	}
+
+	if _, err = NewIstioCA(&IstioCAOptions{
+		KeyCertBundle:      baseCA.GetCAKeyCertBundle(),
+		RotatorConfig:      &SelfSignedCARootCertRotatorConfig{},
+		SignatureAlgorithm: x509.SHA384WithRSA,
+	}); err == nil {
+		t.Error("Expected an error for an RSA signature algorithm with an EC CA key")
+	}
+}
+
+func TestSignWithCertURLs(t *testing.T) {
+	baseCA, err := createCA(time.Hour, "")
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	ca, err := NewIstioCA(&IstioCAOptions{
+		DefaultCertTTL:             time.Hour,
+		MaxCertTTL:                 time.Hour,
+		KeyCertBundle:              baseCA.GetCAKeyCertBundle(),
+		RotatorConfig:              &SelfSignedCARootCertRotatorConfig{},
+		CertCRLDistributionPoints:  []string{"http://pki.example.com/istio.crl"},
+		CertOCSPServers:            []string{"http://ocsp.example.com"},
+		CertIssuingCertificateURLs: []string{"http://pki.example.com/istio-ca.crt"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/foo/sa/bar", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := ca.Sign(csrPEM, []string{"spiffe://cluster.local/ns/foo/sa/bar"}, time.Hour, false)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cert.CRLDistributionPoints, []string{"http://pki.example.com/istio.crl"}) {
+		t.Errorf("Unexpected CRL distribution points %v", cert.CRLDistributionPoints)
+	}
+	if !reflect.DeepEqual(cert.OCSPServer, []string{"http://ocsp.example.com"}) {
+		t.Errorf("Unexpected OCSP servers %v", cert.OCSPServer)
+	}
+	if !reflect.DeepEqual(cert.IssuingCertificateURL, []string{"http://pki.example.com/istio-ca.crt"}) {
+		t.Errorf("Unexpected issuing certificate URLs %v", cert.IssuingCertificateURL)
+	}
+
+	for _, invalid := range []string{"pki.example.com/istio.crl", "file:///etc/istio.crl", "http://%zz"} {
+		if _, err = NewIstioCA(&IstioCAOptions{
+			KeyCertBundle:             baseCA.GetCAKeyCertBundle(),
+			RotatorConfig:             &SelfSignedCARootCertRotatorConfig{},
+			CertCRLDistributionPoints: []string{invalid},
+		}); err == nil {
+			t.Errorf("Expected an error for the invalid URL %q", invalid)
+		}
+	}
+}
+
+func TestCreatePluggedCertCAWithEncryptedKey(t *testing.T) {
+	rootCertFile := "../testdata/multilevelpki/root-cert.pem"
+	certChainFile := "../testdata/multilevelpki/int-cert-chain.pem"
+	signingCertFile := "../testdata/multilevelpki/int-cert.pem"
+	signingKeyFile := "../testdata/multilevelpki/int-key-encrypted.pem"
+
+	client := fake.NewSimpleClientset()
+	if _, err := NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, signingKeyFile, rootCertFile,
+		30*time.Minute, time.Hour, "default", client.CoreV1()); err == nil {
+		t.Error("Expected an error loading an encrypted CA key without passphrase")
+	}
+	caopts, err := NewPluggedCertIstioCAOptionsWithKeyPassphrase(certChainFile, signingCertFile, signingKeyFile,
+		rootCertFile, []byte("istio"), 30*time.Minute, time.Hour, "default", client.CoreV1())
+	if err != nil {
+		t.Fatalf("Failed to create a plugged-cert CA Options: %v", err)
+	}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Got error while creating plugged-cert CA: %v", err)
+	}
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/foo/sa/bar", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ca.SignWithCertChain(csrPEM, []string{"spiffe://cluster.local/ns/foo/sa/bar"}, time.Hour, false); err != nil {
+		t.Errorf("Failed to sign with the decrypted CA key: %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"math/big"
+)
+
+// CAStateRecorder records the operational state of an Istio CA, so that the state survives
+// restarts and can be inspected by other tooling.
+type CAStateRecorder interface {
+	// RecordRootCert records the root cert currently used by the CA. A root cert different
+	// from the previously recorded one is recorded as a rotation.
+	RecordRootCert(rootCertPem []byte)
+	// RecordIssuedCert records the serial number of a cert issued by the CA.
+	RecordIssuedCert(serial *big.Int)
+	// RecordSync records that the CA key and cert were synced with the CA secret.
+	RecordSync()
+}
@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+type fakeStateRecorder struct {
+	rootCerts [][]byte
+	serials   []*big.Int
+	syncs     int
+}
+
+func (r *fakeStateRecorder) RecordRootCert(rootCertPem []byte) {
+	r.rootCerts = append(r.rootCerts, rootCertPem)
+}
+
+func (r *fakeStateRecorder) RecordIssuedCert(serial *big.Int) {
+	r.serials = append(r.serials, serial)
+}
+
+func (r *fakeStateRecorder) RecordSync() {
+	r.syncs++
+}
+
+func TestCAStateRecorder(t *testing.T) {
+	ca, err := createCA(time.Hour, "")
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	recorder := &fakeStateRecorder{}
+	opts := &IstioCAOptions{
+		DefaultCertTTL: time.Hour,
+		MaxCertTTL:     time.Hour,
+		KeyCertBundle:  ca.GetCAKeyCertBundle(),
+		RotatorConfig:  &SelfSignedCARootCertRotatorConfig{},
+		StateRecorder:  recorder,
+	}
+	if ca, err = NewIstioCA(opts); err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	if len(recorder.rootCerts) != 1 || !bytes.Equal(recorder.rootCerts[0], ca.GetCAKeyCertBundle().GetRootCertPem()) {
+		t.Errorf("Root cert should be recorded when the CA is created")
+	}
+
+	csrPEM, _, err := util.GenCSR(util.CertOptions{RSAKeySize: 2048})
+	if err != nil {
+		t.Fatalf("GenCSR error: %v", err)
+	}
+	certPEM, err := ca.Sign(csrPEM, []string{"spiffe://cluster.local/ns/foo/sa/bar"}, time.Hour, false)
+	if err != nil {
+		t.Fatalf("Sign error: %v", err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		t.Fatalf("ParsePemEncodedCertificate error: %v", err)
+	}
+	if len(recorder.serials) != 1 || recorder.serials[0].Cmp(cert.SerialNumber) != 0 {
+		t.Errorf("Serial number of the issued cert should be recorded, got %v", recorder.serials)
+	}
+}
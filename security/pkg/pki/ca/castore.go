@@ -0,0 +1,100 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// ErrCAKeyCertExists is returned by CAKeyCertStore.Store when a CA key and cert are already stored.
+var ErrCAKeyCertExists = errors.New("a CA key and cert are already stored")
+
+// CAKeyCertStore persists the signing key and cert of a self-signed CA outside of
+// the istio-ca-secret Kubernetes secret.
+type CAKeyCertStore interface {
+	// Load returns the stored CA cert and private key. Empty values and a nil error are
+	// returned when nothing has been stored yet.
+	Load() (certPem, keyPem []byte, err error)
+	// Store persists the CA cert and private key if nothing is stored yet, and returns
+	// ErrCAKeyCertExists otherwise, so that concurrent replicas do not overwrite each other's root.
+	Store(certPem, keyPem []byte) error
+}
+
+// NewSelfSignedIstioCAOptionsFromStore returns a new IstioCAOptions instance using a self-signed
+// certificate persisted in store. For the first time the CA is up, it generates a self-signed
+// key/cert pair and writes it to store. For subsequent restarts, the key/cert are read from store.
+// Root cert rotation is disabled, since the rotator keeps its state in istio-ca-secret.
+func NewSelfSignedIstioCAOptionsFromStore(store CAKeyCertStore, caCertTTL, defaultCertTTL,
+	maxCertTTL time.Duration, org string, dualUse bool, namespace string,
+	client corev1.CoreV1Interface, rootCertFile string) (caOpts *IstioCAOptions, err error) {
+	caOpts = &IstioCAOptions{
+		CAType:         selfSignedCA,
+		DefaultCertTTL: defaultCertTTL,
+		MaxCertTTL:     maxCertTTL,
+		RotatorConfig:  &SelfSignedCARootCertRotatorConfig{},
+	}
+
+	pemCert, pemKey, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CA key and cert from store (%v)", err)
+	}
+	if len(pemCert) == 0 || len(pemKey) == 0 {
+		pkiCaLog.Info("No CA key and cert found in store, will create one")
+		options := util.CertOptions{
+			TTL:          caCertTTL,
+			Org:          org,
+			IsCA:         true,
+			IsSelfSigned: true,
+			RSAKeySize:   caKeySize,
+			IsDualUse:    dualUse,
+		}
+		if pemCert, pemKey, err = util.GenCertKeyFromOptions(options); err != nil {
+			return nil, fmt.Errorf("unable to generate CA cert and key for self-signed CA (%v)", err)
+		}
+		if err = store.Store(pemCert, pemKey); err == ErrCAKeyCertExists {
+			// Another replica stored its key and cert first, all the replicas must share the same root.
+			pkiCaLog.Info("CA key and cert were stored concurrently, load them from store")
+			if pemCert, pemKey, err = store.Load(); err != nil {
+				return nil, fmt.Errorf("failed to load CA key and cert from store (%v)", err)
+			}
+			if len(pemCert) == 0 || len(pemKey) == 0 {
+				return nil, fmt.Errorf("no CA key and cert found in store after a conflicting write")
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to write CA key and cert to store (%v)", err)
+		}
+	} else {
+		pkiCaLog.Info("Load signing key and cert from store")
+	}
+
+	rootCerts, err := util.AppendRootCerts(pemCert, rootCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to append root certificates (%v)", err)
+	}
+	if caOpts.KeyCertBundle, err = util.NewVerifiedKeyCertBundleFromPem(pemCert, pemKey, nil, rootCerts); err != nil {
+		return nil, fmt.Errorf("failed to create CA KeyCertBundle (%v)", err)
+	}
+
+	if err = updateCertInConfigmap(namespace, client, caOpts.KeyCertBundle.GetRootCertPem()); err != nil {
+		pkiCaLog.Errorf("Failed to write Citadel cert to configmap (%v). Node agents will not be able to connect.", err)
+	}
+	return caOpts, nil
+}
@@ -0,0 +1,116 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type memoryCAStore struct {
+	cert, key []byte
+	stores    int
+	// concurrent is the key and cert stored by another replica between the Load and the Store.
+	concurrent *memoryCAStore
+}
+
+func (s *memoryCAStore) Load() ([]byte, []byte, error) {
+	return s.cert, s.key, nil
+}
+
+func (s *memoryCAStore) Store(cert, key []byte) error {
+	if s.concurrent != nil {
+		s.cert, s.key = s.concurrent.cert, s.concurrent.key
+		s.concurrent = nil
+	}
+	if len(s.cert) != 0 {
+		return ErrCAKeyCertExists
+	}
+	s.cert, s.key = cert, key
+	s.stores++
+	return nil
+}
+
+func TestCreateSelfSignedIstioCAFromStore(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	store := &memoryCAStore{}
+
+	caopts, err := NewSelfSignedIstioCAOptionsFromStore(store, time.Hour, 30*time.Minute, time.Hour,
+		"test.ca.Org", false, "default", client.CoreV1(), "")
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+	if store.stores != 1 {
+		t.Errorf("Expected the generated key and cert to be stored once, got %d", store.stores)
+	}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Got error while creating self-signed CA: %v", err)
+	}
+	if ca.rootCertRotator != nil {
+		t.Errorf("Root cert rotator should be disabled for store backed CA")
+	}
+	certPem, keyPem, _, _ := ca.GetCAKeyCertBundle().GetAllPem()
+	if !bytes.Equal(certPem, store.cert) || !bytes.Equal(keyPem, store.key) {
+		t.Errorf("CA key and cert do not match the stored key and cert")
+	}
+	// The signing key must not be persisted in a Kubernetes secret.
+	if _, err = client.CoreV1().Secrets("default").Get(context.TODO(), CASecret, metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("Expected %s to not exist, got %v", CASecret, err)
+	}
+
+	// A restarted CA loads the same key and cert.
+	caopts, err = NewSelfSignedIstioCAOptionsFromStore(store, time.Hour, 30*time.Minute, time.Hour,
+		"test.ca.Org", false, "default", client.CoreV1(), "")
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+	if store.stores != 1 {
+		t.Errorf("Expected the existing key and cert to be reused, got %d stores", store.stores)
+	}
+	reloaded, _, _, _ := caopts.KeyCertBundle.GetAllPem()
+	if !bytes.Equal(reloaded, certPem) {
+		t.Errorf("Reloaded CA cert does not match the stored cert")
+	}
+}
+
+func TestCreateSelfSignedIstioCAFromStoreConflict(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	other := &memoryCAStore{}
+	if _, err := NewSelfSignedIstioCAOptionsFromStore(other, time.Hour, 30*time.Minute, time.Hour,
+		"test.ca.Org", false, "default", client.CoreV1(), ""); err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+
+	store := &memoryCAStore{concurrent: other}
+	caopts, err := NewSelfSignedIstioCAOptionsFromStore(store, time.Hour, 30*time.Minute, time.Hour,
+		"test.ca.Org", false, "default", client.CoreV1(), "")
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+	if store.stores != 0 {
+		t.Errorf("Expected the generated key and cert to not be stored, got %d stores", store.stores)
+	}
+	certPem, keyPem, _, _ := caopts.KeyCertBundle.GetAllPem()
+	if !bytes.Equal(certPem, other.cert) || !bytes.Equal(keyPem, other.key) {
+		t.Errorf("CA key and cert do not match the key and cert stored concurrently")
+	}
+}
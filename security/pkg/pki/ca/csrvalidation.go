@@ -0,0 +1,148 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const (
+	// DefaultMinCSRRSAKeySize is the default minimum RSA key size accepted in a CSR.
+	DefaultMinCSRRSAKeySize = 2048
+	// DefaultMaxCSRSize is the default maximum size in bytes of a PEM-encoded CSR.
+	DefaultMaxCSRSize = 64 * 1024
+	// DefaultMaxCSRSANs is the default maximum number of SANs requested in a CSR.
+	DefaultMaxCSRSANs = 100
+)
+
+// CSRValidationOptions configures the checks applied to a CSR before it is signed.
+type CSRValidationOptions struct {
+	// MinRSAKeySize is the minimum accepted RSA key size in bits.
+	MinRSAKeySize int
+	// MaxCSRSize is the maximum accepted size in bytes of the PEM-encoded CSR.
+	MaxCSRSize int
+	// MaxSANs is the maximum number of SANs requested in the CSR.
+	MaxSANs int
+}
+
+// DefaultCSRValidationOptions returns the CSR checks used when none are configured.
+func DefaultCSRValidationOptions() *CSRValidationOptions {
+	return &CSRValidationOptions{
+		MinRSAKeySize: DefaultMinCSRRSAKeySize,
+		MaxCSRSize:    DefaultMaxCSRSize,
+		MaxSANs:       DefaultMaxCSRSANs,
+	}
+}
+
+// allowedCurves are the elliptic curves accepted in a CSR. P-224 is deprecated.
+var allowedCurves = map[elliptic.Curve]bool{
+	elliptic.P256(): true,
+	elliptic.P384(): true,
+	elliptic.P521(): true,
+}
+
+// validateCSR parses the PEM-encoded CSR and rejects malformed or oversized requests, weak keys
+// and malformed SANs. Rejections are counted by reason.
+func validateCSR(csrPEM []byte, opts *CSRValidationOptions) (*x509.CertificateRequest, error) {
+	if opts.MaxCSRSize > 0 && len(csrPEM) > opts.MaxCSRSize {
+		return nil, rejectCSR(csrOversized, caerror.CSRError,
+			fmt.Errorf("CSR size %d exceeds the limit of %d bytes", len(csrPEM), opts.MaxCSRSize))
+	}
+	csr, err := util.ParsePemEncodedCSR(csrPEM)
+	if err != nil {
+		return nil, rejectCSR(csrMalformed, caerror.CSRError, err)
+	}
+	if err = csr.CheckSignature(); err != nil {
+		return nil, rejectCSR(csrBadSignature, caerror.CSRError, fmt.Errorf("invalid CSR signature (%v)", err))
+	}
+	if err = checkCSRKey(csr, opts); err != nil {
+		return nil, rejectCSR(csrWeakKey, caerror.WeakKeyError, err)
+	}
+	if err = checkCSRSANs(csr, opts); err != nil {
+		return nil, rejectCSR(csrBadSAN, caerror.SANError, err)
+	}
+	return csr, nil
+}
+
+func rejectCSR(reason string, t caerror.ErrType, err error) error {
+	csrRejectionCounts.With(reasonTag.Value(reason)).Increment()
+	return caerror.NewError(t, err)
+}
+
+func checkCSRKey(csr *x509.CertificateRequest, opts *CSRValidationOptions) error {
+	switch key := csr.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if size := key.N.BitLen(); size < opts.MinRSAKeySize {
+			return fmt.Errorf("RSA key size %d is less than the minimum of %d", size, opts.MinRSAKeySize)
+		}
+	case *ecdsa.PublicKey:
+		if !allowedCurves[key.Curve] {
+			return fmt.Errorf("elliptic curve %s is not allowed", key.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+	default:
+		return fmt.Errorf("unsupported public key type %T", csr.PublicKey)
+	}
+	return nil
+}
+
+func checkCSRSANs(csr *x509.CertificateRequest, opts *CSRValidationOptions) error {
+	count := len(csr.DNSNames) + len(csr.IPAddresses) + len(csr.URIs) + len(csr.EmailAddresses)
+	if opts.MaxSANs > 0 && count > opts.MaxSANs {
+		return fmt.Errorf("CSR requests %d SANs, more than the limit of %d", count, opts.MaxSANs)
+	}
+	for _, name := range csr.DNSNames {
+		if !isValidDNSName(name) {
+			return fmt.Errorf("malformed DNS SAN %q", name)
+		}
+	}
+	for _, uri := range csr.URIs {
+		if uri.Scheme == "" || (uri.Host == "" && uri.Opaque == "") {
+			return fmt.Errorf("malformed URI SAN %q", uri.String())
+		}
+	}
+	return nil
+}
+
+// isValidDNSName checks the name is made of valid DNS labels. A leading wildcard label is allowed.
+func isValidDNSName(name string) bool {
+	if len(name) == 0 || len(name) > 253 {
+		return false
+	}
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	for i, label := range labels {
+		if i == 0 && label == "*" && len(labels) > 1 {
+			continue
+		}
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
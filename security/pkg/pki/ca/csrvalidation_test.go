@@ -0,0 +1,123 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"testing"
+
+	caerror "istio.io/istio/security/pkg/pki/error"
+)
+
+func genTestCSR(t *testing.T, key crypto.Signer, tmpl *x509.CertificateRequest) []byte {
+	der, err := x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+	if err != nil {
+		t.Fatalf("failed to create CSR: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+func TestValidateCSR(t *testing.T) {
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spiffeID, _ := url.Parse("spiffe://cluster.local/ns/foo/sa/bar")
+	manyNames := []string{}
+	for i := 0; i <= DefaultMaxCSRSANs; i++ {
+		manyNames = append(manyNames, fmt.Sprintf("host%d.example.com", i))
+	}
+	badSignature := genTestCSR(t, rsa2048, &x509.CertificateRequest{URIs: []*url.URL{spiffeID}})
+	block, _ := pem.Decode(badSignature)
+	block.Bytes[len(block.Bytes)-1] ^= 0xff
+	badSignature = pem.EncodeToMemory(block)
+
+	testCases := map[string]struct {
+		csr     []byte
+		errType string
+	}{
+		"RSA 2048": {
+			csr: genTestCSR(t, rsa2048, &x509.CertificateRequest{URIs: []*url.URL{spiffeID}}),
+		},
+		"P-256 with DNS names": {
+			csr: genTestCSR(t, p256, &x509.CertificateRequest{DNSNames: []string{"*.example.com", "istiod.istio-system.svc"}}),
+		},
+		"RSA 1024": {
+			csr:     genTestCSR(t, rsa1024, &x509.CertificateRequest{URIs: []*url.URL{spiffeID}}),
+			errType: "WEAK_KEY_ERROR",
+		},
+		"P-224": {
+			csr:     genTestCSR(t, p224, &x509.CertificateRequest{URIs: []*url.URL{spiffeID}}),
+			errType: "WEAK_KEY_ERROR",
+		},
+		"malformed DNS SAN": {
+			csr:     genTestCSR(t, p256, &x509.CertificateRequest{DNSNames: []string{"-bad-.example.com"}}),
+			errType: "SAN_ERROR",
+		},
+		"too many SANs": {
+			csr:     genTestCSR(t, p256, &x509.CertificateRequest{DNSNames: manyNames}),
+			errType: "SAN_ERROR",
+		},
+		"bad signature": {
+			csr:     badSignature,
+			errType: "CSR_ERROR",
+		},
+		"not a CSR": {
+			csr:     []byte("not a CSR"),
+			errType: "CSR_ERROR",
+		},
+		"oversized": {
+			csr:     make([]byte, DefaultMaxCSRSize+1),
+			errType: "CSR_ERROR",
+		},
+	}
+	for id, tc := range testCases {
+		_, err := validateCSR(tc.csr, DefaultCSRValidationOptions())
+		if tc.errType == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", id, err)
+			}
+			continue
+		}
+		caErr, ok := err.(*caerror.Error)
+		if !ok {
+			t.Errorf("%s: expected a CA error, got %v", id, err)
+			continue
+		}
+		if caErr.ErrorType() != tc.errType {
+			t.Errorf("%s: expected error type %s, got %s (%v)", id, tc.errType, caErr.ErrorType(), err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+
+	"istio.io/istio/security/pkg/pki/ct"
+)
+
+// issuerChainDER returns the DER-encoded issuer chain of certs signed by signingCert,
+// starting with signingCert itself and ordered towards the root.
+func issuerChainDER(signingCert *x509.Certificate, certChainBytes []byte) [][]byte {
+	chain := [][]byte{signingCert.Raw}
+	certChainBytes = orderCertChain(append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: signingCert.Raw}),
+		certChainBytes...))
+	for rest := certChainBytes; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" || string(block.Bytes) == string(signingCert.Raw) {
+			continue
+		}
+		chain = append(chain, block.Bytes)
+	}
+	return chain
+}
+
+// embedSCTs issues a precertificate from tmpl, submits it to the CT logs and adds the
+// returned SCTs to tmpl. On failure, tmpl is left unchanged so issuance is not blocked.
+func (ca *IstioCA) embedSCTs(tmpl *x509.Certificate, publicKey interface{}, signingCert *x509.Certificate,
+	key crypto.PrivateKey, certChainBytes []byte) {
+	precertTmpl := *tmpl
+	precertTmpl.ExtraExtensions = append(append(precertTmpl.ExtraExtensions[:0:0], tmpl.ExtraExtensions...),
+		ct.PrecertPoisonExtension())
+	precert, err := x509.CreateCertificate(rand.Reader, &precertTmpl, signingCert, publicKey, key)
+	if err != nil {
+		pkiCaLog.Warnf("failed to create precertificate, issuing without SCTs: %v", err)
+		return
+	}
+	scts, err := ca.ctSubmitter.AddPreChain(append([][]byte{precert}, issuerChainDER(signingCert, certChainBytes)...))
+	if err != nil {
+		pkiCaLog.Warnf("failed to get SCTs, issuing without SCTs: %v", err)
+		return
+	}
+	ext, err := ct.SCTListExtension(scts)
+	if err != nil {
+		pkiCaLog.Warnf("failed to encode SCTs, issuing without SCTs: %v", err)
+		return
+	}
+	tmpl.ExtraExtensions = append(tmpl.ExtraExtensions, ext)
+}
+
+// submitToCT submits an issued cert to the CT logs. Failures are logged and counted by the
+// submitter, and do not affect issuance.
+func (ca *IstioCA) submitToCT(certBytes []byte, signingCert *x509.Certificate, certChainBytes []byte) {
+	chain := append([][]byte{certBytes}, issuerChainDER(signingCert, certChainBytes)...)
+	if _, err := ca.ctSubmitter.AddChain(chain); err != nil {
+		pkiCaLog.Warnf("failed to submit cert to CT logs: %v", err)
+	}
+}
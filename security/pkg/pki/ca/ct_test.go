@@ -0,0 +1,135 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/istio/security/pkg/pki/ct"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+type fakeCTLog struct {
+	mutex sync.Mutex
+	paths []string
+	fail  bool
+}
+
+func (f *fakeCTLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.paths = append(f.paths, r.URL.Path)
+	if f.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(ct.SignedCertificateTimestamp{
+		ID:        base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)),
+		Timestamp: uint64(time.Now().UnixNano() / int64(time.Millisecond)),
+		Signature: base64.StdEncoding.EncodeToString([]byte{4, 3, 0, 2, 0xab, 0xcd}),
+	})
+}
+
+func (f *fakeCTLog) getPaths() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string{}, f.paths...)
+}
+
+func TestSignWithCTSubmission(t *testing.T) {
+	testCases := map[string]struct {
+		embed        bool
+		fail         bool
+		expectedPath string
+		expectSCTs   bool
+	}{
+		"embed SCTs": {
+			embed:        true,
+			expectedPath: "/ct/v1/add-pre-chain",
+			expectSCTs:   true,
+		},
+		"embed SCTs with log failure": {
+			embed:        true,
+			fail:         true,
+			expectedPath: "/ct/v1/add-pre-chain",
+		},
+		"submit after issuance": {
+			expectedPath: "/ct/v1/add-chain",
+		},
+	}
+	for id, tc := range testCases {
+		fakeLog := &fakeCTLog{fail: tc.fail}
+		server := httptest.NewServer(fakeLog)
+
+		submitter, err := ct.NewSubmitter(ct.Config{Logs: []string{server.URL}, EmbedSCTs: tc.embed})
+		if err != nil {
+			t.Fatalf("%s: failed to create submitter: %v", id, err)
+		}
+		baseCA, err := createCA(time.Hour, "")
+		if err != nil {
+			t.Fatalf("%s: failed to create CA: %v", id, err)
+		}
+		ca, err := NewIstioCA(&IstioCAOptions{
+			DefaultCertTTL: time.Hour,
+			MaxCertTTL:     time.Hour,
+			KeyCertBundle:  baseCA.GetCAKeyCertBundle(),
+			RotatorConfig:  &SelfSignedCARootCertRotatorConfig{},
+			CTSubmitter:    submitter,
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to create CA: %v", id, err)
+		}
+		csrPEM, _, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/foo/sa/bar", RSAKeySize: 2048})
+		if err != nil {
+			t.Fatal(err)
+		}
+		certPEM, err := ca.Sign(csrPEM, []string{"spiffe://cluster.local/ns/foo/sa/bar"}, time.Hour, false)
+		if err != nil {
+			t.Fatalf("%s: failed to sign: %v", id, err)
+		}
+		cert, err := util.ParsePemEncodedCertificate(certPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hasSCTs := false
+		for _, ext := range cert.Extensions {
+			if ext.Id.Equal(ct.OIDPrecertPoison) {
+				t.Errorf("%s: issued cert contains the precertificate poison extension", id)
+			}
+			hasSCTs = hasSCTs || ext.Id.Equal(ct.OIDSCTList)
+		}
+		if hasSCTs != tc.expectSCTs {
+			t.Errorf("%s: expected embedded SCTs %v, got %v", id, tc.expectSCTs, hasSCTs)
+		}
+
+		var paths []string
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if paths = fakeLog.getPaths(); len(paths) > 0 {
+				break
+			}
+		}
+		if len(paths) != 1 || paths[0] != tc.expectedPath {
+			t.Errorf("%s: expected a submission to %s, got %v", id, tc.expectedPath, paths)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// NewExternalSignerIstioCAOptions returns a new IstioCAOptions instance using the operator-specified
+// certs, with the CA private key held by signer, e.g. in an HSM. No private key material is loaded
+// into the CA.
+func NewExternalSignerIstioCAOptions(signer crypto.Signer, certChainFile, signingCertFile, rootCertFile string,
+	defaultCertTTL, maxCertTTL time.Duration, namespace string, client corev1.CoreV1Interface) (*IstioCAOptions, error) {
+	certBytes, err := ioutil.ReadFile(signingCertFile)
+	if err != nil {
+		return nil, err
+	}
+	certChainBytes := []byte{}
+	if len(certChainFile) != 0 {
+		if certChainBytes, err = ioutil.ReadFile(certChainFile); err != nil {
+			return nil, err
+		}
+	}
+	rootCertBytes, err := ioutil.ReadFile(rootCertFile)
+	if err != nil {
+		return nil, err
+	}
+	return newExternalSignerIstioCAOptions(signer, certBytes, certChainBytes, rootCertBytes,
+		defaultCertTTL, maxCertTTL, namespace, client)
+}
+
+func newExternalSignerIstioCAOptions(signer crypto.Signer, certBytes, certChainBytes, rootCertBytes []byte,
+	defaultCertTTL, maxCertTTL time.Duration, namespace string, client corev1.CoreV1Interface) (*IstioCAOptions, error) {
+	if err := verifySigningCertIsCA(certBytes); err != nil {
+		return nil, err
+	}
+	cert, err := util.ParsePemEncodedCertificate(certBytes)
+	if err != nil {
+		return nil, err
+	}
+	// The public keys are compared in their DER encoding, the Equal methods of the keys require Go 1.15.
+	signerKey, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the public key of the signer: %v", err)
+	}
+	certKey, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the public key of the CA cert: %v", err)
+	}
+	if !bytes.Equal(signerKey, certKey) {
+		return nil, fmt.Errorf("the public key of the signer does not match the CA cert")
+	}
+	bundle, err := util.NewVerifiedCertBundleFromPem(certBytes, certChainBytes, rootCertBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA KeyCertBundle (%v)", err)
+	}
+	caOpts := &IstioCAOptions{
+		CAType:         pluggedCertCA,
+		DefaultCertTTL: defaultCertTTL,
+		MaxCertTTL:     maxCertTTL,
+		KeyCertBundle:  bundle,
+		Signer:         signer,
+	}
+	updatePluggedCertInConfigmap(namespace, client, caOpts.KeyCertBundle)
+	return caOpts, nil
+}
@@ -0,0 +1,98 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"io"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// opaqueSigner hides the private key behind crypto.Signer, like an HSM-backed signer.
+type opaqueSigner struct {
+	signer crypto.Signer
+}
+
+func (s *opaqueSigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+func (s *opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.signer.Sign(rand, digest, opts)
+}
+
+func TestExternalSignerCA(t *testing.T) {
+	key, err := util.ParsePemEncodedKey([]byte(key1Pem))
+	if err != nil {
+		t.Fatalf("Failed to parse key: %v", err)
+	}
+	signer := &opaqueSigner{signer: key.(crypto.Signer)}
+	client := fake.NewSimpleClientset()
+
+	caopts, err := newExternalSignerIstioCAOptions(signer, []byte(cert1Pem), nil, []byte(cert1Pem),
+		30*time.Minute, time.Hour, "default", client.CoreV1())
+	if err != nil {
+		t.Fatalf("Failed to create external signer CA options: %v", err)
+	}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Failed to create external signer CA: %v", err)
+	}
+	if _, keyPem, _, _ := ca.GetCAKeyCertBundle().GetAllPem(); len(keyPem) != 0 {
+		t.Errorf("KeyCertBundle should not hold private key material")
+	}
+
+	csrPEM, keyPEM, err := util.GenCSR(util.CertOptions{RSAKeySize: 2048})
+	if err != nil {
+		t.Fatalf("GenCSR error: %v", err)
+	}
+	subjectID := "spiffe://cluster.local/ns/foo/sa/bar"
+	certChainPEM, err := ca.SignWithCertChain(csrPEM, []string{subjectID}, time.Hour, false)
+	if err != nil {
+		t.Fatalf("Sign error: %v", err)
+	}
+	fields := &util.VerifyFields{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		Host:        subjectID,
+	}
+	if err = util.VerifyCertificate(keyPEM, certChainPEM, []byte(cert1Pem), fields); err != nil {
+		t.Errorf("Workload cert does not chain to the CA cert: %v", err)
+	}
+
+	if _, _, err = ca.GenKeyCert([]string{"istiod.istio-system.svc"}, time.Hour); err != nil {
+		t.Errorf("GenKeyCert error: %v", err)
+	}
+}
+
+func TestExternalSignerCAKeyMismatch(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleClientset()
+	if _, err = newExternalSignerIstioCAOptions(otherKey, []byte(cert1Pem), nil, []byte(cert1Pem),
+		30*time.Minute, time.Hour, "default", client.CoreV1()); err == nil {
+		t.Errorf("Expected error when the signer does not match the CA cert")
+	}
+}
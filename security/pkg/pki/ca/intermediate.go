@@ -0,0 +1,197 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/security/pkg/pki/util"
+	certutil "istio.io/istio/security/pkg/util"
+	"istio.io/pkg/log"
+)
+
+var intermediateLog = log.RegisterScope("intermediateca", "Intermediate CA log", 0)
+
+// UpstreamCA signs the intermediate CA certificate of an Istio CA running in intermediate mode.
+type UpstreamCA interface {
+	// SignIntermediate signs a PEM-encoded CSR for an intermediate CA certificate with the given
+	// subject IDs and TTL. It returns the signed certificate, the certificate chain starting with
+	// the signed certificate, and the root certificates.
+	SignIntermediate(csrPEM []byte, subjectIDs []string, ttl time.Duration) (certPem, certChainPem, rootCertPem []byte, err error)
+}
+
+// fileUpstreamCA is an UpstreamCA backed by an offline parent CA key/cert loaded from files.
+type fileUpstreamCA struct {
+	bundle util.KeyCertBundle
+}
+
+// NewFileUpstreamCA returns an UpstreamCA that signs intermediate CA certificates with the parent
+// CA key/cert in the given files, e.g. an offline root mounted only on the CA.
+func NewFileUpstreamCA(signingCertFile, signingKeyFile, certChainFile, rootCertFile string) (UpstreamCA, error) {
+	bundle, err := util.NewVerifiedKeyCertBundleFromFile(signingCertFile, signingKeyFile, certChainFile, rootCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load upstream CA (%v)", err)
+	}
+	certBytes, _, _, _ := bundle.GetAllPem()
+	if err = verifySigningCertIsCA(certBytes); err != nil {
+		return nil, err
+	}
+	return &fileUpstreamCA{bundle: bundle}, nil
+}
+
+// SignIntermediate implements UpstreamCA.
+func (u *fileUpstreamCA) SignIntermediate(csrPEM []byte, subjectIDs []string, ttl time.Duration) ([]byte, []byte, []byte, error) {
+	csr, err := util.ParsePemEncodedCSR(csrPEM)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	signingCertPem, _, parentChainPem, rootCertPem := u.bundle.GetAllPem()
+	signingCert, signingKey, _, _ := u.bundle.GetAll()
+	certBytes, err := util.GenCertFromCSR(csr, signingCert, csr.PublicKey, *signingKey, subjectIDs, ttl, true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes})
+	if len(parentChainPem) == 0 {
+		parentChainPem = signingCertPem
+	}
+	chainPem := append(append([]byte{}, certPem...), parentChainPem...)
+	return certPem, chainPem, rootCertPem, nil
+}
+
+// IntermediateCertRenewerConfig holds the configuration of the intermediate CA cert renewer.
+type IntermediateCertRenewerConfig struct {
+	// CheckInterval is the interval to check the remaining lifetime of the intermediate cert.
+	CheckInterval time.Duration
+	// GracePeriodPercentile is the remaining lifetime, as a percentage of the cert TTL, at which
+	// the intermediate cert is renewed.
+	GracePeriodPercentile int
+
+	upstream     UpstreamCA
+	certOptions  util.CertOptions
+	subjectIDs   []string
+	rootCertFile string
+}
+
+// NewIntermediateIstioCAOptions returns a new IstioCAOptions instance for an Istio CA running as an
+// intermediate CA. The intermediate key is generated locally, and the intermediate cert is signed
+// by upstream. The intermediate cert is renewed by upstream before it expires.
+func NewIntermediateIstioCAOptions(upstream UpstreamCA, intermediateCertTTL, defaultCertTTL, maxCertTTL,
+	checkInterval time.Duration, gracePeriodPercentile int, org, trustDomain, rootCertFile, namespace string,
+	client corev1.CoreV1Interface) (caOpts *IstioCAOptions, err error) {
+	renewerConfig := &IntermediateCertRenewerConfig{
+		CheckInterval:         checkInterval,
+		GracePeriodPercentile: gracePeriodPercentile,
+		upstream:              upstream,
+		certOptions: util.CertOptions{
+			TTL:        intermediateCertTTL,
+			Org:        org,
+			RSAKeySize: caKeySize,
+		},
+		subjectIDs:   []string{"spiffe://" + trustDomain},
+		rootCertFile: rootCertFile,
+	}
+	caOpts = &IstioCAOptions{
+		CAType:                    intermediateCA,
+		DefaultCertTTL:            defaultCertTTL,
+		MaxCertTTL:                maxCertTTL,
+		IntermediateRenewerConfig: renewerConfig,
+	}
+	certPem, keyPem, certChainPem, rootCertPem, err := renewerConfig.issueIntermediate()
+	if err != nil {
+		return nil, err
+	}
+	if caOpts.KeyCertBundle, err = util.NewVerifiedKeyCertBundleFromPem(
+		certPem, keyPem, certChainPem, rootCertPem); err != nil {
+		return nil, fmt.Errorf("failed to create CA KeyCertBundle (%v)", err)
+	}
+	intermediateLog.Infof("Intermediate CA cert is issued by the upstream CA")
+
+	updatePluggedCertInConfigmap(namespace, client, caOpts.KeyCertBundle)
+	return caOpts, nil
+}
+
+// issueIntermediate generates a new intermediate key and has the upstream CA sign its cert.
+func (c *IntermediateCertRenewerConfig) issueIntermediate() (certPem, keyPem, certChainPem, rootCertPem []byte, err error) {
+	csrPem, keyPem, err := util.GenCSR(c.certOptions)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to generate intermediate CA CSR (%v)", err)
+	}
+	certPem, certChainPem, rootCertPem, err = c.upstream.SignIntermediate(csrPem, c.subjectIDs, c.certOptions.TTL)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("upstream CA failed to sign intermediate CA cert (%v)", err)
+	}
+	if rootCertPem, err = util.AppendRootCerts(rootCertPem, c.rootCertFile); err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to append root certificates (%v)", err)
+	}
+	return certPem, keyPem, certChainPem, rootCertPem, nil
+}
+
+// IntermediateCertRenewer renews the intermediate CA cert before it expires.
+type IntermediateCertRenewer struct {
+	config        *IntermediateCertRenewerConfig
+	certInspector certutil.CertUtil
+	ca            *IstioCA
+}
+
+// NewIntermediateCertRenewer returns a new IntermediateCertRenewer for ca.
+func NewIntermediateCertRenewer(config *IntermediateCertRenewerConfig, ca *IstioCA) *IntermediateCertRenewer {
+	return &IntermediateCertRenewer{
+		config:        config,
+		certInspector: certutil.NewCertUtil(config.GracePeriodPercentile),
+		ca:            ca,
+	}
+}
+
+// Run periodically checks and renews the intermediate CA cert until stopCh is closed.
+func (r *IntermediateCertRenewer) Run(stopCh chan struct{}) {
+	ticker := time.NewTicker(r.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.checkAndRenew()
+		case <-stopCh:
+			intermediateLog.Info("Received stop signal, so stop the intermediate cert renewer.")
+			return
+		}
+	}
+}
+
+// checkAndRenew renews the intermediate CA cert if it is within the grace period.
+func (r *IntermediateCertRenewer) checkAndRenew() {
+	certPem, _, _, _ := r.ca.GetCAKeyCertBundle().GetAllPem()
+	waitTime, err := r.certInspector.GetWaitTime(certPem, time.Now(), time.Duration(0))
+	if err == nil && waitTime > 0 {
+		intermediateLog.Debugf("Intermediate CA cert is not about to expire, renew in %v", waitTime)
+		return
+	}
+	intermediateLog.Infof("Renew intermediate CA cert: %v", err)
+	certPem, keyPem, certChainPem, rootCertPem, err := r.config.issueIntermediate()
+	if err != nil {
+		intermediateLog.Errorf("Failed to renew intermediate CA cert: %v", err)
+		return
+	}
+	if err = r.ca.GetCAKeyCertBundle().VerifyAndSetAll(certPem, keyPem, certChainPem, rootCertPem); err != nil {
+		intermediateLog.Errorf("Failed to update CA KeyCertBundle with renewed intermediate cert: %v", err)
+		return
+	}
+	intermediateLog.Infof("Intermediate CA cert is renewed, new chain: %s", strings.TrimSpace(string(certChainPem)))
+}
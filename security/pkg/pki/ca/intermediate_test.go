@@ -0,0 +1,132 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const (
+	upstreamRootCertFile    = "../testdata/multilevelpki/root-cert.pem"
+	upstreamCertChainFile   = "../testdata/multilevelpki/int-cert-chain.pem"
+	upstreamSigningCertFile = "../testdata/multilevelpki/int-cert.pem"
+	upstreamSigningKeyFile  = "../testdata/multilevelpki/int-key.pem"
+)
+
+func createIntermediateCAOptions(t *testing.T, gracePeriodPercentile int) *IstioCAOptions {
+	upstream, err := NewFileUpstreamCA(upstreamSigningCertFile, upstreamSigningKeyFile,
+		upstreamCertChainFile, upstreamRootCertFile)
+	if err != nil {
+		t.Fatalf("Failed to create upstream CA: %v", err)
+	}
+	client := fake.NewSimpleClientset()
+	caopts, err := NewIntermediateIstioCAOptions(upstream, 24*time.Hour, time.Hour, 2*time.Hour,
+		time.Minute, gracePeriodPercentile, "istio.io", "cluster.local", "", "default", client.CoreV1())
+	if err != nil {
+		t.Fatalf("Failed to create intermediate CA options: %v", err)
+	}
+	return caopts
+}
+
+func TestIntermediateCASign(t *testing.T) {
+	caopts := createIntermediateCAOptions(t, 50)
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Failed to create intermediate CA: %v", err)
+	}
+	if ca.intermediateRenewer == nil {
+		t.Errorf("Intermediate cert renewer should be created")
+	}
+
+	intermediateCert, _, _, rootCertBytes := ca.GetCAKeyCertBundle().GetAllPem()
+	if !comparePem(rootCertBytes, upstreamRootCertFile) {
+		t.Errorf("Root cert should be the upstream root cert")
+	}
+	cert, err := util.ParsePemEncodedCertificate(intermediateCert)
+	if err != nil {
+		t.Fatalf("Failed to parse intermediate cert: %v", err)
+	}
+	if !cert.IsCA {
+		t.Errorf("Intermediate cert should be a CA cert")
+	}
+	if ttl := cert.NotAfter.Sub(cert.NotBefore); ttl != 24*time.Hour {
+		t.Errorf("Unexpected intermediate cert TTL (expecting %v, actual %v)", 24*time.Hour, ttl)
+	}
+
+	csrPEM, keyPEM, err := util.GenCSR(util.CertOptions{RSAKeySize: 2048})
+	if err != nil {
+		t.Fatalf("GenCSR error: %v", err)
+	}
+	subjectID := "spiffe://cluster.local/ns/foo/sa/bar"
+	certChainPEM, err := ca.SignWithCertChain(csrPEM, []string{subjectID}, time.Hour, false)
+	if err != nil {
+		t.Fatalf("Sign error: %v", err)
+	}
+	fields := &util.VerifyFields{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		Host:        subjectID,
+	}
+	if err = util.VerifyCertificate(keyPEM, certChainPEM, rootCertBytes, fields); err != nil {
+		t.Errorf("Workload cert does not chain to the upstream root: %v", err)
+	}
+}
+
+func TestIntermediateCertRenewal(t *testing.T) {
+	cases := map[string]struct {
+		gracePeriodPercentile int
+		expectRenewal         bool
+	}{
+		"Not within grace period": {
+			gracePeriodPercentile: 10,
+			expectRenewal:         false,
+		},
+		"Within grace period": {
+			gracePeriodPercentile: 100,
+			expectRenewal:         true,
+		},
+	}
+
+	for id, tc := range cases {
+		ca, err := NewIstioCA(createIntermediateCAOptions(t, tc.gracePeriodPercentile))
+		if err != nil {
+			t.Fatalf("%s: failed to create intermediate CA: %v", id, err)
+		}
+		oldCert, oldKey, _, _ := ca.GetCAKeyCertBundle().GetAllPem()
+		ca.intermediateRenewer.checkAndRenew()
+		newCert, newKey, _, _ := ca.GetCAKeyCertBundle().GetAllPem()
+		renewed := !bytes.Equal(oldCert, newCert)
+		if renewed != tc.expectRenewal {
+			t.Errorf("%s: unexpected renewal, expected %v but got %v", id, tc.expectRenewal, renewed)
+		}
+		if renewed == bytes.Equal(oldKey, newKey) {
+			t.Errorf("%s: intermediate key should change if and only if the cert is renewed", id)
+		}
+	}
+}
+
+func TestNewFileUpstreamCAInvalid(t *testing.T) {
+	if _, err := NewFileUpstreamCA("invalid", upstreamSigningKeyFile, upstreamCertChainFile,
+		upstreamRootCertFile); err == nil {
+		t.Errorf("Expected error when the upstream signing cert is missing")
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"crypto/x509"
 	"time"
 
+	pkica "istio.io/istio/security/pkg/pki/ca"
 	caerror "istio.io/istio/security/pkg/pki/error"
 	"istio.io/istio/security/pkg/pki/util"
 	"istio.io/istio/security/pkg/pki/util/mock"
@@ -30,6 +31,16 @@ type FakeCA struct {
 	SignErr       *caerror.Error
 	KeyCertBundle util.KeyCertBundle
 	ReceivedIDs   []string
+	AuthorizeErr  error
+	// AuthorizedRequester and AuthorizedSANs are the arguments of the last call to AuthorizeSANs.
+	AuthorizedRequester string
+	AuthorizedSANs      []string
+}
+
+// AuthorizeSANs records its arguments and returns AuthorizeErr.
+func (ca *FakeCA) AuthorizeSANs(requester string, sans []string) error {
+	ca.AuthorizedRequester, ca.AuthorizedSANs = requester, sans
+	return ca.AuthorizeErr
 }
 
 // Sign returns the SignErr if SignErr is not nil, otherwise, it returns SignedCert.
@@ -53,6 +64,27 @@ func (ca *FakeCA) SignWithCertChain(csr []byte, identities []string, lifetime ti
 	return cert, nil
 }
 
+// SignWithResult returns the SignErr if SignErr is not nil, otherwise, it returns SignedCert with the cert chain
+// and root cert of the KeyCertBundle, and the expiration time and serial number of SignedCert if it can be parsed.
+func (ca *FakeCA) SignWithResult(csr []byte, identities []string, lifetime time.Duration, forCA bool) (
+	*pkica.SignResult, error) {
+	ca.ReceivedIDs = identities
+	if ca.SignErr != nil {
+		return nil, ca.SignErr
+	}
+	_, _, certChain, rootCerts := ca.GetCAKeyCertBundle().GetAll()
+	result := &pkica.SignResult{
+		Leaf:      ca.SignedCert,
+		CertChain: certChain,
+		RootCerts: rootCerts,
+	}
+	if cert, err := util.ParsePemEncodedCertificate(ca.SignedCert); err == nil {
+		result.NotAfter = cert.NotAfter
+		result.SerialNumber = cert.SerialNumber
+	}
+	return result, nil
+}
+
 // GetCAKeyCertBundle returns KeyCertBundle if KeyCertBundle is not nil, otherwise, it returns an empty
 // FakeKeyCertBundle.
 func (ca *FakeCA) GetCAKeyCertBundle() util.KeyCertBundle {
@@ -0,0 +1,128 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+const (
+	reasonLabel = "reason"
+	resultLabel = "result"
+
+	// ttlAboveMax means the requested TTL exceeds the max allowed TTL.
+	ttlAboveMax = "above_max"
+	// ttlBelowMin means the requested TTL is shorter than the min allowed TTL.
+	ttlBelowMin = "below_min"
+
+	// csrMalformed means the CSR cannot be parsed.
+	csrMalformed = "malformed"
+	// csrBadSignature means the CSR signature does not verify.
+	csrBadSignature = "bad_signature"
+	// csrWeakKey means the CSR public key is too weak or of an unsupported type.
+	csrWeakKey = "weak_key"
+	// csrBadSAN means the CSR requests malformed or too many SANs.
+	csrBadSAN = "bad_san"
+	// csrOversized means the CSR exceeds the size limit.
+	csrOversized = "oversized"
+
+	// reloadSuccess means the plugged-in CA files are reloaded.
+	reloadSuccess = "success"
+	// reloadFailure means the plugged-in CA files could not be read or verified.
+	reloadFailure = "failure"
+
+	// rotationSuccess means the root cert is rotated.
+	rotationSuccess = "success"
+	// rotationFailure means the new root cert could not be generated or persisted.
+	rotationFailure = "failure"
+	// rotationRollback means the new root cert was persisted but rolled back.
+	rotationRollback = "rollback"
+
+	// syncSuccess means the key cert bundle is reloaded from istio-ca-secret.
+	syncSuccess = "success"
+	// syncFailure means istio-ca-secret could not be loaded, or the key cert bundle could not be reloaded from it.
+	syncFailure = "failure"
+	// syncSkipped means the key cert bundle is already in sync with istio-ca-secret.
+	syncSkipped = "skipped"
+)
+
+var (
+	reasonTag = monitoring.MustCreateLabel(reasonLabel)
+	resultTag = monitoring.MustCreateLabel(resultLabel)
+
+	ttlRejectionCounts = monitoring.NewSum(
+		"citadel_ca_cert_ttl_rejection_count",
+		"The number of signing requests rejected because the TTL is outside the allowed range.",
+		monitoring.WithLabels(reasonTag),
+	)
+
+	csrRejectionCounts = monitoring.NewSum(
+		"citadel_ca_csr_rejection_count",
+		"The number of signing requests rejected by CSR validation, by reason.",
+		monitoring.WithLabels(reasonTag),
+	)
+
+	pluggedCertReloadCounts = monitoring.NewSum(
+		"citadel_ca_plugged_cert_reload_count",
+		"The number of reloads of changed plugged-in CA key/cert files, by result.",
+		monitoring.WithLabels(resultTag),
+	)
+
+	sanPolicyDenialCounts = monitoring.NewSum(
+		"citadel_ca_san_policy_denial_count",
+		"The number of signing requests denied by the SAN authorization policy.",
+	)
+
+	rootCertRotationCounts = monitoring.NewSum(
+		"citadel_ca_root_cert_rotation_count",
+		"The number of self-signed root cert rotation attempts, by result.",
+		monitoring.WithLabels(resultTag),
+	)
+
+	keyCertBundleSyncCounts = monitoring.NewSum(
+		"citadel_ca_key_cert_bundle_sync_count",
+		"The number of syncs of the CA key cert bundle with istio-ca-secret, by result.",
+		monitoring.WithLabels(resultTag),
+	)
+
+	rootRotationPhase = monitoring.NewGauge(
+		"citadel_ca_root_rotation_phase",
+		"The phase of the two-phase root rotation: 1 while distributing the new root, 2 once completed, -1 if failed.",
+	)
+
+	rootRotationConfirmedRatio = monitoring.NewGauge(
+		"citadel_ca_root_rotation_confirmed_ratio",
+		"The ratio of the root cert ConfigMaps and workload secrets holding the new root of the two-phase root rotation.",
+	)
+
+	keyCertBundleLastSyncTimestamp = monitoring.NewGauge(
+		"citadel_ca_key_cert_bundle_last_sync_timestamp",
+		"The unix timestamp, in seconds, of the last successful sync of the CA key cert bundle with istio-ca-secret.",
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		ttlRejectionCounts,
+		csrRejectionCounts,
+		pluggedCertReloadCounts,
+		rootCertRotationCounts,
+		sanPolicyDenialCounts,
+		keyCertBundleSyncCounts,
+		keyCertBundleLastSyncTimestamp,
+		rootRotationPhase,
+		rootRotationConfirmedRatio,
+	)
+}
@@ -0,0 +1,156 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// defaultPluggedCertWatchDebounce is the default delay after the last change of a plugged-in
+// CA file before the files are reloaded.
+const defaultPluggedCertWatchDebounce = time.Second
+
+// PluggedCertWatcherConfig configures the reload of plugged-in CA key/cert files.
+type PluggedCertWatcherConfig struct {
+	// Enabled turns on watching the files.
+	Enabled bool
+	// Debounce is the delay after the last file change before the files are reloaded.
+	Debounce time.Duration
+
+	certChainFile   string
+	signingCertFile string
+	signingKeyFile  string
+	rootCertFile    string
+	namespace       string
+	client          corev1.CoreV1Interface
+}
+
+// PluggedCertWatcher watches the plugged-in CA key/cert files, and swaps the CA KeyCertBundle
+// when they change. The files are usually mounted from a secret, which is updated atomically by
+// replacing a symlink, so the directories holding the files are watched.
+type PluggedCertWatcher struct {
+	config  *PluggedCertWatcherConfig
+	ca      *IstioCA
+	watcher *fsnotify.Watcher
+}
+
+// NewPluggedCertWatcher returns a new PluggedCertWatcher for ca.
+func NewPluggedCertWatcher(config *PluggedCertWatcherConfig, ca *IstioCA) (*PluggedCertWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher (%v)", err)
+	}
+	dirs := map[string]bool{}
+	for _, f := range []string{config.certChainFile, config.signingCertFile, config.signingKeyFile, config.rootCertFile} {
+		if f == "" {
+			continue
+		}
+		dir := filepath.Dir(f)
+		if dirs[dir] {
+			continue
+		}
+		if err = watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+			return nil, fmt.Errorf("failed to watch %s (%v)", dir, err)
+		}
+		dirs[dir] = true
+	}
+	if config.Debounce <= 0 {
+		config.Debounce = defaultPluggedCertWatchDebounce
+	}
+	return &PluggedCertWatcher{
+		config:  config,
+		ca:      ca,
+		watcher: watcher,
+	}, nil
+}
+
+// Run reloads the files after they change, until stopCh is closed.
+func (w *PluggedCertWatcher) Run(stopCh chan struct{}) {
+	defer w.watcher.Close()
+	var timerC <-chan time.Time
+	for {
+		select {
+		case event := <-w.watcher.Events:
+			pkiCaLog.Debugf("Plugged-in CA file event: %v", event)
+			// Debounce, since updating a mounted secret produces several events.
+			if timerC == nil {
+				timerC = time.After(w.config.Debounce)
+			}
+		case err := <-w.watcher.Errors:
+			pkiCaLog.Errorf("Plugged-in CA file watcher error: %v", err)
+		case <-timerC:
+			timerC = nil
+			if err := w.reload(); err != nil {
+				pluggedCertReloadCounts.With(resultTag.Value(reloadFailure)).Increment()
+				pkiCaLog.Errorf("Failed to reload plugged-in CA files, keep using the current key/cert: %v", err)
+			}
+		case <-stopCh:
+			pkiCaLog.Info("Plugged-in CA file watcher stopped")
+			return
+		}
+	}
+}
+
+// reload verifies the files and swaps the CA KeyCertBundle if they changed.
+func (w *PluggedCertWatcher) reload() error {
+	certBytes, err := ioutil.ReadFile(w.config.signingCertFile)
+	if err != nil {
+		return err
+	}
+	privKeyBytes, err := ioutil.ReadFile(w.config.signingKeyFile)
+	if err != nil {
+		return err
+	}
+	certChainBytes := []byte{}
+	if w.config.certChainFile != "" {
+		if certChainBytes, err = ioutil.ReadFile(w.config.certChainFile); err != nil {
+			return err
+		}
+	}
+	rootCertBytes, err := ioutil.ReadFile(w.config.rootCertFile)
+	if err != nil {
+		return err
+	}
+
+	bundle := w.ca.GetCAKeyCertBundle()
+	oldCert, oldKey, oldChain, oldRoot := bundle.GetAllPem()
+	if bytes.Equal(certBytes, oldCert) && bytes.Equal(privKeyBytes, oldKey) &&
+		bytes.Equal(certChainBytes, oldChain) && bytes.Equal(rootCertBytes, oldRoot) {
+		return nil
+	}
+	if err = verifySigningCertIsCA(certBytes); err != nil {
+		return err
+	}
+	if err = bundle.VerifyAndSetAll(certBytes, privKeyBytes, certChainBytes, rootCertBytes); err != nil {
+		return err
+	}
+	pluggedCertReloadCounts.With(resultTag.Value(reloadSuccess)).Increment()
+	pkiCaLog.Info("Reloaded plugged-in CA key and cert files")
+	if w.ca.stateRecorder != nil {
+		w.ca.stateRecorder.RecordRootCert(bundle.GetRootCertPem())
+	}
+	if w.config.client != nil {
+		updatePluggedCertInConfigmap(w.config.namespace, w.config.client, bundle)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func copyTestFile(t *testing.T, src, dst string) {
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(dst, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPluggedCertWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugged-cert-watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testdata := "../testdata/multilevelpki/"
+	rootCertFile := filepath.Join(dir, "root-cert.pem")
+	certChainFile := filepath.Join(dir, "cert-chain.pem")
+	signingCertFile := filepath.Join(dir, "ca-cert.pem")
+	signingKeyFile := filepath.Join(dir, "ca-key.pem")
+	copyTestFile(t, testdata+"root-cert.pem", rootCertFile)
+	copyTestFile(t, testdata+"int-cert-chain.pem", certChainFile)
+	copyTestFile(t, testdata+"int-cert.pem", signingCertFile)
+	copyTestFile(t, testdata+"int-key.pem", signingKeyFile)
+
+	client := fake.NewSimpleClientset()
+	caopts, err := NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, signingKeyFile, rootCertFile,
+		time.Hour, 2*time.Hour, "default", client.CoreV1())
+	if err != nil {
+		t.Fatalf("Failed to create a plugged-cert CA Options: %v", err)
+	}
+	caopts.PluggedCertWatcherConfig.Enabled = true
+	caopts.PluggedCertWatcherConfig.Debounce = 10 * time.Millisecond
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Got error while creating plugged-cert CA: %v", err)
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	ca.Run(stopCh)
+
+	waitForCert := func(expectedFile string) {
+		expected, err := ioutil.ReadFile(expectedFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if cert, _, _, _ := ca.GetCAKeyCertBundle().GetAllPem(); bytes.Equal(cert, expected) {
+				return
+			}
+		}
+		t.Fatalf("CA signing cert was not reloaded from %s", expectedFile)
+	}
+
+	// A new signing key/cert is picked up.
+	copyTestFile(t, testdata+"int2-cert-chain.pem", certChainFile)
+	copyTestFile(t, testdata+"int2-key.pem", signingKeyFile)
+	copyTestFile(t, testdata+"int2-cert.pem", signingCertFile)
+	waitForCert(testdata + "int2-cert.pem")
+
+	// A key that does not match the cert is rejected, and the current key/cert is kept.
+	copyTestFile(t, testdata+"int-key.pem", signingKeyFile)
+	time.Sleep(200 * time.Millisecond)
+	if _, key, _, _ := ca.GetCAKeyCertBundle().GetAllPem(); !comparePem(key, testdata+"int2-key.pem") {
+		t.Error("Expected the CA to keep the current key after an invalid update")
+	}
+}
@@ -0,0 +1,526 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+
+	"istio.io/istio/security/pkg/audit"
+	"istio.io/istio/security/pkg/k8s/configmap"
+	"istio.io/istio/security/pkg/k8s/controller"
+	"istio.io/istio/security/pkg/notify"
+	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/pkg/log"
+)
+
+var rootRotationLog = log.RegisterScope("rootrotation", "Two-phase CA root rotation log", 0)
+
+const (
+	// RootRotationSecret holds the new root key/cert of a two-phase root rotation, under the same keys
+	// as istio-ca-secret. Creating it starts the rotation, which deletes it once completed.
+	RootRotationSecret = "istio-ca-root-rotation"
+	// RootRotationStatusConfigMap holds the status of the two-phase root rotation.
+	RootRotationStatusConfigMap = "istio-ca-root-rotation-status"
+	// RootRotationStatusKey is the key of the JSON encoded RootRotationStatus in RootRotationStatusConfigMap.
+	RootRotationStatusKey = "status.json"
+
+	// rootRotationSwitchEvent is the audit event of the switch of the signer to the new root.
+	rootRotationSwitchEvent = "root_rotation_switch"
+)
+
+// RootRotationPhase is the phase of a two-phase root rotation.
+type RootRotationPhase string
+
+const (
+	// RootRotationDistributing means the new root is distributed along with the current root, and the
+	// CA waits for the root cert ConfigMaps and workload secrets to confirm it.
+	RootRotationDistributing RootRotationPhase = "Distributing"
+	// RootRotationCompleted means the CA signs with the new root. The previous root stays trusted.
+	RootRotationCompleted RootRotationPhase = "Completed"
+	// RootRotationFailed means the requested new root cannot be used.
+	RootRotationFailed RootRotationPhase = "Failed"
+)
+
+// rootRotationPhaseValues are the values of the citadel_ca_root_rotation_phase metric.
+var rootRotationPhaseValues = map[RootRotationPhase]float64{
+	RootRotationDistributing: 1,
+	RootRotationCompleted:    2,
+	RootRotationFailed:       -1,
+}
+
+// RootRotationConfig configures the two-phase rotation of the root of a self-signed CA.
+type RootRotationConfig struct {
+	// ConfirmFraction is the fraction, in (0, 1], of the root cert ConfigMaps and workload secrets
+	// that must hold the new root before the CA switches to signing with it.
+	ConfirmFraction float64
+	// CheckInterval is how often the rotation request and its propagation are checked.
+	CheckInterval time.Duration
+	// MinDistributionTime is how long the new root is distributed at least before the switch, so that
+	// the workloads not counted in the confirmations also get it.
+	MinDistributionTime time.Duration
+	// RootCertConfigMap is the name of the ConfigMaps distributing the root certs to the namespaces.
+	RootCertConfigMap string
+}
+
+// RootRotationStatus is the status of a two-phase root rotation, written to RootRotationStatusConfigMap.
+type RootRotationStatus struct {
+	Phase RootRotationPhase `json:"phase"`
+	// NewRootCertSHA256 is the hex encoded SHA-256 fingerprint of the new root cert.
+	NewRootCertSHA256 string `json:"newRootCertSHA256,omitempty"`
+	// Confirmed is the number of root cert ConfigMaps and workload secrets holding the new root,
+	// out of Total.
+	Confirmed int `json:"confirmed"`
+	Total     int `json:"total"`
+	// RequiredFraction is the fraction of Total that must confirm the new root before the switch.
+	RequiredFraction float64 `json:"requiredFraction"`
+	// StartTime is when the new root started to be distributed.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// SwitchTime is when the CA switched to signing with the new root.
+	SwitchTime *metav1.Time `json:"switchTime,omitempty"`
+	// Message explains a failure, or why the switch is pending.
+	Message    string      `json:"message,omitempty"`
+	UpdateTime metav1.Time `json:"updateTime"`
+}
+
+// RootRotationOrchestrator rotates the root of a self-signed CA to a new root key/cert in two phases.
+// It first distributes the new root along with the current one, and waits until the configured
+// fraction of the root cert ConfigMaps and workload secrets hold it. It then switches the CA to sign
+// with the new root, keeping the previous root trusted. Both phases are persisted in istio-ca-secret,
+// from which the other istiod replicas reload them, so it must only run on one replica.
+type RootRotationOrchestrator struct {
+	config              *RootRotationConfig
+	rotatorConfig       *SelfSignedCARootCertRotatorConfig
+	caSecretController  *controller.CaSecretController
+	configMapController *configmap.Controller
+	ca                  *IstioCA
+
+	// mutex guards status.
+	mutex  sync.Mutex
+	status *RootRotationStatus
+}
+
+// NewRootRotationOrchestrator returns a new RootRotationOrchestrator of ca. The CA secret is read
+// and written with the client and namespace of rotatorConfig.
+func NewRootRotationOrchestrator(config *RootRotationConfig, rotatorConfig *SelfSignedCARootCertRotatorConfig,
+	ca *IstioCA) (*RootRotationOrchestrator, error) {
+	if config.ConfirmFraction <= 0 || config.ConfirmFraction > 1 {
+		return nil, fmt.Errorf("root rotation confirm fraction %v is not in (0, 1]", config.ConfirmFraction)
+	}
+	if config.CheckInterval <= 0 {
+		return nil, fmt.Errorf("root rotation check interval %v is not positive", config.CheckInterval)
+	}
+	if config.MinDistributionTime < 0 {
+		return nil, fmt.Errorf("root rotation minimum distribution time %v is negative", config.MinDistributionTime)
+	}
+	return &RootRotationOrchestrator{
+		config:        config,
+		rotatorConfig: rotatorConfig,
+		caSecretController: controller.NewCaSecretControllerWithKeyEncryption(rotatorConfig.client,
+			rotatorConfig.keyEncryption),
+		configMapController: configmap.NewController(rotatorConfig.caStorageNamespace, rotatorConfig.client),
+		ca:                  ca,
+	}, nil
+}
+
+// Run checks the rotation request and its propagation every check interval until stopCh is closed.
+func (o *RootRotationOrchestrator) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(o.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		o.check(context.TODO())
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the status of the last checked rotation, or nil if none was requested.
+func (o *RootRotationOrchestrator) Status() *RootRotationStatus {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.status == nil {
+		return nil
+	}
+	status := *o.status
+	return &status
+}
+
+// check advances the requested rotation, if any, and records its status.
+func (o *RootRotationOrchestrator) check(ctx context.Context) {
+	namespace := o.rotatorConfig.caStorageNamespace
+	request, err := o.rotatorConfig.client.Secrets(namespace).Get(ctx, RootRotationSecret, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		rootRotationLog.Errorf("Failed to get the root rotation request %s/%s: %v", namespace, RootRotationSecret, err)
+		return
+	}
+	status := o.advance(ctx, request)
+	status.UpdateTime = metav1.Now()
+
+	o.mutex.Lock()
+	o.status = status
+	o.mutex.Unlock()
+	rootRotationPhase.Record(rootRotationPhaseValues[status.Phase])
+	if status.Total > 0 {
+		rootRotationConfirmedRatio.Record(float64(status.Confirmed) / float64(status.Total))
+	}
+	if err := o.writeStatus(ctx, status); err != nil {
+		rootRotationLog.Errorf("Failed to write the root rotation status: %v", err)
+	}
+	if status.Phase == RootRotationCompleted {
+		if err := o.rotatorConfig.client.Secrets(namespace).Delete(ctx, RootRotationSecret,
+			metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			rootRotationLog.Errorf("Failed to delete the completed root rotation request %s/%s: %v",
+				namespace, RootRotationSecret, err)
+		}
+	}
+}
+
+// advance runs the phase of the rotation to the new root key/cert in request, and returns its status.
+func (o *RootRotationOrchestrator) advance(ctx context.Context, request *v1.Secret) *RootRotationStatus {
+	newCert, newKey := request.Data[caCertID], request.Data[caPrivateKeyID]
+	status := &RootRotationStatus{
+		NewRootCertSHA256: fingerprint(newCert),
+		RequiredFraction:  o.config.ConfirmFraction,
+	}
+	last := o.Status()
+	if last == nil {
+		// Resume the rotation started by a previous leader.
+		last = o.readStatus(ctx)
+	}
+	if last != nil && last.NewRootCertSHA256 == status.NewRootCertSHA256 {
+		status.StartTime = last.StartTime
+	}
+	if err := verifyNewRoot(newCert, newKey); err != nil {
+		status.Phase = RootRotationFailed
+		status.Message = err.Error()
+		return status
+	}
+
+	bundle := o.ca.GetCAKeyCertBundle()
+	cert, key, certChain, rootCerts := bundle.GetAllPem()
+	if bytes.Equal(cert, newCert) {
+		status.Phase = RootRotationCompleted
+		return status
+	}
+
+	// Phase 1: distribute the new root along with the current one.
+	status.Phase = RootRotationDistributing
+	if !containsCerts(rootCerts, newCert) {
+		rootCerts = appendMissingCerts(rootCerts, newCert)
+		if err := bundle.VerifyAndSetAll(cert, key, certChain, rootCerts); err != nil {
+			status.Message = fmt.Sprintf("failed to distribute the new root: %v", err)
+			return status
+		}
+		rootRotationLog.Infof("Distributing the new root cert %s", status.NewRootCertSHA256)
+		notify.Send(notify.RootRotationStarted, "distributing the new root cert %s", status.NewRootCertSHA256)
+	}
+	if err := o.persistRootCerts(rootCerts); err != nil {
+		status.Message = fmt.Sprintf("failed to distribute the new root: %v", err)
+		return status
+	}
+	if status.StartTime == nil {
+		now := metav1.Now()
+		status.StartTime = &now
+	}
+	confirmed, total, err := o.countConfirmations(ctx, newCert)
+	if err != nil {
+		status.Message = fmt.Sprintf("failed to check the propagation of the new root: %v", err)
+		return status
+	}
+	status.Confirmed, status.Total = confirmed, total
+	if total == 0 {
+		status.Message = "waiting for root cert ConfigMaps or workload secrets to confirm the new root"
+		return status
+	}
+	if required := int(math.Ceil(o.config.ConfirmFraction * float64(total))); confirmed < required {
+		status.Message = fmt.Sprintf("waiting for %d more of %d to hold the new root", required-confirmed, total)
+		return status
+	}
+	if remaining := o.config.MinDistributionTime - time.Since(status.StartTime.Time); remaining > 0 {
+		status.Message = fmt.Sprintf("distributing the new root for %v more", remaining.Round(time.Second))
+		return status
+	}
+
+	// Phase 2: switch the signer to the new root, keeping the previous roots trusted.
+	if err := o.switchSigner(newCert, newKey, appendMissingCerts(newCert, rootCerts)); err != nil {
+		rootCertRotationCounts.With(resultTag.Value(rotationFailure)).Increment()
+		notify.Send(notify.RootRotationFailed, "failed to switch to the new root cert %s: %v",
+			status.NewRootCertSHA256, err)
+		status.Message = fmt.Sprintf("failed to switch to the new root: %v", err)
+		return status
+	}
+	now := metav1.Now()
+	status.Phase = RootRotationCompleted
+	status.SwitchTime = &now
+	return status
+}
+
+// persistRootCerts adds rootCerts to the roots of istio-ca-secret, from which the other istiod replicas
+// and the restarted ones reload the roots they distribute.
+func (o *RootRotationOrchestrator) persistRootCerts(rootCerts []byte) error {
+	cfg := o.rotatorConfig
+	caSecret, err := o.caSecretController.LoadCASecretWithRetry(CASecret, cfg.caStorageNamespace,
+		cfg.retryInterval, 30*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to load CA secret %s:%s (%v)", cfg.caStorageNamespace, CASecret, err)
+	}
+	if containsCerts(caSecret.Data[RootCertID], rootCerts) {
+		return nil
+	}
+	caSecret = caSecret.DeepCopy()
+	caSecret.Data[RootCertID] = appendMissingCerts(caSecret.Data[RootCertID], rootCerts)
+	if err = o.caSecretController.UpdateCASecretWithRetry(caSecret, cfg.retryInterval, 30*time.Second); err != nil {
+		return fmt.Errorf("failed to update CA secret (%v)", err)
+	}
+	return nil
+}
+
+// switchSigner makes newCert and newKey the CA key/cert, trusting rootCerts, in istio-ca-secret, in
+// the key cert bundle and in the istio-security ConfigMap.
+func (o *RootRotationOrchestrator) switchSigner(newCert, newKey, rootCerts []byte) error {
+	cfg := o.rotatorConfig
+	if err := util.Verify(newCert, newKey, nil, rootCerts); err != nil {
+		return err
+	}
+	caSecret, err := o.caSecretController.LoadCASecretWithRetry(CASecret, cfg.caStorageNamespace,
+		cfg.retryInterval, 30*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to load CA secret %s:%s (%v)", cfg.caStorageNamespace, CASecret, err)
+	}
+	caSecret = caSecret.DeepCopy()
+	caSecret.Data[caCertID] = newCert
+	caSecret.Data[caPrivateKeyID] = newKey
+	caSecret.Data[RootCertID] = rootCerts
+	if err = o.caSecretController.UpdateCASecretWithRetry(caSecret, cfg.retryInterval, 30*time.Second); err != nil {
+		return fmt.Errorf("failed to update CA secret (%v)", err)
+	}
+	if err = o.ca.GetCAKeyCertBundle().VerifyAndSetAll(newCert, newKey, nil, rootCerts); err != nil {
+		return fmt.Errorf("failed to update CA KeyCertBundle (%v)", err)
+	}
+	certEncoded := base64.StdEncoding.EncodeToString(o.ca.GetCAKeyCertBundle().GetRootCertPem())
+	if err = o.configMapController.InsertCATLSRootCertWithRetry(certEncoded, cfg.retryInterval, 30*time.Second); err != nil {
+		rootRotationLog.Errorf("Failed to write the root certs to configmap (%v)", err)
+	}
+	rootRotationLog.Info("Switched the CA to the new root cert")
+	rootCertRotationCounts.With(resultTag.Value(rotationSuccess)).Increment()
+	notify.Send(notify.RootRotationCompleted, "switched the CA in %s/%s to the new root cert",
+		cfg.caStorageNamespace, CASecret)
+	entry := audit.Entry{
+		Event:     rootRotationSwitchEvent,
+		Decision:  audit.Allow,
+		Origin:    audit.Internal,
+		Reason:    "the new root is propagated",
+		Lifecycle: audit.Rotated,
+	}
+	if cert, err := util.ParsePemEncodedCertificate(newCert); err == nil {
+		entry.SerialNumber = cert.SerialNumber.Text(16)
+		entry.NotAfter = &cert.NotAfter
+	}
+	audit.Record(entry)
+	if o.ca.stateRecorder != nil {
+		o.ca.stateRecorder.RecordRootCert(o.ca.GetCAKeyCertBundle().GetRootCertPem())
+	}
+	return nil
+}
+
+// countConfirmations returns the number of root cert ConfigMaps and Istio workload secrets, in all
+// namespaces, whose root certs include newCert, and their total number.
+func (o *RootRotationOrchestrator) countConfirmations(ctx context.Context, newCert []byte) (confirmed, total int, err error) {
+	client := o.rotatorConfig.client
+	configMaps, err := client.ConfigMaps(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", o.config.RootCertConfigMap).String(),
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list the root cert ConfigMaps: %v", err)
+	}
+	for _, cm := range configMaps.Items {
+		if cm.Name != o.config.RootCertConfigMap {
+			continue
+		}
+		total++
+		if containsCerts([]byte(cm.Data[RootCertID]), newCert) {
+			confirmed++
+		}
+	}
+	secrets, err := client.Secrets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("type", controller.IstioSecretType).String(),
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list the workload secrets: %v", err)
+	}
+	for _, s := range secrets.Items {
+		if s.Type != controller.IstioSecretType {
+			continue
+		}
+		total++
+		if containsCerts(s.Data[RootCertID], newCert) {
+			confirmed++
+		}
+	}
+	return confirmed, total, nil
+}
+
+// writeStatus writes status to RootRotationStatusConfigMap, creating it if needed.
+func (o *RootRotationOrchestrator) writeStatus(ctx context.Context, status *RootRotationStatus) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	namespace := o.rotatorConfig.caStorageNamespace
+	configMaps := o.rotatorConfig.client.ConfigMaps(namespace)
+	cm, err := configMaps.Get(ctx, RootRotationStatusConfigMap, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: RootRotationStatusConfigMap, Namespace: namespace},
+			Data:       map[string]string{RootRotationStatusKey: string(data)},
+		}
+		if _, err = configMaps.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create ConfigMap %s/%s: %v", namespace, RootRotationStatusConfigMap, err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get ConfigMap %s/%s: %v", namespace, RootRotationStatusConfigMap, err)
+	}
+	cm = cm.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[RootRotationStatusKey] = string(data)
+	if _, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update ConfigMap %s/%s: %v", namespace, RootRotationStatusConfigMap, err)
+	}
+	return nil
+}
+
+// readStatus returns the status in RootRotationStatusConfigMap, or nil if it cannot be read.
+func (o *RootRotationOrchestrator) readStatus(ctx context.Context) *RootRotationStatus {
+	namespace := o.rotatorConfig.caStorageNamespace
+	cm, err := o.rotatorConfig.client.ConfigMaps(namespace).Get(ctx, RootRotationStatusConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+	status := &RootRotationStatus{}
+	if err := json.Unmarshal([]byte(cm.Data[RootRotationStatusKey]), status); err != nil {
+		rootRotationLog.Warnf("Ignoring the invalid root rotation status in %s/%s: %v", namespace, RootRotationStatusConfigMap, err)
+		return nil
+	}
+	return status
+}
+
+// verifyNewRoot returns an error if newCert and newKey are not a matching self-signed CA key/cert.
+func verifyNewRoot(newCert, newKey []byte) error {
+	if len(newCert) == 0 || len(newKey) == 0 {
+		return fmt.Errorf("secret %s must hold %s and %s", RootRotationSecret, caCertID, caPrivateKeyID)
+	}
+	if err := verifySigningCertIsCA(newCert); err != nil {
+		return err
+	}
+	if err := util.Verify(newCert, newKey, nil, newCert); err != nil {
+		return fmt.Errorf("invalid new root key/cert: %v", err)
+	}
+	return nil
+}
+
+// caSecretRootCerts returns the root certs of the CA key/cert in caSecret: the CA cert, the certs in
+// rootCertFile, and the previous roots kept in the secret by a two-phase root rotation.
+func caSecretRootCerts(caSecret *v1.Secret, rootCertFile string) ([]byte, error) {
+	rootCerts, err := util.AppendRootCerts(caSecret.Data[caCertID], rootCertFile)
+	if err != nil {
+		return nil, err
+	}
+	return appendMissingCerts(rootCerts, caSecret.Data[RootCertID]), nil
+}
+
+// containsCerts returns true if every cert in certsPem is also in bundlePem.
+func containsCerts(bundlePem, certsPem []byte) bool {
+	bundle := pemCertBlocks(bundlePem)
+	for _, c := range pemCertBlocks(certsPem) {
+		if !containsBlock(bundle, c) {
+			return false
+		}
+	}
+	return len(bundle) > 0
+}
+
+// appendMissingCerts returns the certs in bundlePem followed by the certs in certsPem that are not
+// in bundlePem.
+func appendMissingCerts(bundlePem, certsPem []byte) []byte {
+	bundle := pemCertBlocks(bundlePem)
+	out := append([]byte(nil), bundlePem...)
+	for _, c := range pemCertBlocks(certsPem) {
+		if containsBlock(bundle, c) {
+			continue
+		}
+		if len(out) > 0 && out[len(out)-1] != '\n' {
+			out = append(out, '\n')
+		}
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c})...)
+		bundle = append(bundle, c)
+	}
+	return out
+}
+
+func pemCertBlocks(certsPem []byte) [][]byte {
+	var blocks [][]byte
+	for {
+		var block *pem.Block
+		block, certsPem = pem.Decode(certsPem)
+		if block == nil {
+			return blocks
+		}
+		if block.Type == "CERTIFICATE" {
+			blocks = append(blocks, block.Bytes)
+		}
+	}
+}
+
+func containsBlock(blocks [][]byte, block []byte) bool {
+	for _, b := range blocks {
+		if bytes.Equal(b, block) {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprint returns the hex encoded SHA-256 fingerprint of the first cert in certPem.
+func fingerprint(certPem []byte) string {
+	der := certPem
+	if block, _ := pem.Decode(certPem); block != nil {
+		der = block.Bytes
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,321 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/k8s/controller"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const testRootCertConfigMap = "istio-ca-root-cert"
+
+func newTestRootRotation(t *testing.T, client *fake.Clientset, fraction float64) *RootRotationOrchestrator {
+	t.Helper()
+	opts := getDefaultSelfSignedIstioCAOptions(client)
+	opts.RootRotationConfig = &RootRotationConfig{
+		ConfirmFraction:   fraction,
+		CheckInterval:     time.Minute,
+		RootCertConfigMap: testRootCertConfigMap,
+	}
+	ca, err := NewIstioCA(opts)
+	if err != nil {
+		t.Fatalf("failed to create the CA: %v", err)
+	}
+	return ca.rootRotation
+}
+
+func newTestRoot(t *testing.T) (cert, key []byte) {
+	t.Helper()
+	cert, key, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          "new.ca.Org",
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to generate the new root: %v", err)
+	}
+	return cert, key
+}
+
+func setRootCertConfigMap(t *testing.T, client *fake.Clientset, namespace string, rootCerts []byte) {
+	t.Helper()
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: testRootCertConfigMap, Namespace: namespace},
+		Data:       map[string]string{RootCertID: string(rootCerts)},
+	}
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+	if _, err := configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{}); errors.IsNotFound(err) {
+		_, err = configMaps.Create(context.TODO(), cm, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readRootRotationStatus(t *testing.T, client *fake.Clientset) *RootRotationStatus {
+	t.Helper()
+	cm, err := client.CoreV1().ConfigMaps(caNamespace).Get(context.TODO(), RootRotationStatusConfigMap, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the status ConfigMap: %v", err)
+	}
+	status := &RootRotationStatus{}
+	if err := json.Unmarshal([]byte(cm.Data[RootRotationStatusKey]), status); err != nil {
+		t.Fatalf("failed to parse the status: %v", err)
+	}
+	return status
+}
+
+func TestRootRotation(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rotation := newTestRootRotation(t, client, 0.5)
+	bundle := rotation.ca.GetCAKeyCertBundle()
+	oldCert, _, _, oldRoots := bundle.GetAllPem()
+	newCert, newKey := newTestRoot(t)
+
+	// Without a request, nothing happens.
+	rotation.check(context.TODO())
+	if rotation.Status() != nil {
+		t.Fatalf("got status %+v without a rotation request", rotation.Status())
+	}
+
+	setRootCertConfigMap(t, client, "ns1", oldRoots)
+	setRootCertConfigMap(t, client, "ns2", oldRoots)
+	setRootCertConfigMap(t, client, "ns3", oldRoots)
+	if _, err := client.CoreV1().Secrets("ns1").Create(context.TODO(), &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio.default", Namespace: "ns1"},
+		Type:       controller.IstioSecretType,
+		Data:       map[string][]byte{RootCertID: oldRoots},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoreV1().Secrets(caNamespace).Create(context.TODO(), &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: RootRotationSecret, Namespace: caNamespace},
+		Data:       map[string][]byte{caCertID: newCert, caPrivateKeyID: newKey},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Phase 1: the new root is distributed, the CA keeps signing with the old one.
+	rotation.check(context.TODO())
+	status := readRootRotationStatus(t, client)
+	if status.Phase != RootRotationDistributing || status.Confirmed != 0 || status.Total != 4 ||
+		status.NewRootCertSHA256 != fingerprint(newCert) || status.StartTime == nil {
+		t.Fatalf("unexpected status after distribution: %+v", status)
+	}
+	cert, _, _, roots := bundle.GetAllPem()
+	if string(cert) != string(oldCert) {
+		t.Errorf("the CA switched to the new root before it was confirmed")
+	}
+	if !containsCerts(roots, oldRoots) || !containsCerts(roots, newCert) {
+		t.Errorf("the distributed roots should hold the old and the new root:\n%s", roots)
+	}
+	caSecret, err := client.CoreV1().Secrets(caNamespace).Get(context.TODO(), CASecret, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsCerts(caSecret.Data[RootCertID], newCert) {
+		t.Errorf("the distributed roots should be persisted in istio-ca-secret")
+	}
+
+	// One of four confirmations is not enough.
+	setRootCertConfigMap(t, client, "ns1", roots)
+	rotation.check(context.TODO())
+	if status = readRootRotationStatus(t, client); status.Phase != RootRotationDistributing || status.Confirmed != 1 {
+		t.Fatalf("unexpected status with one confirmation: %+v", status)
+	}
+
+	// Phase 2: with half of them confirmed, the CA switches to the new root.
+	setRootCertConfigMap(t, client, "ns2", roots)
+	rotation.check(context.TODO())
+	status = readRootRotationStatus(t, client)
+	if status.Phase != RootRotationCompleted || status.Confirmed != 2 || status.SwitchTime == nil {
+		t.Fatalf("unexpected status after the switch: %+v", status)
+	}
+	cert, _, _, roots = bundle.GetAllPem()
+	if string(cert) != string(newCert) {
+		t.Errorf("the CA did not switch to the new root")
+	}
+	if !containsCerts(roots, oldRoots) {
+		t.Errorf("the old root should stay trusted after the switch:\n%s", roots)
+	}
+	caSecret, err = client.CoreV1().Secrets(caNamespace).Get(context.TODO(), CASecret, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(caSecret.Data[caCertID]) != string(newCert) {
+		t.Errorf("istio-ca-secret does not hold the new root")
+	}
+	reloaded, err := caSecretRootCerts(caSecret, "")
+	if err != nil || !containsCerts(reloaded, oldRoots) || !containsCerts(reloaded, newCert) {
+		t.Errorf("the roots reloaded from istio-ca-secret should hold the old and the new root (%v)", err)
+	}
+	if _, err := client.CoreV1().Secrets(caNamespace).Get(context.TODO(), RootRotationSecret,
+		metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("the completed rotation request should be deleted, got %v", err)
+	}
+}
+
+func createRootRotationRequest(t *testing.T, client *fake.Clientset, newCert, newKey []byte) {
+	t.Helper()
+	if _, err := client.CoreV1().Secrets(caNamespace).Create(context.TODO(), &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: RootRotationSecret, Namespace: caNamespace},
+		Data:       map[string][]byte{caCertID: newCert, caPrivateKeyID: newKey},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRootRotationWithoutConfirmations(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rotation := newTestRootRotation(t, client, 0.5)
+	oldCert, _, _, _ := rotation.ca.GetCAKeyCertBundle().GetAllPem()
+	newCert, newKey := newTestRoot(t)
+	createRootRotationRequest(t, client, newCert, newKey)
+
+	// Nothing confirms the new root, so the CA must not switch to it.
+	rotation.check(context.TODO())
+	rotation.check(context.TODO())
+	if status := readRootRotationStatus(t, client); status.Phase != RootRotationDistributing || status.Total != 0 {
+		t.Fatalf("unexpected status without anything to confirm the new root: %+v", status)
+	}
+	if cert, _, _, _ := rotation.ca.GetCAKeyCertBundle().GetAllPem(); string(cert) != string(oldCert) {
+		t.Errorf("the CA switched to the new root without any confirmation")
+	}
+}
+
+func TestRootRotationMinDistributionTime(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rotation := newTestRootRotation(t, client, 1)
+	rotation.config.MinDistributionTime = time.Hour
+	_, _, _, oldRoots := rotation.ca.GetCAKeyCertBundle().GetAllPem()
+	newCert, newKey := newTestRoot(t)
+	setRootCertConfigMap(t, client, "ns1", appendMissingCerts(oldRoots, newCert))
+	createRootRotationRequest(t, client, newCert, newKey)
+
+	rotation.check(context.TODO())
+	status := readRootRotationStatus(t, client)
+	if status.Phase != RootRotationDistributing || status.Confirmed != 1 || status.Message == "" {
+		t.Fatalf("unexpected status before the minimum distribution time: %+v", status)
+	}
+
+	// A new leader resumes the rotation from the status ConfigMap.
+	started := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	status.StartTime = &started
+	data, err := json.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm, err := client.CoreV1().ConfigMaps(caNamespace).Get(context.TODO(), RootRotationStatusConfigMap, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.Data[RootRotationStatusKey] = string(data)
+	if _, err = client.CoreV1().ConfigMaps(caNamespace).Update(context.TODO(), cm, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	rotation.status = nil
+
+	rotation.check(context.TODO())
+	if status = readRootRotationStatus(t, client); status.Phase != RootRotationCompleted {
+		t.Fatalf("unexpected status after the minimum distribution time: %+v", status)
+	}
+}
+
+func TestRootRotationReloadedByOtherReplicas(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rotation := newTestRootRotation(t, client, 0.5)
+	replica, err := NewIstioCA(getDefaultSelfSignedIstioCAOptions(client))
+	if err != nil {
+		t.Fatalf("failed to create the CA: %v", err)
+	}
+	_, _, _, oldRoots := rotation.ca.GetCAKeyCertBundle().GetAllPem()
+	newCert, newKey := newTestRoot(t)
+	setRootCertConfigMap(t, client, "ns1", oldRoots)
+	createRootRotationRequest(t, client, newCert, newKey)
+
+	rotation.check(context.TODO())
+	caSecret, err := client.CoreV1().Secrets(caNamespace).Get(context.TODO(), CASecret, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica.rootCertRotator.reloadKeyCertBundle(caSecret)
+	if _, _, _, roots := replica.GetCAKeyCertBundle().GetAllPem(); !containsCerts(roots, newCert) {
+		t.Errorf("the other replica should distribute the new root:\n%s", roots)
+	}
+}
+
+func TestRootRotationInvalidRequest(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rotation := newTestRootRotation(t, client, 1)
+	newCert, _ := newTestRoot(t)
+	_, otherKey := newTestRoot(t)
+	if _, err := client.CoreV1().Secrets(caNamespace).Create(context.TODO(), &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: RootRotationSecret, Namespace: caNamespace},
+		Data:       map[string][]byte{caCertID: newCert, caPrivateKeyID: otherKey},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	rotation.check(context.TODO())
+	if status := readRootRotationStatus(t, client); status.Phase != RootRotationFailed || status.Message == "" {
+		t.Errorf("unexpected status of a mismatched key/cert: %+v", status)
+	}
+	if _, _, _, roots := rotation.ca.GetCAKeyCertBundle().GetAllPem(); containsCerts(roots, newCert) {
+		t.Errorf("an invalid new root should not be distributed")
+	}
+}
+
+func TestNewRootRotationOrchestratorErrors(t *testing.T) {
+	for _, config := range []RootRotationConfig{
+		{ConfirmFraction: 0, CheckInterval: time.Minute},
+		{ConfirmFraction: 1.5, CheckInterval: time.Minute},
+		{ConfirmFraction: 1, CheckInterval: 0},
+		{ConfirmFraction: 1, CheckInterval: time.Minute, MinDistributionTime: -time.Minute},
+	} {
+		opts := getDefaultSelfSignedIstioCAOptions(nil)
+		opts.RootRotationConfig = &config
+		if _, err := NewIstioCA(opts); err == nil {
+			t.Errorf("expected an error for %+v", config)
+		}
+	}
+}
+
+func TestAppendMissingCerts(t *testing.T) {
+	a, _ := newTestRoot(t)
+	b, _ := newTestRoot(t)
+	ab := appendMissingCerts(a, b)
+	if !containsCerts(ab, a) || !containsCerts(ab, b) {
+		t.Fatalf("appended bundle should hold both certs")
+	}
+	if got := appendMissingCerts(ab, b); string(got) != string(ab) {
+		t.Errorf("appending a cert already in the bundle should not change it")
+	}
+	if containsCerts(a, b) || containsCerts(nil, a) {
+		t.Errorf("containsCerts matched a missing cert")
+	}
+}
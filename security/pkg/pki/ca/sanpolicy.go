@@ -0,0 +1,168 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/security/pkg/audit"
+	caerror "istio.io/istio/security/pkg/pki/error"
+)
+
+// sanAuthorizationEvent is the audit event of a SAN authorization decision.
+const sanAuthorizationEvent = "san_authorization"
+
+// SANPolicyRule restricts the SANs matching SANs to the requesters matching Requesters.
+// A pattern is either an exact value, "*", or has a single leading or trailing "*" wildcard,
+// e.g. "*.gateway.example.com" or "spiffe://cluster.local/ns/istio-system/*".
+type SANPolicyRule struct {
+	// SANs are the patterns of the protected SANs.
+	SANs []string `json:"sans"`
+	// Requesters are the patterns of the identities allowed to request the protected SANs.
+	Requesters []string `json:"requesters"`
+}
+
+// SANPolicy decides which authenticated identities may request which SANs. SANs not matching
+// any rule can be requested by anyone. A SAN matching one or more rules can only be requested
+// by a requester matching one of those rules.
+type SANPolicy struct {
+	Rules []SANPolicyRule `json:"rules"`
+}
+
+// NewSANPolicy returns a SANPolicy with the given rules, or an error if a pattern is invalid.
+func NewSANPolicy(rules []SANPolicyRule) (*SANPolicy, error) {
+	for i, r := range rules {
+		if len(r.SANs) == 0 {
+			return nil, fmt.Errorf("rule %d has no SAN", i)
+		}
+		for _, p := range append(append([]string{}, r.SANs...), r.Requesters...) {
+			if err := validatePattern(p); err != nil {
+				return nil, fmt.Errorf("rule %d: %v", i, err)
+			}
+		}
+	}
+	return &SANPolicy{Rules: rules}, nil
+}
+
+// LoadSANPolicyFile reads a SANPolicy from a YAML or JSON file, e.g.
+//
+//	rules:
+//	- sans: ["*.gateway.example.com"]
+//	  requesters: ["spiffe://cluster.local/ns/istio-system/sa/istio-ingressgateway-service-account"]
+func LoadSANPolicyFile(path string) (*SANPolicy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SAN policy file %s (%v)", path, err)
+	}
+	policy := &SANPolicy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse SAN policy file %s (%v)", path, err)
+	}
+	return NewSANPolicy(policy.Rules)
+}
+
+// Authorize returns an error if requester is not allowed to request one of sans.
+func (p *SANPolicy) Authorize(requester string, sans []string) error {
+	for _, san := range sans {
+		protected := false
+		allowed := false
+		for _, r := range p.Rules {
+			if !matchSAN(r.SANs, san) {
+				continue
+			}
+			protected = true
+			if matchAny(r.Requesters, requester) {
+				allowed = true
+				break
+			}
+		}
+		if protected && !allowed {
+			return fmt.Errorf("%q is not allowed to request SAN %q", requester, san)
+		}
+	}
+	return nil
+}
+
+// AuthorizeSANs returns an AuthorizationError if the SAN policy of the CA does not allow requester to
+// request sans. Denials are counted and recorded in the audit log.
+func (ca *IstioCA) AuthorizeSANs(requester string, sans []string) error {
+	if ca.sanPolicy == nil {
+		return nil
+	}
+	if err := ca.sanPolicy.Authorize(requester, sans); err != nil {
+		sanPolicyDenialCounts.Increment()
+		audit.Record(audit.Entry{
+			Event:     sanAuthorizationEvent,
+			Requester: requester,
+			SANs:      sans,
+			Decision:  audit.Deny,
+			Reason:    err.Error(),
+		})
+		pkiCaLog.Warnf("SAN policy denied the request: %v", err)
+		return caerror.NewError(caerror.AuthorizationError, err)
+	}
+	return nil
+}
+
+func validatePattern(p string) error {
+	if p == "" {
+		return fmt.Errorf("empty pattern")
+	}
+	if p == "*" {
+		return nil
+	}
+	if strings.Contains(strings.TrimSuffix(strings.TrimPrefix(p, "*"), "*"), "*") ||
+		(strings.HasPrefix(p, "*") && strings.HasSuffix(p, "*")) {
+		return fmt.Errorf("invalid pattern %q: only a single leading or trailing * is supported", p)
+	}
+	return nil
+}
+
+// matchSAN returns true if san matches one of patterns. DNS names are case insensitive, so DNS SANs
+// are matched ignoring the case. URI SANs, and IP SANs, are matched exactly.
+func matchSAN(patterns []string, san string) bool {
+	if strings.Contains(san, ":") {
+		return matchAny(patterns, san)
+	}
+	lowered := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		lowered = append(lowered, strings.ToLower(p))
+	}
+	return matchAny(lowered, strings.ToLower(san))
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		switch {
+		case p == "*":
+			return true
+		case strings.HasPrefix(p, "*"):
+			if strings.HasSuffix(value, p[1:]) {
+				return true
+			}
+		case strings.HasSuffix(p, "*"):
+			if strings.HasPrefix(value, p[:len(p)-1]) {
+				return true
+			}
+		case p == value:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"istio.io/istio/security/pkg/audit"
+	caerror "istio.io/istio/security/pkg/pki/error"
+)
+
+const gatewaySA = "spiffe://cluster.local/ns/istio-system/sa/istio-ingressgateway-service-account"
+
+func TestSANPolicyAuthorize(t *testing.T) {
+	policy, err := NewSANPolicy([]SANPolicyRule{
+		{SANs: []string{"*.gateway.example.com"}, Requesters: []string{gatewaySA}},
+		{SANs: []string{"spiffe://cluster.local/ns/istio-system/*"}, Requesters: []string{"spiffe://cluster.local/ns/istio-system/*"}},
+		{SANs: []string{"admin.example.com"}},
+		{SANs: []string{"*.Internal.Example.com"}, Requesters: []string{gatewaySA}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the SAN policy: %v", err)
+	}
+
+	testCases := map[string]struct {
+		requester string
+		sans      []string
+		allowed   bool
+	}{
+		"unprotected SAN": {
+			requester: "spiffe://cluster.local/ns/foo/sa/foo",
+			sans:      []string{"spiffe://cluster.local/ns/foo/sa/foo", "foo.example.com"},
+			allowed:   true,
+		},
+		"gateway requests a gateway name": {
+			requester: gatewaySA,
+			sans:      []string{"www.gateway.example.com"},
+			allowed:   true,
+		},
+		"workload requests a gateway name": {
+			requester: "spiffe://cluster.local/ns/foo/sa/foo",
+			sans:      []string{"foo.example.com", "www.gateway.example.com"},
+			allowed:   false,
+		},
+		"workload requests an istio-system identity": {
+			requester: "spiffe://cluster.local/ns/foo/sa/foo",
+			sans:      []string{"spiffe://cluster.local/ns/istio-system/sa/istiod"},
+			allowed:   false,
+		},
+		"istio-system requests an istio-system identity": {
+			requester: gatewaySA,
+			sans:      []string{"spiffe://cluster.local/ns/istio-system/sa/istiod"},
+			allowed:   true,
+		},
+		"workload requests an upper case gateway name": {
+			requester: "spiffe://cluster.local/ns/foo/sa/foo",
+			sans:      []string{"WWW.Gateway.Example.COM"},
+			allowed:   false,
+		},
+		"workload requests a name of an upper case pattern": {
+			requester: "spiffe://cluster.local/ns/foo/sa/foo",
+			sans:      []string{"a.internal.example.com"},
+			allowed:   false,
+		},
+		"gateway requests a name of an upper case pattern": {
+			requester: gatewaySA,
+			sans:      []string{"a.internal.example.com"},
+			allowed:   true,
+		},
+		"workload requests an upper case identity": {
+			requester: "spiffe://cluster.local/ns/foo/sa/foo",
+			sans:      []string{"spiffe://cluster.local/ns/ISTIO-SYSTEM/sa/istiod"},
+			allowed:   true,
+		},
+		"SAN without requesters": {
+			requester: gatewaySA,
+			sans:      []string{"admin.example.com"},
+			allowed:   false,
+		},
+	}
+	for id, tc := range testCases {
+		err := policy.Authorize(tc.requester, tc.sans)
+		if tc.allowed && err != nil {
+			t.Errorf("%s: unexpected error: %v", id, err)
+		} else if !tc.allowed && err == nil {
+			t.Errorf("%s: expected the request to be denied", id)
+		}
+	}
+}
+
+func TestNewSANPolicyInvalidPattern(t *testing.T) {
+	testCases := map[string][]SANPolicyRule{
+		"no SAN":            {{Requesters: []string{"*"}}},
+		"empty pattern":     {{SANs: []string{""}}},
+		"inner wildcard":    {{SANs: []string{"foo.*.example.com"}}},
+		"both wildcards":    {{SANs: []string{"*.example.*"}}},
+		"invalid requester": {{SANs: []string{"foo"}, Requesters: []string{"spiffe://*/ns/foo/*"}}},
+	}
+	for id, rules := range testCases {
+		if _, err := NewSANPolicy(rules); err == nil {
+			t.Errorf("%s: expected an error", id)
+		}
+	}
+}
+
+func TestLoadSANPolicyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sanpolicy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policy.yaml")
+	content := `rules:
+- sans: ["*.gateway.example.com"]
+  requesters: ["` + gatewaySA + `"]
+`
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadSANPolicyFile(path)
+	if err != nil {
+		t.Fatalf("failed to load the SAN policy: %v", err)
+	}
+	if len(policy.Rules) != 1 || policy.Rules[0].Requesters[0] != gatewaySA {
+		t.Errorf("unexpected SAN policy: %+v", policy)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("rules:\n- san: [foo]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSANPolicyFile(path); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
+
+type auditSink struct {
+	entries []audit.Entry
+}
+
+func (s *auditSink) Record(e audit.Entry) {
+	s.entries = append(s.entries, e)
+}
+
+func TestAuthorizeSANs(t *testing.T) {
+	policy, err := NewSANPolicy([]SANPolicyRule{
+		{SANs: []string{"*.gateway.example.com"}, Requesters: []string{gatewaySA}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the SAN policy: %v", err)
+	}
+	sink := &auditSink{}
+	defer audit.RegisterSink(sink)()
+
+	ca := &IstioCA{}
+	if err := ca.AuthorizeSANs("foo", []string{"www.gateway.example.com"}); err != nil {
+		t.Errorf("unexpected error without a SAN policy: %v", err)
+	}
+
+	ca.sanPolicy = policy
+	if err := ca.AuthorizeSANs(gatewaySA, []string{"www.gateway.example.com"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = ca.AuthorizeSANs("foo", []string{"www.gateway.example.com"})
+	if err == nil {
+		t.Fatal("expected the request to be denied")
+	}
+	if err.(*caerror.Error).ErrorType() != "AUTHORIZATION_ERROR" {
+		t.Errorf("unexpected error type %s", err.(*caerror.Error).ErrorType())
+	}
+	if len(sink.entries) != 1 || sink.entries[0].Decision != audit.Deny || sink.entries[0].Requester != "foo" {
+		t.Errorf("unexpected audit entries %+v", sink.entries)
+	}
+}
@@ -16,15 +16,24 @@ package ca
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"math/rand"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
 
+	"istio.io/istio/security/pkg/audit"
 	"istio.io/istio/security/pkg/k8s/configmap"
 	"istio.io/istio/security/pkg/k8s/controller"
+	"istio.io/istio/security/pkg/notify"
+	"istio.io/istio/security/pkg/pki/kms"
 	"istio.io/istio/security/pkg/pki/util"
 	certutil "istio.io/istio/security/pkg/util"
 	"istio.io/pkg/log"
@@ -34,6 +43,9 @@ import (
 
 var rootCertRotatorLog = log.RegisterScope("rootcertrotator", "Self-signed CA root cert rotator log", 0)
 
+// rootCertRotationEvent is the audit event of the rotation of the self-signed root cert.
+const rootCertRotationEvent = "root_cert_rotation"
+
 type SelfSignedCARootCertRotatorConfig struct {
 	certInspector      certutil.CertUtil
 	caStorageNamespace string
@@ -45,6 +57,14 @@ type SelfSignedCARootCertRotatorConfig struct {
 	retryInterval      time.Duration
 	dualUse            bool
 	enableJitter       bool
+
+	// RenewBefore is the remaining lifetime of the root cert below which the root cert is
+	// rotated, regardless of the grace period percentile.
+	RenewBefore time.Duration
+
+	// keyEncryption encrypts the CA private key in istio-ca-secret. It is nil if the key
+	// is stored in plaintext.
+	keyEncryption kms.Provider
 }
 
 // SelfSignedCARootCertRotator automatically checks self-signed signing root
@@ -55,6 +75,9 @@ type SelfSignedCARootCertRotator struct {
 	config              *SelfSignedCARootCertRotatorConfig
 	backOffTime         time.Duration
 	ca                  *IstioCA
+
+	// caSecretInformer watches istio-ca-secret for root certs rotated by other Citadels.
+	caSecretInformer cache.Controller
 }
 
 // NewSelfSignedCARootCertRotator returns a new root cert rotator instance that
@@ -63,10 +86,26 @@ func NewSelfSignedCARootCertRotator(config *SelfSignedCARootCertRotatorConfig,
 	ca *IstioCA) *SelfSignedCARootCertRotator {
 	rotator := &SelfSignedCARootCertRotator{
 		configMapController: configmap.NewController(config.caStorageNamespace, config.client),
-		caSecretController:  controller.NewCaSecretController(config.client),
+		caSecretController:  controller.NewCaSecretControllerWithKeyEncryption(config.client, config.keyEncryption),
 		config:              config,
 		ca:                  ca,
 	}
+	caSecretSelector := fields.OneTermEqualSelector("metadata.name", CASecret).String()
+	_, rotator.caSecretInformer = cache.NewInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = caSecretSelector
+			return config.client.Secrets(config.caStorageNamespace).List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = caSecretSelector
+			return config.client.Secrets(config.caStorageNamespace).Watch(context.TODO(), options)
+		},
+	}, &v1.Secret{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc: rotator.caSecretChanged,
+		UpdateFunc: func(_, newObj interface{}) {
+			rotator.caSecretChanged(newObj)
+		},
+	})
 	if config.enableJitter {
 		// Select a back off time in seconds, which is in the range of [0, rotator.config.CheckInterval).
 		randSource := rand.NewSource(time.Now().UnixNano())
@@ -82,6 +121,7 @@ func NewSelfSignedCARootCertRotator(config *SelfSignedCARootCertRotatorConfig,
 
 // Run refreshes root certs and updates config map accordingly.
 func (rotator *SelfSignedCARootCertRotator) Run(stopCh chan struct{}) {
+	go rotator.caSecretInformer.Run(stopCh)
 	if rotator.config.enableJitter {
 		rootCertRotatorLog.Infof("Jitter is enabled, wait %s before "+
 			"starting root cert rotator.", rotator.backOffTime.String())
@@ -120,6 +160,7 @@ func (rotator *SelfSignedCARootCertRotator) checkAndRotateRootCert() {
 	if scrtErr != nil {
 		rootCertRotatorLog.Errorf("Fail to load CA secret %s:%s (error: %s), skip cert rotation job",
 			rotator.config.caStorageNamespace, CASecret, scrtErr.Error())
+		keyCertBundleSyncCounts.With(resultTag.Value(syncFailure)).Increment()
 	} else {
 		rotator.checkAndRotateRootCertForSigningCertCitadel(caSecret)
 	}
@@ -136,42 +177,16 @@ func (rotator *SelfSignedCARootCertRotator) checkAndRotateRootCertForSigningCert
 		return
 	}
 	// Check root certificate expiration time in CA secret
-	waitTime, err := rotator.config.certInspector.GetWaitTime(caSecret.Data[caCertID], time.Now(), time.Duration(0))
+	waitTime, err := rotator.config.certInspector.GetWaitTime(caSecret.Data[caCertID], time.Now(), rotator.config.RenewBefore)
 	if err == nil && waitTime > 0 {
 		rootCertRotatorLog.Info("Root cert is not about to expire, skipping root cert rotation.")
-		caCertInMem, _, _, _ := rotator.ca.GetCAKeyCertBundle().GetAllPem()
-		// If CA certificate is different from the CA certificate in local key
-		// cert bundle, it implies that other Citadels have updated istio-ca-secret.
-		// Reload root certificate into key cert bundle.
-		if !bytes.Equal(caCertInMem, caSecret.Data[caCertID]) {
-			rootCertRotatorLog.Warn("CA cert in KeyCertBundle does not match CA cert in " +
-				"istio-ca-secret. Start to reload root cert into KeyCertBundle")
-			rootCerts, err := util.AppendRootCerts(caSecret.Data[caCertID], rotator.config.rootCertFile)
-			if err != nil {
-				rootCertRotatorLog.Errorf("failed to append root certificates from file: %s", err.Error())
-				return
-			}
-			if err := rotator.ca.GetCAKeyCertBundle().VerifyAndSetAll(caSecret.Data[caCertID],
-				caSecret.Data[caPrivateKeyID], nil, rootCerts); err != nil {
-				rootCertRotatorLog.Errorf("failed to reload root cert into KeyCertBundle (%v)", err)
-			} else {
-				rootCertRotatorLog.Info("Successfully reloaded root cert into KeyCertBundle.")
-			}
-			certEncoded := base64.StdEncoding.EncodeToString(rotator.ca.GetCAKeyCertBundle().GetRootCertPem())
-			// Keep root certificate in configmap in sync with the root certificate in istio-ca-secret.
-			if err = rotator.configMapController.InsertCATLSRootCertWithRetry(
-				certEncoded, rotator.config.retryInterval, 30*time.Second); err != nil {
-				rootCertRotatorLog.Errorf("Failed to write self-signed Citadel's root cert "+
-					"to configmap (%s). Citadel agents will not be able to connect.",
-					err.Error())
-			} else {
-				rootCertRotatorLog.Info("Root certificate is updated into configmap.")
-			}
-		}
+		rotator.reloadKeyCertBundle(caSecret)
 		return
 	}
 
 	rootCertRotatorLog.Infof("Refresh root certificate, root cert is about to expire: %s", err.Error())
+	notify.Send(notify.RootRotationStarted, "rotating the self-signed root cert in %s/%s: %v",
+		rotator.config.caStorageNamespace, CASecret, err)
 
 	oldCertOptions, err := util.GetCertOptionsFromExistingCert(caSecret.Data[caCertID])
 	if err != nil {
@@ -194,12 +209,16 @@ func (rotator *SelfSignedCARootCertRotator) checkAndRotateRootCertForSigningCert
 	pemCert, pemKey, ckErr := util.GenRootCertFromExistingKey(options)
 	if ckErr != nil {
 		rootCertRotatorLog.Errorf("unable to generate CA cert and key for self-signed CA: %s", ckErr.Error())
+		rootCertRotationCounts.With(resultTag.Value(rotationFailure)).Increment()
+		notify.Send(notify.RootRotationFailed, "failed to generate the new root cert: %v", ckErr)
 		return
 	}
 
 	pemRootCerts, err := util.AppendRootCerts(pemCert, rotator.config.rootCertFile)
 	if err != nil {
 		rootCertRotatorLog.Errorf("failed to append root certificates: %s", err.Error())
+		rootCertRotationCounts.With(resultTag.Value(rotationFailure)).Increment()
+		notify.Send(notify.RootRotationFailed, "failed to append the root certs: %v", err)
 		return
 	}
 
@@ -210,8 +229,12 @@ func (rotator *SelfSignedCARootCertRotator) checkAndRotateRootCertForSigningCert
 		if !rollback {
 			rootCertRotatorLog.Errorf("Failed to roll forward root certificate (error: %s). "+
 				"Abort new root certificate", err.Error())
+			rootCertRotationCounts.With(resultTag.Value(rotationFailure)).Increment()
+			notify.Send(notify.RootRotationFailed, "failed to store the new root cert: %v", err)
 			return
 		}
+		rootCertRotationCounts.With(resultTag.Value(rotationRollback)).Increment()
+		notify.Send(notify.RootRotationFailed, "failed to roll forward the new root cert, rolling back: %v", err)
 		// caSecret is out-of-date. Need to load the latest istio-ca-secret to roll back root certificate.
 		_, err = rotator.updateRootCertificate(nil, false, oldCaCert, oldCaPrivateKey, oldRootCerts)
 		if err != nil {
@@ -220,6 +243,102 @@ func (rotator *SelfSignedCARootCertRotator) checkAndRotateRootCertForSigningCert
 		return
 	}
 	rootCertRotatorLog.Info("Root certificate rotation is completed successfully.")
+	rootCertRotationCounts.With(resultTag.Value(rotationSuccess)).Increment()
+	notify.Send(notify.RootRotationCompleted, "rotated the self-signed root cert in %s/%s",
+		rotator.config.caStorageNamespace, CASecret)
+	auditRootCertRotation(pemCert)
+	if rotator.ca.stateRecorder != nil {
+		rotator.ca.stateRecorder.RecordRootCert(rotator.ca.GetCAKeyCertBundle().GetRootCertPem())
+	}
+}
+
+// auditRootCertRotation records the rotation of the self-signed root cert to pemCert in the audit log.
+func auditRootCertRotation(pemCert []byte) {
+	entry := audit.Entry{
+		Event:     rootCertRotationEvent,
+		Decision:  audit.Allow,
+		Origin:    audit.Internal,
+		Reason:    "the root cert is about to expire",
+		Lifecycle: audit.Rotated,
+	}
+	if cert, err := util.ParsePemEncodedCertificate(pemCert); err == nil {
+		entry.SerialNumber = cert.SerialNumber.Text(16)
+		entry.NotAfter = &cert.NotAfter
+	}
+	audit.Record(entry)
+}
+
+// reloadKeyCertBundle reloads the key cert bundle and the root cert configmap from caSecret,
+// if the CA certificate in caSecret differs from the one in the local key cert bundle.
+func (rotator *SelfSignedCARootCertRotator) reloadKeyCertBundle(caSecret *v1.Secret) {
+	if rotator.ca.stateRecorder != nil {
+		defer rotator.ca.stateRecorder.RecordSync()
+	}
+	caCertInMem, _, _, rootCertsInMem := rotator.ca.GetCAKeyCertBundle().GetAllPem()
+	// If CA certificate is different from the CA certificate in local key
+	// cert bundle, or istio-ca-secret holds new roots distributed by a two-phase
+	// root rotation, it implies that other Citadels have updated istio-ca-secret.
+	// Reload root certificate into key cert bundle.
+	if bytes.Equal(caCertInMem, caSecret.Data[caCertID]) && containsCerts(rootCertsInMem, caSecret.Data[RootCertID]) {
+		recordKeyCertBundleSync(syncSkipped)
+		return
+	}
+	rootCertRotatorLog.Warn("CA cert in KeyCertBundle does not match CA cert in " +
+		"istio-ca-secret. Start to reload root cert into KeyCertBundle")
+	rootCerts, err := caSecretRootCerts(caSecret, rotator.config.rootCertFile)
+	if err != nil {
+		rootCertRotatorLog.Errorf("failed to append root certificates from file: %s", err.Error())
+		recordKeyCertBundleSync(syncFailure)
+		return
+	}
+	if err := rotator.ca.GetCAKeyCertBundle().VerifyAndSetAll(caSecret.Data[caCertID],
+		caSecret.Data[caPrivateKeyID], nil, rootCerts); err != nil {
+		rootCertRotatorLog.Errorf("failed to reload root cert into KeyCertBundle (%v)", err)
+		recordKeyCertBundleSync(syncFailure)
+	} else {
+		rootCertRotatorLog.Info("Successfully reloaded root cert into KeyCertBundle.")
+		recordKeyCertBundleSync(syncSuccess)
+		if rotator.ca.stateRecorder != nil {
+			rotator.ca.stateRecorder.RecordRootCert(rotator.ca.GetCAKeyCertBundle().GetRootCertPem())
+		}
+	}
+	certEncoded := base64.StdEncoding.EncodeToString(rotator.ca.GetCAKeyCertBundle().GetRootCertPem())
+	// Keep root certificate in configmap in sync with the root certificate in istio-ca-secret.
+	if err = rotator.configMapController.InsertCATLSRootCertWithRetry(
+		certEncoded, rotator.config.retryInterval, 30*time.Second); err != nil {
+		rootCertRotatorLog.Errorf("Failed to write self-signed Citadel's root cert "+
+			"to configmap (%s). Citadel agents will not be able to connect.",
+			err.Error())
+	} else {
+		rootCertRotatorLog.Info("Root certificate is updated into configmap.")
+	}
+}
+
+// recordKeyCertBundleSync records the result of a sync of the key cert bundle with istio-ca-secret.
+func recordKeyCertBundleSync(result string) {
+	keyCertBundleSyncCounts.With(resultTag.Value(result)).Increment()
+	if result != syncFailure {
+		keyCertBundleLastSyncTimestamp.Record(float64(time.Now().Unix()))
+	}
+}
+
+// caSecretChanged is the callback for add and update events of istio-ca-secret. It picks up
+// root certs rotated by other Citadels without waiting for the next check interval.
+func (rotator *SelfSignedCARootCertRotator) caSecretChanged(obj interface{}) {
+	caSecret, ok := obj.(*v1.Secret)
+	if !ok {
+		rootCertRotatorLog.Warnf("failed to convert to secret object: %v", obj)
+		return
+	}
+	if caSecret.Name != CASecret {
+		return
+	}
+	caSecret, err := rotator.caSecretController.DecryptCASecret(caSecret)
+	if err != nil {
+		rootCertRotatorLog.Errorf("Failed to reload root cert: %v", err)
+		return
+	}
+	rotator.reloadKeyCertBundle(caSecret)
 }
 
 // updateRootCertificate updates root certificate in istio-ca-secret, keycertbundle and configmap. It takes a scrt
@@ -28,7 +28,9 @@ import (
 	"k8s.io/client-go/kubernetes/fake"
 	ktesting "k8s.io/client-go/testing"
 
+	"istio.io/istio/security/pkg/audit"
 	"istio.io/istio/security/pkg/cmd"
+	"istio.io/istio/security/pkg/notify"
 
 	"istio.io/istio/security/pkg/pki/util"
 	certutil "istio.io/istio/security/pkg/util"
@@ -133,6 +135,69 @@ func TestRootCertRotatorForSigningCitadel(t *testing.T) {
 	verifyRootCertAndPrivateKey(t, false, certItem1, certItem2)
 }
 
+// TestRootCertRotatorRenewBefore verifies that rotator rotates root cert when the
+// remaining lifetime is below RenewBefore, even if the grace period percentile
+// alone would not trigger a rotation.
+func TestRootCertRotatorRenewBefore(t *testing.T) {
+	rotator := getRootCertRotator(getDefaultSelfSignedIstioCAOptions(nil))
+	certItem0 := loadCert(rotator)
+
+	rotator.config.certInspector = certutil.NewCertUtil(0)
+	rotator.config.RenewBefore = time.Minute
+	rotator.checkAndRotateRootCert()
+	certItem1 := loadCert(rotator)
+	verifyRootCertAndPrivateKey(t, true, certItem0, certItem1)
+
+	// The root cert TTL is one hour, so a two hour threshold forces a rotation.
+	rotator.config.RenewBefore = 2 * time.Hour
+	rotator.checkAndRotateRootCert()
+	certItem2 := loadCert(rotator)
+	verifyRootCertAndPrivateKey(t, false, certItem1, certItem2)
+}
+
+type fakeNotifier struct {
+	alerts []notify.Alert
+}
+
+func (n *fakeNotifier) Notify(alert notify.Alert) {
+	n.alerts = append(n.alerts, alert)
+}
+
+type fakeAuditSink struct {
+	entries []audit.Entry
+}
+
+func (s *fakeAuditSink) Record(e audit.Entry) {
+	s.entries = append(s.entries, e)
+}
+
+// TestRootCertRotatorNotifications verifies that rotator sends alerts when the rotation
+// starts and completes, and audits the rotation.
+func TestRootCertRotatorNotifications(t *testing.T) {
+	n := &fakeNotifier{}
+	defer notify.Register(n)()
+	sink := &fakeAuditSink{}
+	defer audit.RegisterSink(sink)()
+	rotator := getRootCertRotator(getDefaultSelfSignedIstioCAOptions(nil))
+
+	rotator.config.certInspector = certutil.NewCertUtil(0)
+	rotator.checkAndRotateRootCert()
+	if len(n.alerts) != 0 {
+		t.Errorf("unexpected alerts %v", n.alerts)
+	}
+
+	rotator.config.certInspector = certutil.NewCertUtil(100)
+	rotator.checkAndRotateRootCert()
+	if len(n.alerts) != 2 || n.alerts[0].Kind != notify.RootRotationStarted ||
+		n.alerts[1].Kind != notify.RootRotationCompleted {
+		t.Errorf("unexpected alerts %v", n.alerts)
+	}
+	if len(sink.entries) != 1 || sink.entries[0].Event != rootCertRotationEvent || !sink.entries[0].IsIssuance() ||
+		sink.entries[0].Lifecycle != audit.Rotated {
+		t.Errorf("unexpected audit entries %+v", sink.entries)
+	}
+}
+
 // TestRootCertRotatorKeepCertFieldsUnchanged verifies that rotator
 // extracts information from existing certificate and passes then into new root
 // certificate.
@@ -277,6 +342,45 @@ func TestKeyCertBundleReloadInRootCertRotatorForSigningCitadel(t *testing.T) {
 	}
 }
 
+// TestKeyCertBundleReloadOnCASecretUpdate verifies that the rotator watches
+// istio-ca-secret and reloads root cert into KeyCertBundle as soon as other
+// Citadels update the secret, without waiting for the check interval.
+func TestKeyCertBundleReloadOnCASecretUpdate(t *testing.T) {
+	rotator := getRootCertRotator(getDefaultSelfSignedIstioCAOptions(nil))
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go rotator.Run(stopCh)
+
+	certItem0 := loadCert(rotator)
+	options := util.CertOptions{
+		TTL:           rotator.config.caCertTTL,
+		SignerPrivPem: certItem0.caSecret.Data[caPrivateKeyID],
+		Org:           rotator.config.org,
+		IsCA:          true,
+		IsSelfSigned:  true,
+		RSAKeySize:    caKeySize,
+	}
+	pemCert, pemKey, err := util.GenRootCertFromExistingKey(options)
+	if err != nil {
+		t.Fatalf("failed to rotate secret: %v", err)
+	}
+	newSecret := certItem0.caSecret
+	newSecret.Data[caCertID] = pemCert
+	newSecret.Data[caPrivateKeyID] = pemKey
+	if _, err = rotator.config.client.Secrets(rotator.config.caStorageNamespace).Update(
+		context.TODO(), newSecret, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update CA secret: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !bytes.Equal(pemCert, rotator.ca.keyCertBundle.GetRootCertPem()) {
+		if time.Now().After(deadline) {
+			t.Fatal("root cert in key cert bundle was not reloaded after istio-ca-secret update")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
 // TestRollbackAtRootCertRotatorForSigningCitadel verifies that rotator rollbacks
 // new root cert if it fails to update new root cert into configmap.
 func TestRollbackAtRootCertRotatorForSigningCitadel(t *testing.T) {
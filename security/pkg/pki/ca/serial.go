@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+const (
+	// RandomSerialNumbers is the serial number strategy issuing random 128-bit serial numbers.
+	// Collisions are negligible, also across restarts and CA replicas.
+	RandomSerialNumbers = "random"
+	// SequentialSerialNumbers is the serial number strategy issuing sequential serial numbers
+	// from blocks reserved in a persisted issuance registry.
+	SequentialSerialNumbers = "sequential"
+)
+
+// SerialNumberGenerator generates the serial numbers of issued certs.
+type SerialNumberGenerator interface {
+	// NextSerialNumber returns a serial number that was not returned before.
+	NextSerialNumber() (*big.Int, error)
+}
+
+// SerialNumberReserver reserves blocks of sequential serial numbers. A reserved block is never
+// reserved again, also after restarts and by other CA replicas sharing the reserver's storage.
+type SerialNumberReserver interface {
+	// ReserveSerialNumbers reserves size serial numbers and returns the first one.
+	ReserveSerialNumbers(ctx context.Context, size int64) (*big.Int, error)
+}
+
+// SequentialSerialNumberGenerator generates sequential serial numbers from the blocks reserved by a
+// SerialNumberReserver. The serial numbers left in a block when the CA stops are never used.
+type SequentialSerialNumberGenerator struct {
+	reserver  SerialNumberReserver
+	blockSize int64
+
+	mutex sync.Mutex
+	// next is the next serial number to issue and end the end of the reserved block, exclusive.
+	next *big.Int
+	end  *big.Int
+}
+
+// NewSequentialSerialNumberGenerator returns a SequentialSerialNumberGenerator reserving blocks of
+// blockSize serial numbers.
+func NewSequentialSerialNumberGenerator(reserver SerialNumberReserver, blockSize int64) (
+	*SequentialSerialNumberGenerator, error) {
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("invalid serial number block size %d", blockSize)
+	}
+	return &SequentialSerialNumberGenerator{
+		reserver:  reserver,
+		blockSize: blockSize,
+	}, nil
+}
+
+// NextSerialNumber implements SerialNumberGenerator.
+func (g *SequentialSerialNumberGenerator) NextSerialNumber() (*big.Int, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.next == nil || g.next.Cmp(g.end) >= 0 {
+		first, err := g.reserver.ReserveSerialNumbers(context.TODO(), g.blockSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reserve serial numbers: %v", err)
+		}
+		if first.Sign() <= 0 {
+			return nil, fmt.Errorf("reserved serial number %v is not positive", first)
+		}
+		g.next = new(big.Int).Set(first)
+		g.end = new(big.Int).Add(first, big.NewInt(g.blockSize))
+	}
+	serial := new(big.Int).Set(g.next)
+	g.next.Add(g.next, big.NewInt(1))
+	return serial, nil
+}
@@ -0,0 +1,112 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// fakeSerialNumberReserver reserves consecutive blocks starting at 1.
+type fakeSerialNumberReserver struct {
+	next  int64
+	calls int
+	err   error
+}
+
+func (r *fakeSerialNumberReserver) ReserveSerialNumbers(_ context.Context, size int64) (*big.Int, error) {
+	r.calls++
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.next == 0 {
+		r.next = 1
+	}
+	first := big.NewInt(r.next)
+	r.next += size
+	return first, nil
+}
+
+func TestSequentialSerialNumberGenerator(t *testing.T) {
+	if _, err := NewSequentialSerialNumberGenerator(&fakeSerialNumberReserver{}, 0); err == nil {
+		t.Error("expected an error for an empty block size")
+	}
+
+	reserver := &fakeSerialNumberReserver{}
+	g, err := NewSequentialSerialNumberGenerator(reserver, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= 7; i++ {
+		serial, err := g.NextSerialNumber()
+		if err != nil {
+			t.Fatalf("NextSerialNumber() error: %v", err)
+		}
+		if serial.Int64() != i {
+			t.Errorf("got serial number %v, expected %d", serial, i)
+		}
+	}
+	if reserver.calls != 3 {
+		t.Errorf("expected 3 reservations, got %d", reserver.calls)
+	}
+
+	g, _ = NewSequentialSerialNumberGenerator(&fakeSerialNumberReserver{err: errors.New("unavailable")}, 3)
+	if _, err := g.NextSerialNumber(); err == nil {
+		t.Error("expected an error when the reservation fails")
+	}
+}
+
+func TestSignWithSequentialSerialNumbers(t *testing.T) {
+	baseCA, err := createCA(time.Hour, "")
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	g, err := NewSequentialSerialNumberGenerator(&fakeSerialNumberReserver{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := NewIstioCA(&IstioCAOptions{
+		DefaultCertTTL:        time.Hour,
+		MaxCertTTL:            time.Hour,
+		KeyCertBundle:         baseCA.GetCAKeyCertBundle(),
+		RotatorConfig:         &SelfSignedCARootCertRotatorConfig{},
+		SerialNumberGenerator: g,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/foo/sa/bar", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= 2; i++ {
+		certPEM, err := ca.Sign(csrPEM, []string{"spiffe://cluster.local/ns/foo/sa/bar"}, time.Hour, false)
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		cert, err := util.ParsePemEncodedCertificate(certPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cert.SerialNumber.Int64() != i {
+			t.Errorf("got serial number %v, expected %d", cert.SerialNumber, i)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto"
+	"fmt"
+	"math/big"
+	"time"
+
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// SignResult is a signed certificate with its chain, trust bundle and expiry metadata.
+type SignResult struct {
+	// Leaf is the PEM-encoded signed certificate.
+	Leaf []byte
+	// CertChain is the PEM-encoded chain of intermediate certs, from the issuer of Leaf towards the root.
+	// It is empty if Leaf is signed by a root cert.
+	CertChain []byte
+	// RootCerts are the PEM-encoded root certs trusted by the mesh.
+	RootCerts []byte
+	// NotAfter is the expiration time of Leaf.
+	NotAfter time.Time
+	// SerialNumber is the serial number of Leaf.
+	SerialNumber *big.Int
+}
+
+// SignWithResult is similar to Sign, but returns the signed certificate together with the cert chain and
+// root certs of the CA key/cert it is signed with, and its expiration time and serial number.
+func (ca *IstioCA) SignWithResult(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) (
+	*SignResult, error) {
+	signingCert, signingKey, certChainBytes, rootCertBytes := ca.keyCertBundle.GetAll()
+	if signingCert == nil || (signingKey == nil && ca.signer == nil) {
+		return nil, caerror.NewError(caerror.CANotReady, fmt.Errorf("Istio CA is not ready")) // nolint
+	}
+	var key crypto.PrivateKey = ca.signer
+	if ca.signer == nil {
+		key = *signingKey
+	}
+	leaf, err := ca.sign(csrPEM, subjectIDs, ttl, forCA, signingCert, key, certChainBytes)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := util.ParsePemEncodedCertificate(leaf)
+	if err != nil {
+		return nil, caerror.NewError(caerror.CertGenError, err)
+	}
+	if len(certChainBytes) > 0 {
+		certChainBytes = orderCertChain(certChainBytes)
+	}
+	return &SignResult{
+		Leaf:         leaf,
+		CertChain:    certChainBytes,
+		RootCerts:    rootCertBytes,
+		NotAfter:     cert.NotAfter,
+		SerialNumber: cert.SerialNumber,
+	}, nil
+}
@@ -0,0 +1,63 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func TestSignWithResult(t *testing.T) {
+	ca, err := createCA(time.Hour, "")
+	if err != nil {
+		t.Fatalf("failed to create the CA: %v", err)
+	}
+	csrPEM, _, err := util.GenCSR(util.CertOptions{
+		Host:       "spiffe://cluster.local/ns/foo/sa/foo",
+		RSAKeySize: 2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to generate a CSR: %v", err)
+	}
+
+	result, err := ca.SignWithResult(csrPEM, []string{"spiffe://cluster.local/ns/foo/sa/foo"}, 30*time.Minute, false)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	leaf, err := util.ParsePemEncodedCertificate(result.Leaf)
+	if err != nil {
+		t.Fatalf("failed to parse the leaf cert: %v", err)
+	}
+	if !result.NotAfter.Equal(leaf.NotAfter) {
+		t.Errorf("NotAfter is %v, want %v", result.NotAfter, leaf.NotAfter)
+	}
+	if result.SerialNumber == nil || result.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+		t.Errorf("SerialNumber is %v, want %v", result.SerialNumber, leaf.SerialNumber)
+	}
+	_, _, certChain, rootCerts := ca.GetCAKeyCertBundle().GetAll()
+	if !bytes.Equal(result.CertChain, certChain) {
+		t.Errorf("CertChain is %s, want %s", result.CertChain, certChain)
+	}
+	if !bytes.Equal(result.RootCerts, rootCerts) {
+		t.Errorf("RootCerts is %s, want %s", result.RootCerts, rootCerts)
+	}
+
+	if _, err := ca.SignWithResult([]byte("invalid"), []string{"foo"}, time.Minute, false); err == nil {
+		t.Error("expected an error for an invalid CSR")
+	}
+}
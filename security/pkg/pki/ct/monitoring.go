@@ -0,0 +1,49 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+var (
+	logTag    = monitoring.MustCreateLabel("log")
+	resultTag = monitoring.MustCreateLabel("result")
+
+	submissionCounts = monitoring.NewSum(
+		"citadel_ct_submission_count",
+		"The number of certificate submissions to CT logs, by log and result.",
+		monitoring.WithLabels(logTag, resultTag),
+	)
+
+	submissionLatency = monitoring.NewDistribution(
+		"citadel_ct_submission_latency_seconds",
+		"Latency in seconds of certificate submissions to CT logs.",
+		[]float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+		monitoring.WithLabels(logTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		submissionCounts,
+		submissionLatency,
+	)
+}
@@ -0,0 +1,232 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ct submits issued certificates to Certificate Transparency logs (RFC 6962).
+package ct
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"istio.io/pkg/log"
+)
+
+var ctLog = log.RegisterScope("ct", "Certificate Transparency submission log", 0)
+
+var (
+	// OIDSCTList is the X.509 extension holding the embedded SCT list.
+	OIDSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+	// OIDPrecertPoison is the critical extension marking a precertificate.
+	OIDPrecertPoison = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+)
+
+const (
+	addChainPath    = "/ct/v1/add-chain"
+	addPreChainPath = "/ct/v1/add-pre-chain"
+
+	defaultTimeout = 10 * time.Second
+)
+
+// Config configures CT submission.
+type Config struct {
+	// Logs are the base URLs of the CT logs, e.g. https://ct.googleapis.com/logs/argon2021.
+	Logs []string
+	// EmbedSCTs makes the CA submit a precertificate before issuance and embed the returned
+	// SCTs in the issued cert. Otherwise the issued cert is submitted after issuance.
+	EmbedSCTs bool
+	// Timeout bounds a single submission to a log. Defaults to 10s.
+	Timeout time.Duration
+}
+
+// SignedCertificateTimestamp is an SCT returned by a CT log.
+type SignedCertificateTimestamp struct {
+	SCTVersion uint8  `json:"sct_version"`
+	ID         string `json:"id"`
+	Timestamp  uint64 `json:"timestamp"`
+	Extensions string `json:"extensions"`
+	Signature  string `json:"signature"`
+}
+
+// Submitter submits certificate chains to CT logs.
+type Submitter struct {
+	config Config
+	client *http.Client
+}
+
+// NewSubmitter returns a Submitter for the CT logs in config.
+func NewSubmitter(config Config) (*Submitter, error) {
+	if len(config.Logs) == 0 {
+		return nil, errors.New("no CT logs configured")
+	}
+	for i, l := range config.Logs {
+		l = strings.TrimSpace(l)
+		if !strings.HasPrefix(l, "https://") && !strings.HasPrefix(l, "http://") {
+			return nil, fmt.Errorf("invalid CT log URL %q", l)
+		}
+		config.Logs[i] = strings.TrimSuffix(l, "/")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &Submitter{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// EmbedSCTs returns whether SCTs should be embedded in issued certs.
+func (s *Submitter) EmbedSCTs() bool {
+	return s.config.EmbedSCTs
+}
+
+// AddChain submits a DER-encoded cert followed by its issuer chain to all logs, and returns
+// the SCTs of the logs that accepted it. An error is returned only if no log accepted it.
+func (s *Submitter) AddChain(chain [][]byte) ([]SignedCertificateTimestamp, error) {
+	return s.submit(addChainPath, chain)
+}
+
+// AddPreChain submits a DER-encoded precertificate followed by its issuer chain to all logs,
+// and returns the SCTs of the logs that accepted it. An error is returned only if no log
+// accepted it.
+func (s *Submitter) AddPreChain(chain [][]byte) ([]SignedCertificateTimestamp, error) {
+	return s.submit(addPreChainPath, chain)
+}
+
+func (s *Submitter) submit(path string, chain [][]byte) ([]SignedCertificateTimestamp, error) {
+	req := struct {
+		Chain []string `json:"chain"`
+	}{}
+	for _, der := range chain {
+		req.Chain = append(req.Chain, base64.StdEncoding.EncodeToString(der))
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	scts := []SignedCertificateTimestamp{}
+	var lastErr error
+	for _, l := range s.config.Logs {
+		start := time.Now()
+		sct, err := s.post(l+path, body)
+		submissionLatency.With(logTag.Value(l)).Record(time.Since(start).Seconds())
+		if err != nil {
+			submissionCounts.With(logTag.Value(l), resultTag.Value(resultFailure)).Increment()
+			ctLog.Warnf("failed to submit to CT log %s: %v", l, err)
+			lastErr = err
+			continue
+		}
+		submissionCounts.With(logTag.Value(l), resultTag.Value(resultSuccess)).Increment()
+		scts = append(scts, *sct)
+	}
+	if len(scts) == 0 {
+		return nil, fmt.Errorf("no CT log accepted the submission: %v", lastErr)
+	}
+	return scts, nil
+}
+
+func (s *Submitter) post(url string, body []byte) (*SignedCertificateTimestamp, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	sct := &SignedCertificateTimestamp{}
+	if err = json.Unmarshal(respBody, sct); err != nil {
+		return nil, fmt.Errorf("failed to parse the SCT: %v", err)
+	}
+	return sct, nil
+}
+
+// PrecertPoisonExtension returns the critical extension that marks a precertificate.
+func PrecertPoisonExtension() pkix.Extension {
+	return pkix.Extension{Id: OIDPrecertPoison, Critical: true, Value: asn1.NullBytes}
+}
+
+// SCTListExtension encodes SCTs as the embedded SCT list extension (RFC 6962 section 3.3).
+func SCTListExtension(scts []SignedCertificateTimestamp) (pkix.Extension, error) {
+	list := []byte{}
+	for _, sct := range scts {
+		serialized, err := serializeSCT(sct)
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+		if len(serialized) > 0xffff {
+			return pkix.Extension{}, errors.New("SCT is too large")
+		}
+		list = append(list, byte(len(serialized)>>8), byte(len(serialized)))
+		list = append(list, serialized...)
+	}
+	if len(list) == 0 || len(list) > 0xffff {
+		return pkix.Extension{}, fmt.Errorf("invalid SCT list size %d", len(list))
+	}
+	value, err := asn1.Marshal(append([]byte{byte(len(list) >> 8), byte(len(list))}, list...))
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: OIDSCTList, Value: value}, nil
+}
+
+// serializeSCT encodes an SCT in its TLS presentation form.
+func serializeSCT(sct SignedCertificateTimestamp) ([]byte, error) {
+	id, err := base64.StdEncoding.DecodeString(sct.ID)
+	if err != nil || len(id) != 32 {
+		return nil, fmt.Errorf("invalid SCT log ID %q", sct.ID)
+	}
+	extensions, err := base64.StdEncoding.DecodeString(sct.Extensions)
+	if err != nil || len(extensions) > 0xffff {
+		return nil, fmt.Errorf("invalid SCT extensions %q", sct.Extensions)
+	}
+	// The signature is a TLS encoded DigitallySigned struct.
+	signature, err := base64.StdEncoding.DecodeString(sct.Signature)
+	if err != nil || len(signature) < 4 {
+		return nil, fmt.Errorf("invalid SCT signature %q", sct.Signature)
+	}
+
+	out := make([]byte, 0, 1+32+8+2+len(extensions)+len(signature))
+	out = append(out, sct.SCTVersion)
+	out = append(out, id...)
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, sct.Timestamp)
+	out = append(out, ts...)
+	out = append(out, byte(len(extensions)>>8), byte(len(extensions)))
+	out = append(out, extensions...)
+	out = append(out, signature...)
+	return out, nil
+}
@@ -0,0 +1,139 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeLog is a CT log that accepts every submission and returns a fixed SCT.
+type fakeLog struct {
+	mutex  sync.Mutex
+	paths  []string
+	chains [][]string
+	fail   bool
+}
+
+var testSCT = SignedCertificateTimestamp{
+	SCTVersion: 0,
+	ID:         base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)),
+	Timestamp:  1600000000000,
+	Extensions: "",
+	Signature:  base64.StdEncoding.EncodeToString([]byte{4, 3, 0, 2, 0xab, 0xcd}),
+}
+
+func (f *fakeLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	req := struct {
+		Chain []string `json:"chain"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.paths = append(f.paths, r.URL.Path)
+	f.chains = append(f.chains, req.Chain)
+	_ = json.NewEncoder(w).Encode(testSCT)
+}
+
+func TestSubmitter(t *testing.T) {
+	good := &fakeLog{}
+	goodServer := httptest.NewServer(good)
+	defer goodServer.Close()
+	bad := &fakeLog{fail: true}
+	badServer := httptest.NewServer(bad)
+	defer badServer.Close()
+
+	s, err := NewSubmitter(Config{Logs: []string{badServer.URL + "/", goodServer.URL}})
+	if err != nil {
+		t.Fatalf("failed to create submitter: %v", err)
+	}
+	scts, err := s.AddChain([][]byte{[]byte("leaf"), []byte("issuer")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(scts) != 1 || scts[0] != testSCT {
+		t.Errorf("unexpected SCTs %v", scts)
+	}
+	if _, err = s.AddPreChain([][]byte{[]byte("precert")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(good.paths) != 2 || good.paths[0] != addChainPath || good.paths[1] != addPreChainPath {
+		t.Errorf("unexpected submission paths %v", good.paths)
+	}
+	if len(good.chains[0]) != 2 || good.chains[0][0] != base64.StdEncoding.EncodeToString([]byte("leaf")) {
+		t.Errorf("unexpected submitted chain %v", good.chains[0])
+	}
+
+	s, err = NewSubmitter(Config{Logs: []string{badServer.URL}})
+	if err != nil {
+		t.Fatalf("failed to create submitter: %v", err)
+	}
+	if _, err = s.AddChain([][]byte{[]byte("leaf")}); err == nil {
+		t.Error("expected an error when no log accepts the submission")
+	}
+}
+
+func TestNewSubmitterInvalidConfig(t *testing.T) {
+	if _, err := NewSubmitter(Config{}); err == nil {
+		t.Error("expected an error without logs")
+	}
+	if _, err := NewSubmitter(Config{Logs: []string{"ct.example.com"}}); err == nil {
+		t.Error("expected an error for a log URL without scheme")
+	}
+}
+
+func TestSCTListExtension(t *testing.T) {
+	ext, err := SCTListExtension([]SignedCertificateTimestamp{testSCT, testSCT})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ext.Id.Equal(OIDSCTList) || ext.Critical {
+		t.Errorf("unexpected extension %v", ext)
+	}
+	var list []byte
+	if _, err = asn1.Unmarshal(ext.Value, &list); err != nil {
+		t.Fatalf("extension value is not an octet string: %v", err)
+	}
+	// Each SCT is 1 + 32 + 8 + 2 + 6 bytes, prefixed with a 2 byte length.
+	sctLen := 1 + 32 + 8 + 2 + 6
+	if expected := 2 + 2*(2+sctLen); len(list) != expected {
+		t.Fatalf("expected SCT list of %d bytes, got %d", expected, len(list))
+	}
+	if int(list[0])<<8|int(list[1]) != len(list)-2 || int(list[2])<<8|int(list[3]) != sctLen {
+		t.Errorf("unexpected length prefixes in %x", list[:4])
+	}
+
+	if _, err = SCTListExtension(nil); err == nil {
+		t.Error("expected an error for an empty SCT list")
+	}
+	badSCT := testSCT
+	badSCT.ID = "short"
+	if _, err = SCTListExtension([]SignedCertificateTimestamp{badSCT}); err == nil {
+		t.Error("expected an error for an invalid log ID")
+	}
+}
@@ -28,6 +28,16 @@ const (
 	TTLError
 	// CertGenError means an error happened during the certificate generation.
 	CertGenError
+	// WeakKeyError means the CSR public key is too weak or of an unsupported type.
+	WeakKeyError
+	// SANError means the CSR requests malformed or too many SANs.
+	SANError
+	// AuthorizationError means the requester is not allowed to request the SANs.
+	AuthorizationError
+	// BackendError means a transient failure of the CA backend, such as an unavailable external CA.
+	BackendError
+	// QuotaExceededError means a quota or rate limit of the CA backend is exceeded.
+	QuotaExceededError
 )
 
 // Error encapsulates the short and long errors.
@@ -52,6 +62,16 @@ func (e Error) ErrorType() string {
 		return "TTL_ERROR"
 	case CertGenError:
 		return "CERT_GEN_ERROR"
+	case WeakKeyError:
+		return "WEAK_KEY_ERROR"
+	case SANError:
+		return "SAN_ERROR"
+	case AuthorizationError:
+		return "AUTHORIZATION_ERROR"
+	case BackendError:
+		return "BACKEND_ERROR"
+	case QuotaExceededError:
+		return "QUOTA_EXCEEDED"
 	}
 	return "UNKNOWN"
 }
@@ -67,10 +87,41 @@ func (e Error) HTTPErrorCode() codes.Code {
 		return codes.InvalidArgument
 	case TTLError:
 		return codes.InvalidArgument
+	case WeakKeyError:
+		return codes.InvalidArgument
+	case SANError:
+		return codes.InvalidArgument
+	case AuthorizationError:
+		return codes.PermissionDenied
+	case BackendError:
+		return codes.Unavailable
+	case QuotaExceededError:
+		return codes.ResourceExhausted
 	}
 	return codes.Internal
 }
 
+// IsRetryable returns whether the request may succeed if retried later. Errors of the request itself,
+// such as a malformed CSR or a policy denial, are not retryable.
+func (e Error) IsRetryable() bool {
+	switch e.t {
+	case CANotReady, BackendError, QuotaExceededError:
+		return true
+	}
+	return false
+}
+
+// IsRetryable returns whether err is a retryable CA error.
+func IsRetryable(err error) bool {
+	switch e := err.(type) {
+	case *Error:
+		return e != nil && e.IsRetryable()
+	case Error:
+		return e.IsRetryable()
+	}
+	return false
+}
+
 // NewError creates a new Error instance.
 func NewError(t ErrType, err error) *Error {
 	return &Error{
@@ -23,16 +23,18 @@ import (
 
 func TestError(t *testing.T) {
 	testCases := map[string]struct {
-		eType   ErrType
-		err     error
-		message string
-		code    codes.Code
+		eType     ErrType
+		err       error
+		message   string
+		code      codes.Code
+		retryable bool
 	}{
 		"CA_NOT_READY": {
-			eType:   CANotReady,
-			err:     fmt.Errorf("test error1"),
-			message: "CA_NOT_READY",
-			code:    codes.Internal,
+			eType:     CANotReady,
+			err:       fmt.Errorf("test error1"),
+			message:   "CA_NOT_READY",
+			code:      codes.Internal,
+			retryable: true,
 		},
 		"CSR_ERROR": {
 			eType:   CSRError,
@@ -52,6 +54,38 @@ func TestError(t *testing.T) {
 			message: "CERT_GEN_ERROR",
 			code:    codes.Internal,
 		},
+		"WEAK_KEY_ERROR": {
+			eType:   WeakKeyError,
+			err:     fmt.Errorf("test error6"),
+			message: "WEAK_KEY_ERROR",
+			code:    codes.InvalidArgument,
+		},
+		"SAN_ERROR": {
+			eType:   SANError,
+			err:     fmt.Errorf("test error7"),
+			message: "SAN_ERROR",
+			code:    codes.InvalidArgument,
+		},
+		"AUTHORIZATION_ERROR": {
+			eType:   AuthorizationError,
+			err:     fmt.Errorf("test error8"),
+			message: "AUTHORIZATION_ERROR",
+			code:    codes.PermissionDenied,
+		},
+		"BACKEND_ERROR": {
+			eType:     BackendError,
+			err:       fmt.Errorf("test error9"),
+			message:   "BACKEND_ERROR",
+			code:      codes.Unavailable,
+			retryable: true,
+		},
+		"QUOTA_EXCEEDED": {
+			eType:     QuotaExceededError,
+			err:       fmt.Errorf("test error10"),
+			message:   "QUOTA_EXCEEDED",
+			code:      codes.ResourceExhausted,
+			retryable: true,
+		},
 		"UNKNOWN": {
 			eType:   -1,
 			err:     fmt.Errorf("test error5"),
@@ -71,5 +105,20 @@ func TestError(t *testing.T) {
 		if caErr.HTTPErrorCode() != tc.code {
 			t.Errorf("[%s] unexpected error HTTP code: '%d' VS (expected)'%d'", k, caErr.HTTPErrorCode(), tc.code)
 		}
+		if caErr.IsRetryable() != tc.retryable || IsRetryable(caErr) != tc.retryable {
+			t.Errorf("[%s] unexpected retryable: %v VS (expected)%v", k, caErr.IsRetryable(), tc.retryable)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	var nilErr *Error
+	for _, err := range []error{nil, nilErr, fmt.Errorf("not a CA error")} {
+		if IsRetryable(err) {
+			t.Errorf("unexpected retryable error %v", err)
+		}
+	}
+	if !IsRetryable(*NewError(BackendError, fmt.Errorf("unavailable"))) {
+		t.Error("expected a backend error value to be retryable")
 	}
 }
@@ -0,0 +1,145 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package escrow escrows a CA private key as Shamir secret shares stored in separate locations, so
+// that no single location holds the complete key, and recovers the key from enough of them.
+package escrow
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"istio.io/istio/security/pkg/pki/shamir"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const (
+	// shareBlockType is the PEM block type of a key share.
+	shareBlockType = "ISTIO CA KEY SHARE"
+
+	thresholdHeader = "Threshold"
+	sharesHeader    = "Shares"
+	// publicKeyHeader is the hex encoded SHA-256 fingerprint of the public key of the escrowed key, to
+	// tell apart the shares of different keys and to verify the recovered key.
+	publicKeyHeader = "Public-Key-SHA256"
+)
+
+// Location stores one share of an escrowed key.
+type Location interface {
+	// String describes the location.
+	String() string
+	// Store stores share. It fails if the location already holds a share.
+	Store(ctx context.Context, share []byte) error
+	// Load returns the stored share.
+	Load(ctx context.Context) ([]byte, error)
+}
+
+// Split splits the PEM encoded private key keyPEM into one share per location, any threshold of which
+// recover it, and stores the shares.
+func Split(ctx context.Context, keyPEM []byte, threshold int, locations []Location) error {
+	fingerprint, err := publicKeyFingerprint(keyPEM)
+	if err != nil {
+		return err
+	}
+	shares, err := shamir.Split(keyPEM, len(locations), threshold)
+	if err != nil {
+		return err
+	}
+	for i, loc := range locations {
+		share := pem.EncodeToMemory(&pem.Block{
+			Type: shareBlockType,
+			Headers: map[string]string{
+				thresholdHeader: strconv.Itoa(threshold),
+				sharesHeader:    strconv.Itoa(len(locations)),
+				publicKeyHeader: fingerprint,
+			},
+			Bytes: shares[i],
+		})
+		if err := loc.Store(ctx, share); err != nil {
+			return fmt.Errorf("failed to store the key share in %s: %v", loc, err)
+		}
+	}
+	return nil
+}
+
+// Recover loads the shares in locations and recovers the escrowed private key from them. Locations
+// that cannot be read are skipped, as long as at least the threshold of the shares are loaded.
+func Recover(ctx context.Context, locations []Location) ([]byte, error) {
+	var shares [][]byte
+	var fingerprint string
+	threshold := 0
+	var errs []string
+	for _, loc := range locations {
+		data, err := loc.Load(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", loc, err))
+			continue
+		}
+		block, _ := pem.Decode(data)
+		if block == nil || block.Type != shareBlockType {
+			errs = append(errs, fmt.Sprintf("%s: not a key share", loc))
+			continue
+		}
+		t, err := strconv.Atoi(block.Headers[thresholdHeader])
+		if err != nil || t < 2 {
+			errs = append(errs, fmt.Sprintf("%s: invalid threshold %q", loc, block.Headers[thresholdHeader]))
+			continue
+		}
+		if fingerprint == "" {
+			fingerprint, threshold = block.Headers[publicKeyHeader], t
+		} else if block.Headers[publicKeyHeader] != fingerprint || t != threshold {
+			errs = append(errs, fmt.Sprintf("%s: a share of another key", loc))
+			continue
+		}
+		shares = append(shares, block.Bytes)
+	}
+	if threshold == 0 || len(shares) < threshold {
+		return nil, fmt.Errorf("loaded %d key shares, %d are required: %s", len(shares), threshold,
+			strings.Join(errs, "; "))
+	}
+	keyPEM, err := shamir.Combine(shares)
+	if err != nil {
+		return nil, err
+	}
+	if got, err := publicKeyFingerprint(keyPEM); err != nil || got != fingerprint {
+		return nil, errors.New("the recovered key does not match the escrowed key, some shares are corrupted")
+	}
+	return keyPEM, nil
+}
+
+// publicKeyFingerprint returns the hex encoded SHA-256 fingerprint of the public key of keyPEM.
+func publicKeyFingerprint(keyPEM []byte) (string, error) {
+	key, err := util.ParsePemEncodedKey(keyPEM)
+	if err != nil {
+		return "", err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return "", fmt.Errorf("unsupported private key type %T", key)
+	}
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
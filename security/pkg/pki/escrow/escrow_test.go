@@ -0,0 +1,223 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package escrow
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+func genKey(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+type memLocation struct {
+	name  string
+	share []byte
+}
+
+func (l *memLocation) String() string {
+	return l.name
+}
+
+func (l *memLocation) Store(_ context.Context, share []byte) error {
+	if l.share != nil {
+		return fmt.Errorf("%s already holds a share", l)
+	}
+	l.share = share
+	return nil
+}
+
+func (l *memLocation) Load(_ context.Context) ([]byte, error) {
+	if l.share == nil {
+		return nil, fmt.Errorf("%s holds no share", l)
+	}
+	return l.share, nil
+}
+
+func newMemLocations(n int) []Location {
+	locations := make([]Location, n)
+	for i := range locations {
+		locations[i] = &memLocation{name: fmt.Sprintf("location-%d", i)}
+	}
+	return locations
+}
+
+func TestSplitRecover(t *testing.T) {
+	ctx := context.Background()
+	key := genKey(t)
+	locations := newMemLocations(5)
+	if err := Split(ctx, key, 3, locations); err != nil {
+		t.Fatalf("Split() error: %v", err)
+	}
+	for _, loc := range locations {
+		if bytes.Contains(loc.(*memLocation).share, key) {
+			t.Fatalf("%s holds the complete key", loc)
+		}
+	}
+
+	// Any 3 of the shares recover the key, the unreadable locations are skipped.
+	locations[1].(*memLocation).share = nil
+	locations[3].(*memLocation).share = nil
+	recovered, err := Recover(ctx, locations)
+	if err != nil {
+		t.Fatalf("Recover() error: %v", err)
+	}
+	if !bytes.Equal(recovered, key) {
+		t.Errorf("Recover() returned a different key")
+	}
+
+	locations[4].(*memLocation).share = nil
+	if _, err := Recover(ctx, locations); err == nil || !strings.Contains(err.Error(), "loaded 2 key shares, 3 are required") {
+		t.Errorf("Recover() with 2 shares: unexpected error %v", err)
+	}
+}
+
+func TestSplitExistingShare(t *testing.T) {
+	locations := newMemLocations(3)
+	locations[2].(*memLocation).share = []byte("share")
+	if err := Split(context.Background(), genKey(t), 2, locations); err == nil {
+		t.Errorf("Split() overwrote an existing share")
+	}
+}
+
+func TestRecoverMixedKeys(t *testing.T) {
+	ctx := context.Background()
+	first, second := newMemLocations(3), newMemLocations(3)
+	if err := Split(ctx, genKey(t), 2, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := Split(ctx, genKey(t), 2, second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Recover(ctx, []Location{first[0], second[1]}); err == nil {
+		t.Errorf("Recover() combined the shares of different keys")
+	}
+}
+
+func TestRecoverCorruptedShare(t *testing.T) {
+	ctx := context.Background()
+	locations := newMemLocations(2)
+	if err := Split(ctx, genKey(t), 2, locations); err != nil {
+		t.Fatal(err)
+	}
+	loc := locations[0].(*memLocation)
+	block, _ := pem.Decode(loc.share)
+	block.Bytes[0] ^= 0xff
+	loc.share = pem.EncodeToMemory(block)
+	if _, err := Recover(ctx, locations); err == nil || !strings.Contains(err.Error(), "corrupted") {
+		t.Errorf("Recover() with a corrupted share: unexpected error %v", err)
+	}
+}
+
+func TestLocations(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "escrow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	kekFile := filepath.Join(dir, "kek")
+	kek := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x42}, 32))
+	if err := ioutil.WriteFile(kekFile, []byte(kek), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleClientset()
+	getClient := func() (corev1.SecretsGetter, error) {
+		return client.CoreV1(), nil
+	}
+
+	specs := []string{
+		"secret:istio-system/share-0",
+		"file:" + filepath.Join(dir, "share-1"),
+		"local-kms:" + kekFile + "@secret:default/share-2",
+	}
+	var locations []Location
+	for _, spec := range specs {
+		loc, err := ParseLocation(spec, getClient)
+		if err != nil {
+			t.Fatalf("ParseLocation(%q) error: %v", spec, err)
+		}
+		locations = append(locations, loc)
+	}
+	key := genKey(t)
+	if err := Split(ctx, key, 2, locations); err != nil {
+		t.Fatalf("Split() error: %v", err)
+	}
+	if err := Split(ctx, key, 2, locations); err == nil {
+		t.Errorf("Split() overwrote the existing shares")
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "share-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("share file mode is %v, expected 0600", info.Mode().Perm())
+	}
+	encrypted, err := NewSecretLocation(client.CoreV1(), "default", "share-2").Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encrypted, []byte(shareBlockType)) {
+		t.Errorf("the share is not encrypted: %s", encrypted)
+	}
+
+	recovered, err := Recover(ctx, locations[1:])
+	if err != nil {
+		t.Fatalf("Recover() error: %v", err)
+	}
+	if !bytes.Equal(recovered, key) {
+		t.Errorf("Recover() returned a different key")
+	}
+}
+
+func TestParseLocationErrors(t *testing.T) {
+	noClient := func() (corev1.SecretsGetter, error) {
+		return nil, fmt.Errorf("no client")
+	}
+	for _, spec := range []string{
+		"",
+		"file:",
+		"secret:name",
+		"secret:/name",
+		"secret:istio-system/name",
+		"s3://bucket/share",
+		"aws-kms:key@file:/tmp/share",
+		"vault-kms:key@file:/tmp/share",
+	} {
+		if _, err := ParseLocation(spec, noClient); err == nil {
+			t.Errorf("ParseLocation(%q) expected an error", spec)
+		}
+	}
+}
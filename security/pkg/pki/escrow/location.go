@@ -0,0 +1,202 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package escrow
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/security/pkg/pki/kms"
+)
+
+const (
+	// ShareSecretKey is the key of the share in the secrets storing key shares.
+	ShareSecretKey = "key-share.pem"
+	// ShareSecretLabel labels the secrets storing key shares.
+	ShareSecretLabel = "istio.io/ca-key-share"
+)
+
+// secretLocation stores a share in a Kubernetes secret.
+type secretLocation struct {
+	client    corev1.SecretsGetter
+	namespace string
+	name      string
+}
+
+// NewSecretLocation returns a Location storing a share in the secret namespace/name.
+func NewSecretLocation(client corev1.SecretsGetter, namespace, name string) Location {
+	return &secretLocation{client: client, namespace: namespace, name: name}
+}
+
+func (l *secretLocation) String() string {
+	return fmt.Sprintf("secret %s/%s", l.namespace, l.name)
+}
+
+// Store implements Location.
+func (l *secretLocation) Store(ctx context.Context, share []byte) error {
+	_, err := l.client.Secrets(l.namespace).Create(ctx, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      l.name,
+			Namespace: l.namespace,
+			Labels:    map[string]string{ShareSecretLabel: "true"},
+		},
+		Data: map[string][]byte{ShareSecretKey: share},
+	}, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		return fmt.Errorf("%s already exists", l)
+	}
+	return err
+}
+
+// Load implements Location.
+func (l *secretLocation) Load(ctx context.Context) ([]byte, error) {
+	secret, err := l.client.Secrets(l.namespace).Get(ctx, l.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	share, ok := secret.Data[ShareSecretKey]
+	if !ok {
+		return nil, fmt.Errorf("%s has no %s", l, ShareSecretKey)
+	}
+	return share, nil
+}
+
+// fileLocation stores a share in a file, e.g. on removable media.
+type fileLocation struct {
+	path string
+}
+
+// NewFileLocation returns a Location storing a share in the file path.
+func NewFileLocation(path string) Location {
+	return &fileLocation{path: path}
+}
+
+func (l *fileLocation) String() string {
+	return "file " + l.path
+}
+
+// Store implements Location.
+func (l *fileLocation) Store(_ context.Context, share []byte) error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(share); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load implements Location.
+func (l *fileLocation) Load(_ context.Context) ([]byte, error) {
+	return ioutil.ReadFile(l.path)
+}
+
+// kmsLocation encrypts a share with a KMS key before storing it in another location, so that reading
+// the share also requires access to the KMS key.
+type kmsLocation struct {
+	location Location
+	provider kms.Provider
+}
+
+// NewKMSLocation returns a Location storing a share in location, encrypted with provider.
+func NewKMSLocation(location Location, provider kms.Provider) Location {
+	return &kmsLocation{location: location, provider: provider}
+}
+
+func (l *kmsLocation) String() string {
+	return fmt.Sprintf("%s encrypted with %s key %s", l.location, l.provider.Name(), l.provider.KeyID())
+}
+
+// Store implements Location.
+func (l *kmsLocation) Store(ctx context.Context, share []byte) error {
+	encrypted, err := kms.EncryptData(l.provider, share)
+	if err != nil {
+		return err
+	}
+	return l.location.Store(ctx, encrypted)
+}
+
+// Load implements Location.
+func (l *kmsLocation) Load(ctx context.Context) ([]byte, error) {
+	encrypted, err := l.location.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return kms.DecryptData(l.provider, encrypted)
+}
+
+// ParseLocation parses a location spec:
+//
+//	secret:<namespace>/<name>, the secret is read and written with client
+//	file:<path>
+//
+// optionally prefixed by the KMS key encrypting the share and '@':
+//
+//	local-kms:<key encryption key file>@<location>
+//	aws-kms:<region>/<key id>@<location>
+func ParseLocation(spec string, client func() (corev1.SecretsGetter, error)) (Location, error) {
+	if i := strings.Index(spec, "@"); i >= 0 {
+		provider, err := parseKMSProvider(spec[:i])
+		if err != nil {
+			return nil, err
+		}
+		location, err := ParseLocation(spec[i+1:], client)
+		if err != nil {
+			return nil, err
+		}
+		return NewKMSLocation(location, provider), nil
+	}
+	switch {
+	case strings.HasPrefix(spec, "secret:"):
+		parts := strings.Split(strings.TrimPrefix(spec, "secret:"), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid secret location %q, expecting secret:<namespace>/<name>", spec)
+		}
+		c, err := client()
+		if err != nil {
+			return nil, err
+		}
+		return NewSecretLocation(c, parts[0], parts[1]), nil
+	case strings.HasPrefix(spec, "file:") && len(spec) > len("file:"):
+		return NewFileLocation(strings.TrimPrefix(spec, "file:")), nil
+	default:
+		return nil, fmt.Errorf("invalid key share location %q", spec)
+	}
+}
+
+func parseKMSProvider(spec string) (kms.Provider, error) {
+	switch {
+	case strings.HasPrefix(spec, "local-kms:"):
+		return kms.NewLocalProvider(strings.TrimPrefix(spec, "local-kms:"))
+	case strings.HasPrefix(spec, "aws-kms:"):
+		parts := strings.SplitN(strings.TrimPrefix(spec, "aws-kms:"), "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid AWS KMS key %q, expecting aws-kms:<region>/<key id>", spec)
+		}
+		return kms.NewAWSProvider(parts[0], parts[1])
+	default:
+		return nil, fmt.Errorf("unsupported KMS key %q", spec)
+	}
+}
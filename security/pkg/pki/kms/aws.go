@@ -0,0 +1,73 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kms
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awskms "github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// awsProvider wraps data encryption keys with an AWS KMS symmetric customer master key.
+type awsProvider struct {
+	client kmsiface.KMSAPI
+	keyID  string
+}
+
+// NewAWSProvider returns a Provider using the AWS KMS key keyID in region. Credentials are
+// taken from the default AWS credential chain.
+func NewAWSProvider(region, keyID string) (Provider, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create an AWS session: %v", err)
+	}
+	return &awsProvider{client: awskms.New(sess), keyID: keyID}, nil
+}
+
+// Name implements Provider.
+func (p *awsProvider) Name() string {
+	return "aws-kms"
+}
+
+// KeyID implements Provider.
+func (p *awsProvider) KeyID() string {
+	return p.keyID
+}
+
+// WrapKey implements Provider.
+func (p *awsProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	out, err := p.client.Encrypt(&awskms.EncryptInput{
+		KeyId:     aws.String(p.keyID),
+		Plaintext: dataKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+// UnwrapKey implements Provider.
+func (p *awsProvider) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	out, err := p.client.Decrypt(&awskms.DecryptInput{
+		CiphertextBlob: wrappedKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
@@ -0,0 +1,171 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kms implements envelope encryption of CA private keys with a key management service,
+// so that the CA private key is never stored in plaintext.
+package kms
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// encryptedKeyBlockType is the PEM block type of an envelope encrypted private key.
+	encryptedKeyBlockType = "ISTIO ENCRYPTED PRIVATE KEY"
+	// encryptedDataBlockType is the PEM block type of envelope encrypted data.
+	encryptedDataBlockType = "ISTIO ENCRYPTED DATA"
+
+	keyIDHeader      = "Key-Id"
+	providerHeader   = "Provider"
+	wrappedKeyHeader = "Wrapped-Key"
+	nonceHeader      = "Nonce"
+
+	// dataKeySize is the size of the AES-256 data encryption key.
+	dataKeySize = 32
+)
+
+// Provider wraps and unwraps data encryption keys with a key encryption key held by a KMS.
+type Provider interface {
+	// Name returns the name of the provider, recorded along with the encrypted key.
+	Name() string
+	// KeyID returns the ID of the key encryption key.
+	KeyID() string
+	// WrapKey encrypts the data encryption key.
+	WrapKey(dataKey []byte) ([]byte, error)
+	// UnwrapKey decrypts a data encryption key encrypted by WrapKey.
+	UnwrapKey(wrappedKey []byte) ([]byte, error)
+}
+
+// IsEncrypted returns true if keyPem is a private key encrypted by EncryptPrivateKey.
+func IsEncrypted(keyPem []byte) bool {
+	block, _ := pem.Decode(keyPem)
+	return block != nil && block.Type == encryptedKeyBlockType
+}
+
+// EncryptPrivateKey encrypts keyPem with a freshly generated data encryption key, and returns
+// a PEM block holding the ciphertext and the data encryption key wrapped by provider.
+func EncryptPrivateKey(provider Provider, keyPem []byte) ([]byte, error) {
+	return encrypt(provider, encryptedKeyBlockType, keyPem)
+}
+
+// DecryptPrivateKey decrypts a private key encrypted by EncryptPrivateKey. A plaintext key is
+// returned unchanged, so that existing secrets keep working until they are re-encrypted.
+func DecryptPrivateKey(provider Provider, keyPem []byte) ([]byte, error) {
+	block, _ := pem.Decode(keyPem)
+	if block == nil || block.Type != encryptedKeyBlockType {
+		return keyPem, nil
+	}
+	return decrypt(provider, block)
+}
+
+// EncryptData encrypts data as EncryptPrivateKey does, e.g. for backups holding private keys.
+func EncryptData(provider Provider, data []byte) ([]byte, error) {
+	return encrypt(provider, encryptedDataBlockType, data)
+}
+
+// DecryptData decrypts data encrypted by EncryptData. Unlike DecryptPrivateKey, it fails if
+// encrypted is not encrypted.
+func DecryptData(provider Provider, encrypted []byte) ([]byte, error) {
+	block, _ := pem.Decode(encrypted)
+	if block == nil || block.Type != encryptedDataBlockType {
+		return nil, fmt.Errorf("data is not encrypted by EncryptData")
+	}
+	return decrypt(provider, block)
+}
+
+func encrypt(provider Provider, blockType string, plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data encryption key (%v)", err)
+	}
+	nonce, ciphertext, err := seal(dataKey, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := provider.WrapKey(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to wrap data encryption key (%v)", provider.Name(), err)
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type: blockType,
+		Headers: map[string]string{
+			providerHeader:   provider.Name(),
+			keyIDHeader:      provider.KeyID(),
+			wrappedKeyHeader: base64.StdEncoding.EncodeToString(wrappedKey),
+			nonceHeader:      base64.StdEncoding.EncodeToString(nonce),
+		},
+		Bytes: ciphertext,
+	}), nil
+}
+
+func decrypt(provider Provider, block *pem.Block) ([]byte, error) {
+	if name := block.Headers[providerHeader]; name != provider.Name() {
+		return nil, fmt.Errorf("%s is encrypted by KMS provider %q, not %q", strings.ToLower(block.Type), name, provider.Name())
+	}
+	wrappedKey, err := base64.StdEncoding.DecodeString(block.Headers[wrappedKeyHeader])
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped data encryption key (%v)", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(block.Headers[nonceHeader])
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce (%v)", err)
+	}
+	dataKey, err := provider.UnwrapKey(wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to unwrap data encryption key (%v)", provider.Name(), err)
+	}
+	return open(dataKey, nonce, block.Bytes)
+}
+
+func seal(key, plaintext []byte) (nonce, ciphertext []byte, err error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce (%v)", err)
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, nil), nil
+}
+
+func open(key, nonce, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce size %d", len(nonce))
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt (%v)", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key (%v)", err)
+	}
+	return cipher.NewGCM(block)
+}
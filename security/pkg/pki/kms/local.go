@@ -0,0 +1,75 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kms
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// localProvider wraps data encryption keys with an AES-256 key encryption key read from a file,
+// e.g. a key mounted from an external secret store. It does not call out to a KMS.
+type localProvider struct {
+	keyID string
+	kek   []byte
+}
+
+// NewLocalProvider returns a Provider using the base64 encoded 32 byte key in kekFile as the
+// key encryption key.
+func NewLocalProvider(kekFile string) (Provider, error) {
+	content, err := ioutil.ReadFile(kekFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key encryption key file %s (%v)", kekFile, err)
+	}
+	kek, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("key encryption key in %s is not base64 encoded (%v)", kekFile, err)
+	}
+	if len(kek) != dataKeySize {
+		return nil, fmt.Errorf("key encryption key in %s has %d bytes, expected %d", kekFile, len(kek), dataKeySize)
+	}
+	return &localProvider{keyID: kekFile, kek: kek}, nil
+}
+
+// Name implements Provider.
+func (p *localProvider) Name() string {
+	return "local"
+}
+
+// KeyID implements Provider.
+func (p *localProvider) KeyID() string {
+	return p.keyID
+}
+
+// WrapKey implements Provider.
+func (p *localProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	nonce, ciphertext, err := seal(p.kek, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ciphertext...), nil
+}
+
+// UnwrapKey implements Provider.
+func (p *localProvider) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	// The nonce size of AES-GCM is 12 bytes.
+	const nonceSize = 12
+	if len(wrappedKey) < nonceSize {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+	return open(p.kek, wrappedKey[:nonceSize], wrappedKey[nonceSize:])
+}
@@ -0,0 +1,98 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kms
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// vaultTransitProvider wraps data encryption keys with a key of the Vault transit secrets engine.
+type vaultTransitProvider struct {
+	client  *api.Client
+	mount   string
+	keyName string
+}
+
+// NewVaultTransitProvider returns a Provider using the key keyName of the Vault transit secrets
+// engine mounted at mount.
+func NewVaultTransitProvider(vaultAddr, token, mount, keyName string) (Provider, error) {
+	config := api.DefaultConfig()
+	config.Address = vaultAddr
+	client, err := api.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a Vault client: %v", err)
+	}
+	client.SetToken(token)
+	return &vaultTransitProvider{
+		client:  client,
+		mount:   mount,
+		keyName: keyName,
+	}, nil
+}
+
+// Name implements Provider.
+func (p *vaultTransitProvider) Name() string {
+	return "vault-transit"
+}
+
+// KeyID implements Provider.
+func (p *vaultTransitProvider) KeyID() string {
+	return path.Join(p.mount, "keys", p.keyName)
+}
+
+// WrapKey implements Provider.
+func (p *vaultTransitProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	secret, err := p.client.Logical().Write(path.Join(p.mount, "encrypt", p.keyName), map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(dataKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := transitField(secret, "ciphertext")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(ciphertext), nil
+}
+
+// UnwrapKey implements Provider.
+func (p *vaultTransitProvider) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	secret, err := p.client.Logical().Write(path.Join(p.mount, "decrypt", p.keyName), map[string]interface{}{
+		"ciphertext": string(wrappedKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := transitField(secret, "plaintext")
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(plaintext)
+}
+
+func transitField(secret *api.Secret, field string) (string, error) {
+	if secret == nil || secret.Data == nil {
+		return "", fmt.Errorf("empty response from Vault transit engine")
+	}
+	value, ok := secret.Data[field].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("response from Vault transit engine has no %s", field)
+	}
+	return value, nil
+}
@@ -0,0 +1,98 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kmssigner
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// awsBackend signs with an AWS KMS asymmetric key.
+type awsBackend struct {
+	client kmsiface.KMSAPI
+	keyID  string
+	public crypto.PublicKey
+}
+
+// NewAWSSigner returns a Signer using the AWS KMS asymmetric key keyID in region. Credentials
+// are taken from the default AWS credential chain.
+func NewAWSSigner(region, keyID string) (*Signer, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create an AWS session: %v", err)
+	}
+	return newSigner(&awsBackend{client: kms.New(sess), keyID: keyID})
+}
+
+func (b *awsBackend) name() string {
+	return "aws"
+}
+
+func (b *awsBackend) publicKey() (crypto.PublicKey, error) {
+	out, err := b.client.GetPublicKey(&kms.GetPublicKeyInput{KeyId: aws.String(b.keyID)})
+	if err != nil {
+		return nil, err
+	}
+	if b.public, err = x509.ParsePKIXPublicKey(out.PublicKey); err != nil {
+		return nil, err
+	}
+	return b.public, nil
+}
+
+func (b *awsBackend) sign(digest []byte, hash crypto.Hash) ([]byte, error) {
+	algorithm, err := awsSigningAlgorithm(b.public, hash)
+	if err != nil {
+		return nil, err
+	}
+	out, err := b.client.Sign(&kms.SignInput{
+		KeyId:            aws.String(b.keyID),
+		Message:          digest,
+		MessageType:      aws.String(kms.MessageTypeDigest),
+		SigningAlgorithm: aws.String(algorithm),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// AWS KMS returns ASN.1 encoded ECDSA signatures.
+	return out.Signature, nil
+}
+
+func awsSigningAlgorithm(public crypto.PublicKey, hash crypto.Hash) (string, error) {
+	algorithms := map[crypto.Hash][2]string{
+		crypto.SHA256: {kms.SigningAlgorithmSpecRsassaPkcs1V15Sha256, kms.SigningAlgorithmSpecEcdsaSha256},
+		crypto.SHA384: {kms.SigningAlgorithmSpecRsassaPkcs1V15Sha384, kms.SigningAlgorithmSpecEcdsaSha384},
+		crypto.SHA512: {kms.SigningAlgorithmSpecRsassaPkcs1V15Sha512, kms.SigningAlgorithmSpecEcdsaSha512},
+	}
+	algorithm, ok := algorithms[hash]
+	if !ok {
+		return "", fmt.Errorf("unsupported hash function %v", hash)
+	}
+	switch public.(type) {
+	case *rsa.PublicKey:
+		return algorithm[0], nil
+	case *ecdsa.PublicKey:
+		return algorithm[1], nil
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", public)
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kmssigner
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	azureKeyVaultAPIVersion = "7.0"
+	azureKeyVaultResource   = "https://vault.azure.net"
+	// azureIMDSTokenURL is the managed identity token endpoint of the Azure instance metadata service.
+	azureIMDSTokenURL = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// azureBackend signs with an Azure Key Vault key.
+type azureBackend struct {
+	client *http.Client
+	// keyURL is the URL of the key version, e.g. https://myvault.vault.azure.net/keys/mykey/version.
+	keyURL string
+	// token returns the bearer token used to access Key Vault.
+	token  func() (string, error)
+	public crypto.PublicKey
+}
+
+// NewAzureSigner returns a Signer using the Azure Key Vault key version at keyURL, in the form
+// https://<vault>.vault.azure.net/keys/<name>/<version>. Access tokens are obtained with the
+// managed identity of the instance.
+func NewAzureSigner(keyURL string) (*Signer, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	tokens := &azureTokenCache{client: client}
+	return newSigner(&azureBackend{
+		client: client,
+		keyURL: strings.TrimSuffix(keyURL, "/"),
+		token:  tokens.get,
+	})
+}
+
+func (b *azureBackend) name() string {
+	return "azure"
+}
+
+// azureJWK is the subset of a JSON web key returned by Key Vault.
+type azureJWK struct {
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (b *azureBackend) publicKey() (crypto.PublicKey, error) {
+	var resp struct {
+		Key azureJWK `json:"key"`
+	}
+	if err := b.do(http.MethodGet, b.keyURL, nil, &resp); err != nil {
+		return nil, err
+	}
+	public, err := resp.Key.publicKey()
+	if err != nil {
+		return nil, err
+	}
+	b.public = public
+	return public, nil
+}
+
+func (b *azureBackend) sign(digest []byte, hash crypto.Hash) ([]byte, error) {
+	algorithm, err := azureSigningAlgorithm(b.public, hash)
+	if err != nil {
+		return nil, err
+	}
+	req := map[string]string{
+		"alg":   algorithm,
+		"value": base64.RawURLEncoding.EncodeToString(digest),
+	}
+	var resp struct {
+		Value string `json:"value"`
+	}
+	if err = b.do(http.MethodPost, b.keyURL+"/sign", req, &resp); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(resp.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding (%v)", err)
+	}
+	if _, ok := b.public.(*ecdsa.PublicKey); ok {
+		// Key Vault returns raw r || s ECDSA signatures.
+		return rawECDSAToASN1(sig)
+	}
+	return sig, nil
+}
+
+func (b *azureBackend) do(method, rawURL string, body, out interface{}) error {
+	token, err := b.token()
+	if err != nil {
+		return fmt.Errorf("failed to get access token (%v)", err)
+	}
+	var reqBody []byte
+	if body != nil {
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, rawURL+"?api-version="+azureKeyVaultAPIVersion, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("key vault returned %s: %s", resp.Status, string(respBody))
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+func (k *azureJWK) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA", "RSA-HSM":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus (%v)", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent (%v)", err)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC", "EC-HSM":
+		curves := map[string]elliptic.Curve{
+			"P-256": elliptic.P256(),
+			"P-384": elliptic.P384(),
+			"P-521": elliptic.P521(),
+		}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC point (%v)", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC point (%v)", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func azureSigningAlgorithm(public crypto.PublicKey, hash crypto.Hash) (string, error) {
+	bits := map[crypto.Hash]string{
+		crypto.SHA256: "256",
+		crypto.SHA384: "384",
+		crypto.SHA512: "512",
+	}
+	size, ok := bits[hash]
+	if !ok {
+		return "", fmt.Errorf("unsupported hash function %v", hash)
+	}
+	switch public.(type) {
+	case *rsa.PublicKey:
+		return "RS" + size, nil
+	case *ecdsa.PublicKey:
+		return "ES" + size, nil
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", public)
+	}
+}
+
+// azureTokenCache caches the Key Vault access token of the managed identity of the instance.
+type azureTokenCache struct {
+	client *http.Client
+
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// get returns a cached access token, or fetches a new one if it is about to expire.
+func (c *azureTokenCache) get() (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.token != "" && time.Until(c.expires) > time.Minute {
+		return c.token, nil
+	}
+	query := url.Values{}
+	query.Set("api-version", "2018-02-01")
+	query.Set("resource", azureKeyVaultResource)
+	req, err := http.NewRequest(http.MethodGet, azureIMDSTokenURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata", "true")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("instance metadata service returned %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresOn   string `json:"expires_on"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	expiresOn, err := strconv.ParseInt(token.ExpiresOn, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid token expiration %q", token.ExpiresOn)
+	}
+	c.token, c.expires = token.AccessToken, time.Unix(expiresOn, 0)
+	return c.token, nil
+}
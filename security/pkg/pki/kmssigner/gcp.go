@@ -0,0 +1,93 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kmssigner
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	cloudkms "cloud.google.com/go/kms/apiv1"
+	gax "github.com/googleapis/gax-go/v2"
+	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+)
+
+// gcpClient is the subset of the Cloud KMS client used by the signer.
+type gcpClient interface {
+	GetPublicKey(ctx context.Context, req *kmspb.GetPublicKeyRequest, opts ...gax.CallOption) (*kmspb.PublicKey, error)
+	AsymmetricSign(ctx context.Context, req *kmspb.AsymmetricSignRequest,
+		opts ...gax.CallOption) (*kmspb.AsymmetricSignResponse, error)
+}
+
+// gcpBackend signs with a Cloud KMS asymmetric key version.
+type gcpBackend struct {
+	client     gcpClient
+	keyVersion string
+	timeout    time.Duration
+}
+
+// NewGCPSigner returns a Signer using the Cloud KMS asymmetric signing key version keyVersion, in
+// the form projects/*/locations/*/keyRings/*/cryptoKeys/*/cryptoKeyVersions/*. Credentials are
+// taken from the application default credentials.
+func NewGCPSigner(ctx context.Context, keyVersion string) (*Signer, error) {
+	client, err := cloudkms.NewKeyManagementClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a Cloud KMS client: %v", err)
+	}
+	return newSigner(&gcpBackend{client: client, keyVersion: keyVersion, timeout: 10 * time.Second})
+}
+
+func (b *gcpBackend) name() string {
+	return "gcp"
+}
+
+func (b *gcpBackend) publicKey() (crypto.PublicKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+	resp, err := b.client.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: b.keyVersion})
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(resp.Pem))
+	if block == nil {
+		return nil, fmt.Errorf("invalid public key PEM")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func (b *gcpBackend) sign(digest []byte, hash crypto.Hash) ([]byte, error) {
+	req := &kmspb.AsymmetricSignRequest{Name: b.keyVersion}
+	switch hash {
+	case crypto.SHA256:
+		req.Digest = &kmspb.Digest{Digest: &kmspb.Digest_Sha256{Sha256: digest}}
+	case crypto.SHA384:
+		req.Digest = &kmspb.Digest{Digest: &kmspb.Digest_Sha384{Sha384: digest}}
+	case crypto.SHA512:
+		req.Digest = &kmspb.Digest{Digest: &kmspb.Digest_Sha512{Sha512: digest}}
+	default:
+		return nil, fmt.Errorf("unsupported hash function %v", hash)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+	resp, err := b.client.AsymmetricSign(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	// Cloud KMS returns ASN.1 encoded ECDSA signatures.
+	return resp.Signature, nil
+}
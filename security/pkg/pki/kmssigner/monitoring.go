@@ -0,0 +1,44 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kmssigner
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	backendTag   = monitoring.MustCreateLabel("backend")
+	operationTag = monitoring.MustCreateLabel("operation")
+
+	kmsSignLatency = monitoring.NewDistribution(
+		"citadel_kms_sign_latency_seconds",
+		"Latency in seconds of signature operations delegated to a KMS.",
+		[]float64{.01, .05, .1, .25, .5, 1, 2.5, 5},
+		monitoring.WithLabels(backendTag),
+	)
+
+	kmsErrorCounts = monitoring.NewSum(
+		"citadel_kms_error_count",
+		"The number of failed KMS operations, by backend and operation.",
+		monitoring.WithLabels(backendTag, operationTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		kmsSignLatency,
+		kmsErrorCounts,
+	)
+}
@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kmssigner implements crypto.Signer on top of asymmetric keys held by cloud key management
+// services, so that the CA private key never needs to be stored in the cluster.
+package kmssigner
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+// backend signs digests with an asymmetric key held by a KMS.
+type backend interface {
+	// name returns the name of the backend, used as a metric label.
+	name() string
+	// publicKey returns the public key of the KMS key.
+	publicKey() (crypto.PublicKey, error)
+	// sign signs digest, computed with hash, with the KMS key.
+	sign(digest []byte, hash crypto.Hash) ([]byte, error)
+}
+
+// Signer is a crypto.Signer delegating signature operations to a KMS.
+type Signer struct {
+	backend backend
+	public  crypto.PublicKey
+}
+
+func newSigner(b backend) (*Signer, error) {
+	public, err := b.publicKey()
+	if err != nil {
+		kmsErrorCounts.With(backendTag.Value(b.name()), operationTag.Value("get_public_key")).Increment()
+		return nil, fmt.Errorf("%s: failed to get public key (%v)", b.name(), err)
+	}
+	switch public.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("%s: unsupported public key type %T", b.name(), public)
+	}
+	return &Signer{backend: b, public: public}, nil
+}
+
+// Public implements crypto.Signer.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign implements crypto.Signer. Only RSA PKCS#1 v1.5 and ECDSA signatures are supported.
+func (s *Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, fmt.Errorf("%s: RSA-PSS signatures are not supported", s.backend.name())
+	}
+	start := time.Now()
+	sig, err := s.backend.sign(digest, opts.HashFunc())
+	kmsSignLatency.With(backendTag.Value(s.backend.name())).Record(time.Since(start).Seconds())
+	if err != nil {
+		kmsErrorCounts.With(backendTag.Value(s.backend.name()), operationTag.Value("sign")).Increment()
+		return nil, fmt.Errorf("%s: failed to sign (%v)", s.backend.name(), err)
+	}
+	return sig, nil
+}
+
+// rawECDSAToASN1 converts a raw r || s ECDSA signature into the ASN.1 encoding returned by crypto.Signer.
+func rawECDSAToASN1(raw []byte) ([]byte, error) {
+	if len(raw) == 0 || len(raw)%2 != 0 {
+		return nil, fmt.Errorf("invalid ECDSA signature length %d", len(raw))
+	}
+	return asn1.Marshal(struct{ R, S *big.Int }{
+		R: new(big.Int).SetBytes(raw[:len(raw)/2]),
+		S: new(big.Int).SetBytes(raw[len(raw)/2:]),
+	})
+}
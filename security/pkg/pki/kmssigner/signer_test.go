@@ -0,0 +1,229 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kmssigner
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	gax "github.com/googleapis/gax-go/v2"
+	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+)
+
+func newTestKeys(t *testing.T) map[string]crypto.Signer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]crypto.Signer{"RSA": rsaKey, "ECDSA": ecKey}
+}
+
+func verify(t *testing.T, id string, public crypto.PublicKey, digest, sig []byte) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, sig); err != nil {
+			t.Errorf("%s: RSA signature does not verify: %v", id, err)
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, sig) {
+			t.Errorf("%s: ECDSA signature does not verify", id)
+		}
+	}
+}
+
+// fakeAWSKMS emulates AWS KMS asymmetric keys with a software key.
+type fakeAWSKMS struct {
+	kmsiface.KMSAPI
+	key crypto.Signer
+}
+
+func (f *fakeAWSKMS) GetPublicKey(_ *kms.GetPublicKeyInput) (*kms.GetPublicKeyOutput, error) {
+	der, err := x509.MarshalPKIXPublicKey(f.key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &kms.GetPublicKeyOutput{PublicKey: der}, nil
+}
+
+func (f *fakeAWSKMS) Sign(in *kms.SignInput) (*kms.SignOutput, error) {
+	if aws.StringValue(in.MessageType) != kms.MessageTypeDigest {
+		return nil, fmt.Errorf("unexpected message type %s", aws.StringValue(in.MessageType))
+	}
+	sig, err := f.key.Sign(rand.Reader, in.Message, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.SignOutput{Signature: sig, SigningAlgorithm: in.SigningAlgorithm}, nil
+}
+
+func TestAWSSigner(t *testing.T) {
+	digest := sha256.Sum256([]byte("message"))
+	for id, key := range newTestKeys(t) {
+		signer, err := newSigner(&awsBackend{client: &fakeAWSKMS{key: key}, keyID: "alias/istio-ca"})
+		if err != nil {
+			t.Fatalf("%s: newSigner() error: %v", id, err)
+		}
+		sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("%s: Sign() error: %v", id, err)
+		}
+		verify(t, id, signer.Public(), digest[:], sig)
+		if _, err = signer.Sign(rand.Reader, digest[:], crypto.MD5); err == nil {
+			t.Errorf("%s: expected error for an unsupported hash", id)
+		}
+	}
+}
+
+// fakeGCPKMS emulates Cloud KMS asymmetric keys with a software key.
+type fakeGCPKMS struct {
+	key crypto.Signer
+}
+
+func (f *fakeGCPKMS) GetPublicKey(_ context.Context, _ *kmspb.GetPublicKeyRequest,
+	_ ...gax.CallOption) (*kmspb.PublicKey, error) {
+	der, err := x509.MarshalPKIXPublicKey(f.key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &kmspb.PublicKey{Pem: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))}, nil
+}
+
+func (f *fakeGCPKMS) AsymmetricSign(_ context.Context, req *kmspb.AsymmetricSignRequest,
+	_ ...gax.CallOption) (*kmspb.AsymmetricSignResponse, error) {
+	sig, err := f.key.Sign(rand.Reader, req.Digest.GetSha256(), crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	return &kmspb.AsymmetricSignResponse{Signature: sig}, nil
+}
+
+func TestGCPSigner(t *testing.T) {
+	digest := sha256.Sum256([]byte("message"))
+	for id, key := range newTestKeys(t) {
+		signer, err := newSigner(&gcpBackend{client: &fakeGCPKMS{key: key}, keyVersion: "key", timeout: time.Second})
+		if err != nil {
+			t.Fatalf("%s: newSigner() error: %v", id, err)
+		}
+		sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("%s: Sign() error: %v", id, err)
+		}
+		verify(t, id, signer.Public(), digest[:], sig)
+	}
+}
+
+// fakeKeyVault emulates the Azure Key Vault key get and sign APIs with a software key.
+type fakeKeyVault struct {
+	key crypto.Signer
+}
+
+func (f *fakeKeyVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	enc := base64.RawURLEncoding.EncodeToString
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/keys/ca/v1":
+		var jwk map[string]string
+		switch pub := f.key.Public().(type) {
+		case *rsa.PublicKey:
+			jwk = map[string]string{"kty": "RSA", "n": enc(pub.N.Bytes()), "e": enc(big.NewInt(int64(pub.E)).Bytes())}
+		case *ecdsa.PublicKey:
+			jwk = map[string]string{"kty": "EC", "crv": "P-256", "x": enc(pub.X.Bytes()), "y": enc(pub.Y.Bytes())}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"key": jwk})
+	case r.Method == http.MethodPost && r.URL.Path == "/keys/ca/v1/sign":
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		digest, _ := base64.RawURLEncoding.DecodeString(req["value"])
+		sig, err := f.key.Sign(rand.Reader, digest, crypto.SHA256)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if strings.HasPrefix(req["alg"], "ES") {
+			// Key Vault returns raw r || s.
+			var parsed struct{ R, S *big.Int }
+			if _, err = asn1.Unmarshal(sig, &parsed); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			sig = make([]byte, 64)
+			parsed.R.FillBytes(sig[:32])
+			parsed.S.FillBytes(sig[32:])
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"value": enc(sig)})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestAzureSigner(t *testing.T) {
+	digest := sha256.Sum256([]byte("message"))
+	for id, key := range newTestKeys(t) {
+		server := httptest.NewServer(&fakeKeyVault{key: key})
+		signer, err := newSigner(&azureBackend{
+			client: server.Client(),
+			keyURL: server.URL + "/keys/ca/v1",
+			token:  func() (string, error) { return "token", nil },
+		})
+		if err != nil {
+			t.Fatalf("%s: newSigner() error: %v", id, err)
+		}
+		sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("%s: Sign() error: %v", id, err)
+		}
+		verify(t, id, signer.Public(), digest[:], sig)
+		server.Close()
+	}
+}
+
+func TestSignerRejectsPSS(t *testing.T) {
+	key := newTestKeys(t)["RSA"]
+	signer, err := newSigner(&awsBackend{client: &fakeAWSKMS{key: key}, keyID: "alias/istio-ca"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte("message"))
+	if _, err = signer.Sign(rand.Reader, digest[:], &rsa.PSSOptions{Hash: crypto.SHA256}); err == nil {
+		t.Errorf("expected error for RSA-PSS")
+	}
+}
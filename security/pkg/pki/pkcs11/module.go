@@ -0,0 +1,132 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build pkcs11
+
+package pkcs11
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/miekg/pkcs11"
+)
+
+// moduleSession is a session with a PKCS#11 module.
+type moduleSession struct {
+	ctx      *pkcs11.Ctx
+	handle   pkcs11.SessionHandle
+	keyObj   pkcs11.ObjectHandle
+	loggedIn bool
+}
+
+// NewSigner returns a Signer using the private key with config.KeyLabel in the token with
+// config.TokenLabel. publicKey is the public key of the private key, usually taken from the
+// CA cert, since the private key itself cannot be read from the HSM.
+func NewSigner(config Config, publicKey crypto.PublicKey) (*Signer, error) {
+	ctx := pkcs11.New(config.ModulePath)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %s", config.ModulePath)
+	}
+	if err := ctx.Initialize(); err != nil {
+		return nil, fmt.Errorf("failed to initialize PKCS#11 module %s (%v)", config.ModulePath, err)
+	}
+	s, err := openSession(ctx, config)
+	if err != nil {
+		_ = ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+	return &Signer{publicKey: publicKey, session: s}, nil
+}
+
+func openSession(ctx *pkcs11.Ctx, config Config) (*moduleSession, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PKCS#11 slots (%v)", err)
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil || info.Label != config.TokenLabel {
+			continue
+		}
+		handle, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open a session with token %s (%v)", config.TokenLabel, err)
+		}
+		s := &moduleSession{ctx: ctx, handle: handle}
+		if err = ctx.Login(handle, pkcs11.CKU_USER, config.PIN); err != nil {
+			_ = s.closeSession()
+			return nil, fmt.Errorf("failed to log in to token %s (%v)", config.TokenLabel, err)
+		}
+		s.loggedIn = true
+		if s.keyObj, err = s.findKey(config.KeyLabel); err != nil {
+			_ = s.closeSession()
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("PKCS#11 token %s is not found", config.TokenLabel)
+}
+
+func (s *moduleSession) findKey(label string) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := s.ctx.FindObjectsInit(s.handle, template); err != nil {
+		return 0, fmt.Errorf("failed to search private key %s (%v)", label, err)
+	}
+	objs, _, err := s.ctx.FindObjects(s.handle, 1)
+	_ = s.ctx.FindObjectsFinal(s.handle)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search private key %s (%v)", label, err)
+	}
+	if len(objs) == 0 {
+		return 0, fmt.Errorf("private key %s is not found", label)
+	}
+	return objs[0], nil
+}
+
+// Sign implements session.
+func (s *moduleSession) Sign(m mechanism, data []byte) ([]byte, error) {
+	var mech *pkcs11.Mechanism
+	switch m {
+	case mechanismRSAPKCS:
+		mech = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)
+	case mechanismECDSA:
+		mech = pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)
+	default:
+		return nil, fmt.Errorf("unsupported mechanism %d", m)
+	}
+	if err := s.ctx.SignInit(s.handle, []*pkcs11.Mechanism{mech}, s.keyObj); err != nil {
+		return nil, fmt.Errorf("failed to initialize PKCS#11 signing (%v)", err)
+	}
+	return s.ctx.Sign(s.handle, data)
+}
+
+// Close implements session. It also unloads the PKCS#11 module.
+func (s *moduleSession) Close() error {
+	err := s.closeSession()
+	_ = s.ctx.Finalize()
+	s.ctx.Destroy()
+	return err
+}
+
+func (s *moduleSession) closeSession() error {
+	if s.loggedIn {
+		_ = s.ctx.Logout(s.handle)
+	}
+	return s.ctx.CloseSession(s.handle)
+}
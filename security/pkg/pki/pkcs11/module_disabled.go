@@ -0,0 +1,27 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !pkcs11
+
+package pkcs11
+
+import (
+	"crypto"
+	"fmt"
+)
+
+// NewSigner returns an error, since loading a PKCS#11 module requires the pkcs11 build tag and cgo.
+func NewSigner(_ Config, _ crypto.PublicKey) (*Signer, error) {
+	return nil, fmt.Errorf("PKCS#11 support requires a binary built with the pkcs11 build tag")
+}
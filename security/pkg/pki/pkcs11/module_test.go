@@ -0,0 +1,177 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+// +build pkcs11
+
+package pkcs11
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+)
+
+const (
+	testTokenLabel = "istio-test"
+	testKeyLabel   = "istio-ca-key"
+	testPIN        = "1234"
+)
+
+// newTestToken initializes a SoftHSM token holding a P-256 key in a temporary directory and
+// returns the public key. PKCS11_TEST_MODULE is the path of the SoftHSM module, the test is
+// skipped if it is not set.
+func newTestToken(t *testing.T) (string, crypto.PublicKey) {
+	t.Helper()
+	module := os.Getenv("PKCS11_TEST_MODULE")
+	if module == "" {
+		t.Skip("PKCS11_TEST_MODULE is not set")
+	}
+	dir, err := ioutil.TempDir("", "softhsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	conf := filepath.Join(dir, "softhsm2.conf")
+	if err = ioutil.WriteFile(conf, []byte("directories.tokendir = "+dir+"\nobjectstore.backend = file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Setenv("SOFTHSM2_CONF", conf); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		t.Fatalf("failed to load PKCS#11 module %s", module)
+	}
+	if err = ctx.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	// The module is finalized before the signer initializes it again.
+	defer func() {
+		_ = ctx.Finalize()
+		ctx.Destroy()
+	}()
+	slots, err := ctx.GetSlotList(false)
+	if err != nil || len(slots) == 0 {
+		t.Fatalf("no PKCS#11 slot found: %v", err)
+	}
+	if err = ctx.InitToken(slots[0], testPIN, testTokenLabel); err != nil {
+		t.Fatal(err)
+	}
+	// SoftHSM moves the initialized token to a new slot.
+	if slots, err = ctx.GetSlotList(true); err != nil || len(slots) == 0 {
+		t.Fatalf("no initialized PKCS#11 token found: %v", err)
+	}
+	session, err := ctx.OpenSession(slots[0], pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ctx.CloseSession(session) }()
+	if err = ctx.Login(session, pkcs11.CKU_SO, testPIN); err != nil {
+		t.Fatal(err)
+	}
+	if err = ctx.InitPIN(session, testPIN); err != nil {
+		t.Fatal(err)
+	}
+	if err = ctx.Logout(session); err != nil {
+		t.Fatal(err)
+	}
+	if err = ctx.Login(session, pkcs11.CKU_USER, testPIN); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ctx.Logout(session) }()
+
+	curve, err := asn1.Marshal(asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _, err := ctx.GenerateKeyPair(session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, curve),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, testKeyLabel),
+		},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, testKeyLabel),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs, err := ctx.GetAttributeValue(session, pub, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// CKA_EC_POINT is the DER encoding of an OCTET STRING holding the uncompressed point.
+	var point []byte
+	if _, err = asn1.Unmarshal(attrs[0].Value, &point); err != nil {
+		t.Fatal(err)
+	}
+	x, y := elliptic.Unmarshal(elliptic.P256(), point)
+	if x == nil {
+		t.Fatal("invalid EC point of the generated key")
+	}
+	return module, &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+}
+
+func TestModuleSigner(t *testing.T) {
+	module, publicKey := newTestToken(t)
+
+	signer, err := NewSigner(Config{
+		ModulePath: module,
+		TokenLabel: testTokenLabel,
+		PIN:        testPIN,
+		KeyLabel:   testKeyLabel,
+	}, publicKey)
+	if err != nil {
+		t.Fatalf("failed to create the signer: %v", err)
+	}
+
+	digest := sha256.Sum256([]byte("hello"))
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if !ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), digest[:], sig) {
+		t.Error("the signature of the HSM key does not verify")
+	}
+	// Closing the signer finalizes the module, so that it can be initialized again.
+	if err = signer.Close(); err != nil {
+		t.Fatalf("failed to close the signer: %v", err)
+	}
+
+	if _, err = NewSigner(Config{
+		ModulePath: module,
+		TokenLabel: testTokenLabel,
+		PIN:        testPIN,
+		KeyLabel:   "unknown",
+	}, publicKey); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
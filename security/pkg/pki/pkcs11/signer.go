@@ -0,0 +1,123 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pkcs11 implements a crypto.Signer backed by a private key held in an HSM, accessed
+// through PKCS#11. The private key never leaves the HSM.
+//
+// Loading a PKCS#11 module requires cgo, while the release binaries are built without it. The
+// module support is therefore only built with the pkcs11 build tag: `make pilot-discovery-pkcs11`
+// builds a pilot-discovery reading the PKCS11_* env vars, and `make pkcs11-test` tests it against
+// SoftHSM.
+package pkcs11
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+)
+
+// Config is the configuration of a PKCS#11 signer.
+type Config struct {
+	// ModulePath is the path of the PKCS#11 module shared library of the HSM.
+	ModulePath string
+	// TokenLabel is the label of the token holding the key.
+	TokenLabel string
+	// PIN is the user PIN of the token.
+	PIN string
+	// KeyLabel is the label of the private key object.
+	KeyLabel string
+}
+
+// mechanism is a signature mechanism supported by the signer.
+type mechanism int
+
+const (
+	// mechanismRSAPKCS is CKM_RSA_PKCS, RSA PKCS#1 v1.5 signing of a DigestInfo.
+	mechanismRSAPKCS mechanism = iota
+	// mechanismECDSA is CKM_ECDSA, ECDSA signing of a digest.
+	mechanismECDSA
+)
+
+// session signs data with the private key in the HSM.
+type session interface {
+	// Sign signs data with the given mechanism.
+	Sign(m mechanism, data []byte) ([]byte, error)
+	// Close releases the session.
+	Close() error
+}
+
+// Signer is a crypto.Signer whose private key is held in an HSM.
+type Signer struct {
+	publicKey crypto.PublicKey
+	// mutex serializes access to the session, which is not safe for concurrent use.
+	mutex   sync.Mutex
+	session session
+}
+
+// digestInfoPrefixes are the DER encoded DigestInfo prefixes of the hashes supported
+// for RSA PKCS#1 v1.5 signatures, see RFC 8017 section 9.2.
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// Public implements crypto.Signer.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign implements crypto.Signer. The digest is signed in the HSM.
+func (s *Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch s.publicKey.(type) {
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return nil, fmt.Errorf("RSA-PSS signatures are not supported")
+		}
+		prefix, ok := digestInfoPrefixes[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash function %v", opts.HashFunc())
+		}
+		return s.session.Sign(mechanismRSAPKCS, append(append([]byte{}, prefix...), digest...))
+	case *ecdsa.PublicKey:
+		raw, err := s.session.Sign(mechanismECDSA, digest)
+		if err != nil {
+			return nil, err
+		}
+		// PKCS#11 returns the raw r || s, while crypto.Signer returns an ASN.1 encoded signature.
+		if len(raw)%2 != 0 {
+			return nil, fmt.Errorf("invalid ECDSA signature length %d", len(raw))
+		}
+		return asn1.Marshal(struct{ R, S *big.Int }{
+			R: new(big.Int).SetBytes(raw[:len(raw)/2]),
+			S: new(big.Int).SetBytes(raw[len(raw)/2:]),
+		})
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", s.publicKey)
+	}
+}
+
+// Close releases the HSM session.
+func (s *Signer) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.session.Close()
+}
@@ -0,0 +1,103 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pkcs11 implements a crypto.Signer backed by a private key held in an HSM, accessed
+package pkcs11
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// fakeSession emulates the raw PKCS#11 mechanisms with a software key.
+type fakeSession struct {
+	key crypto.Signer
+}
+
+func (f *fakeSession) Sign(m mechanism, data []byte) ([]byte, error) {
+	switch m {
+	case mechanismRSAPKCS:
+		// With a zero hash, SignPKCS1v15 signs the DigestInfo as is, like CKM_RSA_PKCS.
+		return rsa.SignPKCS1v15(rand.Reader, f.key.(*rsa.PrivateKey), 0, data)
+	case mechanismECDSA:
+		key := f.key.(*ecdsa.PrivateKey)
+		r, s, err := ecdsa.Sign(rand.Reader, key, data)
+		if err != nil {
+			return nil, err
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		raw := make([]byte, 2*size)
+		r.FillBytes(raw[:size])
+		s.FillBytes(raw[size:])
+		return raw, nil
+	}
+	return nil, fmt.Errorf("unsupported mechanism %d", m)
+}
+
+func (f *fakeSession) Close() error {
+	return nil
+}
+
+func TestSignerRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &Signer{publicKey: key.Public(), session: &fakeSession{key: key}}
+	digest := sha256.Sum256([]byte("message"))
+
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Sign() error: %v", err)
+	}
+	if err = rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+
+	if _, err = signer.Sign(rand.Reader, digest[:], &rsa.PSSOptions{Hash: crypto.SHA256}); err == nil {
+		t.Errorf("expected error for RSA-PSS")
+	}
+	if _, err = signer.Sign(rand.Reader, digest[:], crypto.MD5); err == nil {
+		t.Errorf("expected error for unsupported hash")
+	}
+}
+
+func TestSignerECDSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &Signer{publicKey: key.Public(), session: &fakeSession{key: key}}
+	digest := sha256.Sum256([]byte("message"))
+
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Sign() error: %v", err)
+	}
+	var parsed struct{ R, S *big.Int }
+	if _, err = asn1.Unmarshal(sig, &parsed); err != nil {
+		t.Fatalf("signature is not ASN.1 encoded: %v", err)
+	}
+	if !ecdsa.Verify(&key.PublicKey, digest[:], parsed.R, parsed.S) {
+		t.Errorf("signature does not verify")
+	}
+}
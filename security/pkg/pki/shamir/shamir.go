@@ -0,0 +1,143 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shamir implements Shamir's secret sharing over GF(2^8), splitting a secret into shares
+// so that any threshold of them reconstructs the secret and fewer reveal nothing about it.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// MaxShares is the max number of shares of a secret.
+const MaxShares = 255
+
+// expTable and logTable are the exponentials and logarithms of the generator 3 of GF(2^8) with the
+// AES polynomial x^8 + x^4 + x^3 + x + 1.
+var expTable, logTable [256]byte
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		logTable[x] = byte(i)
+		// Multiply x by the generator 3, i.e. x*2 + x.
+		x2 := x << 1
+		if x&0x80 != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+	expTable[255] = expTable[0]
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])+int(logTable[b]))%255]
+}
+
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])-int(logTable[b])+255)%255]
+}
+
+// Split splits secret into n shares, any threshold of which reconstruct it with Combine. Each share
+// is one byte longer than secret: the last byte is the x coordinate of the share.
+func Split(secret []byte, n, threshold int) ([][]byte, error) {
+	switch {
+	case len(secret) == 0:
+		return nil, errors.New("cannot split an empty secret")
+	case n < 2 || n > MaxShares:
+		return nil, fmt.Errorf("the number of shares %d is not between 2 and %d", n, MaxShares)
+	case threshold < 2 || threshold > n:
+		return nil, fmt.Errorf("the threshold %d is not between 2 and the number of shares %d", threshold, n)
+	}
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = byte(i + 1)
+	}
+	// coefficients[0] is the secret byte, the others are random.
+	coefficients := make([]byte, threshold)
+	for j, b := range secret {
+		coefficients[0] = b
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, fmt.Errorf("failed to generate the polynomial: %v", err)
+		}
+		for i := range shares {
+			shares[i][j] = evaluate(coefficients, byte(i+1))
+		}
+	}
+	for i := range coefficients {
+		coefficients[i] = 0
+	}
+	return shares, nil
+}
+
+// evaluate evaluates the polynomial with coefficients at x with Horner's method.
+func evaluate(coefficients []byte, x byte) byte {
+	y := byte(0)
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coefficients[i]
+	}
+	return y
+}
+
+// Combine reconstructs the secret from shares returned by Split. It returns a wrong secret, not an
+// error, if fewer shares than the threshold are given.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("at least 2 shares are required")
+	}
+	size := len(shares[0])
+	if size < 2 {
+		return nil, errors.New("the shares are too short")
+	}
+	xs := make([]byte, len(shares))
+	seen := map[byte]bool{}
+	for i, share := range shares {
+		if len(share) != size {
+			return nil, errors.New("the shares have different lengths")
+		}
+		x := share[size-1]
+		if x == 0 || seen[x] {
+			return nil, fmt.Errorf("invalid or duplicate share %d", x)
+		}
+		seen[x] = true
+		xs[i] = x
+	}
+	secret := make([]byte, size-1)
+	for j := range secret {
+		// Lagrange interpolation at x = 0.
+		var y byte
+		for i, xi := range xs {
+			basis := byte(1)
+			for k, xk := range xs {
+				if k != i {
+					// (0 - xk) / (xi - xk), where subtraction is xor.
+					basis = mul(basis, div(xk, xi^xk))
+				}
+			}
+			y ^= mul(shares[i][j], basis)
+		}
+		secret[j] = y
+	}
+	return secret, nil
+}
@@ -51,5 +51,8 @@ cat int-cert.pem >> int-cert-chain.pem
 cp int-cert-chain.pem int2-cert-chain.pem
 cat int2-cert.pem >> int2-cert-chain.pem
 
+# Encrypted PKCS#8 copy of the intermediate CA key, with passphrase "istio".
+openssl pkcs8 -topk8 -in int-key.pem -v2 aes-128-cbc -v2prf hmacWithSHA1 -passout pass:istio -out int-key-encrypted.pem
+
 rm ./*csr
 rm ./*srl
@@ -0,0 +1,138 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// ParsePemEncodedCertificates parses all the certificates in the PEM-encoded bytes, in order.
+func ParsePemEncodedCertificates(certsPEM []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for rest := certsPEM; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse X.509 certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("invalid PEM encoded certificate")
+	}
+	return certs, nil
+}
+
+// OrderCertChain orders the PEM-encoded certs from the leaf, the first cert that issues none of
+// the others, up to its root, so that each cert is issued by the cert that follows it. Chains of
+// any number of intermediate CAs are supported, e.g. issuing CA -> policy CA -> root CA. Duplicate
+// certs are dropped and certs that are not part of the path, e.g. other roots, are kept at the end.
+func OrderCertChain(certsPEM []byte) ([]byte, error) {
+	certs, err := ParsePemEncodedCertificates(certsPEM)
+	if err != nil {
+		return nil, err
+	}
+	var remaining []*x509.Certificate
+	for _, c := range certs {
+		if !containsCert(remaining, c) {
+			remaining = append(remaining, c)
+		}
+	}
+
+	leaf := 0
+	for i, c := range remaining {
+		if !issuesAny(c, remaining) {
+			leaf = i
+			break
+		}
+	}
+	ordered := []*x509.Certificate{remaining[leaf]}
+	remaining = append(remaining[:leaf:leaf], remaining[leaf+1:]...)
+	for {
+		last := ordered[len(ordered)-1]
+		if last.CheckSignatureFrom(last) == nil {
+			break
+		}
+		issuer := -1
+		for i, c := range remaining {
+			if last.CheckSignatureFrom(c) == nil {
+				issuer = i
+				break
+			}
+		}
+		if issuer < 0 {
+			break
+		}
+		ordered = append(ordered, remaining[issuer])
+		remaining = append(remaining[:issuer:issuer], remaining[issuer+1:]...)
+	}
+	ordered = append(ordered, remaining...)
+
+	var out []byte
+	for _, c := range ordered {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+	}
+	return out, nil
+}
+
+// SameCertificates returns true if the PEM-encoded bytes hold the same set of certs, regardless
+// of their order and PEM formatting.
+func SameCertificates(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	certsA, errA := ParsePemEncodedCertificates(a)
+	certsB, errB := ParsePemEncodedCertificates(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	for _, c := range certsA {
+		if !containsCert(certsB, c) {
+			return false
+		}
+	}
+	for _, c := range certsB {
+		if !containsCert(certsA, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// issuesAny returns true if the cert issued any of the other certs.
+func issuesAny(cert *x509.Certificate, certs []*x509.Certificate) bool {
+	for _, c := range certs {
+		if !c.Equal(cert) && c.CheckSignatureFrom(cert) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func containsCert(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
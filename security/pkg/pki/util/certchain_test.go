@@ -0,0 +1,110 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func readCertChainTestFiles(t *testing.T, files ...string) []byte {
+	var out []byte
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, b...)
+	}
+	return out
+}
+
+func TestOrderCertChain(t *testing.T) {
+	// The chain file lists the root first: root -> int -> int2.
+	ordered, err := OrderCertChain(readCertChainTestFiles(t, int2CertChainFile))
+	if err != nil {
+		t.Fatalf("OrderCertChain error: %v", err)
+	}
+	certs, err := ParsePemEncodedCertificates(ordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ParsePemEncodedCertificates(readCertChainTestFiles(t, int2CertFile, intCertFile, rootCertFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != len(expected) {
+		t.Fatalf("got %d certs, expected %d", len(certs), len(expected))
+	}
+	for i := range certs {
+		if !certs[i].Equal(expected[i]) {
+			t.Errorf("cert %d is %q, expected %q", i, certs[i].Subject, expected[i].Subject)
+		}
+	}
+
+	// Duplicates are dropped and unrelated certs are kept at the end.
+	ordered, err = OrderCertChain(readCertChainTestFiles(t, rootCertFile, int2CertFile, int2CertChainFile, anotherRootCertFile))
+	if err != nil {
+		t.Fatalf("OrderCertChain error: %v", err)
+	}
+	certs, err = ParsePemEncodedCertificates(ordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 4 || !certs[0].Equal(expected[0]) || !certs[2].Equal(expected[2]) {
+		t.Errorf("unexpected order of certs: %s", ordered)
+	}
+
+	if _, err := OrderCertChain([]byte("invalid")); err == nil {
+		t.Error("expected an error for invalid certs")
+	}
+}
+
+func TestSameCertificates(t *testing.T) {
+	chain := readCertChainTestFiles(t, int2CertChainFile)
+	reversed := readCertChainTestFiles(t, int2CertFile, intCertFile, rootCertFile)
+	cases := []struct {
+		name     string
+		a, b     []byte
+		expected bool
+	}{
+		{name: "identical", a: chain, b: chain, expected: true},
+		{name: "different order", a: chain, b: reversed, expected: true},
+		{name: "different PEM formatting", a: chain, b: append(bytes.Replace(chain, []byte("\n"), []byte("\r\n"), -1), '\n'),
+			expected: true},
+		{name: "missing cert", a: chain, b: readCertChainTestFiles(t, intCertChainFile), expected: false},
+		{name: "different root", a: readCertChainTestFiles(t, rootCertFile), b: readCertChainTestFiles(t, anotherRootCertFile)},
+		{name: "invalid", a: chain, b: []byte("invalid")},
+	}
+	for _, tc := range cases {
+		if got := SameCertificates(tc.a, tc.b); got != tc.expected {
+			t.Errorf("%s: got %v, expected %v", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestVerifyCertChainWithUnrelatedCert(t *testing.T) {
+	cert, key, root := readCertChainTestFiles(t, int2CertFile), readCertChainTestFiles(t, int2KeyFile),
+		readCertChainTestFiles(t, rootCertFile)
+	chain := readCertChainTestFiles(t, int2CertChainFile)
+	if _, err := NewVerifiedKeyCertBundleFromPem(cert, key, chain, root); err != nil {
+		t.Fatalf("unexpected error for a 3 level CA: %v", err)
+	}
+	if _, err := NewVerifiedKeyCertBundleFromPem(cert, key, append(chain, readCertChainTestFiles(t, ecClientCertFile)...),
+		root); err == nil {
+		t.Error("expected an error for a cert chain with a cert not verifiable from the root cert")
+	}
+}
@@ -46,6 +46,28 @@ func ParsePemEncodedCertificate(certBytes []byte) (*x509.Certificate, error) {
 	return cert, nil
 }
 
+// SplitRootCerts splits PEM-encoded certs, e.g. the CA chain returned by an external CA, into
+// the self-signed root certs and the other certs.
+func SplitRootCerts(certsPEM []byte) (intermediates, roots []byte, err error) {
+	for rest := certsPEM; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse X.509 certificate: %v", err)
+		}
+		if cert.CheckSignatureFrom(cert) == nil {
+			roots = append(roots, pem.EncodeToMemory(block)...)
+		} else {
+			intermediates = append(intermediates, pem.EncodeToMemory(block)...)
+		}
+	}
+	return intermediates, roots, nil
+}
+
 // ParsePemEncodedCSR constructs a `x509.CertificateRequest` object using the
 // given PEM-encoded certificate signing request.
 func ParsePemEncodedCSR(csrBytes []byte) (*x509.CertificateRequest, error) {
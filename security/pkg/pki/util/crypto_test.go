@@ -22,8 +22,10 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/pem"
 	"reflect"
 	"testing"
+	"time"
 )
 
 const (
@@ -188,6 +190,38 @@ func TestParsePemEncodedCertificate(t *testing.T) {
 	}
 }
 
+func TestSplitRootCerts(t *testing.T) {
+	rootPEM, _, err := GenCertKeyFromOptions(CertOptions{
+		TTL:          time.Hour,
+		Org:          "root",
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to generate a root cert: %v", err)
+	}
+	// certRSA is self-signed, but not a CA cert.
+	leafBlock, _ := pem.Decode([]byte(certRSA))
+	leafPEM := pem.EncodeToMemory(leafBlock)
+
+	intermediates, roots, err := SplitRootCerts(append(append([]byte{}, leafPEM...), rootPEM...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(intermediates) != string(leafPEM) {
+		t.Errorf("got intermediates %q, want %q", intermediates, leafPEM)
+	}
+	if string(roots) != string(rootPEM) {
+		t.Errorf("got roots %q, want %q", roots, rootPEM)
+	}
+
+	invalid := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("invalid")})
+	if _, _, err := SplitRootCerts(invalid); err == nil {
+		t.Error("expected an error for an invalid cert")
+	}
+}
+
 func TestParsePemEncodedCSR(t *testing.T) {
 	testCases := map[string]struct {
 		algo   x509.PublicKeyAlgorithm
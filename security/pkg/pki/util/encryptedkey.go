@@ -0,0 +1,266 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	blockTypeEncryptedPKCS8PrivateKey = "ENCRYPTED PRIVATE KEY"
+
+	// pbkdf2Iterations is the PBKDF2 iteration count used by EncryptPKCS8PrivateKey.
+	pbkdf2Iterations = 100000
+)
+
+var (
+	oidPBES2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+
+	errNoPassphrase = errors.New("the private key is encrypted but no passphrase is provided")
+)
+
+// encryptedPrivateKeyInfo is the PKCS#8 EncryptedPrivateKeyInfo structure (RFC 5958).
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params are the PBES2 parameters (RFC 8018).
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params are the PBKDF2 parameters (RFC 8018).
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// IsEncryptedPemKey returns whether the PEM-encoded private key is encrypted, either as an
+// encrypted PKCS#8 key or as a legacy encrypted PEM block.
+func IsEncryptedPemKey(keyBytes []byte) bool {
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return false
+	}
+	// nolint: staticcheck
+	return block.Type == blockTypeEncryptedPKCS8PrivateKey || x509.IsEncryptedPEMBlock(block)
+}
+
+// DecryptPemEncodedKey decrypts an encrypted PEM-encoded private key with passphrase, and
+// returns the PEM-encoded plaintext key. An unencrypted key is returned as is.
+func DecryptPemEncodedKey(keyBytes, passphrase []byte) ([]byte, error) {
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM-encoded key")
+	}
+	// nolint: staticcheck
+	legacy := x509.IsEncryptedPEMBlock(block)
+	if block.Type != blockTypeEncryptedPKCS8PrivateKey && !legacy {
+		return keyBytes, nil
+	}
+	if len(passphrase) == 0 {
+		return nil, errNoPassphrase
+	}
+	if legacy {
+		// nolint: staticcheck
+		der, err := x509.DecryptPEMBlock(block, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt the private key (%v)", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), nil
+	}
+	der, err := decryptPKCS8(block.Bytes, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: blockTypePKCS8PrivateKey, Bytes: der}), nil
+}
+
+// ParsePemEncodedKeyWithPassphrase is similar to ParsePemEncodedKey, but decrypts the key with
+// passphrase first if it is encrypted.
+func ParsePemEncodedKeyWithPassphrase(keyBytes, passphrase []byte) (crypto.PrivateKey, error) {
+	plain, err := DecryptPemEncodedKey(keyBytes, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePemEncodedKey(plain)
+}
+
+// EncryptPKCS8PrivateKey encrypts key with passphrase as a PEM-encoded PKCS#8 key, using PBES2
+// with PBKDF2-HMAC-SHA256 and AES-256-CBC.
+func EncryptPKCS8PrivateKey(key crypto.PrivateKey, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err = rand.Read(iv); err != nil {
+		return nil, err
+	}
+	dk := pbkdf2.Key(passphrase, salt, pbkdf2Iterations, 32, sha256.New)
+	c, err := aes.NewCipher(dk)
+	if err != nil {
+		return nil, err
+	}
+	padding := aes.BlockSize - len(der)%aes.BlockSize
+	plain := append(der, make([]byte, padding)...)
+	for i := len(der); i < len(plain); i++ {
+		plain[i] = byte(padding)
+	}
+	encrypted := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(c, iv).CryptBlocks(encrypted, plain)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: pbkdf2Iterations,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	info, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: encrypted,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: blockTypeEncryptedPKCS8PrivateKey, Bytes: info}), nil
+}
+
+// decryptPKCS8 decrypts a DER-encoded PKCS#8 EncryptedPrivateKeyInfo encrypted with PBES2.
+func decryptPKCS8(der, passphrase []byte) ([]byte, error) {
+	info := encryptedPrivateKeyInfo{}
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse the encrypted private key (%v)", err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported private key encryption %v, only PBES2 is supported", info.Algorithm.Algorithm)
+	}
+	params := pbes2Params{}
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("failed to parse PBES2 parameters (%v)", err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function %v", params.KeyDerivationFunc.Algorithm)
+	}
+	kdfParams := pbkdf2Params{}
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, fmt.Errorf("failed to parse PBKDF2 parameters (%v)", err)
+	}
+	var prf func() hash.Hash
+	switch alg := kdfParams.PRF.Algorithm; {
+	case len(alg) == 0 || alg.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case alg.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	case alg.Equal(oidHMACWithSHA384):
+		prf = sha512.New384
+	case alg.Equal(oidHMACWithSHA512):
+		prf = sha512.New
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 PRF %v", alg)
+	}
+
+	var keyLen int
+	var newCipher func([]byte) (cipher.Block, error)
+	switch alg := params.EncryptionScheme.Algorithm; {
+	case alg.Equal(oidAES128CBC):
+		keyLen, newCipher = 16, aes.NewCipher
+	case alg.Equal(oidAES192CBC):
+		keyLen, newCipher = 24, aes.NewCipher
+	case alg.Equal(oidAES256CBC):
+		keyLen, newCipher = 32, aes.NewCipher
+	case alg.Equal(oidDESEDE3CBC):
+		keyLen, newCipher = 24, des.NewTripleDESCipher
+	default:
+		return nil, fmt.Errorf("unsupported encryption scheme %v", alg)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("failed to parse the encryption IV (%v)", err)
+	}
+
+	c, err := newCipher(pbkdf2.Key(passphrase, kdfParams.Salt, kdfParams.IterationCount, keyLen, prf))
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != c.BlockSize() || len(info.EncryptedData) == 0 || len(info.EncryptedData)%c.BlockSize() != 0 {
+		return nil, errors.New("invalid encrypted private key size")
+	}
+	plain := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(c, iv).CryptBlocks(plain, info.EncryptedData)
+
+	// Remove the PKCS#7 padding. Invalid padding usually means a wrong passphrase.
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > c.BlockSize() {
+		return nil, errors.New("failed to decrypt the private key, the passphrase may be incorrect")
+	}
+	for _, b := range plain[len(plain)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("failed to decrypt the private key, the passphrase may be incorrect")
+		}
+	}
+	return plain[:len(plain)-padding], nil
+}
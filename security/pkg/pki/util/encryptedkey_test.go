@@ -0,0 +1,115 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+const encryptedIntKeyFile = "../testdata/multilevelpki/int-key-encrypted.pem"
+
+func TestDecryptPemEncodedKey(t *testing.T) {
+	plainKey, err := ioutil.ReadFile(intKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParsePemEncodedKey(plainKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Generated by openssl with PBES2, PBKDF2-HMAC-SHA1 and AES-128-CBC.
+	opensslKey, err := ioutil.ReadFile(encryptedIntKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8Key, err := EncryptPKCS8PrivateKey(key, []byte("istio"))
+	if err != nil {
+		t.Fatalf("failed to encrypt key: %v", err)
+	}
+	block, _ := pem.Decode(plainKey)
+	// nolint: staticcheck
+	legacyBlock, err := x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte("istio"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyKey := pem.EncodeToMemory(legacyBlock)
+
+	testCases := map[string]struct {
+		keyBytes    []byte
+		passphrase  string
+		encrypted   bool
+		expectedErr bool
+	}{
+		"plaintext key":                 {keyBytes: plainKey},
+		"openssl PKCS#8":                {keyBytes: opensslKey, passphrase: "istio", encrypted: true},
+		"PKCS#8":                        {keyBytes: pkcs8Key, passphrase: "istio", encrypted: true},
+		"legacy encrypted PEM":          {keyBytes: legacyKey, passphrase: "istio", encrypted: true},
+		"PKCS#8 with wrong passphrase":  {keyBytes: pkcs8Key, passphrase: "wrong", encrypted: true, expectedErr: true},
+		"legacy with wrong passphrase":  {keyBytes: legacyKey, passphrase: "wrong", encrypted: true, expectedErr: true},
+		"PKCS#8 without passphrase":     {keyBytes: opensslKey, encrypted: true, expectedErr: true},
+		"not a PEM-encoded private key": {keyBytes: []byte("bad key"), expectedErr: true},
+	}
+	for id, tc := range testCases {
+		if IsEncryptedPemKey(tc.keyBytes) != tc.encrypted {
+			t.Errorf("%s: expected IsEncryptedPemKey %v", id, tc.encrypted)
+		}
+		decrypted, err := ParsePemEncodedKeyWithPassphrase(tc.keyBytes, []byte(tc.passphrase))
+		if tc.expectedErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", id, err)
+		} else if !reflect.DeepEqual(decrypted, key) {
+			t.Errorf("%s: decrypted key does not match the plaintext key", id)
+		}
+	}
+}
+
+func TestKeyCertBundleWithEncryptedKey(t *testing.T) {
+	bundle, err := NewVerifiedKeyCertBundleFromFileWithPassphrase(intCertFile, encryptedIntKeyFile,
+		intCertChainFile, rootCertFile, []byte("istio"))
+	if err != nil {
+		t.Fatalf("failed to create key cert bundle: %v", err)
+	}
+	encryptedKey, err := ioutil.ReadFile(encryptedIntKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The bundle keeps the key encrypted.
+	if _, keyBytes, _, _ := bundle.GetAllPem(); !bytes.Equal(keyBytes, encryptedKey) {
+		t.Error("expected the bundle to keep the encrypted key")
+	}
+	if _, key, _, _ := bundle.GetAll(); key == nil || *key == nil {
+		t.Error("expected the bundle to hold the decrypted key")
+	}
+
+	if _, err = NewVerifiedKeyCertBundleFromFileWithPassphrase(intCertFile, encryptedIntKeyFile,
+		intCertChainFile, rootCertFile, []byte("wrong")); err == nil {
+		t.Error("expected an error with a wrong passphrase")
+	}
+	if _, err = NewVerifiedKeyCertBundleFromFile(intCertFile, encryptedIntKeyFile,
+		intCertChainFile, rootCertFile); err == nil {
+		t.Error("expected an error without passphrase")
+	}
+}
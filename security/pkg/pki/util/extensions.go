@@ -0,0 +1,98 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// reservedExtensions are the extensions set by the CA itself, which cannot be overridden
+	// by extra extensions.
+	reservedExtensions = []asn1.ObjectIdentifier{
+		oidSubjectAlternativeName,
+		{2, 5, 29, 15}, // key usage
+		{2, 5, 29, 19}, // basic constraints
+		{2, 5, 29, 30}, // name constraints
+		{2, 5, 29, 32}, // certificate policies
+		{2, 5, 29, 37}, // extended key usage
+	}
+)
+
+// ParseObjectIdentifier parses an OID in dotted form, e.g. "1.3.6.1.4.1.11129.2.5.1".
+func ParseObjectIdentifier(s string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q: at least two arcs are required", s)
+	}
+	oid := make(asn1.ObjectIdentifier, 0, len(parts))
+	for _, part := range parts {
+		arc, err := strconv.Atoi(part)
+		if err != nil || arc < 0 {
+			return nil, fmt.Errorf("invalid OID %q: bad arc %q", s, part)
+		}
+		oid = append(oid, arc)
+	}
+	return oid, nil
+}
+
+// ParseExtraExtension parses an extension in the form "<oid>=<base64 DER value>". A leading
+// "!" marks the extension critical, e.g. "!1.2.3.4=BQA=".
+func ParseExtraExtension(s string) (pkix.Extension, error) {
+	s = strings.TrimSpace(s)
+	ext := pkix.Extension{}
+	if strings.HasPrefix(s, "!") {
+		ext.Critical = true
+		s = s[1:]
+	}
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return ext, fmt.Errorf("invalid extension %q: expected <oid>=<base64 value>", s)
+	}
+	oid, err := ParseObjectIdentifier(parts[0])
+	if err != nil {
+		return ext, err
+	}
+	value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return ext, fmt.Errorf("invalid extension %q: value is not base64 encoded (%v)", s, err)
+	}
+	ext.Id = oid
+	ext.Value = value
+	return ext, nil
+}
+
+// ValidateExtraExtensions verifies that extra extensions do not override the extensions
+// set by the CA, and that no extension is repeated.
+func ValidateExtraExtensions(exts []pkix.Extension) error {
+	for i, ext := range exts {
+		for _, reserved := range reservedExtensions {
+			if ext.Id.Equal(reserved) {
+				return fmt.Errorf("extension %v is set by the CA and cannot be overridden", ext.Id)
+			}
+		}
+		for _, other := range exts[:i] {
+			if ext.Id.Equal(other.Id) {
+				return fmt.Errorf("extension %v is repeated", ext.Id)
+			}
+		}
+	}
+	return nil
+}
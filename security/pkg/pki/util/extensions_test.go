@@ -0,0 +1,149 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+	"time"
+)
+
+func TestParseObjectIdentifier(t *testing.T) {
+	testCases := map[string]struct {
+		in          string
+		expected    asn1.ObjectIdentifier
+		expectedErr bool
+	}{
+		"valid":        {in: "1.3.6.1.4.1.11129", expected: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129}},
+		"spaces":       {in: " 2.5.29.32 ", expected: asn1.ObjectIdentifier{2, 5, 29, 32}},
+		"single arc":   {in: "1", expectedErr: true},
+		"bad arc":      {in: "1.a.3", expectedErr: true},
+		"negative arc": {in: "1.-2", expectedErr: true},
+		"empty":        {in: "", expectedErr: true},
+	}
+	for id, tc := range testCases {
+		oid, err := ParseObjectIdentifier(tc.in)
+		if tc.expectedErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", id, err)
+		} else if !oid.Equal(tc.expected) {
+			t.Errorf("%s: got %v, want %v", id, oid, tc.expected)
+		}
+	}
+}
+
+func TestParseExtraExtension(t *testing.T) {
+	testCases := map[string]struct {
+		in          string
+		expected    pkix.Extension
+		expectedErr bool
+	}{
+		"non-critical": {
+			in:       "1.2.3.4=BQA=",
+			expected: pkix.Extension{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: []byte{5, 0}},
+		},
+		"critical": {
+			in:       "!1.2.3.4=BQA=",
+			expected: pkix.Extension{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Critical: true, Value: []byte{5, 0}},
+		},
+		"missing value": {in: "1.2.3.4", expectedErr: true},
+		"bad base64":    {in: "1.2.3.4=???", expectedErr: true},
+		"bad oid":       {in: "x=BQA=", expectedErr: true},
+	}
+	for id, tc := range testCases {
+		ext, err := ParseExtraExtension(tc.in)
+		if tc.expectedErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", id, err)
+			continue
+		}
+		if !ext.Id.Equal(tc.expected.Id) || ext.Critical != tc.expected.Critical ||
+			string(ext.Value) != string(tc.expected.Value) {
+			t.Errorf("%s: got %v, want %v", id, ext, tc.expected)
+		}
+	}
+}
+
+func TestValidateExtraExtensions(t *testing.T) {
+	custom := pkix.Extension{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: []byte{5, 0}}
+	testCases := map[string]struct {
+		exts        []pkix.Extension
+		expectedErr bool
+	}{
+		"none":      {},
+		"custom":    {exts: []pkix.Extension{custom}},
+		"repeated":  {exts: []pkix.Extension{custom, custom}, expectedErr: true},
+		"SAN":       {exts: []pkix.Extension{{Id: oidSubjectAlternativeName}}, expectedErr: true},
+		"key usage": {exts: []pkix.Extension{{Id: asn1.ObjectIdentifier{2, 5, 29, 15}}}, expectedErr: true},
+	}
+	for id, tc := range testCases {
+		if err := ValidateExtraExtensions(tc.exts); (err != nil) != tc.expectedErr {
+			t.Errorf("%s: expected error %v, got %v", id, tc.expectedErr, err)
+		}
+	}
+}
+
+func TestGenCertKeyWithExtensions(t *testing.T) {
+	policy := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 1234, 1}
+	custom := pkix.Extension{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 1234, 2}, Value: []byte{5, 0}}
+	certPem, _, err := GenCertKeyFromOptions(CertOptions{
+		Host:              "spiffe://cluster.local/ns/foo/sa/bar",
+		TTL:               time.Hour,
+		IsSelfSigned:      true,
+		RSAKeySize:        2048,
+		PolicyIdentifiers: []asn1.ObjectIdentifier{policy},
+		ExtraExtensions:   []pkix.Extension{custom},
+	})
+	if err != nil {
+		t.Fatalf("failed to generate cert: %v", err)
+	}
+	cert, err := ParsePemEncodedCertificate(certPem)
+	if err != nil {
+		t.Fatalf("failed to parse cert: %v", err)
+	}
+	if len(cert.PolicyIdentifiers) != 1 || !cert.PolicyIdentifiers[0].Equal(policy) {
+		t.Errorf("expected policy identifiers [%v], got %v", policy, cert.PolicyIdentifiers)
+	}
+	found := false
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(custom.Id) {
+			found = string(ext.Value) == string(custom.Value)
+		}
+	}
+	if !found {
+		t.Errorf("expected extension %v in cert", custom.Id)
+	}
+
+	if _, _, err = GenCertKeyFromOptions(CertOptions{
+		Host:            "spiffe://cluster.local/ns/foo/sa/bar",
+		TTL:             time.Hour,
+		IsSelfSigned:    true,
+		RSAKeySize:      2048,
+		ExtraExtensions: []pkix.Extension{{Id: oidSubjectAlternativeName}},
+	}); err == nil {
+		t.Error("expected an error overriding the SAN extension")
+	}
+}
@@ -22,11 +22,11 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -43,8 +43,12 @@ import (
 type SupportedECSignatureAlgorithms string
 
 const (
-	// only ECDSA using P256 is currently supported
+	// EcdsaSigAlg is ECDSA using P256.
 	EcdsaSigAlg SupportedECSignatureAlgorithms = "ECDSA"
+	// EcdsaP384SigAlg is ECDSA using P384.
+	EcdsaP384SigAlg SupportedECSignatureAlgorithms = "ECDSA-P384"
+	// EcdsaP521SigAlg is ECDSA using P521.
+	EcdsaP521SigAlg SupportedECSignatureAlgorithms = "ECDSA-P521"
 )
 
 // CertOptions contains options for generating a new certificate.
@@ -94,9 +98,33 @@ type CertOptions struct {
 	PKCS8Key bool
 
 	// The type of Elliptical Signature algorithm to use
-	// when generating private keys. Currently only ECDSA is supported.
-	// If empty, RSA is used, otherwise ECC is used.
+	// when generating private keys. Currently only ECDSA is supported,
+	// with the P256, P384 or P521 curve. If empty, RSA is used, otherwise ECC is used.
 	ECSigAlg SupportedECSignatureAlgorithms
+
+	// Policy identifiers to include in the certificate policies extension.
+	PolicyIdentifiers []asn1.ObjectIdentifier
+
+	// Additional extensions to include in the certificate, e.g. proprietary extensions.
+	// They must not override the extensions set from the other options.
+	ExtraExtensions []pkix.Extension
+
+	// The algorithm used to sign the certificate or CSR. If unknown, the default
+	// for the signing key is used. It must match the type of the signing key.
+	SignatureAlgorithm x509.SignatureAlgorithm
+
+	// The path length constraint of a CA cert, i.e. the number of CAs that may follow it in a
+	// chain. As in x509.Certificate, zero means unset unless MaxPathLenZero is true, and a
+	// negative value means unset. Only allowed if IsCA is true.
+	MaxPathLen int
+
+	// If true, a zero MaxPathLen is set explicitly, so that the CA cannot issue further CA certs.
+	MaxPathLenZero bool
+
+	// Name constraints of a CA cert: the DNS domains and the URI domains, e.g. SPIFFE trust
+	// domains, that certs issued under the CA may contain. Only allowed if IsCA is true.
+	PermittedDNSDomains []string
+	PermittedURIDomains []string
 }
 
 // GenCertKeyFromOptions generates a X.509 certificate and a private key with the given options.
@@ -107,17 +135,14 @@ func GenCertKeyFromOptions(options CertOptions) (pemCert []byte, pemKey []byte,
 	// case, otherwise the certificate is signed by the signer private key
 	// as specified in the CertOptions.
 	if options.ECSigAlg != "" {
-		var ecPriv *ecdsa.PrivateKey
-
-		switch options.ECSigAlg {
-		case EcdsaSigAlg:
-			ecPriv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-			if err != nil {
-				return nil, nil, fmt.Errorf("cert generation fails at EC key generation (%v)", err)
-			}
-		default:
+		curve, ok := ecCurve(options.ECSigAlg)
+		if !ok {
 			return nil, nil, errors.New("cert generation fails due to unsupported EC signature algorithm")
 		}
+		ecPriv, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cert generation fails at EC key generation (%v)", err)
+		}
 		return genCert(options, ecPriv, &ecPriv.PublicKey)
 	}
 
@@ -219,13 +244,37 @@ func MergeCertOptions(defaultOpts, deltaOpts CertOptions) CertOptions {
 // GenCertFromCSR generates a X.509 certificate with the given CSR.
 func GenCertFromCSR(csr *x509.CertificateRequest, signingCert *x509.Certificate, publicKey interface{},
 	signingKey crypto.PrivateKey, subjectIDs []string, ttl time.Duration, isCA bool) (cert []byte, err error) {
-	tmpl, err := genCertTemplateFromCSR(csr, subjectIDs, ttl, isCA)
+	return GenCertFromCSRWithExtensions(csr, signingCert, publicKey, signingKey, subjectIDs, ttl, isCA, nil, nil)
+}
+
+// GenCertFromCSRWithExtensions is similar to GenCertFromCSR, but also includes the given policy
+// identifiers and extra extensions in the certificate.
+func GenCertFromCSRWithExtensions(csr *x509.CertificateRequest, signingCert *x509.Certificate, publicKey interface{},
+	signingKey crypto.PrivateKey, subjectIDs []string, ttl time.Duration, isCA bool,
+	policyIdentifiers []asn1.ObjectIdentifier, extraExtensions []pkix.Extension) (cert []byte, err error) {
+	tmpl, err := GenCertTemplateFromCSR(csr, subjectIDs, ttl, isCA, policyIdentifiers, extraExtensions)
 	if err != nil {
 		return nil, err
 	}
 	return x509.CreateCertificate(rand.Reader, tmpl, signingCert, publicKey, signingKey)
 }
 
+// GenCertTemplateFromCSR generates a certificate template with the given CSR, including the given
+// policy identifiers and extra extensions. The NotBefore value of the cert is set to current time.
+func GenCertTemplateFromCSR(csr *x509.CertificateRequest, subjectIDs []string, ttl time.Duration, isCA bool,
+	policyIdentifiers []asn1.ObjectIdentifier, extraExtensions []pkix.Extension) (*x509.Certificate, error) {
+	if err := ValidateExtraExtensions(extraExtensions); err != nil {
+		return nil, err
+	}
+	tmpl, err := genCertTemplateFromCSR(csr, subjectIDs, ttl, isCA)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.PolicyIdentifiers = policyIdentifiers
+	tmpl.ExtraExtensions = append(tmpl.ExtraExtensions, extraExtensions...)
+	return tmpl, nil
+}
+
 // LoadSignerCredsFromFiles loads the signer cert&key from the given files.
 //   signerCertFile: cert file name
 //   signerPrivFile: private key file name
@@ -342,6 +391,16 @@ func genCertTemplateFromOptions(options CertOptions) (*x509.Certificate, error)
 		Organization: []string{options.Org},
 	}
 
+	if err = ValidateExtraExtensions(options.ExtraExtensions); err != nil {
+		return nil, err
+	}
+	if !options.IsCA && (options.MaxPathLen > 0 || options.MaxPathLenZero) {
+		return nil, fmt.Errorf("the path length constraint can only be set for CA certs")
+	}
+	if !options.IsCA && (len(options.PermittedDNSDomains) > 0 || len(options.PermittedURIDomains) > 0) {
+		return nil, fmt.Errorf("name constraints can only be set for CA certs")
+	}
+
 	exts := []pkix.Extension{}
 	if h := options.Host; len(h) > 0 {
 		s, err := BuildSubjectAltNameExtension(h)
@@ -360,7 +419,7 @@ func genCertTemplateFromOptions(options CertOptions) (*x509.Certificate, error)
 		exts = []pkix.Extension{*s}
 	}
 
-	return &x509.Certificate{
+	tmpl := &x509.Certificate{
 		SerialNumber:          serialNum,
 		Subject:               subject,
 		NotBefore:             notBefore,
@@ -369,7 +428,16 @@ func genCertTemplateFromOptions(options CertOptions) (*x509.Certificate, error)
 		ExtKeyUsage:           extKeyUsages,
 		IsCA:                  options.IsCA,
 		BasicConstraintsValid: true,
-		ExtraExtensions:       exts}, nil
+		MaxPathLen:            options.MaxPathLen,
+		MaxPathLenZero:        options.MaxPathLenZero,
+		PermittedDNSDomains:   options.PermittedDNSDomains,
+		PermittedURIDomains:   options.PermittedURIDomains,
+		PolicyIdentifiers:     options.PolicyIdentifiers,
+		ExtraExtensions:       append(exts, options.ExtraExtensions...),
+		SignatureAlgorithm:    options.SignatureAlgorithm}
+	// Name constraints must be marked critical (RFC 5280, section 4.2.1.10).
+	tmpl.PermittedDNSDomainsCritical = len(options.PermittedDNSDomains) > 0 || len(options.PermittedURIDomains) > 0
+	return tmpl, nil
 }
 
 func genSerialNum() (*big.Int, error) {
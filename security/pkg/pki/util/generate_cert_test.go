@@ -926,3 +926,89 @@ func TestMergeCertOptions(t *testing.T) {
 			mergedCertOptions.IsDualUse, deltaCertOptions.IsDualUse)
 	}
 }
+
+func TestGenCertKeyWithMaxPathLen(t *testing.T) {
+	cases := map[string]struct {
+		opts            CertOptions
+		expectedPathLen int
+		expectedZero    bool
+		expectedErr     bool
+	}{
+		"unset": {
+			opts:            CertOptions{IsCA: true},
+			expectedPathLen: -1,
+		},
+		"zero": {
+			opts:            CertOptions{IsCA: true, MaxPathLenZero: true},
+			expectedPathLen: 0,
+			expectedZero:    true,
+		},
+		"one": {
+			opts:            CertOptions{IsCA: true, MaxPathLen: 1},
+			expectedPathLen: 1,
+		},
+		"not a CA": {
+			opts:        CertOptions{MaxPathLenZero: true},
+			expectedErr: true,
+		},
+	}
+	for id, tc := range cases {
+		opts := tc.opts
+		opts.IsSelfSigned = true
+		opts.TTL = time.Hour
+		opts.Org = "MyOrg"
+		opts.RSAKeySize = 2048
+		certPem, _, err := GenCertKeyFromOptions(opts)
+		if tc.expectedErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", id, err)
+		}
+		cert, err := ParsePemEncodedCertificate(certPem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cert.MaxPathLen != tc.expectedPathLen || cert.MaxPathLenZero != tc.expectedZero {
+			t.Errorf("%s: got path length %d (zero: %v), expected %d (zero: %v)", id,
+				cert.MaxPathLen, cert.MaxPathLenZero, tc.expectedPathLen, tc.expectedZero)
+		}
+	}
+}
+
+func TestGenCertKeyWithNameConstraints(t *testing.T) {
+	opts := CertOptions{
+		IsCA:                true,
+		IsSelfSigned:        true,
+		TTL:                 time.Hour,
+		Org:                 "MyOrg",
+		RSAKeySize:          2048,
+		PermittedDNSDomains: []string{"example.com"},
+		PermittedURIDomains: []string{"cluster.local"},
+	}
+	certPem, _, err := GenCertKeyFromOptions(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cert, err := ParsePemEncodedCertificate(certPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cert.PermittedDNSDomainsCritical {
+		t.Error("expected the name constraints to be critical")
+	}
+	if len(cert.PermittedDNSDomains) != 1 || cert.PermittedDNSDomains[0] != "example.com" {
+		t.Errorf("unexpected permitted DNS domains %v", cert.PermittedDNSDomains)
+	}
+	if len(cert.PermittedURIDomains) != 1 || cert.PermittedURIDomains[0] != "cluster.local" {
+		t.Errorf("unexpected permitted URI domains %v", cert.PermittedURIDomains)
+	}
+
+	opts.IsCA = false
+	if _, _, err := GenCertKeyFromOptions(opts); err == nil {
+		t.Error("expected an error for name constraints of a non-CA cert")
+	}
+}
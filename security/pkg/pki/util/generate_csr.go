@@ -21,7 +21,6 @@ package util
 import (
 	"crypto"
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -43,15 +42,14 @@ func GenCSR(options CertOptions) ([]byte, []byte, error) {
 	var priv interface{}
 	var err error
 	if options.ECSigAlg != "" {
-		switch options.ECSigAlg {
-		case EcdsaSigAlg:
-			priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-			if err != nil {
-				return nil, nil, fmt.Errorf("EC key generation failed (%v)", err)
-			}
-		default:
+		curve, ok := ecCurve(options.ECSigAlg)
+		if !ok {
 			return nil, nil, errors.New("csr cert generation fails due to unsupported EC signature algorithm")
 		}
+		priv, err = ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("EC key generation failed (%v)", err)
+		}
 	} else {
 		if options.RSAKeySize < minimumRsaKeySize {
 			return nil, nil, fmt.Errorf("requested key size does not meet the minimum requied size of %d (requested: %d)", minimumRsaKeySize, options.RSAKeySize)
@@ -82,6 +80,7 @@ func GenCSRTemplate(options CertOptions) (*x509.CertificateRequest, error) {
 		Subject: pkix.Name{
 			Organization: []string{options.Org},
 		},
+		SignatureAlgorithm: options.SignatureAlgorithm,
 	}
 
 	if h := options.Host; len(h) > 0 {
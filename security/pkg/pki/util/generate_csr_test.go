@@ -45,6 +45,29 @@ func TestGenCSR(t *testing.T) {
 				ECSigAlg: EcdsaSigAlg,
 			},
 		},
+		"GenCSR with EC P384": {
+			csrOptions: CertOptions{
+				Host:     "test_ca.com",
+				Org:      "MyOrg",
+				ECSigAlg: EcdsaP384SigAlg,
+			},
+		},
+		"GenCSR with EC P384 and SHA512": {
+			csrOptions: CertOptions{
+				Host:               "test_ca.com",
+				Org:                "MyOrg",
+				ECSigAlg:           EcdsaP384SigAlg,
+				SignatureAlgorithm: x509.ECDSAWithSHA512,
+			},
+		},
+		"GenCSR with RSA and SHA384": {
+			csrOptions: CertOptions{
+				Host:               "test_ca.com",
+				Org:                "MyOrg",
+				RSAKeySize:         2048,
+				SignatureAlgorithm: x509.SHA384WithRSA,
+			},
+		},
 		"GenCSR with EC errors due to invalid signature algorithm": {
 			csrOptions: CertOptions{
 				Host:     "test_ca.com",
@@ -86,9 +109,12 @@ func TestGenCSR(t *testing.T) {
 		if !strings.HasSuffix(string(csr.Extensions[0].Value), "test_ca.com") {
 			t.Errorf("%s: csr host does not match", id)
 		}
+		if alg := tc.csrOptions.SignatureAlgorithm; alg != x509.UnknownSignatureAlgorithm && csr.SignatureAlgorithm != alg {
+			t.Errorf("%s: csr signature algorithm %v does not match %v", id, csr.SignatureAlgorithm, alg)
+		}
 		if tc.csrOptions.ECSigAlg != "" {
-			if tc.csrOptions.ECSigAlg != EcdsaSigAlg {
-				t.Errorf("%s: Only ECDSA signature algorithms are currently supported", id)
+			if alg := ECSigAlgForKey(csr.PublicKey); alg != tc.csrOptions.ECSigAlg {
+				t.Errorf("%s: csr key is for EC signature algorithm %q, expected %q", id, alg, tc.csrOptions.ECSigAlg)
 			}
 			if reflect.TypeOf(csr.PublicKey) != reflect.TypeOf(&ecdsa.PublicKey{}) {
 				t.Errorf("%s: decoded PKCS#8 returned unexpected key type: %T", id, csr.PublicKey)
@@ -155,9 +181,12 @@ func TestGenCSRPKCS8Key(t *testing.T) {
 		if err != nil {
 			t.Errorf("%s: failed to parse PKCS#8 private key", id)
 		}
+		if alg := tc.csrOptions.SignatureAlgorithm; alg != x509.UnknownSignatureAlgorithm && csr.SignatureAlgorithm != alg {
+			t.Errorf("%s: csr signature algorithm %v does not match %v", id, csr.SignatureAlgorithm, alg)
+		}
 		if tc.csrOptions.ECSigAlg != "" {
-			if tc.csrOptions.ECSigAlg != EcdsaSigAlg {
-				t.Errorf("%s: Only ECDSA signature algorithms are currently supported", id)
+			if alg := ECSigAlgForKey(csr.PublicKey); alg != tc.csrOptions.ECSigAlg {
+				t.Errorf("%s: csr key is for EC signature algorithm %q, expected %q", id, alg, tc.csrOptions.ECSigAlg)
 			}
 			if reflect.TypeOf(key) != reflect.TypeOf(&ecdsa.PrivateKey{}) {
 				t.Errorf("%s: decoded PKCS#8 returned unexpected key type: %T", id, key)
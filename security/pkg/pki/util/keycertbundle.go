@@ -19,6 +19,7 @@
 package util
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/rsa"
@@ -60,6 +61,33 @@ type KeyCertBundle interface {
 	// ExtractCACertExpiryTimestamp returns the unix timestamp when the CA cert becomes expires.
 	// An error indicates the certificate is expired.
 	ExtractCACertExpiryTimestamp() (float64, error)
+
+	// SetOverlapPeriod sets how long a key/cert replaced by VerifyAndSetAll is kept as a previous version.
+	// Root certs of previous versions are included in GetRootCertPem until their overlap period ends.
+	SetOverlapPeriod(period time.Duration)
+
+	// GetVersions returns the current key/cert version followed by the previous versions that are still
+	// within their overlap period, newest first.
+	GetVersions() []KeyCertVersion
+
+	// ExportPKCS7 returns the cert chain and root certs as a DER-encoded PKCS#7 (P7B) bundle.
+	ExportPKCS7() ([]byte, error)
+}
+
+// KeyCertVersion is a snapshot of the key/certs held by a KeyCertBundle.
+type KeyCertVersion struct {
+	// Version is incremented every time the key/certs are replaced.
+	Version        int
+	CertBytes      []byte
+	PrivKeyBytes   []byte
+	CertChainBytes []byte
+	RootCertBytes  []byte
+	// PrivKey is the parsed, and if needed decrypted, private key. It is nil if the bundle has no key.
+	PrivKey crypto.PrivateKey
+	// RetiredAt is when the version was replaced. It is zero for the current version.
+	RetiredAt time.Time
+	// ExpiresAt is when the overlap period of a previous version ends. It is zero for the current version.
+	ExpiresAt time.Time
 }
 
 // KeyCertBundleImpl implements the KeyCertBundle interface.
@@ -75,13 +103,29 @@ type KeyCertBundleImpl struct {
 	rootCertBytes  []byte
 	// mutex protects the R/W to all keys and certs.
 	mutex sync.RWMutex
+
+	// version is the version number of the current key/certs.
+	version int
+	// overlapPeriod is how long replaced key/certs are kept in previous.
+	overlapPeriod time.Duration
+	// previous holds the replaced key/certs, newest first.
+	previous []KeyCertVersion
+	// keyPassphrase decrypts privKeyBytes if they are encrypted.
+	keyPassphrase []byte
 }
 
 // NewVerifiedKeyCertBundleFromPem returns a new KeyCertBundle, or error if the provided certs failed the
 // verification.
 func NewVerifiedKeyCertBundleFromPem(certBytes, privKeyBytes, certChainBytes, rootCertBytes []byte) (
 	*KeyCertBundleImpl, error) {
-	bundle := &KeyCertBundleImpl{}
+	return NewVerifiedKeyCertBundleFromPemWithPassphrase(certBytes, privKeyBytes, certChainBytes, rootCertBytes, nil)
+}
+
+// NewVerifiedKeyCertBundleFromPemWithPassphrase is similar to NewVerifiedKeyCertBundleFromPem, but the private
+// key can be encrypted with keyPassphrase. The bundle keeps the key encrypted, and decrypts it when it is set.
+func NewVerifiedKeyCertBundleFromPemWithPassphrase(certBytes, privKeyBytes, certChainBytes, rootCertBytes,
+	keyPassphrase []byte) (*KeyCertBundleImpl, error) {
+	bundle := &KeyCertBundleImpl{keyPassphrase: copyBytes(keyPassphrase)}
 	if err := bundle.VerifyAndSetAll(certBytes, privKeyBytes, certChainBytes, rootCertBytes); err != nil {
 		return nil, err
 	}
@@ -92,6 +136,13 @@ func NewVerifiedKeyCertBundleFromPem(certBytes, privKeyBytes, certChainBytes, ro
 // verification.
 func NewVerifiedKeyCertBundleFromFile(certFile, privKeyFile, certChainFile, rootCertFile string) (
 	*KeyCertBundleImpl, error) {
+	return NewVerifiedKeyCertBundleFromFileWithPassphrase(certFile, privKeyFile, certChainFile, rootCertFile, nil)
+}
+
+// NewVerifiedKeyCertBundleFromFileWithPassphrase is similar to NewVerifiedKeyCertBundleFromFile, but the
+// private key can be encrypted with keyPassphrase.
+func NewVerifiedKeyCertBundleFromFileWithPassphrase(certFile, privKeyFile, certChainFile, rootCertFile string,
+	keyPassphrase []byte) (*KeyCertBundleImpl, error) {
 	certBytes, err := ioutil.ReadFile(certFile)
 	if err != nil {
 		return nil, err
@@ -110,7 +161,25 @@ func NewVerifiedKeyCertBundleFromFile(certFile, privKeyFile, certChainFile, root
 	if err != nil {
 		return nil, err
 	}
-	return NewVerifiedKeyCertBundleFromPem(certBytes, privKeyBytes, certChainBytes, rootCertBytes)
+	return NewVerifiedKeyCertBundleFromPemWithPassphrase(certBytes, privKeyBytes, certChainBytes, rootCertBytes,
+		keyPassphrase)
+}
+
+// NewVerifiedCertBundleFromPem returns a new KeyCertBundle without private key material, or error if the
+// provided certs failed the verification. It is used when the private key is held externally, e.g. in an HSM.
+func NewVerifiedCertBundleFromPem(certBytes, certChainBytes, rootCertBytes []byte) (*KeyCertBundleImpl, error) {
+	if err := verifyCertChain(certBytes, certChainBytes, rootCertBytes); err != nil {
+		return nil, err
+	}
+	cert, _ := ParsePemEncodedCertificate(certBytes)
+	return &KeyCertBundleImpl{
+		certBytes:      copyBytes(certBytes),
+		cert:           cert,
+		privKeyBytes:   []byte{},
+		privKey:        nil,
+		certChainBytes: copyBytes(certChainBytes),
+		rootCertBytes:  copyBytes(rootCertBytes),
+	}, nil
 }
 
 // NewKeyCertBundleWithRootCertFromFile returns a new KeyCertBundle with the root cert without verification.
@@ -160,20 +229,41 @@ func (b *KeyCertBundleImpl) GetCertChainPem() []byte {
 	return copyBytes(b.certChainBytes)
 }
 
-// GetRootCertPem returns the root certificate PEM.
+// GetRootCertPem returns the root certificate PEM. The root certs of previous versions within their
+// overlap period are appended after the current root certs, so that certs issued by either are trusted.
 func (b *KeyCertBundleImpl) GetRootCertPem() []byte {
 	b.mutex.RLock()
 	defer b.mutex.RUnlock()
-	return copyBytes(b.rootCertBytes)
+	rootCertBytes := copyBytes(b.rootCertBytes)
+	now := time.Now()
+	for _, v := range b.previous {
+		if !now.Before(v.ExpiresAt) || len(v.RootCertBytes) == 0 || bytes.Contains(rootCertBytes, v.RootCertBytes) {
+			continue
+		}
+		if len(rootCertBytes) > 0 && rootCertBytes[len(rootCertBytes)-1] != '\n' {
+			rootCertBytes = append(rootCertBytes, '\n')
+		}
+		rootCertBytes = append(rootCertBytes, v.RootCertBytes...)
+	}
+	return rootCertBytes
 }
 
 // VerifyAndSetAll verifies the key/certs, and sets all key/certs in KeyCertBundle together.
-// Setting all values together avoids inconsistency.
+// Setting all values together avoids inconsistency. An encrypted private key is decrypted with
+// the passphrase of the bundle.
 func (b *KeyCertBundleImpl) VerifyAndSetAll(certBytes, privKeyBytes, certChainBytes, rootCertBytes []byte) error {
-	if err := Verify(certBytes, privKeyBytes, certChainBytes, rootCertBytes); err != nil {
+	plainKeyBytes := privKeyBytes
+	if IsEncryptedPemKey(privKeyBytes) {
+		var err error
+		if plainKeyBytes, err = DecryptPemEncodedKey(privKeyBytes, b.keyPassphrase); err != nil {
+			return err
+		}
+	}
+	if err := Verify(certBytes, plainKeyBytes, certChainBytes, rootCertBytes); err != nil {
 		return err
 	}
 	b.mutex.Lock()
+	b.retireCurrent(certBytes)
 	b.certBytes = copyBytes(certBytes)
 	b.privKeyBytes = copyBytes(privKeyBytes)
 	b.certChainBytes = copyBytes(certChainBytes)
@@ -181,16 +271,94 @@ func (b *KeyCertBundleImpl) VerifyAndSetAll(certBytes, privKeyBytes, certChainBy
 	// cert and privKey are always reset to point to new addresses. This avoids modifying the pointed structs that
 	// could be still used outside of the class.
 	b.cert, _ = ParsePemEncodedCertificate(certBytes)
-	privKey, _ := ParsePemEncodedKey(privKeyBytes)
+	privKey, _ := ParsePemEncodedKey(plainKeyBytes)
 	b.privKey = &privKey
 	b.mutex.Unlock()
 	return nil
 }
 
+// ExportPKCS7 returns the cert chain and root certs as a DER-encoded PKCS#7 (P7B) bundle. The cert
+// is used in place of the cert chain if the chain is empty.
+func (b *KeyCertBundleImpl) ExportPKCS7() ([]byte, error) {
+	b.mutex.RLock()
+	chain := copyBytes(b.certChainBytes)
+	if len(chain) == 0 {
+		chain = copyBytes(b.certBytes)
+	}
+	b.mutex.RUnlock()
+	return EncodePKCS7(chain, b.GetRootCertPem())
+}
+
+// SetOverlapPeriod sets how long a key/cert replaced by VerifyAndSetAll is kept as a previous version.
+// A zero period, the default, discards replaced key/certs immediately.
+func (b *KeyCertBundleImpl) SetOverlapPeriod(period time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.overlapPeriod = period
+	if period <= 0 {
+		b.previous = nil
+	}
+}
+
+// GetVersions returns the current key/cert version followed by the previous versions that are still
+// within their overlap period, newest first.
+func (b *KeyCertBundleImpl) GetVersions() []KeyCertVersion {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	versions := []KeyCertVersion{{
+		Version:        b.version,
+		CertBytes:      copyBytes(b.certBytes),
+		PrivKeyBytes:   copyBytes(b.privKeyBytes),
+		CertChainBytes: copyBytes(b.certChainBytes),
+		RootCertBytes:  copyBytes(b.rootCertBytes),
+		PrivKey:        derefKey(b.privKey),
+	}}
+	now := time.Now()
+	for _, v := range b.previous {
+		if now.Before(v.ExpiresAt) {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// retireCurrent moves the current key/certs to the previous versions if an overlap period is set and
+// they are being replaced by a different cert, and drops previous versions whose overlap period has
+// ended. The caller must hold the write lock.
+func (b *KeyCertBundleImpl) retireCurrent(newCertBytes []byte) {
+	if len(b.certBytes) > 0 && bytes.Equal(b.certBytes, newCertBytes) {
+		return
+	}
+	now := time.Now()
+	previous := make([]KeyCertVersion, 0, len(b.previous)+1)
+	if b.overlapPeriod > 0 && len(b.certBytes) > 0 {
+		previous = append(previous, KeyCertVersion{
+			Version:        b.version,
+			CertBytes:      b.certBytes,
+			PrivKeyBytes:   b.privKeyBytes,
+			CertChainBytes: b.certChainBytes,
+			RootCertBytes:  b.rootCertBytes,
+			PrivKey:        derefKey(b.privKey),
+			RetiredAt:      now,
+			ExpiresAt:      now.Add(b.overlapPeriod),
+		})
+	}
+	for _, v := range b.previous {
+		if now.Before(v.ExpiresAt) {
+			previous = append(previous, v)
+		}
+	}
+	b.previous = previous
+	b.version++
+}
+
 // CertOptions returns the certificate config based on currently stored cert.
 func (b *KeyCertBundleImpl) CertOptions() (*CertOptions, error) {
 	b.mutex.RLock()
 	defer b.mutex.RUnlock()
+	if b.privKey == nil {
+		return nil, errors.New("no private key in the bundle")
+	}
 	ids, err := ExtractIDs(b.cert.Extensions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract id %v", err)
@@ -235,7 +403,27 @@ func (b *KeyCertBundleImpl) ExtractCACertExpiryTimestamp() (float64, error) {
 
 // Verify that the cert chain, root cert and key/cert match.
 func Verify(certBytes, privKeyBytes, certChainBytes, rootCertBytes []byte) error {
-	// Verify the cert can be verified from the root cert through the cert chain.
+	if err := verifyCertChain(certBytes, certChainBytes, rootCertBytes); err != nil {
+		return err
+	}
+
+	// Verify that the key can be correctly parsed.
+	if _, err := ParsePemEncodedKey(privKeyBytes); err != nil {
+		return fmt.Errorf("failed to parse private key PEM: %v", err)
+	}
+
+	// Verify the cert and key match.
+	if _, err := tls.X509KeyPair(certBytes, privKeyBytes); err != nil {
+		return fmt.Errorf("the cert does not match the key")
+	}
+
+	return nil
+}
+
+// verifyCertChain verifies the cert can be verified from the root cert through the cert chain.
+// The cert chain may hold any number of intermediate CAs, in any order, but each of them must
+// also be verifiable from the root cert.
+func verifyCertChain(certBytes, certChainBytes, rootCertBytes []byte) error {
 	rcp := x509.NewCertPool()
 	rcp.AppendCertsFromPEM(rootCertBytes)
 
@@ -258,16 +446,20 @@ func Verify(certBytes, privKeyBytes, certChainBytes, rootCertBytes []byte) error
 				"pool with error: %v", err)
 	}
 
-	// Verify that the key can be correctly parsed.
-	if _, err = ParsePemEncodedKey(privKeyBytes); err != nil {
-		return fmt.Errorf("failed to parse private key PEM: %v", err)
+	if len(bytes.TrimSpace(certChainBytes)) == 0 {
+		return nil
 	}
-
-	// Verify the cert and key match.
-	if _, err := tls.X509KeyPair(certBytes, privKeyBytes); err != nil {
-		return fmt.Errorf("the cert does not match the key")
+	intermediates, err := ParsePemEncodedCertificates(certChainBytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse cert chain PEM: %v", err)
+	}
+	opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	for _, c := range intermediates {
+		if _, err := c.Verify(opts); err != nil {
+			return fmt.Errorf("cannot verify the cert %q in the cert chain with the provided root "+
+				"chain with error: %v", c.Subject, err)
+		}
 	}
-
 	return nil
 }
 
@@ -285,6 +477,13 @@ func extractCertExpiryTimestamp(certType string, certPem []byte) (float64, error
 	return expiryTimestamp, nil
 }
 
+func derefKey(key *crypto.PrivateKey) crypto.PrivateKey {
+	if key == nil {
+		return nil
+	}
+	return *key
+}
+
 func copyBytes(src []byte) []byte {
 	bs := make([]byte, len(src))
 	copy(bs, src)
@@ -15,6 +15,7 @@
 package util
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 	"time"
@@ -484,3 +485,83 @@ func TestExtractCACertExpiryTimestamp(t *testing.T) {
 		})
 	}
 }
+
+func TestKeyCertBundleVersions(t *testing.T) {
+	genRoot := func() ([]byte, []byte) {
+		cert, key, err := GenCertKeyFromOptions(CertOptions{
+			Host:         "citadel.testing.istio.io",
+			TTL:          time.Hour,
+			Org:          "MyOrg",
+			IsCA:         true,
+			IsSelfSigned: true,
+			RSAKeySize:   2048,
+		})
+		if err != nil {
+			t.Fatalf("failed to gen self-signed cert: %v", err)
+		}
+		return cert, key
+	}
+	cert1, key1 := genRoot()
+	cert2, key2 := genRoot()
+
+	testCases := map[string]struct {
+		overlap          time.Duration
+		expectedVersions int
+		expectOldRoot    bool
+	}{
+		"no overlap": {
+			overlap:          0,
+			expectedVersions: 1,
+			expectOldRoot:    false,
+		},
+		"overlap": {
+			overlap:          time.Hour,
+			expectedVersions: 2,
+			expectOldRoot:    true,
+		},
+		"overlap ended": {
+			overlap:          time.Nanosecond,
+			expectedVersions: 1,
+			expectOldRoot:    false,
+		},
+	}
+	for id, tc := range testCases {
+		kb, err := NewVerifiedKeyCertBundleFromPem(cert1, key1, nil, cert1)
+		if err != nil {
+			t.Fatalf("%s: failed to create key cert bundle: %v", id, err)
+		}
+		kb.SetOverlapPeriod(tc.overlap)
+		if err := kb.VerifyAndSetAll(cert2, key2, nil, cert2); err != nil {
+			t.Fatalf("%s: failed to set key cert bundle: %v", id, err)
+		}
+		// Setting the same cert again must not retire it.
+		if err := kb.VerifyAndSetAll(cert2, key2, nil, cert2); err != nil {
+			t.Fatalf("%s: failed to set key cert bundle: %v", id, err)
+		}
+		time.Sleep(time.Millisecond)
+
+		versions := kb.GetVersions()
+		if len(versions) != tc.expectedVersions {
+			t.Fatalf("%s: expected %d versions, got %d", id, tc.expectedVersions, len(versions))
+		}
+		if versions[0].Version != 2 || !bytes.Equal(versions[0].CertBytes, cert2) {
+			t.Errorf("%s: unexpected current version %d", id, versions[0].Version)
+		}
+		if len(versions) > 1 {
+			if versions[1].Version != 1 || !bytes.Equal(versions[1].PrivKeyBytes, key1) {
+				t.Errorf("%s: unexpected previous version %d", id, versions[1].Version)
+			}
+			if !versions[1].ExpiresAt.After(versions[1].RetiredAt) {
+				t.Errorf("%s: expected the previous version to expire after it was retired", id)
+			}
+		}
+
+		rootCerts := kb.GetRootCertPem()
+		if !bytes.HasPrefix(rootCerts, cert2) {
+			t.Errorf("%s: expected the current root cert first, got %s", id, rootCerts)
+		}
+		if bytes.Contains(rootCerts, cert1) != tc.expectOldRoot {
+			t.Errorf("%s: expected previous root cert in root certs: %v, got %s", id, tc.expectOldRoot, rootCerts)
+		}
+	}
+}
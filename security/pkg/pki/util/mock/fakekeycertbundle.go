@@ -18,6 +18,7 @@ import (
 	"crypto"
 	"crypto/x509"
 	"sync"
+	"time"
 
 	"istio.io/istio/security/pkg/pki/util"
 )
@@ -93,3 +94,30 @@ func (b *FakeKeyCertBundle) ExtractRootCertExpiryTimestamp() (float64, error) {
 func (b *FakeKeyCertBundle) ExtractCACertExpiryTimestamp() (float64, error) {
 	return b.CACertExpiryTimestamp, nil
 }
+
+// SetOverlapPeriod is a no-op.
+func (b *FakeKeyCertBundle) SetOverlapPeriod(period time.Duration) {}
+
+// GetVersions returns the current key/certs as the only version.
+func (b *FakeKeyCertBundle) GetVersions() []util.KeyCertVersion {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var privKey crypto.PrivateKey
+	if b.PrivKey != nil {
+		privKey = *b.PrivKey
+	}
+	return []util.KeyCertVersion{{
+		CertBytes:      b.CertBytes,
+		PrivKeyBytes:   b.PrivKeyBytes,
+		CertChainBytes: b.CertChainBytes,
+		RootCertBytes:  b.RootCertBytes,
+		PrivKey:        privKey,
+	}}
+}
+
+// ExportPKCS7 returns CertChainBytes and RootCertBytes as a PKCS#7 bundle.
+func (b *FakeKeyCertBundle) ExportPKCS7() ([]byte, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return util.EncodePKCS7(b.CertChainBytes, b.RootCertBytes)
+}
@@ -0,0 +1,83 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"io/ioutil"
+	"testing"
+)
+
+// decodePKCS7 returns the certificates in a DER-encoded certificate-only PKCS#7 bundle.
+func decodePKCS7(t *testing.T, der []byte) []*x509.Certificate {
+	contentInfo := pkcs7ContentInfo{}
+	if _, err := asn1.Unmarshal(der, &contentInfo); err != nil {
+		t.Fatalf("failed to parse ContentInfo: %v", err)
+	}
+	if !contentInfo.ContentType.Equal(oidPKCS7SignedData) {
+		t.Fatalf("unexpected content type %v", contentInfo.ContentType)
+	}
+	signedData := pkcs7SignedData{}
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		t.Fatalf("failed to parse SignedData: %v", err)
+	}
+	certs, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse certificates: %v", err)
+	}
+	return certs
+}
+
+func TestEncodePKCS7(t *testing.T) {
+	chain, err := ioutil.ReadFile(int2CertChainFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ioutil.ReadFile(rootCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The chain already holds the root cert, which must only be included once.
+	der, err := EncodePKCS7(chain, root)
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	certs := decodePKCS7(t, der)
+	if len(certs) != 3 {
+		t.Fatalf("expected 3 certs, got %d", len(certs))
+	}
+	if certs[0].Subject.CommonName != "Root CA" || certs[2].Subject.CommonName != "Intermediate CA2" {
+		t.Errorf("unexpected cert order: %s, %s", certs[0].Subject.CommonName, certs[2].Subject.CommonName)
+	}
+
+	if _, err = EncodePKCS7([]byte("not a cert")); err == nil {
+		t.Error("expected an error without certificates")
+	}
+}
+
+func TestKeyCertBundleExportPKCS7(t *testing.T) {
+	bundle, err := NewVerifiedKeyCertBundleFromFile(intCertFile, intKeyFile, intCertChainFile, rootCertFile)
+	if err != nil {
+		t.Fatalf("failed to create key cert bundle: %v", err)
+	}
+	der, err := bundle.ExportPKCS7()
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if certs := decodePKCS7(t, der); len(certs) != 2 {
+		t.Errorf("expected the intermediate and root certs, got %d certs", len(certs))
+	}
+}
@@ -15,10 +15,12 @@
 package util
 
 import (
+	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"fmt"
 	"net"
+	"net/url"
 	"strings"
 
 	"istio.io/istio/pkg/spiffe"
@@ -193,6 +195,33 @@ func ExtractIDs(exts []pkix.Extension) ([]string, error) {
 	return ids, nil
 }
 
+// CSRSANs returns the DNS, URI, IP and email SANs requested by the CSR.
+func CSRSANs(csr *x509.CertificateRequest) []string {
+	requested := append([]string{}, csr.DNSNames...)
+	for _, u := range csr.URIs {
+		requested = append(requested, (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String())
+	}
+	for _, ip := range csr.IPAddresses {
+		requested = append(requested, ip.String())
+	}
+	return append(requested, csr.EmailAddresses...)
+}
+
+// CheckCSRSANs returns an error if the CSR requests a SAN that is not in allowedIDs. It is used
+// by external CAs issuing the SANs of the CSR as is.
+func CheckCSRSANs(csr *x509.CertificateRequest, allowedIDs []string) error {
+	allowed := map[string]bool{}
+	for _, id := range allowedIDs {
+		allowed[id] = true
+	}
+	for _, san := range CSRSANs(csr) {
+		if !allowed[san] {
+			return fmt.Errorf("the CSR requests SAN %q, which is not in %v", san, allowedIDs)
+		}
+	}
+	return nil
+}
+
 func generateReversedMap(m map[IdentityType]int) map[int]IdentityType {
 	reversed := make(map[int]IdentityType)
 	for key, value := range m {
@@ -15,9 +15,11 @@
 package util
 
 import (
+	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"net"
+	"net/url"
 	"reflect"
 	"testing"
 )
@@ -231,3 +233,42 @@ func TestExtractIDs(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckCSRSANs(t *testing.T) {
+	id := "spiffe://cluster.local/ns/default/sa/foo"
+	u, _ := url.Parse(id)
+	testCases := map[string]struct {
+		csr        *x509.CertificateRequest
+		allowedIDs []string
+		expectErr  bool
+	}{
+		"allowed URI": {
+			csr:        &x509.CertificateRequest{URIs: []*url.URL{u}},
+			allowedIDs: []string{id},
+		},
+		"allowed DNS name and IP": {
+			csr:        &x509.CertificateRequest{DNSNames: []string{"foo.com"}, IPAddresses: []net.IP{net.ParseIP("10.0.0.1")}},
+			allowedIDs: []string{"foo.com", "10.0.0.1"},
+		},
+		"no SAN": {
+			csr:        &x509.CertificateRequest{},
+			allowedIDs: []string{id},
+		},
+		"other URI": {
+			csr:        &x509.CertificateRequest{URIs: []*url.URL{u}},
+			allowedIDs: []string{"spiffe://cluster.local/ns/default/sa/bar"},
+			expectErr:  true,
+		},
+		"extra email": {
+			csr:        &x509.CertificateRequest{URIs: []*url.URL{u}, EmailAddresses: []string{"foo@bar.com"}},
+			allowedIDs: []string{id},
+			expectErr:  true,
+		},
+	}
+	for name, tc := range testCases {
+		err := CheckCSRSANs(tc.csr, tc.allowedIDs)
+		if (err != nil) != tc.expectErr {
+			t.Errorf("%s: got error %v, expected error %v", name, err, tc.expectErr)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"strings"
+)
+
+// signatureAlgorithms are the signature algorithms that can be configured by name.
+var signatureAlgorithms = map[string]x509.SignatureAlgorithm{
+	"SHA256-RSA":    x509.SHA256WithRSA,
+	"SHA384-RSA":    x509.SHA384WithRSA,
+	"SHA512-RSA":    x509.SHA512WithRSA,
+	"SHA256-RSAPSS": x509.SHA256WithRSAPSS,
+	"SHA384-RSAPSS": x509.SHA384WithRSAPSS,
+	"SHA512-RSAPSS": x509.SHA512WithRSAPSS,
+	"ECDSA-SHA256":  x509.ECDSAWithSHA256,
+	"ECDSA-SHA384":  x509.ECDSAWithSHA384,
+	"ECDSA-SHA512":  x509.ECDSAWithSHA512,
+}
+
+// ecCurve returns the elliptic curve used to generate keys for the EC signature algorithm.
+func ecCurve(alg SupportedECSignatureAlgorithms) (elliptic.Curve, bool) {
+	switch alg {
+	case EcdsaSigAlg:
+		return elliptic.P256(), true
+	case EcdsaP384SigAlg:
+		return elliptic.P384(), true
+	case EcdsaP521SigAlg:
+		return elliptic.P521(), true
+	default:
+		return nil, false
+	}
+}
+
+// ECSigAlgForKey returns the EC signature algorithm that generates keys on the same
+// curve as the given EC public key, or an empty string if the key is not supported.
+func ECSigAlgForKey(pub crypto.PublicKey) SupportedECSignatureAlgorithms {
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return ""
+	}
+	for _, alg := range []SupportedECSignatureAlgorithms{EcdsaSigAlg, EcdsaP384SigAlg, EcdsaP521SigAlg} {
+		if curve, _ := ecCurve(alg); curve == key.Curve {
+			return alg
+		}
+	}
+	return ""
+}
+
+// ParseSignatureAlgorithm parses a signature algorithm name such as "SHA384-RSA" or
+// "ECDSA-SHA384". An empty name returns x509.UnknownSignatureAlgorithm, which lets
+// the signing key pick its default algorithm.
+func ParseSignatureAlgorithm(name string) (x509.SignatureAlgorithm, error) {
+	if name == "" {
+		return x509.UnknownSignatureAlgorithm, nil
+	}
+	if alg, ok := signatureAlgorithms[strings.ToUpper(name)]; ok {
+		return alg, nil
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported signature algorithm %q", name)
+}
+
+// CheckSignatureAlgorithm returns an error if the signature algorithm cannot be used
+// with the signing key of the given public key.
+func CheckSignatureAlgorithm(alg x509.SignatureAlgorithm, pub crypto.PublicKey) error {
+	if alg == x509.UnknownSignatureAlgorithm {
+		return nil
+	}
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA,
+			x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS:
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		switch alg {
+		case x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512:
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported signing key type %T", pub)
+	}
+	return fmt.Errorf("signature algorithm %v cannot be used with a %T signing key", alg, pub)
+}
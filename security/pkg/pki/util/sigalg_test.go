@@ -0,0 +1,98 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+)
+
+func TestParseSignatureAlgorithm(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected x509.SignatureAlgorithm
+		err      bool
+	}{
+		{name: "", expected: x509.UnknownSignatureAlgorithm},
+		{name: "SHA384-RSA", expected: x509.SHA384WithRSA},
+		{name: "sha512-rsapss", expected: x509.SHA512WithRSAPSS},
+		{name: "ECDSA-SHA384", expected: x509.ECDSAWithSHA384},
+		{name: "MD5-RSA", err: true},
+		{name: "ED25519", err: true},
+	}
+	for _, tc := range cases {
+		alg, err := ParseSignatureAlgorithm(tc.name)
+		if tc.err != (err != nil) {
+			t.Errorf("%q: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if alg != tc.expected {
+			t.Errorf("%q: got %v, expected %v", tc.name, alg, tc.expected)
+		}
+	}
+}
+
+func TestCheckSignatureAlgorithm(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		alg  x509.SignatureAlgorithm
+		pub  interface{}
+		err  bool
+	}{
+		{name: "default", alg: x509.UnknownSignatureAlgorithm, pub: &rsaKey.PublicKey},
+		{name: "RSA", alg: x509.SHA384WithRSA, pub: &rsaKey.PublicKey},
+		{name: "RSA-PSS", alg: x509.SHA256WithRSAPSS, pub: &rsaKey.PublicKey},
+		{name: "ECDSA", alg: x509.ECDSAWithSHA384, pub: &ecKey.PublicKey},
+		{name: "ECDSA with RSA key", alg: x509.ECDSAWithSHA384, pub: &rsaKey.PublicKey, err: true},
+		{name: "RSA with EC key", alg: x509.SHA384WithRSA, pub: &ecKey.PublicKey, err: true},
+	}
+	for _, tc := range cases {
+		if err := CheckSignatureAlgorithm(tc.alg, tc.pub); tc.err != (err != nil) {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+	}
+}
+
+func TestECSigAlgForKey(t *testing.T) {
+	for _, alg := range []SupportedECSignatureAlgorithms{EcdsaSigAlg, EcdsaP384SigAlg, EcdsaP521SigAlg} {
+		curve, _ := ecCurve(alg)
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ECSigAlgForKey(&key.PublicKey); got != alg {
+			t.Errorf("got %q, expected %q", got, alg)
+		}
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ECSigAlgForKey(&rsaKey.PublicKey); got != "" {
+		t.Errorf("got %q for an RSA key, expected none", got)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	"istio.io/istio/security/pkg/audit"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+)
+
+// csrSigningEvent is the audit event of a CSR received through the CSR API.
+const csrSigningEvent = "csr_signing"
+
+// auditCSR records the outcome of a CSR received through the CSR API in the audit log. caller is nil
+// if the request is not authenticated.
+func auditCSR(ctx context.Context, caller *authenticate.Caller, decision audit.Decision, reason string) {
+	audit.Record(csrEntry(ctx, caller, decision, reason))
+}
+
+// auditCSRIssued records the certificate issued for a CSR received through the CSR API in the audit log.
+func auditCSRIssued(ctx context.Context, caller *authenticate.Caller, serialNumber string, notAfter time.Time) {
+	entry := csrEntry(ctx, caller, audit.Allow, "")
+	entry.SerialNumber = serialNumber
+	if !notAfter.IsZero() {
+		entry.NotAfter = &notAfter
+	}
+	audit.Record(entry)
+}
+
+func csrEntry(ctx context.Context, caller *authenticate.Caller, decision audit.Decision, reason string) audit.Entry {
+	entry := audit.Entry{
+		Event:    csrSigningEvent,
+		Decision: decision,
+		Reason:   reason,
+		Origin:   audit.Remote,
+		Peer:     getConnectionAddress(ctx),
+	}
+	if caller != nil {
+		if len(caller.Identities) > 0 {
+			entry.Requester = caller.Identities[0]
+		}
+		entry.SANs = caller.Identities
+		entry.CredentialType = caller.AuthSource.String()
+	}
+	return entry
+}
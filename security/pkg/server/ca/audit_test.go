@@ -0,0 +1,134 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"istio.io/istio/security/pkg/audit"
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+	mockutil "istio.io/istio/security/pkg/pki/util/mock"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+	pb "istio.io/istio/security/proto"
+)
+
+type auditSink struct {
+	entries []audit.Entry
+}
+
+func (s *auditSink) Record(e audit.Entry) {
+	s.entries = append(s.entries, e)
+}
+
+func TestCreateCertificateAudit(t *testing.T) {
+	certPem, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         "spiffe://cluster.local/ns/foo/sa/foo",
+		TTL:          time.Hour,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to generate a cert: %v", err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPem)
+	if err != nil {
+		t.Fatalf("failed to parse the cert: %v", err)
+	}
+	identities := []string{"spiffe://cluster.local/ns/foo/sa/foo"}
+
+	testCases := map[string]struct {
+		authenticators []authenticate.Authenticator
+		ca             CertificateAuthority
+		expected       audit.Entry
+	}{
+		"Unauthenticated request": {
+			authenticators: []authenticate.Authenticator{&mockAuthenticator{errMsg: "Not authorized"}},
+			ca:             &mockca.FakeCA{},
+			expected: audit.Entry{
+				Event:    csrSigningEvent,
+				Decision: audit.Deny,
+				Reason:   "authentication failure",
+				Origin:   audit.Remote,
+				Peer:     "unknown",
+			},
+		},
+		"Failed to sign": {
+			authenticators: []authenticate.Authenticator{&mockAuthenticator{
+				authSource: authenticate.AuthSourceIDToken,
+				identities: identities,
+			}},
+			ca: &mockca.FakeCA{SignErr: caerror.NewError(caerror.CSRError, fmt.Errorf("cannot sign"))},
+			expected: audit.Entry{
+				Event:          csrSigningEvent,
+				Requester:      identities[0],
+				SANs:           identities,
+				Decision:       audit.Deny,
+				Reason:         "cannot sign",
+				Origin:         audit.Remote,
+				Peer:           "unknown",
+				CredentialType: "id_token",
+			},
+		},
+		"Successful signing": {
+			authenticators: []authenticate.Authenticator{&mockAuthenticator{
+				authSource: authenticate.AuthSourceClientCertificate,
+				identities: identities,
+			}},
+			ca: &mockca.FakeCA{
+				SignedCert:    certPem,
+				KeyCertBundle: &mockutil.FakeKeyCertBundle{RootCertBytes: []byte("root_cert")},
+			},
+			expected: audit.Entry{
+				Event:          csrSigningEvent,
+				Requester:      identities[0],
+				SANs:           identities,
+				Decision:       audit.Allow,
+				Origin:         audit.Remote,
+				Peer:           "unknown",
+				CredentialType: "client_certificate",
+				SerialNumber:   cert.SerialNumber.Text(16),
+				NotAfter:       &cert.NotAfter,
+			},
+		},
+	}
+
+	for id, c := range testCases {
+		sink := &auditSink{}
+		unregister := audit.RegisterSink(sink)
+		server := &Server{
+			ca:             c.ca,
+			hostnames:      []string{"hostname"},
+			Authenticators: c.authenticators,
+			monitoring:     newMonitoringMetrics(),
+		}
+		_, _ = server.CreateCertificate(context.Background(), &pb.IstioCertificateRequest{Csr: "dumb CSR"})
+		unregister()
+
+		if len(sink.entries) != 1 {
+			t.Errorf("Case %s: expecting 1 audit entry but got %v", id, sink.entries)
+			continue
+		}
+		if !reflect.DeepEqual(sink.entries[0], c.expected) {
+			t.Errorf("Case %s: expecting audit entry %+v but got %+v", id, c.expected, sink.entries[0])
+		}
+	}
+}
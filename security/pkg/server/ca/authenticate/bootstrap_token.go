@@ -0,0 +1,186 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	BootstrapTokenAuthenticatorType = "BootstrapTokenAuthenticator"
+
+	// BootstrapTokenSecretType is the type of the secrets holding VM bootstrap tokens.
+	BootstrapTokenSecretType v1.SecretType = "istio.io/bootstrap-token"
+	// BootstrapTokenSecretPrefix is the name prefix of the secrets holding VM bootstrap tokens. The
+	// secret of token "<id>.<secret>" is named "<prefix><id>".
+	BootstrapTokenSecretPrefix = "istio-bootstrap-token-"
+
+	// Data keys of the bootstrap token secrets.
+	BootstrapTokenIDKey         = "token-id"
+	BootstrapTokenSecretKey     = "token-secret"
+	BootstrapTokenIdentityKey   = "identity"
+	BootstrapTokenExpirationKey = "expiration"
+
+	bootstrapTokenChars     = "abcdefghijklmnopqrstuvwxyz0123456789"
+	bootstrapTokenIDLen     = 6
+	bootstrapTokenSecretLen = 16
+)
+
+var bootstrapTokenRegexp = regexp.MustCompile(`^([a-z0-9]{6})\.([a-z0-9]{16})$`)
+
+// BootstrapTokenAuthenticator authenticates short-lived, single-use bootstrap tokens, which VMs exchange
+// for their initial certificate. Each token is bound to an identity and stored in a secret of type
+// istio.io/bootstrap-token. The secret is deleted once a certificate is issued with the token, after which
+// the VM renews its certificate by authenticating with the issued certificate.
+type BootstrapTokenAuthenticator struct {
+	client    corev1.SecretsGetter
+	namespace string
+	now       func() time.Time
+}
+
+var _ Authenticator = &BootstrapTokenAuthenticator{}
+
+// NewBootstrapTokenAuthenticator creates a BootstrapTokenAuthenticator validating the tokens stored in
+// namespace.
+func NewBootstrapTokenAuthenticator(client corev1.SecretsGetter, namespace string) *BootstrapTokenAuthenticator {
+	return &BootstrapTokenAuthenticator{
+		client:    client,
+		namespace: namespace,
+		now:       time.Now,
+	}
+}
+
+func (a *BootstrapTokenAuthenticator) AuthenticatorType() string {
+	return BootstrapTokenAuthenticatorType
+}
+
+// Authenticate authenticates the bootstrap token in the context. The returned Caller.Identities is the
+// identity the token is bound to, and Caller.Consume deletes the token, so that a request failing
+// after the authentication, e.g. because it is rate limited, can be retried with the same token.
+func (a *BootstrapTokenAuthenticator) Authenticate(ctx context.Context) (*Caller, error) {
+	token, err := extractBearerToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap token extraction error: %v", err)
+	}
+	match := bootstrapTokenRegexp.FindStringSubmatch(token)
+	if match == nil {
+		return nil, fmt.Errorf("not a bootstrap token")
+	}
+	tokenID, tokenSecret := match[1], match[2]
+
+	secrets := a.client.Secrets(a.namespace)
+	name := BootstrapTokenSecretPrefix + tokenID
+	scrt, err := secrets.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bootstrap token %s: %v", tokenID, err)
+	}
+	if scrt.Type != BootstrapTokenSecretType ||
+		subtle.ConstantTimeCompare(scrt.Data[BootstrapTokenSecretKey], []byte(tokenSecret)) != 1 {
+		return nil, fmt.Errorf("invalid bootstrap token %s", tokenID)
+	}
+	expiration, err := time.Parse(time.RFC3339, string(scrt.Data[BootstrapTokenExpirationKey]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid expiration of bootstrap token %s: %v", tokenID, err)
+	}
+	if a.now().After(expiration) {
+		_ = secrets.Delete(context.TODO(), name, metav1.DeleteOptions{})
+		return nil, fmt.Errorf("bootstrap token %s expired at %s", tokenID, expiration.Format(time.RFC3339))
+	}
+	identity := string(scrt.Data[BootstrapTokenIdentityKey])
+	if identity == "" {
+		return nil, fmt.Errorf("bootstrap token %s is not bound to an identity", tokenID)
+	}
+
+	// The token is single use. Deleting the secret with a precondition on its UID makes sure that
+	// concurrent requests with the same token cannot all succeed.
+	uid := scrt.UID
+	consume := func() error {
+		if err := secrets.Delete(context.TODO(), name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &uid},
+		}); err != nil {
+			if errors.IsNotFound(err) || errors.IsConflict(err) {
+				return fmt.Errorf("bootstrap token %s is already used", tokenID)
+			}
+			return fmt.Errorf("failed to consume bootstrap token %s: %v", tokenID, err)
+		}
+		return nil
+	}
+	return &Caller{
+		AuthSource: AuthSourceBootstrapToken,
+		Identities: []string{identity},
+		Consume:    consume,
+	}, nil
+}
+
+// CreateBootstrapToken mints a bootstrap token bound to identity, valid for ttl, and stores it in
+// namespace. The identity must be a SPIFFE ID.
+func CreateBootstrapToken(client corev1.SecretsGetter, namespace, identity string, ttl time.Duration) (string, error) {
+	if !strings.HasPrefix(identity, "spiffe://") {
+		return "", fmt.Errorf("identity %q is not a SPIFFE ID", identity)
+	}
+	if ttl <= 0 {
+		return "", fmt.Errorf("invalid bootstrap token TTL %v", ttl)
+	}
+	tokenID, err := randomTokenString(bootstrapTokenIDLen)
+	if err != nil {
+		return "", err
+	}
+	tokenSecret, err := randomTokenString(bootstrapTokenSecretLen)
+	if err != nil {
+		return "", err
+	}
+	scrt := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      BootstrapTokenSecretPrefix + tokenID,
+			Namespace: namespace,
+		},
+		Type: BootstrapTokenSecretType,
+		Data: map[string][]byte{
+			BootstrapTokenIDKey:         []byte(tokenID),
+			BootstrapTokenSecretKey:     []byte(tokenSecret),
+			BootstrapTokenIdentityKey:   []byte(identity),
+			BootstrapTokenExpirationKey: []byte(time.Now().Add(ttl).UTC().Format(time.RFC3339)),
+		},
+	}
+	if _, err := client.Secrets(namespace).Create(context.TODO(), scrt, metav1.CreateOptions{}); err != nil {
+		return "", fmt.Errorf("failed to store bootstrap token: %v", err)
+	}
+	return tokenID + "." + tokenSecret, nil
+}
+
+func randomTokenString(n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(bootstrapTokenChars)))
+	for i := range b {
+		r, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate bootstrap token: %v", err)
+		}
+		b[i] = bootstrapTokenChars[r.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,102 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const vmIdentity = "spiffe://cluster.local/ns/vm/sa/vm-workload"
+
+func bootstrapTokenContext(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.MD{"authorization": []string{"Bearer " + token}})
+}
+
+func TestBootstrapTokenAuthenticator(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	token, err := CreateBootstrapToken(client.CoreV1(), "istio-system", vmIdentity, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create a bootstrap token: %v", err)
+	}
+	if !bootstrapTokenRegexp.MatchString(token) {
+		t.Fatalf("malformed bootstrap token %q", token)
+	}
+	authenticator := NewBootstrapTokenAuthenticator(client.CoreV1(), "istio-system")
+
+	tokenID := strings.Split(token, ".")[0]
+	if _, err := authenticator.Authenticate(bootstrapTokenContext(tokenID + ".0000000000000000")); err == nil {
+		t.Error("expected an error for a wrong token secret")
+	}
+	if _, err := authenticator.Authenticate(bootstrapTokenContext("header.payload.signature")); err == nil {
+		t.Error("expected an error for a JWT")
+	}
+
+	caller, err := authenticator.Authenticate(bootstrapTokenContext(token))
+	if err != nil {
+		t.Fatalf("failed to authenticate the bootstrap token: %v", err)
+	}
+	if caller.AuthSource != AuthSourceBootstrapToken || len(caller.Identities) != 1 || caller.Identities[0] != vmIdentity {
+		t.Errorf("unexpected caller %+v", caller)
+	}
+
+	// The token is only consumed once the certificate is issued, so a failed request can be retried.
+	retried, err := authenticator.Authenticate(bootstrapTokenContext(token))
+	if err != nil {
+		t.Fatalf("expected the bootstrap token to be usable until it is consumed: %v", err)
+	}
+	if err := caller.Consume(); err != nil {
+		t.Fatalf("failed to consume the bootstrap token: %v", err)
+	}
+	if err := retried.Consume(); err == nil {
+		t.Error("expected an error when the bootstrap token is consumed twice")
+	}
+	if _, err := authenticator.Authenticate(bootstrapTokenContext(token)); err == nil {
+		t.Error("expected an error when the bootstrap token is reused")
+	}
+	if _, err := client.CoreV1().Secrets("istio-system").Get(context.TODO(), BootstrapTokenSecretPrefix+tokenID,
+		metav1.GetOptions{}); err == nil {
+		t.Error("expected the secret of the used bootstrap token to be deleted")
+	}
+}
+
+func TestBootstrapTokenExpired(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	token, err := CreateBootstrapToken(client.CoreV1(), "istio-system", vmIdentity, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create a bootstrap token: %v", err)
+	}
+	authenticator := NewBootstrapTokenAuthenticator(client.CoreV1(), "istio-system")
+	authenticator.now = func() time.Time { return time.Now().Add(time.Hour) }
+	if _, err := authenticator.Authenticate(bootstrapTokenContext(token)); err == nil {
+		t.Error("expected an error for an expired bootstrap token")
+	}
+}
+
+func TestCreateBootstrapTokenInvalid(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	if _, err := CreateBootstrapToken(client.CoreV1(), "istio-system", "vm-workload", time.Hour); err == nil {
+		t.Error("expected an error for an identity that is not a SPIFFE ID")
+	}
+	if _, err := CreateBootstrapToken(client.CoreV1(), "istio-system", vmIdentity, 0); err == nil {
+		t.Error("expected an error for a non-positive TTL")
+	}
+}
@@ -38,8 +38,25 @@ type AuthSource int
 const (
 	AuthSourceClientCertificate AuthSource = iota
 	AuthSourceIDToken
+	AuthSourceBootstrapToken
+	AuthSourceCloudIdentity
 )
 
+// String returns the name of the authentication source.
+func (s AuthSource) String() string {
+	switch s {
+	case AuthSourceClientCertificate:
+		return "client_certificate"
+	case AuthSourceIDToken:
+		return "id_token"
+	case AuthSourceBootstrapToken:
+		return "bootstrap_token"
+	case AuthSourceCloudIdentity:
+		return "cloud_identity"
+	}
+	return "unknown"
+}
+
 // ClientCertAuthenticator extracts identities from client certificate.
 type ClientCertAuthenticator struct{}
 
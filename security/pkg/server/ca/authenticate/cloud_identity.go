@@ -0,0 +1,190 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc"
+)
+
+const (
+	CloudIdentityAuthenticatorType = "CloudIdentityAuthenticator"
+
+	// GKEWorkloadIdentity is the provider of Google ID tokens, which GKE workloads get for the GCP
+	// service account bound to their Kubernetes service account.
+	GKEWorkloadIdentity = "gke"
+	// EKSServiceAccountIdentity is the provider of the tokens projected by EKS for IAM roles for
+	// service accounts (IRSA), issued by the OIDC issuer of the EKS cluster.
+	EKSServiceAccountIdentity = "eks"
+
+	googleIssuer = "https://accounts.google.com"
+)
+
+// CloudIdentityAuthenticator authenticates the tokens of cloud-managed workload identities, and
+// maps the cloud identity to the mesh identity it may request certificates for. GKE tokens are
+// identified by the email of the GCP service account, EKS tokens by their subject.
+type CloudIdentityAuthenticator struct {
+	provider string
+	verifier *oidc.IDTokenVerifier
+
+	// trustDomainMutex guards trustDomain, which can be changed at runtime.
+	trustDomainMutex sync.RWMutex
+	trustDomain      string
+
+	// mapping maps cloud identities to mesh identities in the "<namespace>/<service account>" form.
+	mapping map[string]string
+}
+
+var _ Authenticator = &CloudIdentityAuthenticator{}
+
+// NewCloudIdentityAuthenticator creates a CloudIdentityAuthenticator for the tokens of provider,
+// validated against the OIDC issuer with the audience. The issuer defaults to Google for GKE, and
+// must be set for EKS.
+func NewCloudIdentityAuthenticator(provider, issuer, audience, trustDomain string,
+	mapping map[string]string) (*CloudIdentityAuthenticator, error) {
+	switch provider {
+	case GKEWorkloadIdentity:
+		if issuer == "" {
+			issuer = googleIssuer
+		}
+	case EKSServiceAccountIdentity:
+		if issuer == "" {
+			return nil, fmt.Errorf("the OIDC issuer of the EKS cluster is required")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported cloud identity provider %q", provider)
+	}
+	if audience == "" {
+		return nil, fmt.Errorf("the audience of the %s tokens is required", provider)
+	}
+	if err := validateCloudIdentityMapping(mapping); err != nil {
+		return nil, err
+	}
+
+	oidcProvider, err := oidc.NewProvider(context.Background(), issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize the OIDC provider %s: %v", issuer, err)
+	}
+	return &CloudIdentityAuthenticator{
+		provider:    provider,
+		verifier:    oidcProvider.Verifier(&oidc.Config{ClientID: audience}),
+		trustDomain: trustDomain,
+		mapping:     mapping,
+	}, nil
+}
+
+func (a *CloudIdentityAuthenticator) AuthenticatorType() string {
+	return CloudIdentityAuthenticatorType
+}
+
+// SetTrustDomain sets the trust domain of the identities of the callers.
+func (a *CloudIdentityAuthenticator) SetTrustDomain(trustDomain string) {
+	a.trustDomainMutex.Lock()
+	defer a.trustDomainMutex.Unlock()
+	a.trustDomain = trustDomain
+}
+
+func (a *CloudIdentityAuthenticator) getTrustDomain() string {
+	a.trustDomainMutex.RLock()
+	defer a.trustDomainMutex.RUnlock()
+	return a.trustDomain
+}
+
+// Authenticate verifies the cloud identity token of the caller and returns the mesh identity it is
+// mapped to.
+func (a *CloudIdentityAuthenticator) Authenticate(ctx context.Context) (*Caller, error) {
+	bearerToken, err := extractBearerToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cloud identity token extraction error: %v", err)
+	}
+	idToken, err := a.verifier.Verify(ctx, bearerToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify the cloud identity token: %v", err)
+	}
+	claims := &cloudIdentityClaims{}
+	if err := idToken.Claims(claims); err != nil {
+		return nil, fmt.Errorf("failed to extract the claims of the cloud identity token: %v", err)
+	}
+
+	var cloudIdentity string
+	switch a.provider {
+	case GKEWorkloadIdentity:
+		if claims.Email == "" || !claims.EmailVerified {
+			return nil, fmt.Errorf("the Google ID token has no verified email")
+		}
+		cloudIdentity = claims.Email
+	case EKSServiceAccountIdentity:
+		cloudIdentity = claims.Sub
+	}
+
+	meshIdentity, found := a.mapping[cloudIdentity]
+	if !found && a.provider == EKSServiceAccountIdentity {
+		// The subject of an EKS token is the service account of the workload, used as is by default.
+		parts := strings.Split(cloudIdentity, ":")
+		if len(parts) == 4 && parts[0] == "system" && parts[1] == "serviceaccount" {
+			meshIdentity, found = parts[2]+"/"+parts[3], true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("the cloud identity %q is not mapped to a mesh identity", cloudIdentity)
+	}
+	parts := strings.Split(meshIdentity, "/")
+	return &Caller{
+		AuthSource: AuthSourceCloudIdentity,
+		Identities: []string{fmt.Sprintf(identityTemplate, a.getTrustDomain(), parts[0], parts[1])},
+	}, nil
+}
+
+type cloudIdentityClaims struct {
+	Sub           string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// ParseCloudIdentityMapping parses a comma-separated list of "<cloud identity>=<namespace>/<service account>"
+// mappings.
+func ParseCloudIdentityMapping(s string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid cloud identity mapping %q, expected <cloud identity>=<namespace>/<service account>", entry)
+		}
+		mapping[parts[0]] = parts[1]
+	}
+	if err := validateCloudIdentityMapping(mapping); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+func validateCloudIdentityMapping(mapping map[string]string) error {
+	for cloudIdentity, meshIdentity := range mapping {
+		parts := strings.Split(meshIdentity, "/")
+		if cloudIdentity == "" || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid mapping of cloud identity %q to %q, expected <namespace>/<service account>",
+				cloudIdentity, meshIdentity)
+		}
+	}
+	return nil
+}
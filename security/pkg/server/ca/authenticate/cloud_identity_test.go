@@ -0,0 +1,236 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc"
+	"google.golang.org/grpc/metadata"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+type staticKeySet struct {
+	key *ecdsa.PublicKey
+}
+
+func (s *staticKeySet) VerifySignature(_ context.Context, jwt string) ([]byte, error) {
+	jws, err := jose.ParseSigned(jwt)
+	if err != nil {
+		return nil, err
+	}
+	return jws.Verify(s.key)
+}
+
+func signCloudIdentityToken(t *testing.T, key *ecdsa.PrivateKey, claims map[string]interface{}) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, nil)
+	if err != nil {
+		t.Fatalf("failed to create the signer: %v", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to marshal the claims: %v", err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("failed to sign the token: %v", err)
+	}
+	token, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatalf("failed to serialize the token: %v", err)
+	}
+	return token
+}
+
+func TestCloudIdentityAuthenticator(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate the key: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate the key: %v", err)
+	}
+	eksIssuer := "https://oidc.eks.us-west-2.amazonaws.com/id/EXAMPLE"
+	exp := time.Now().Add(time.Hour).Unix()
+
+	testCases := map[string]struct {
+		provider   string
+		issuer     string
+		mapping    map[string]string
+		token      string
+		identities []string
+		expectErr  bool
+	}{
+		"GKE mapped service account": {
+			provider: GKEWorkloadIdentity,
+			issuer:   googleIssuer,
+			mapping:  map[string]string{"httpbin@project.iam.gserviceaccount.com": "foo/httpbin"},
+			token: signCloudIdentityToken(t, key, map[string]interface{}{
+				"iss": googleIssuer, "aud": "istio-ca", "exp": exp,
+				"sub": "1234567890", "email": "httpbin@project.iam.gserviceaccount.com", "email_verified": true,
+			}),
+			identities: []string{"spiffe://cluster.local/ns/foo/sa/httpbin"},
+		},
+		"GKE unmapped service account": {
+			provider: GKEWorkloadIdentity,
+			issuer:   googleIssuer,
+			mapping:  map[string]string{"httpbin@project.iam.gserviceaccount.com": "foo/httpbin"},
+			token: signCloudIdentityToken(t, key, map[string]interface{}{
+				"iss": googleIssuer, "aud": "istio-ca", "exp": exp,
+				"sub": "1234567890", "email": "sleep@project.iam.gserviceaccount.com", "email_verified": true,
+			}),
+			expectErr: true,
+		},
+		"GKE unverified email": {
+			provider: GKEWorkloadIdentity,
+			issuer:   googleIssuer,
+			mapping:  map[string]string{"httpbin@project.iam.gserviceaccount.com": "foo/httpbin"},
+			token: signCloudIdentityToken(t, key, map[string]interface{}{
+				"iss": googleIssuer, "aud": "istio-ca", "exp": exp,
+				"sub": "1234567890", "email": "httpbin@project.iam.gserviceaccount.com",
+			}),
+			expectErr: true,
+		},
+		"EKS service account": {
+			provider: EKSServiceAccountIdentity,
+			issuer:   eksIssuer,
+			token: signCloudIdentityToken(t, key, map[string]interface{}{
+				"iss": eksIssuer, "aud": "istio-ca", "exp": exp, "sub": "system:serviceaccount:foo:httpbin",
+			}),
+			identities: []string{"spiffe://cluster.local/ns/foo/sa/httpbin"},
+		},
+		"EKS mapped service account": {
+			provider: EKSServiceAccountIdentity,
+			issuer:   eksIssuer,
+			mapping:  map[string]string{"system:serviceaccount:foo:httpbin": "bar/httpbin"},
+			token: signCloudIdentityToken(t, key, map[string]interface{}{
+				"iss": eksIssuer, "aud": "istio-ca", "exp": exp, "sub": "system:serviceaccount:foo:httpbin",
+			}),
+			identities: []string{"spiffe://cluster.local/ns/bar/sa/httpbin"},
+		},
+		"wrong audience": {
+			provider: EKSServiceAccountIdentity,
+			issuer:   eksIssuer,
+			token: signCloudIdentityToken(t, key, map[string]interface{}{
+				"iss": eksIssuer, "aud": "sts.amazonaws.com", "exp": exp, "sub": "system:serviceaccount:foo:httpbin",
+			}),
+			expectErr: true,
+		},
+		"wrong issuer": {
+			provider: EKSServiceAccountIdentity,
+			issuer:   eksIssuer,
+			token: signCloudIdentityToken(t, key, map[string]interface{}{
+				"iss": googleIssuer, "aud": "istio-ca", "exp": exp, "sub": "system:serviceaccount:foo:httpbin",
+			}),
+			expectErr: true,
+		},
+		"wrong signing key": {
+			provider: EKSServiceAccountIdentity,
+			issuer:   eksIssuer,
+			token: signCloudIdentityToken(t, otherKey, map[string]interface{}{
+				"iss": eksIssuer, "aud": "istio-ca", "exp": exp, "sub": "system:serviceaccount:foo:httpbin",
+			}),
+			expectErr: true,
+		},
+		"expired token": {
+			provider: EKSServiceAccountIdentity,
+			issuer:   eksIssuer,
+			token: signCloudIdentityToken(t, key, map[string]interface{}{
+				"iss": eksIssuer, "aud": "istio-ca", "exp": time.Now().Add(-time.Hour).Unix(),
+				"sub": "system:serviceaccount:foo:httpbin",
+			}),
+			expectErr: true,
+		},
+	}
+
+	for id, tc := range testCases {
+		a := &CloudIdentityAuthenticator{
+			provider: tc.provider,
+			verifier: oidc.NewVerifier(tc.issuer, &staticKeySet{key: &key.PublicKey},
+				&oidc.Config{ClientID: "istio-ca", SupportedSigningAlgs: []string{oidc.ES256}}),
+			trustDomain: "cluster.local",
+			mapping:     tc.mapping,
+		}
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{
+			"authorization": []string{fmt.Sprintf("Bearer %s", tc.token)},
+		})
+		caller, err := a.Authenticate(ctx)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got none", id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", id, err)
+			continue
+		}
+		if caller.AuthSource != AuthSourceCloudIdentity || !reflect.DeepEqual(caller.Identities, tc.identities) {
+			t.Errorf("%s: got caller %+v, want identities %v", id, caller, tc.identities)
+		}
+	}
+}
+
+func TestParseCloudIdentityMapping(t *testing.T) {
+	testCases := map[string]struct {
+		mapping   string
+		expected  map[string]string
+		expectErr bool
+	}{
+		"empty": {
+			mapping:  "",
+			expected: map[string]string{},
+		},
+		"mappings": {
+			mapping: "httpbin@project.iam.gserviceaccount.com=foo/httpbin, system:serviceaccount:a:b=bar/sleep",
+			expected: map[string]string{
+				"httpbin@project.iam.gserviceaccount.com": "foo/httpbin",
+				"system:serviceaccount:a:b":               "bar/sleep",
+			},
+		},
+		"missing mesh identity": {
+			mapping:   "httpbin@project.iam.gserviceaccount.com",
+			expectErr: true,
+		},
+		"invalid mesh identity": {
+			mapping:   "httpbin@project.iam.gserviceaccount.com=httpbin",
+			expectErr: true,
+		},
+	}
+
+	for id, tc := range testCases {
+		mapping, err := ParseCloudIdentityMapping(tc.mapping)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got none", id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", id, err)
+		} else if !reflect.DeepEqual(mapping, tc.expected) {
+			t.Errorf("%s: got %v, want %v", id, mapping, tc.expected)
+		}
+	}
+}
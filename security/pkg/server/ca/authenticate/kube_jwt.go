@@ -17,6 +17,7 @@ package authenticate
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/metadata"
@@ -35,8 +36,10 @@ type RemoteKubeClientGetter func(clusterID string) kubernetes.Interface
 
 // KubeJWTAuthenticator authenticates K8s JWTs.
 type KubeJWTAuthenticator struct {
-	trustDomain string
-	jwtPolicy   string
+	// trustDomainMutex guards trustDomain, which can be changed at runtime.
+	trustDomainMutex sync.RWMutex
+	trustDomain      string
+	jwtPolicy        string
 
 	// Primary cluster kube client
 	kubeClient kubernetes.Interface
@@ -45,6 +48,12 @@ type KubeJWTAuthenticator struct {
 
 	// remote cluster kubeClient getter
 	remoteKubeClientGetter RemoteKubeClientGetter
+
+	// The audiences the tokens must be bound to, instead of the ones of the JWT policy, if set.
+	audiences []string
+
+	// The policy the tokens must comply with before they are reviewed.
+	tokenPolicy TokenPolicy
 }
 
 var _ Authenticator = &KubeJWTAuthenticator{}
@@ -62,6 +71,29 @@ func NewKubeJWTAuthenticator(client kubernetes.Interface, clusterID string,
 	}
 }
 
+// SetAudiences requires the tokens to be bound to one of audiences, regardless of the JWT policy.
+func (a *KubeJWTAuthenticator) SetAudiences(audiences []string) {
+	a.audiences = audiences
+}
+
+// SetTokenPolicy rejects the tokens that do not comply with policy, without reviewing them.
+func (a *KubeJWTAuthenticator) SetTokenPolicy(policy TokenPolicy) {
+	a.tokenPolicy = policy
+}
+
+// SetTrustDomain sets the trust domain of the identities of the callers.
+func (a *KubeJWTAuthenticator) SetTrustDomain(trustDomain string) {
+	a.trustDomainMutex.Lock()
+	defer a.trustDomainMutex.Unlock()
+	a.trustDomain = trustDomain
+}
+
+func (a *KubeJWTAuthenticator) getTrustDomain() string {
+	a.trustDomainMutex.RLock()
+	defer a.trustDomainMutex.RUnlock()
+	return a.trustDomain
+}
+
 func (a *KubeJWTAuthenticator) AuthenticatorType() string {
 	return KubeJWTAuthenticatorType
 }
@@ -73,6 +105,9 @@ func (a *KubeJWTAuthenticator) Authenticate(ctx context.Context) (*Caller, error
 	if err != nil {
 		return nil, fmt.Errorf("target JWT extraction error: %v", err)
 	}
+	if err := a.tokenPolicy.Check(targetJWT); err != nil {
+		return nil, fmt.Errorf("target JWT rejected: %v", err)
+	}
 	clusterID := extractClusterID(ctx)
 	var id []string
 
@@ -80,7 +115,11 @@ func (a *KubeJWTAuthenticator) Authenticate(ctx context.Context) (*Caller, error
 	if kubeClient == nil {
 		return nil, fmt.Errorf("could not get cluster %s's kube client", clusterID)
 	}
-	id, err = tokenreview.ValidateK8sJwt(kubeClient, targetJWT, a.jwtPolicy)
+	if len(a.audiences) > 0 {
+		id, err = tokenreview.ValidateK8sJwtForAudiences(kubeClient, targetJWT, a.audiences)
+	} else {
+		id, err = tokenreview.ValidateK8sJwt(kubeClient, targetJWT, a.jwtPolicy)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to validate the JWT: %v", err)
 	}
@@ -91,7 +130,7 @@ func (a *KubeJWTAuthenticator) Authenticate(ctx context.Context) (*Caller, error
 	callerServiceAccount := id[1]
 	return &Caller{
 		AuthSource: AuthSourceIDToken,
-		Identities: []string{fmt.Sprintf(identityTemplate, a.trustDomain, callerNamespace, callerServiceAccount)},
+		Identities: []string{fmt.Sprintf(identityTemplate, a.getTrustDomain(), callerNamespace, callerServiceAccount)},
 	}, nil
 }
 
@@ -48,6 +48,14 @@ func TestNewKubeJWTAuthenticator(t *testing.T) {
 	}
 }
 
+func TestKubeJWTAuthenticatorSetTrustDomain(t *testing.T) {
+	authenticator := NewKubeJWTAuthenticator(nil, "kubernetes", nil, "old.example.com", jwt.PolicyThirdParty)
+	authenticator.SetTrustDomain("new.example.com")
+	if got := authenticator.getTrustDomain(); got != "new.example.com" {
+		t.Errorf("Got trust domain %q, want new.example.com", got)
+	}
+}
+
 func TestAuthenticate(t *testing.T) {
 	primaryCluster := "Kubernetes"
 	remoteCluster := "remote"
@@ -202,3 +210,54 @@ func TestAuthenticate(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthenticateWithAudiences(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{
+		"clusterid":     []string{"Kubernetes"},
+		"authorization": []string{bearerTokenPrefix + "bearer-token"},
+	})
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action ktesting.Action) (bool, runtime.Object, error) {
+		review := action.(ktesting.CreateAction).GetObject().(*k8sauth.TokenReview)
+		review.Status = k8sauth.TokenReviewStatus{
+			Authenticated: true,
+			Audiences:     []string{"istio-ca"},
+			User: k8sauth.UserInfo{
+				Username: "system:serviceaccount:default:example-pod-sa",
+				Groups:   []string{"system:serviceaccounts"},
+			},
+		}
+		return true, review, nil
+	})
+
+	authenticator := NewKubeJWTAuthenticator(client, "Kubernetes", nil, "example.com", jwt.PolicyFirstParty)
+	authenticator.SetAudiences([]string{"custom-ca"})
+	if _, err := authenticator.Authenticate(ctx); err == nil {
+		t.Error("expected a token bound to another audience to be rejected")
+	}
+
+	authenticator.SetAudiences([]string{"custom-ca", "istio-ca"})
+	caller, err := authenticator.Authenticate(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := fmt.Sprintf(identityTemplate, "example.com", "default", "example-pod-sa"); caller.Identities[0] != expected {
+		t.Errorf("expected the identity %v, got %v", expected, caller.Identities)
+	}
+}
+
+func TestAuthenticateWithTokenPolicy(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{
+		"authorization": []string{bearerTokenPrefix + fakeToken(`{"iss":"kubernetes/serviceaccount"}`)},
+	})
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action ktesting.Action) (bool, runtime.Object, error) {
+		t.Error("expected the token to be rejected without a review")
+		return true, nil, nil
+	})
+	authenticator := NewKubeJWTAuthenticator(client, "Kubernetes", nil, "example.com", jwt.PolicyFirstParty)
+	authenticator.SetTokenPolicy(TokenPolicy{RequireBoundTokens: true})
+	if _, err := authenticator.Authenticate(ctx); err == nil {
+		t.Error("expected the first-party token to be rejected")
+	}
+}
@@ -20,6 +20,10 @@ import "context"
 type Caller struct {
 	AuthSource AuthSource
 	Identities []string
+
+	// Consume, if set, invalidates the single-use credential the caller authenticated with. It is
+	// called once the request of the caller succeeded, which fails if the credential was already used.
+	Consume func() error
 }
 
 type Authenticator interface {
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/coreos/go-oidc"
 )
@@ -27,9 +28,15 @@ const (
 )
 
 type JwtAuthenticator struct {
-	provider    *oidc.Provider
-	verifier    *oidc.IDTokenVerifier
-	trustDomain string
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+
+	// trustDomainMutex guards trustDomain, which can be changed at runtime.
+	trustDomainMutex sync.RWMutex
+	trustDomain      string
+
+	// The policy the tokens must comply with before they are verified.
+	tokenPolicy TokenPolicy
 }
 
 var _ Authenticator = &JwtAuthenticator{}
@@ -50,6 +57,24 @@ func NewJwtAuthenticator(iss string, trustDomain, audience string) (*JwtAuthenti
 	}, nil
 }
 
+// SetTokenPolicy rejects the tokens that do not comply with policy, without verifying them.
+func (j *JwtAuthenticator) SetTokenPolicy(policy TokenPolicy) {
+	j.tokenPolicy = policy
+}
+
+// SetTrustDomain sets the trust domain of the identities of the callers.
+func (j *JwtAuthenticator) SetTrustDomain(trustDomain string) {
+	j.trustDomainMutex.Lock()
+	defer j.trustDomainMutex.Unlock()
+	j.trustDomain = trustDomain
+}
+
+func (j *JwtAuthenticator) getTrustDomain() string {
+	j.trustDomainMutex.RLock()
+	defer j.trustDomainMutex.RUnlock()
+	return j.trustDomain
+}
+
 // Authenticate - based on the old OIDC authenticator for mesh expansion.
 func (j *JwtAuthenticator) Authenticate(ctx context.Context) (*Caller, error) {
 	bearerToken, err := extractBearerToken(ctx)
@@ -57,6 +82,10 @@ func (j *JwtAuthenticator) Authenticate(ctx context.Context) (*Caller, error) {
 		return nil, fmt.Errorf("ID token extraction error: %v", err)
 	}
 
+	if err := j.tokenPolicy.Check(bearerToken); err != nil {
+		return nil, fmt.Errorf("ID token rejected: %v", err)
+	}
+
 	idToken, err := j.verifier.Verify(context.Background(), bearerToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify the ID token (error %v)", err)
@@ -77,7 +106,7 @@ func (j *JwtAuthenticator) Authenticate(ctx context.Context) (*Caller, error) {
 
 	return &Caller{
 		AuthSource: AuthSourceIDToken,
-		Identities: []string{fmt.Sprintf(identityTemplate, j.trustDomain, ns, ksa)},
+		Identities: []string{fmt.Sprintf(identityTemplate, j.getTrustDomain(), ns, ksa)},
 	}, nil
 }
 
@@ -96,6 +125,6 @@ type JwtPayload struct {
 	Sub string `json:"sub"`
 }
 
-func (j JwtAuthenticator) AuthenticatorType() string {
+func (j *JwtAuthenticator) AuthenticatorType() string {
 	return IDTokenAuthenticatorType
 }
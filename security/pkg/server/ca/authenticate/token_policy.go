@@ -0,0 +1,149 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"istio.io/pkg/monitoring"
+)
+
+const (
+	// legacyServiceAccountIssuer is the issuer of the first-party Kubernetes service account tokens,
+	// which are not bound to an audience and never expire.
+	legacyServiceAccountIssuer = "kubernetes/serviceaccount"
+
+	rejectMalformed = "malformed"
+	rejectUnbound   = "unbound"
+	rejectIssuer    = "issuer"
+	rejectAudience  = "audience"
+)
+
+var (
+	reasonTag = monitoring.MustCreateLabel("reason")
+
+	tokenRejectionCounts = monitoring.NewSum(
+		"citadel_server_token_rejection_count",
+		"The number of bearer tokens rejected by the token policy, by reason.",
+		monitoring.WithLabels(reasonTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(tokenRejectionCounts)
+}
+
+// TokenPolicy restricts the bearer tokens accepted by the authenticators, on top of the validation
+// of the tokens by their issuer.
+type TokenPolicy struct {
+	// Audiences requires the tokens to be issued for one of them, if set.
+	Audiences []string
+	// Issuers requires the tokens to be issued by one of them, if set.
+	Issuers []string
+	// RequireBoundTokens rejects the first-party service account tokens, which are not bound to an
+	// audience and never expire, in favor of projected tokens.
+	RequireBoundTokens bool
+}
+
+// IsEmpty returns true if the policy accepts all tokens.
+func (p TokenPolicy) IsEmpty() bool {
+	return len(p.Audiences) == 0 && len(p.Issuers) == 0 && !p.RequireBoundTokens
+}
+
+// Check returns an error if the policy rejects token. The signature of the token is not verified.
+func (p TokenPolicy) Check(token string) error {
+	if p.IsEmpty() {
+		return nil
+	}
+	reason, err := p.check(token)
+	if err != nil {
+		tokenRejectionCounts.With(reasonTag.Value(reason)).Increment()
+	}
+	return err
+}
+
+func (p TokenPolicy) check(token string) (string, error) {
+	claims, err := parseTokenClaims(token)
+	if err != nil {
+		return rejectMalformed, err
+	}
+	if p.RequireBoundTokens && (claims.Iss == legacyServiceAccountIssuer || len(claims.Aud) == 0 || claims.Exp == 0) {
+		return rejectUnbound, fmt.Errorf("a bound service account token is required")
+	}
+	if len(p.Issuers) > 0 && !contains(p.Issuers, claims.Iss) {
+		return rejectIssuer, fmt.Errorf("the token issuer %q is not trusted", claims.Iss)
+	}
+	if len(p.Audiences) > 0 {
+		for _, aud := range claims.Aud {
+			if contains(p.Audiences, aud) {
+				return "", nil
+			}
+		}
+		return rejectAudience, fmt.Errorf("the token audiences %v do not include any of %v", []string(claims.Aud), p.Audiences)
+	}
+	return "", nil
+}
+
+// tokenClaims are the claims of a JWT checked by TokenPolicy.
+type tokenClaims struct {
+	Iss string    `json:"iss"`
+	Aud audiences `json:"aud"`
+	Exp int64     `json:"exp"`
+}
+
+// audiences is the "aud" claim, which is either a string or an array of strings.
+type audiences []string
+
+func (a *audiences) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audiences{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+func parseTokenClaims(token string) (*tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("the token has %d segments instead of 3", len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the token payload: %v", err)
+	}
+	claims := &tokenClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the token claims: %v", err)
+	}
+	return claims, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func fakeToken(claims string) string {
+	return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".c2ln"
+}
+
+func TestTokenPolicy(t *testing.T) {
+	firstParty := fakeToken(`{"iss":"kubernetes/serviceaccount","sub":"system:serviceaccount:default:sa"}`)
+	bound := fakeToken(`{"iss":"https://kubernetes.default.svc","aud":["istio-ca"],"exp":1600000000}`)
+	singleAudience := fakeToken(`{"iss":"https://kubernetes.default.svc","aud":"istio-ca","exp":1600000000}`)
+
+	testCases := map[string]struct {
+		policy TokenPolicy
+		token  string
+		reason string
+	}{
+		"empty policy": {
+			token: "opaque",
+		},
+		"malformed": {
+			policy: TokenPolicy{RequireBoundTokens: true},
+			token:  "opaque",
+			reason: rejectMalformed,
+		},
+		"first-party token rejected": {
+			policy: TokenPolicy{RequireBoundTokens: true},
+			token:  firstParty,
+			reason: rejectUnbound,
+		},
+		"bound token": {
+			policy: TokenPolicy{RequireBoundTokens: true, Audiences: []string{"other", "istio-ca"}},
+			token:  bound,
+		},
+		"single audience": {
+			policy: TokenPolicy{Audiences: []string{"istio-ca"}},
+			token:  singleAudience,
+		},
+		"wrong audience": {
+			policy: TokenPolicy{Audiences: []string{"other"}},
+			token:  bound,
+			reason: rejectAudience,
+		},
+		"trusted issuer": {
+			policy: TokenPolicy{Issuers: []string{"https://kubernetes.default.svc"}},
+			token:  bound,
+		},
+		"untrusted issuer": {
+			policy: TokenPolicy{Issuers: []string{"https://remote.cluster"}},
+			token:  bound,
+			reason: rejectIssuer,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if tc.policy.IsEmpty() {
+				if err := tc.policy.Check(tc.token); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			reason, err := tc.policy.check(tc.token)
+			if reason != tc.reason {
+				t.Errorf("expected the rejection reason %q, got %q (error %v)", tc.reason, reason, err)
+			}
+			if (err != nil) != (tc.reason != "") {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// certificateServiceName is the name of the CA gRPC service, reported by the health service.
+const certificateServiceName = "istio.v1.auth.IstioCertificateService"
+
+// HealthChecker is implemented by CAs signing with an external backend, to report whether the
+// backend is reachable.
+type HealthChecker interface {
+	// Healthy returns an error if the CA cannot sign.
+	Healthy() error
+}
+
+// healthServer is the standard gRPC health service of the CA server. The status of the CA service
+// is refreshed from the readiness of the CA on each health check.
+type healthServer struct {
+	*health.Server
+	caServer *Server
+	// overall is set when the gRPC server only serves the CA, in which case the status of the
+	// server ("") follows the CA. Otherwise the server is reported as serving.
+	overall bool
+}
+
+func newHealthServer(caServer *Server, overall bool) *healthServer {
+	h := &healthServer{
+		Server:   health.NewServer(),
+		caServer: caServer,
+		overall:  overall,
+	}
+	h.refresh()
+	return h
+}
+
+// Check implements healthpb.HealthServer.
+func (h *healthServer) Check(ctx context.Context, in *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	h.refresh()
+	return h.Server.Check(ctx, in)
+}
+
+// Watch implements healthpb.HealthServer.
+func (h *healthServer) Watch(in *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	h.refresh()
+	return h.Server.Watch(in, stream)
+}
+
+func (h *healthServer) refresh() {
+	status := h.caServer.servingStatus()
+	h.SetServingStatus(certificateServiceName, status)
+	if h.overall {
+		h.SetServingStatus("", status)
+	}
+}
+
+// servingStatus returns whether the CA can sign: its trust bundle is loaded and, for CAs with an
+// external backend, the backend is healthy.
+func (s *Server) servingStatus() healthpb.HealthCheckResponse_ServingStatus {
+	if bundle := s.ca.GetCAKeyCertBundle(); bundle == nil || len(bundle.GetRootCertPem()) == 0 {
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	if checker, ok := s.ca.(HealthChecker); ok {
+		if err := checker.Healthy(); err != nil {
+			serverCaLog.Warnf("CA is not healthy: %v", err)
+			return healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	return healthpb.HealthCheckResponse_SERVING
+}
@@ -0,0 +1,88 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/net/context"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	mockutil "istio.io/istio/security/pkg/pki/util/mock"
+)
+
+type healthCheckedCA struct {
+	*mockca.FakeCA
+	err error
+}
+
+func (ca *healthCheckedCA) Healthy() error {
+	return ca.err
+}
+
+func TestHealthServer(t *testing.T) {
+	testCases := map[string]struct {
+		ca       CertificateAuthority
+		overall  bool
+		expected healthpb.HealthCheckResponse_ServingStatus
+	}{
+		"CA ready": {
+			ca:       &mockca.FakeCA{},
+			expected: healthpb.HealthCheckResponse_SERVING,
+		},
+		"CA ready on its own server": {
+			ca:       &mockca.FakeCA{},
+			overall:  true,
+			expected: healthpb.HealthCheckResponse_SERVING,
+		},
+		"trust bundle not loaded": {
+			ca:       &mockca.FakeCA{KeyCertBundle: &mockutil.FakeKeyCertBundle{}},
+			expected: healthpb.HealthCheckResponse_NOT_SERVING,
+		},
+		"backend healthy": {
+			ca:       &healthCheckedCA{FakeCA: &mockca.FakeCA{}},
+			expected: healthpb.HealthCheckResponse_SERVING,
+		},
+		"backend unreachable": {
+			ca:       &healthCheckedCA{FakeCA: &mockca.FakeCA{}, err: errors.New("unreachable")},
+			overall:  true,
+			expected: healthpb.HealthCheckResponse_NOT_SERVING,
+		},
+	}
+
+	for id, tc := range testCases {
+		h := newHealthServer(&Server{ca: tc.ca}, tc.overall)
+		resp, err := h.Check(context.Background(), &healthpb.HealthCheckRequest{Service: certificateServiceName})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", id, err)
+		} else if resp.Status != tc.expected {
+			t.Errorf("%s: got status %v, want %v", id, resp.Status, tc.expected)
+		}
+
+		// A shared server is serving regardless of the CA.
+		expectedOverall := healthpb.HealthCheckResponse_SERVING
+		if tc.overall {
+			expectedOverall = tc.expected
+		}
+		resp, err = h.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", id, err)
+		} else if resp.Status != expectedOverall {
+			t.Errorf("%s: got server status %v, want %v", id, resp.Status, expectedOverall)
+		}
+	}
+}
@@ -30,11 +30,21 @@ var (
 		"The number of CSRs received by Citadel server.",
 	)
 
+	csrBatchCounts = monitoring.NewSum(
+		"citadel_server_csr_batch_count",
+		"The number of batch CSR requests received by Citadel server.",
+	)
+
 	authnErrorCounts = monitoring.NewSum(
 		"citadel_server_authentication_failure_count",
 		"The number of authentication failures.",
 	)
 
+	authzErrorCounts = monitoring.NewSum(
+		"citadel_server_authorization_failure_count",
+		"The number of CSRs denied by the SAN authorization policy.",
+	)
+
 	csrParsingErrorCounts = monitoring.NewSum(
 		"citadel_server_csr_parsing_err_count",
 		"The number of errors occurred when parsing the CSR.",
@@ -56,6 +66,11 @@ var (
 		"The number of certificates issuances that have succeeded.",
 	)
 
+	throttledCounts = monitoring.NewSum(
+		"citadel_server_csr_throttled_count",
+		"The number of CSRs rejected by the per-caller rate limit.",
+	)
+
 	rootCertExpiryTimestamp = monitoring.NewGauge(
 		"citadel_server_root_cert_expiry_timestamp",
 		"The unix timestamp, in seconds, when Citadel root cert will expire. "+
@@ -71,11 +86,14 @@ var (
 func init() {
 	monitoring.MustRegister(
 		csrCounts,
+		csrBatchCounts,
 		authnErrorCounts,
+		authzErrorCounts,
 		csrParsingErrorCounts,
 		idExtractionErrorCounts,
 		certSignErrorCounts,
 		successCounts,
+		throttledCounts,
 		rootCertExpiryTimestamp,
 		certChainExpiryTimestamp,
 	)
@@ -84,22 +102,28 @@ func init() {
 // monitoringMetrics are counters for certificate signing related operations.
 type monitoringMetrics struct {
 	CSR               monitoring.Metric
+	Batch             monitoring.Metric
 	AuthnError        monitoring.Metric
+	AuthzError        monitoring.Metric
 	Success           monitoring.Metric
 	CSRError          monitoring.Metric
 	IDExtractionError monitoring.Metric
 	certSignErrors    monitoring.Metric
+	Throttled         monitoring.Metric
 }
 
 // newMonitoringMetrics creates a new monitoringMetrics.
 func newMonitoringMetrics() monitoringMetrics {
 	return monitoringMetrics{
 		CSR:               csrCounts,
+		Batch:             csrBatchCounts,
 		AuthnError:        authnErrorCounts,
+		AuthzError:        authzErrorCounts,
 		Success:           successCounts,
 		CSRError:          csrParsingErrorCounts,
 		IDExtractionError: idExtractionErrorCounts,
 		certSignErrors:    certSignErrorCounts,
+		Throttled:         throttledCounts,
 	}
 }
 
@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"errors"
+
+	"golang.org/x/net/context"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	k8scache "k8s.io/client-go/tools/cache"
+
+	"istio.io/istio/pkg/spiffe"
+)
+
+// namespaceLabels watches the labels of the namespaces, to select the namespaces the CA policies
+// apply to.
+type namespaceLabels struct {
+	informer k8scache.SharedIndexInformer
+}
+
+func newNamespaceLabels(client kubernetes.Interface) *namespaceLabels {
+	return &namespaceLabels{
+		informer: k8scache.NewSharedIndexInformer(
+			&k8scache.ListWatch{
+				ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+					return client.CoreV1().Namespaces().List(context.TODO(), opts)
+				},
+				WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+					return client.CoreV1().Namespaces().Watch(context.TODO(), opts)
+				},
+			},
+			&v1.Namespace{}, 0, k8scache.Indexers{},
+		),
+	}
+}
+
+// run watches the namespaces until stop is closed.
+func (n *namespaceLabels) run(stop <-chan struct{}) {
+	n.informer.Run(stop)
+}
+
+// get returns the labels of namespace, or nil if it does not exist.
+func (n *namespaceLabels) get(namespace string) (labels.Set, error) {
+	if !n.informer.HasSynced() {
+		return nil, errors.New("the namespaces are not synced yet")
+	}
+	obj, exists, err := n.informer.GetStore().GetByKey(namespace)
+	if err != nil || !exists {
+		return nil, err
+	}
+	return labels.Set(obj.(*v1.Namespace).Labels), nil
+}
+
+// identityNamespace returns the namespace of the first SPIFFE identity in ids, or "" if there is none.
+func identityNamespace(ids []string) string {
+	for _, id := range ids {
+		if identity, err := spiffe.ParseIdentity(id); err == nil {
+			return identity.Namespace
+		}
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/security/pkg/audit"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+	pb "istio.io/istio/security/proto"
+)
+
+// IssuanceRequest is the input of an IssuancePolicy, describing a certificate about to be signed.
+type IssuanceRequest struct {
+	// Identity is the identity of the requester, the first of SANs.
+	Identity string `json:"identity"`
+	// SANs are the SANs of the certificate.
+	SANs []string `json:"sans"`
+	// TTLSeconds is the requested lifetime of the certificate.
+	TTLSeconds int64 `json:"ttlSeconds"`
+	// Peer is the address of the requester.
+	Peer string `json:"peer"`
+	// CredentialType is the type of the credential the requester authenticated with.
+	CredentialType string `json:"credentialType"`
+}
+
+// IssuanceDecision is the output of an IssuancePolicy.
+type IssuanceDecision struct {
+	Allow bool `json:"allow"`
+	// Reason explains a denial.
+	Reason string `json:"reason,omitempty"`
+}
+
+// IssuancePolicy decides whether a certificate may be signed, so that the issuance policy can be
+// expressed outside of the CA.
+type IssuancePolicy interface {
+	Evaluate(ctx context.Context, request IssuanceRequest) (IssuanceDecision, error)
+}
+
+// WebhookIssuancePolicy evaluates the issuance requests with an HTTP endpoint. The request is posted
+// as {"input": <IssuanceRequest>} and the decision is read from {"result": <IssuanceDecision>}, as
+// in the data API of Open Policy Agent, e.g. http://localhost:8181/v1/data/istio/issuance.
+type WebhookIssuancePolicy struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookIssuancePolicy creates a WebhookIssuancePolicy evaluating the requests with url, failing
+// the evaluations that take longer than timeout.
+func NewWebhookIssuancePolicy(url string, timeout time.Duration) *WebhookIssuancePolicy {
+	return &WebhookIssuancePolicy{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Evaluate implements IssuancePolicy. A missing result, e.g. when the OPA policy is not loaded, is
+// an error.
+func (p *WebhookIssuancePolicy) Evaluate(ctx context.Context, request IssuanceRequest) (IssuanceDecision, error) {
+	body, err := json.Marshal(struct {
+		Input IssuanceRequest `json:"input"`
+	}{request})
+	if err != nil {
+		return IssuanceDecision{}, err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return IssuanceDecision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return IssuanceDecision{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return IssuanceDecision{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var response struct {
+		Result *IssuanceDecision `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return IssuanceDecision{}, fmt.Errorf("failed to decode the decision: %v", err)
+	}
+	if response.Result == nil {
+		return IssuanceDecision{}, fmt.Errorf("the policy returned no decision")
+	}
+	return *response.Result, nil
+}
+
+// checkIssuancePolicy returns a gRPC error if the issuance policy of the server denies the request of
+// caller, or fails to be evaluated and the server does not fail open.
+func (s *Server) checkIssuancePolicy(ctx context.Context, caller *authenticate.Caller,
+	request *pb.IstioCertificateRequest) error {
+	if s.issuancePolicy == nil {
+		return nil
+	}
+	ctx, span := trace.StartSpan(ctx, "istioca.IssuancePolicy")
+	defer span.End()
+	input := IssuanceRequest{
+		SANs:           caller.Identities,
+		TTLSeconds:     request.ValidityDuration,
+		Peer:           getConnectionAddress(ctx),
+		CredentialType: caller.AuthSource.String(),
+	}
+	if len(caller.Identities) > 0 {
+		input.Identity = caller.Identities[0]
+	}
+	decision, err := s.issuancePolicy.Evaluate(ctx, input)
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+		if s.issuancePolicyFailOpen {
+			serverCaLog.Warnf("failed to evaluate the issuance policy for %v, allowing the CSR (%v)", caller.Identities, err)
+			return nil
+		}
+		serverCaLog.Errorf("failed to evaluate the issuance policy for %v (%v)", caller.Identities, err)
+		auditCSR(ctx, caller, audit.Deny, fmt.Sprintf("issuance policy failure: %v", err))
+		return status.Error(codes.Unavailable, "issuance policy evaluation failure")
+	}
+	if !decision.Allow {
+		s.monitoring.AuthzError.Increment()
+		auditCSR(ctx, caller, audit.Deny, fmt.Sprintf("denied by the issuance policy: %s", decision.Reason))
+		return status.Errorf(codes.PermissionDenied, "denied by the issuance policy (%s)", decision.Reason)
+	}
+	return nil
+}
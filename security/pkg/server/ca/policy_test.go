@@ -0,0 +1,167 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	mockutil "istio.io/istio/security/pkg/pki/util/mock"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+	pb "istio.io/istio/security/proto"
+)
+
+func TestWebhookIssuancePolicy(t *testing.T) {
+	request := IssuanceRequest{
+		Identity:       "spiffe://cluster.local/ns/foo/sa/foo",
+		SANs:           []string{"spiffe://cluster.local/ns/foo/sa/foo"},
+		TTLSeconds:     3600,
+		Peer:           "10.0.0.1:1234",
+		CredentialType: "JWT",
+	}
+	testCases := map[string]struct {
+		response string
+		status   int
+		expected IssuanceDecision
+		err      bool
+	}{
+		"allow": {
+			response: `{"result": {"allow": true}}`,
+			status:   http.StatusOK,
+			expected: IssuanceDecision{Allow: true},
+		},
+		"deny": {
+			response: `{"result": {"allow": false, "reason": "TTL too long"}}`,
+			status:   http.StatusOK,
+			expected: IssuanceDecision{Reason: "TTL too long"},
+		},
+		"undefined decision": {
+			response: `{}`,
+			status:   http.StatusOK,
+			err:      true,
+		},
+		"server error": {
+			status: http.StatusInternalServerError,
+			err:    true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Input IssuanceRequest `json:"input"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("failed to decode the request: %v", err)
+				}
+				if !reflect.DeepEqual(body.Input, request) {
+					t.Errorf("expected input %+v, got %+v", request, body.Input)
+				}
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.response))
+			}))
+			defer server.Close()
+
+			decision, err := NewWebhookIssuancePolicy(server.URL, time.Second).Evaluate(context.Background(), request)
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected an error, got decision %+v", decision)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if decision != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, decision)
+			}
+		})
+	}
+}
+
+type fakeIssuancePolicy struct {
+	decision IssuanceDecision
+	err      error
+	requests []IssuanceRequest
+}
+
+func (p *fakeIssuancePolicy) Evaluate(_ context.Context, request IssuanceRequest) (IssuanceDecision, error) {
+	p.requests = append(p.requests, request)
+	return p.decision, p.err
+}
+
+func TestCreateCertificateIssuancePolicy(t *testing.T) {
+	testCases := map[string]struct {
+		policy   *fakeIssuancePolicy
+		failOpen bool
+		code     codes.Code
+	}{
+		"allowed": {
+			policy: &fakeIssuancePolicy{decision: IssuanceDecision{Allow: true}},
+			code:   codes.OK,
+		},
+		"denied": {
+			policy: &fakeIssuancePolicy{decision: IssuanceDecision{Reason: "not allowed"}},
+			code:   codes.PermissionDenied,
+		},
+		"evaluation failure": {
+			policy: &fakeIssuancePolicy{err: fmt.Errorf("connection refused")},
+			code:   codes.Unavailable,
+		},
+		"evaluation failure with fail open": {
+			policy:   &fakeIssuancePolicy{err: fmt.Errorf("connection refused")},
+			failOpen: true,
+			code:     codes.OK,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			server := &Server{
+				ca: &mockca.FakeCA{
+					SignedCert:    []byte("cert"),
+					KeyCertBundle: &mockutil.FakeKeyCertBundle{},
+				},
+				Authenticators: []authenticate.Authenticator{&mockAuthenticator{identities: []string{"id"}}},
+				monitoring:     newMonitoringMetrics(),
+			}
+			server.SetIssuancePolicy(tc.policy, tc.failOpen)
+			_, err := server.CreateCertificate(context.Background(),
+				&pb.IstioCertificateRequest{Csr: "dumb CSR", ValidityDuration: 3600})
+			if code := status.Code(err); code != tc.code {
+				t.Errorf("expected code %v, got %v (%v)", tc.code, code, err)
+			}
+			expected := []IssuanceRequest{{
+				Identity:       "id",
+				SANs:           []string{"id"},
+				TTLSeconds:     3600,
+				Peer:           "unknown",
+				CredentialType: authenticate.AuthSource(0).String(),
+			}}
+			if !reflect.DeepEqual(tc.policy.requests, expected) {
+				t.Errorf("expected policy requests %+v, got %+v", expected, tc.policy.requests)
+			}
+		})
+	}
+}
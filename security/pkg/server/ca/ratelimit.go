@@ -0,0 +1,95 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// idleLimiterTimeout is how long the rate limiter of a caller without requests is kept.
+	idleLimiterTimeout = 10 * time.Minute
+	// unknownCaller is the rate limiting key of callers without an identity.
+	unknownCaller = "unknown"
+)
+
+// RateLimitConfig configures the per-caller rate limit of CSR signing requests.
+type RateLimitConfig struct {
+	// QPS is the sustained number of requests per second allowed for each caller.
+	QPS float64
+	// Burst is the number of requests a caller can make at once.
+	Burst int
+}
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// callerRateLimiter keeps a token bucket per caller identity.
+type callerRateLimiter struct {
+	mutex     sync.Mutex
+	config    RateLimitConfig
+	limiters  map[string]*limiterEntry
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func newCallerRateLimiter(config RateLimitConfig) *callerRateLimiter {
+	return &callerRateLimiter{
+		config:    config,
+		limiters:  map[string]*limiterEntry{},
+		lastPrune: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Allow returns whether a request from the caller with the given identities is allowed. Callers are
+// keyed by their first identity, which is the SPIFFE identity of the workload's service account.
+func (l *callerRateLimiter) Allow(identities []string) bool {
+	key := unknownCaller
+	if len(identities) > 0 {
+		key = identities[0]
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	l.pruneLocked(now)
+	entry, ok := l.limiters[key]
+	if !ok {
+		entry = &limiterEntry{limiter: rate.NewLimiter(rate.Limit(l.config.QPS), l.config.Burst)}
+		l.limiters[key] = entry
+	}
+	entry.lastSeen = now
+	return entry.limiter.AllowN(now, 1)
+}
+
+// pruneLocked drops the limiters of callers idle for longer than idleLimiterTimeout, so that the
+// number of limiters stays bounded by the number of active callers.
+func (l *callerRateLimiter) pruneLocked(now time.Time) {
+	if now.Sub(l.lastPrune) < idleLimiterTimeout {
+		return
+	}
+	for key, entry := range l.limiters {
+		if now.Sub(entry.lastSeen) > idleLimiterTimeout {
+			delete(l.limiters, key)
+		}
+	}
+	l.lastPrune = now
+}
@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	mockutil "istio.io/istio/security/pkg/pki/util/mock"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+	pb "istio.io/istio/security/proto"
+)
+
+func TestCallerRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := newCallerRateLimiter(RateLimitConfig{QPS: 1, Burst: 2})
+	limiter.now = func() time.Time { return now }
+
+	foo := []string{"spiffe://cluster.local/ns/foo/sa/foo"}
+	bar := []string{"spiffe://cluster.local/ns/bar/sa/bar"}
+	for i := 0; i < 2; i++ {
+		if !limiter.Allow(foo) {
+			t.Fatalf("request %d within the burst is not allowed", i)
+		}
+	}
+	if limiter.Allow(foo) {
+		t.Error("request over the burst is allowed")
+	}
+	if !limiter.Allow(bar) {
+		t.Error("request from another caller is not allowed")
+	}
+
+	now = now.Add(time.Second)
+	if !limiter.Allow(foo) {
+		t.Error("request after the refill is not allowed")
+	}
+
+	now = now.Add(2 * idleLimiterTimeout)
+	limiter.Allow(foo)
+	if _, ok := limiter.limiters[bar[0]]; ok {
+		t.Error("limiter of an idle caller is not pruned")
+	}
+}
+
+func TestCreateCertificateRateLimit(t *testing.T) {
+	server := &Server{
+		ca: &mockca.FakeCA{
+			SignedCert:    []byte("cert"),
+			KeyCertBundle: &mockutil.FakeKeyCertBundle{RootCertBytes: []byte("root_cert")},
+		},
+		hostnames: []string{"hostname"},
+		Authenticators: []authenticate.Authenticator{&mockAuthenticator{
+			identities: []string{"spiffe://cluster.local/ns/foo/sa/foo"},
+		}},
+		monitoring: newMonitoringMetrics(),
+	}
+	server.SetRateLimit(RateLimitConfig{QPS: 0.001, Burst: 1})
+	request := &pb.IstioCertificateRequest{Csr: "dumb CSR"}
+
+	if _, err := server.CreateCertificate(context.Background(), request); err != nil {
+		t.Fatalf("first request failed: %v", err)
+	}
+	_, err := server.CreateCertificate(context.Background(), request)
+	if s, _ := status.FromError(err); s.Code() != codes.ResourceExhausted {
+		t.Errorf("expecting code ResourceExhausted but got %v", s.Code())
+	}
+
+	server.SetRateLimit(RateLimitConfig{})
+	if _, err := server.CreateCertificate(context.Background(), request); err != nil {
+		t.Errorf("request failed after disabling the rate limit: %v", err)
+	}
+}
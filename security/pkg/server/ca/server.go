@@ -17,21 +17,28 @@ package ca
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"net"
 	"time"
 
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"go.opencensus.io/trace"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 	"k8s.io/client-go/kubernetes"
 
 	"istio.io/pkg/log"
 
+	"istio.io/istio/security/pkg/audit"
+	pkica "istio.io/istio/security/pkg/pki/ca"
 	caerror "istio.io/istio/security/pkg/pki/error"
 	"istio.io/istio/security/pkg/pki/util"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
@@ -43,6 +50,12 @@ const (
 	jwtPath              = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 	caCertPath           = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
 	certExpirationBuffer = time.Minute
+
+	// maxBatchSize is the maximum number of CSRs in a batch request.
+	maxBatchSize = 100
+
+	authorizationMeta = "authorization"
+	bearerTokenPrefix = "Bearer "
 )
 
 var serverCaLog = log.RegisterScope("serverca", "Citadel server log", 0)
@@ -54,10 +67,19 @@ type CertificateAuthority interface {
 	Sign(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error)
 	// SignWithCertChain is similar to Sign but returns the leaf cert and the entire cert chain.
 	SignWithCertChain(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error)
+	// SignWithResult is similar to Sign but returns the leaf cert together with the cert chain, root certs,
+	// expiration time and serial number.
+	SignWithResult(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) (*pkica.SignResult, error)
 	// GetCAKeyCertBundle returns the KeyCertBundle used by CA.
 	GetCAKeyCertBundle() util.KeyCertBundle
 }
 
+// SANAuthorizer is implemented by CAs restricting which identities may request which SANs.
+type SANAuthorizer interface {
+	// AuthorizeSANs returns an error if requester is not allowed to request sans.
+	AuthorizeSANs(requester string, sans []string) error
+}
+
 // Server implements IstioCAService and IstioCertificateService and provides the services on the
 // specified port.
 type Server struct {
@@ -70,6 +92,21 @@ type Server struct {
 	port           int
 	forCA          bool
 	grpcServer     *grpc.Server
+
+	// rateLimiter limits the CSR signing requests of each caller. Nil if rate limiting is disabled.
+	rateLimiter *callerRateLimiter
+
+	// issuancePolicy is consulted before signing, if set.
+	issuancePolicy IssuancePolicy
+	// issuancePolicyFailOpen allows the signing when issuancePolicy fails to be evaluated.
+	issuancePolicyFailOpen bool
+
+	// getServingCertificate returns the serving certificate of the CA endpoint, if set. Otherwise the
+	// CA signs its own serving certificate.
+	getServingCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+	// signingPolicy restricts the certificates issued to the workloads of each namespace, if set.
+	signingPolicy *SigningPolicy
 }
 
 func getConnectionAddress(ctx context.Context) string {
@@ -88,31 +125,173 @@ func getConnectionAddress(ctx context.Context) string {
 // the validity duration is the ValidityDuration in request, or default value if the given duration is invalid.
 // it is signed by the CA signing key.
 func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertificateRequest) (
+	*pb.IstioCertificateResponse, error) {
+	return s.createCertificate(ctx, request)
+}
+
+// CreateCertificateBatch handles a batch of CSRs in one round trip. Each CSR is authenticated and signed
+// as by CreateCertificate, with the bearer token of the item if set, or else the credentials of the batch
+// request. A failed CSR does not fail the batch; its status is returned in its result.
+func (s *Server) CreateCertificateBatch(ctx context.Context, request *pb.IstioCertificateBatchRequest) (
+	*pb.IstioCertificateBatchResponse, error) {
+	if len(request.Requests) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch of %d CSRs exceeds the limit of %d",
+			len(request.Requests), maxBatchSize)
+	}
+	s.monitoring.Batch.Increment()
+	response := &pb.IstioCertificateBatchResponse{
+		Results: make([]*pb.IstioCertificateBatchResult, 0, len(request.Requests)),
+	}
+	for _, item := range request.Requests {
+		result := &pb.IstioCertificateBatchResult{}
+		if item.Request == nil {
+			result.Code = int32(codes.InvalidArgument)
+			result.Message = "missing certificate request"
+			response.Results = append(response.Results, result)
+			continue
+		}
+		itemCtx := ctx
+		if item.Token != "" {
+			itemCtx = withBearerToken(ctx, item.Token)
+		}
+		resp, err := s.createCertificate(itemCtx, item.Request)
+		if err != nil {
+			st, _ := status.FromError(err)
+			result.Code = int32(st.Code())
+			result.Message = st.Message()
+		} else {
+			result.Response = resp
+		}
+		response.Results = append(response.Results, result)
+	}
+	return response, nil
+}
+
+// requestedSANs returns the identities the caller is issued a cert for, followed by the other SANs
+// requested in its CSR, which are checked against the SAN policy of the CA. The SANs of an invalid CSR
+// are ignored here, the CSR is rejected when it is signed.
+func requestedSANs(caller *authenticate.Caller, request *pb.IstioCertificateRequest) []string {
+	sans := append([]string{}, caller.Identities...)
+	csr, err := util.ParsePemEncodedCSR([]byte(request.Csr))
+	if err != nil {
+		return sans
+	}
+	for _, san := range util.CSRSANs(csr) {
+		if !containsString(sans, san) {
+			sans = append(sans, san)
+		}
+	}
+	return sans
+}
+
+// splitPEM returns the PEM blocks in pemBytes, each PEM-encoded separately.
+func splitPEM(pemBytes []byte) []string {
+	var blocks []string
+	for rest := pemBytes; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return blocks
+		}
+		blocks = append(blocks, string(pem.EncodeToMemory(block)))
+	}
+}
+
+// withBearerToken returns a copy of ctx whose incoming metadata authenticates with token.
+func withBearerToken(ctx context.Context, token string) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	md.Set(authorizationMeta, bearerTokenPrefix+token)
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+func (s *Server) createCertificate(ctx context.Context, request *pb.IstioCertificateRequest) (
 	*pb.IstioCertificateResponse, error) {
 	s.monitoring.CSR.Increment()
+	ctx, span := trace.StartSpan(ctx, "istioca.CreateCertificate")
+	defer span.End()
 	caller := s.authenticate(ctx)
 	if caller == nil {
 		s.monitoring.AuthnError.Increment()
+		auditCSR(ctx, nil, audit.Deny, "authentication failure")
 		return nil, status.Error(codes.Unauthenticated, "request authenticate failure")
 	}
 
-	// TODO: Call authorizer.
+	span.AddAttributes(identityAttributes(caller.Identities)...)
+
+	if s.rateLimiter != nil && !s.rateLimiter.Allow(caller.Identities) {
+		s.monitoring.Throttled.Increment()
+		serverCaLog.Warnf("CSR from %v (identities %v) is rate limited", getConnectionAddress(ctx), caller.Identities)
+		auditCSR(ctx, caller, audit.Deny, "rate limited")
+		return nil, status.Error(codes.ResourceExhausted, "CSR rate limit exceeded")
+	}
+
+	if authorizer, ok := s.ca.(SANAuthorizer); ok && len(caller.Identities) > 0 {
+		if err := authorizer.AuthorizeSANs(caller.Identities[0], requestedSANs(caller, request)); err != nil {
+			s.monitoring.AuthzError.Increment()
+			auditCSR(ctx, caller, audit.Deny, err.Error())
+			return nil, status.Errorf(codes.PermissionDenied, "SAN authorization failure (%v)", err)
+		}
+	}
+
+	if err := s.checkIssuancePolicy(ctx, caller, request); err != nil {
+		return nil, err
+	}
+
+	ttl, err := s.checkSigningPolicy(ctx, caller, request)
+	if err != nil {
+		return nil, err
+	}
 
-	_, _, certChainBytes, rootCertBytes := s.ca.GetCAKeyCertBundle().GetAll()
-	cert, signErr := s.ca.Sign(
-		[]byte(request.Csr), caller.Identities, time.Duration(request.ValidityDuration)*time.Second, false)
+	_, signSpan := trace.StartSpan(ctx, "istioca.Sign")
+	signSpan.AddAttributes(trace.Int64Attribute("ttl_seconds", int64(ttl/time.Second)))
+	result, signErr := s.ca.SignWithResult([]byte(request.Csr), caller.Identities, ttl, false)
 	if signErr != nil {
-		serverCaLog.Errorf("CSR signing error (%v)", signErr.Error())
+		signSpan.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: signErr.Error()})
+	}
+	signSpan.End()
+	if signErr != nil {
+		if caerror.IsRetryable(signErr) {
+			// The client retries the request, so this is only an error if it persists.
+			serverCaLog.Warnf("CSR signing error, the request can be retried (%v)", signErr.Error())
+		} else {
+			serverCaLog.Errorf("CSR signing error (%v)", signErr.Error())
+		}
 		s.monitoring.GetCertSignError(signErr.(*caerror.Error).ErrorType()).Increment()
+		auditCSR(ctx, caller, audit.Deny, signErr.Error())
 		return nil, status.Errorf(signErr.(*caerror.Error).HTTPErrorCode(), "CSR signing error (%v)", signErr.(*caerror.Error))
 	}
-	respCertChain := []string{string(cert)}
-	if len(certChainBytes) != 0 {
-		respCertChain = append(respCertChain, string(certChainBytes))
+	if caller.Consume != nil {
+		// The cert is only returned if the single-use credential was not used by a concurrent request.
+		if err := caller.Consume(); err != nil {
+			s.monitoring.AuthnError.Increment()
+			auditCSR(ctx, caller, audit.Deny, err.Error())
+			return nil, status.Errorf(codes.Unauthenticated, "request authenticate failure (%v)", err)
+		}
+	}
+	serialNumber := ""
+	if result.SerialNumber != nil {
+		serialNumber = result.SerialNumber.Text(16)
+	}
+	auditCSRIssued(ctx, caller, serialNumber, result.NotAfter)
+	respCertChain := []string{string(result.Leaf)}
+	if len(result.CertChain) != 0 {
+		respCertChain = append(respCertChain, string(result.CertChain))
 	}
-	respCertChain = append(respCertChain, string(rootCertBytes))
+	respCertChain = append(respCertChain, string(result.RootCerts))
 	response := &pb.IstioCertificateResponse{
-		CertChain: respCertChain,
+		CertChain:     respCertChain,
+		Leaf:          string(result.Leaf),
+		Intermediates: splitPEM(result.CertChain),
+		TrustBundle:   splitPEM(result.RootCerts),
+		SerialNumber:  serialNumber,
+	}
+	if !result.NotAfter.IsZero() {
+		response.NotAfter = result.NotAfter.Unix()
 	}
 	s.monitoring.Success.Increment()
 	serverCaLog.Debug("CSR successfully signed.")
@@ -120,6 +299,71 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 	return response, nil
 }
 
+// SetRateLimit limits the CSR signing requests of each caller identity to config.QPS, with bursts of
+// up to config.Burst requests. Requests over the limit fail with ResourceExhausted. A non-positive QPS
+// disables rate limiting.
+func (s *Server) SetRateLimit(config RateLimitConfig) {
+	if config.QPS <= 0 {
+		s.rateLimiter = nil
+		return
+	}
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	s.rateLimiter = newCallerRateLimiter(config)
+}
+
+// SetIssuancePolicy consults policy before signing each certificate. If failOpen is true, the
+// certificates are signed when the policy fails to be evaluated, otherwise the requests fail.
+func (s *Server) SetIssuancePolicy(policy IssuancePolicy, failOpen bool) {
+	s.issuancePolicy = policy
+	s.issuancePolicyFailOpen = failOpen
+}
+
+// SetSigningPolicy enforces policy on the certificates issued to the workloads of each namespace.
+func (s *Server) SetSigningPolicy(policy *SigningPolicy) {
+	s.signingPolicy = policy
+}
+
+// SetServingCertificate makes the CA endpoint serve with the certificates returned by getCertificate,
+// e.g. an operator-provided certificate, instead of a certificate signed by the CA itself. It only
+// applies when the CA runs its own gRPC server.
+func (s *Server) SetServingCertificate(getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) {
+	s.getServingCertificate = getCertificate
+}
+
+// SetTokenAudiences requires the Kubernetes service account tokens of the callers to be bound to one
+// of audiences.
+func (s *Server) SetTokenAudiences(audiences []string) {
+	for _, a := range s.Authenticators {
+		if kubeAuthenticator, ok := a.(*authenticate.KubeJWTAuthenticator); ok {
+			kubeAuthenticator.SetAudiences(audiences)
+		}
+	}
+}
+
+// SetTokenPolicy rejects the bearer tokens of the callers that do not comply with policy.
+func (s *Server) SetTokenPolicy(policy authenticate.TokenPolicy) {
+	for _, a := range s.Authenticators {
+		switch tokenAuthenticator := a.(type) {
+		case *authenticate.KubeJWTAuthenticator:
+			tokenAuthenticator.SetTokenPolicy(policy)
+		case *authenticate.JwtAuthenticator:
+			tokenAuthenticator.SetTokenPolicy(policy)
+		}
+	}
+}
+
+// SetTrustDomain sets the trust domain of the identities the authenticators derive from the tokens of
+// the callers.
+func (s *Server) SetTrustDomain(trustDomain string) {
+	for _, a := range s.Authenticators {
+		if setter, ok := a.(interface{ SetTrustDomain(string) }); ok {
+			setter.SetTrustDomain(trustDomain)
+		}
+	}
+}
+
 func recordCertsExpiry(keyCertBundle util.KeyCertBundle) {
 	rootCertExpiry, err := keyCertBundle.ExtractRootCertExpiryTimestamp()
 	if err != nil {
@@ -156,6 +400,11 @@ func (s *Server) Run() error {
 		grpcServer = grpc.NewServer(grpcOptions...)
 	}
 	pb.RegisterIstioCertificateServiceServer(grpcServer, s)
+	healthpb.RegisterHealthServer(grpcServer, newHealthServer(s, listener != nil))
+	// Istiod registers the reflection service on its own gRPC servers.
+	if listener != nil {
+		reflection.Register(grpcServer)
+	}
 
 	grpc_prometheus.EnableHandlingTimeHistogram()
 	grpc_prometheus.Register(grpcServer)
@@ -227,7 +476,10 @@ func (s *Server) createTLSServerOption() grpc.ServerOption {
 	config := &tls.Config{
 		ClientCAs:  cp,
 		ClientAuth: tls.VerifyClientCertIfGiven,
-		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		GetCertificate: func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if s.getServingCertificate != nil {
+				return s.getServingCertificate(info)
+			}
 			if s.certificate == nil || shouldRefresh(s.certificate) {
 				// Apply new certificate if there isn't one yet, or the one has become invalid.
 				newCert, err := s.getServerCertificate()
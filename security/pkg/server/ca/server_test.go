@@ -19,11 +19,14 @@ import (
 	"crypto/x509"
 	"fmt"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"k8s.io/client-go/kubernetes/fake"
 
@@ -31,6 +34,7 @@ import (
 	"istio.io/istio/security/pkg/pki/ca"
 	mockca "istio.io/istio/security/pkg/pki/ca/mock"
 	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
 	mockutil "istio.io/istio/security/pkg/pki/util/mock"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
 	pb "istio.io/istio/security/proto"
@@ -53,6 +57,9 @@ type mockAuthenticator struct {
 	authSource authenticate.AuthSource
 	identities []string
 	errMsg     string
+	// consumeErr, if set, makes the callers single use, returning consumeErr when they are consumed.
+	consumeErr error
+	consumed   int
 }
 
 func (authn *mockAuthenticator) AuthenticatorType() string {
@@ -64,10 +71,20 @@ func (authn *mockAuthenticator) Authenticate(ctx context.Context) (*authenticate
 		return nil, fmt.Errorf("%v", authn.errMsg)
 	}
 
-	return &authenticate.Caller{
+	caller := &authenticate.Caller{
 		AuthSource: authn.authSource,
 		Identities: authn.identities,
-	}, nil
+	}
+	if authn.consumeErr != nil {
+		caller.Consume = func() error {
+			authn.consumed++
+			if authn.consumed > 1 {
+				return authn.consumeErr
+			}
+			return nil
+		}
+	}
+	return caller, nil
 }
 
 func TestCreateCertificate(t *testing.T) {
@@ -109,6 +126,13 @@ func TestCreateCertificate(t *testing.T) {
 			ca:             &mockca.FakeCA{SignErr: caerror.NewError(caerror.CertGenError, fmt.Errorf("cannot sign"))},
 			code:           codes.Internal,
 		},
+		"SAN authorization failure": {
+			authenticators: []authenticate.Authenticator{&mockAuthenticator{identities: []string{"id"}}},
+			ca: &mockca.FakeCA{
+				AuthorizeErr: caerror.NewError(caerror.AuthorizationError, fmt.Errorf("not allowed")),
+			},
+			code: codes.PermissionDenied,
+		},
 		"Successful signing": {
 			authenticators: []authenticate.Authenticator{&mockAuthenticator{}},
 			ca: &mockca.FakeCA{
@@ -154,6 +178,228 @@ func TestCreateCertificate(t *testing.T) {
 	}
 }
 
+func TestCreateCertificateAuthorizesCSRSANs(t *testing.T) {
+	csr, _, err := util.GenCSR(util.CertOptions{
+		Host:       "spiffe://cluster.local/ns/foo/sa/foo,www.gateway.example.com",
+		RSAKeySize: 2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to generate a CSR: %v", err)
+	}
+	fakeCA := &mockca.FakeCA{
+		AuthorizeErr: caerror.NewError(caerror.AuthorizationError, fmt.Errorf("not allowed")),
+	}
+	server := &Server{
+		ca: fakeCA,
+		Authenticators: []authenticate.Authenticator{&mockAuthenticator{
+			identities: []string{"spiffe://cluster.local/ns/foo/sa/foo"},
+		}},
+		monitoring: newMonitoringMetrics(),
+	}
+	_, err = server.CreateCertificate(context.Background(), &pb.IstioCertificateRequest{Csr: string(csr)})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected the request to be denied, got %v", err)
+	}
+	if fakeCA.AuthorizedRequester != "spiffe://cluster.local/ns/foo/sa/foo" {
+		t.Errorf("unexpected requester %q", fakeCA.AuthorizedRequester)
+	}
+	expected := []string{"spiffe://cluster.local/ns/foo/sa/foo", "www.gateway.example.com"}
+	if !reflect.DeepEqual(fakeCA.AuthorizedSANs, expected) {
+		t.Errorf("expected the SANs %v to be authorized, got %v", expected, fakeCA.AuthorizedSANs)
+	}
+}
+
+func TestCreateCertificateStructuredResponse(t *testing.T) {
+	leafPem, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         "spiffe://cluster.local/ns/foo/sa/foo",
+		TTL:          time.Hour,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to generate a cert: %v", err)
+	}
+	leaf, err := util.ParsePemEncodedCertificate(leafPem)
+	if err != nil {
+		t.Fatalf("failed to parse the cert: %v", err)
+	}
+	rootPem, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Org:          "Root CA",
+		TTL:          time.Hour,
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to generate a root cert: %v", err)
+	}
+
+	server := &Server{
+		ca: &mockca.FakeCA{
+			SignedCert: leafPem,
+			KeyCertBundle: &mockutil.FakeKeyCertBundle{
+				CertChainBytes: leafPem,
+				RootCertBytes:  append(append([]byte{}, rootPem...), rootPem...),
+			},
+		},
+		hostnames:      []string{"hostname"},
+		Authenticators: []authenticate.Authenticator{&mockAuthenticator{}},
+		monitoring:     newMonitoringMetrics(),
+	}
+	response, err := server.CreateCertificate(context.Background(), &pb.IstioCertificateRequest{Csr: "dumb CSR"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Leaf != string(leafPem) {
+		t.Errorf("expecting leaf %s but got %s", leafPem, response.Leaf)
+	}
+	if !reflect.DeepEqual(response.Intermediates, []string{string(leafPem)}) {
+		t.Errorf("unexpected intermediates %v", response.Intermediates)
+	}
+	if !reflect.DeepEqual(response.TrustBundle, []string{string(rootPem), string(rootPem)}) {
+		t.Errorf("unexpected trust bundle %v", response.TrustBundle)
+	}
+	if response.NotAfter != leaf.NotAfter.Unix() {
+		t.Errorf("expecting not after %d but got %d", leaf.NotAfter.Unix(), response.NotAfter)
+	}
+	if response.SerialNumber != leaf.SerialNumber.Text(16) {
+		t.Errorf("expecting serial number %s but got %s", leaf.SerialNumber.Text(16), response.SerialNumber)
+	}
+}
+
+func TestCreateCertificateConsumesSingleUseCredential(t *testing.T) {
+	authenticator := &mockAuthenticator{
+		identities: []string{"spiffe://cluster.local/ns/vm/sa/vm"},
+		consumeErr: fmt.Errorf("already used"),
+	}
+	fakeCA := &mockca.FakeCA{
+		SignErr:       caerror.NewError(caerror.CertGenError, fmt.Errorf("cannot sign")),
+		KeyCertBundle: &mockutil.FakeKeyCertBundle{},
+	}
+	server := &Server{
+		ca:             fakeCA,
+		Authenticators: []authenticate.Authenticator{authenticator},
+		monitoring:     newMonitoringMetrics(),
+	}
+	if _, err := server.CreateCertificate(context.Background(), &pb.IstioCertificateRequest{Csr: "dumb CSR"}); err == nil {
+		t.Fatal("expected a signing error")
+	}
+	if authenticator.consumed != 0 {
+		t.Errorf("expected the credential not to be consumed by a failed request")
+	}
+
+	fakeCA.SignErr = nil
+	fakeCA.SignedCert = []byte("cert")
+	if _, err := server.CreateCertificate(context.Background(), &pb.IstioCertificateRequest{Csr: "dumb CSR"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if authenticator.consumed != 1 {
+		t.Errorf("expected the credential to be consumed by the successful request")
+	}
+	_, err := server.CreateCertificate(context.Background(), &pb.IstioCertificateRequest{Csr: "dumb CSR"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected a reused credential to be refused, got %v", err)
+	}
+}
+
+// tokenAuthenticator authenticates the bearer token of a request as the identity with the same name.
+type tokenAuthenticator struct{}
+
+func (authn *tokenAuthenticator) AuthenticatorType() string {
+	return "tokenAuthenticator"
+}
+
+func (authn *tokenAuthenticator) Authenticate(ctx context.Context) (*authenticate.Caller, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	auth := md.Get(authorizationMeta)
+	if len(auth) != 1 || !strings.HasPrefix(auth[0], bearerTokenPrefix) {
+		return nil, fmt.Errorf("no bearer token")
+	}
+	return &authenticate.Caller{
+		AuthSource: authenticate.AuthSourceIDToken,
+		Identities: []string{strings.TrimPrefix(auth[0], bearerTokenPrefix)},
+	}, nil
+}
+
+func TestCreateCertificateBatch(t *testing.T) {
+	fakeCA := &mockca.FakeCA{
+		SignedCert: []byte("cert"),
+		KeyCertBundle: &mockutil.FakeKeyCertBundle{
+			CertChainBytes: []byte("cert_chain"),
+			RootCertBytes:  []byte("root_cert"),
+		},
+	}
+	server := &Server{
+		ca:             fakeCA,
+		hostnames:      []string{"hostname"},
+		port:           8080,
+		Authenticators: []authenticate.Authenticator{&tokenAuthenticator{}},
+		monitoring:     newMonitoringMetrics(),
+	}
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(authorizationMeta, bearerTokenPrefix+"node-agent"))
+	request := &pb.IstioCertificateBatchRequest{
+		Requests: []*pb.IstioCertificateBatchRequestItem{
+			{Request: &pb.IstioCertificateRequest{Csr: "csr1"}, Token: "workload-1"},
+			{Request: &pb.IstioCertificateRequest{Csr: "csr2"}},
+			{},
+		},
+	}
+
+	var receivedIDs [][]string
+	server.ca = &recordingCA{FakeCA: fakeCA, received: &receivedIDs}
+	response, err := server.CreateCertificateBatch(ctx, request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(response.Results) != 3 {
+		t.Fatalf("expecting 3 results but got %d", len(response.Results))
+	}
+	for i := 0; i < 2; i++ {
+		r := response.Results[i]
+		if r.Code != int32(codes.OK) || r.Response == nil || len(r.Response.CertChain) != 3 {
+			t.Errorf("result %d: unexpected result %v", i, r)
+		}
+	}
+	if response.Results[2].Code != int32(codes.InvalidArgument) {
+		t.Errorf("result 2: expecting code InvalidArgument but got %d", response.Results[2].Code)
+	}
+	expectedIDs := [][]string{{"workload-1"}, {"node-agent"}}
+	if !reflect.DeepEqual(receivedIDs, expectedIDs) {
+		t.Errorf("expecting signed identities %v but got %v", expectedIDs, receivedIDs)
+	}
+
+	fakeCA.SignErr = caerror.NewError(caerror.CSRError, fmt.Errorf("cannot sign"))
+	response, err = server.CreateCertificateBatch(ctx, request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Results[0].Code != int32(codes.InvalidArgument) || response.Results[0].Response != nil {
+		t.Errorf("expecting a failed result but got %v", response.Results[0])
+	}
+
+	request.Requests = make([]*pb.IstioCertificateBatchRequestItem, maxBatchSize+1)
+	_, err = server.CreateCertificateBatch(ctx, request)
+	if s, _ := status.FromError(err); s.Code() != codes.InvalidArgument {
+		t.Errorf("expecting code InvalidArgument for an oversized batch but got %v", s.Code())
+	}
+}
+
+// recordingCA records the identities of the signed CSRs.
+type recordingCA struct {
+	*mockca.FakeCA
+	received *[][]string
+}
+
+func (r *recordingCA) SignWithResult(csr []byte, identities []string, lifetime time.Duration, forCA bool) (
+	*ca.SignResult, error) {
+	result, err := r.FakeCA.SignWithResult(csr, identities, lifetime, forCA)
+	if err == nil {
+		*r.received = append(*r.received, identities)
+	}
+	return result, err
+}
+
 func TestShouldRefresh(t *testing.T) {
 	now := time.Now()
 	testCases := map[string]struct {
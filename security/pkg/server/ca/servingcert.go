@@ -0,0 +1,93 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileCertificate serves the certificate and key in files, e.g. an operator-provided certificate
+// mounted from a secret. The files are reloaded when they are modified.
+type FileCertificate struct {
+	certFile string
+	keyFile  string
+
+	mutex       sync.Mutex
+	certificate *tls.Certificate
+	modTime     time.Time
+}
+
+// NewFileCertificate creates a FileCertificate for certFile and keyFile, and loads them.
+func NewFileCertificate(certFile, keyFile string) (*FileCertificate, error) {
+	f := &FileCertificate{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if _, err := f.GetCertificate(nil); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// GetCertificate returns the certificate in the files, reloading them if they were modified since
+// they were last loaded. It can be passed to Server.SetServingCertificate.
+func (f *FileCertificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	modTime, err := latestModTime(f.certFile, f.keyFile)
+	if err != nil {
+		if f.certificate != nil {
+			// Keep serving the loaded certificate while the files are being replaced.
+			serverCaLog.Warnf("failed to check the serving certificate files: %v", err)
+			return f.certificate, nil
+		}
+		return nil, err
+	}
+	if f.certificate != nil && !modTime.After(f.modTime) {
+		return f.certificate, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+	if err != nil {
+		if f.certificate != nil {
+			serverCaLog.Warnf("failed to reload the serving certificate: %v", err)
+			return f.certificate, nil
+		}
+		return nil, fmt.Errorf("failed to load the serving certificate: %v", err)
+	}
+	serverCaLog.Infof("loaded the serving certificate from %s", f.certFile)
+	f.certificate = &cert
+	f.modTime = modTime
+	return f.certificate, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,100 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func writeServingCert(t *testing.T, dir, host string) {
+	certPEM, keyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         host,
+		NotBefore:    time.Now(),
+		TTL:          time.Hour,
+		IsSelfSigned: true,
+		IsServer:     true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatalf("failed to generate the serving cert: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "cert.pem"), certPEM, 0644); err != nil {
+		t.Fatalf("failed to write the serving cert: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "key.pem"), keyPEM, 0600); err != nil {
+		t.Fatalf("failed to write the serving key: %v", err)
+	}
+}
+
+func servingCertHost(t *testing.T, f *FileCertificate) string {
+	cert, err := f.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("failed to get the serving cert: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("failed to parse the serving cert: %v", err)
+	}
+	return leaf.DNSNames[0]
+}
+
+func TestFileCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "serving-cert")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	if _, err := NewFileCertificate(certFile, keyFile); err == nil {
+		t.Fatal("expected error for missing files, got none")
+	}
+
+	writeServingCert(t, dir, "ca.corp.example.com")
+	f, err := NewFileCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("failed to load the serving cert: %v", err)
+	}
+	if got := servingCertHost(t, f); got != "ca.corp.example.com" {
+		t.Errorf("got serving cert for %q, want ca.corp.example.com", got)
+	}
+
+	// The rotated certificate is served once the files are modified.
+	writeServingCert(t, dir, "ca2.corp.example.com")
+	later := time.Now().Add(time.Minute)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatalf("failed to update the mod time of %s: %v", file, err)
+		}
+	}
+	if got := servingCertHost(t, f); got != "ca2.corp.example.com" {
+		t.Errorf("got serving cert for %q, want ca2.corp.example.com", got)
+	}
+
+	// The loaded certificate is kept while the files are missing.
+	if err := os.Remove(keyFile); err != nil {
+		t.Fatalf("failed to remove the serving key: %v", err)
+	}
+	if got := servingCertHost(t, f); got != "ca2.corp.example.com" {
+		t.Errorf("got serving cert for %q, want ca2.corp.example.com", got)
+	}
+}
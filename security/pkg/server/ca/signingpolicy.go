@@ -0,0 +1,291 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"time"
+
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/security/pkg/audit"
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+	pb "istio.io/istio/security/proto"
+)
+
+// The types of the SANs of the certs.
+const (
+	SANTypeSPIFFE = "spiffe"
+	SANTypeURI    = "uri"
+	SANTypeDNS    = "dns"
+	SANTypeIP     = "ip"
+)
+
+// The types of the keys of the CSRs.
+const (
+	KeyTypeRSA     = "RSA"
+	KeyTypeECDSA   = "ECDSA"
+	KeyTypeEd25519 = "Ed25519"
+)
+
+// SigningPolicyRule restricts the certs issued to the workloads of a group of namespaces.
+type SigningPolicyRule struct {
+	// Name of the rule, reported in the denials.
+	Name string `json:"name"`
+	// Namespaces are the names of the namespaces the rule applies to.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector selects the namespaces the rule applies to by their labels.
+	NamespaceSelector map[string]string `json:"namespaceSelector,omitempty"`
+	// MaxTTL caps the lifetime of the certs, e.g. 24h. Longer lifetimes are reduced to MaxTTL.
+	MaxTTL string `json:"maxTTL,omitempty"`
+	// AllowedSANTypes are the types of the SANs the certs may hold: spiffe, uri, dns or ip.
+	// All types are allowed if empty.
+	AllowedSANTypes []string `json:"allowedSANTypes,omitempty"`
+	// KeyTypes are the types of the keys the CSRs may hold: RSA, ECDSA or Ed25519. All types are
+	// allowed if empty.
+	KeyTypes []string `json:"keyTypes,omitempty"`
+	// MinRSAKeySize is the minimum size of the RSA keys of the CSRs.
+	MinRSAKeySize int `json:"minRSAKeySize,omitempty"`
+
+	maxTTL   time.Duration
+	selector labels.Selector
+}
+
+// SigningPolicy restricts the certs issued to the workloads of each namespace, so that the relaxed
+// settings of some namespaces do not apply to the others. The namespace of a workload is the one of
+// its SPIFFE identity, and the first rule applying to the namespace is enforced. Workloads without a
+// SPIFFE identity or whose namespace matches no rule are not restricted.
+type SigningPolicy struct {
+	Rules []SigningPolicyRule `json:"rules"`
+
+	namespaces *namespaceLabels
+}
+
+// NewSigningPolicy returns a SigningPolicy with the given rules, or an error if a rule is invalid.
+// client watches the labels of the namespaces, and is only required by rules with a namespace selector.
+func NewSigningPolicy(rules []SigningPolicyRule, client kubernetes.Interface) (*SigningPolicy, error) {
+	policy := &SigningPolicy{Rules: rules}
+	for i := range policy.Rules {
+		r := &policy.Rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i)
+		}
+		if len(r.Namespaces) == 0 && len(r.NamespaceSelector) == 0 {
+			return nil, fmt.Errorf("rule %s selects no namespace", r.Name)
+		}
+		if len(r.NamespaceSelector) > 0 {
+			if client == nil {
+				return nil, fmt.Errorf("rule %s has a namespace selector, which requires a Kubernetes client", r.Name)
+			}
+			r.selector = labels.SelectorFromSet(r.NamespaceSelector)
+			if policy.namespaces == nil {
+				policy.namespaces = newNamespaceLabels(client)
+			}
+		}
+		if r.MaxTTL != "" {
+			ttl, err := time.ParseDuration(r.MaxTTL)
+			if err != nil || ttl <= 0 {
+				return nil, fmt.Errorf("rule %s has an invalid max TTL %q", r.Name, r.MaxTTL)
+			}
+			r.maxTTL = ttl
+		}
+		for _, t := range r.AllowedSANTypes {
+			switch t {
+			case SANTypeSPIFFE, SANTypeURI, SANTypeDNS, SANTypeIP:
+			default:
+				return nil, fmt.Errorf("rule %s has an unknown SAN type %q", r.Name, t)
+			}
+		}
+		for _, t := range r.KeyTypes {
+			switch t {
+			case KeyTypeRSA, KeyTypeECDSA, KeyTypeEd25519:
+			default:
+				return nil, fmt.Errorf("rule %s has an unknown key type %q", r.Name, t)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// LoadSigningPolicyFile reads a SigningPolicy from a YAML or JSON file, e.g.
+//
+//	rules:
+//	- name: prod
+//	  namespaceSelector:
+//	    env: prod
+//	  maxTTL: 24h
+//	  allowedSANTypes: [spiffe]
+//	  keyTypes: [ECDSA]
+func LoadSigningPolicyFile(path string, client kubernetes.Interface) (*SigningPolicy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing policy file %s (%v)", path, err)
+	}
+	policy := &SigningPolicy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse signing policy file %s (%v)", path, err)
+	}
+	return NewSigningPolicy(policy.Rules, client)
+}
+
+// Run watches the labels of the namespaces, if a rule selects namespaces by labels, until stop is
+// closed.
+func (p *SigningPolicy) Run(stop <-chan struct{}) {
+	if p.namespaces != nil {
+		p.namespaces.run(stop)
+	}
+}
+
+// rule returns the rule applying to namespace, or nil if there is none.
+func (p *SigningPolicy) rule(namespace string) (*SigningPolicyRule, error) {
+	var nsLabels labels.Set
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		for _, ns := range r.Namespaces {
+			if ns == namespace {
+				return r, nil
+			}
+		}
+		if r.selector == nil {
+			continue
+		}
+		if nsLabels == nil {
+			var err error
+			if nsLabels, err = p.namespaces.get(namespace); err != nil {
+				return nil, err
+			}
+			if nsLabels == nil {
+				nsLabels = labels.Set{}
+			}
+		}
+		if r.selector.Matches(nsLabels) {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// Check returns the lifetime of the cert with sans requested by csrPEM for ttl, capped by the rule
+// applying to the namespace of the workload, or an error if the rule denies the cert.
+func (p *SigningPolicy) Check(sans []string, csrPEM []byte, ttl time.Duration) (time.Duration, error) {
+	namespace := identityNamespace(sans)
+	if namespace == "" {
+		return ttl, nil
+	}
+	r, err := p.rule(namespace)
+	if err != nil {
+		return 0, caerror.NewError(caerror.CANotReady, err)
+	}
+	if r == nil {
+		return ttl, nil
+	}
+	if len(r.AllowedSANTypes) > 0 {
+		for _, san := range sans {
+			if t := sanType(san); !containsString(r.AllowedSANTypes, t) {
+				return 0, caerror.NewError(caerror.AuthorizationError, fmt.Errorf(
+					"signing policy %s of namespace %s does not allow %s SAN %s", r.Name, namespace, t, san))
+			}
+		}
+	}
+	if len(r.KeyTypes) > 0 || r.MinRSAKeySize > 0 {
+		csr, err := util.ParsePemEncodedCSR(csrPEM)
+		if err != nil {
+			return 0, caerror.NewError(caerror.CSRError, err)
+		}
+		keyType, keySize := csrKeyType(csr.PublicKey)
+		if len(r.KeyTypes) > 0 && !containsString(r.KeyTypes, keyType) {
+			return 0, caerror.NewError(caerror.WeakKeyError, fmt.Errorf(
+				"signing policy %s of namespace %s does not allow %s keys", r.Name, namespace, keyType))
+		}
+		if keyType == KeyTypeRSA && keySize < r.MinRSAKeySize {
+			return 0, caerror.NewError(caerror.WeakKeyError, fmt.Errorf(
+				"signing policy %s of namespace %s requires RSA keys of at least %d bits, got %d",
+				r.Name, namespace, r.MinRSAKeySize, keySize))
+		}
+	}
+	// The default lifetime of the CA may exceed the cap, hence a cap is also set when none is requested.
+	if r.maxTTL > 0 && (ttl <= 0 || ttl > r.maxTTL) {
+		ttl = r.maxTTL
+	}
+	return ttl, nil
+}
+
+// sanType returns the type of san.
+func sanType(san string) string {
+	if net.ParseIP(san) != nil {
+		return SANTypeIP
+	}
+	if u, err := url.Parse(san); err == nil && u.Scheme != "" {
+		if u.Scheme == "spiffe" {
+			return SANTypeSPIFFE
+		}
+		return SANTypeURI
+	}
+	return SANTypeDNS
+}
+
+// csrKeyType returns the type of key, and its size in bits for RSA keys.
+func csrKeyType(key interface{}) (string, int) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return KeyTypeRSA, k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return KeyTypeECDSA, k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return KeyTypeEd25519, 0
+	}
+	return fmt.Sprintf("%T", key), 0
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSigningPolicy returns the lifetime of the cert requested by caller, capped by the signing
+// policy of the server, or a gRPC error if the policy denies the request.
+func (s *Server) checkSigningPolicy(ctx context.Context, caller *authenticate.Caller,
+	request *pb.IstioCertificateRequest) (time.Duration, error) {
+	ttl := time.Duration(request.ValidityDuration) * time.Second
+	if s.signingPolicy == nil {
+		return ttl, nil
+	}
+	ttl, err := s.signingPolicy.Check(caller.Identities, []byte(request.Csr), ttl)
+	if err != nil {
+		caErr := err.(*caerror.Error)
+		if !caErr.IsRetryable() {
+			s.monitoring.AuthzError.Increment()
+		}
+		auditCSR(ctx, caller, audit.Deny, fmt.Sprintf("denied by the signing policy: %v", err))
+		return 0, status.Errorf(caErr.HTTPErrorCode(), "denied by the signing policy (%v)", err)
+	}
+	return ttl, nil
+}
@@ -0,0 +1,183 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8scache "k8s.io/client-go/tools/cache"
+
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+	pb "istio.io/istio/security/proto"
+)
+
+func TestSigningPolicyCheck(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{"env": "prod"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sandbox"}},
+	)
+	policy, err := NewSigningPolicy([]SigningPolicyRule{
+		{Name: "dev", Namespaces: []string{"dev"}},
+		{
+			Name:              "prod",
+			NamespaceSelector: map[string]string{"env": "prod"},
+			MaxTTL:            "24h",
+			AllowedSANTypes:   []string{SANTypeSPIFFE},
+			KeyTypes:          []string{KeyTypeECDSA},
+		},
+		{Name: "staging", Namespaces: []string{"staging"}, MinRSAKeySize: 2048, MaxTTL: "1h"},
+	}, client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go policy.Run(stop)
+	if !k8scache.WaitForCacheSync(stop, policy.namespaces.informer.HasSynced) {
+		t.Fatal("the namespaces are not synced")
+	}
+	ecCSR, _, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/payments/sa/foo",
+		ECSigAlg: util.EcdsaSigAlg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name    string
+		sans    []string
+		csr     string
+		ttl     time.Duration
+		wantTTL time.Duration
+		wantErr string
+	}{
+		{
+			name:    "no restriction",
+			sans:    []string{"spiffe://cluster.local/ns/dev/sa/foo", "foo.dev.svc"},
+			csr:     csr,
+			ttl:     48 * time.Hour,
+			wantTTL: 48 * time.Hour,
+		},
+		{
+			name:    "no rule",
+			sans:    []string{"spiffe://cluster.local/ns/sandbox/sa/foo"},
+			csr:     csr,
+			ttl:     48 * time.Hour,
+			wantTTL: 48 * time.Hour,
+		},
+		{
+			name:    "no SPIFFE identity",
+			sans:    []string{"istiod.istio-system.svc"},
+			csr:     csr,
+			wantTTL: 0,
+		},
+		{
+			name:    "TTL capped",
+			sans:    []string{"spiffe://cluster.local/ns/payments/sa/foo"},
+			csr:     string(ecCSR),
+			ttl:     48 * time.Hour,
+			wantTTL: 24 * time.Hour,
+		},
+		{
+			name:    "default TTL capped",
+			sans:    []string{"spiffe://cluster.local/ns/payments/sa/foo"},
+			csr:     string(ecCSR),
+			wantTTL: 24 * time.Hour,
+		},
+		{
+			name:    "DNS SAN denied",
+			sans:    []string{"spiffe://cluster.local/ns/payments/sa/foo", "foo.payments.svc"},
+			csr:     string(ecCSR),
+			wantErr: "does not allow dns SAN foo.payments.svc",
+		},
+		{
+			name:    "key type denied",
+			sans:    []string{"spiffe://cluster.local/ns/payments/sa/foo"},
+			csr:     csr,
+			wantErr: "does not allow RSA keys",
+		},
+		{
+			name:    "RSA key too small",
+			sans:    []string{"spiffe://cluster.local/ns/staging/sa/foo"},
+			csr:     csr,
+			wantErr: "at least 2048 bits, got 1024",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ttl, err := policy.Check(c.sans, []byte(c.csr), c.ttl)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("got error %v, want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ttl != c.wantTTL {
+				t.Errorf("got TTL %v, want %v", ttl, c.wantTTL)
+			}
+		})
+	}
+}
+
+func TestNewSigningPolicyErrors(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	for _, c := range []struct {
+		rule   SigningPolicyRule
+		client kubernetes.Interface
+	}{
+		{rule: SigningPolicyRule{Name: "none"}, client: client},
+		{rule: SigningPolicyRule{NamespaceSelector: map[string]string{"env": "prod"}}},
+		{rule: SigningPolicyRule{Namespaces: []string{"a"}, MaxTTL: "1 day"}, client: client},
+		{rule: SigningPolicyRule{Namespaces: []string{"a"}, AllowedSANTypes: []string{"email"}}, client: client},
+		{rule: SigningPolicyRule{Namespaces: []string{"a"}, KeyTypes: []string{"DSA"}}, client: client},
+	} {
+		if _, err := NewSigningPolicy([]SigningPolicyRule{c.rule}, c.client); err == nil {
+			t.Errorf("expected an error for rule %+v", c.rule)
+		}
+	}
+}
+
+func TestCreateCertificateSigningPolicy(t *testing.T) {
+	policy, err := NewSigningPolicy([]SigningPolicyRule{
+		{Name: "prod", Namespaces: []string{"prod"}, KeyTypes: []string{KeyTypeECDSA}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{
+		ca: &mockca.FakeCA{SignedCert: []byte("cert")},
+		Authenticators: []authenticate.Authenticator{&mockAuthenticator{
+			identities: []string{"spiffe://cluster.local/ns/prod/sa/foo"},
+		}},
+		monitoring: newMonitoringMetrics(),
+	}
+	server.SetSigningPolicy(policy)
+	_, err = server.CreateCertificate(context.Background(), &pb.IstioCertificateRequest{Csr: csr})
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "does not allow RSA keys") {
+		t.Errorf("got error %v, want a denial by the signing policy", err)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	pkica "istio.io/istio/security/pkg/pki/ca"
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// TenantConfig maps the namespaces of a tenant to the issuing CA of the tenant.
+type TenantConfig struct {
+	// Name of the tenant.
+	Name string `json:"name"`
+	// NamespaceSelector selects the namespaces of the tenant by their labels.
+	NamespaceSelector map[string]string `json:"namespaceSelector"`
+	// CertDir is the directory holding the issuing CA of the tenant, in the layout of the
+	// plugged-in CA: ca-cert.pem, ca-key.pem, cert-chain.pem and root-cert.pem.
+	CertDir string `json:"certDir"`
+}
+
+// TenantsConfig is the configuration of the issuing CAs of the tenants.
+type TenantsConfig struct {
+	Tenants []TenantConfig `json:"tenants"`
+}
+
+// LoadTenantsFile reads a TenantsConfig from a YAML or JSON file, e.g.
+//
+//	tenants:
+//	- name: payments
+//	  namespaceSelector:
+//	    tenant: payments
+//	  certDir: /etc/cacerts/payments
+func LoadTenantsFile(path string) (*TenantsConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file %s (%v)", path, err)
+	}
+	config := &TenantsConfig{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants file %s (%v)", path, err)
+	}
+	names := map[string]bool{}
+	for i, t := range config.Tenants {
+		switch {
+		case t.Name == "":
+			return nil, fmt.Errorf("tenant %d has no name", i)
+		case names[t.Name]:
+			return nil, fmt.Errorf("tenant %s is defined more than once", t.Name)
+		case len(t.NamespaceSelector) == 0:
+			return nil, fmt.Errorf("tenant %s has no namespace selector", t.Name)
+		case t.CertDir == "":
+			return nil, fmt.Errorf("tenant %s has no cert directory", t.Name)
+		}
+		names[t.Name] = true
+	}
+	return config, nil
+}
+
+// Tenant is a group of namespaces whose workload certs are signed by the issuing CA of the tenant.
+type Tenant struct {
+	Name     string
+	Selector labels.Selector
+	CA       CertificateAuthority
+}
+
+// TenantCA signs the certs of the workloads in the namespaces of a tenant with the issuing CA of the
+// tenant, so that they chain up to the intermediate of the tenant. The certs of the other workloads
+// are signed by the default CA. The namespace of a workload is the one of its SPIFFE identity, and a
+// namespace belongs to the first tenant selecting it.
+type TenantCA struct {
+	defaultCA  CertificateAuthority
+	tenants    []Tenant
+	namespaces *namespaceLabels
+}
+
+var _ CertificateAuthority = &TenantCA{}
+
+// NewTenantCA creates a TenantCA selecting the tenants by the labels of the namespaces, which are
+// watched once Run is called.
+func NewTenantCA(defaultCA CertificateAuthority, tenants []Tenant, client kubernetes.Interface) *TenantCA {
+	return &TenantCA{
+		defaultCA:  defaultCA,
+		tenants:    tenants,
+		namespaces: newNamespaceLabels(client),
+	}
+}
+
+// Run watches the namespaces until stop is closed.
+func (t *TenantCA) Run(stop <-chan struct{}) {
+	t.namespaces.run(stop)
+}
+
+// selectCA returns the CA signing the certs of subjectIDs.
+func (t *TenantCA) selectCA(subjectIDs []string) (CertificateAuthority, error) {
+	namespace := identityNamespace(subjectIDs)
+	if namespace == "" {
+		return t.defaultCA, nil
+	}
+	nsLabels, err := t.namespaces.get(namespace)
+	if err != nil {
+		return nil, caerror.NewError(caerror.CANotReady, err)
+	}
+	for _, tenant := range t.tenants {
+		if nsLabels != nil && tenant.Selector.Matches(nsLabels) {
+			return tenant.CA, nil
+		}
+	}
+	return t.defaultCA, nil
+}
+
+// Sign implements CertificateAuthority.
+func (t *TenantCA) Sign(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error) {
+	ca, err := t.selectCA(subjectIDs)
+	if err != nil {
+		return nil, err
+	}
+	return ca.Sign(csrPEM, subjectIDs, ttl, forCA)
+}
+
+// SignWithCertChain implements CertificateAuthority.
+func (t *TenantCA) SignWithCertChain(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error) {
+	ca, err := t.selectCA(subjectIDs)
+	if err != nil {
+		return nil, err
+	}
+	return ca.SignWithCertChain(csrPEM, subjectIDs, ttl, forCA)
+}
+
+// SignWithResult implements CertificateAuthority. The result holds the chain and root certs of the
+// CA of the tenant.
+func (t *TenantCA) SignWithResult(csrPEM []byte, subjectIDs []string, ttl time.Duration,
+	forCA bool) (*pkica.SignResult, error) {
+	ca, err := t.selectCA(subjectIDs)
+	if err != nil {
+		return nil, err
+	}
+	return ca.SignWithResult(csrPEM, subjectIDs, ttl, forCA)
+}
+
+// GetCAKeyCertBundle returns the KeyCertBundle of the default CA.
+func (t *TenantCA) GetCAKeyCertBundle() util.KeyCertBundle {
+	return t.defaultCA.GetCAKeyCertBundle()
+}
+
+// AuthorizeSANs implements SANAuthorizer with the authorizer of the default CA, if any.
+func (t *TenantCA) AuthorizeSANs(requester string, sans []string) error {
+	if authorizer, ok := t.defaultCA.(SANAuthorizer); ok {
+		return authorizer.AuthorizeSANs(requester, sans)
+	}
+	return nil
+}
+
+// Healthy implements HealthChecker. It returns an error if the default CA or the CA of a tenant is
+// not healthy.
+func (t *TenantCA) Healthy() error {
+	if checker, ok := t.defaultCA.(HealthChecker); ok {
+		if err := checker.Healthy(); err != nil {
+			return err
+		}
+	}
+	for _, tenant := range t.tenants {
+		if checker, ok := tenant.CA.(HealthChecker); ok {
+			if err := checker.Healthy(); err != nil {
+				return fmt.Errorf("CA of tenant %s: %v", tenant.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// RootCerts returns the PEM-encoded root certs of the tenants that differ from the root certs of
+// the default CA, so that the workloads of all tenants can trust each other.
+func (t *TenantCA) RootCerts() []byte {
+	defaultRootCerts := t.defaultCA.GetCAKeyCertBundle().GetRootCertPem()
+	var rootCerts []byte
+	for _, tenant := range t.tenants {
+		tenantRootCerts := tenant.CA.GetCAKeyCertBundle().GetRootCertPem()
+		if bytes.Equal(tenantRootCerts, defaultRootCerts) || bytes.Contains(rootCerts, tenantRootCerts) {
+			continue
+		}
+		if len(rootCerts) > 0 {
+			rootCerts = append(rootCerts, '\n')
+		}
+		rootCerts = append(rootCerts, tenantRootCerts...)
+	}
+	return rootCerts
+}
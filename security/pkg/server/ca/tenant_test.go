@@ -0,0 +1,150 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+	k8scache "k8s.io/client-go/tools/cache"
+
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	"istio.io/istio/security/pkg/pki/util/mock"
+)
+
+func fakeTenantCA(cert, root string) *mockca.FakeCA {
+	return &mockca.FakeCA{
+		SignedCert:    []byte(cert),
+		KeyCertBundle: &mock.FakeKeyCertBundle{CertChainBytes: []byte(cert + "-chain"), RootCertBytes: []byte(root)},
+	}
+}
+
+func TestTenantCA(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{"tenant": "payments"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "search", Labels: map[string]string{"tenant": "search"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+	)
+	defaultCA := fakeTenantCA("default", "root")
+	paymentsCA := fakeTenantCA("payments", "payments-root")
+	searchCA := fakeTenantCA("search", "root")
+	stop := make(chan struct{})
+	defer close(stop)
+	tenantCA := NewTenantCA(defaultCA, []Tenant{
+		{Name: "payments", Selector: labels.SelectorFromSet(labels.Set{"tenant": "payments"}), CA: paymentsCA},
+		{Name: "search", Selector: labels.SelectorFromSet(labels.Set{"tenant": "search"}), CA: searchCA},
+	}, client)
+	go tenantCA.Run(stop)
+	if !k8scache.WaitForCacheSync(stop, tenantCA.namespaces.informer.HasSynced) {
+		t.Fatal("the namespaces are not synced")
+	}
+
+	for _, c := range []struct {
+		ids       []string
+		wantChain string
+		wantRoot  string
+	}{
+		{[]string{"spiffe://cluster.local/ns/payments/sa/foo"}, "payments-chain", "payments-root"},
+		{[]string{"spiffe://cluster.local/ns/search/sa/foo"}, "search-chain", "root"},
+		{[]string{"spiffe://cluster.local/ns/default/sa/foo"}, "default-chain", "root"},
+		{[]string{"spiffe://cluster.local/ns/unknown/sa/foo"}, "default-chain", "root"},
+		{[]string{"istiod.istio-system.svc"}, "default-chain", "root"},
+	} {
+		result, err := tenantCA.SignWithResult(nil, c.ids, time.Hour, false)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", c.ids, err)
+		}
+		if string(result.CertChain) != c.wantChain || string(result.RootCerts) != c.wantRoot {
+			t.Errorf("%v: got chain %q and root %q, want %q and %q", c.ids, result.CertChain, result.RootCerts,
+				c.wantChain, c.wantRoot)
+		}
+	}
+
+	if got := string(tenantCA.RootCerts()); got != "payments-root" {
+		t.Errorf("got tenant root certs %q, want payments-root", got)
+	}
+	if got := string(tenantCA.GetCAKeyCertBundle().GetRootCertPem()); got != "root" {
+		t.Errorf("got root certs %q, want those of the default CA", got)
+	}
+}
+
+func TestLoadTenantsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tenants")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, c := range []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `
+tenants:
+- name: payments
+  namespaceSelector:
+    tenant: payments
+  certDir: /etc/cacerts/payments
+`,
+		},
+		{
+			name:    "unknown field",
+			content: "tenants:\n- name: payments\n  selector: {}\n",
+			wantErr: "failed to parse",
+		},
+		{
+			name:    "no selector",
+			content: "tenants:\n- name: payments\n  certDir: /etc/cacerts/payments\n",
+			wantErr: "no namespace selector",
+		},
+		{
+			name: "duplicate",
+			content: "tenants:\n- name: a\n  namespaceSelector: {t: a}\n  certDir: /a\n" +
+				"- name: a\n  namespaceSelector: {t: b}\n  certDir: /b\n",
+			wantErr: "more than once",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(dir, c.name+".yaml")
+			if err := ioutil.WriteFile(path, []byte(c.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			config, err := LoadTenantsFile(path)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("got error %v, want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(config.Tenants) != 1 || config.Tenants[0].CertDir != "/etc/cacerts/payments" {
+				t.Errorf("unexpected config %+v", config)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"go.opencensus.io/trace"
+
+	"istio.io/istio/pkg/spiffe"
+)
+
+// identityAttributes returns the span attributes of the first caller identity. The namespace and
+// service account are extracted from identities of the form spiffe://<trust domain>/ns/<ns>/sa/<sa>.
+func identityAttributes(identities []string) []trace.Attribute {
+	if len(identities) == 0 {
+		return nil
+	}
+	attrs := []trace.Attribute{trace.StringAttribute("identity", identities[0])}
+	if id, err := spiffe.ParseIdentity(identities[0]); err == nil {
+		attrs = append(attrs,
+			trace.StringAttribute("namespace", id.Namespace),
+			trace.StringAttribute("service_account", id.ServiceAccount))
+	}
+	return attrs
+}
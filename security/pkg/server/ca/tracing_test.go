@@ -0,0 +1,52 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+func TestIdentityAttributes(t *testing.T) {
+	testCases := map[string]struct {
+		identities []string
+		expected   []trace.Attribute
+	}{
+		"no identity": {},
+		"workload identity": {
+			identities: []string{"spiffe://cluster.local/ns/default/sa/bookinfo", "spiffe://other/ns/a/sa/b"},
+			expected: []trace.Attribute{
+				trace.StringAttribute("identity", "spiffe://cluster.local/ns/default/sa/bookinfo"),
+				trace.StringAttribute("namespace", "default"),
+				trace.StringAttribute("service_account", "bookinfo"),
+			},
+		},
+		"custom identity": {
+			identities: []string{"spiffe://cluster.local/custom"},
+			expected:   []trace.Attribute{trace.StringAttribute("identity", "spiffe://cluster.local/custom")},
+		},
+		"non-SPIFFE identity": {
+			identities: []string{"vm-1.example.com"},
+			expected:   []trace.Attribute{trace.StringAttribute("identity", "vm-1.example.com")},
+		},
+	}
+	for id, tc := range testCases {
+		if attrs := identityAttributes(tc.identities); !reflect.DeepEqual(attrs, tc.expected) {
+			t.Errorf("%s: got attributes %v, want %v", id, attrs, tc.expected)
+		}
+	}
+}
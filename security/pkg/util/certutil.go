@@ -31,18 +31,25 @@ type CertUtil interface {
 
 // CertUtilImpl is the implementation of CertUtil, for production use.
 type CertUtilImpl struct {
-	gracePeriodPercentage int
+	strategy RenewalStrategy
 }
 
 // NewCertUtil returns a new CertUtilImpl
 func NewCertUtil(gracePeriodPercentage int) CertUtilImpl {
 	return CertUtilImpl{
-		gracePeriodPercentage: gracePeriodPercentage,
+		strategy: PercentageRemaining(gracePeriodPercentage),
+	}
+}
+
+// NewCertUtilWithStrategy returns a new CertUtilImpl that renews certificates as decided by strategy.
+func NewCertUtilWithStrategy(strategy RenewalStrategy) CertUtilImpl {
+	return CertUtilImpl{
+		strategy: strategy,
 	}
 }
 
 // GetWaitTime returns the waititng time before renewing the cert, based on current time, the timestamps in cert and
-// graceperiod.
+// the grace period given by the renewal strategy.
 func (cu CertUtilImpl) GetWaitTime(certBytes []byte, now time.Time, minGracePeriod time.Duration) (time.Duration, error) {
 	cert, certErr := util.ParsePemEncodedCertificate(certBytes)
 	if certErr != nil {
@@ -53,12 +60,10 @@ func (cu CertUtilImpl) GetWaitTime(certBytes []byte, now time.Time, minGracePeri
 		return time.Duration(0), fmt.Errorf("certificate already expired at %s, but now is %s",
 			cert.NotAfter, now)
 	}
-	// Note: multiply time.Duration(int64) by an int (gracePeriodPercentage) will cause overflow (e.g.,
-	// when duration is time.Hour * 90000). So float64 is used instead.
-	gracePeriod := time.Duration(float64(cert.NotAfter.Sub(cert.NotBefore)) * (float64(cu.gracePeriodPercentage) / 100))
+	gracePeriod := cu.strategy.GracePeriod(cert.NotBefore, cert.NotAfter)
 	if gracePeriod < minGracePeriod {
-		log.Warnf("gracePeriod (%v * %f) = %v is less than minGracePeriod %v. Apply minGracePeriod.",
-			cert.NotAfter.Sub(cert.NotBefore), float64(cu.gracePeriodPercentage/100), gracePeriod, minGracePeriod)
+		log.Warnf("gracePeriod %v of the certificate valid for %v is less than minGracePeriod %v. Apply minGracePeriod.",
+			gracePeriod, cert.NotAfter.Sub(cert.NotBefore), minGracePeriod)
 		gracePeriod = minGracePeriod
 	}
 
@@ -31,15 +31,15 @@ func IsJwtExpired(token string, now time.Time) (bool, error) {
 	}
 
 	var expiration time.Time
-	if claims["iat"] == nil {
-		// The JWT doesn't have "iat", so it's always valid. E.g., the K8s first party JWT.
+	if claims["exp"] == nil {
+		// The JWT doesn't have "exp", so it's always valid. E.g., the K8s first party JWT.
 		return false, nil
 	}
-	switch iat := claims["iat"].(type) {
+	switch exp := claims["exp"].(type) {
 	case float64:
-		expiration = time.Unix(int64(iat), 0)
+		expiration = time.Unix(int64(exp), 0)
 	case json.Number:
-		v, _ := iat.Int64()
+		v, _ := exp.Int64()
 		expiration = time.Unix(v, 0)
 	}
 	if now.After(expiration) {
@@ -49,6 +49,22 @@ func IsJwtExpired(token string, now time.Time) (bool, error) {
 
 }
 
+// GetServiceAccountFromJwt returns the namespace and name of the Kubernetes service account in the
+// "sub" claim of the JWT, without validating it.
+func GetServiceAccountFromJwt(token string) (namespace, serviceAccount string, err error) {
+	claims, err := parseJwtClaims(token)
+	if err != nil {
+		return "", "", err
+	}
+	sub, _ := claims["sub"].(string)
+	// The subject of a service account token is system:serviceaccount:{namespace}:{name}.
+	parts := strings.Split(sub, ":")
+	if len(parts) != 4 || parts[0] != "system" || parts[1] != "serviceaccount" {
+		return "", "", fmt.Errorf("the JWT subject %q is not a Kubernetes service account", sub)
+	}
+	return parts[2], parts[3], nil
+}
+
 func parseJwtClaims(token string) (map[string]interface{}, error) {
 	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
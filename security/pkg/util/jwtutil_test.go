@@ -63,6 +63,12 @@ func TestIsJwtExpired(t *testing.T) {
 			expResult: false,
 			expErr:    nil,
 		},
+		"JWT within its lifetime": {
+			jwt:       thirdPartyJwt,
+			now:       time.Unix(1586063634+3600, 0),
+			expResult: false,
+			expErr:    nil,
+		},
 		"Expired JWT": {
 			jwt:       thirdPartyJwt,
 			now:       time.Now(),
@@ -98,3 +104,44 @@ func TestIsJwtExpired(t *testing.T) {
 		}
 	}
 }
+
+func TestGetServiceAccountFromJwt(t *testing.T) {
+	testCases := map[string]struct {
+		jwt       string
+		namespace string
+		sa        string
+		expectErr bool
+	}{
+		"Third party JWT": {
+			jwt:       thirdPartyJwt,
+			namespace: "foo",
+			sa:        "httpbin",
+		},
+		"First party JWT": {
+			jwt:       firstPartyJwt,
+			namespace: "foo",
+			sa:        "httpbin",
+		},
+		"Invalid JWT": {
+			jwt:       "invalid-jwt",
+			expectErr: true,
+		},
+	}
+
+	for id, tc := range testCases {
+		namespace, sa, err := GetServiceAccountFromJwt(tc.jwt)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got none", id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", id, err)
+			continue
+		}
+		if namespace != tc.namespace || sa != tc.sa {
+			t.Errorf("%s: got %s/%s, want %s/%s", id, namespace, sa, tc.namespace, tc.sa)
+		}
+	}
+}
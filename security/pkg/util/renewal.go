@@ -0,0 +1,91 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RenewalStrategy decides how long before its expiration a certificate is renewed.
+type RenewalStrategy interface {
+	// GracePeriod returns the length of the period before notAfter in which the certificate
+	// valid from notBefore to notAfter should be renewed.
+	GracePeriod(notBefore, notAfter time.Time) time.Duration
+}
+
+// PercentageRemaining renews a certificate when the given percentage of its lifetime remains.
+// This is the strategy of NewCertUtil.
+type PercentageRemaining int
+
+// GracePeriod implements RenewalStrategy.
+func (p PercentageRemaining) GracePeriod(notBefore, notAfter time.Time) time.Duration {
+	// Note: multiply time.Duration(int64) by an int (the percentage) will cause overflow (e.g.,
+	// when duration is time.Hour * 90000). So float64 is used instead.
+	return time.Duration(float64(notAfter.Sub(notBefore)) * (float64(p) / 100))
+}
+
+// RenewBefore renews a certificate the given duration before its expiration, regardless of its TTL.
+type RenewBefore time.Duration
+
+// GracePeriod implements RenewalStrategy.
+func (r RenewBefore) GracePeriod(_, _ time.Time) time.Duration {
+	return time.Duration(r)
+}
+
+// FixedSchedule renews a certificate the given duration after it was issued, regardless of its TTL.
+// A certificate whose lifetime is shorter than the duration is renewed right away.
+type FixedSchedule time.Duration
+
+// GracePeriod implements RenewalStrategy.
+func (f FixedSchedule) GracePeriod(notBefore, notAfter time.Time) time.Duration {
+	gracePeriod := notAfter.Sub(notBefore) - time.Duration(f)
+	if gracePeriod < 0 {
+		return notAfter.Sub(notBefore)
+	}
+	return gracePeriod
+}
+
+// ParseRenewalStrategy parses a renewal strategy of the form "<kind>:<value>", where kind is one of
+// "remaining" (a percentage of the lifetime, e.g. "remaining:50"), "before" (a duration before the
+// expiration, e.g. "before:24h") or "after" (a duration after the issuance, e.g. "after:12h").
+func ParseRenewalStrategy(s string) (RenewalStrategy, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid renewal strategy %q, expected <kind>:<value>", s)
+	}
+	kind, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	switch kind {
+	case "remaining":
+		p, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+		if err != nil || p < 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentage %q in renewal strategy %q", value, s)
+		}
+		return PercentageRemaining(p), nil
+	case "before", "after":
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid duration %q in renewal strategy %q", value, s)
+		}
+		if kind == "before" {
+			return RenewBefore(d), nil
+		}
+		return FixedSchedule(d), nil
+	default:
+		return nil, fmt.Errorf("unknown renewal strategy kind %q in %q", kind, s)
+	}
+}
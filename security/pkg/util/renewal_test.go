@@ -0,0 +1,108 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestRenewalStrategies(t *testing.T) {
+	testCert, err := ioutil.ReadFile(testCertFile)
+	if err != nil {
+		t.Fatalf("cannot read testing cert file")
+	}
+	// The cert is valid from 2017-08-23 19:00:40 to 2017-08-24 19:00:40 UTC.
+	now := time.Date(2017, time.August, 23, 21, 0, 40, 0, time.UTC)
+	testCases := map[string]struct {
+		strategy         RenewalStrategy
+		expectedWaitTime time.Duration
+		expectedErr      bool
+	}{
+		"percentage remaining": {
+			strategy:         PercentageRemaining(50),
+			expectedWaitTime: 10 * time.Hour,
+		},
+		"renew before": {
+			strategy:         RenewBefore(4 * time.Hour),
+			expectedWaitTime: 18 * time.Hour,
+		},
+		"renew before longer than the remaining lifetime": {
+			strategy:    RenewBefore(48 * time.Hour),
+			expectedErr: true,
+		},
+		"fixed schedule": {
+			strategy:         FixedSchedule(6 * time.Hour),
+			expectedWaitTime: 4 * time.Hour,
+		},
+		"fixed schedule longer than the lifetime": {
+			strategy:    FixedSchedule(48 * time.Hour),
+			expectedErr: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			waitTime, err := NewCertUtilWithStrategy(tc.strategy).GetWaitTime(testCert, now, 0)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got wait time %v", waitTime)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if waitTime != tc.expectedWaitTime {
+				t.Errorf("expected wait time %v, got %v", tc.expectedWaitTime, waitTime)
+			}
+		})
+	}
+}
+
+func TestParseRenewalStrategy(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected RenewalStrategy
+		err      bool
+	}{
+		{in: "remaining:50", expected: PercentageRemaining(50)},
+		{in: "remaining:20%", expected: PercentageRemaining(20)},
+		{in: "before:24h", expected: RenewBefore(24 * time.Hour)},
+		{in: " after : 12h", expected: FixedSchedule(12 * time.Hour)},
+		{in: "remaining:150", err: true},
+		{in: "before:-1h", err: true},
+		{in: "after:soon", err: true},
+		{in: "never:1h", err: true},
+		{in: "24h", err: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseRenewalStrategy(tc.in)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,1577 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: security/proto/caplugin/v1/caplugin.proto
+
+// The CA plugin protocol lets an out-of-process CA sign the workload certificates
+// of Istio. The plugin should also implement the grpc.health.v1.Health service,
+// which Istio uses to check that the plugin is ready.
+
+package v1
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	io "io"
+	math "math"
+	math_bits "math/bits"
+	reflect "reflect"
+	strings "strings"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+// Request to sign a certificate signing request.
+type SignRequest struct {
+	// PEM-encoded certificate signing request.
+	Csr string `protobuf:"bytes,1,opt,name=csr,proto3" json:"csr,omitempty"`
+	// Authenticated identities of the requester, e.g. SPIFFE IDs. The plugin must
+	// only issue these identities as SANs.
+	SubjectIds []string `protobuf:"bytes,2,rep,name=subject_ids,json=subjectIds,proto3" json:"subject_ids,omitempty"`
+	// Requested validity period, in seconds. 0 requests the default validity period
+	// of the plugin.
+	TtlSeconds int64 `protobuf:"varint,3,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"`
+	// Whether the certificate is a CA certificate.
+	ForCa bool `protobuf:"varint,4,opt,name=for_ca,json=forCa,proto3" json:"for_ca,omitempty"`
+}
+
+func (m *SignRequest) Reset()      { *m = SignRequest{} }
+func (*SignRequest) ProtoMessage() {}
+func (*SignRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_282ad7f18ada9a02, []int{0}
+}
+func (m *SignRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SignRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SignRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *SignRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SignRequest.Merge(m, src)
+}
+func (m *SignRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *SignRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SignRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SignRequest proto.InternalMessageInfo
+
+func (m *SignRequest) GetCsr() string {
+	if m != nil {
+		return m.Csr
+	}
+	return ""
+}
+
+func (m *SignRequest) GetSubjectIds() []string {
+	if m != nil {
+		return m.SubjectIds
+	}
+	return nil
+}
+
+func (m *SignRequest) GetTtlSeconds() int64 {
+	if m != nil {
+		return m.TtlSeconds
+	}
+	return 0
+}
+
+func (m *SignRequest) GetForCa() bool {
+	if m != nil {
+		return m.ForCa
+	}
+	return false
+}
+
+// Signed certificate.
+type SignResponse struct {
+	// PEM-encoded leaf certificate.
+	Leaf string `protobuf:"bytes,1,opt,name=leaf,proto3" json:"leaf,omitempty"`
+	// PEM-encoded intermediate certificates, from the issuer of the leaf towards
+	// the root.
+	Intermediates []string `protobuf:"bytes,2,rep,name=intermediates,proto3" json:"intermediates,omitempty"`
+	// PEM-encoded root certificates.
+	Roots []string `protobuf:"bytes,3,rep,name=roots,proto3" json:"roots,omitempty"`
+}
+
+func (m *SignResponse) Reset()      { *m = SignResponse{} }
+func (*SignResponse) ProtoMessage() {}
+func (*SignResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_282ad7f18ada9a02, []int{1}
+}
+func (m *SignResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SignResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SignResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *SignResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SignResponse.Merge(m, src)
+}
+func (m *SignResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *SignResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SignResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SignResponse proto.InternalMessageInfo
+
+func (m *SignResponse) GetLeaf() string {
+	if m != nil {
+		return m.Leaf
+	}
+	return ""
+}
+
+func (m *SignResponse) GetIntermediates() []string {
+	if m != nil {
+		return m.Intermediates
+	}
+	return nil
+}
+
+func (m *SignResponse) GetRoots() []string {
+	if m != nil {
+		return m.Roots
+	}
+	return nil
+}
+
+// Request for the certificates of the CA.
+type GetTrustBundleRequest struct {
+}
+
+func (m *GetTrustBundleRequest) Reset()      { *m = GetTrustBundleRequest{} }
+func (*GetTrustBundleRequest) ProtoMessage() {}
+func (*GetTrustBundleRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_282ad7f18ada9a02, []int{2}
+}
+func (m *GetTrustBundleRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *GetTrustBundleRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_GetTrustBundleRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *GetTrustBundleRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetTrustBundleRequest.Merge(m, src)
+}
+func (m *GetTrustBundleRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *GetTrustBundleRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetTrustBundleRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetTrustBundleRequest proto.InternalMessageInfo
+
+// Certificates of the CA.
+type GetTrustBundleResponse struct {
+	// PEM-encoded certificate of the CA signing the certificates.
+	CaCert string `protobuf:"bytes,1,opt,name=ca_cert,json=caCert,proto3" json:"ca_cert,omitempty"`
+	// PEM-encoded intermediate certificates, from the signing CA towards the root.
+	// The first element is the signing CA certificate, unless it is a root.
+	Intermediates []string `protobuf:"bytes,2,rep,name=intermediates,proto3" json:"intermediates,omitempty"`
+	// PEM-encoded root certificates.
+	Roots []string `protobuf:"bytes,3,rep,name=roots,proto3" json:"roots,omitempty"`
+}
+
+func (m *GetTrustBundleResponse) Reset()      { *m = GetTrustBundleResponse{} }
+func (*GetTrustBundleResponse) ProtoMessage() {}
+func (*GetTrustBundleResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_282ad7f18ada9a02, []int{3}
+}
+func (m *GetTrustBundleResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *GetTrustBundleResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_GetTrustBundleResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *GetTrustBundleResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetTrustBundleResponse.Merge(m, src)
+}
+func (m *GetTrustBundleResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *GetTrustBundleResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetTrustBundleResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetTrustBundleResponse proto.InternalMessageInfo
+
+func (m *GetTrustBundleResponse) GetCaCert() string {
+	if m != nil {
+		return m.CaCert
+	}
+	return ""
+}
+
+func (m *GetTrustBundleResponse) GetIntermediates() []string {
+	if m != nil {
+		return m.Intermediates
+	}
+	return nil
+}
+
+func (m *GetTrustBundleResponse) GetRoots() []string {
+	if m != nil {
+		return m.Roots
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*SignRequest)(nil), "istio.security.caplugin.v1.SignRequest")
+	proto.RegisterType((*SignResponse)(nil), "istio.security.caplugin.v1.SignResponse")
+	proto.RegisterType((*GetTrustBundleRequest)(nil), "istio.security.caplugin.v1.GetTrustBundleRequest")
+	proto.RegisterType((*GetTrustBundleResponse)(nil), "istio.security.caplugin.v1.GetTrustBundleResponse")
+}
+
+func init() {
+	proto.RegisterFile("security/proto/caplugin/v1/caplugin.proto", fileDescriptor_282ad7f18ada9a02)
+}
+
+var fileDescriptor_282ad7f18ada9a02 = []byte{
+	// 395 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x52, 0x3d, 0x8f, 0xd3, 0x40,
+	0x10, 0xf5, 0x9e, 0x73, 0xe1, 0x6e, 0x0e, 0x10, 0x5a, 0x71, 0x9c, 0x95, 0x62, 0xb1, 0x2c, 0x24,
+	0x4c, 0xe3, 0xc8, 0x47, 0x43, 0xcb, 0xa5, 0x40, 0x74, 0xc8, 0x47, 0x05, 0x12, 0x96, 0x6f, 0xbd,
+	0x39, 0x2d, 0x72, 0xbc, 0x61, 0x77, 0x1c, 0x91, 0x8e, 0x9f, 0xc0, 0xcf, 0xe0, 0xa7, 0x50, 0xa6,
+	0x4c, 0x49, 0x36, 0x0d, 0x65, 0x7e, 0x02, 0xf2, 0x47, 0x22, 0x82, 0xf8, 0x10, 0xba, 0x6e, 0x76,
+	0xde, 0x3c, 0xbd, 0xf7, 0x66, 0x16, 0x9e, 0x18, 0xc1, 0x2b, 0x2d, 0x71, 0x3e, 0x9c, 0x6a, 0x85,
+	0x6a, 0xc8, 0xb3, 0x69, 0x51, 0x5d, 0xcb, 0x72, 0x38, 0x8b, 0x77, 0x75, 0xd4, 0x40, 0x74, 0x20,
+	0x0d, 0x4a, 0x15, 0x6d, 0x09, 0xd1, 0x0e, 0x9e, 0xc5, 0xc1, 0x47, 0x38, 0xb9, 0x94, 0xd7, 0x65,
+	0x22, 0x3e, 0x54, 0xc2, 0x20, 0xbd, 0x07, 0x2e, 0x37, 0xda, 0x23, 0x3e, 0x09, 0x8f, 0x93, 0xba,
+	0xa4, 0x0f, 0xe1, 0xc4, 0x54, 0x57, 0xef, 0x05, 0xc7, 0x54, 0xe6, 0xc6, 0x3b, 0xf0, 0xdd, 0xf0,
+	0x38, 0x81, 0xae, 0xf5, 0x32, 0x37, 0xf5, 0x00, 0x62, 0x91, 0x1a, 0xc1, 0x55, 0x99, 0x1b, 0xcf,
+	0xf5, 0x49, 0xe8, 0x26, 0x80, 0x58, 0x5c, 0xb6, 0x1d, 0x7a, 0x0a, 0xfd, 0xb1, 0xd2, 0x29, 0xcf,
+	0xbc, 0x9e, 0x4f, 0xc2, 0xa3, 0xe4, 0x70, 0xac, 0xf4, 0x28, 0x0b, 0xde, 0xc1, 0xed, 0x56, 0xd9,
+	0x4c, 0x55, 0x69, 0x04, 0xa5, 0xd0, 0x2b, 0x44, 0x36, 0xee, 0xb4, 0x9b, 0x9a, 0x3e, 0x82, 0x3b,
+	0xb2, 0x44, 0xa1, 0x27, 0x22, 0x97, 0x19, 0x8a, 0xad, 0xfc, 0x7e, 0x93, 0xde, 0x87, 0x43, 0xad,
+	0x14, 0xd6, 0xda, 0x35, 0xda, 0x3e, 0x82, 0x33, 0x38, 0x7d, 0x21, 0xf0, 0xb5, 0xae, 0x0c, 0x5e,
+	0x54, 0x65, 0x5e, 0x88, 0x2e, 0x63, 0x30, 0x81, 0x07, 0xbf, 0x02, 0x9d, 0x85, 0x33, 0xb8, 0xc5,
+	0xb3, 0x94, 0x0b, 0x8d, 0x9d, 0x8b, 0x3e, 0xcf, 0x46, 0x42, 0xe3, 0x4d, 0x7c, 0x9c, 0x5b, 0x02,
+	0x47, 0xa3, 0xe7, 0xaf, 0x9a, 0x8d, 0xd3, 0xb7, 0xd0, 0xab, 0x43, 0xd3, 0xc7, 0xd1, 0x9f, 0x6f,
+	0x12, 0xfd, 0x74, 0x90, 0x41, 0xf8, 0xef, 0xc1, 0xd6, 0x7c, 0xe0, 0xd0, 0x39, 0xdc, 0xdd, 0x0f,
+	0x46, 0xe3, 0xbf, 0xb1, 0x7f, 0xbb, 0x9d, 0xc1, 0xf9, 0xff, 0x50, 0xb6, 0xd2, 0x17, 0xcf, 0x16,
+	0x2b, 0xe6, 0x2c, 0x57, 0xcc, 0xd9, 0xac, 0x18, 0xf9, 0x64, 0x19, 0xf9, 0x62, 0x19, 0xf9, 0x6a,
+	0x19, 0x59, 0x58, 0x46, 0xbe, 0x59, 0x46, 0xbe, 0x5b, 0xe6, 0x6c, 0x2c, 0x23, 0x9f, 0xd7, 0xcc,
+	0x59, 0xac, 0x99, 0xb3, 0x5c, 0x33, 0xe7, 0xcd, 0xc1, 0x2c, 0xbe, 0xea, 0x37, 0x7f, 0xf4, 0xe9,
+	0x8f, 0x01, 0x00, 0xa6, 0xa3, 0x39, 0x2c, 0xd0, 0x02, 0x00, 0x00,
+}
+
+func (this *SignRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*SignRequest)
+	if !ok {
+		that2, ok := that.(SignRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Csr != that1.Csr {
+		return false
+	}
+	if len(this.SubjectIds) != len(that1.SubjectIds) {
+		return false
+	}
+	for i := range this.SubjectIds {
+		if this.SubjectIds[i] != that1.SubjectIds[i] {
+			return false
+		}
+	}
+	if this.TtlSeconds != that1.TtlSeconds {
+		return false
+	}
+	if this.ForCa != that1.ForCa {
+		return false
+	}
+	return true
+}
+func (this *SignResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*SignResponse)
+	if !ok {
+		that2, ok := that.(SignResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Leaf != that1.Leaf {
+		return false
+	}
+	if len(this.Intermediates) != len(that1.Intermediates) {
+		return false
+	}
+	for i := range this.Intermediates {
+		if this.Intermediates[i] != that1.Intermediates[i] {
+			return false
+		}
+	}
+	if len(this.Roots) != len(that1.Roots) {
+		return false
+	}
+	for i := range this.Roots {
+		if this.Roots[i] != that1.Roots[i] {
+			return false
+		}
+	}
+	return true
+}
+func (this *GetTrustBundleRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*GetTrustBundleRequest)
+	if !ok {
+		that2, ok := that.(GetTrustBundleRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	return true
+}
+func (this *GetTrustBundleResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*GetTrustBundleResponse)
+	if !ok {
+		that2, ok := that.(GetTrustBundleResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.CaCert != that1.CaCert {
+		return false
+	}
+	if len(this.Intermediates) != len(that1.Intermediates) {
+		return false
+	}
+	for i := range this.Intermediates {
+		if this.Intermediates[i] != that1.Intermediates[i] {
+			return false
+		}
+	}
+	if len(this.Roots) != len(that1.Roots) {
+		return false
+	}
+	for i := range this.Roots {
+		if this.Roots[i] != that1.Roots[i] {
+			return false
+		}
+	}
+	return true
+}
+func (this *SignRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&v1.SignRequest{")
+	s = append(s, "Csr: "+fmt.Sprintf("%#v", this.Csr)+",\n")
+	s = append(s, "SubjectIds: "+fmt.Sprintf("%#v", this.SubjectIds)+",\n")
+	s = append(s, "TtlSeconds: "+fmt.Sprintf("%#v", this.TtlSeconds)+",\n")
+	s = append(s, "ForCa: "+fmt.Sprintf("%#v", this.ForCa)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *SignResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&v1.SignResponse{")
+	s = append(s, "Leaf: "+fmt.Sprintf("%#v", this.Leaf)+",\n")
+	s = append(s, "Intermediates: "+fmt.Sprintf("%#v", this.Intermediates)+",\n")
+	s = append(s, "Roots: "+fmt.Sprintf("%#v", this.Roots)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *GetTrustBundleRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 4)
+	s = append(s, "&v1.GetTrustBundleRequest{")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *GetTrustBundleResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&v1.GetTrustBundleResponse{")
+	s = append(s, "CaCert: "+fmt.Sprintf("%#v", this.CaCert)+",\n")
+	s = append(s, "Intermediates: "+fmt.Sprintf("%#v", this.Intermediates)+",\n")
+	s = append(s, "Roots: "+fmt.Sprintf("%#v", this.Roots)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func valueToGoStringCaplugin(v interface{}, typ string) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("func(v %v) *%v { return &v } ( %#v )", typ, typ, pv)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// CAPluginClient is the client API for CAPlugin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type CAPluginClient interface {
+	// Signs a certificate signing request. Errors use the gRPC status codes:
+	// INVALID_ARGUMENT for an invalid request, PERMISSION_DENIED for SANs the
+	// requester may not have, and UNAVAILABLE or RESOURCE_EXHAUSTED when the
+	// request may be retried.
+	Sign(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignResponse, error)
+	// Returns the certificates of the CA.
+	GetTrustBundle(ctx context.Context, in *GetTrustBundleRequest, opts ...grpc.CallOption) (*GetTrustBundleResponse, error)
+}
+
+type cAPluginClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewCAPluginClient(cc *grpc.ClientConn) CAPluginClient {
+	return &cAPluginClient{cc}
+}
+
+func (c *cAPluginClient) Sign(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignResponse, error) {
+	out := new(SignResponse)
+	err := c.cc.Invoke(ctx, "/istio.security.caplugin.v1.CAPlugin/Sign", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cAPluginClient) GetTrustBundle(ctx context.Context, in *GetTrustBundleRequest, opts ...grpc.CallOption) (*GetTrustBundleResponse, error) {
+	out := new(GetTrustBundleResponse)
+	err := c.cc.Invoke(ctx, "/istio.security.caplugin.v1.CAPlugin/GetTrustBundle", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CAPluginServer is the server API for CAPlugin service.
+type CAPluginServer interface {
+	// Signs a certificate signing request. Errors use the gRPC status codes:
+	// INVALID_ARGUMENT for an invalid request, PERMISSION_DENIED for SANs the
+	// requester may not have, and UNAVAILABLE or RESOURCE_EXHAUSTED when the
+	// request may be retried.
+	Sign(context.Context, *SignRequest) (*SignResponse, error)
+	// Returns the certificates of the CA.
+	GetTrustBundle(context.Context, *GetTrustBundleRequest) (*GetTrustBundleResponse, error)
+}
+
+// UnimplementedCAPluginServer can be embedded to have forward compatible implementations.
+type UnimplementedCAPluginServer struct {
+}
+
+func (*UnimplementedCAPluginServer) Sign(ctx context.Context, req *SignRequest) (*SignResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sign not implemented")
+}
+func (*UnimplementedCAPluginServer) GetTrustBundle(ctx context.Context, req *GetTrustBundleRequest) (*GetTrustBundleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTrustBundle not implemented")
+}
+
+func RegisterCAPluginServer(s *grpc.Server, srv CAPluginServer) {
+	s.RegisterService(&_CAPlugin_serviceDesc, srv)
+}
+
+func _CAPlugin_Sign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CAPluginServer).Sign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/istio.security.caplugin.v1.CAPlugin/Sign",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CAPluginServer).Sign(ctx, req.(*SignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CAPlugin_GetTrustBundle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTrustBundleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CAPluginServer).GetTrustBundle(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/istio.security.caplugin.v1.CAPlugin/GetTrustBundle",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CAPluginServer).GetTrustBundle(ctx, req.(*GetTrustBundleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _CAPlugin_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "istio.security.caplugin.v1.CAPlugin",
+	HandlerType: (*CAPluginServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Sign",
+			Handler:    _CAPlugin_Sign_Handler,
+		},
+		{
+			MethodName: "GetTrustBundle",
+			Handler:    _CAPlugin_GetTrustBundle_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "security/proto/caplugin/v1/caplugin.proto",
+}
+
+func (m *SignRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SignRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SignRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.ForCa {
+		i--
+		if m.ForCa {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.TtlSeconds != 0 {
+		i = encodeVarintCaplugin(dAtA, i, uint64(m.TtlSeconds))
+		i--
+		dAtA[i] = 0x18
+	}
+	if len(m.SubjectIds) > 0 {
+		for iNdEx := len(m.SubjectIds) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.SubjectIds[iNdEx])
+			copy(dAtA[i:], m.SubjectIds[iNdEx])
+			i = encodeVarintCaplugin(dAtA, i, uint64(len(m.SubjectIds[iNdEx])))
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if len(m.Csr) > 0 {
+		i -= len(m.Csr)
+		copy(dAtA[i:], m.Csr)
+		i = encodeVarintCaplugin(dAtA, i, uint64(len(m.Csr)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *SignResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SignResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SignResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Roots) > 0 {
+		for iNdEx := len(m.Roots) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Roots[iNdEx])
+			copy(dAtA[i:], m.Roots[iNdEx])
+			i = encodeVarintCaplugin(dAtA, i, uint64(len(m.Roots[iNdEx])))
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
+	if len(m.Intermediates) > 0 {
+		for iNdEx := len(m.Intermediates) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Intermediates[iNdEx])
+			copy(dAtA[i:], m.Intermediates[iNdEx])
+			i = encodeVarintCaplugin(dAtA, i, uint64(len(m.Intermediates[iNdEx])))
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if len(m.Leaf) > 0 {
+		i -= len(m.Leaf)
+		copy(dAtA[i:], m.Leaf)
+		i = encodeVarintCaplugin(dAtA, i, uint64(len(m.Leaf)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *GetTrustBundleRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *GetTrustBundleRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *GetTrustBundleRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *GetTrustBundleResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *GetTrustBundleResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *GetTrustBundleResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Roots) > 0 {
+		for iNdEx := len(m.Roots) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Roots[iNdEx])
+			copy(dAtA[i:], m.Roots[iNdEx])
+			i = encodeVarintCaplugin(dAtA, i, uint64(len(m.Roots[iNdEx])))
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
+	if len(m.Intermediates) > 0 {
+		for iNdEx := len(m.Intermediates) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Intermediates[iNdEx])
+			copy(dAtA[i:], m.Intermediates[iNdEx])
+			i = encodeVarintCaplugin(dAtA, i, uint64(len(m.Intermediates[iNdEx])))
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if len(m.CaCert) > 0 {
+		i -= len(m.CaCert)
+		copy(dAtA[i:], m.CaCert)
+		i = encodeVarintCaplugin(dAtA, i, uint64(len(m.CaCert)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintCaplugin(dAtA []byte, offset int, v uint64) int {
+	offset -= sovCaplugin(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *SignRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Csr)
+	if l > 0 {
+		n += 1 + l + sovCaplugin(uint64(l))
+	}
+	if len(m.SubjectIds) > 0 {
+		for _, s := range m.SubjectIds {
+			l = len(s)
+			n += 1 + l + sovCaplugin(uint64(l))
+		}
+	}
+	if m.TtlSeconds != 0 {
+		n += 1 + sovCaplugin(uint64(m.TtlSeconds))
+	}
+	if m.ForCa {
+		n += 2
+	}
+	return n
+}
+
+func (m *SignResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Leaf)
+	if l > 0 {
+		n += 1 + l + sovCaplugin(uint64(l))
+	}
+	if len(m.Intermediates) > 0 {
+		for _, s := range m.Intermediates {
+			l = len(s)
+			n += 1 + l + sovCaplugin(uint64(l))
+		}
+	}
+	if len(m.Roots) > 0 {
+		for _, s := range m.Roots {
+			l = len(s)
+			n += 1 + l + sovCaplugin(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *GetTrustBundleRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *GetTrustBundleResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.CaCert)
+	if l > 0 {
+		n += 1 + l + sovCaplugin(uint64(l))
+	}
+	if len(m.Intermediates) > 0 {
+		for _, s := range m.Intermediates {
+			l = len(s)
+			n += 1 + l + sovCaplugin(uint64(l))
+		}
+	}
+	if len(m.Roots) > 0 {
+		for _, s := range m.Roots {
+			l = len(s)
+			n += 1 + l + sovCaplugin(uint64(l))
+		}
+	}
+	return n
+}
+
+func sovCaplugin(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozCaplugin(x uint64) (n int) {
+	return sovCaplugin(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (this *SignRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&SignRequest{`,
+		`Csr:` + fmt.Sprintf("%v", this.Csr) + `,`,
+		`SubjectIds:` + fmt.Sprintf("%v", this.SubjectIds) + `,`,
+		`TtlSeconds:` + fmt.Sprintf("%v", this.TtlSeconds) + `,`,
+		`ForCa:` + fmt.Sprintf("%v", this.ForCa) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *SignResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&SignResponse{`,
+		`Leaf:` + fmt.Sprintf("%v", this.Leaf) + `,`,
+		`Intermediates:` + fmt.Sprintf("%v", this.Intermediates) + `,`,
+		`Roots:` + fmt.Sprintf("%v", this.Roots) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *GetTrustBundleRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&GetTrustBundleRequest{`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *GetTrustBundleResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&GetTrustBundleResponse{`,
+		`CaCert:` + fmt.Sprintf("%v", this.CaCert) + `,`,
+		`Intermediates:` + fmt.Sprintf("%v", this.Intermediates) + `,`,
+		`Roots:` + fmt.Sprintf("%v", this.Roots) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func valueToStringCaplugin(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("*%v", pv)
+}
+func (m *SignRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCaplugin
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SignRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SignRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Csr", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCaplugin
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Csr = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SubjectIds", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCaplugin
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SubjectIds = append(m.SubjectIds, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TtlSeconds", wireType)
+			}
+			m.TtlSeconds = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCaplugin
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TtlSeconds |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ForCa", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCaplugin
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ForCa = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCaplugin(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SignResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCaplugin
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SignResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SignResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Leaf", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCaplugin
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Leaf = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Intermediates", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCaplugin
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Intermediates = append(m.Intermediates, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Roots", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCaplugin
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Roots = append(m.Roots, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCaplugin(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *GetTrustBundleRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCaplugin
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: GetTrustBundleRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: GetTrustBundleRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCaplugin(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *GetTrustBundleResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCaplugin
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: GetTrustBundleResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: GetTrustBundleResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CaCert", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCaplugin
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.CaCert = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Intermediates", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCaplugin
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Intermediates = append(m.Intermediates, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Roots", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCaplugin
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Roots = append(m.Roots, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCaplugin(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCaplugin
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipCaplugin(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowCaplugin
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowCaplugin
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
+					break
+				}
+			}
+			return iNdEx, nil
+		case 1:
+			iNdEx += 8
+			return iNdEx, nil
+		case 2:
+			var length int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowCaplugin
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				length |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if length < 0 {
+				return 0, ErrInvalidLengthCaplugin
+			}
+			iNdEx += length
+			if iNdEx < 0 {
+				return 0, ErrInvalidLengthCaplugin
+			}
+			return iNdEx, nil
+		case 3:
+			for {
+				var innerWire uint64
+				var start int = iNdEx
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return 0, ErrIntOverflowCaplugin
+					}
+					if iNdEx >= l {
+						return 0, io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					innerWire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				innerWireType := int(innerWire & 0x7)
+				if innerWireType == 4 {
+					break
+				}
+				next, err := skipCaplugin(dAtA[start:])
+				if err != nil {
+					return 0, err
+				}
+				iNdEx = start + next
+				if iNdEx < 0 {
+					return 0, ErrInvalidLengthCaplugin
+				}
+			}
+			return iNdEx, nil
+		case 4:
+			return iNdEx, nil
+		case 5:
+			iNdEx += 4
+			return iNdEx, nil
+		default:
+			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
+		}
+	}
+	panic("unreachable")
+}
+
+var (
+	ErrInvalidLengthCaplugin = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowCaplugin   = fmt.Errorf("proto: integer overflow")
+)
@@ -96,6 +96,16 @@ type IstioCertificateResponse struct {
 	// PEM-encoded certificate chain.
 	// Leaf cert is element '0'. Root cert is element 'n'.
 	CertChain []string `protobuf:"bytes,1,rep,name=cert_chain,json=certChain,proto3" json:"cert_chain,omitempty"`
+	// PEM-encoded leaf certificate.
+	Leaf string `protobuf:"bytes,2,opt,name=leaf,proto3" json:"leaf,omitempty"`
+	// PEM-encoded intermediate certificates, from the issuer of the leaf towards the root.
+	Intermediates []string `protobuf:"bytes,3,rep,name=intermediates,proto3" json:"intermediates,omitempty"`
+	// PEM-encoded root certificates trusted by the mesh.
+	TrustBundle []string `protobuf:"bytes,4,rep,name=trust_bundle,json=trustBundle,proto3" json:"trust_bundle,omitempty"`
+	// Expiration time of the leaf certificate, in seconds since the Unix epoch.
+	NotAfter int64 `protobuf:"varint,5,opt,name=not_after,json=notAfter,proto3" json:"not_after,omitempty"`
+	// Serial number of the leaf certificate, in hexadecimal.
+	SerialNumber string `protobuf:"bytes,6,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
 }
 
 func (m *IstioCertificateResponse) Reset()      { *m = IstioCertificateResponse{} }
@@ -137,34 +147,296 @@ func (m *IstioCertificateResponse) GetCertChain() []string {
 	return nil
 }
 
+func (m *IstioCertificateResponse) GetLeaf() string {
+	if m != nil {
+		return m.Leaf
+	}
+	return ""
+}
+
+func (m *IstioCertificateResponse) GetIntermediates() []string {
+	if m != nil {
+		return m.Intermediates
+	}
+	return nil
+}
+
+func (m *IstioCertificateResponse) GetTrustBundle() []string {
+	if m != nil {
+		return m.TrustBundle
+	}
+	return nil
+}
+
+func (m *IstioCertificateResponse) GetNotAfter() int64 {
+	if m != nil {
+		return m.NotAfter
+	}
+	return 0
+}
+
+func (m *IstioCertificateResponse) GetSerialNumber() string {
+	if m != nil {
+		return m.SerialNumber
+	}
+	return ""
+}
+
+// Batch certificate request message.
+type IstioCertificateBatchRequest struct {
+	// Certificate requests. Each request is authenticated and signed independently.
+	Requests []*IstioCertificateBatchRequestItem `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+}
+
+func (m *IstioCertificateBatchRequest) Reset()      { *m = IstioCertificateBatchRequest{} }
+func (*IstioCertificateBatchRequest) ProtoMessage() {}
+func (*IstioCertificateBatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9eff2d2b4471d6ff, []int{2}
+}
+func (m *IstioCertificateBatchRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *IstioCertificateBatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_IstioCertificateBatchRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *IstioCertificateBatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IstioCertificateBatchRequest.Merge(m, src)
+}
+func (m *IstioCertificateBatchRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *IstioCertificateBatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_IstioCertificateBatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IstioCertificateBatchRequest proto.InternalMessageInfo
+
+func (m *IstioCertificateBatchRequest) GetRequests() []*IstioCertificateBatchRequestItem {
+	if m != nil {
+		return m.Requests
+	}
+	return nil
+}
+
+// Certificate request in a batch.
+type IstioCertificateBatchRequestItem struct {
+	// Certificate request.
+	Request *IstioCertificateRequest `protobuf:"bytes,1,opt,name=request,proto3" json:"request,omitempty"`
+	// Optional: bearer token authenticating the request, e.g. the JWT of the workload the
+	// certificate is requested for. If empty, the credentials of the batch request are used.
+	Token string `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+}
+
+func (m *IstioCertificateBatchRequestItem) Reset()      { *m = IstioCertificateBatchRequestItem{} }
+func (*IstioCertificateBatchRequestItem) ProtoMessage() {}
+func (*IstioCertificateBatchRequestItem) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9eff2d2b4471d6ff, []int{3}
+}
+func (m *IstioCertificateBatchRequestItem) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *IstioCertificateBatchRequestItem) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_IstioCertificateBatchRequestItem.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *IstioCertificateBatchRequestItem) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IstioCertificateBatchRequestItem.Merge(m, src)
+}
+func (m *IstioCertificateBatchRequestItem) XXX_Size() int {
+	return m.Size()
+}
+func (m *IstioCertificateBatchRequestItem) XXX_DiscardUnknown() {
+	xxx_messageInfo_IstioCertificateBatchRequestItem.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IstioCertificateBatchRequestItem proto.InternalMessageInfo
+
+func (m *IstioCertificateBatchRequestItem) GetRequest() *IstioCertificateRequest {
+	if m != nil {
+		return m.Request
+	}
+	return nil
+}
+
+func (m *IstioCertificateBatchRequestItem) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+// Batch certificate response message.
+type IstioCertificateBatchResponse struct {
+	// Results, in the order of the requests.
+	Results []*IstioCertificateBatchResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+}
+
+func (m *IstioCertificateBatchResponse) Reset()      { *m = IstioCertificateBatchResponse{} }
+func (*IstioCertificateBatchResponse) ProtoMessage() {}
+func (*IstioCertificateBatchResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9eff2d2b4471d6ff, []int{4}
+}
+func (m *IstioCertificateBatchResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *IstioCertificateBatchResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_IstioCertificateBatchResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *IstioCertificateBatchResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IstioCertificateBatchResponse.Merge(m, src)
+}
+func (m *IstioCertificateBatchResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *IstioCertificateBatchResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_IstioCertificateBatchResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IstioCertificateBatchResponse proto.InternalMessageInfo
+
+func (m *IstioCertificateBatchResponse) GetResults() []*IstioCertificateBatchResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+// Result of a certificate request in a batch.
+type IstioCertificateBatchResult struct {
+	// Certificate response. Set if the request is signed.
+	Response *IstioCertificateResponse `protobuf:"bytes,1,opt,name=response,proto3" json:"response,omitempty"`
+	// gRPC status code of the request. 0 (OK) if the request is signed.
+	Code int32 `protobuf:"varint,2,opt,name=code,proto3" json:"code,omitempty"`
+	// Error message if the request failed.
+	Message string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (m *IstioCertificateBatchResult) Reset()      { *m = IstioCertificateBatchResult{} }
+func (*IstioCertificateBatchResult) ProtoMessage() {}
+func (*IstioCertificateBatchResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9eff2d2b4471d6ff, []int{5}
+}
+func (m *IstioCertificateBatchResult) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *IstioCertificateBatchResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_IstioCertificateBatchResult.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *IstioCertificateBatchResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IstioCertificateBatchResult.Merge(m, src)
+}
+func (m *IstioCertificateBatchResult) XXX_Size() int {
+	return m.Size()
+}
+func (m *IstioCertificateBatchResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_IstioCertificateBatchResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IstioCertificateBatchResult proto.InternalMessageInfo
+
+func (m *IstioCertificateBatchResult) GetResponse() *IstioCertificateResponse {
+	if m != nil {
+		return m.Response
+	}
+	return nil
+}
+
+func (m *IstioCertificateBatchResult) GetCode() int32 {
+	if m != nil {
+		return m.Code
+	}
+	return 0
+}
+
+func (m *IstioCertificateBatchResult) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*IstioCertificateRequest)(nil), "istio.v1.auth.IstioCertificateRequest")
 	proto.RegisterType((*IstioCertificateResponse)(nil), "istio.v1.auth.IstioCertificateResponse")
+	proto.RegisterType((*IstioCertificateBatchRequest)(nil), "istio.v1.auth.IstioCertificateBatchRequest")
+	proto.RegisterType((*IstioCertificateBatchRequestItem)(nil), "istio.v1.auth.IstioCertificateBatchRequestItem")
+	proto.RegisterType((*IstioCertificateBatchResponse)(nil), "istio.v1.auth.IstioCertificateBatchResponse")
+	proto.RegisterType((*IstioCertificateBatchResult)(nil), "istio.v1.auth.IstioCertificateBatchResult")
 }
 
 func init() { proto.RegisterFile("security/proto/istioca.proto", fileDescriptor_9eff2d2b4471d6ff) }
 
 var fileDescriptor_9eff2d2b4471d6ff = []byte{
-	// 294 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x91, 0xbd, 0x4a, 0x03, 0x41,
-	0x14, 0x85, 0xe7, 0xba, 0x20, 0x64, 0x40, 0x48, 0xb6, 0x71, 0x11, 0xbd, 0x84, 0x14, 0x1a, 0x10,
-	0x36, 0xf8, 0xd3, 0xd8, 0x1a, 0x9b, 0xb4, 0xf1, 0x01, 0xc2, 0x64, 0xf6, 0x86, 0x8c, 0x48, 0x36,
-	0xce, 0xdc, 0x8d, 0xa4, 0xd3, 0x37, 0xf0, 0x31, 0x7c, 0x14, 0xcb, 0x94, 0x29, 0xcd, 0x6c, 0x63,
-	0x99, 0x47, 0x90, 0x59, 0x23, 0x28, 0x41, 0xec, 0xe6, 0x7c, 0x67, 0xe0, 0x9c, 0xc3, 0x95, 0x87,
-	0x8e, 0x74, 0x61, 0x0d, 0xcf, 0x3b, 0x53, 0x9b, 0x73, 0xde, 0x31, 0x8e, 0x4d, 0xae, 0x55, 0x5a,
-	0xa9, 0x78, 0xaf, 0x92, 0xe9, 0xec, 0x2c, 0x55, 0x05, 0x8f, 0x5b, 0x8f, 0x72, 0xbf, 0x17, 0x40,
-	0x97, 0x2c, 0x9b, 0x91, 0xd1, 0x8a, 0xa9, 0x4f, 0x0f, 0x05, 0x39, 0x8e, 0xeb, 0x32, 0xd2, 0xce,
-	0x26, 0xd0, 0x84, 0x76, 0xad, 0x1f, 0x9e, 0xf1, 0x91, 0x94, 0xae, 0x18, 0xde, 0x91, 0xe6, 0x81,
-	0xc9, 0x92, 0x9d, 0xca, 0xa8, 0x6d, 0x48, 0x2f, 0x8b, 0x4f, 0x65, 0x63, 0xa6, 0xee, 0x4d, 0x66,
-	0x78, 0x3e, 0xc8, 0x0a, 0xab, 0xd8, 0xe4, 0x93, 0x24, 0x6a, 0x42, 0x3b, 0xea, 0xd7, 0xbf, 0x8d,
-	0x9b, 0x0d, 0x6f, 0x5d, 0xc9, 0x64, 0x3b, 0xd8, 0x4d, 0xf3, 0x89, 0xa3, 0x90, 0xa3, 0xc9, 0xf2,
-	0x40, 0x8f, 0x95, 0x99, 0x24, 0xd0, 0x8c, 0x42, 0x4e, 0x20, 0xdd, 0x00, 0xce, 0x9f, 0x61, 0xbb,
-	0xf4, 0x2d, 0xd9, 0x99, 0xd1, 0x14, 0x8f, 0x64, 0xa3, 0x6b, 0x49, 0x31, 0xfd, 0xf0, 0xe2, 0xe3,
-	0xf4, 0xd7, 0xe8, 0xf4, 0x8f, 0xc5, 0x07, 0x27, 0xff, 0xfe, 0xfb, 0x2a, 0xd8, 0x12, 0xd7, 0x97,
-	0x8b, 0x15, 0x8a, 0xe5, 0x0a, 0xc5, 0x7a, 0x85, 0xf0, 0xe4, 0x11, 0x5e, 0x3d, 0xc2, 0x9b, 0x47,
-	0x58, 0x78, 0x84, 0x77, 0x8f, 0xf0, 0xe1, 0x51, 0xac, 0x3d, 0xc2, 0x4b, 0x89, 0x62, 0x51, 0xa2,
-	0x58, 0x96, 0x28, 0x86, 0xbb, 0xd5, 0x0d, 0x2e, 0x3e, 0x03, 0x00, 0x00, 0xff, 0xff, 0x23, 0xaa,
-	0x00, 0x39, 0xa3, 0x01, 0x00, 0x00,
+	// 537 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xcf, 0x6e, 0xd3, 0x4e,
+	0x10, 0xf6, 0xfe, 0xd2, 0x7f, 0x99, 0x36, 0x52, 0xbb, 0xfa, 0x09, 0xac, 0xfe, 0x59, 0x05, 0x83,
+	0xa0, 0xa2, 0x28, 0x11, 0x81, 0x07, 0x80, 0xa4, 0x97, 0x08, 0x89, 0x83, 0x79, 0x00, 0x6b, 0x63,
+	0x4f, 0xc8, 0xd2, 0xc4, 0x2e, 0xbb, 0xe3, 0xa0, 0x72, 0xe2, 0x11, 0xfa, 0x18, 0x3c, 0x0a, 0xc7,
+	0x1c, 0x73, 0x24, 0xce, 0x85, 0x63, 0x8f, 0x1c, 0x91, 0x37, 0x4e, 0x44, 0x89, 0x68, 0xc2, 0x6d,
+	0xe6, 0xdb, 0xf9, 0xf2, 0xcd, 0x37, 0x33, 0x31, 0x1c, 0x1b, 0x0c, 0x53, 0xad, 0xe8, 0xaa, 0x7e,
+	0xa9, 0x13, 0x4a, 0xea, 0xca, 0x90, 0x4a, 0x42, 0x59, 0xb3, 0x19, 0xaf, 0xd8, 0xb4, 0x36, 0x7c,
+	0x5e, 0x93, 0x29, 0xf5, 0xbc, 0x4f, 0x70, 0xbf, 0x9d, 0x03, 0x2d, 0xd4, 0xa4, 0xba, 0x2a, 0x94,
+	0x84, 0x3e, 0x7e, 0x4c, 0xd1, 0x10, 0xdf, 0x87, 0x52, 0x68, 0xb4, 0xcb, 0xaa, 0xec, 0xb4, 0xec,
+	0xe7, 0x21, 0x3f, 0x01, 0x30, 0x69, 0xe7, 0x03, 0x86, 0x14, 0xa8, 0xc8, 0xfd, 0xcf, 0x3e, 0x94,
+	0x0b, 0xa4, 0x1d, 0xf1, 0x33, 0x38, 0x18, 0xca, 0xbe, 0x8a, 0x14, 0x5d, 0x05, 0x51, 0xaa, 0x25,
+	0xa9, 0x24, 0x76, 0x4b, 0x55, 0x76, 0x5a, 0xf2, 0xf7, 0xe7, 0x0f, 0xe7, 0x05, 0xee, 0x8d, 0x19,
+	0xb8, 0xcb, 0xca, 0xe6, 0x32, 0x89, 0x0d, 0xe6, 0x42, 0x21, 0x6a, 0x0a, 0xc2, 0x9e, 0x54, 0xb1,
+	0xcb, 0xaa, 0xa5, 0x5c, 0x28, 0x47, 0x5a, 0x39, 0xc0, 0x39, 0x6c, 0xf4, 0x51, 0x76, 0x8b, 0x0e,
+	0x6c, 0xcc, 0x1f, 0x41, 0x45, 0xc5, 0x84, 0x7a, 0x80, 0x91, 0x92, 0x84, 0xc6, 0x2d, 0x59, 0xd6,
+	0x6d, 0x90, 0x3f, 0x80, 0x3d, 0xd2, 0xa9, 0xa1, 0xa0, 0x93, 0xc6, 0x51, 0x1f, 0xdd, 0x0d, 0x5b,
+	0xb4, 0x6b, 0xb1, 0xa6, 0x85, 0xf8, 0x11, 0x94, 0xe3, 0x84, 0x02, 0xd9, 0x25, 0xd4, 0xee, 0xa6,
+	0xed, 0x7e, 0x27, 0x4e, 0xe8, 0x75, 0x9e, 0xf3, 0x87, 0x50, 0x31, 0xa8, 0x95, 0xec, 0x07, 0x71,
+	0x3a, 0xe8, 0xa0, 0x76, 0xb7, 0x6c, 0x0b, 0x7b, 0x33, 0xf0, 0xad, 0xc5, 0xbc, 0x0b, 0x38, 0xfe,
+	0xd3, 0x59, 0x53, 0x52, 0xd8, 0x9b, 0x0f, 0xf6, 0x0d, 0xec, 0xe8, 0x59, 0x68, 0xac, 0xb7, 0xdd,
+	0x46, 0xbd, 0x76, 0x6b, 0x2b, 0xb5, 0xbb, 0xe8, 0x6d, 0xc2, 0x81, 0xbf, 0xf8, 0x01, 0xef, 0x33,
+	0x54, 0x57, 0x55, 0xf3, 0x57, 0xb0, 0x5d, 0xd4, 0xdb, 0x6d, 0xee, 0x36, 0x1e, 0xaf, 0xd0, 0x2b,
+	0xc8, 0xfe, 0x9c, 0xc6, 0xff, 0x87, 0x4d, 0x4a, 0x2e, 0x30, 0x2e, 0x46, 0x3e, 0x4b, 0x3c, 0x84,
+	0x93, 0xbf, 0x68, 0x17, 0x7b, 0x3c, 0xcf, 0x85, 0x4d, 0xda, 0x5f, 0x18, 0x7d, 0xba, 0x9e, 0xd1,
+	0x9c, 0xe2, 0xcf, 0xa9, 0xde, 0x35, 0x83, 0xa3, 0x3b, 0x0a, 0x79, 0x2b, 0x9f, 0xe7, 0x4c, 0xb1,
+	0xf0, 0xf7, 0x64, 0xa5, 0xbf, 0x59, 0xb9, 0xbf, 0x20, 0xe6, 0x37, 0x15, 0x26, 0x11, 0x5a, 0x83,
+	0x9b, 0xbe, 0x8d, 0xb9, 0x0b, 0xdb, 0x03, 0x34, 0x46, 0xbe, 0x47, 0x7b, 0xc6, 0x65, 0x7f, 0x9e,
+	0x36, 0x7e, 0xb2, 0xe5, 0xff, 0xcd, 0x3b, 0xd4, 0x43, 0x15, 0x22, 0xef, 0xc2, 0x41, 0x4b, 0xa3,
+	0x24, 0xfc, 0xed, 0x8d, 0xaf, 0x39, 0xf1, 0xc3, 0x75, 0x3b, 0xf7, 0x1c, 0x9e, 0xc2, 0xbd, 0x25,
+	0x1d, 0x3b, 0x16, 0x7e, 0xf6, 0x0f, 0xe7, 0x74, 0xf8, 0x6c, 0xcd, 0x95, 0x14, 0xb2, 0xcd, 0x97,
+	0xa3, 0x89, 0x70, 0xc6, 0x13, 0xe1, 0xdc, 0x4c, 0x04, 0xfb, 0x92, 0x09, 0xf6, 0x35, 0x13, 0xec,
+	0x5b, 0x26, 0xd8, 0x28, 0x13, 0xec, 0x7b, 0x26, 0xd8, 0x8f, 0x4c, 0x38, 0x37, 0x99, 0x60, 0xd7,
+	0x53, 0xe1, 0x8c, 0xa6, 0xc2, 0x19, 0x4f, 0x85, 0xd3, 0xd9, 0xb2, 0x5f, 0x9f, 0x17, 0xbf, 0x06,
+	0x00, 0x9c, 0xe7, 0x58, 0xd7, 0x9d, 0x04, 0x00, 0x00,
 }
 
 func (this *IstioCertificateRequest) Equal(that interface{}) bool {
@@ -224,6 +496,146 @@ func (this *IstioCertificateResponse) Equal(that interface{}) bool {
 			return false
 		}
 	}
+	if this.Leaf != that1.Leaf {
+		return false
+	}
+	if len(this.Intermediates) != len(that1.Intermediates) {
+		return false
+	}
+	for i := range this.Intermediates {
+		if this.Intermediates[i] != that1.Intermediates[i] {
+			return false
+		}
+	}
+	if len(this.TrustBundle) != len(that1.TrustBundle) {
+		return false
+	}
+	for i := range this.TrustBundle {
+		if this.TrustBundle[i] != that1.TrustBundle[i] {
+			return false
+		}
+	}
+	if this.NotAfter != that1.NotAfter {
+		return false
+	}
+	if this.SerialNumber != that1.SerialNumber {
+		return false
+	}
+	return true
+}
+func (this *IstioCertificateBatchRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*IstioCertificateBatchRequest)
+	if !ok {
+		that2, ok := that.(IstioCertificateBatchRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Requests) != len(that1.Requests) {
+		return false
+	}
+	for i := range this.Requests {
+		if !this.Requests[i].Equal(that1.Requests[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *IstioCertificateBatchRequestItem) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*IstioCertificateBatchRequestItem)
+	if !ok {
+		that2, ok := that.(IstioCertificateBatchRequestItem)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !this.Request.Equal(that1.Request) {
+		return false
+	}
+	if this.Token != that1.Token {
+		return false
+	}
+	return true
+}
+func (this *IstioCertificateBatchResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*IstioCertificateBatchResponse)
+	if !ok {
+		that2, ok := that.(IstioCertificateBatchResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Results) != len(that1.Results) {
+		return false
+	}
+	for i := range this.Results {
+		if !this.Results[i].Equal(that1.Results[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *IstioCertificateBatchResult) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*IstioCertificateBatchResult)
+	if !ok {
+		that2, ok := that.(IstioCertificateBatchResult)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !this.Response.Equal(that1.Response) {
+		return false
+	}
+	if this.Code != that1.Code {
+		return false
+	}
+	if this.Message != that1.Message {
+		return false
+	}
 	return true
 }
 func (this *IstioCertificateRequest) GoString() string {
@@ -242,15 +654,71 @@ func (this *IstioCertificateResponse) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 5)
+	s := make([]string, 0, 10)
 	s = append(s, "&istio_v1_auth.IstioCertificateResponse{")
 	s = append(s, "CertChain: "+fmt.Sprintf("%#v", this.CertChain)+",\n")
+	s = append(s, "Leaf: "+fmt.Sprintf("%#v", this.Leaf)+",\n")
+	s = append(s, "Intermediates: "+fmt.Sprintf("%#v", this.Intermediates)+",\n")
+	s = append(s, "TrustBundle: "+fmt.Sprintf("%#v", this.TrustBundle)+",\n")
+	s = append(s, "NotAfter: "+fmt.Sprintf("%#v", this.NotAfter)+",\n")
+	s = append(s, "SerialNumber: "+fmt.Sprintf("%#v", this.SerialNumber)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
-func valueToGoStringIstioca(v interface{}, typ string) string {
-	rv := reflect.ValueOf(v)
-	if rv.IsNil() {
+func (this *IstioCertificateBatchRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&istio_v1_auth.IstioCertificateBatchRequest{")
+	if this.Requests != nil {
+		s = append(s, "Requests: "+fmt.Sprintf("%#v", this.Requests)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *IstioCertificateBatchRequestItem) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&istio_v1_auth.IstioCertificateBatchRequestItem{")
+	if this.Request != nil {
+		s = append(s, "Request: "+fmt.Sprintf("%#v", this.Request)+",\n")
+	}
+	s = append(s, "Token: "+fmt.Sprintf("%#v", this.Token)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *IstioCertificateBatchResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&istio_v1_auth.IstioCertificateBatchResponse{")
+	if this.Results != nil {
+		s = append(s, "Results: "+fmt.Sprintf("%#v", this.Results)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *IstioCertificateBatchResult) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&istio_v1_auth.IstioCertificateBatchResult{")
+	if this.Response != nil {
+		s = append(s, "Response: "+fmt.Sprintf("%#v", this.Response)+",\n")
+	}
+	s = append(s, "Code: "+fmt.Sprintf("%#v", this.Code)+",\n")
+	s = append(s, "Message: "+fmt.Sprintf("%#v", this.Message)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func valueToGoStringIstioca(v interface{}, typ string) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
 		return "nil"
 	}
 	pv := reflect.Indirect(rv).Interface()
@@ -271,6 +739,9 @@ const _ = grpc.SupportPackageIsVersion4
 type IstioCertificateServiceClient interface {
 	// Using provided CSR, returns a signed certificate.
 	CreateCertificate(ctx context.Context, in *IstioCertificateRequest, opts ...grpc.CallOption) (*IstioCertificateResponse, error)
+	// Using provided CSRs, returns signed certificates in one round trip, e.g. for node agents
+	// bootstrapping many workloads at once.
+	CreateCertificateBatch(ctx context.Context, in *IstioCertificateBatchRequest, opts ...grpc.CallOption) (*IstioCertificateBatchResponse, error)
 }
 
 type istioCertificateServiceClient struct {
@@ -290,10 +761,22 @@ func (c *istioCertificateServiceClient) CreateCertificate(ctx context.Context, i
 	return out, nil
 }
 
+func (c *istioCertificateServiceClient) CreateCertificateBatch(ctx context.Context, in *IstioCertificateBatchRequest, opts ...grpc.CallOption) (*IstioCertificateBatchResponse, error) {
+	out := new(IstioCertificateBatchResponse)
+	err := c.cc.Invoke(ctx, "/istio.v1.auth.IstioCertificateService/CreateCertificateBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // IstioCertificateServiceServer is the server API for IstioCertificateService service.
 type IstioCertificateServiceServer interface {
 	// Using provided CSR, returns a signed certificate.
 	CreateCertificate(context.Context, *IstioCertificateRequest) (*IstioCertificateResponse, error)
+	// Using provided CSRs, returns signed certificates in one round trip, e.g. for node agents
+	// bootstrapping many workloads at once.
+	CreateCertificateBatch(context.Context, *IstioCertificateBatchRequest) (*IstioCertificateBatchResponse, error)
 }
 
 // UnimplementedIstioCertificateServiceServer can be embedded to have forward compatible implementations.
@@ -303,6 +786,9 @@ type UnimplementedIstioCertificateServiceServer struct {
 func (*UnimplementedIstioCertificateServiceServer) CreateCertificate(ctx context.Context, req *IstioCertificateRequest) (*IstioCertificateResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateCertificate not implemented")
 }
+func (*UnimplementedIstioCertificateServiceServer) CreateCertificateBatch(ctx context.Context, req *IstioCertificateBatchRequest) (*IstioCertificateBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCertificateBatch not implemented")
+}
 
 func RegisterIstioCertificateServiceServer(s *grpc.Server, srv IstioCertificateServiceServer) {
 	s.RegisterService(&_IstioCertificateService_serviceDesc, srv)
@@ -326,6 +812,24 @@ func _IstioCertificateService_CreateCertificate_Handler(srv interface{}, ctx con
 	return interceptor(ctx, in, info, handler)
 }
 
+func _IstioCertificateService_CreateCertificateBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IstioCertificateBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IstioCertificateServiceServer).CreateCertificateBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/istio.v1.auth.IstioCertificateService/CreateCertificateBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IstioCertificateServiceServer).CreateCertificateBatch(ctx, req.(*IstioCertificateBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _IstioCertificateService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "istio.v1.auth.IstioCertificateService",
 	HandlerType: (*IstioCertificateServiceServer)(nil),
@@ -334,6 +838,10 @@ var _IstioCertificateService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "CreateCertificate",
 			Handler:    _IstioCertificateService_CreateCertificate_Handler,
 		},
+		{
+			MethodName: "CreateCertificateBatch",
+			Handler:    _IstioCertificateService_CreateCertificateBatch_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "security/proto/istioca.proto",
@@ -401,6 +909,43 @@ func (m *IstioCertificateResponse) MarshalToSizedBuffer(dAtA []byte) (int, error
 	_ = i
 	var l int
 	_ = l
+	if len(m.SerialNumber) > 0 {
+		i -= len(m.SerialNumber)
+		copy(dAtA[i:], m.SerialNumber)
+		i = encodeVarintIstioca(dAtA, i, uint64(len(m.SerialNumber)))
+		i--
+		dAtA[i] = 0x32
+	}
+	if m.NotAfter != 0 {
+		i = encodeVarintIstioca(dAtA, i, uint64(m.NotAfter))
+		i--
+		dAtA[i] = 0x28
+	}
+	if len(m.TrustBundle) > 0 {
+		for iNdEx := len(m.TrustBundle) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.TrustBundle[iNdEx])
+			copy(dAtA[i:], m.TrustBundle[iNdEx])
+			i = encodeVarintIstioca(dAtA, i, uint64(len(m.TrustBundle[iNdEx])))
+			i--
+			dAtA[i] = 0x22
+		}
+	}
+	if len(m.Intermediates) > 0 {
+		for iNdEx := len(m.Intermediates) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Intermediates[iNdEx])
+			copy(dAtA[i:], m.Intermediates[iNdEx])
+			i = encodeVarintIstioca(dAtA, i, uint64(len(m.Intermediates[iNdEx])))
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
+	if len(m.Leaf) > 0 {
+		i -= len(m.Leaf)
+		copy(dAtA[i:], m.Leaf)
+		i = encodeVarintIstioca(dAtA, i, uint64(len(m.Leaf)))
+		i--
+		dAtA[i] = 0x12
+	}
 	if len(m.CertChain) > 0 {
 		for iNdEx := len(m.CertChain) - 1; iNdEx >= 0; iNdEx-- {
 			i -= len(m.CertChain[iNdEx])
@@ -413,6 +958,169 @@ func (m *IstioCertificateResponse) MarshalToSizedBuffer(dAtA []byte) (int, error
 	return len(dAtA) - i, nil
 }
 
+func (m *IstioCertificateBatchRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *IstioCertificateBatchRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *IstioCertificateBatchRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Requests) > 0 {
+		for iNdEx := len(m.Requests) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Requests[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintIstioca(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *IstioCertificateBatchRequestItem) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *IstioCertificateBatchRequestItem) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *IstioCertificateBatchRequestItem) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Token) > 0 {
+		i -= len(m.Token)
+		copy(dAtA[i:], m.Token)
+		i = encodeVarintIstioca(dAtA, i, uint64(len(m.Token)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.Request != nil {
+		{
+			size, err := m.Request.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintIstioca(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *IstioCertificateBatchResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *IstioCertificateBatchResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *IstioCertificateBatchResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Results) > 0 {
+		for iNdEx := len(m.Results) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Results[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintIstioca(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *IstioCertificateBatchResult) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *IstioCertificateBatchResult) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *IstioCertificateBatchResult) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Message) > 0 {
+		i -= len(m.Message)
+		copy(dAtA[i:], m.Message)
+		i = encodeVarintIstioca(dAtA, i, uint64(len(m.Message)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.Code != 0 {
+		i = encodeVarintIstioca(dAtA, i, uint64(m.Code))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Response != nil {
+		{
+			size, err := m.Response.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintIstioca(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintIstioca(dAtA []byte, offset int, v uint64) int {
 	offset -= sovIstioca(v)
 	base := offset
@@ -456,46 +1164,649 @@ func (m *IstioCertificateResponse) Size() (n int) {
 			n += 1 + l + sovIstioca(uint64(l))
 		}
 	}
-	return n
-}
+	l = len(m.Leaf)
+	if l > 0 {
+		n += 1 + l + sovIstioca(uint64(l))
+	}
+	if len(m.Intermediates) > 0 {
+		for _, s := range m.Intermediates {
+			l = len(s)
+			n += 1 + l + sovIstioca(uint64(l))
+		}
+	}
+	if len(m.TrustBundle) > 0 {
+		for _, s := range m.TrustBundle {
+			l = len(s)
+			n += 1 + l + sovIstioca(uint64(l))
+		}
+	}
+	if m.NotAfter != 0 {
+		n += 1 + sovIstioca(uint64(m.NotAfter))
+	}
+	l = len(m.SerialNumber)
+	if l > 0 {
+		n += 1 + l + sovIstioca(uint64(l))
+	}
+	return n
+}
+
+func (m *IstioCertificateBatchRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Requests) > 0 {
+		for _, e := range m.Requests {
+			l = e.Size()
+			n += 1 + l + sovIstioca(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *IstioCertificateBatchRequestItem) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Request != nil {
+		l = m.Request.Size()
+		n += 1 + l + sovIstioca(uint64(l))
+	}
+	l = len(m.Token)
+	if l > 0 {
+		n += 1 + l + sovIstioca(uint64(l))
+	}
+	return n
+}
+
+func (m *IstioCertificateBatchResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Results) > 0 {
+		for _, e := range m.Results {
+			l = e.Size()
+			n += 1 + l + sovIstioca(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *IstioCertificateBatchResult) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Response != nil {
+		l = m.Response.Size()
+		n += 1 + l + sovIstioca(uint64(l))
+	}
+	if m.Code != 0 {
+		n += 1 + sovIstioca(uint64(m.Code))
+	}
+	l = len(m.Message)
+	if l > 0 {
+		n += 1 + l + sovIstioca(uint64(l))
+	}
+	return n
+}
+
+func sovIstioca(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozIstioca(x uint64) (n int) {
+	return sovIstioca(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (this *IstioCertificateRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&IstioCertificateRequest{`,
+		`Csr:` + fmt.Sprintf("%v", this.Csr) + `,`,
+		`SubjectId:` + fmt.Sprintf("%v", this.SubjectId) + `,`,
+		`ValidityDuration:` + fmt.Sprintf("%v", this.ValidityDuration) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *IstioCertificateResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&IstioCertificateResponse{`,
+		`CertChain:` + fmt.Sprintf("%v", this.CertChain) + `,`,
+		`Leaf:` + fmt.Sprintf("%v", this.Leaf) + `,`,
+		`Intermediates:` + fmt.Sprintf("%v", this.Intermediates) + `,`,
+		`TrustBundle:` + fmt.Sprintf("%v", this.TrustBundle) + `,`,
+		`NotAfter:` + fmt.Sprintf("%v", this.NotAfter) + `,`,
+		`SerialNumber:` + fmt.Sprintf("%v", this.SerialNumber) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *IstioCertificateBatchRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForRequests := "[]*IstioCertificateBatchRequestItem{"
+	for _, f := range this.Requests {
+		repeatedStringForRequests += strings.Replace(f.String(), "IstioCertificateBatchRequestItem", "IstioCertificateBatchRequestItem", 1) + ","
+	}
+	repeatedStringForRequests += "}"
+	s := strings.Join([]string{`&IstioCertificateBatchRequest{`,
+		`Requests:` + repeatedStringForRequests + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *IstioCertificateBatchRequestItem) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&IstioCertificateBatchRequestItem{`,
+		`Request:` + strings.Replace(this.Request.String(), "IstioCertificateRequest", "IstioCertificateRequest", 1) + `,`,
+		`Token:` + fmt.Sprintf("%v", this.Token) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *IstioCertificateBatchResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForResults := "[]*IstioCertificateBatchResult{"
+	for _, f := range this.Results {
+		repeatedStringForResults += strings.Replace(f.String(), "IstioCertificateBatchResult", "IstioCertificateBatchResult", 1) + ","
+	}
+	repeatedStringForResults += "}"
+	s := strings.Join([]string{`&IstioCertificateBatchResponse{`,
+		`Results:` + repeatedStringForResults + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *IstioCertificateBatchResult) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&IstioCertificateBatchResult{`,
+		`Response:` + strings.Replace(this.Response.String(), "IstioCertificateResponse", "IstioCertificateResponse", 1) + `,`,
+		`Code:` + fmt.Sprintf("%v", this.Code) + `,`,
+		`Message:` + fmt.Sprintf("%v", this.Message) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func valueToStringIstioca(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("*%v", pv)
+}
+func (m *IstioCertificateRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIstioca
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: IstioCertificateRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: IstioCertificateRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Csr", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Csr = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SubjectId", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SubjectId = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ValidityDuration", wireType)
+			}
+			m.ValidityDuration = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ValidityDuration |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIstioca(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *IstioCertificateResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIstioca
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: IstioCertificateResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: IstioCertificateResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CertChain", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.CertChain = append(m.CertChain, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Leaf", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Leaf = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Intermediates", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Intermediates = append(m.Intermediates, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TrustBundle", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TrustBundle = append(m.TrustBundle, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NotAfter", wireType)
+			}
+			m.NotAfter = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.NotAfter |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SerialNumber", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SerialNumber = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIstioca(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
 
-func sovIstioca(x uint64) (n int) {
-	return (math_bits.Len64(x|1) + 6) / 7
-}
-func sozIstioca(x uint64) (n int) {
-	return sovIstioca(uint64((x << 1) ^ uint64((int64(x) >> 63))))
-}
-func (this *IstioCertificateRequest) String() string {
-	if this == nil {
-		return "nil"
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	s := strings.Join([]string{`&IstioCertificateRequest{`,
-		`Csr:` + fmt.Sprintf("%v", this.Csr) + `,`,
-		`SubjectId:` + fmt.Sprintf("%v", this.SubjectId) + `,`,
-		`ValidityDuration:` + fmt.Sprintf("%v", this.ValidityDuration) + `,`,
-		`}`,
-	}, "")
-	return s
+	return nil
 }
-func (this *IstioCertificateResponse) String() string {
-	if this == nil {
-		return "nil"
+func (m *IstioCertificateBatchRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIstioca
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: IstioCertificateBatchRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: IstioCertificateBatchRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Requests", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Requests = append(m.Requests, &IstioCertificateBatchRequestItem{})
+			if err := m.Requests[len(m.Requests)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIstioca(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
 	}
-	s := strings.Join([]string{`&IstioCertificateResponse{`,
-		`CertChain:` + fmt.Sprintf("%v", this.CertChain) + `,`,
-		`}`,
-	}, "")
-	return s
-}
-func valueToStringIstioca(v interface{}) string {
-	rv := reflect.ValueOf(v)
-	if rv.IsNil() {
-		return "nil"
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	pv := reflect.Indirect(rv).Interface()
-	return fmt.Sprintf("*%v", pv)
+	return nil
 }
-func (m *IstioCertificateRequest) Unmarshal(dAtA []byte) error {
+func (m *IstioCertificateBatchRequestItem) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -518,17 +1829,17 @@ func (m *IstioCertificateRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: IstioCertificateRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: IstioCertificateBatchRequestItem: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: IstioCertificateRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: IstioCertificateBatchRequestItem: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Csr", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Request", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowIstioca
@@ -538,27 +1849,31 @@ func (m *IstioCertificateRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthIstioca
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthIstioca
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Csr = string(dAtA[iNdEx:postIndex])
+			if m.Request == nil {
+				m.Request = &IstioCertificateRequest{}
+			}
+			if err := m.Request.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field SubjectId", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Token", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -586,13 +1901,66 @@ func (m *IstioCertificateRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.SubjectId = string(dAtA[iNdEx:postIndex])
+			m.Token = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ValidityDuration", wireType)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIstioca(dAtA[iNdEx:])
+			if err != nil {
+				return err
 			}
-			m.ValidityDuration = 0
+			if skippy < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *IstioCertificateBatchResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIstioca
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: IstioCertificateBatchResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: IstioCertificateBatchResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Results", wireType)
+			}
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowIstioca
@@ -602,11 +1970,26 @@ func (m *IstioCertificateRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.ValidityDuration |= int64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			if msglen < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Results = append(m.Results, &IstioCertificateBatchResult{})
+			if err := m.Results[len(m.Results)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipIstioca(dAtA[iNdEx:])
@@ -631,7 +2014,7 @@ func (m *IstioCertificateRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *IstioCertificateResponse) Unmarshal(dAtA []byte) error {
+func (m *IstioCertificateBatchResult) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -654,15 +2037,70 @@ func (m *IstioCertificateResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: IstioCertificateResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: IstioCertificateBatchResult: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: IstioCertificateResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: IstioCertificateBatchResult: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field CertChain", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Response", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Response == nil {
+				m.Response = &IstioCertificateResponse{}
+			}
+			if err := m.Response.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Code", wireType)
+			}
+			m.Code = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Code |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Message", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -690,7 +2128,7 @@ func (m *IstioCertificateResponse) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.CertChain = append(m.CertChain, string(dAtA[iNdEx:postIndex]))
+			m.Message = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -25,6 +25,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os/exec"
+	"strings"
 	"time"
 
 	k8s "k8s.io/api/core/v1"
@@ -58,6 +59,12 @@ var (
 	//Enable this flag if istio mTLS is enabled and the service is running as server side
 	isServer = flag.Bool("server", false, "Whether this certificate is for a server.")
 	ec       = flag.String("ec-sig-alg", "", "Generate an elliptical curve private key with the specified algorithm")
+	pathLen  = flag.Int("max-path-len", -1, "Path length constraint of a CA cert. 0 prevents the CA from "+
+		"issuing further CA certs, a negative value leaves it unset.")
+	permittedDNS = flag.String("permitted-dns-domains", "", "Comma-separated DNS domains set as name constraints "+
+		"of a CA cert.")
+	permittedURI = flag.String("permitted-uri-domains", "", "Comma-separated URI domains, e.g. trust domains, "+
+		"set as name constraints of a CA cert.")
 )
 
 func checkCmdLine() {
@@ -151,6 +158,16 @@ func main() {
 		IsServer:     *isServer,
 		ECSigAlg:     util.SupportedECSignatureAlgorithms(*ec),
 	}
+	if *isCA {
+		opts.MaxPathLen = *pathLen
+		opts.MaxPathLenZero = *pathLen == 0
+		if *permittedDNS != "" {
+			opts.PermittedDNSDomains = strings.Split(*permittedDNS, ",")
+		}
+		if *permittedURI != "" {
+			opts.PermittedURIDomains = strings.Split(*permittedURI, ",")
+		}
+	}
 	certPem, privPem, err := util.GenCertKeyFromOptions(opts)
 
 	if err != nil {